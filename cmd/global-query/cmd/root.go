@@ -7,6 +7,7 @@ import (
 	"github.com/els0r/goProbe/cmd/global-query/pkg/conf"
 	"github.com/els0r/goProbe/cmd/global-query/pkg/distributed"
 	"github.com/els0r/goProbe/cmd/global-query/pkg/hosts"
+	"github.com/els0r/goProbe/pkg/goDB/protocols"
 	"github.com/els0r/goProbe/pkg/query"
 	"github.com/els0r/goProbe/pkg/version"
 	"github.com/els0r/telemetry/logging"
@@ -52,6 +53,7 @@ func Execute() {
 
 func init() {
 	cobra.OnInitialize(initConfig)
+	cobra.OnInitialize(initProtocols)
 	cobra.OnInitialize(initLogger)
 
 	// help commands
@@ -64,6 +66,11 @@ func init() {
 	rootCmd.PersistentFlags().String(conf.QuerierType, conf.DefaultHostsQuerierType, "querier used to run queries")
 	rootCmd.PersistentFlags().String(conf.QuerierConfig, "", "querier config file location")
 	rootCmd.PersistentFlags().Int(conf.QuerierMaxConcurrent, 0, "maximum number of concurrent queries to hosts")
+	rootCmd.PersistentFlags().Int(conf.QuerierMaxPerHost, distributed.DefaultMaxPerHost, "maximum number of concurrent queries to a single host (across all running queries)")
+	rootCmd.PersistentFlags().Int(conf.QuerierMaxSlots, 0, "maximum number of concurrent queries to hosts across all running queries (0: unlimited)")
+	rootCmd.PersistentFlags().Int(conf.QuerierHostResults, distributed.DefaultHostResultsFactor, "multiple of the row limit of a query returned by each host (0: all rows)")
+	rootCmd.PersistentFlags().Int(conf.QuerierCircuitBreakerThreshold, distributed.DefaultCircuitBreakerThreshold, "number of failed queries within the cooldown period after which a host is skipped (0: disabled)")
+	rootCmd.PersistentFlags().Duration(conf.QuerierCircuitBreakerCooldown, distributed.DefaultCircuitBreakerCooldown, "period during which a repeatedly failing host is skipped")
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.global-query.yaml)")
 
@@ -111,6 +118,14 @@ func initConfig() {
 	}
 }
 
+// initProtocols applies the site-specific IP protocol names (if present)
+func initProtocols() {
+	if err := protocols.LoadOverrides(protocols.DefaultOverridesFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load IP protocols: %v\n", err)
+		os.Exit(1)
+	}
+}
+
 func initHostListResolver() (hosts.Resolver, error) {
 	resolverType := viper.GetString(conf.HostsResolverType)
 	switch resolverType {
@@ -124,11 +139,24 @@ func initHostListResolver() (hosts.Resolver, error) {
 
 func initQuerier() (distributed.Querier, error) {
 	querierType := viper.GetString(conf.QuerierType)
+
+	var (
+		querier distributed.Querier
+		err     error
+	)
 	switch querierType {
 	case string(distributed.APIClientQuerierType):
-		return distributed.NewAPIClientQuerier(viper.GetString(conf.QuerierConfig))
+		querier, err = distributed.NewAPIClientQuerier(viper.GetString(conf.QuerierConfig))
 	default:
-		err := fmt.Errorf("querier type %q not supported", querierType)
+		err = fmt.Errorf("querier type %q not supported", querierType)
+	}
+	if err != nil {
 		return nil, err
 	}
+
+	// skip hosts which failed repeatedly instead of waiting for them to time out on every query
+	if threshold := viper.GetInt(conf.QuerierCircuitBreakerThreshold); threshold > 0 {
+		querier = distributed.NewCircuitBreakerQuerier(querier, threshold, viper.GetDuration(conf.QuerierCircuitBreakerCooldown))
+	}
+	return querier, nil
 }
@@ -6,16 +6,30 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 
 	"github.com/els0r/goProbe/cmd/global-query/pkg/conf"
+	"github.com/els0r/goProbe/cmd/global-query/pkg/distributed"
+	"github.com/els0r/goProbe/cmd/global-query/pkg/reports"
+	"github.com/els0r/goProbe/pkg/api"
 	gqserver "github.com/els0r/goProbe/pkg/api/globalquery/server"
 	"github.com/els0r/goProbe/pkg/api/server"
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/telemetry/tracing"
 	"github.com/els0r/telemetry/logging"
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+var (
+	errorUnknownQuotaKey  = errors.New("API key with quota is not among the configured keys")
+	errorUnknownTenantKey = errors.New("tenant restricted API key is not among the configured keys")
+	errorNoKeyTenants     = errors.New("tenant restricted API key requires at least one tenant")
+	errorUnknownRoleKey   = errors.New("API key with role is not among the configured keys")
+)
+
 // serverCmd represents the server command
 var serverCmd = &cobra.Command{
 	Use:   "server",
@@ -32,8 +46,16 @@ func init() {
 	pflags.String(conf.ServerAddr, conf.DefaultServerAddr, "address to which the server binds")
 	pflags.Duration(conf.ServerShutdownGracePeriod, conf.DefaultServerShutdownGracePeriod, "duration the server will wait during shutdown before forcing shutdown")
 
+	pflags.StringSlice(conf.ServerKeys, nil, "API keys authorizing requests to the server. Access is unrestricted if empty")
+	pflags.Int64(conf.ServerMaxRequestBodySize, api.DefaultMaxRequestBodySize, "maximum size (in bytes) of compressed request bodies once decompressed")
+
+	pflags.String(conf.ReportsConfig, "", "scheduled reports config file location. No reports are generated if empty")
+
 	// telemetry
 	pflags.Bool(conf.ProfilingEnabled, false, "enable profiling endpoints")
+	pflags.String(conf.TracingCollectorEndpoint, "", "OTLP/HTTP collector endpoint (host:port) to which traces are exported. Tracing is disabled if empty")
+	pflags.Bool(conf.TracingCollectorInsecure, false, "disable TLS when connecting to the trace collector")
+	pflags.Float64(conf.TracingSampleRatio, conf.DefaultTracingSampleRatio, "fraction of queries which are traced")
 
 	_ = viper.BindPFlags(pflags)
 }
@@ -57,15 +79,83 @@ func serverEntrypoint(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	// set up tracing (if configured)
+	shutdownTracing, err := tracing.Init(ctx, conf.ServiceName,
+		tracing.WithEndpoint(viper.GetString(conf.TracingCollectorEndpoint)),
+		tracing.WithInsecure(viper.GetBool(conf.TracingCollectorInsecure)),
+		tracing.WithSampleRatio(viper.GetFloat64(conf.TracingSampleRatio)),
+	)
+	if err != nil {
+		logger.Errorf("failed to set up tracing: %v", err)
+		return err
+	}
+
+	// all queries share the same scheduler so that simultaneous queries don't hit the same hosts concurrently
+	queryOpts := []distributed.QueryOption{
+		distributed.WithMaxConcurrent(viper.GetInt(conf.QuerierMaxConcurrent)),
+		distributed.WithHostResultsFactor(viper.GetInt(conf.QuerierHostResults)),
+		distributed.WithScheduler(
+			distributed.NewScheduler(viper.GetInt(conf.QuerierMaxPerHost), viper.GetInt(conf.QuerierMaxSlots)),
+		),
+	}
+
+	// generate the scheduled reports (if configured)
+	var reportScheduler *reports.Scheduler
+	if reportsConfig := viper.GetString(conf.ReportsConfig); reportsConfig != "" {
+		cfg, err := reports.LoadConfig(reportsConfig)
+		if err != nil {
+			logger.Errorf("failed to load reports config: %v", err)
+			return err
+		}
+		reportScheduler, err = reports.NewScheduler(distributed.NewQueryRunner(hostListResolver, querier, queryOpts...), cfg)
+		if err != nil {
+			logger.Errorf("failed to set up report scheduler: %v", err)
+			return err
+		}
+		logger.With("reports", len(cfg.Reports)).Info("starting report scheduler")
+		go reportScheduler.Run(ctx)
+	}
+
 	// set up the API server
 	addr := viper.GetString(conf.ServerAddr)
-	apiServer := gqserver.New(addr, hostListResolver, querier,
+	apiOptions := []server.Option{
 		// Set the release mode of GIN depending on the log level
 		server.WithDebugMode(
 			logging.LevelFromString(viper.GetString(conf.LogLevel)) == logging.LevelDebug,
 		),
 		server.WithProfiling(viper.GetBool(conf.ProfilingEnabled)),
-	)
+		server.WithMaxRequestBodySize(viper.GetInt64(conf.ServerMaxRequestBodySize)),
+	}
+	if keys := viper.GetStringSlice(conf.ServerKeys); len(keys) > 0 {
+		keyQuotas, err := loadKeyQuotas(keys)
+		if err != nil {
+			logger.Errorf("failed to load API key quotas: %v", err)
+			return err
+		}
+		apiOptions = append(apiOptions, server.WithKeys(keys...), server.WithKeyQuotas(keyQuotas))
+	}
+	keyTenants, err := loadKeyTenants(viper.GetStringSlice(conf.ServerKeys))
+	if err != nil {
+		logger.Errorf("failed to load API key tenants: %v", err)
+		return err
+	}
+	keyRoles, err := loadKeyRoles(viper.GetStringSlice(conf.ServerKeys))
+	if err != nil {
+		logger.Errorf("failed to load API key roles: %v", err)
+		return err
+	}
+	if viper.IsSet(conf.ServerExport) {
+		exportConfig, err := loadExportConfig()
+		if err != nil {
+			logger.Errorf("failed to load export config: %v", err)
+			return err
+		}
+		apiOptions = append(apiOptions, server.WithExport(exportConfig))
+	}
+	apiServer := gqserver.New(addr, hostListResolver, querier, queryOpts, apiOptions...).
+		SetReportScheduler(reportScheduler).
+		SetKeyTenants(keyTenants).
+		SetKeyRoles(keyRoles)
 
 	// initializing the server in a goroutine so that it won't block the graceful
 	// shutdown handling below
@@ -95,6 +185,78 @@ func serverEntrypoint(cmd *cobra.Command, args []string) error {
 		logger.Errorf("forced shut down of API server: %v", err)
 	}
 
+	// flush any pending traces
+	err = shutdownTracing(ctx)
+	if err != nil {
+		logger.Errorf("failed to shut down tracing: %v", err)
+	}
+
 	logger.Info("shut down complete")
 	return nil
 }
+
+// loadKeyQuotas reads the daily quotas of the API keys from the configuration (only supported via
+// the config file). Quotas may only be set for configured keys
+func loadKeyQuotas(keys []string) (map[string]api.KeyQuota, error) {
+	var keyQuotas map[string]api.KeyQuota
+	err := viper.UnmarshalKey(conf.ServerKeyQuotas, &keyQuotas, func(cfg *mapstructure.DecoderConfig) {
+		cfg.TagName = "yaml"
+	})
+	if err != nil {
+		return nil, err
+	}
+	for key := range keyQuotas {
+		if !slices.Contains(keys, key) {
+			return nil, errorUnknownQuotaKey
+		}
+	}
+	return keyQuotas, nil
+}
+
+// loadKeyTenants reads the tenants the API keys are restricted to from the configuration (only supported
+// via the config file). Tenants may only be set for configured keys
+func loadKeyTenants(keys []string) (map[string][]string, error) {
+	keyTenants := viper.GetStringMapStringSlice(conf.ServerKeyTenants)
+	for key, tenants := range keyTenants {
+		if !slices.Contains(keys, key) {
+			return nil, errorUnknownTenantKey
+		}
+		if len(tenants) == 0 {
+			return nil, errorNoKeyTenants
+		}
+		for _, tenant := range tenants {
+			if err := info.ValidateTenant(tenant); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return keyTenants, nil
+}
+
+// loadKeyRoles reads the roles of the API keys from the configuration (only supported via the config
+// file). Roles may only be set for configured keys
+func loadKeyRoles(keys []string) (map[string]api.Role, error) {
+	keyRoles := make(map[string]api.Role)
+	for key, role := range viper.GetStringMapString(conf.ServerKeyRoles) {
+		if !slices.Contains(keys, key) {
+			return nil, errorUnknownRoleKey
+		}
+		if err := api.Role(role).Validate(); err != nil {
+			return nil, err
+		}
+		keyRoles[key] = api.Role(role)
+	}
+	return keyRoles, nil
+}
+
+// loadExportConfig reads the destinations query results may be exported to from the configuration
+// (only supported via the config file)
+func loadExportConfig() (cfg api.ExportConfig, err error) {
+	err = viper.UnmarshalKey(conf.ServerExport, &cfg, func(cfg *mapstructure.DecoderConfig) {
+		cfg.TagName = "yaml"
+	})
+	if err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.Validate()
+}
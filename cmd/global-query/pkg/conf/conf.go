@@ -16,6 +16,12 @@ const (
 	profilingKey     = "profiling"
 	ProfilingEnabled = profilingKey + ".enabled"
 
+	tracingKey               = "tracing"
+	tracingCollectorKey      = tracingKey + ".collector"
+	TracingCollectorEndpoint = tracingCollectorKey + ".endpoint"
+	TracingCollectorInsecure = tracingCollectorKey + ".insecure"
+	TracingSampleRatio       = tracingKey + ".sample_ratio"
+
 	hostsKey         = "hosts"
 	hostsResolverKey = hostsKey + ".resolver"
 
@@ -26,10 +32,26 @@ const (
 	QuerierType          = querierKey + ".type"
 	QuerierConfig        = querierKey + ".config"
 	QuerierMaxConcurrent = querierKey + ".max_concurrent"
+	QuerierMaxPerHost    = querierKey + ".max_per_host"
+	QuerierMaxSlots      = querierKey + ".max_slots"
+	QuerierHostResults   = querierKey + ".host_results_factor"
+
+	querierCircuitBreakerKey       = querierKey + ".circuit_breaker"
+	QuerierCircuitBreakerThreshold = querierCircuitBreakerKey + ".threshold"
+	QuerierCircuitBreakerCooldown  = querierCircuitBreakerKey + ".cooldown"
+
+	reportsKey    = "reports"
+	ReportsConfig = reportsKey + ".config"
 
 	serverKey                 = "server"
 	ServerAddr                = serverKey + ".addr"
 	ServerShutdownGracePeriod = serverKey + ".shutdowngraceperiod"
+	ServerKeys                = serverKey + ".keys"
+	ServerKeyQuotas           = serverKey + ".key_quotas"
+	ServerKeyTenants          = serverKey + ".key_tenants"
+	ServerKeyRoles            = serverKey + ".key_roles"
+	ServerExport              = serverKey + ".export"
+	ServerMaxRequestBodySize  = serverKey + ".max_request_body_size"
 )
 
 // Global defaults for command line parameters / arguments
@@ -43,4 +65,6 @@ const (
 
 	DefaultServerAddr                = "localhost:8145"
 	DefaultServerShutdownGracePeriod = 30 * time.Second
+
+	DefaultTracingSampleRatio = 1.0
 )
@@ -0,0 +1,160 @@
+package distributed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/results"
+)
+
+const (
+	// DefaultCircuitBreakerThreshold denotes the default number of failed queries within the cooldown
+	// period after which a host is skipped
+	DefaultCircuitBreakerThreshold = 3
+
+	// DefaultCircuitBreakerCooldown denotes the default period during which a host is skipped once its
+	// circuit is open (and within which failures are accumulated)
+	DefaultCircuitBreakerCooldown = time.Minute
+)
+
+// ErrCircuitOpen is returned for hosts which were skipped because they failed repeatedly
+var ErrCircuitOpen = errors.New("skipped: circuit open")
+
+// CircuitBreakerQuerier wraps a Querier and keeps track of recent failures per host. Hosts which failed
+// at least threshold times within the cooldown period are skipped for the duration of the cooldown, so
+// that unreachable hosts don't add a full timeout to every query. Once the cooldown has passed, a single
+// query is let through to probe the host: if it succeeds, the circuit is closed again. Otherwise, the
+// host is skipped for another cooldown period.
+//
+// In order to share the failure history, the same instance has to be used for all queries
+type CircuitBreakerQuerier struct {
+	querier   Querier
+	threshold int
+	cooldown  time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostCircuit
+
+	now func() time.Time
+}
+
+type hostCircuit struct {
+	failures    int
+	lastFailure time.Time
+	openUntil   time.Time
+}
+
+// NewCircuitBreakerQuerier creates a new circuit breaker around querier. If threshold or cooldown
+// aren't positive, DefaultCircuitBreakerThreshold and DefaultCircuitBreakerCooldown are used
+func NewCircuitBreakerQuerier(querier Querier, threshold int, cooldown time.Duration) *CircuitBreakerQuerier {
+	if threshold <= 0 {
+		threshold = DefaultCircuitBreakerThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultCircuitBreakerCooldown
+	}
+	return &CircuitBreakerQuerier{
+		querier:   querier,
+		threshold: threshold,
+		cooldown:  cooldown,
+		hosts:     make(map[string]*hostCircuit),
+		now:       time.Now,
+	}
+}
+
+// CreateQueryWorkload prepares the workload of the wrapped querier. If the circuit of the host is open,
+// the workload fails immediately with ErrCircuitOpen instead of querying the host
+func (c *CircuitBreakerQuerier) CreateQueryWorkload(ctx context.Context, host string, args *query.Args) (*QueryWorkload, error) {
+	qw, err := c.querier.CreateQueryWorkload(ctx, host, args)
+	if err != nil || qw == nil {
+		return qw, err
+	}
+
+	if err := c.allow(host); err != nil {
+		qw.Runner = &errorRunner{err: err}
+		return qw, nil
+	}
+	qw.Runner = &circuitBreakerRunner{
+		Runner:  qw.Runner,
+		breaker: c,
+		host:    host,
+	}
+	return qw, nil
+}
+
+// allow checks whether the host may be queried. If the circuit of the host is open, the returned error
+// wraps ErrCircuitOpen
+func (c *CircuitBreakerQuerier) allow(host string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hc, exists := c.hosts[host]
+	if !exists || hc.failures < c.threshold {
+		return nil
+	}
+
+	now := c.now()
+	if now.Before(hc.openUntil) {
+		return fmt.Errorf("%w: %d failed queries, retrying in %s", ErrCircuitOpen, hc.failures, hc.openUntil.Sub(now).Round(time.Second))
+	}
+
+	// the cooldown has passed: let this query probe the host and skip all others until its outcome
+	// is known (or another cooldown has passed, in case it never completes)
+	hc.openUntil = now.Add(c.cooldown)
+	return nil
+}
+
+func (c *CircuitBreakerQuerier) recordSuccess(host string) {
+	c.mu.Lock()
+	delete(c.hosts, host)
+	c.mu.Unlock()
+}
+
+func (c *CircuitBreakerQuerier) recordFailure(host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	hc, exists := c.hosts[host]
+	if !exists {
+		hc = &hostCircuit{}
+		c.hosts[host] = hc
+	}
+
+	// failures which lie further back than the cooldown period are forgotten (unless the circuit
+	// is open already)
+	if hc.failures < c.threshold && now.Sub(hc.lastFailure) > c.cooldown {
+		hc.failures = 0
+	}
+	hc.failures++
+	hc.lastFailure = now
+
+	if hc.failures >= c.threshold {
+		hc.openUntil = now.Add(c.cooldown)
+	}
+}
+
+// circuitBreakerRunner records the outcome of a query in the circuit breaker
+type circuitBreakerRunner struct {
+	query.Runner
+
+	breaker *CircuitBreakerQuerier
+	host    string
+}
+
+func (r *circuitBreakerRunner) Run(ctx context.Context, args *query.Args) (*results.Result, error) {
+	res, err := r.Runner.Run(ctx, args)
+	switch {
+	case err == nil:
+		r.breaker.recordSuccess(r.host)
+	case errors.Is(err, context.Canceled):
+		// the query was aborted by the caller, which says nothing about the host
+	default:
+		r.breaker.recordFailure(r.host)
+	}
+	return res, err
+}
@@ -0,0 +1,146 @@
+package distributed
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/els0r/goProbe/cmd/global-query/pkg/hosts"
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+var errHostDown = errors.New("connection refused")
+
+// testQuerier runs queries against hosts which are either up or down
+type testQuerier struct {
+	down    map[string]bool
+	queried map[string]int
+	mu      sync.Mutex
+}
+
+func (q *testQuerier) CreateQueryWorkload(_ context.Context, host string, args *query.Args) (*QueryWorkload, error) {
+	return &QueryWorkload{
+		Host:   host,
+		Args:   args,
+		Runner: &testRunner{querier: q, host: host},
+	}, nil
+}
+
+type testRunner struct {
+	querier *testQuerier
+	host    string
+}
+
+func (r *testRunner) Run(_ context.Context, _ *query.Args) (*results.Result, error) {
+	r.querier.mu.Lock()
+	defer r.querier.mu.Unlock()
+
+	r.querier.queried[r.host]++
+	if r.querier.down[r.host] {
+		return nil, errHostDown
+	}
+	res := results.New()
+	res.HostsStatuses = results.HostsStatuses{r.host: results.Status{Code: types.StatusOK}}
+	return res, nil
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	querier := &testQuerier{
+		down:    map[string]bool{"host-b": true},
+		queried: make(map[string]int),
+	}
+	breaker := NewCircuitBreakerQuerier(querier, 2, time.Minute)
+
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	breaker.now = func() time.Time { return now }
+
+	run := func(host string) error {
+		wl, err := breaker.CreateQueryWorkload(context.Background(), host, &query.Args{})
+		require.Nil(t, err)
+		_, err = wl.Runner.Run(context.Background(), wl.Args)
+		return err
+	}
+
+	// failures further apart than the cooldown don't open the circuit
+	require.ErrorIs(t, run("host-b"), errHostDown)
+	now = now.Add(2 * time.Minute)
+	require.ErrorIs(t, run("host-b"), errHostDown)
+	require.ErrorIs(t, run("host-b"), errHostDown)
+	require.Equal(t, 3, querier.queried["host-b"])
+
+	// the circuit is open, the host is skipped during the cooldown
+	now = now.Add(30 * time.Second)
+	err := run("host-b")
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	require.Equal(t, "skipped: circuit open: 2 failed queries, retrying in 30s", err.Error())
+	require.Equal(t, 3, querier.queried["host-b"])
+
+	// other hosts are unaffected
+	require.Nil(t, run("host-a"))
+
+	// after the cooldown, a single query probes the host
+	now = now.Add(time.Minute)
+	wl, err := breaker.CreateQueryWorkload(context.Background(), "host-b", &query.Args{})
+	require.Nil(t, err)
+	require.ErrorIs(t, run("host-b"), ErrCircuitOpen)
+
+	// if the probe fails, the host is skipped for another cooldown period
+	_, err = wl.Runner.Run(context.Background(), wl.Args)
+	require.ErrorIs(t, err, errHostDown)
+	require.Equal(t, 4, querier.queried["host-b"])
+	now = now.Add(59 * time.Second)
+	require.ErrorIs(t, run("host-b"), ErrCircuitOpen)
+
+	// a successful probe closes the circuit again
+	querier.down["host-b"] = false
+	now = now.Add(time.Second)
+	require.Nil(t, run("host-b"))
+	require.Nil(t, run("host-b"))
+	require.Equal(t, 6, querier.queried["host-b"])
+}
+
+func TestCircuitBreakerIgnoresCancellation(t *testing.T) {
+	querier := &testQuerier{queried: make(map[string]int)}
+	breaker := NewCircuitBreakerQuerier(querier, 1, time.Minute)
+
+	wl, err := breaker.CreateQueryWorkload(context.Background(), "host-a", &query.Args{})
+	require.Nil(t, err)
+
+	runner := wl.Runner.(*circuitBreakerRunner)
+	runner.Runner = &errorRunner{err: context.Canceled}
+	_, err = wl.Runner.Run(context.Background(), wl.Args)
+	require.ErrorIs(t, err, context.Canceled)
+
+	require.Nil(t, breaker.allow("host-a"))
+}
+
+func TestCircuitBreakerHostsStatuses(t *testing.T) {
+	querier := &testQuerier{
+		down:    map[string]bool{"host-b": true},
+		queried: make(map[string]int),
+	}
+	qr := NewQueryRunner(hosts.NewStringResolver(true), NewCircuitBreakerQuerier(querier, 1, time.Minute))
+
+	args := query.NewArgs("sip", "eth0", query.WithFirst("-1h"))
+	args.QueryHosts = "host-a,host-b"
+
+	res, err := qr.Run(context.Background(), args)
+	require.Nil(t, err)
+	require.Equal(t, types.StatusError, res.HostsStatuses["host-b"].Code)
+	require.Equal(t, errHostDown.Error(), res.HostsStatuses["host-b"].Message)
+
+	// the second query doesn't reach the host anymore
+	res, err = qr.Run(context.Background(), args)
+	require.Nil(t, err)
+	require.Equal(t, types.StatusOK, res.HostsStatuses["host-a"].Code)
+	require.Equal(t, types.StatusError, res.HostsStatuses["host-b"].Code)
+	require.True(t, strings.HasPrefix(res.HostsStatuses["host-b"].Message, ErrCircuitOpen.Error()), res.HostsStatuses["host-b"].Message)
+	require.Equal(t, 1, querier.queried["host-b"])
+	require.Equal(t, 2, querier.queried["host-a"])
+}
@@ -5,12 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/els0r/goProbe/cmd/global-query/pkg/hosts"
 	"github.com/els0r/goProbe/pkg/query"
 	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/telemetry/tracing"
 	"github.com/els0r/goProbe/pkg/types"
 	"github.com/els0r/telemetry/logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // QueryRunner denotes a query runner / executor, wrapping a Querier interface instance with
@@ -19,9 +23,20 @@ type QueryRunner struct {
 	resolver hosts.Resolver
 	querier  Querier
 
-	maxConcurrent int
+	maxConcurrent     int
+	maxClockSkew      time.Duration
+	hostResultsFactor int
+
+	scheduler *Scheduler
 }
 
+// DefaultMaxClockSkew denotes the clock offset of a host beyond which it is flagged in the
+// hosts statuses of the result
+const DefaultMaxClockSkew = 30 * time.Second
+
+// DefaultHostResultsFactor denotes the multiple of the global row limit each host is asked to return
+const DefaultHostResultsFactor = 4
+
 // QueryOption configures the query runner
 type QueryOption func(*QueryRunner)
 
@@ -33,11 +48,39 @@ func WithMaxConcurrent(n int) QueryOption {
 	}
 }
 
+// WithMaxClockSkew sets the clock offset of a host relative to the querying host beyond which
+// the host is flagged in the hosts statuses of the result. A negative value disables the check
+func WithMaxClockSkew(d time.Duration) QueryOption {
+	return func(qr *QueryRunner) {
+		qr.maxClockSkew = d
+	}
+}
+
+// WithHostResultsFactor sets the multiple of the global row limit each host returns (at least
+// query.DefaultNumResults rows), so that hosts send bounded result sets instead of all their rows.
+// Since a row's global rank depends on its counters on all hosts, a higher factor improves the
+// accuracy of the merged top rows. A factor smaller than 1 disables the limit
+func WithHostResultsFactor(factor int) QueryOption {
+	return func(qr *QueryRunner) {
+		qr.hostResultsFactor = factor
+	}
+}
+
+// WithScheduler assigns a scheduler limiting the number of concurrent queries per host (and in total).
+// In order to coordinate simultaneous queries, the same scheduler has to be used by all query runners
+func WithScheduler(s *Scheduler) QueryOption {
+	return func(qr *QueryRunner) {
+		qr.scheduler = s
+	}
+}
+
 // NewQueryRunner instantiates a new distributed query runner
 func NewQueryRunner(resolver hosts.Resolver, querier Querier, opts ...QueryOption) (qr *QueryRunner) {
 	qr = &QueryRunner{
-		resolver: resolver,
-		querier:  querier,
+		resolver:          resolver,
+		querier:           querier,
+		maxClockSkew:      DefaultMaxClockSkew,
+		hostResultsFactor: DefaultHostResultsFactor,
 	}
 	for _, opt := range opts {
 		opt(qr)
@@ -46,17 +89,30 @@ func NewQueryRunner(resolver hosts.Resolver, querier Querier, opts ...QueryOptio
 }
 
 // Run executes / runs the query and creates the final result structure
-func (q *QueryRunner) Run(ctx context.Context, args *query.Args) (*results.Result, error) {
+func (q *QueryRunner) Run(ctx context.Context, args *query.Args) (res *results.Result, err error) {
+	ctx, span := tracing.Start(ctx, "distributed.QueryRunner.Run", trace.WithAttributes(
+		attribute.String("query", args.Query),
+		attribute.String("ifaces", args.Ifaces),
+		attribute.String("condition", args.Condition),
+	))
+	defer func() {
+		tracing.Error(span, err)
+		span.End()
+	}()
+
 	// use a copy of the arguments, since some fields are modified by the querier
 	queryArgs := *args
 
 	// a distributed query, by definition, requires a list of hosts to query
 	if queryArgs.QueryHosts == "" {
-		return nil, fmt.Errorf("couldn't prepare query: list of target hosts is empty")
+		return nil, fmt.Errorf("%w: list of target hosts is empty", query.ErrInvalidArgs)
 	}
 
 	// check if the statement can be created
+	_, prepareSpan := tracing.Start(ctx, "Args.Prepare")
 	stmt, err := queryArgs.Prepare()
+	tracing.Error(prepareSpan, err)
+	prepareSpan.End()
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare query statement: %w", err)
 	}
@@ -65,6 +121,7 @@ func (q *QueryRunner) Run(ctx context.Context, args *query.Args) (*results.Resul
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve host list: %w", err)
 	}
+	span.SetAttributes(attribute.Int("hosts", len(hostList)))
 
 	// log the query
 	logger := logging.Logger().With("hosts", hostList)
@@ -78,14 +135,35 @@ func (q *QueryRunner) Run(ctx context.Context, args *query.Args) (*results.Resul
 
 	logger.With("runners", numRunners).Info("dispatching queries")
 
-	finalResult := aggregateResults(ctx, stmt,
-		runQueries(ctx, numRunners,
-			prepareQueries(ctx, q.querier, hostList, &queryArgs),
+	var slots *schedulerQueue
+	if q.scheduler != nil {
+		slots = q.scheduler.register()
+		defer slots.close()
+	}
+
+	// the hosts only return their top rows (according to the sort key), since any rows beyond the
+	// global limit are discarded after merging anyway
+	hostArgs := queryArgs
+	hostArgs.NumResults = hostNumResults(stmt, q.hostResultsFactor)
+	span.SetAttributes(attribute.Int64("host_limit", int64(hostArgs.NumResults)))
+
+	// the merged result is exported by the querying instance (if requested), not by each host
+	hostArgs.Export = ""
+
+	finalResult := aggregateResults(ctx, stmt, q.maxClockSkew,
+		runQueries(ctx, numRunners, slots,
+			prepareQueries(ctx, q.querier, hostList, &hostArgs),
 		),
 	)
 
 	finalResult.End()
 
+	// apply the filters on hostnames / enriched columns (if any) to the merged rows, since merging drops
+	// the hostnames / enrichments the hosts filtered on
+	if err := stmt.FilterRows(ctx, finalResult); err != nil {
+		return nil, fmt.Errorf("failed to filter rows: %w", err)
+	}
+
 	// truncate results based on the limit
 	if queryArgs.NumResults < uint64(len(finalResult.Rows)) {
 		finalResult.Rows = finalResult.Rows[:queryArgs.NumResults]
@@ -95,6 +173,20 @@ func (q *QueryRunner) Run(ctx context.Context, args *query.Args) (*results.Resul
 	return finalResult, nil
 }
 
+// hostNumResults derives the number of rows each host returns from the global limit and sort order of
+// the statement. Rows sorted by time or in ascending order (or filtered, since the hosts may resolve hostnames
+// differently) can't be bounded per host without dropping rows of the global result, hence all rows are
+// requested for them
+func hostNumResults(stmt *query.Statement, factor int) uint64 {
+	if factor < 1 || stmt.SortBy == results.SortTime || stmt.SortAscending || len(stmt.Filter) > 0 {
+		return query.MaxResults
+	}
+	if stmt.NumResults > query.MaxResults/uint64(factor) {
+		return query.MaxResults
+	}
+	return max(stmt.NumResults*uint64(factor), query.DefaultNumResults)
+}
+
 // prepareQueries creates query workloads for all hosts in the host list and returns the channel it sends the
 // workloads on
 func prepareQueries(ctx context.Context, querier Querier, hostList hosts.Hosts, args *query.Args) <-chan *QueryWorkload {
@@ -117,8 +209,8 @@ func prepareQueries(ctx context.Context, querier Querier, hostList hosts.Hosts,
 }
 
 // runQueries takes query workloads from the workloads channel, runs them, and returns a channel from which
-// the results can be read
-func runQueries(ctx context.Context, maxConcurrent int, workloads <-chan *QueryWorkload) <-chan *queryResponse {
+// the results can be read. If slots is provided, each workload waits for a slot of its host before running
+func runQueries(ctx context.Context, maxConcurrent int, slots *schedulerQueue, workloads <-chan *QueryWorkload) <-chan *queryResponse {
 	out := make(chan *queryResponse, maxConcurrent)
 
 	wg := new(sync.WaitGroup)
@@ -135,15 +227,35 @@ func runQueries(ctx context.Context, maxConcurrent int, workloads <-chan *QueryW
 						return
 					}
 
-					res, err := wl.Runner.Run(ctx, wl.Args)
+					hostCtx, span := tracing.Start(ctx, "QueryWorkload.Run", trace.WithAttributes(
+						attribute.String("host", wl.Host),
+					))
+
+					var (
+						res            *results.Result
+						sent, received time.Time
+					)
+					release, err := acquireSlot(hostCtx, slots, wl.Host)
+					if err == nil {
+						sent = time.Now()
+						res, err = wl.Runner.Run(hostCtx, wl.Args)
+						received = time.Now()
+						release()
+					}
 					if err != nil {
 						err = fmt.Errorf("failed to run query: %w", err)
+					} else {
+						span.SetAttributes(attribute.Int("hits", res.Summary.Hits.Total))
 					}
+					tracing.Error(span, err)
+					span.End()
 
 					qr := &queryResponse{
-						host:   wl.Host,
-						result: res,
-						err:    err,
+						host:     wl.Host,
+						result:   res,
+						err:      err,
+						sent:     sent,
+						received: received,
 					}
 
 					out <- qr
@@ -158,9 +270,21 @@ func runQueries(ctx context.Context, maxConcurrent int, workloads <-chan *QueryW
 	return out
 }
 
+func acquireSlot(ctx context.Context, slots *schedulerQueue, host string) (release func(), err error) {
+	if slots == nil {
+		return func() {}, nil
+	}
+	release, err = slots.acquire(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire query slot: %w", err)
+	}
+	return release, nil
+}
+
 // aggregateResults takes finished query workloads from the workloads channel, aggregates the result by merging the rows and summaries,
-// and returns the final result. The `tracker` variable provides information about potential Run failures for individual hosts
-func aggregateResults(ctx context.Context, stmt *query.Statement, queryResults <-chan *queryResponse) (finalResult *results.Result) {
+// and returns the final result. The `tracker` variable provides information about potential Run failures for individual hosts.
+// Hosts whose clock is off by more than maxClockSkew are flagged in the hosts statuses
+func aggregateResults(ctx context.Context, stmt *query.Statement, maxClockSkew time.Duration, queryResults <-chan *queryResponse) (finalResult *results.Result) {
 	// aggregation
 	finalResult = results.New()
 	finalResult.Start()
@@ -189,14 +313,19 @@ func aggregateResults(ctx context.Context, stmt *query.Statement, queryResults <
 			}
 			logger := logger.With("hostname", qr.host)
 			if qr.err != nil {
-				// unwrap the error if it's possible
 				var msg string
-
-				uerr := errors.Unwrap(qr.err)
-				if uerr != nil {
-					msg = uerr.Error()
-				} else {
-					msg = qr.err.Error()
+				switch {
+				case errors.Is(qr.err, query.ErrQueryTimeout), errors.Is(qr.err, context.DeadlineExceeded):
+					msg = query.ErrQueryTimeout.Error()
+				case errors.Is(qr.err, query.ErrMemoryBreach):
+					msg = query.ErrMemoryBreach.Error()
+				default:
+					// unwrap the error if it's possible
+					if uerr := errors.Unwrap(qr.err); uerr != nil {
+						msg = uerr.Error()
+					} else {
+						msg = qr.err.Error()
+					}
 				}
 
 				finalResult.HostsStatuses[qr.host] = results.Status{
@@ -208,7 +337,20 @@ func aggregateResults(ctx context.Context, stmt *query.Statement, queryResults <
 			}
 
 			res := qr.result
+
+			// skew silently corrupts time-based aggregation, hence it is surfaced alongside the status
+			skew := qr.clockSkew()
+			flagSkew := maxClockSkew >= 0 && skew.Abs() > maxClockSkew
+			if flagSkew {
+				logger.With("skew", skew).Warn("detected clock skew")
+			}
 			for host, status := range res.HostsStatuses {
+				if flagSkew {
+					status.ClockSkew = skew
+					if status.Message == "" {
+						status.Message = fmt.Sprintf("clock skew of %s", skew)
+					}
+				}
 				finalResult.HostsStatuses[host] = status
 			}
 
@@ -230,6 +372,38 @@ func aggregateResults(ctx context.Context, stmt *query.Statement, queryResults <
 			finalResult.Summary.First = res.Summary.First
 			finalResult.Summary.Last = res.Summary.Last
 			finalResult.Summary.Totals = finalResult.Summary.Totals.Add(res.Summary.Totals)
+			finalResult.Summary.Gaps = append(finalResult.Summary.Gaps, res.Summary.Gaps...)
+			finalResult.Summary.Corruptions = append(finalResult.Summary.Corruptions, res.Summary.Corruptions...)
+			finalResult.Summary.Anomalies = append(finalResult.Summary.Anomalies, res.Summary.Anomalies...)
+
+			// the counters of a merged row underestimate the actual ones by at most the sum of the
+			// errors of all hosts
+			if approx := res.Summary.Approximation; approx != nil {
+				if finalResult.Summary.Approximation == nil {
+					finalResult.Summary.Approximation = &results.Approximation{}
+				}
+				finalResult.Summary.Approximation.Capacity = max(finalResult.Summary.Approximation.Capacity, approx.Capacity)
+				finalResult.Summary.Approximation.MaxError += approx.MaxError
+			}
+			if quality := res.Summary.CaptureQuality; quality != nil {
+				if finalResult.Summary.CaptureQuality == nil {
+					finalResult.Summary.CaptureQuality = &results.CaptureQuality{}
+				}
+				*finalResult.Summary.CaptureQuality = finalResult.Summary.CaptureQuality.Merge(*quality)
+			}
+			if dedup := res.Summary.Deduplication; dedup != nil {
+				if finalResult.Summary.Deduplication == nil {
+					finalResult.Summary.Deduplication = &results.Deduplication{}
+				}
+				finalResult.Summary.Deduplication.Flows += dedup.Flows
+				finalResult.Summary.Deduplication.Counters = finalResult.Summary.Deduplication.Counters.Add(dedup.Counters)
+			}
+			if resources := res.Summary.Resources; resources != nil {
+				if finalResult.Summary.Resources == nil {
+					finalResult.Summary.Resources = &results.Resources{}
+				}
+				*finalResult.Summary.Resources = finalResult.Summary.Resources.Merge(*resources)
+			}
 
 			// take the total from the query result. Since there may be overlap between the queries of two
 			// different systems, the overlap has to be deducted from the total
@@ -250,4 +424,35 @@ type queryResponse struct {
 	host   string
 	result *results.Result
 	err    error
+
+	// sent and received bracket the host's local time during the query (as measured
+	// by the querying host)
+	sent, received time.Time
+}
+
+// clockSkew estimates the offset of the host's clock relative to the local one. The host's local
+// time at the end of the query is expected to lie between the time the query was sent and the time
+// the response was received. Data reported to end after the response was received indicates skew
+// as well
+func (qr *queryResponse) clockSkew() time.Duration {
+	if qr.result == nil || qr.result.Summary.Timings.QueryStart.IsZero() {
+		return 0
+	}
+
+	var skew time.Duration
+	hostTime := qr.result.Summary.Timings.QueryStart.Add(qr.result.Summary.Timings.QueryDuration)
+	switch {
+	case hostTime.Before(qr.sent):
+		skew = hostTime.Sub(qr.sent)
+	case hostTime.After(qr.received):
+		skew = hostTime.Sub(qr.received)
+	}
+
+	// the covered time range of the host is based on the host's clock and must not lie in the future
+	if last := qr.result.Summary.Last; last.After(qr.received) {
+		if dataSkew := last.Sub(qr.received); dataSkew > skew {
+			skew = dataSkew
+		}
+	}
+	return skew
 }
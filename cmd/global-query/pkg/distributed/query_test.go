@@ -0,0 +1,112 @@
+package distributed
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/els0r/goProbe/cmd/global-query/pkg/hosts"
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClockSkew(t *testing.T) {
+	sent := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	received := sent.Add(2 * time.Second)
+
+	var tests = []struct {
+		name      string
+		hostStart time.Time
+		last      time.Time
+		expected  time.Duration
+	}{
+		{"in sync", sent.Add(500 * time.Millisecond), sent.Add(-time.Minute), 0},
+		{"behind", sent.Add(-time.Minute), sent.Add(-2 * time.Minute), -time.Minute + time.Second},
+		{"ahead", received.Add(time.Minute), received, time.Minute + time.Second},
+		{"data in the future", sent, received.Add(5 * time.Minute), 5 * time.Minute},
+		{"no timings", time.Time{}, time.Time{}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := results.New()
+			res.Summary.Timings.QueryStart = test.hostStart
+			res.Summary.Timings.QueryDuration = time.Second
+			res.Summary.Last = test.last
+
+			qr := &queryResponse{
+				result:   res,
+				sent:     sent,
+				received: received,
+			}
+			require.Equal(t, test.expected, qr.clockSkew())
+		})
+	}
+}
+
+func TestHostNumResults(t *testing.T) {
+	var tests = []struct {
+		name     string
+		stmt     query.Statement
+		factor   int
+		expected uint64
+	}{
+		{"small limit", query.Statement{NumResults: 10, SortBy: results.SortTraffic}, 4, query.DefaultNumResults},
+		{"large limit", query.Statement{NumResults: 5000, SortBy: results.SortPackets}, 4, 20000},
+		{"overflow", query.Statement{NumResults: query.MaxResults, SortBy: results.SortTraffic}, 4, query.MaxResults},
+		{"disabled", query.Statement{NumResults: 10, SortBy: results.SortTraffic}, 0, query.MaxResults},
+		{"ascending", query.Statement{NumResults: 10, SortBy: results.SortTraffic, SortAscending: true}, 4, query.MaxResults},
+		{"time", query.Statement{NumResults: 10, SortBy: results.SortTime}, 4, query.MaxResults},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, hostNumResults(&test.stmt, test.factor))
+		})
+	}
+}
+
+// limitQuerier records the row limits the hosts are queried with
+type limitQuerier struct {
+	limits map[string]uint64
+	mu     sync.Mutex
+}
+
+func (q *limitQuerier) CreateQueryWorkload(_ context.Context, host string, args *query.Args) (*QueryWorkload, error) {
+	return &QueryWorkload{
+		Host:   host,
+		Args:   args,
+		Runner: &limitRunner{querier: q, host: host},
+	}, nil
+}
+
+type limitRunner struct {
+	querier *limitQuerier
+	host    string
+}
+
+func (r *limitRunner) Run(_ context.Context, args *query.Args) (*results.Result, error) {
+	r.querier.mu.Lock()
+	defer r.querier.mu.Unlock()
+
+	r.querier.limits[r.host] = args.NumResults
+	res := results.New()
+	res.HostsStatuses = results.HostsStatuses{r.host: results.Status{Code: types.StatusOK}}
+	return res, nil
+}
+
+func TestHostResultsLimit(t *testing.T) {
+	querier := &limitQuerier{limits: make(map[string]uint64)}
+
+	args := query.NewArgs("sip", "eth0", query.WithNumResults(500))
+	args.QueryHosts = "host-a,host-b"
+	_, err := NewQueryRunner(hosts.NewStringResolver(true), querier, WithHostResultsFactor(3)).Run(context.Background(), args)
+	require.Nil(t, err)
+	require.Equal(t, map[string]uint64{"host-a": 1500, "host-b": 1500}, querier.limits)
+
+	// the arguments of the caller are left untouched
+	require.Equal(t, uint64(500), args.NumResults)
+}
@@ -0,0 +1,181 @@
+package distributed
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultMaxPerHost denotes the default number of queries which may run concurrently against
+// a single host
+const DefaultMaxPerHost = 1
+
+// Scheduler hands out query slots to distributed queries. It is meant to be shared among all
+// queries run by a global-query instance: it limits the number of in-flight queries per target
+// host (and optionally in total) and serves the queries waiting for a slot in a round-robin
+// fashion, so that simultaneous queries of several users make progress at the same pace instead
+// of hammering the same hosts concurrently
+type Scheduler struct {
+	maxPerHost int
+	maxSlots   int
+
+	mu       sync.Mutex
+	inFlight map[string]int
+	total    int
+
+	// queues holds all registered queries in round-robin order, next denotes the position of
+	// the query which is served first on the next dispatch
+	queues []*schedulerQueue
+	next   int
+}
+
+// NewScheduler creates a new scheduler allowing at most maxPerHost concurrent queries per host
+// and at most maxSlots concurrent queries in total. If maxPerHost isn't positive, DefaultMaxPerHost
+// is used. If maxSlots isn't positive, the total number of concurrent queries is not limited
+func NewScheduler(maxPerHost, maxSlots int) *Scheduler {
+	if maxPerHost <= 0 {
+		maxPerHost = DefaultMaxPerHost
+	}
+	return &Scheduler{
+		maxPerHost: maxPerHost,
+		maxSlots:   maxSlots,
+		inFlight:   make(map[string]int),
+	}
+}
+
+// schedulerQueue tracks the slot requests of a single distributed query
+type schedulerQueue struct {
+	scheduler *Scheduler
+	waiting   []*slotRequest
+}
+
+type slotRequest struct {
+	host    string
+	granted bool
+	ready   chan struct{}
+}
+
+// register adds a new query to the scheduler. The returned queue must be closed once the query
+// is done
+func (s *Scheduler) register() *schedulerQueue {
+	q := &schedulerQueue{scheduler: s}
+
+	s.mu.Lock()
+	s.queues = append(s.queues, q)
+	s.mu.Unlock()
+
+	return q
+}
+
+// acquire blocks until a slot for host is available or the context is done. On success, the
+// returned function has to be called to hand back the slot
+func (q *schedulerQueue) acquire(ctx context.Context, host string) (release func(), err error) {
+	s := q.scheduler
+	req := &slotRequest{host: host, ready: make(chan struct{})}
+
+	s.mu.Lock()
+	q.waiting = append(q.waiting, req)
+	s.dispatch()
+	s.mu.Unlock()
+
+	select {
+	case <-req.ready:
+		return func() { s.release(host) }, nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		// the slot may have been granted in the meantime, in which case it has to be handed back
+		if req.granted {
+			s.free(host)
+		} else {
+			q.remove(req)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// close removes the query from the scheduler
+func (q *schedulerQueue) close() {
+	s := q.scheduler
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, queue := range s.queues {
+		if queue == q {
+			s.queues = append(s.queues[:i], s.queues[i+1:]...)
+			if s.next > i {
+				s.next--
+			}
+			break
+		}
+	}
+	if s.next >= len(s.queues) {
+		s.next = 0
+	}
+}
+
+func (q *schedulerQueue) remove(req *slotRequest) {
+	for i, r := range q.waiting {
+		if r == req {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *Scheduler) release(host string) {
+	s.mu.Lock()
+	s.free(host)
+	s.mu.Unlock()
+}
+
+// free hands back a slot for host and passes it on to the next waiting request. It must be called
+// with the lock held
+func (s *Scheduler) free(host string) {
+	s.inFlight[host]--
+	if s.inFlight[host] <= 0 {
+		delete(s.inFlight, host)
+	}
+	s.total--
+	s.dispatch()
+}
+
+// dispatch grants slots to waiting requests until no further slot can be handed out. Slots are
+// handed out one at a time, starting with the query following the one served last. It must be
+// called with the lock held
+func (s *Scheduler) dispatch() {
+	for granted := true; granted; {
+		granted = false
+		for i := 0; i < len(s.queues); i++ {
+			if s.maxSlots > 0 && s.total >= s.maxSlots {
+				return
+			}
+
+			idx := (s.next + i) % len(s.queues)
+			if s.grant(s.queues[idx]) {
+				granted = true
+				s.next = (idx + 1) % len(s.queues)
+				break
+			}
+		}
+	}
+}
+
+// grant hands out a slot to the oldest request of queue q whose host has a free slot
+func (s *Scheduler) grant(q *schedulerQueue) bool {
+	for i, req := range q.waiting {
+		if s.inFlight[req.host] >= s.maxPerHost {
+			continue
+		}
+		q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+
+		s.inFlight[req.host]++
+		s.total++
+
+		req.granted = true
+		close(req.ready)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package distributed
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedulerMaxPerHost(t *testing.T) {
+	s := NewScheduler(1, 0)
+
+	q1, q2 := s.register(), s.register()
+	defer q1.close()
+	defer q2.close()
+
+	release, err := q1.acquire(context.Background(), "host1")
+	require.Nil(t, err)
+
+	// a different host is not affected
+	releaseOther, err := q2.acquire(context.Background(), "host2")
+	require.Nil(t, err)
+	releaseOther()
+
+	// the same host is blocked until the slot is handed back
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = q2.acquire(ctx, "host1")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	acquired := make(chan struct{})
+	go func() {
+		release, err := q2.acquire(context.Background(), "host1")
+		require.Nil(t, err)
+		release()
+		close(acquired)
+	}()
+	release()
+
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("slot wasn't handed on after release")
+	}
+	require.Empty(t, s.inFlight)
+	require.Zero(t, s.total)
+}
+
+func TestSchedulerFairness(t *testing.T) {
+	s := NewScheduler(1, 1)
+
+	q1, q2 := s.register(), s.register()
+	defer q1.close()
+	defer q2.close()
+
+	// occupy the only slot so that all further requests queue up
+	release, err := q1.acquire(context.Background(), "host0")
+	require.Nil(t, err)
+
+	type grant struct {
+		query   int
+		release func()
+	}
+	grants := make(chan grant, 4)
+	request := func(query int, q *schedulerQueue, host string) {
+		go func() {
+			release, err := q.acquire(context.Background(), host)
+			require.Nil(t, err)
+			grants <- grant{query, release}
+		}()
+	}
+
+	// the first query enqueues all of its requests before the second one
+	for _, host := range []string{"host1", "host2"} {
+		request(1, q1, host)
+	}
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(q1.waiting) == 2
+	}, time.Second, time.Millisecond)
+	for _, host := range []string{"host3", "host4"} {
+		request(2, q2, host)
+	}
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(q2.waiting) == 2
+	}, time.Second, time.Millisecond)
+
+	// slots are handed out alternately to both queries
+	release()
+	var order []int
+	for i := 0; i < 4; i++ {
+		g := <-grants
+		order = append(order, g.query)
+		g.release()
+	}
+	require.Equal(t, []int{2, 1, 2, 1}, order)
+}
@@ -0,0 +1,288 @@
+package reports
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/results"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultFormat denotes the output format of reports not setting a format
+	DefaultFormat = "json"
+
+	// DefaultFirst denotes the start of the time range covered by reports not setting one (i.e.
+	// the last 24 hours)
+	DefaultFirst = "-24h"
+
+	// DefaultHistorySize denotes the number of runs kept per report
+	DefaultHistorySize = 20
+)
+
+var (
+	errorNoReportName     = errors.New("report name must not be empty")
+	errorDuplicateReport  = errors.New("duplicate report name")
+	errorNoQueryHosts     = errors.New("report query requires a hosts query")
+	errorNoDelivery       = errors.New("report requires at least one delivery")
+	errorUnknownFormat    = errors.New("unknown report format")
+	errorInvalidTemplate  = errors.New("invalid template")
+	errorMissingParameter = errors.New("missing delivery parameter")
+)
+
+// Config stores the definitions of all scheduled reports
+type Config struct {
+	// HistorySize: number of runs kept (and exposed via the API) per report. Example: 20
+	HistorySize int `json:"history_size,omitempty" yaml:"history_size,omitempty"`
+
+	Reports []ReportConfig `json:"reports" yaml:"reports"`
+}
+
+// ReportConfig defines a scheduled report, i.e. a named query run on a schedule, whose results
+// are delivered to one or more destinations
+type ReportConfig struct {
+	// Name: unique name of the report. Example: top-talkers
+	Name string `json:"name" yaml:"name"`
+
+	// Schedule: cron expression defining when the report is generated (in the local time of the
+	// server). Example: 0 6 * * 1-5
+	Schedule string `json:"schedule" yaml:"schedule"`
+
+	// Format: output format of the report. Example: csv
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+
+	// Query: the query arguments. If no time range is set, the report covers the last 24 hours
+	Query query.Args `json:"query" yaml:"query"`
+
+	// Delivery: destinations the report is delivered to
+	Delivery DeliveryConfig `json:"delivery" yaml:"delivery"`
+}
+
+// DeliveryConfig lists the destinations a report is delivered to. Paths, object keys and email
+// subjects are Go templates with access to the name of the report and the time it was generated,
+// e.g. "/var/reports/{{.Name}}-{{.Time.Format \"2006-01-02\"}}.csv"
+type DeliveryConfig struct {
+	File    *FileConfig    `json:"file,omitempty" yaml:"file,omitempty"`
+	S3      *S3Config      `json:"s3,omitempty" yaml:"s3,omitempty"`
+	Email   *EmailConfig   `json:"email,omitempty" yaml:"email,omitempty"`
+	Webhook *WebhookConfig `json:"webhook,omitempty" yaml:"webhook,omitempty"`
+}
+
+// FileConfig delivers reports to a file. An existing file is replaced atomically
+type FileConfig struct {
+	// Path: path (template) of the report file. Example: /var/reports/{{.Name}}.csv
+	Path string `json:"path" yaml:"path"`
+}
+
+// S3Config delivers reports to an S3 compatible object store
+type S3Config struct {
+	// Endpoint: URL of the object store. Objects are addressed path-style. Example: https://s3.eu-central-1.amazonaws.com
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+
+	// Region: region of the bucket. Example: eu-central-1
+	Region string `json:"region" yaml:"region"`
+
+	// Bucket: bucket the reports are stored in. Example: reports
+	Bucket string `json:"bucket" yaml:"bucket"`
+
+	// Key: object key (template) of the report. Example: goprobe/{{.Name}}/{{.Time.Format "2006-01-02"}}.json
+	Key string `json:"key" yaml:"key"`
+
+	// AccessKeyID and SecretAccessKey: credentials used to sign requests
+	AccessKeyID     string `json:"access_key_id" yaml:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key" yaml:"secret_access_key"`
+}
+
+// EmailConfig delivers reports as email attachment
+type EmailConfig struct {
+	// SMTPAddr: address of the SMTP server. Example: mail.example.com:587
+	SMTPAddr string `json:"smtp_addr" yaml:"smtp_addr"`
+
+	// Username and Password: credentials for the SMTP server (PLAIN authentication). If empty,
+	// no authentication is performed
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+
+	// From: sender address. Example: goprobe@example.com
+	From string `json:"from" yaml:"from"`
+
+	// To: recipient addresses. Example: ["noc@example.com"]
+	To []string `json:"to" yaml:"to"`
+
+	// Subject: subject (template) of the email. If empty, the name of the report is used
+	Subject string `json:"subject,omitempty" yaml:"subject,omitempty"`
+}
+
+// WebhookConfig delivers reports via HTTP POST
+type WebhookConfig struct {
+	// URL: endpoint the report is posted to. Example: https://hooks.example.com/reports
+	URL string `json:"url" yaml:"url"`
+
+	// Headers: additional headers sent with the request. Example: {"Authorization": "Bearer <token>"}
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+}
+
+// LoadConfig reads the report definitions from the (YAML) file at path
+func LoadConfig(path string) (*Config, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open reports config: %w", err)
+	}
+	defer f.Close()
+
+	return ParseConfig(f)
+}
+
+// ParseConfig reads and validates the report definitions from r
+func ParseConfig(r io.Reader) (*Config, error) {
+	cfg := new(Config)
+	if err := yaml.NewDecoder(r).Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read reports config: %w", err)
+	}
+	if cfg.HistorySize <= 0 {
+		cfg.HistorySize = DefaultHistorySize
+	}
+	for i := range cfg.Reports {
+		cfg.Reports[i].setDefaults()
+	}
+	return cfg, cfg.validate()
+}
+
+func (r *ReportConfig) setDefaults() {
+	defaults := query.DefaultArgs()
+	if r.Format == "" {
+		r.Format = DefaultFormat
+	}
+	if r.Query.First == "" {
+		r.Query.First = DefaultFirst
+	}
+	if r.Query.Last == "" {
+		r.Query.Last = defaults.Last
+	}
+	if r.Query.SortBy == "" {
+		r.Query.SortBy = defaults.SortBy
+	}
+	if r.Query.NumResults == 0 {
+		r.Query.NumResults = defaults.NumResults
+	}
+	if r.Query.MaxMemPct == 0 {
+		r.Query.MaxMemPct = defaults.MaxMemPct
+	}
+	if !r.Query.In && !r.Query.Out && !r.Query.Sum {
+		r.Query.In, r.Query.Out = defaults.In, defaults.Out
+	}
+	if r.Query.DNSResolution.MaxRows == 0 {
+		r.Query.DNSResolution.MaxRows = defaults.DNSResolution.MaxRows
+	}
+	if r.Query.DNSResolution.Timeout == 0 {
+		r.Query.DNSResolution.Timeout = defaults.DNSResolution.Timeout
+	}
+	r.Query.Format = r.Format
+}
+
+func (c *Config) validate() error {
+	names := make(map[string]struct{}, len(c.Reports))
+	for _, r := range c.Reports {
+		if _, exists := names[r.Name]; exists {
+			return fmt.Errorf("%w: %s", errorDuplicateReport, r.Name)
+		}
+		names[r.Name] = struct{}{}
+
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("report %q: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+func (r *ReportConfig) validate() error {
+	if r.Name == "" {
+		return errorNoReportName
+	}
+	if _, err := ParseSchedule(r.Schedule); err != nil {
+		return err
+	}
+	if _, exists := results.LookupFormatter(r.Format); !exists {
+		return fmt.Errorf("%w: %s", errorUnknownFormat, r.Format)
+	}
+	if r.Query.QueryHosts == "" {
+		return errorNoQueryHosts
+	}
+
+	// make sure that the query can be run
+	args := r.Query
+	if _, err := args.Prepare(); err != nil {
+		return err
+	}
+	return r.Delivery.validate()
+}
+
+func (d *DeliveryConfig) validate() error {
+	if d.File == nil && d.S3 == nil && d.Email == nil && d.Webhook == nil {
+		return errorNoDelivery
+	}
+	required := func(kind, param, value string) error {
+		if value == "" {
+			return fmt.Errorf("%w: %s.%s", errorMissingParameter, kind, param)
+		}
+		return nil
+	}
+
+	var errs []error
+	if d.File != nil {
+		errs = append(errs, required("file", "path", d.File.Path), checkTemplate(d.File.Path))
+	}
+	if d.S3 != nil {
+		errs = append(errs,
+			required("s3", "endpoint", d.S3.Endpoint),
+			required("s3", "region", d.S3.Region),
+			required("s3", "bucket", d.S3.Bucket),
+			required("s3", "key", d.S3.Key),
+			checkTemplate(d.S3.Key),
+		)
+	}
+	if d.Email != nil {
+		errs = append(errs,
+			required("email", "smtp_addr", d.Email.SMTPAddr),
+			required("email", "from", d.Email.From),
+			checkTemplate(d.Email.Subject),
+		)
+		if len(d.Email.To) == 0 {
+			errs = append(errs, fmt.Errorf("%w: email.to", errorMissingParameter))
+		}
+	}
+	if d.Webhook != nil {
+		errs = append(errs, required("webhook", "url", d.Webhook.URL))
+	}
+	return errors.Join(errs...)
+}
+
+// templateData is available to the templates of the delivery configuration
+type templateData struct {
+	Name string
+	Time time.Time
+}
+
+func checkTemplate(text string) error {
+	_, err := expandTemplate(text, templateData{Time: time.Now()})
+	return err
+}
+
+func expandTemplate(text string, data templateData) (string, error) {
+	tmpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("%w `%s`: %w", errorInvalidTemplate, text, err)
+	}
+	buf := new(bytes.Buffer)
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", fmt.Errorf("%w `%s`: %w", errorInvalidTemplate, text, err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,176 @@
+package reports
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSchedule is returned if a schedule can't be parsed
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// maxScheduleSearch bounds the search for the next activation of a schedule (e.g. for schedules
+// which only fire on the 29th of February)
+const maxScheduleSearch = 5 * 366 * 24 * time.Hour
+
+// scheduleMacros maps the supported shorthands to their cron expressions
+var scheduleMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = [5]cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7}, // both 0 and 7 denote Sunday
+}
+
+// Schedule denotes a cron schedule, i.e. the minutes, hours, days of month, months and days of
+// week at which a report is generated
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// if both the day of month and the day of week are restricted, either of them has to match
+	// (as is the case for cron)
+	domRestricted, dowRestricted bool
+}
+
+// ParseSchedule parses a schedule in cron syntax ("<minute> <hour> <day of month> <month> <day of week>"),
+// supporting lists ("1,15"), ranges ("1-5"), steps ("*/15", "0-30/10") and the macros @yearly / @annually,
+// @monthly, @weekly, @daily / @midnight and @hourly
+func ParseSchedule(spec string) (*Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if expr, isMacro := scheduleMacros[spec]; isMacro {
+		spec = expr
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("%w `%s`: expected %d fields, got %d", ErrInvalidSchedule, spec, len(cronFields), len(fields))
+	}
+
+	var sets [len(cronFields)]uint64
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("%w `%s`: %w", ErrInvalidSchedule, spec, err)
+		}
+		sets[i] = set
+	}
+
+	// Sunday may be denoted by 7 as well
+	if sets[4]&(1<<7) != 0 {
+		sets[4] = sets[4]&^(1<<7) | 1
+	}
+
+	return &Schedule{
+		minute:        sets[0],
+		hour:          sets[1],
+		dom:           sets[2],
+		month:         sets[3],
+		dow:           sets[4],
+		domRestricted: !strings.HasPrefix(fields[2], "*"),
+		dowRestricted: !strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+func parseCronField(field string, f cronField) (set uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			if step, err = strconv.Atoi(stepExpr); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step `%s` in %s", stepExpr, f.name)
+			}
+		}
+
+		first, last := f.min, f.max
+		if rangeExpr != "*" {
+			lower, upper, isRange := strings.Cut(rangeExpr, "-")
+			if first, err = parseCronValue(lower, f); err != nil {
+				return 0, err
+			}
+			last = first
+			if isRange {
+				if last, err = parseCronValue(upper, f); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// a single value with step (e.g. "5/15") runs until the end of the range
+				last = f.max
+			}
+			if first > last {
+				return 0, fmt.Errorf("invalid range `%s` in %s", rangeExpr, f.name)
+			}
+		}
+
+		for v := first; v <= last; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func parseCronValue(s string, f cronField) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s `%s` (must be within [%d, %d])", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next returns the first activation of the schedule after t (in the location of t). If there is
+// none within the next five years, the zero time is returned
+func (s *Schedule) Next(t time.Time) time.Time {
+	limit := t.Add(maxScheduleSearch)
+
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = advance(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location()))
+		case !s.matchesDay(t):
+			t = advance(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location()))
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = advance(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location()))
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// advance moves on to next, making sure that the search progresses even if next falls into a
+// daylight saving time transition (and hence is normalized to a time before t)
+func advance(t, next time.Time) time.Time {
+	if !next.After(t) {
+		return t.Add(time.Minute)
+	}
+	return next
+}
+
+func (s *Schedule) matchesDay(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
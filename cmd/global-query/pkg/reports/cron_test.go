@@ -0,0 +1,66 @@
+package reports
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	for _, spec := range []string{
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"10-5 * * * *",
+		"a * * * *",
+		"@never",
+	} {
+		t.Run(spec, func(t *testing.T) {
+			_, err := ParseSchedule(spec)
+			require.ErrorIs(t, err, ErrInvalidSchedule)
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// Friday, 2023-09-01 12:34:56
+	now := time.Date(2023, 9, 1, 12, 34, 56, 0, time.UTC)
+
+	var tests = []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2023, 9, 1, 12, 35, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2023, 9, 1, 12, 45, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2023, 9, 1, 12, 45, 0, 0, time.UTC)},
+		{"0 6,18 * * *", time.Date(2023, 9, 1, 18, 0, 0, 0, time.UTC)},
+		{"30 2 * * 1-5", time.Date(2023, 9, 4, 2, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2023, 9, 3, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2023, 9, 1, 13, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2023, 9, 2, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2023, 9, 3, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", time.Date(2023, 10, 31, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+
+		// if both day of month and day of week are restricted, either of them matches
+		{"0 0 15 * 6", time.Date(2023, 9, 2, 0, 0, 0, 0, time.UTC)},
+
+		// a schedule never firing
+		{"0 0 31 2 *", time.Time{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			schedule, err := ParseSchedule(test.spec)
+			require.Nil(t, err)
+			require.Equal(t, test.expected, schedule.Next(now))
+		})
+	}
+}
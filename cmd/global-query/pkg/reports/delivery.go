@@ -0,0 +1,124 @@
+package reports
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/s3"
+)
+
+// deliveryTimeout bounds the time a single delivery of a report may take
+const deliveryTimeout = time.Minute
+
+// contentTypes maps the output formats to the content type of the generated reports
+var contentTypes = map[string]string{
+	"json": "application/json",
+	"csv":  "text/csv",
+}
+
+// output denotes a generated report
+type output struct {
+	name    string
+	time    time.Time
+	format  string
+	content []byte
+}
+
+func (o *output) contentType() string {
+	if contentType, exists := contentTypes[o.format]; exists {
+		return contentType
+	}
+	return "text/plain; charset=utf-8"
+}
+
+func (o *output) templateData() templateData {
+	return templateData{Name: o.name, Time: o.time}
+}
+
+// deliverer delivers generated reports to a destination
+type deliverer interface {
+
+	// kind returns the type of the destination (e.g. "file")
+	kind() string
+
+	// deliver delivers the report
+	deliver(ctx context.Context, out *output) error
+}
+
+func newDeliverers(cfg DeliveryConfig) (deliverers []deliverer) {
+	if cfg.File != nil {
+		deliverers = append(deliverers, &fileDeliverer{cfg: *cfg.File})
+	}
+	if cfg.S3 != nil {
+		deliverers = append(deliverers, &s3Deliverer{
+			cfg:    *cfg.S3,
+			client: s3.New(cfg.S3.Endpoint, cfg.S3.Region, cfg.S3.AccessKeyID, cfg.S3.SecretAccessKey),
+		})
+	}
+	if cfg.Email != nil {
+		deliverers = append(deliverers, &emailDeliverer{cfg: *cfg.Email})
+	}
+	if cfg.Webhook != nil {
+		deliverers = append(deliverers, &webhookDeliverer{cfg: *cfg.Webhook, client: http.DefaultClient})
+	}
+	return deliverers
+}
+
+type fileDeliverer struct {
+	cfg FileConfig
+}
+
+func (d *fileDeliverer) kind() string { return "file" }
+
+func (d *fileDeliverer) deliver(_ context.Context, out *output) error {
+	path, err := expandTemplate(d.cfg.Path, out.templateData())
+	if err != nil {
+		return err
+	}
+	return query.WriteFileAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(out.content)
+		return err
+	})
+}
+
+type webhookDeliverer struct {
+	cfg    WebhookConfig
+	client *http.Client
+}
+
+func (d *webhookDeliverer) kind() string { return "webhook" }
+
+func (d *webhookDeliverer) deliver(ctx context.Context, out *output) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.cfg.URL, bytes.NewReader(out.content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", out.contentType())
+	req.Header.Set("X-Report-Name", out.name)
+	req.Header.Set("X-Report-Time", out.time.Format(time.RFC3339))
+	for key, value := range d.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	return doRequest(d.client, req)
+}
+
+// doRequest performs the request, treating any non-2xx response as failure
+func doRequest(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Redacted(), resp.Status, bytes.TrimSpace(body))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,160 @@
+package reports
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// smtpSendMail sends the message, allowing tests to intercept outgoing emails
+var smtpSendMail = sendMail
+
+// attachmentLineLength denotes the line length of base64 encoded attachments (as required by RFC 2045)
+const attachmentLineLength = 76
+
+type emailDeliverer struct {
+	cfg EmailConfig
+}
+
+func (d *emailDeliverer) kind() string { return "email" }
+
+func (d *emailDeliverer) deliver(ctx context.Context, out *output) error {
+	subject := out.name
+	if d.cfg.Subject != "" {
+		var err error
+		if subject, err = expandTemplate(d.cfg.Subject, out.templateData()); err != nil {
+			return err
+		}
+	}
+
+	msg, err := d.message(subject, out)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if d.cfg.Username != "" {
+		host, _, err := net.SplitHostPort(d.cfg.SMTPAddr)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP server address: %w", err)
+		}
+		auth = smtp.PlainAuth("", d.cfg.Username, d.cfg.Password, host)
+	}
+	return smtpSendMail(ctx, d.cfg.SMTPAddr, auth, d.cfg.From, d.cfg.To, msg)
+}
+
+// sendMail sends the message like smtp.SendMail, but bounds the whole SMTP session by the context:
+// the connection is subject to the deadline of the context and is closed once it is canceled
+func sendMail(ctx context.Context, addr string, auth smtp.Auth, from string, to []string, msg []byte) (err error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP server address: %w", err)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer func() {
+		// report the cancellation rather than the error of the closed connection
+		if !stop() && err != nil {
+			err = fmt.Errorf("%w: %w", ctx.Err(), err)
+		}
+	}()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return fmt.Errorf("SMTP server %s doesn't support AUTH", addr)
+		}
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message creates a multipart message with a short summary as text and the report as attachment
+func (d *emailDeliverer) message(subject string, out *output) ([]byte, error) {
+	var rnd [16]byte
+	if _, err := rand.Read(rnd[:]); err != nil {
+		return nil, err
+	}
+	boundary := hex.EncodeToString(rnd[:])
+	filename := fmt.Sprintf("%s-%s.%s", out.name, out.time.Format("20060102T150405"), out.format)
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "From: %s\r\n", d.cfg.From)
+	fmt.Fprintf(buf, "To: %s\r\n", strings.Join(d.cfg.To, ", "))
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(buf, "Date: %s\r\n", out.time.Format(time.RFC1123Z))
+	fmt.Fprintf(buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(buf, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+
+	fmt.Fprintf(buf, "--%s\r\n", boundary)
+	fmt.Fprintf(buf, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(buf, "Report %q generated at %s is attached.\r\n\r\n", out.name, out.time.Format(time.RFC3339))
+
+	fmt.Fprintf(buf, "--%s\r\n", boundary)
+	fmt.Fprintf(buf, "Content-Type: %s\r\n", out.contentType())
+	fmt.Fprintf(buf, "Content-Transfer-Encoding: base64\r\n")
+	fmt.Fprintf(buf, "Content-Disposition: attachment; filename=%q\r\n\r\n", filename)
+
+	encoded := base64.StdEncoding.EncodeToString(out.content)
+	for len(encoded) > attachmentLineLength {
+		buf.WriteString(encoded[:attachmentLineLength] + "\r\n")
+		encoded = encoded[attachmentLineLength:]
+	}
+	buf.WriteString(encoded + "\r\n")
+	fmt.Fprintf(buf, "--%s--\r\n", boundary)
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,23 @@
+package reports
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/els0r/goProbe/pkg/s3"
+)
+
+type s3Deliverer struct {
+	cfg    S3Config
+	client *s3.Client
+}
+
+func (d *s3Deliverer) kind() string { return "s3" }
+
+func (d *s3Deliverer) deliver(ctx context.Context, out *output) error {
+	key, err := expandTemplate(d.cfg.Key, out.templateData())
+	if err != nil {
+		return err
+	}
+	return d.client.Put(ctx, d.cfg.Bucket, key, out.contentType(), bytes.NewReader(out.content))
+}
@@ -0,0 +1,269 @@
+package reports
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/telemetry/logging"
+)
+
+// Run summarizes a single run of a report
+type Run struct {
+	Started  time.Time     `json:"started"`     // Started: time the run was started. Example: 2023-09-01T06:00:00Z
+	Duration time.Duration `json:"duration_ns"` // Duration: time it took to generate and deliver the report. Example: 1500000000
+
+	Status types.Status `json:"status,omitempty"` // Status: status of the query result. Example: ok
+	Rows   int          `json:"rows"`             // Rows: number of rows in the query result. Example: 25
+	Error  string       `json:"error,omitempty"`  // Error: error which caused the report generation to fail. Example: "failed to execute query: context deadline exceeded"
+
+	Deliveries []DeliveryStatus `json:"deliveries,omitempty"` // Deliveries: outcome of the deliveries of the report
+}
+
+// DeliveryStatus stores the outcome of the delivery of a report to a destination
+type DeliveryStatus struct {
+	Type  string `json:"type"`            // Type: type of the destination. Example: s3
+	Error string `json:"error,omitempty"` // Error: error which caused the delivery to fail. Example: "PUT https://s3.example.com/reports/top.json: 403 Forbidden"
+}
+
+// Failed returns whether the report generation or any of its deliveries failed
+func (r Run) Failed() bool {
+	if r.Error != "" {
+		return true
+	}
+	for _, d := range r.Deliveries {
+		if d.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Info describes a scheduled report along with its run history
+type Info struct {
+	Name       string    `json:"name"`               // Name: name of the report. Example: top-talkers
+	Schedule   string    `json:"schedule"`           // Schedule: cron expression of the report. Example: 0 6 * * 1-5
+	Format     string    `json:"format"`             // Format: output format of the report. Example: csv
+	Query      string    `json:"query"`              // Query: the query type of the report. Example: sip,dip
+	Deliveries []string  `json:"deliveries"`         // Deliveries: types of destinations the report is delivered to. Example: ["file", "email"]
+	Running    bool      `json:"running,omitempty"`  // Running: whether the report is being generated right now. Example: false
+	NextRun    time.Time `json:"next_run,omitempty"` // NextRun: time of the next scheduled run. Example: 2023-09-04T06:00:00Z
+
+	History []Run `json:"history,omitempty"` // History: most recent runs (newest first)
+}
+
+type report struct {
+	cfg        ReportConfig
+	schedule   *Schedule
+	deliverers []deliverer
+
+	running bool
+	nextRun time.Time
+	history []Run // oldest first
+}
+
+// Scheduler runs reports on their schedule and keeps track of their run history
+type Scheduler struct {
+	runner      query.Runner
+	historySize int
+
+	reports []*report // sorted by name
+	mu      sync.Mutex
+}
+
+// NewScheduler creates a new scheduler for the reports defined in cfg, running the queries
+// using runner (usually a distributed.QueryRunner)
+func NewScheduler(runner query.Runner, cfg *Config) (*Scheduler, error) {
+	s := &Scheduler{
+		runner:      runner,
+		historySize: cfg.HistorySize,
+	}
+	if s.historySize <= 0 {
+		s.historySize = DefaultHistorySize
+	}
+
+	for _, reportCfg := range cfg.Reports {
+		schedule, err := ParseSchedule(reportCfg.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("report %q: %w", reportCfg.Name, err)
+		}
+		s.reports = append(s.reports, &report{
+			cfg:        reportCfg,
+			schedule:   schedule,
+			deliverers: newDeliverers(reportCfg.Delivery),
+		})
+	}
+	sort.Slice(s.reports, func(i, j int) bool {
+		return s.reports[i].cfg.Name < s.reports[j].cfg.Name
+	})
+	return s, nil
+}
+
+// Run generates the reports on their schedule until the context is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, r := range s.reports {
+		wg.Add(1)
+		go func(r *report) {
+			defer wg.Done()
+			s.schedule(ctx, r)
+		}(r)
+	}
+	wg.Wait()
+}
+
+func (s *Scheduler) schedule(ctx context.Context, r *report) {
+	ctx = logging.WithFields(ctx, slog.String("report", r.cfg.Name))
+	logger := logging.FromContext(ctx)
+
+	for {
+		next := r.schedule.Next(time.Now())
+		if next.IsZero() {
+			logger.Warn("report schedule never fires, stopping")
+			return
+		}
+
+		s.mu.Lock()
+		r.nextRun = next
+		s.mu.Unlock()
+
+		logger.With("next_run", next).Debug("scheduled report")
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		run := s.generate(ctx, r)
+		if run.Failed() {
+			logger.With("run", run).Error("failed to generate report")
+		} else {
+			logger.With("rows", run.Rows, "elapsed", run.Duration.Round(time.Millisecond).String()).Info("generated report")
+		}
+	}
+}
+
+// generate runs the query of the report and delivers the results to all destinations
+func (s *Scheduler) generate(ctx context.Context, r *report) (run Run) {
+	run.Started = time.Now()
+
+	s.mu.Lock()
+	r.running = true
+	s.mu.Unlock()
+
+	defer func() {
+		run.Duration = time.Since(run.Started)
+
+		s.mu.Lock()
+		r.running = false
+		r.history = append(r.history, run)
+		if len(r.history) > s.historySize {
+			r.history = r.history[len(r.history)-s.historySize:]
+		}
+		s.mu.Unlock()
+	}()
+
+	out, result, err := s.render(ctx, r, run.Started)
+	if err != nil {
+		run.Error = err.Error()
+		return run
+	}
+	run.Status, run.Rows = result.Status.Code, len(result.Rows)
+
+	for _, d := range r.deliverers {
+		deliveryCtx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+		status := DeliveryStatus{Type: d.kind()}
+		if err := d.deliver(deliveryCtx, out); err != nil {
+			status.Error = err.Error()
+		}
+		cancel()
+		run.Deliveries = append(run.Deliveries, status)
+	}
+	return run
+}
+
+// render runs the query of the report and prints its results in the format of the report
+func (s *Scheduler) render(ctx context.Context, r *report, started time.Time) (*output, *results.Result, error) {
+	args := r.cfg.Query
+	args.Caller = "global-query/reports/" + r.cfg.Name
+
+	buf := new(bytes.Buffer)
+	stmt, err := args.Prepare(buf)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to prepare query: %w", err)
+	}
+
+	result, err := s.runner.Run(ctx, &args)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	// formats serializing the raw result print it regardless of its status
+	if result.Status.Code != types.StatusOK && !results.IsRawFormat(stmt.Format) {
+		fmt.Fprintf(buf, "Status %q: %s\n", result.Status.Code, result.Status.Message)
+	} else if err := stmt.Print(ctx, result); err != nil {
+		return nil, nil, fmt.Errorf("failed to print query result: %w", err)
+	}
+
+	return &output{
+		name:    r.cfg.Name,
+		time:    started,
+		format:  r.cfg.Format,
+		content: buf.Bytes(),
+	}, result, nil
+}
+
+// Reports returns all scheduled reports along with their run history
+func (s *Scheduler) Reports() []Info {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	infos := make([]Info, 0, len(s.reports))
+	for _, r := range s.reports {
+		infos = append(infos, s.info(r))
+	}
+	return infos
+}
+
+// Report returns the scheduled report called name along with its run history
+func (s *Scheduler) Report(name string) (Info, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range s.reports {
+		if r.cfg.Name == name {
+			return s.info(r), true
+		}
+	}
+	return Info{}, false
+}
+
+// info describes the report. It must be called with the lock held
+func (s *Scheduler) info(r *report) Info {
+	info := Info{
+		Name:     r.cfg.Name,
+		Schedule: r.cfg.Schedule,
+		Format:   r.cfg.Format,
+		Query:    r.cfg.Query.Query,
+		Running:  r.running,
+		NextRun:  r.nextRun,
+	}
+	for _, d := range r.deliverers {
+		info.Deliveries = append(info.Deliveries, d.kind())
+	}
+	info.History = make([]Run, 0, len(r.history))
+	for i := len(r.history) - 1; i >= 0; i-- {
+		info.History = append(info.History, r.history[i])
+	}
+	return info
+}
@@ -0,0 +1,171 @@
+package reports
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+type runnerFunc func(ctx context.Context, args *query.Args) (*results.Result, error)
+
+func (f runnerFunc) Run(ctx context.Context, args *query.Args) (*results.Result, error) {
+	return f(ctx, args)
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig(strings.NewReader(`
+reports:
+  - name: top-talkers
+    schedule: "0 6 * * 1-5"
+    query:
+      query: sip,dip
+      ifaces: any
+      query_hosts: hostA,hostB
+    delivery:
+      file:
+        path: /tmp/{{.Name}}-{{.Time.Format "2006-01-02"}}.json
+`))
+	require.Nil(t, err)
+	require.Equal(t, DefaultHistorySize, cfg.HistorySize)
+	require.Len(t, cfg.Reports, 1)
+
+	report := cfg.Reports[0]
+	require.Equal(t, DefaultFormat, report.Format)
+	require.Equal(t, DefaultFormat, report.Query.Format)
+	require.Equal(t, DefaultFirst, report.Query.First)
+
+	path, err := expandTemplate(report.Delivery.File.Path, templateData{
+		Name: report.Name,
+		Time: time.Date(2023, 9, 1, 6, 0, 0, 0, time.UTC),
+	})
+	require.Nil(t, err)
+	require.Equal(t, "/tmp/top-talkers-2023-09-01.json", path)
+
+	for name, input := range map[string]string{
+		"no delivery":      "reports: [{name: a, schedule: '@daily', query: {query: sip, ifaces: any, query_hosts: hostA}}]",
+		"no hosts":         "reports: [{name: a, schedule: '@daily', query: {query: sip, ifaces: any}, delivery: {file: {path: /tmp/a}}}]",
+		"invalid schedule": "reports: [{name: a, schedule: '* *', query: {query: sip, ifaces: any, query_hosts: hostA}, delivery: {file: {path: /tmp/a}}}]",
+		"invalid template": "reports: [{name: a, schedule: '@daily', query: {query: sip, ifaces: any, query_hosts: hostA}, delivery: {file: {path: '/tmp/{{.Host}}'}}}]",
+		"duplicate name": `reports:
+  - {name: a, schedule: '@daily', query: {query: sip, ifaces: any, query_hosts: hostA}, delivery: {file: {path: /tmp/a}}}
+  - {name: a, schedule: '@daily', query: {query: sip, ifaces: any, query_hosts: hostA}, delivery: {file: {path: /tmp/a}}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseConfig(strings.NewReader(input))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+
+	var webhookBody []byte
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "top-talkers", r.Header.Get("X-Report-Name"))
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		webhookBody, _ = io.ReadAll(r.Body)
+	}))
+	defer webhook.Close()
+
+	var mail []byte
+	smtpSendMail = func(_ context.Context, _ string, _ smtp.Auth, _ string, to []string, msg []byte) error {
+		require.Equal(t, []string{"noc@example.com"}, to)
+		mail = msg
+		return nil
+	}
+	defer func() { smtpSendMail = sendMail }()
+
+	cfg := &Config{Reports: []ReportConfig{{
+		Name:     "top-talkers",
+		Schedule: "@daily",
+		Query:    query.Args{Query: "sip", Ifaces: "eth0", QueryHosts: "hostA"},
+		Delivery: DeliveryConfig{
+			File:    &FileConfig{Path: path},
+			Webhook: &WebhookConfig{URL: webhook.URL},
+			Email:   &EmailConfig{SMTPAddr: "localhost:25", From: "goprobe@example.com", To: []string{"noc@example.com"}},
+		},
+	}}}
+	cfg.Reports[0].setDefaults()
+	require.Nil(t, cfg.validate())
+
+	var calls int
+	s, err := NewScheduler(runnerFunc(func(_ context.Context, args *query.Args) (*results.Result, error) {
+		calls++
+		require.Equal(t, "hostA", args.QueryHosts)
+
+		res := results.New()
+		res.Status = results.Status{Code: types.StatusOK}
+		res.Rows = results.Rows{{
+			Attributes: results.Attributes{SrcIP: netip.MustParseAddr("10.0.0.1")},
+			Counters:   types.Counters{BytesRcvd: 100, PacketsRcvd: 1},
+		}}
+		return res, nil
+	}), cfg)
+	require.Nil(t, err)
+
+	run := s.generate(context.Background(), s.reports[0])
+	require.False(t, run.Failed(), "%+v", run)
+	require.Equal(t, 1, calls)
+	require.Equal(t, 1, run.Rows)
+	require.Equal(t, types.StatusOK, run.Status)
+	require.Len(t, run.Deliveries, 3)
+
+	content, err := os.ReadFile(path)
+	require.Nil(t, err)
+	require.Contains(t, string(content), "10.0.0.1")
+	require.Equal(t, content, webhookBody)
+	require.Contains(t, string(mail), `filename="top-talkers-`)
+
+	// a failing delivery is recorded in the history
+	s.reports[0].deliverers = append(s.reports[0].deliverers, &fileDeliverer{cfg: FileConfig{Path: filepath.Join(path, "invalid")}})
+	run = s.generate(context.Background(), s.reports[0])
+	require.True(t, run.Failed())
+
+	info, exists := s.Report("top-talkers")
+	require.True(t, exists)
+	require.Len(t, info.History, 2)
+	require.True(t, info.History[0].Failed())
+	require.False(t, info.History[1].Failed())
+
+	_, exists = s.Report("unknown")
+	require.False(t, exists)
+}
+
+func TestSendMailTimeout(t *testing.T) {
+	// the server accepts the connection, but never greets the client
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = sendMail(ctx, listener.Addr().String(), nil, "goprobe@example.com", []string{"noc@example.com"}, []byte("test"))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
@@ -20,6 +20,7 @@ import (
 	"io"
 	"io/fs"
 	"os"
+	"path/filepath"
 	"runtime"
 	"runtime/debug"
 	"sort"
@@ -47,6 +48,8 @@ type Config struct {
 	SavePath      string
 	Iface         string
 	Schema        string
+	Delimiter     string
+	Mapping       string
 	NumLines      int
 	EncoderType   int
 	DBPermissions uint
@@ -55,6 +58,10 @@ type Config struct {
 // parameter governing the number of seconds that are covered by a block
 const (
 	csvDefaultSchema = "time,iface,sip,dip,dport,proto,packets received,packets sent,%,data vol. received,data vol. sent,%"
+
+	csvDelimiter = ","
+	tsvDelimiter = "\t"
+	tsvExtension = ".tsv"
 )
 
 type writeJob struct {
@@ -102,11 +109,65 @@ func newStringKeyParser(field string) goDB.StringKeyParser {
 	return goDB.NewStringKeyParser(field)
 }
 
+// ColumnMapping maps column names found in the input file to the goDB attribute and
+// counter names understood by the converter (e.g. "tstamp" -> "time")
+type ColumnMapping map[string]string
+
+// ParseColumnMapping parses a comma-separated list of <column>=<attribute> pairs
+// into a column mapping
+func ParseColumnMapping(mapping string) (ColumnMapping, error) {
+	var m = make(ColumnMapping)
+	if strings.TrimSpace(mapping) == "" {
+		return m, nil
+	}
+	for _, pair := range strings.Split(mapping, ",") {
+		column, attribute, found := strings.Cut(pair, "=")
+		column, attribute = strings.TrimSpace(column), strings.TrimSpace(attribute)
+		if !found || column == "" || attribute == "" {
+			return nil, fmt.Errorf("invalid column mapping %q, expected <column>=<attribute>", pair)
+		}
+		if _, exists := m[column]; exists {
+			return nil, fmt.Errorf("duplicate column mapping for %q", column)
+		}
+		m[column] = attribute
+	}
+	return m, nil
+}
+
+// ParseDelimiter determines the field delimiter of the input file. If none is provided, it is
+// derived from the file extension (tab for .tsv files, comma otherwise)
+func ParseDelimiter(delimiter, filePath string) (string, error) {
+	switch delimiter {
+	case "":
+		if strings.EqualFold(filepath.Ext(filePath), tsvExtension) {
+			return tsvDelimiter, nil
+		}
+		return csvDelimiter, nil
+	case "tab", `\t`, tsvDelimiter:
+		return tsvDelimiter, nil
+	case "comma":
+		return csvDelimiter, nil
+	}
+	if len(delimiter) != 1 {
+		return "", fmt.Errorf("unsupported delimiter %q: must be a single character, \"tab\" or \"comma\"", delimiter)
+	}
+	return delimiter, nil
+}
+
 // CSVConverter can read CSV files containing goProbe flow information
 type CSVConverter struct {
 	// map field index to how it should be parsed
 	KeyParsers []keyIndParserItem
 	ValParsers map[int]goDB.StringValParser
+
+	// Delimiter separates the fields of a row
+	Delimiter string
+
+	// Mapping renames input columns before parsers are selected for them
+	Mapping ColumnMapping
+
+	// Iface is used for all rows if the schema doesn't contain an interface column
+	Iface string
 }
 
 // NewCSVConverter initializes a CSVConverter with the Key- and Value parsers for goProbe flows
@@ -114,21 +175,38 @@ func NewCSVConverter() *CSVConverter {
 	return &CSVConverter{
 		KeyParsers: make([]keyIndParserItem, 0),
 		ValParsers: make(map[int]goDB.StringValParser),
+		Delimiter:  csvDelimiter,
+		Mapping:    make(ColumnMapping),
+	}
+}
+
+// splitRow splits a single line of the input file into its fields
+func (c *CSVConverter) splitRow(line string) []string {
+	fields := strings.Split(line, c.Delimiter)
+	for i, field := range fields {
+		fields[i] = strings.TrimSpace(field)
 	}
+	return fields
 }
 
 func (c *CSVConverter) readSchema(schema string) error {
-	logger := logging.Logger()
+	return c.readSchemaFields(strings.Split(schema, ","))
+}
 
-	fields := strings.Split(schema, ",")
+func (c *CSVConverter) readSchemaFields(fields []string) error {
+	logger := logging.Logger()
 
 	var (
-		canParse  = make([]string, len(fields))
-		cantParse = make([]string, len(fields))
+		canParse  = make([]string, 0, len(fields))
+		cantParse = make([]string, 0, len(fields))
 	)
 
 	// first try to extract all attributes which need to be parsed
 	for ind, field := range fields {
+		field = strings.TrimSpace(field)
+		if mapped, exists := c.Mapping[field]; exists {
+			field = mapped
+		}
 		parser := newStringKeyParser(field)
 
 		// check if a NOP parser was created. If so, try to create
@@ -179,10 +257,31 @@ func (c *CSVConverter) parsesIface() bool {
 	return false
 }
 
+// checkIface ensures that the interface is known for every row, either via a column in
+// the schema or the default interface of the converter
+func (c *CSVConverter) checkIface() error {
+	if !c.parsesIface() && c.Iface == "" {
+		return errors.New("interface has not been specified by either data or -iface parameter")
+	}
+	return nil
+}
+
+// rowIface extracts the interface from the row key if it is part of the schema. Otherwise,
+// the default interface is returned
+func (c *CSVConverter) rowIface(key *types.ExtendedKey) (iface string) {
+	if !c.parsesIface() {
+		return c.Iface
+	}
+	*key, iface = extractIface(*key)
+	return iface
+}
+
 func parseCommandLineArgs(cfg *Config) {
 	flag.StringVar(&cfg.FilePath, "in", "", "CSV file from which the data should be read")
 	flag.StringVar(&cfg.SavePath, "out", "", "Folder to which the .gpf files should be written")
 	flag.StringVar(&cfg.Schema, "schema", "", "Structure of CSV file (e.g. \"sip,dip,dport,time\"")
+	flag.StringVar(&cfg.Delimiter, "delimiter", "", "Field delimiter (single character, \"tab\" or \"comma\"). Defaults to tab for .tsv files and comma otherwise")
+	flag.StringVar(&cfg.Mapping, "mapping", "", "Map input columns to goDB attributes (e.g. \"tstamp=time,src=sip,bytes_in=bytes_rcvd\")")
 	flag.StringVar(&cfg.Iface, "iface", "", "Interface from which CSV data was created")
 	flag.IntVar(&cfg.NumLines, "n", 1000, "Number of rows to read from the CSV file")
 	flag.IntVar(&cfg.EncoderType, "encoder", 0, "Encoder type to use for compression")
@@ -191,7 +290,7 @@ func parseCommandLineArgs(cfg *Config) {
 }
 
 func printUsage(msg string) {
-	fmt.Println(msg + ".\nUsage: ./goConvert -in <input file path> -out <output folder> [-n <number of lines to read> -schema <schema string> -iface <interface>]")
+	fmt.Println(msg + ".\nUsage: ./goConvert -in <input file path> -out <output folder> [-n <number of lines to read> -schema <schema string> -delimiter <delimiter> -mapping <column mapping> -iface <interface>]")
 }
 
 func main() {
@@ -238,10 +337,20 @@ func main() {
 
 	// create a CSV converter
 	var csvconv = NewCSVConverter()
+	csvconv.Iface = config.Iface
+	if csvconv.Delimiter, err = ParseDelimiter(config.Delimiter, config.FilePath); err != nil {
+		logger.Fatalf("failed to set delimiter: %s", err)
+	}
+	if csvconv.Mapping, err = ParseColumnMapping(config.Mapping); err != nil {
+		logger.Fatalf("failed to read column mapping: %s", err)
+	}
 	if config.Schema != "" {
 		if err = csvconv.readSchema(config.Schema); err != nil {
 			logger.Fatalf("failed to read schema: %s", err)
 		}
+		if err = csvconv.checkIface(); err != nil {
+			logger.Fatalf("%s. Aborting", err)
+		}
 	}
 
 	// map writers. There's one for each interface
@@ -294,23 +403,13 @@ func main() {
 		// create the parsers for the converter based on the title line provided in the CSV file
 		if linesRead == 1 {
 			if config.Schema == "" {
-				if err = csvconv.readSchema(scanner.Text()); err != nil {
+				if err = csvconv.readSchemaFields(csvconv.splitRow(scanner.Text())); err != nil {
 					logger.Fatalf("Failed to read schema: %s. Schema title line needed in CSV\n", err)
 				}
 
-				// assign interface to row key if it was specified
-				if !csvconv.parsesIface() {
-					if config.Iface == "" {
-						logger.Fatalf("Interface has not been specified by either data or -iface parameter. Aborting")
-					}
-
-					p := &IfaceStringParser{}
-					if err := p.ParseKey(config.Iface, &rowKeyV4); err != nil {
-						logger.Fatalf("Failed to parse interface from config: %s\n", err)
-					}
-					if err := p.ParseKey(config.Iface, &rowKeyV6); err != nil {
-						logger.Fatalf("Failed to parse interface from config: %s\n", err)
-					}
+				// make sure the interface is known if it isn't part of the data
+				if err = csvconv.checkIface(); err != nil {
+					logger.Fatalf("%s. Aborting", err)
 				}
 
 				linesRead++
@@ -368,7 +467,7 @@ func main() {
 		// fully parse the current line and load it into key and value objects
 		rowKey := &rowKeyV4
 		rowVal := types.Counters{}
-		fields := strings.Split(scanner.Text(), ",")
+		fields := csvconv.splitRow(scanner.Text())
 		if len(fields) < len(csvconv.KeyParsers)+len(csvconv.ValParsers) {
 			fmt.Printf("Skipping incomplete data row: %s\n", scanner.Text())
 			continue
@@ -394,8 +493,7 @@ func main() {
 
 		// check if a new submap has to be created (e.g. if there's new data
 		// from another interface
-		var iface string
-		*rowKey, iface = extractIface(*rowKey)
+		iface := csvconv.rowIface(rowKey)
 
 		ts, _ := rowKey.AttrTime()
 		if _, exists := flowMaps[iface]; !exists {
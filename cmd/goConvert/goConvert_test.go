@@ -318,3 +318,130 @@ func testParsers(t *testing.T) {
 		}
 	}
 }
+
+func TestParseDelimiter(t *testing.T) {
+	var tests = []struct {
+		delimiter string
+		filePath  string
+		expected  string
+		shouldErr bool
+	}{
+		{"", "data.csv", ",", false},
+		{"", "data.tsv", "\t", false},
+		{"", "DATA.TSV", "\t", false},
+		{"tab", "data.csv", "\t", false},
+		{`\t`, "data.csv", "\t", false},
+		{"comma", "data.tsv", ",", false},
+		{";", "data.csv", ";", false},
+		{";;", "data.csv", "", true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(fmt.Sprintf("%q_%s", tt.delimiter, tt.filePath), func(t *testing.T) {
+			delimiter, err := ParseDelimiter(tt.delimiter, tt.filePath)
+			if tt.shouldErr {
+				if err == nil {
+					t.Fatalf("expected error for delimiter %q", tt.delimiter)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if delimiter != tt.expected {
+				t.Fatalf("got: %q; expect: %q", delimiter, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseColumnMapping(t *testing.T) {
+	var tests = []struct {
+		mapping   string
+		expected  ColumnMapping
+		shouldErr bool
+	}{
+		{"", ColumnMapping{}, false},
+		{"tstamp=time", ColumnMapping{"tstamp": "time"}, false},
+		{"tstamp=time, src = sip,bytes_in=bytes_rcvd", ColumnMapping{"tstamp": "time", "src": "sip", "bytes_in": "bytes_rcvd"}, false},
+		{"tstamp", nil, true},
+		{"tstamp=", nil, true},
+		{"tstamp=time,tstamp=sip", nil, true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.mapping, func(t *testing.T) {
+			mapping, err := ParseColumnMapping(tt.mapping)
+			if tt.shouldErr {
+				if err == nil {
+					t.Fatalf("expected error for mapping %q", tt.mapping)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(mapping, tt.expected) {
+				t.Fatalf("got: %v; expect: %v", mapping, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMappedTSVSchema(t *testing.T) {
+	var (
+		header = "tstamp\tsrc\tdst\tport\tl4\tbytes_in\tbytes_out\tpkts_in\tpkts_out\trule"
+		input  = "1460362502\t213.156.236.211\t213.156.236.255\t8080\tTCP\t525\t0\t2\t0\tallow"
+		outKey = types.NewV4KeyStatic([4]byte{213, 156, 236, 211}, [4]byte{213, 156, 236, 255}, []byte{0x1f, 0x90}, 6).Extend(int64(1460362502))
+		outVal = types.Counters{BytesRcvd: uint64(525), BytesSent: uint64(0), PacketsRcvd: uint64(2), PacketsSent: uint64(0)}
+	)
+
+	mapping, err := ParseColumnMapping("tstamp=time,src=sip,dst=dip,port=dport,l4=proto,bytes_in=bytes_rcvd,bytes_out=bytes_sent,pkts_in=pkts_rcvd,pkts_out=pkts_sent")
+	if err != nil {
+		t.Fatalf("failed to parse mapping: %s", err)
+	}
+
+	conv := NewCSVConverter()
+	conv.Mapping = mapping
+	if conv.Delimiter, err = ParseDelimiter("", "export.tsv"); err != nil {
+		t.Fatalf("failed to parse delimiter: %s", err)
+	}
+	if err = conv.readSchemaFields(conv.splitRow(header)); err != nil {
+		t.Fatalf("unable to read schema: %s", err)
+	}
+	if len(conv.KeyParsers) != 5 || len(conv.ValParsers) != 4 {
+		t.Fatalf("unexpected number of parsers: %d key parsers, %d value parsers", len(conv.KeyParsers), len(conv.ValParsers))
+	}
+
+	conv.Iface = "eth0"
+	if err = conv.checkIface(); err != nil {
+		t.Fatalf("failed to assign interface: %s", err)
+	}
+
+	rowKey := types.NewEmptyV4Key().ExtendEmpty()
+	var rowVal types.Counters
+	fields := conv.splitRow(input)
+	for _, parser := range conv.KeyParsers {
+		if err = parser.parser.ParseKey(fields[parser.ind], &rowKey); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+	for ind, parser := range conv.ValParsers {
+		if err = parser.ParseVal(fields[ind], &rowVal); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	iface := conv.rowIface(&rowKey)
+	if !bytes.Equal(rowKey, outKey) {
+		t.Fatalf("Key (%s): got: %s; expect: %s", input, fmt.Sprint(rowKey), fmt.Sprint(outKey))
+	}
+	if !reflect.DeepEqual(rowVal, outVal) {
+		t.Fatalf("Val (%s): got: %s; expect: %s", input, fmt.Sprint(rowVal), fmt.Sprint(outVal))
+	}
+	if iface != "eth0" {
+		t.Fatalf("Iface: got: %s; expect: eth0", iface)
+	}
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/els0r/goProbe/pkg/api"
+	"github.com/els0r/goProbe/pkg/privileges"
+)
+
+// Diagnostic denotes a single issue found while checking a configuration against the
+// environment it is supposed to run in
+type Diagnostic struct {
+	Section string // Section: the part of the configuration the issue was found in. Example: "interfaces.eth0"
+	Err     error  // Err: the issue itself
+}
+
+// String returns a human-readable representation of the diagnostic
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %v", d.Section, d.Err)
+}
+
+// Diagnostics stores all issues found during a configuration check
+type Diagnostics []Diagnostic
+
+// Err returns an error summarizing all diagnostics or nil if there are none
+func (d Diagnostics) Err() error {
+	if len(d) == 0 {
+		return nil
+	}
+	msgs := make([]string, 0, len(d))
+	for _, diag := range d {
+		msgs = append(msgs, diag.String())
+	}
+	return fmt.Errorf("%d issue(s) found in configuration:\n\t%s", len(d), strings.Join(msgs, "\n\t"))
+}
+
+var (
+	errorInterfaceNotFound = errors.New("interface not found on host")
+	errorNotADirectory     = errors.New("path is not a directory")
+	errorDBNotFound        = errors.New("database directory not found")
+)
+
+// Check validates the configuration and additionally verifies that it can be applied on the
+// host it is run on. This covers the existence of all configured interfaces, access to the DB
+// and logging paths as well as the availability of the API bind address. In contrast to Validate,
+// all issues are collected instead of returning on the first one
+func (c *Config) Check() (diags Diagnostics) {
+	c.Lock()
+	defer c.Unlock()
+
+	if err := c.Validate(); err != nil {
+		diags = append(diags, Diagnostic{Section: "config", Err: err})
+	}
+
+	// make sure the interfaces exist and report them in deterministic order
+	ifaces := make([]string, 0, len(c.Interfaces))
+	for iface := range c.Interfaces {
+		ifaces = append(ifaces, iface)
+	}
+	sort.Strings(ifaces)
+	for _, iface := range ifaces {
+		if _, err := net.InterfaceByName(iface); err != nil {
+			diags = append(diags, Diagnostic{Section: "interfaces." + iface, Err: fmt.Errorf("%w: %v", errorInterfaceNotFound, err)})
+		}
+	}
+
+	if c.DB.Path != "" {
+		checkDB := checkWritableDir
+		if c.Archive {
+			checkDB = checkExistingDir
+		}
+		if err := checkDB(c.DB.Path); err != nil {
+			diags = append(diags, Diagnostic{Section: "db.path", Err: err})
+		}
+	}
+	if c.DB.Owner != "" {
+		if _, err := privileges.Lookup(c.DB.Owner); err != nil {
+			diags = append(diags, Diagnostic{Section: "db.owner", Err: err})
+		}
+	}
+	if c.RunAs != "" {
+		if _, err := privileges.Lookup(c.RunAs); err != nil {
+			diags = append(diags, Diagnostic{Section: "run_as", Err: err})
+		}
+	}
+	if c.Logging.IsFileDestination() {
+		if err := checkWritableDir(filepath.Dir(c.Logging.Destination)); err != nil {
+			diags = append(diags, Diagnostic{Section: "logging.destination", Err: err})
+		}
+	}
+	if c.API != nil && c.API.Addr != "" {
+		if err := checkBindAddr(c.API.Addr); err != nil {
+			diags = append(diags, Diagnostic{Section: "api.addr", Err: err})
+		}
+	}
+
+	return diags
+}
+
+// checkWritableDir verifies that files can be created in path. If the directory doesn't exist,
+// the closest existing parent directory is checked instead (since goProbe creates it on startup)
+func checkWritableDir(path string) error {
+	path = filepath.Clean(path)
+	for {
+		stat, err := os.Stat(path)
+		if err == nil {
+			if !stat.IsDir() {
+				return fmt.Errorf("%w: %s", errorNotADirectory, path)
+			}
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return err
+		}
+		path = parent
+	}
+
+	f, err := os.CreateTemp(path, ".goprobe-check-*")
+	if err != nil {
+		return fmt.Errorf("directory %s is not writable: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Remove(f.Name())
+}
+
+// checkExistingDir verifies that path is an existing directory (e.g. a DB served in archive mode,
+// which is only read and hence never created)
+func checkExistingDir(path string) error {
+	stat, err := os.Stat(filepath.Clean(path))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: %s", errorDBNotFound, path)
+		}
+		return err
+	}
+	if !stat.IsDir() {
+		return fmt.Errorf("%w: %s", errorNotADirectory, path)
+	}
+	return nil
+}
+
+// checkBindAddr verifies that the API server can listen on addr
+func checkBindAddr(addr string) error {
+	if unixSocketFile := api.ExtractUnixSocket(addr); unixSocketFile != "" {
+		return checkWritableDir(filepath.Dir(unixSocketFile))
+	}
+
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("cannot bind to address: %w", err)
+	}
+	return listener.Close()
+}
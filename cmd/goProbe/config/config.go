@@ -16,12 +16,23 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"unicode"
 
+	"github.com/els0r/goProbe/pkg/aggregator"
+	"github.com/els0r/goProbe/pkg/api"
 	"github.com/els0r/goProbe/pkg/defaults"
 	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/goDB/protocols"
+	"github.com/els0r/goProbe/pkg/logging"
 	jsoniter "github.com/json-iterator/go"
 	"golang.org/x/time/rate"
 	"gopkg.in/yaml.v3"
@@ -29,9 +40,13 @@ import (
 
 const (
 	// ServiceName is the name of the service as it will show up in telemetry such as metrics, logs, traces, etc.
-	ServiceName = "goprobe"
+	ServiceName = defaults.ServiceName
 
 	maxConfigSize = 16 * 1024 * 1024 // 16 MiB
+
+	// maxWriteoutStagger denotes the maximum number of seconds the writeouts of the interfaces may
+	// be spread across (half of the writeout interval of the DB)
+	maxWriteoutStagger = 150
 )
 
 // demoKeys stores the API keys that should, under no circumstance, be used in production.
@@ -51,11 +66,20 @@ type validator interface {
 type Config struct {
 	sync.Mutex
 	DB           DBConfig           `json:"db" yaml:"db"`
+	Archive      bool               `json:"archive" yaml:"archive"`
+	RunAs        string             `json:"run_as" yaml:"run_as"`
 	Interfaces   Ifaces             `json:"interfaces" yaml:"interfaces"`
 	SyslogFlows  bool               `json:"syslog_flows" yaml:"syslog_flows"`
 	Logging      LogConfig          `json:"logging" yaml:"logging"`
 	API          *APIConfig         `json:"api" yaml:"api"`
 	LocalBuffers *LocalBufferConfig `json:"local_buffers" yaml:"local_buffers"`
+	Tracing      *TracingConfig     `json:"tracing" yaml:"tracing"`
+	Metrics      *MetricsConfig     `json:"metrics" yaml:"metrics"`
+	Identity     *IdentityConfig    `json:"identity" yaml:"identity"`
+	Stream       *StreamConfig      `json:"stream" yaml:"stream"`
+	Aggregator   *AggregatorConfig  `json:"aggregator" yaml:"aggregator"`
+
+	SelfMonitoring *SelfMonitoringConfig `json:"self_monitoring" yaml:"self_monitoring"`
 }
 
 // DBConfig stores the local on-disk database configuration
@@ -63,12 +87,196 @@ type DBConfig struct {
 	Path        string      `json:"path" yaml:"path"`
 	EncoderType string      `json:"encoder_type" yaml:"encoder_type"`
 	Permissions fs.FileMode `json:"permissions" yaml:"permissions"`
+
+	// DirPermissions: permissions of the directories created in the database. If unset, they are
+	// derived from Permissions (adding the execute bit wherever reading is permitted). Example: 0750
+	DirPermissions fs.FileMode `json:"dir_permissions,omitempty" yaml:"dir_permissions,omitempty"`
+
+	// Owner: user (and optionally group) all files and directories created in the database are assigned
+	// to, e.g. to allow goQuery to read it as a different user. Example: goprobe:goquery
+	Owner string `json:"owner,omitempty" yaml:"owner,omitempty"`
+
+	// Instance: name of this goProbe instance if multiple instances write to the same database, each
+	// capturing a distinct set of interfaces (e.g. running as different users). The subtree of each
+	// interface is locked by the instance capturing it, and the interface aliases / groups and the
+	// runtime state are stored per instance. Example: uplinks
+	Instance string `json:"instance,omitempty" yaml:"instance,omitempty"`
+
+	// MirrorPath: path of a secondary database all writeouts are mirrored to asynchronously
+	// (e.g. on a network share for archival). Example: /mnt/archive/goprobe/db
+	MirrorPath string `json:"mirror_path,omitempty" yaml:"mirror_path,omitempty"`
+
+	// MaxPendingWriteouts: number of failed writeouts per interface (e.g. due to a full disk) that
+	// are buffered in memory and retried with backoff (and a final time upon shutdown). If unset, up to
+	// 12 writeouts (one hour of data) are buffered, a negative value disables retries. Example: 24
+	MaxPendingWriteouts int `json:"max_pending_writeouts,omitempty" yaml:"max_pending_writeouts,omitempty"`
+
+	// SnapshotPath: directory snapshots of the database are created in via the API. Since snapshots
+	// hard-link the database files, it must reside on the same file system as the database. If unset,
+	// snapshots are created in "<path>.snapshots". Example: /usr/local/goprobe/snapshots
+	SnapshotPath string `json:"snapshot_path,omitempty" yaml:"snapshot_path,omitempty"`
+
+	// WriteoutQueueLength: number of rotated interfaces which may be queued for writeout before the
+	// rotation of further interfaces blocks. If unset, up to 100 interfaces are queued. Example: 256
+	WriteoutQueueLength int `json:"writeout_queue_length,omitempty" yaml:"writeout_queue_length,omitempty"`
+
+	// WriteoutWorkers: number of interfaces written to the database concurrently. Consider raising it
+	// if writeouts lag behind on hosts with many interfaces. If unset, interfaces are written one after
+	// another. Example: 4
+	WriteoutWorkers int `json:"writeout_workers,omitempty" yaml:"writeout_workers,omitempty"`
+
+	// Preallocate: reserve the disk space of the files of each daily directory upon its first writeout,
+	// estimated from the sizes of the preceding days (Linux only). This reduces fragmentation and the
+	// risk of running out of space mid-writeout on nearly full disks
+	Preallocate bool `json:"preallocate,omitempty" yaml:"preallocate,omitempty"`
+
+	// WriteoutStagger: number of seconds the scheduled rotations and writeouts of the interfaces are
+	// spread across, smoothing the disk I/O spikes of hosts with many interfaces. Each interface is
+	// written out separately (and reported to writeout hooks on its own). Must not exceed half of the
+	// writeout interval. If unset, all interfaces are written out at once. Example: 60
+	WriteoutStagger int `json:"writeout_stagger,omitempty" yaml:"writeout_stagger,omitempty"`
+
+	// FlowStateMaxAge: if set, the flows captured since the last writeout are persisted in the
+	// database upon shutdown (instead of being written out) and restored upon the next start, as
+	// long as they are no older than the given number of seconds. Older flows are written out upon
+	// start instead. This keeps brief restarts (e.g. upgrades) from splitting the current writeout
+	// interval. Example: 300
+	FlowStateMaxAge int `json:"flow_state_max_age,omitempty" yaml:"flow_state_max_age,omitempty"`
+
+	// FlowSampling: if set, a sample of the individual flows (including their source port) is
+	// written to sample files upon each writeout, in addition to the aggregated flows
+	FlowSampling *FlowSamplingConfig `json:"flow_sampling,omitempty" yaml:"flow_sampling,omitempty"`
+
+	// WriteoutHooks: actions invoked after each writeout with a JSON summary of the blocks written,
+	// e.g. in order to process them further downstream
+	WriteoutHooks []WriteoutHookConfig `json:"writeout_hooks,omitempty" yaml:"writeout_hooks,omitempty"`
+}
+
+// WriteoutHookConfig stores the configuration of an action invoked after each writeout. Exactly one of
+// Exec and URL must be set
+type WriteoutHookConfig struct {
+	// Exec: command (followed by its arguments) which is executed with the summary on its standard
+	// input. Example: ["/usr/local/bin/upload-blocks", "--bucket", "flows"]
+	Exec []string `json:"exec,omitempty" yaml:"exec,omitempty"`
+
+	// URL: webhook the summary is POSTed to. Example: https://hooks.example.com/goprobe
+	URL string `json:"url,omitempty" yaml:"url,omitempty"`
+
+	// Headers: additional headers sent with the webhook request (e.g. for authentication)
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+
+	// Timeout: number of seconds after which the hook is aborted. If unset, hooks are aborted after
+	// 30 seconds. Example: 10
+	Timeout int `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// FlowSamplingConfig stores the configuration of the flow samples persisted upon writeout
+type FlowSamplingConfig struct {
+	// Rate: one in Rate flows is sampled. Flows are selected based on their hash, so a sampled flow
+	// is contained in the samples of all writeouts it is active in. Example: 1000
+	Rate int `json:"rate" yaml:"rate"`
+
+	// Path: directory the sample files (one per interface and day, in JSON lines format) are written
+	// to. It must not be located within the database. If unset, samples are written to "<path>.samples"
+	// Example: /usr/local/goprobe/samples
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
 }
 
 // CaptureConfig stores the capture / buffer related configuration for an individual interface
 type CaptureConfig struct {
 	Promisc    bool              `json:"promisc" yaml:"promisc"`         // Promisc: enables / disables promiscuous capture mode. Example: true
 	RingBuffer *RingBufferConfig `json:"ring_buffer" yaml:"ring_buffer"` // RingBuffer: denotes the kernel ring buffer configuration of this interface
+
+	// Tenant: assigns the interface to a tenant. Its flows are stored in a separate subtree of the
+	// database and are only returned by queries for the tenant. Example: acme
+	Tenant string `json:"tenant,omitempty" yaml:"tenant,omitempty"`
+
+	// Alias: human readable name of the interface. It is accepted by queries in place of the
+	// interface name and shown in their results, while the database keeps the interface name
+	// Example: wan
+	Alias string `json:"alias,omitempty" yaml:"alias,omitempty"`
+
+	// Groups: interface groups the interface is a member of. Queries accept a group in place of
+	// interface names, aggregating the flows of its members under the group's name
+	// Example: ["uplinks"]
+	Groups []string `json:"groups,omitempty" yaml:"groups,omitempty"`
+
+	// EncoderType: overrides the encoder the flows of the interface are stored with. If unset,
+	// the encoder of the database is used. Example: zstd
+	EncoderType string `json:"encoder_type,omitempty" yaml:"encoder_type,omitempty"`
+
+	// EncoderLevel: overrides the compression level of the interface's encoder (requires its
+	// encoder type to be set). Example: 19
+	EncoderLevel int `json:"encoder_level,omitempty" yaml:"encoder_level,omitempty"`
+
+	// Exclude: traffic which is dropped at capture time, i.e. before it is aggregated into flows
+	// (e.g. backups or storage replication)
+	Exclude []ExclusionRule `json:"exclude,omitempty" yaml:"exclude,omitempty"`
+
+	// ProcessAttribution: attributes the flows of the interface to the local processes owning their
+	// sockets (Linux only). The attribution is performed upon each rotation and stored in the
+	// process label of the flows. Example: true
+	ProcessAttribution bool `json:"process_attribution,omitempty" yaml:"process_attribution,omitempty"`
+
+	// Scheduling: pins the capture of the interface to CPUs and / or sets its priority (Linux only)
+	Scheduling *SchedulingConfig `json:"scheduling,omitempty" yaml:"scheduling,omitempty"`
+}
+
+// SchedulingConfig stores the CPU affinity and priority of the thread capturing an interface, preventing
+// it from being descheduled under load on shared machines
+type SchedulingConfig struct {
+	// CPUs: CPUs the capture thread is pinned to (if empty, it may run on any CPU). Example: [2, 3]
+	CPUs []int `json:"cpus,omitempty" yaml:"cpus,omitempty"`
+
+	// Nice: niceness of the capture thread, ranging from -20 (highest priority) to 19 (lowest
+	// priority). Negative values require root privileges (or CAP_SYS_NICE). Example: -10
+	Nice int `json:"nice,omitempty" yaml:"nice,omitempty"`
+}
+
+// ExclusionRule denotes traffic which is dropped at capture time. A packet is dropped if it matches
+// all fields set in the rule, where the network and port may match either of its endpoints
+type ExclusionRule struct {
+	Net   string `json:"net,omitempty" yaml:"net,omitempty"`     // Net: network in CIDR notation (or a single IP). Example: 10.20.0.0/16
+	Port  uint16 `json:"port,omitempty" yaml:"port,omitempty"`   // Port: port of TCP / UDP traffic. Example: 873
+	Proto string `json:"proto,omitempty" yaml:"proto,omitempty"` // Proto: IP protocol (name or number). Example: tcp
+}
+
+// ParseNet returns the network of the rule (nil if none is set). Single IPs are treated as host
+// networks
+func (r ExclusionRule) ParseNet() (*net.IPNet, error) {
+	if r.Net == "" {
+		return nil, nil
+	}
+	if !strings.Contains(r.Net, "/") {
+		ip := net.ParseIP(r.Net)
+		if ip == nil {
+			return nil, fmt.Errorf("%w: `%s`", errorInvalidExclusionNet, r.Net)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+	_, ipNet, err := net.ParseCIDR(r.Net)
+	if err != nil {
+		return nil, fmt.Errorf("%w: `%s`", errorInvalidExclusionNet, r.Net)
+	}
+	return ipNet, nil
+}
+
+// ParseProto returns the IP protocol number of the rule (and false if none is set)
+func (r ExclusionRule) ParseProto() (byte, bool, error) {
+	if r.Proto == "" {
+		return 0, false, nil
+	}
+	if id, err := strconv.ParseUint(r.Proto, 10, 8); err == nil {
+		return byte(id), true, nil
+	}
+	id, exists := protocols.GetIPProtoID(strings.ToLower(r.Proto))
+	if !exists || id > 255 {
+		return 0, false, fmt.Errorf("%w: `%s`", errorInvalidExclusionProto, r.Proto)
+	}
+	return byte(id), true, nil
 }
 
 // LocalBufferConfig stores the shared local in-memory buffer configuration
@@ -107,9 +315,49 @@ type Ifaces map[string]CaptureConfig
 
 // LogConfig stores the logging configuration
 type LogConfig struct {
+	// Destination: file path or one of stdout / stderr / devnull / syslog / journald
+	// Example: /var/log/goprobe.log
 	Destination string `json:"destination" yaml:"destination"`
 	Level       string `json:"level" yaml:"level"`
-	Encoding    string `json:"encoding" yaml:"encoding"`
+
+	// Encoding: encoding of the log messages (ignored for syslog / journald destinations, which
+	// map the structured fields natively)
+	Encoding string `json:"encoding" yaml:"encoding"`
+
+	// Syslog: syslog daemon configuration (only used with destination syslog)
+	Syslog *SyslogConfig `json:"syslog" yaml:"syslog"`
+
+	// Rotation: rotation of the log file (only used if the destination is a file)
+	Rotation *LogRotationConfig `json:"rotation" yaml:"rotation"`
+}
+
+// LogRotationConfig stores the configuration for rotating the log file
+type LogRotationConfig struct {
+	// MaxSize: maximum size of the log file (in bytes) before it is rotated. Example: 104857600
+	MaxSize int64 `json:"max_size" yaml:"max_size"`
+
+	// MaxAge: maximum time (in seconds) a log file is written to before it is rotated. Example: 86400
+	MaxAge int `json:"max_age" yaml:"max_age"`
+
+	// MaxBackups: number of rotated log files to retain (0 retains all of them). Example: 7
+	MaxBackups int `json:"max_backups" yaml:"max_backups"`
+
+	// Compress: compresses rotated log files (gzip). Example: true
+	Compress bool `json:"compress" yaml:"compress"`
+}
+
+// SyslogConfig stores the configuration for logging to a syslog daemon (RFC5424)
+type SyslogConfig struct {
+	// Network: one of udp / tcp / unix / unixgram. If unset, the local syslog socket is used
+	// Example: udp
+	Network string `json:"network" yaml:"network"`
+
+	// Address: address of the syslog daemon
+	// Example: logs.example.com:514
+	Address string `json:"address" yaml:"address"`
+
+	// Facility: syslog facility of all messages. Example: local3
+	Facility string `json:"facility" yaml:"facility"`
 }
 
 // QueryRateLimitConfig contains query rate limiting related config arguments / parameters
@@ -126,8 +374,174 @@ type APIConfig struct {
 	Timeout        int                  `json:"request_timeout" yaml:"request_timeout"`
 	Keys           []string             `json:"keys" yaml:"keys"`
 	QueryRateLimit QueryRateLimitConfig `json:"query_rate_limit" yaml:"query_rate_limit"`
+
+	// KeyTenants: restricts API keys to querying the listed tenants (including the status and live
+	// flows of their interfaces). Keys not listed may query any tenant. Example: {"<key>": ["acme"]}
+	KeyTenants map[string][]string `json:"key_tenants" yaml:"key_tenants"`
+
+	// KeyRoles: assigns roles to API keys. Keys with the "read" role may query and fetch the
+	// capture status, but may not modify the running capture (e.g. update or reload the
+	// configuration, create snapshots). Keys not listed are granted the "read" role, hence the
+	// "admin" role has to be assigned explicitly. Example: {"<admin key>": "admin"}
+	KeyRoles map[string]api.Role `json:"key_roles" yaml:"key_roles"`
+
+	// KeyQuotas: limits the number of queries, the bytes scanned and / or the query runtime (in seconds)
+	// per day (UTC) of API keys. Queries exceeding a quota are rejected until the next day. Keys not listed
+	// aren't limited, but their usage is accounted nonetheless (see the /usage endpoint).
+	// Example: {"<key>": {"queries": 1000, "runtime": 3600}}
+	KeyQuotas map[string]api.KeyQuota `json:"key_quotas" yaml:"key_quotas"`
+
+	// QueryMaxBlocksPerSec: number of blocks a query may read from disk per second, so that queries
+	// don't starve the writeouts. Applies to queries not setting a limit themselves and caps the limit
+	// of the ones that do. If unset, reads aren't throttled. Example: 500
+	QueryMaxBlocksPerSec int `json:"query_max_blocks_per_sec" yaml:"query_max_blocks_per_sec"`
+
+	// QueryWorkers: default number of workers reading the blocks of each interface concurrently for
+	// queries not setting it themselves. If unset, one worker per CPU is used. Example: 2
+	QueryWorkers int `json:"query_workers" yaml:"query_workers"`
+
+	// QueryMaxWorkers: maximum number of workers per interface a query may request, so that queries
+	// don't compete with the capture for CPU. If unset, queries may use one worker per CPU. Example: 4
+	QueryMaxWorkers int `json:"query_max_workers" yaml:"query_max_workers"`
+
+	// MaxRequestBodySize: maximum size (in bytes) of compressed request bodies once decompressed. Larger
+	// bodies are rejected (413). If unset, 32 MiB apply. Example: 1048576
+	MaxRequestBodySize int64 `json:"max_request_body_size,omitempty" yaml:"max_request_body_size,omitempty"`
+
+	// Export: destinations (a local directory and / or an S3 bucket) queries may export their results to
+	// instead of returning them (see the `export` query argument). If unset, exports are rejected
+	Export *api.ExportConfig `json:"export,omitempty" yaml:"export,omitempty"`
 }
 
+// TracingConfig stores the OpenTelemetry tracing configuration
+type TracingConfig struct {
+	// Endpoint: OTLP/HTTP collector endpoint (host:port) to which traces are exported
+	// Example: localhost:4318
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+
+	// Insecure: disables TLS when connecting to the collector
+	Insecure bool `json:"insecure" yaml:"insecure"`
+
+	// SampleRatio: fraction of queries which are traced. If unset, all queries are traced
+	// Example: 0.1
+	SampleRatio float64 `json:"sample_ratio" yaml:"sample_ratio"`
+}
+
+// MetricsConfig stores the configuration for exporting goProbe's internal metrics. Note that the
+// metrics are exposed to Prometheus via the API (if enabled) regardless of this configuration
+type MetricsConfig struct {
+	// OTLP: pushes the metrics to an OpenTelemetry collector
+	OTLP *OTLPMetricsConfig `json:"otlp" yaml:"otlp"`
+}
+
+// OTLPMetricsConfig stores the configuration for pushing metrics to an OpenTelemetry collector
+type OTLPMetricsConfig struct {
+	// Endpoint: OTLP/HTTP collector endpoint (host:port) to which metrics are pushed
+	// Example: localhost:4318
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+
+	// Insecure: disables TLS when connecting to the collector
+	Insecure bool `json:"insecure" yaml:"insecure"`
+
+	// Interval: push interval in seconds. If unset, DefaultMetricsPushInterval is used
+	// Example: 60
+	Interval int `json:"interval" yaml:"interval"`
+}
+
+// IdentityConfig stores the identity goProbe stamps into the results of its queries. Fields left
+// empty are detected from the system (hostname and machine ID)
+type IdentityConfig struct {
+	// Hostname: the hostname reported for the flows captured by this host
+	// Example: probe-01
+	Hostname string `json:"hostname" yaml:"hostname"`
+
+	// HostID: the unique identifier reported for the flows captured by this host
+	// Example: 2ef5e6b2b5c14f3d9f0b7a8d7c3f0a1e
+	HostID string `json:"host_id" yaml:"host_id"`
+
+	// Store: stores the identity in the database, so that queries run directly against the DB
+	// (e.g. via goQuery, or on a copy of the DB) report it as well
+	Store bool `json:"store" yaml:"store"`
+}
+
+// StreamTypeNATS denotes the NATS message bus
+const StreamTypeNATS = "nats"
+
+// StreamConfig stores the configuration for publishing the flows of each writeout to a message bus
+// (in addition to writing them to the database)
+type StreamConfig struct {
+	// Type: the message bus the flows are published to. Example: nats
+	Type string `json:"type" yaml:"type"`
+
+	// Address: address of the message bus. Example: nats.example.com:4222
+	Address string `json:"address" yaml:"address"`
+
+	// Subject: prefix of the subjects the flows are published on. The flows of an interface are
+	// published as JSON on "<subject>.<iface>". Example: goprobe.flows
+	Subject string `json:"subject" yaml:"subject"`
+}
+
+// AggregatorConfig stores the configuration for streaming the flows of each writeout to a central
+// aggregator (see "godb aggregate"), which writes the flows of many hosts to a fleet-wide database
+type AggregatorConfig struct {
+	// Address: address of the aggregator. Example: aggregator.example.com:8147
+	Address string `json:"address" yaml:"address"`
+
+	// SkipLocalDB: don't write the flows to the local database (e.g. on sensors without storage).
+	// Sinks depending on the local writeout (stream, flow sampling, writeout hooks, mirror) are
+	// disabled as well
+	SkipLocalDB bool `json:"skip_local_db" yaml:"skip_local_db"`
+
+	// BatchSize: maximum number of flows sent per message. Example: 16384
+	BatchSize int `json:"batch_size" yaml:"batch_size"`
+
+	// TLS: secures the connection to the aggregator. Without it, the flows are sent in plain text
+	TLS *aggregator.TLSConfig `json:"tls,omitempty" yaml:"tls,omitempty"`
+
+	// Token: shared token presented to the aggregator (see "godb aggregate --token-file")
+	Token string `json:"token,omitempty" yaml:"token,omitempty"`
+}
+
+const (
+	// SelfMonitoringRecord moves the traffic caused by goProbe itself to a pseudo-interface
+	SelfMonitoringRecord = "record"
+
+	// SelfMonitoringExclude discards the traffic caused by goProbe itself
+	SelfMonitoringExclude = "exclude"
+
+	// DefaultSelfMonitoringIface denotes the default name of the pseudo-interface goProbe's own
+	// traffic is recorded in
+	DefaultSelfMonitoringIface = "goprobe"
+)
+
+// SelfMonitoringConfig stores the configuration for separating the traffic caused by goProbe itself
+// (API requests, as well as streaming, metrics, tracing and syslog connections) from the captured
+// traffic, so that measurements aren't polluted by the tool and its overhead can be quantified
+type SelfMonitoringConfig struct {
+	// Mode: one of "record" (moves goProbe's own flows to a dedicated pseudo-interface) or "exclude"
+	// (discards them). Example: record
+	Mode string `json:"mode" yaml:"mode"`
+
+	// Iface: name of the pseudo-interface goProbe's own flows are recorded in. If unset, "goprobe"
+	// is used. Example: goprobe-self
+	Iface string `json:"iface,omitempty" yaml:"iface,omitempty"`
+}
+
+// PseudoIface returns the name of the pseudo-interface goProbe's own flows are recorded in (empty
+// if they are discarded)
+func (s SelfMonitoringConfig) PseudoIface() string {
+	if s.Mode != SelfMonitoringRecord {
+		return ""
+	}
+	if s.Iface == "" {
+		return DefaultSelfMonitoringIface
+	}
+	return s.Iface
+}
+
+// DefaultMetricsPushInterval denotes the default interval (in seconds) in which metrics are pushed
+const DefaultMetricsPushInterval = 30
+
 // newDefault creates a new configuration struct with default settings
 func newDefault() *Config {
 	return &Config{
@@ -143,7 +557,47 @@ func newDefault() *Config {
 	}
 }
 
+var (
+	errorSyslogAddress          = errors.New("syslog network and address must be provided together")
+	errorLogRotationDestination = errors.New("log rotation requires a log file destination")
+	errorLogRotationNoLimit     = errors.New("log rotation requires a positive maximum size and / or age")
+	errorLogRotationBackups     = errors.New("the number of log file backups must not be negative")
+)
+
+// IsFileDestination returns true if the logs are written to a file (as opposed to the standard
+// outputs, syslog or the journal)
+func (l LogConfig) IsFileDestination() bool {
+	switch strings.ToLower(l.Destination) {
+	case "", "stdout", "stderr", "devnull", logging.DestinationSyslog, logging.DestinationJournal:
+		return false
+	}
+	return true
+}
+
 func (l LogConfig) validate() error {
+	if l.Rotation != nil {
+		if !l.IsFileDestination() {
+			return errorLogRotationDestination
+		}
+		if l.Rotation.MaxSize < 0 || l.Rotation.MaxAge < 0 || (l.Rotation.MaxSize == 0 && l.Rotation.MaxAge == 0) {
+			return errorLogRotationNoLimit
+		}
+		if l.Rotation.MaxBackups < 0 {
+			return errorLogRotationBackups
+		}
+	}
+
+	if l.Syslog == nil {
+		return nil
+	}
+	if (l.Syslog.Network == "") != (l.Syslog.Address == "") {
+		return errorSyslogAddress
+	}
+	if l.Syslog.Facility != "" {
+		if _, err := logging.ParseFacility(l.Syslog.Facility); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -151,6 +605,14 @@ var (
 	errorNoAPIAddrSpecified       = errors.New("no API address specified")
 	errorInvalidAPITimeout        = errors.New("the request timeout must be a positive number")
 	errorInvalidAPIQueryRateLimit = errors.New("the query rate limit values must both be positive numbers")
+	errorInvalidAPIQueryReadLimit = errors.New("the query read rate limit must not be negative")
+	errorInvalidAPIQueryWorkers   = errors.New("the number of query workers must not be negative")
+	errorInvalidAPIMaxBodySize    = errors.New("the maximum request body size must not be negative")
+	errorUnknownTenantKey         = errors.New("tenant restricted API key is not among the configured keys")
+	errorNoKeyTenants             = errors.New("tenant restricted API key requires at least one tenant")
+	errorUnknownRoleKey           = errors.New("API key with role is not among the configured keys")
+	errorUnknownQuotaKey          = errors.New("API key with quota is not among the configured keys")
+	errorInvalidKeyQuota          = errors.New("the query runtime quota of an API key must not be negative")
 )
 
 func (a APIConfig) validate() error {
@@ -171,6 +633,144 @@ func (a APIConfig) validate() error {
 	if a.Timeout < 0 {
 		return errorInvalidAPITimeout
 	}
+	if a.QueryMaxBlocksPerSec < 0 {
+		return errorInvalidAPIQueryReadLimit
+	}
+	if a.QueryWorkers < 0 || a.QueryMaxWorkers < 0 {
+		return errorInvalidAPIQueryWorkers
+	}
+	if a.MaxRequestBodySize < 0 {
+		return errorInvalidAPIMaxBodySize
+	}
+	for key, tenants := range a.KeyTenants {
+		if !slices.Contains(a.Keys, key) {
+			return errorUnknownTenantKey
+		}
+		if len(tenants) == 0 {
+			return errorNoKeyTenants
+		}
+		for _, tenant := range tenants {
+			if err := info.ValidateTenant(tenant); err != nil {
+				return err
+			}
+		}
+	}
+	for key, role := range a.KeyRoles {
+		if !slices.Contains(a.Keys, key) {
+			return errorUnknownRoleKey
+		}
+		if err := role.Validate(); err != nil {
+			return err
+		}
+	}
+	for key, quota := range a.KeyQuotas {
+		if !slices.Contains(a.Keys, key) {
+			return errorUnknownQuotaKey
+		}
+		if quota.Runtime < 0 {
+			return errorInvalidKeyQuota
+		}
+	}
+	if a.Export != nil {
+		return a.Export.Validate()
+	}
+	return nil
+}
+
+var (
+	errorNoTracingEndpoint     = errors.New("no tracing collector endpoint specified")
+	errorInvalidTracingSampler = errors.New("tracing sample ratio must be in [0, 1]")
+)
+
+func (t TracingConfig) validate() error {
+	if t.Endpoint == "" {
+		return errorNoTracingEndpoint
+	}
+	if t.SampleRatio < 0 || t.SampleRatio > 1 {
+		return errorInvalidTracingSampler
+	}
+	return nil
+}
+
+var (
+	errorNoMetricsEndpoint     = errors.New("no metrics collector endpoint specified")
+	errorInvalidMetricsInteval = errors.New("the metrics push interval must be a positive number")
+)
+
+func (m MetricsConfig) validate() error {
+	if m.OTLP == nil {
+		return nil
+	}
+	if m.OTLP.Endpoint == "" {
+		return errorNoMetricsEndpoint
+	}
+	if m.OTLP.Interval < 0 {
+		return errorInvalidMetricsInteval
+	}
+	return nil
+}
+
+var (
+	errorInvalidIdentity = errors.New("hostname and host ID must not contain whitespace")
+)
+
+func (i IdentityConfig) validate() error {
+	if strings.ContainsFunc(i.Hostname, unicode.IsSpace) || strings.ContainsFunc(i.HostID, unicode.IsSpace) {
+		return errorInvalidIdentity
+	}
+	return nil
+}
+
+var (
+	errorUnsupportedStreamType = errors.New("unsupported stream type")
+	errorNoStreamAddress       = errors.New("no stream address specified")
+	errorInvalidStreamSubject  = errors.New("stream subject must not be empty or contain whitespace")
+)
+
+func (s StreamConfig) validate() error {
+	if s.Type != StreamTypeNATS {
+		return fmt.Errorf("%w: `%s`", errorUnsupportedStreamType, s.Type)
+	}
+	if s.Address == "" {
+		return errorNoStreamAddress
+	}
+	if s.Subject == "" || strings.ContainsFunc(s.Subject, unicode.IsSpace) {
+		return errorInvalidStreamSubject
+	}
+	return nil
+}
+
+var (
+	errorInvalidAggregatorAddress   = errors.New("aggregator address must be of the form host:port")
+	errorInvalidAggregatorBatchSize = errors.New("aggregator batch size must not be negative")
+)
+
+func (a AggregatorConfig) validate() error {
+	if _, _, err := net.SplitHostPort(a.Address); err != nil {
+		return fmt.Errorf("%w: %w", errorInvalidAggregatorAddress, err)
+	}
+	if a.BatchSize < 0 {
+		return errorInvalidAggregatorBatchSize
+	}
+	if a.TLS != nil {
+		return a.TLS.Validate()
+	}
+	return nil
+}
+
+var (
+	errorInvalidSelfMonitoringMode  = errors.New("self monitoring mode must be one of `record` or `exclude`")
+	errorInvalidSelfMonitoringIface = errors.New("self monitoring interface name must not contain whitespace or path separators")
+	errorSelfMonitoringIfaceClash   = errors.New("self monitoring interface coincides with a configured interface or alias")
+)
+
+func (s SelfMonitoringConfig) validate() error {
+	if s.Mode != SelfMonitoringRecord && s.Mode != SelfMonitoringExclude {
+		return fmt.Errorf("%w: `%s`", errorInvalidSelfMonitoringMode, s.Mode)
+	}
+	if strings.ContainsFunc(s.Iface, unicode.IsSpace) || strings.ContainsAny(s.Iface, `/\`) || s.Iface == "." || s.Iface == ".." {
+		return errorInvalidSelfMonitoringIface
+	}
 	return nil
 }
 
@@ -190,16 +790,90 @@ func (l LocalBufferConfig) validate() error {
 }
 
 var (
-	errorNoRingBufferConfig = errors.New("no ring buffer configuration specified")
+	errorNoRingBufferConfig      = errors.New("no ring buffer configuration specified")
+	errorEncoderLevelWithoutType = errors.New("encoder level requires the encoder type to be set")
+)
+
+var (
+	errorEmptyExclusionRule    = errors.New("exclusion rule must specify at least one of net, port or proto")
+	errorInvalidExclusionNet   = errors.New("invalid exclusion network")
+	errorInvalidExclusionProto = errors.New("invalid exclusion protocol")
 )
 
+func (r ExclusionRule) validate() error {
+	if r.Net == "" && r.Port == 0 && r.Proto == "" {
+		return errorEmptyExclusionRule
+	}
+	if _, err := r.ParseNet(); err != nil {
+		return err
+	}
+	_, _, err := r.ParseProto()
+	return err
+}
+
 func (c CaptureConfig) validate() error {
 	if c.RingBuffer == nil {
 		return errorNoRingBufferConfig
 	}
+	if err := info.ValidateTenant(c.Tenant); err != nil {
+		return err
+	}
+	if c.Alias != "" {
+		if err := info.ValidateAlias(c.Alias); err != nil {
+			return err
+		}
+	}
+	for _, group := range c.Groups {
+		if err := info.ValidateGroupName(group); err != nil {
+			return err
+		}
+	}
+	if c.EncoderType == "" {
+		if c.EncoderLevel != 0 {
+			return errorEncoderLevelWithoutType
+		}
+	} else {
+		encoderType, err := encoders.GetTypeByString(c.EncoderType)
+		if err != nil {
+			return err
+		}
+		if err = encoders.ValidateLevel(encoderType, c.EncoderLevel); err != nil {
+			return err
+		}
+	}
+	for _, rule := range c.Exclude {
+		if err := rule.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Scheduling != nil {
+		if err := c.Scheduling.validate(); err != nil {
+			return err
+		}
+	}
 	return c.RingBuffer.validate()
 }
 
+// maxCPU denotes the highest CPU index a capture can be pinned to (CPU_SETSIZE on Linux)
+const maxCPU = 1023
+
+var (
+	errorInvalidSchedulingCPU  = errors.New("scheduling CPU must be between 0 and 1023")
+	errorInvalidSchedulingNice = errors.New("scheduling niceness must be between -20 and 19")
+)
+
+func (s SchedulingConfig) validate() error {
+	for _, cpu := range s.CPUs {
+		if cpu < 0 || cpu > maxCPU {
+			return fmt.Errorf("%w: %d", errorInvalidSchedulingCPU, cpu)
+		}
+	}
+	if s.Nice < -20 || s.Nice > 19 {
+		return fmt.Errorf("%w: %d", errorInvalidSchedulingNice, s.Nice)
+	}
+	return nil
+}
+
 var (
 	errorRingBufferBlockSize = errors.New("ring buffer block size must be a postive number")
 	errorRingBufferNumBlocks = errors.New("ring buffer num blocks must be a postive number")
@@ -218,9 +892,25 @@ func (r *RingBufferConfig) validate() error {
 // Equals compares c to cfg and returns true if all fields are identical
 func (c CaptureConfig) Equals(cfg CaptureConfig) bool {
 	return c.Promisc == cfg.Promisc &&
+		c.Tenant == cfg.Tenant &&
+		c.Alias == cfg.Alias &&
+		slices.Equal(c.Groups, cfg.Groups) &&
+		strings.EqualFold(c.EncoderType, cfg.EncoderType) &&
+		c.EncoderLevel == cfg.EncoderLevel &&
+		slices.Equal(c.Exclude, cfg.Exclude) &&
+		c.ProcessAttribution == cfg.ProcessAttribution &&
+		c.Scheduling.Equals(cfg.Scheduling) &&
 		c.RingBuffer.Equals(cfg.RingBuffer)
 }
 
+// Equals compares s to cfg and returns true if all fields are identical
+func (s *SchedulingConfig) Equals(cfg *SchedulingConfig) bool {
+	if s == nil || cfg == nil {
+		return s == cfg
+	}
+	return slices.Equal(s.CPUs, cfg.CPUs) && s.Nice == cfg.Nice
+}
+
 // Equals compares r to cfg and returns true if all fields are identical
 func (r *RingBufferConfig) Equals(cfg *RingBufferConfig) bool {
 	if cfg == nil {
@@ -231,6 +921,9 @@ func (r *RingBufferConfig) Equals(cfg *RingBufferConfig) bool {
 
 var (
 	errorNoInterfacesSpecified = errors.New("no interfaces specified")
+	errorDuplicateAlias        = errors.New("alias is assigned to more than one interface")
+	errorAliasIsInterface      = errors.New("alias coincides with the name of a configured interface")
+	errorGroupIsInterface      = errors.New("group coincides with the name or alias of a configured interface")
 )
 
 func (i Ifaces) validate() error {
@@ -238,22 +931,84 @@ func (i Ifaces) validate() error {
 		return errorNoInterfacesSpecified
 	}
 
+	aliases := make(info.Aliases)
 	for iface, cc := range i {
 		err := cc.validate()
 		if err != nil {
 			return fmt.Errorf("%s: %w", iface, err)
 		}
+		if cc.Alias == "" {
+			continue
+		}
+		if _, exists := aliases[cc.Alias]; exists {
+			return fmt.Errorf("%s: %w: `%s`", iface, errorDuplicateAlias, cc.Alias)
+		}
+		if _, exists := i[cc.Alias]; exists {
+			return fmt.Errorf("%s: %w: `%s`", iface, errorAliasIsInterface, cc.Alias)
+		}
+		aliases[cc.Alias] = iface
+	}
+
+	for group := range i.Groups() {
+		if _, exists := i[group]; exists {
+			return fmt.Errorf("%w: `%s`", errorGroupIsInterface, group)
+		}
+		if _, exists := aliases[group]; exists {
+			return fmt.Errorf("%w: `%s`", errorGroupIsInterface, group)
+		}
 	}
 	return nil
 }
 
+// Aliases returns the aliases assigned to the interfaces
+func (i Ifaces) Aliases() info.Aliases {
+	aliases := make(info.Aliases)
+	for iface, cc := range i {
+		if cc.Alias != "" {
+			aliases[cc.Alias] = iface
+		}
+	}
+	return aliases
+}
+
+// Groups returns the interface groups the interfaces are members of
+func (i Ifaces) Groups() info.Groups {
+	groups := make(info.Groups)
+	for iface, cc := range i {
+		for _, group := range cc.Groups {
+			if !slices.Contains(groups[group], iface) {
+				groups[group] = append(groups[group], iface)
+			}
+		}
+	}
+	return groups
+}
+
 // Validate validates the interfaces configuration
 func (i Ifaces) Validate() error {
 	return i.validate()
 }
 
 var (
-	errorEmptyDBPath = errors.New("database path must not be empty")
+	errorEmptyDBPath    = errors.New("database path must not be empty")
+	errorMirrorIsDBPath = errors.New("database mirror path must differ from the database path")
+
+	errorSnapshotInDBPath = errors.New("database snapshot path must not be located within the database path")
+
+	errorInvalidWriteoutQueueLength = errors.New("writeout queue length must not be negative")
+	errorInvalidWriteoutWorkers     = errors.New("number of writeout workers must not be negative")
+	errorInvalidWriteoutStagger     = errors.New("writeout stagger must neither be negative nor exceed half of the writeout interval")
+	errorInvalidFlowStateMaxAge     = errors.New("maximum age of persisted flows must not be negative")
+
+	errorInvalidDirPermissions = errors.New("database directory permissions must only contain permission bits")
+	errorInvalidDBOwner        = errors.New("database owner must be of the form `user` or `user:group`")
+
+	errorInvalidFlowSamplingRate = errors.New("flow sampling rate must be positive")
+	errorFlowSamplesInDBPath     = errors.New("flow sample path must not be located within the database path")
+
+	errorInvalidWriteoutHook        = errors.New("writeout hook must either execute a command or call a URL")
+	errorInvalidWriteoutHookURL     = errors.New("writeout hook URL must be an absolute http(s) URL")
+	errorInvalidWriteoutHookTimeout = errors.New("writeout hook timeout must not be negative")
 )
 
 func (d DBConfig) validate() error {
@@ -264,23 +1019,133 @@ func (d DBConfig) validate() error {
 	if err != nil {
 		return err
 	}
+	if d.Instance != "" {
+		if err := info.ValidateInstance(d.Instance); err != nil {
+			return err
+		}
+	}
+	if d.MirrorPath != "" && filepath.Clean(d.MirrorPath) == filepath.Clean(d.Path) {
+		return errorMirrorIsDBPath
+	}
+	if d.SnapshotPath != "" && isWithinPath(d.Path, d.SnapshotPath) {
+		return errorSnapshotInDBPath
+	}
+	if d.WriteoutQueueLength < 0 {
+		return errorInvalidWriteoutQueueLength
+	}
+	if d.WriteoutWorkers < 0 {
+		return errorInvalidWriteoutWorkers
+	}
+	if d.WriteoutStagger < 0 || d.WriteoutStagger > maxWriteoutStagger {
+		return fmt.Errorf("%w: %ds", errorInvalidWriteoutStagger, d.WriteoutStagger)
+	}
+	if d.FlowStateMaxAge < 0 {
+		return errorInvalidFlowStateMaxAge
+	}
+	if d.DirPermissions&^fs.ModePerm != 0 {
+		return fmt.Errorf("%w: %o", errorInvalidDirPermissions, d.DirPermissions)
+	}
+	if d.Owner != "" && !isUserSpec(d.Owner) {
+		return fmt.Errorf("%w: `%s`", errorInvalidDBOwner, d.Owner)
+	}
+	if d.FlowSampling != nil {
+		if d.FlowSampling.Rate < 1 {
+			return errorInvalidFlowSamplingRate
+		}
+		if d.FlowSampling.Path != "" && isWithinPath(d.Path, d.FlowSampling.Path) {
+			return errorFlowSamplesInDBPath
+		}
+	}
+	for _, hook := range d.WriteoutHooks {
+		if err := hook.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h WriteoutHookConfig) validate() error {
+	if (len(h.Exec) == 0) == (h.URL == "") {
+		return errorInvalidWriteoutHook
+	}
+	if len(h.Exec) > 0 && h.Exec[0] == "" {
+		return errorInvalidWriteoutHook
+	}
+	if h.URL != "" {
+		u, err := url.Parse(h.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: `%s`", errorInvalidWriteoutHookURL, redactUserInfo(h.URL))
+		}
+	}
+	if h.Timeout < 0 {
+		return errorInvalidWriteoutHookTimeout
+	}
+	return nil
+}
+
+// isUserSpec determines if spec is of the form `user` or `user:group`
+func isUserSpec(spec string) bool {
+	user, group, hasGroup := strings.Cut(spec, ":")
+	return user != "" && (!hasGroup || (group != "" && !strings.Contains(group, ":")))
+}
+
+// isWithinPath determines if path is (or is located within) base
+func isWithinPath(base, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(base), filepath.Clean(path))
+	return err == nil && !strings.HasPrefix(rel, "..")
+}
+
+var (
+	errorArchiveWithoutAPI = errors.New("archive mode requires the API to be configured")
+	errorInvalidRunAs      = errors.New("run_as must be of the form `user` or `user:group`")
+	errorRunAsPrivileged   = errors.New("run_as can't be combined with settings requiring root privileges after startup")
+)
+
+// validateRunAs makes sure that nothing requires root privileges once they were dropped
+func (c *Config) validateRunAs() error {
+	if c.DB.Owner != "" {
+		return fmt.Errorf("%w: db.owner", errorRunAsPrivileged)
+	}
+	for iface, cfg := range c.Interfaces {
+		if cfg.ProcessAttribution {
+			return fmt.Errorf("%w: interfaces.%s.process_attribution", errorRunAsPrivileged, iface)
+		}
+		if cfg.Scheduling != nil && cfg.Scheduling.Nice < 0 {
+			return fmt.Errorf("%w: interfaces.%s.scheduling.nice", errorRunAsPrivileged, iface)
+		}
+	}
 	return nil
 }
 
 // Validate checks all config parameters
 func (c *Config) Validate() error {
-	// run all config subsection validators
-	for _, section := range []validator{
-		c.DB,
-		c.Interfaces,
-		c.Logging,
-	} {
+	// run all config subsection validators. In archive mode, nothing is captured, hence
+	// interfaces are optional
+	sections := []validator{c.DB, c.Logging}
+	if !c.Archive || len(c.Interfaces) > 0 {
+		sections = append(sections, c.Interfaces)
+	}
+	for _, section := range sections {
 		err := section.validate()
 		if err != nil {
 			return err
 		}
 	}
 
+	// an archive server is of no use if it can't be queried
+	if c.Archive && c.API == nil {
+		return errorArchiveWithoutAPI
+	}
+
+	if c.RunAs != "" {
+		if !isUserSpec(c.RunAs) {
+			return fmt.Errorf("%w: `%s`", errorInvalidRunAs, c.RunAs)
+		}
+		if err := c.validateRunAs(); err != nil {
+			return err
+		}
+	}
+
 	// run all config subsection validators for optional sections
 	optValidators := []validator{}
 	if c.API != nil {
@@ -289,12 +1154,42 @@ func (c *Config) Validate() error {
 	if c.LocalBuffers != nil {
 		optValidators = append(optValidators, c.LocalBuffers)
 	}
+	if c.Tracing != nil {
+		optValidators = append(optValidators, c.Tracing)
+	}
+	if c.Metrics != nil {
+		optValidators = append(optValidators, c.Metrics)
+	}
+	if c.Identity != nil {
+		optValidators = append(optValidators, c.Identity)
+	}
+	if c.Stream != nil {
+		optValidators = append(optValidators, c.Stream)
+	}
+	if c.Aggregator != nil {
+		optValidators = append(optValidators, c.Aggregator)
+	}
+	if c.SelfMonitoring != nil {
+		optValidators = append(optValidators, c.SelfMonitoring)
+	}
 	for _, section := range optValidators {
 		err := section.validate()
 		if err != nil {
 			return err
 		}
 	}
+
+	// the pseudo-interface must not clash with the captured interfaces (or their aliases)
+	if c.SelfMonitoring != nil {
+		if iface := c.SelfMonitoring.PseudoIface(); iface != "" {
+			if _, exists := c.Interfaces[iface]; exists {
+				return fmt.Errorf("%w: `%s`", errorSelfMonitoringIfaceClash, iface)
+			}
+			if _, exists := c.Interfaces.Aliases()[iface]; exists {
+				return fmt.Errorf("%w: `%s`", errorSelfMonitoringIfaceClash, iface)
+			}
+		}
+	}
 	return nil
 }
 
@@ -346,6 +1241,91 @@ func Parse(src io.Reader) (*Config, error) {
 	return config, nil
 }
 
+// redacted replaces secrets in the redacted copy of a configuration
+const redacted = "<redacted>"
+
+// Redacted returns a copy of the configuration with all secrets (API keys and credentials contained
+// in addresses) replaced, so that it can be shared (e.g. as part of a support bundle)
+func (c *Config) Redacted() (*Config, error) {
+	c.Lock()
+	b, err := jsoniter.Marshal(c)
+	c.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy config: %w", err)
+	}
+
+	cfg := new(Config)
+	if err := jsoniter.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("failed to copy config: %w", err)
+	}
+
+	if cfg.API != nil {
+		// keys are replaced by numbered placeholders, so that the tenants, roles and quotas assigned to
+		// a key can still be told apart
+		placeholders := make(map[string]string, len(cfg.API.Keys))
+		for i, key := range cfg.API.Keys {
+			placeholders[key] = fmt.Sprintf("%s-%d", redacted, i+1)
+			cfg.API.Keys[i] = placeholders[key]
+		}
+		if len(cfg.API.KeyTenants) > 0 {
+			keyTenants := make(map[string][]string, len(cfg.API.KeyTenants))
+			for key, tenants := range cfg.API.KeyTenants {
+				keyTenants[redactKey(placeholders, key)] = tenants
+			}
+			cfg.API.KeyTenants = keyTenants
+		}
+		if len(cfg.API.KeyRoles) > 0 {
+			keyRoles := make(map[string]api.Role, len(cfg.API.KeyRoles))
+			for key, role := range cfg.API.KeyRoles {
+				keyRoles[redactKey(placeholders, key)] = role
+			}
+			cfg.API.KeyRoles = keyRoles
+		}
+		if len(cfg.API.KeyQuotas) > 0 {
+			keyQuotas := make(map[string]api.KeyQuota, len(cfg.API.KeyQuotas))
+			for key, quota := range cfg.API.KeyQuotas {
+				keyQuotas[redactKey(placeholders, key)] = quota
+			}
+			cfg.API.KeyQuotas = keyQuotas
+		}
+		if cfg.API.Export != nil && cfg.API.Export.S3 != nil {
+			cfg.API.Export.S3.SecretAccessKey = redacted
+		}
+	}
+	if cfg.Stream != nil {
+		cfg.Stream.Address = redactUserInfo(cfg.Stream.Address)
+	}
+	for i, hook := range cfg.DB.WriteoutHooks {
+		cfg.DB.WriteoutHooks[i].URL = redactUserInfo(hook.URL)
+		for key := range hook.Headers {
+			hook.Headers[key] = redacted
+		}
+	}
+	return cfg, nil
+}
+
+// redactKey returns the placeholder of an API key. Keys which aren't among the configured ones
+// are redacted entirely
+func redactKey(placeholders map[string]string, key string) string {
+	if placeholder, exists := placeholders[key]; exists {
+		return placeholder
+	}
+	return redacted
+}
+
+// redactUserInfo removes the credentials from an address of the form [scheme://][user[:password]@]host
+func redactUserInfo(addr string) string {
+	var scheme string
+	if i := strings.Index(addr, "://"); i >= 0 {
+		scheme, addr = addr[:i+len("://")], addr[i+len("://"):]
+	}
+	userEnd := strings.LastIndex(addr, "@")
+	if userEnd < 0 {
+		return scheme + addr
+	}
+	return scheme + redacted + addr[userEnd:]
+}
+
 func checkKeyConstraints(key string) error {
 	// enforce long API keys (e.g. SHA256)
 	if len(key) < 32 {
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// IfacesDiff denotes the differences between two interface configurations, e.g. between the running
+// configuration and the one stored on disk
+type IfacesDiff struct {
+	Added   []string               `json:"added,omitempty"`   // Added: interfaces only present in the new configuration. Example: ["eth2"]
+	Removed []string               `json:"removed,omitempty"` // Removed: interfaces only present in the old configuration. Example: ["eth5"]
+	Changed map[string][]FieldDiff `json:"changed,omitempty"` // Changed: the differing fields of the interfaces present in both configurations
+}
+
+// FieldDiff denotes a field of an interface configuration whose value differs
+type FieldDiff struct {
+	Field string `json:"field"` // Field: path of the field. Example: ring_buffer.num_blocks
+	Old   any    `json:"old"`   // Old: value of the field in the old configuration. Example: 4
+	New   any    `json:"new"`   // New: value of the field in the new configuration. Example: 8
+}
+
+// InSync returns true if there are no differences
+func (d IfacesDiff) InSync() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff compares i (old) to cfg (new) and returns their differences. An interface is reported as
+// changed if (and only if) its configurations aren't equal (see CaptureConfig.Equals())
+func (i Ifaces) Diff(cfg Ifaces) (diff IfacesDiff) {
+	for iface, cc := range i {
+		newCC, exists := cfg[iface]
+		if !exists {
+			diff.Removed = append(diff.Removed, iface)
+			continue
+		}
+		if fields := cc.diff(newCC); len(fields) > 0 {
+			if diff.Changed == nil {
+				diff.Changed = make(map[string][]FieldDiff)
+			}
+			diff.Changed[iface] = fields
+		}
+	}
+	for iface := range cfg {
+		if _, exists := i[iface]; !exists {
+			diff.Added = append(diff.Added, iface)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+
+	return diff
+}
+
+func (c CaptureConfig) diff(cfg CaptureConfig) (fields []FieldDiff) {
+	add := func(field string, old, new any) {
+		fields = append(fields, FieldDiff{Field: field, Old: old, New: new})
+	}
+
+	if c.Promisc != cfg.Promisc {
+		add("promisc", c.Promisc, cfg.Promisc)
+	}
+	if c.Tenant != cfg.Tenant {
+		add("tenant", c.Tenant, cfg.Tenant)
+	}
+	if c.Alias != cfg.Alias {
+		add("alias", c.Alias, cfg.Alias)
+	}
+	if !slices.Equal(c.Groups, cfg.Groups) {
+		add("groups", c.Groups, cfg.Groups)
+	}
+	if !strings.EqualFold(c.EncoderType, cfg.EncoderType) {
+		add("encoder_type", c.EncoderType, cfg.EncoderType)
+	}
+	if c.EncoderLevel != cfg.EncoderLevel {
+		add("encoder_level", c.EncoderLevel, cfg.EncoderLevel)
+	}
+	if !slices.Equal(c.Exclude, cfg.Exclude) {
+		add("exclude", c.Exclude, cfg.Exclude)
+	}
+	if c.ProcessAttribution != cfg.ProcessAttribution {
+		add("process_attribution", c.ProcessAttribution, cfg.ProcessAttribution)
+	}
+	if !c.Scheduling.Equals(cfg.Scheduling) {
+		add("scheduling", c.Scheduling, cfg.Scheduling)
+	}
+
+	// a missing ring buffer configuration never equals any other (see RingBufferConfig.Equals())
+	if c.RingBuffer == nil || cfg.RingBuffer == nil {
+		add("ring_buffer", c.RingBuffer, cfg.RingBuffer)
+		return fields
+	}
+	if c.RingBuffer.BlockSize != cfg.RingBuffer.BlockSize {
+		add("ring_buffer.block_size", c.RingBuffer.BlockSize, cfg.RingBuffer.BlockSize)
+	}
+	if c.RingBuffer.NumBlocks != cfg.RingBuffer.NumBlocks {
+		add("ring_buffer.num_blocks", c.RingBuffer.NumBlocks, cfg.RingBuffer.NumBlocks)
+	}
+	return fields
+}
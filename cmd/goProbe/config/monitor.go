@@ -79,6 +79,21 @@ func (m *Monitor) PutIfaceConfig(cfg Ifaces) {
 	m.Unlock()
 }
 
+// Diff compares the interface configuration of running (e.g. as modified via the API) to the one of
+// the config file on disk (without reloading it)
+func (m *Monitor) Diff(running Ifaces) (IfacesDiff, error) {
+	cfg, err := ParseFile(m.path)
+	if err != nil {
+		return IfacesDiff{}, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return running.Diff(cfg.Interfaces), nil
+}
+
+// Path returns the path of the monitored config file
+func (m *Monitor) Path() string {
+	return m.path
+}
+
 // Start initializaes the config monitor background task(s)
 func (m *Monitor) Start(ctx context.Context, fn CallbackFn) {
 	go m.reloadPeriodically(ctx, fn)
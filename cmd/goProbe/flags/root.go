@@ -8,8 +8,9 @@ import (
 
 // Flags stores goProbe's command line parameters
 type Flags struct {
-	Config  string
-	Version bool
+	Config         string
+	Version        bool
+	ValidateConfig bool
 }
 
 // CmdLine globally exposes the parsed flags
@@ -19,6 +20,7 @@ var CmdLine = &Flags{}
 func Read() error {
 	flag.StringVar(&CmdLine.Config, "config", "", "path to goProbe's configuration file (required)")
 	flag.BoolVar(&CmdLine.Version, "version", false, "print goProbe's version and exit")
+	flag.BoolVar(&CmdLine.ValidateConfig, "validate-config", false, "validate the configuration against the host (interfaces, DB path, API address) and exit")
 
 	flag.Parse()
 
@@ -15,6 +15,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"net/http"
 	"os"
 	"os/signal"
@@ -26,6 +28,14 @@ import (
 	gpserver "github.com/els0r/goProbe/pkg/api/goprobe/server"
 	"github.com/els0r/goProbe/pkg/api/server"
 	"github.com/els0r/goProbe/pkg/capture"
+	"github.com/els0r/goProbe/pkg/goDB"
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/goDB/protocols"
+	gplogging "github.com/els0r/goProbe/pkg/logging"
+	"github.com/els0r/goProbe/pkg/metrics"
+	"github.com/els0r/goProbe/pkg/privileges"
+	"github.com/els0r/goProbe/pkg/systemd"
+	"github.com/els0r/goProbe/pkg/telemetry/tracing"
 	"github.com/els0r/goProbe/pkg/version"
 	"github.com/els0r/telemetry/logging"
 
@@ -51,6 +61,22 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Apply the site-specific IP protocol names (if present) before they are referenced by the config
+	if err := protocols.LoadOverrides(protocols.DefaultOverridesFile); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load IP protocols: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Check the config file and exit, reporting all issues found
+	if flags.CmdLine.ValidateConfig {
+		if err := validateConfig(flags.CmdLine.Config); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("configuration %s is valid\n", flags.CmdLine.Config)
+		os.Exit(0)
+	}
+
 	// Read / parse config file
 	configMonitor, err := gpconf.NewMonitor(flags.CmdLine.Config)
 	if err != nil {
@@ -63,11 +89,74 @@ func main() {
 	loggerOpts := []logging.Option{
 		logging.WithVersion(appVersion),
 	}
-	if config.Logging.Destination != "" {
-		loggerOpts = append(loggerOpts, logging.WithFileOutput(config.Logging.Destination))
+	logEncoding := logging.Encoding(config.Logging.Encoding)
+
+	// files created at startup (while still running as root) are assigned to the user the privileges
+	// are dropped to (if configured, see below)
+	var startupFiles []string
+
+	// errors are additionally retained in memory in order to include them in support bundles
+	var logOutput io.Writer = os.Stdout
+	recentErrors := gplogging.NewRecentLines(gplogging.DefaultRecentLines)
+	switch config.Logging.Destination {
+	case "":
+	case gplogging.DestinationSyslog, gplogging.DestinationJournal:
+		// the syslog / journal sinks consume JSON in order to map the structured fields
+		logSink, err := newLogSink(config.Logging)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to initialize %s logging: %v\n", config.Logging.Destination, err)
+			os.Exit(1)
+		}
+		defer logSink.Close()
+
+		logEncoding = logging.EncodingJSON
+		logOutput = logSink
+	default:
+		if config.Logging.Rotation == nil || !config.Logging.IsFileDestination() {
+			logDestination, err := gplogging.OpenDestination(config.Logging.Destination)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to open log destination: %v\n", err)
+				os.Exit(1)
+			}
+			if config.Logging.IsFileDestination() {
+				startupFiles = append(startupFiles, config.Logging.Destination)
+			}
+			defer func() {
+				if err := logDestination.Close(); err != nil {
+					fmt.Fprintf(os.Stderr, "failed to close log destination: %v\n", err)
+				}
+			}()
+
+			logOutput = logDestination
+			break
+		}
+
+		rotation := config.Logging.Rotation
+		logFile, err := gplogging.NewRotatingFileWriter(config.Logging.Destination,
+			gplogging.WithMaxSize(rotation.MaxSize),
+			gplogging.WithMaxAge(time.Duration(rotation.MaxAge)*time.Second),
+			gplogging.WithMaxBackups(rotation.MaxBackups),
+			gplogging.WithCompression(rotation.Compress),
+		)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to initialize log file: %v\n", err)
+			os.Exit(1)
+		}
+		startupFiles = append(startupFiles, config.Logging.Destination)
+		defer func() {
+			if err := logFile.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to close log file: %v\n", err)
+			}
+		}()
+
+		logOutput = logFile
 	}
+	loggerOpts = append(loggerOpts,
+		logging.WithOutput(logOutput),
+		logging.WithErrorOutput(io.MultiWriter(logOutput, recentErrors)),
+	)
 
-	err = logging.Init(logging.LevelFromString(config.Logging.Level), logging.Encoding(config.Logging.Encoding),
+	err = logging.Init(logging.LevelFromString(config.Logging.Level), logEncoding,
 		loggerOpts...,
 	)
 	if err != nil {
@@ -78,8 +167,8 @@ func main() {
 	logger := logging.Logger()
 	logger.Info("loaded configuration")
 
-	// It doesn't make sense to monitor zero interfaces
-	if len(config.Interfaces) == 0 {
+	// It doesn't make sense to monitor zero interfaces (unless nothing is captured at all)
+	if len(config.Interfaces) == 0 && !config.Archive {
 		logger.Fatalf("no interfaces have been specified in the configuration file")
 	}
 
@@ -92,29 +181,117 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
 	defer stop()
 
-	// Create DB directory if it doesn't exist already.
-	// #nosec G301
-	if err := os.MkdirAll(filepath.Clean(config.DB.Path), 0755); err != nil {
-		logger.Fatalf("failed to create database directory: %v", err)
+	// Set up tracing of queries served by the API (if configured)
+	var tracingOpts []tracing.Option
+	if config.Tracing != nil {
+		tracingOpts = append(tracingOpts,
+			tracing.WithEndpoint(config.Tracing.Endpoint),
+			tracing.WithInsecure(config.Tracing.Insecure),
+		)
+		if config.Tracing.SampleRatio > 0 {
+			tracingOpts = append(tracingOpts, tracing.WithSampleRatio(config.Tracing.SampleRatio))
+		}
+	}
+	shutdownTracing, err := tracing.Init(ctx, gpconf.ServiceName, tracingOpts...)
+	if err != nil {
+		logger.Fatalf("failed to set up tracing: %v", err)
 	}
 
-	// Initialize packet logger
-	ifaces := make([]string, len(config.Interfaces))
-	i := 0
-	for k := range config.Interfaces {
-		ifaces[i] = k
-		i++
+	// Push internal metrics to an OpenTelemetry collector (if configured)
+	var metricsExporter *metrics.OTLPExporter
+	if config.Metrics != nil && config.Metrics.OTLP != nil {
+		metricsExporter, err = metrics.NewOTLPExporter(ctx, gpconf.ServiceName,
+			metrics.WithOTLPEndpoint(config.Metrics.OTLP.Endpoint),
+			metrics.WithOTLPInsecure(config.Metrics.OTLP.Insecure),
+		)
+		if err != nil {
+			logger.Fatalf("failed to set up metrics export: %v", err)
+		}
+
+		interval := config.Metrics.OTLP.Interval
+		if interval == 0 {
+			interval = gpconf.DefaultMetricsPushInterval
+		}
+		go metrics.DefaultRegistry.Push(ctx, metricsExporter, time.Duration(interval)*time.Second, func(err error) {
+			logger.Warnf("failed to push metrics: %v", err)
+		})
 	}
 
-	// None of the initialization steps failed.
-	logger.Info("started goProbe")
-	captureManager, err := capture.InitManager(ctx, config)
+	// Create DB directory if it doesn't exist already. In archive mode, an existing DB is served
+	// (read-only), hence it must not be created
+	if config.Archive {
+		if stat, err := os.Stat(filepath.Clean(config.DB.Path)); err != nil || !stat.IsDir() {
+			logger.Fatalf("database directory %s not found", config.DB.Path)
+		}
+	} else {
+		dirPermissions := fs.FileMode(0755)
+		if config.DB.DirPermissions != 0 {
+			dirPermissions = config.DB.DirPermissions
+		}
+		if _, err := os.Stat(filepath.Clean(config.DB.Path)); errors.Is(err, fs.ErrNotExist) {
+			startupFiles = append(startupFiles, config.DB.Path)
+		}
+		// #nosec G301
+		if err := os.MkdirAll(filepath.Clean(config.DB.Path), dirPermissions); err != nil {
+			logger.Fatalf("failed to create database directory: %v", err)
+		}
+	}
+
+	// Determine the identity the captured flows are attributed to
+	var identityCfg gpconf.IdentityConfig
+	if config.Identity != nil {
+		identityCfg = *config.Identity
+	}
+	identity, err := info.DetectIdentity(config.DB.Path, info.Identity{
+		Hostname: identityCfg.Hostname,
+		HostID:   identityCfg.HostID,
+	})
 	if err != nil {
-		logger.Fatal(err)
+		logger.Fatalf("failed to determine host identity: %v", err)
+	}
+	if identityCfg.Store && !config.Archive {
+		if err := info.WriteIdentity(config.DB.Path, identity); err != nil {
+			logger.Fatalf("failed to store host identity in database: %v", err)
+		}
+		startupFiles = append(startupFiles, info.IdentityPath(config.DB.Path))
+	}
+	logger.With("hostname", identity.Hostname, "host_id", identity.HostID).Info("determined host identity")
+
+	// None of the initialization steps failed.
+	logger.With("archive", config.Archive).Info("started goProbe")
+
+	// In archive mode, nothing is captured and only the API serves queries over the existing DB
+	var captureManager *capture.Manager
+	if !config.Archive {
+		captureManager, err = capture.InitManager(ctx, config)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		// Initialize constant monitoring / reloading of the config file. Once privileges are dropped,
+		// interfaces can't be opened anymore, hence the configured ones are fixed
+		if config.RunAs == "" {
+			configMonitor.Start(ctx, captureManager.Update)
+		}
 	}
 
-	// Initialize constant monitoring / reloading of the config file
-	configMonitor.Start(ctx, captureManager.Update)
+	// Now that the capture sockets are open, root privileges are no longer required
+	if config.RunAs != "" {
+		creds, err := privileges.Lookup(config.RunAs)
+		if err != nil {
+			logger.Fatalf("failed to determine user to run as: %v", err)
+		}
+		if err := privileges.Chown(creds, startupFiles...); err != nil {
+			logger.Fatalf("failed to assign startup files to %s: %v", config.RunAs, err)
+		}
+		if err := privileges.Drop(creds); err != nil {
+			logger.Fatalf("failed to drop privileges: %v", err)
+		}
+		if captureManager != nil {
+			captureManager.SetPrivilegesDropped()
+		}
+		logger.With("user", config.RunAs, "uid", creds.UID, "gid", creds.GID).Info("dropped privileges")
+	}
 
 	// configure api server
 	var apiServer *gpserver.Server
@@ -135,13 +312,36 @@ func main() {
 
 			// enable global query rate limit if provided
 			server.WithQueryRateLimit(config.API.QueryRateLimit.MaxReqPerSecond, config.API.QueryRateLimit.MaxBurst),
+
+			// limit the size of decompressed request bodies
+			server.WithMaxRequestBodySize(config.API.MaxRequestBodySize),
+		}
+		// use the socket passed by systemd if the API is socket activated
+		listeners, err := systemd.Listeners()
+		if err != nil && !errors.Is(err, systemd.ErrNoListeners) {
+			logger.Fatalf("failed to retrieve socket activation listeners: %v", err)
+		}
+		if len(listeners) > 0 {
+			logger.With("addr", listeners[0].Addr().String()).Info("using socket activation listener for API server")
+			apiOptions = append(apiOptions, server.WithListener(listeners[0]))
+		}
+
+		if len(config.API.Keys) > 0 {
+			apiOptions = append(apiOptions, server.WithKeys(config.API.Keys...), server.WithKeyQuotas(config.API.KeyQuotas))
+		}
+		if config.API.Export != nil {
+			apiOptions = append(apiOptions, server.WithExport(*config.API.Export))
 		}
-		// if len(config.API.Keys) > 0 {
-		// 	apiOptions = append(apiOptions, api.WithKeys(config.API.Keys))
-		// }
 
 		apiServer = gpserver.New(config.API.Addr, captureManager, configMonitor, apiOptions...)
-		apiServer.SetDBPath(config.DB.Path)
+		apiServer.SetDBPath(config.DB.Path).SetIdentity(identity).SetKeyTenants(config.API.KeyTenants).SetKeyRoles(config.API.KeyRoles).
+			SetQueryMaxBlocksPerSec(config.API.QueryMaxBlocksPerSec).SetQueryWorkers(config.API.QueryWorkers, config.API.QueryMaxWorkers).
+			SetRecentErrors(recentErrors)
+		if config.DB.SnapshotPath != "" {
+			apiServer.SetSnapshotPath(config.DB.SnapshotPath)
+		} else {
+			apiServer.SetSnapshotPath(goDB.DefaultSnapshotPath(config.DB.Path))
+		}
 
 		logger.With("addr", config.API.Addr).Info("starting API server")
 		go func() {
@@ -152,12 +352,33 @@ func main() {
 		}()
 	}
 
+	// signal readiness to systemd (if applicable) and keep its watchdog happy as long as the
+	// capture manager is healthy
+	if _, err := systemd.Notify(systemd.NotifyReady); err != nil {
+		logger.Errorf("failed to notify systemd about readiness: %v", err)
+	}
+	watchdogInterval, err := systemd.WatchdogInterval()
+	if err != nil {
+		logger.Errorf("failed to determine systemd watchdog interval: %v", err)
+	}
+	if watchdogInterval > 0 {
+		logger.With("interval", watchdogInterval).Info("enabling systemd watchdog")
+		var healthFn systemd.HealthCheckFn
+		if captureManager != nil {
+			healthFn = captureManager.CheckHealth
+		}
+		go systemd.RunWatchdog(ctx, watchdogInterval, healthFn)
+	}
+
 	// listen for the interrupt signal
 	<-ctx.Done()
 
 	// restore default behavior on the interrupt signal and notify user of shutdown.
 	stop()
 	logger.Info("shutting down gracefully")
+	if _, err := systemd.Notify(systemd.NotifyStopping); err != nil {
+		logger.Errorf("failed to notify systemd about shutdown: %v", err)
+	}
 
 	// the context is used to inform the server it has ShutdownGracePeriod to wrap up the requests it is
 	// currently handling
@@ -172,6 +393,62 @@ func main() {
 		}
 	}
 
-	captureManager.Close(fallbackCtx)
+	if captureManager != nil {
+		captureManager.Close(fallbackCtx)
+	}
+
+	// flush any pending traces and push the final state of all metrics
+	if err := shutdownTracing(fallbackCtx); err != nil {
+		logger.Errorf("failed to shut down tracing: %v", err)
+	}
+	if metricsExporter != nil {
+		if err := metricsExporter.Export(fallbackCtx, metrics.DefaultRegistry.Start(), metrics.DefaultRegistry.Samples()); err != nil {
+			logger.Errorf("failed to push final metrics: %v", err)
+		}
+		if err := metricsExporter.Shutdown(fallbackCtx); err != nil {
+			logger.Errorf("failed to shut down metrics export: %v", err)
+		}
+	}
 	logger.Info("graceful shut down completed")
 }
+
+// newLogSink creates the writer for the syslog / journald log destinations
+func newLogSink(cfg gpconf.LogConfig) (io.WriteCloser, error) {
+	if cfg.Destination == gplogging.DestinationJournal {
+		return gplogging.NewJournalWriter(gplogging.WithJournalIdentifier(gpconf.ServiceName))
+	}
+
+	opts := []gplogging.SyslogOption{
+		gplogging.WithSyslogAppName(gpconf.ServiceName),
+	}
+	if cfg.Syslog != nil {
+		if cfg.Syslog.Network != "" {
+			opts = append(opts, gplogging.WithSyslogAddress(cfg.Syslog.Network, cfg.Syslog.Address))
+		}
+		if cfg.Syslog.Facility != "" {
+			facility, err := gplogging.ParseFacility(cfg.Syslog.Facility)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, gplogging.WithSyslogFacility(facility))
+		}
+	}
+	return gplogging.NewSyslogWriter(opts...)
+}
+
+// validateConfig parses the configuration file and checks whether it can be applied on this host
+func validateConfig(path string) error {
+	config, err := gpconf.ParseFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to parse configuration: %w", err)
+	}
+
+	diags := config.Check()
+	if len(config.Interfaces) > capture.MaxIfaces {
+		diags = append(diags, gpconf.Diagnostic{
+			Section: "interfaces",
+			Err:     fmt.Errorf("cannot monitor more than %d interfaces", capture.MaxIfaces),
+		})
+	}
+	return diags.Err()
+}
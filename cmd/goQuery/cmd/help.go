@@ -38,9 +38,13 @@ const helpBaseLong = helpBase + `
 
     Labels which can also be printed as columns:
 
+      dir              direction of the flow (in, out or bi), derived from
+                       its packet counters per block
       hostid           unique ID of the host
       hostname         hostname
       iface            interface
+      process          local process / service the flow was attributed to
+                       (requires process attribution to be enabled in goProbe)
       time             timestamp
 
   QUERY_TYPE
@@ -60,12 +64,102 @@ const helpBaseLong = helpBase + `
                       (equivalent to columns "sip,dip,dport,proto")
       raw             a raw dump of all flows, including timestamps and interfaces
                       (equivalent to columns "time,iface,sip,dip,dport,proto")
+      meta            the metadata of the blocks of each day (number of flows,
+                      drops, traffic) without reading any flows
 `
 
 var helpMap = map[string]string{
 	"Ifaces": `Interfaces for which the query should be performed
 (e.g. "eth0 "eth0,t4_33760").
 You can specify "ANY" to query all interfaces.
+
+Interfaces can also be selected by wildcards (e.g. "eth*" or "t4_*") and
+excluded by prefixing them with "!" (e.g. "any,!docker0"). A list consisting
+of exclusions only selects all other interfaces (e.g. "!lo").
+
+Interface groups (see --iface-group) select all their members, which are
+reported under the group's name (e.g. "uplinks" or "any,!uplinks").
+
+Tunnel interfaces can be selected by the peer or site they lead to (as
+provided in /etc/goprobe/tunnels.yaml), using wildcards as well, e.g.
+"site=zurich", "peer=198.51.100.*" or "t4_*,!site=lab*".
+`,
+	"IfaceGroup": `Define an interface group of the form <group>=<iface>[,<iface>...], e.g.
+"uplinks=eth0,eth2". Selecting the group (e.g. "-i uplinks" or "-i uplinks,eth1")
+queries all its members, whose flows are aggregated and reported under the
+group's name. Can be repeated.
+
+Apart from the groups stored in the DB by goProbe, groups can be defined in
+the "query.iface-groups" key of the config file, e.g.
+
+  query:
+    iface-groups:
+      uplinks: [eth0, eth2]
+
+Groups provided on the command line take precedence over the ones of the same
+name in the config file, which in turn take precedence over the ones in the DB.
+An interface must not be selected via more than one group.
+`,
+	"Tenant": `Tenant whose flows are queried. If not set, the flows of the interfaces
+which aren't assigned to a tenant are queried.
+`,
+	"OutputFile": `Write the results to a file instead of the console. The results are written
+to a temporary file first, which replaces the file only once all results were
+written. Hence, other tools (e.g. consuming reports generated by cron) never
+read partially written results.
+`,
+	"Windows": `Query several disjoint time windows in one pass instead of the
+range given by --first / --last (which are ignored). Each window is of the
+form "<first>..<last>" (see help for --first for the supported formats).
+The flag can be repeated, e.g. to cover business hours:
+
+  --window "2024-01-08 08:00..2024-01-08 18:00" \
+  --window "2024-01-09 08:00..2024-01-09 18:00"
+
+Blocks outside of all windows are skipped without being read.
+`,
+	"Filter": `Filter the rows on values which can't be used in a condition, i.e. the
+resolved hostnames, the process labels and the columns attached via --enrich.
+Each filter is of the form "<field>=<pattern>", where the pattern is a glob or
+a regular expression enclosed in slashes. Supported fields are:
+
+  hostname      Hostname of the source or destination IP
+  sip_hostname  Hostname of the source IP
+  dip_hostname  Hostname of the destination IP
+  process       Process label (requires the process column), e.g. "nginx*"
+  <attribute>   Any attribute stored in a label column (requires it to be queried)
+  <column>      Any column of the enrichers, e.g. dport_service or sip_asn
+
+The flag can be repeated (all filters must match), e.g.:
+
+  --enrich asn --filter "dip_asn=/^AS(16509|14618)$/"
+
+The filters are applied before the rows are limited via -n. When filtering on
+hostnames, all rows are resolved (regardless of --dns-resolution.max-rows).
+`,
+	"FilterHostname": `Filter the rows on the hostname of the source or destination IP (shorthand
+for --filter "hostname=<pattern>"), e.g. --filter-hostname "*.amazonaws.com".
+Enables reverse DNS resolution.
+`,
+	"IPVersion": `Restrict the query to IPv4 (4) or IPv6 (6) flows. Flows of the other IP
+version are skipped during aggregation. Since both versions are stored in the same
+blocks, these are still read from disk (the number of blocks read isn't reduced).
+`,
+	"MaxDropPct": `Exclude the 5 minute blocks of an interface whose capture dropped a higher
+percentage of packets, e.g. --max-drop-pct 1 to only include blocks with at most 1%
+drops. Blocks written by goProbe versions not recording the number of received
+packets aren't excluded. The capture quality of the included blocks is reported
+in the summary.
+`,
+	"Verify": `Check that all columns of each queried block hold the same number of entries,
+catching blocks broken by partial writes. Inconsistent blocks are reported as
+corruptions (along with their timestamps) and skipped. Slower, since all columns
+of all blocks are read.
+`,
+	"Anomalies": `Compare the traffic of each interface over (the last day of) the queried range
+with the same period of the preceding seven days. Interfaces whose traffic volume
+deviates from this baseline by a factor of two or more (in either direction) are
+flagged as unusual in the summary. The comparison disregards the condition.
 `,
 	"Help": `Display this help text.
 `,
@@ -202,7 +296,11 @@ ATTRIBUTES:
     snet        Source network in CIDR notation
     net         Source network or destination network
 
+    Both IPv4 and IPv6 networks with arbitrary prefix lengths are supported.
+    A network only matches addresses of its own IP version.
+
     EXAMPLE: "dnet = 192.168.1.0/25 | snet = 172.16.22.0/12"
+             "dnet = 10.20.4.0/22 | dnet = 2001:db8::/29"
              "net = 192.168.1.0/24" is equivalent to
              "(snet = 192.168.1.0/24 | dnet = 192.168.1.0/24)"
              "net != 192.168.1.0/24" is equivalent to
@@ -216,6 +314,26 @@ ATTRIBUTES:
     EXAMPLE: "dport = 22 & proto = TCP" is equivalent to
              "port = 22 & proto = 6"
 
+    ICMP and ICMPv6 flows carry no ports (nor are their types / codes
+    stored), hence conditions combining dport with "proto = ICMP" or
+    "proto = IPv6-ICMP" are rejected
+
+  Direction:
+
+    dir             Direction of the flow, derived from its packet counters:
+                      in   only inbound traffic (received packets)
+                      out  only outbound traffic (sent packets)
+                      uni  unidirectional traffic (in or out)
+                      bi   bidirectional traffic
+
+    EXAMPLE: "dir = uni & dport = 443" selects flows towards port 443
+             that never received an answer (or never sent one)
+             "dir != bi" is equivalent to "dir = uni"
+
+    The direction can also be queried as a column (e.g. "dport,dir"). Since
+    it is derived per block, a flow whose direction changed over time is
+    reported in several rows.
+
 COMPARATIVE OPERATORS:
 
   Base    Description            Other representations
@@ -293,6 +411,27 @@ and ( dport   le 1024 or dport   ge 443 )
   * { dport -leq 1024 || dport -geq 443 }
 
 and any other combination of the allowed representations.
+
+MACROS
+
+Recurring conditions can be defined as named macros in a file, one per
+line (lines starting with "#" are ignored), e.g.:
+
+    internal := snet = 10.0.0.0/8 | snet = 192.168.0.0/16
+    web      := dport = 80 | dport = 443
+
+If the file is provided via --query.macros (or the "query.macros" key
+of the config file), the macros can be referenced via $name:
+
+    $internal & !($web)
+
+A reference is replaced by the bracketed condition of the macro, i.e.
+the above is equivalent to
+
+    (snet = 10.0.0.0/8 | snet = 192.168.0.0/16)
+  & !((dport = 80 | dport = 443))
+
+Macros may reference other macros.
 `,
 
 	"List": `List all interfaces on which data was captured and written
@@ -28,6 +28,10 @@ are printed.
 
 Otherwise, all interface statistics are printed. By default, cumulative
 information is printed (sum of flows, sum of ingress and egress traffic)
+
+For each interface, the range of available data (first / last timestamp), the
+number of days holding data and their size on disk are shown, revealing which
+time ranges can be queried
 `,
 	RunE: listInterfacesEntrypoint,
 }
@@ -63,6 +67,11 @@ func listInterfaces(dbPath string, ifaces ...string) error {
 		return err
 	}
 
+	if err = info.ValidateTenant(queryArgs.Tenant); err != nil {
+		return err
+	}
+	dbPath = info.TenantPath(dbPath, queryArgs.Tenant)
+
 	ifaceDirs, err := info.GetInterfaces(dbPath)
 	if err != nil {
 		return err
@@ -168,6 +177,7 @@ func printInterfaceStats(w io.Writer, ifaceMetadata []*goDB.InterfaceMetadata, d
 
 		// sum across interfaces
 		totalsMetadata.Stats = totalsMetadata.Add(metadata.Stats)
+		totalsMetadata.DiskSize += metadata.DiskSize
 	}
 
 	// empty row for the table. Just reuse the sep slice
@@ -178,12 +188,12 @@ func printInterfaceStats(w io.Writer, ifaceMetadata []*goDB.InterfaceMetadata, d
 
 	// sum row
 	sumRow := totalsMetadata.TableRow(detailed)
-	// iface, from, to make no sense in the totals, so remove them
+	// iface, from, to and days make no sense in the totals, so remove them
 	sumRow[0] = "Total"
 	if detailed {
-		sumRow[8], sumRow[9] = "", ""
+		sumRow[8], sumRow[9], sumRow[10] = "", "", ""
 	} else {
-		sumRow[4], sumRow[5] = "", ""
+		sumRow[4], sumRow[5], sumRow[6] = "", "", ""
 	}
 
 	fmt.Fprintln(tw, strings.Join(sumRow, itemSep)+itemSep)
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/els0r/goProbe/cmd/goQuery/pkg/conf"
+	"github.com/els0r/goProbe/pkg/formatting"
+	"github.com/els0r/goProbe/pkg/goDB"
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/goDB/storage/gpfile"
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/types"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/spf13/viper"
+)
+
+// metaQueryType denotes the query type reading the block metadata of the DB instead of any flows
+const metaQueryType = "meta"
+
+// printDayMetadata reads the per-day block metadata of the queried interfaces (all if none or "any" are
+// provided) and time range, either from the local DB or via the API of a remote goProbe instance
+func printDayMetadata(ctx context.Context, w io.Writer, dbPath string, queryArgs *query.Args) error {
+	var ifaces []string
+	if queryArgs.Ifaces != "" && !strings.EqualFold(queryArgs.Ifaces, "any") {
+		ifaces = strings.Split(queryArgs.Ifaces, ",")
+	}
+
+	var days []goDB.DayMetadata
+	if remoteAddr := viper.GetString(conf.QueryRemoteAddr); remoteAddr != "" {
+		client, err := newRemoteClient(remoteAddr, viper.GetString(conf.QueryRemoteKey), viper.GetDuration(conf.QueryTimeout))
+		if err != nil {
+			return fmt.Errorf("failed to set up remote query: %w", err)
+		}
+		if days, err = client.GetDBMetadata(ctx, queryArgs.First, queryArgs.Last, queryArgs.Tenant, ifaces...); err != nil {
+			return fmt.Errorf("failed to retrieve block metadata: %w", err)
+		}
+	} else {
+		first, last, err := query.ParseTimeRange(queryArgs.First, queryArgs.Last)
+		if err != nil {
+			return err
+		}
+		if err = info.ValidateTenant(queryArgs.Tenant); err != nil {
+			return err
+		}
+		if days, err = goDB.ReadDayMetadata(info.TenantPath(dbPath, queryArgs.Tenant), first, last, ifaces...); err != nil {
+			return fmt.Errorf("failed to read block metadata: %w", err)
+		}
+	}
+
+	if queryArgs.Format == "json" {
+		return jsoniter.NewEncoder(w).Encode(days)
+	}
+	return printDayMetadataTable(w, days)
+}
+
+// printDayMetadataTable prints a row per day, summarizing its blocks within the time range. The traffic
+// is only known per day (irrespective of the time range)
+func printDayMetadataTable(w io.Writer, days []goDB.DayMetadata) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 4, tableSep, tabwriter.AlignRight)
+
+	header := []string{"iface", "day", "blocks", "first block", "last block", "flows", "drops", "day traffic", "on disk"}
+	fmt.Fprintln(tw, strings.Join(header, itemSep)+itemSep)
+	seps := make([]string, len(header))
+	for i, field := range header {
+		seps[i] = strings.Repeat("-", len(field))
+	}
+	fmt.Fprintln(tw, strings.Join(seps, itemSep)+itemSep)
+
+	for _, day := range days {
+		var traffic gpfile.TrafficMetadata
+		for _, block := range day.Blocks {
+			traffic = traffic.Add(block.TrafficMetadata)
+		}
+		fmt.Fprintln(tw, strings.Join([]string{
+			day.Iface,
+			time.Unix(day.Timestamp, 0).Format(time.DateOnly),
+			strconv.Itoa(len(day.Blocks)),
+			day.Blocks[0].Timestamp.Format(types.DefaultTimeOutputFormat),
+			day.Blocks[len(day.Blocks)-1].Timestamp.Format(types.DefaultTimeOutputFormat),
+			formatting.Count(traffic.NumFlows()),
+			formatting.Count(traffic.NumDrops),
+			formatting.Size(day.Counts.SumBytes()),
+			formatting.Size(uint64(day.Size)),
+		}, itemSep)+itemSep)
+	}
+
+	return tw.Flush()
+}
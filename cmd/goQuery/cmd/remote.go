@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/api"
+	"github.com/els0r/goProbe/pkg/api/client"
+	gpclient "github.com/els0r/goProbe/pkg/api/goprobe/client"
+)
+
+var errorEmptyRemoteHost = errors.New("no host provided in remote address")
+
+// newRemoteClient creates a client (implementing query.Runner) sending the query args to the query
+// endpoint of a goProbe (or global-query) API, e.g. https://probe:8145. The results are rendered locally
+func newRemoteClient(remote, key string, timeout time.Duration) (*gpclient.Client, error) {
+	scheme, addr, err := parseRemote(remote)
+	if err != nil {
+		return nil, err
+	}
+	return gpclient.New(addr,
+		client.WithScheme(scheme),
+		client.WithAPIKey(key),
+		client.WithRequestTimeout(timeout),
+	), nil
+}
+
+// parseRemote splits a remote address into the scheme expected by the API client and the
+// host address. If no scheme is provided, http is assumed. unix: addresses are passed through
+func parseRemote(remote string) (scheme, addr string, err error) {
+	if api.ExtractUnixSocket(remote) != "" {
+		return "", remote, nil
+	}
+
+	u, err := url.Parse(remote)
+	if err != nil || u.Host == "" {
+		// attempt to parse as plain host:port
+		u, err = url.Parse("http://" + remote)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid remote address %q: %w", remote, err)
+		}
+	}
+	if u.Host == "" {
+		return "", "", fmt.Errorf("invalid remote address %q: %w", remote, errorEmptyRemoteHost)
+	}
+	switch u.Scheme {
+	case "http", "https":
+	default:
+		return "", "", fmt.Errorf("invalid remote address %q: unsupported scheme %q", remote, u.Scheme)
+	}
+
+	return u.Scheme + "://", u.Host, nil
+}
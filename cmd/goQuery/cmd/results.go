@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/types"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/spf13/cobra"
+)
+
+var resultsCmd = &cobra.Command{
+	Use:   "results",
+	Short: "Combines saved query results",
+	Long: `Combines saved query results
+
+The results are read from JSON files as written by "goQuery -e json -o <file>". Rows
+are matched by their attributes and labels (e.g. time, iface), hence the results
+must stem from queries for the same attributes. The combined result is printed
+like the result of a query, e.g. the hosts seen today but not yesterday via
+
+  goQuery -i eth0 -f -24h -e json -o today.json sip
+  goQuery -i eth0 -f -48h -l -24h -e json -o yesterday.json sip
+  goQuery results subtract today.json yesterday.json
+`,
+}
+
+var (
+	resultsSortBy        string
+	resultsSortAscending bool
+	resultsNumResults    uint64
+	resultsOutputFile    string
+)
+
+func init() {
+	rootCmd.AddCommand(resultsCmd)
+
+	resultsCmd.AddCommand(&cobra.Command{
+		Use:   "merge FILE FILE...",
+		Short: "Merges results, adding up the counters of matching rows",
+		Args:  cobra.MinimumNArgs(2),
+		RunE:  resultsEntrypoint(results.Merge),
+	})
+	resultsCmd.AddCommand(&cobra.Command{
+		Use:   "subtract FILE FILE...",
+		Short: "Shows the rows of the first result which aren't part of any other result",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: resultsEntrypoint(func(res ...*results.Result) (*results.Result, error) {
+			return results.Subtract(res[0], res[1:]...)
+		}),
+	})
+	resultsCmd.AddCommand(&cobra.Command{
+		Use:   "intersect FILE FILE...",
+		Short: "Shows the rows which are part of all results, adding up their counters",
+		Args:  cobra.MinimumNArgs(2),
+		RunE:  resultsEntrypoint(results.Intersect),
+	})
+
+	pflags := resultsCmd.PersistentFlags()
+
+	pflags.StringVarP(&resultsSortBy, "sort-by", "s", "bytes", "Sort the combined rows by given column name (bytes, packets or time)\n")
+	pflags.BoolVarP(&resultsSortAscending, "ascending", "a", false, "Sort the combined rows in ascending instead of descending order\n")
+	pflags.Uint64VarP(&resultsNumResults, "limit", "n", query.MaxResults, "Maximum number of combined rows to show\n")
+	pflags.StringVarP(&resultsOutputFile, "output", "o", "", helpMap["OutputFile"])
+}
+
+func resultsEntrypoint(combine func(res ...*results.Result) (*results.Result, error)) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		res := make([]*results.Result, 0, len(args))
+		for _, path := range args {
+			r, err := readResult(path)
+			if err != nil {
+				return err
+			}
+			res = append(res, r)
+		}
+
+		combined, err := combine(res...)
+		if err != nil {
+			return err
+		}
+		return printCombinedResult(cmd.Context(), combined)
+	}
+}
+
+// readResult reads a result serialized in JSON format from disk
+func readResult(path string) (*results.Result, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read result from %s: %w", path, err)
+	}
+	var res results.Result
+	if err := jsoniter.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON result from %s: %w", path, err)
+	}
+	return &res, nil
+}
+
+// printCombinedResult prints the result as if it was returned by a query for its attributes and labels
+func printCombinedResult(ctx context.Context, res *results.Result) error {
+	args := query.NewArgs(resultQueryType(res), strings.Join(res.Summary.Interfaces, ","),
+		query.WithFormat(cmdLineParams.Format),
+		query.WithSortBy(resultsSortBy),
+		query.WithNumResults(resultsNumResults),
+		query.WithFirst(strconv.FormatInt(res.Summary.First.Unix(), 10)),
+		query.WithLast(strconv.FormatInt(res.Summary.Last.Unix(), 10)),
+		query.WithDistinct(res.Query.Distinct),
+	)
+	args.OutputFile = resultsOutputFile
+	for _, row := range res.Rows {
+		if row.Distribution != nil {
+			args.Distribution = true
+			break
+		}
+	}
+
+	stmt, err := args.Prepare()
+	if err != nil {
+		return fmt.Errorf("failed to prepare printing of result: %w", err)
+	}
+
+	results.By(stmt.SortBy, stmt.Direction, resultsSortAscending || stmt.SortAscending).Sort(res.Rows)
+	if uint64(len(res.Rows)) > stmt.NumResults {
+		res.Rows = res.Rows[:stmt.NumResults]
+	}
+	res.Summary.Hits.Displayed = len(res.Rows)
+
+	return stmt.Print(ctx, res)
+}
+
+// resultQueryType reconstructs the query type of the result from its attributes and the labels
+// present in its rows
+func resultQueryType(res *results.Result) string {
+	var selector types.LabelSelector
+	for _, row := range res.Rows {
+		selector.Timestamp = selector.Timestamp || !row.Labels.Timestamp.IsZero()
+		selector.Iface = selector.Iface || row.Labels.Iface != ""
+		selector.Hostname = selector.Hostname || row.Labels.Hostname != ""
+		selector.HostID = selector.HostID || row.Labels.HostID != ""
+		selector.Process = selector.Process || row.Labels.Process != ""
+		selector.Direction = selector.Direction || row.Labels.Direction != ""
+		for name := range row.Labels.Columns.Map() {
+			if !slices.Contains(selector.Columns, name) {
+				selector.Columns = append(selector.Columns, name)
+			}
+		}
+	}
+	sort.Strings(selector.Columns)
+
+	queryType := append([]string{}, res.Query.Attributes...)
+	for _, label := range []struct {
+		name     string
+		selected bool
+	}{
+		{types.TimeName, selector.Timestamp},
+		{types.IfaceName, selector.Iface},
+		{types.HostnameName, selector.Hostname},
+		{types.HostIDName, selector.HostID},
+		{types.ProcessName, selector.Process},
+		{types.DirectionName, selector.Direction},
+	} {
+		if label.selected {
+			queryType = append(queryType, label.name)
+		}
+	}
+	queryType = append(queryType, selector.Columns...)
+	return strings.Join(queryType, ",")
+}
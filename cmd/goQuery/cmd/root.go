@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -15,7 +16,10 @@ import (
 	"github.com/els0r/goProbe/cmd/goQuery/pkg/conf"
 	"github.com/els0r/goProbe/pkg/api/globalquery/client"
 	"github.com/els0r/goProbe/pkg/defaults"
+	"github.com/els0r/goProbe/pkg/goDB/conditions"
 	"github.com/els0r/goProbe/pkg/goDB/engine"
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/goDB/protocols"
 	"github.com/els0r/goProbe/pkg/query"
 	"github.com/els0r/goProbe/pkg/results"
 	"github.com/els0r/goProbe/pkg/types"
@@ -75,11 +79,14 @@ func Execute() {
 // globally accessible variable for other packages
 var (
 	cmdLineParams = &query.Args{}
-	argsLocation  string // for stored queries
+	argsLocation  string   // for stored queries
+	ifaceGroups   []string // interface group definitions of the form <group>=<iface>[,<iface>...]
+	hostFilters   []string // hostname patterns, shorthand for filters on the hostname field
 )
 
 func init() {
 	cobra.OnInitialize(initConfig)
+	cobra.OnInitialize(initProtocols)
 	cobra.OnInitialize(initLogger)
 
 	// help commands
@@ -95,13 +102,21 @@ func init() {
 	flags.BoolVarP(&cmdLineParams.Version, "version", "v", false, "Print version information and exit\n")
 
 	flags.StringVarP(&cmdLineParams.Ifaces, "ifaces", "i", "", helpMap["Ifaces"])
+	flags.StringArrayVar(&ifaceGroups, "iface-group", nil, helpMap["IfaceGroup"])
 	flags.StringVarP(&cmdLineParams.Condition, "condition", "c", "", helpMap["Condition"])
+	flags.IntVar(&cmdLineParams.IPVersion, "ip-version", 0, helpMap["IPVersion"])
+	flags.Float64Var(&cmdLineParams.MaxDropPct, "max-drop-pct", 0, helpMap["MaxDropPct"])
+	flags.BoolVar(&cmdLineParams.Verify, "verify", false, helpMap["Verify"])
+	flags.BoolVar(&cmdLineParams.Anomalies, "anomalies", false, helpMap["Anomalies"])
 
 	flags.StringVarP(&cmdLineParams.SortBy, conf.SortBy, "s", query.DefaultSortBy,
 		`Sort results by given column name:
   bytes         Sort by accumulated data volume (default)
   packets       Sort by accumulated packets
   time          Sort by time. Enforced for "time" queries
+
+Rows with equal values are ordered by their attributes (ascending), hence
+the order of the results is deterministic
 `,
 	)
 	flags.BoolVarP(&cmdLineParams.SortAscending, conf.SortAscending, "a", false,
@@ -110,6 +125,36 @@ including the "time" field.
 `,
 	)
 
+	flags.BoolVar(&cmdLineParams.Explain, "explain", false,
+		`Show how the query would be executed, i.e. which columns are read from the DB and how
+many daily directories are scanned per interface, instead of running it
+`,
+	)
+	flags.BoolVar(&cmdLineParams.RawUnits, "raw-units", false,
+		`Print data volumes (in bytes) and packet counts as exact integers instead of in
+human-readable form, e.g. for further processing in shell pipelines (txt output only)
+`,
+	)
+	flags.BoolVar(&cmdLineParams.TotalRow, "total-row", false,
+		`Append a row summing up the printed rows (txt output only)
+`,
+	)
+	flags.BoolVar(&cmdLineParams.Subtotals, "subtotals", false,
+		`Group the printed rows by their first column and append a subtotal row to each
+group. Only applies if more than one attribute is queried (txt output only)
+`,
+	)
+	flags.StringSliceVar(&cmdLineParams.Enrich, "enrich", nil,
+		`Enrich the printed rows with additional columns (comma-separated, in order):
+  service       Service name of the destination port (from /etc/services)
+  label         Label of the source / destination IP (requires --enrichment.labels)
+  asn           ASN of the source / destination IP (requires --enrichment.asn)
+  tunnel        Peer / site of the tunnel interface (from /etc/goprobe/tunnels.yaml)
+`,
+	)
+	flags.StringArrayVar(&cmdLineParams.Filter, "filter", nil, helpMap["Filter"])
+	flags.StringArrayVar(&hostFilters, "filter-hostname", nil, helpMap["FilterHostname"])
+
 	flags.Uint64VarP(&cmdLineParams.NumResults, conf.ResultsLimit, "n", query.DefaultNumResults,
 		`Maximum number of final entries to show. Defaults to 95% of the overall
 data volume / number of packets (depending on the '-s' parameter).
@@ -119,7 +164,10 @@ Ignored for queries including the "time" field.
 
 	flags.BoolVarP(&cmdLineParams.DNSResolution.Enabled, conf.DNSResolutionEnabled, "r", false,
 		`Resolve top IPs in output using reverse DNS lookups.
-If the reverse DNS lookup for an IP fails, the IP is shown instead.
+The hostnames are shown in separate columns (sip_host / dip_host)
+next to the IPs, which are kept as is. If the reverse DNS lookup for
+an IP fails, its hostname column is left empty. For JSON output, the
+hostnames are part of each row.
 The lookup is performed for the first '--resolve-rows' rows
 of output.
 Beware: The lookup is carried out at query time; DNS data may have been
@@ -135,6 +183,19 @@ a high load on the DNS resolver and network!
 	flags.DurationVar(&cmdLineParams.DNSResolution.Timeout, conf.DNSResolutionTimeout, query.DefaultResolveTimeout,
 		"Timeout in seconds for (reverse) DNS lookups\n",
 	)
+	flags.StringSliceVar(&cmdLineParams.DNSResolution.Resolvers, conf.DNSResolutionServers, nil,
+		`DNS servers to use for reverse lookups instead of the resolver of the host.
+Lookups are distributed across the servers. Supported are plain DNS
+(e.g. 1.1.1.1), DNS over TLS (e.g. tls://1.1.1.1) and DNS over HTTPS
+(e.g. https://cloudflare-dns.com/dns-query)
+`,
+	)
+	flags.IntVar(&cmdLineParams.DNSResolution.MaxConcurrency, conf.DNSResolutionMaxConcurrency, 0,
+		"Maximum number of reverse DNS lookups performed in parallel (0: unlimited)\n",
+	)
+	flags.DurationVar(&cmdLineParams.DNSResolution.NegativeCacheTTL, conf.DNSResolutionNegativeCacheTTL, 0,
+		"Duration for which IPs without RDNS entry aren't looked up again (0: disabled)\n",
+	)
 
 	flags.IntVar(&cmdLineParams.MaxMemPct, conf.MemoryMaxPct, query.DefaultMaxMemPct,
 		`Maximum amount of memory that can be used for the query
@@ -146,6 +207,45 @@ a high load on the DNS resolver and network!
 and I/O load)
 `,
 	)
+	flags.IntVar(&cmdLineParams.MaxBlocksPerSec, conf.IOMaxBlocksPerSec, 0,
+		`Maximum number of blocks read from disk per second (0: unlimited). Throttles
+background queries so they don't starve the writeouts of goProbe on the same disk
+`,
+	)
+	flags.IntVar(&cmdLineParams.Workers, conf.IOWorkers, 0,
+		`Number of workers reading the blocks of each interface concurrently (0: one per
+CPU). Lower it to keep queries on a capturing host polite, queries against a goProbe
+API are capped by the server (see api.query_max_workers)
+`,
+	)
+	flags.BoolVar(&cmdLineParams.Approx, "approx", false,
+		`Approximate the top results instead of aggregating all flows exactly. Bounds
+the memory used for aggregation if the number of flows explodes (e.g. during
+scans or DDoS attacks), the error bounds of the counters are reported in the
+summary. Requires sorting by bytes or packets in descending order
+`,
+	)
+	flags.StringVar(&cmdLineParams.Distinct, "distinct", "",
+		`Count the distinct values of an attribute per row, e.g. the number of distinct
+destination IPs per source IP via "goQuery -i eth0 --distinct dip sip". The counts are
+estimated (with a standard error of ~3%)
+`,
+	)
+	flags.BoolVar(&cmdLineParams.Distribution, "distribution", false,
+		`Show the time distribution of the bytes of each row across the queried range
+(split into 8 equally sized intervals), e.g. to tell whether a top talker was
+constant or a burst. Printed as a sparkline in txt format. Not supported for
+queries including time
+`,
+	)
+	flags.BoolVar(&cmdLineParams.Dedup, "dedup", false,
+		`Count flows seen identically (same attributes, matching counters) on several of
+the queried interfaces only once, e.g. if both sides of a link are mirrored to
+different capture ports. The removed flows are reported in the summary
+`,
+	)
+	flags.StringVarP(&cmdLineParams.OutputFile, "output", "o", "", helpMap["OutputFile"])
+
 	flags.StringVarP(&cmdLineParams.QueryHosts, conf.QueryHostsResolution, "q", "", "Hosts resolution query\n")
 
 	// persistent flags to be also passed to children commands
@@ -159,15 +259,25 @@ and I/O load)
 `,
 	)
 
+	pflags.StringVar(&cmdLineParams.Tenant, "tenant", "", helpMap["Tenant"])
+
 	// the time parameter should be available to commands other than query
 	pflags.StringVarP(&cmdLineParams.First, conf.First, "f", "", helpMap["First"])
 	pflags.StringVarP(&cmdLineParams.Last, conf.Last, "l", "", "Show flows no later than --last. See help for --first for more info\n")
+	flags.StringArrayVar(&cmdLineParams.Windows, "window", nil, helpMap["Windows"])
 
 	pflags.String(conf.QueryServerAddr, "",
 		`Address of query server to run queries against (host:port). If this value is
 set, goQuery will attempt to run queries using the specified query server as opposed to its local goDB
 `,
 	)
+	pflags.String(conf.QueryRemoteAddr, "",
+		`URL of a goProbe (or global-query) API to run the query against (e.g. https://probe:8145).
+If this value is set, the query is executed remotely and only the results are
+rendered locally, hence no access to the DB files is required
+`,
+	)
+	pflags.String(conf.QueryRemoteKey, "", "API key to authenticate against the remote API\n")
 	pflags.StringP(conf.QueryDBPath, "d", defaults.DBPath,
 		`Path to goDB database directory. By default,
 the database path from the configuration file is used.
@@ -181,6 +291,9 @@ goProbe.
 	pflags.String(conf.StoredQuery, "", "Load JSON serialized query arguments from disk and run them\n")
 	pflags.Duration(conf.QueryTimeout, query.DefaultQueryTimeout, "Abort query processing after timeout expires\n")
 	pflags.String(conf.QueryLog, "", "Log query invocations to file\n")
+	pflags.String(conf.QueryMacros, "", "Load condition macros (referenced via $name) from file. See help for --condition\n")
+	pflags.String(conf.EnrichmentLabels, "", "Load IP labels (one \"<prefix> <label>\" per line) for the \"label\" enricher from file\n")
+	pflags.String(conf.EnrichmentASN, "", "Load ASNs (one \"<prefix> <asn>\" per line) for the \"asn\" enricher from file\n")
 
 	pflags.String(conf.LogLevel, logging.LevelWarn.String(), "log level (debug, info, warn, error, fatal, panic)")
 
@@ -220,6 +333,14 @@ func initConfig() {
 	}
 }
 
+// initProtocols applies the site-specific IP protocol names (if present)
+func initProtocols() {
+	if err := protocols.LoadOverrides(protocols.DefaultOverridesFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load IP protocols: %v\n", err)
+		os.Exit(1)
+	}
+}
+
 // main program entrypoint
 func entrypoint(cmd *cobra.Command, args []string) (err error) {
 	// assign query args
@@ -269,6 +390,37 @@ func entrypoint(cmd *cobra.Command, args []string) (err error) {
 		queryArgs.Query = args[0]
 	}
 
+	// load the condition macros (if any), which are expanded upon preparation of the query
+	if macrosFile := viper.GetString(conf.QueryMacros); macrosFile != "" {
+		macros, err := conditions.LoadMacros(macrosFile)
+		if err != nil {
+			return err
+		}
+		queryArgs.SetConditionMacros(macros)
+	}
+
+	// the interface groups of the config file are overridden by the ones of stored queries, which
+	// in turn are overridden by the ones provided on the command line
+	cmdLineGroups, err := parseIfaceGroups(ifaceGroups)
+	if err != nil {
+		return err
+	}
+	queryArgs.IfaceGroups = info.Groups(viper.GetStringMapStringSlice(conf.QueryIfaceGroups)).
+		With(queryArgs.IfaceGroups).
+		With(cmdLineGroups)
+
+	for _, pattern := range hostFilters {
+		queryArgs.Filter = append(queryArgs.Filter, results.FilterHostname+"="+pattern)
+	}
+
+	// register the enrichers requiring data (if provided)
+	if err := registerPrefixEnricher(results.EnricherLabel, viper.GetString(conf.EnrichmentLabels)); err != nil {
+		return err
+	}
+	if err := registerPrefixEnricher(results.EnricherASN, viper.GetString(conf.EnrichmentASN)); err != nil {
+		return err
+	}
+
 	// make sure there's protection against unbounded time intervals
 	queryArgs = setDefaultTimeRange(&queryArgs)
 
@@ -285,6 +437,11 @@ func entrypoint(cmd *cobra.Command, args []string) (err error) {
 		ctx = queryCtx
 	}
 
+	// block metadata is read without running a query
+	if queryArgs.Query == metaQueryType {
+		return printDayMetadata(ctx, os.Stdout, dbPathCfg, &queryArgs)
+	}
+
 	// get logger
 	logger := logging.FromContext(ctx)
 
@@ -295,7 +452,13 @@ func entrypoint(cmd *cobra.Command, args []string) (err error) {
 
 	// run query against query server if it is specified, otherwise, take the local DB
 	var querier query.Runner
-	if viper.GetString(conf.QueryServerAddr) != "" {
+	if remoteAddr := viper.GetString(conf.QueryRemoteAddr); remoteAddr != "" {
+		// query using the API of a remote goProbe / global-query instance
+		querier, err = newRemoteClient(remoteAddr, viper.GetString(conf.QueryRemoteKey), queryTimeout)
+		if err != nil {
+			return fmt.Errorf("failed to set up remote query: %w", err)
+		}
+	} else if viper.GetString(conf.QueryServerAddr) != "" {
 		if queryArgs.QueryHosts == "" {
 			err := fmt.Errorf("list of target hosts is empty")
 			fmt.Fprintf(os.Stderr, "Distributed query preparation failed: %v\n", err)
@@ -362,25 +525,19 @@ func entrypoint(cmd *cobra.Command, args []string) (err error) {
 		return fmt.Errorf("failed to execute query %s: %w", stmt, err)
 	}
 
-	// serialize raw results array if json is selected
-	if stmt.Format == "json" {
-		err = jsoniter.NewEncoder(stmt.Output).Encode(result)
-		if err != nil {
-			return fmt.Errorf("failed to serialize query results: %w", err)
-		}
-		return nil
-	}
-
-	// when running against a local goDB, there should be exactly one result
-	if result.Status.Code != types.StatusOK {
-		logger, err := logging.New(logging.LevelInfo, logging.EncodingPlain,
-			logging.WithOutput(stmt.Output),
-		)
-		if err != nil {
-			return err
-		}
-		logger.Infof("Status %q: %s", result.Status.Code, result.Status.Message)
-		return nil
+	// when running against a local goDB, there should be exactly one result. Formats
+	// serializing the raw result (e.g. json) print it regardless of its status
+	if result.Status.Code != types.StatusOK && !results.IsRawFormat(stmt.Format) {
+		return stmt.WriteOutput(func(w io.Writer) error {
+			logger, err := logging.New(logging.LevelInfo, logging.EncodingPlain,
+				logging.WithOutput(w),
+			)
+			if err != nil {
+				return err
+			}
+			logger.Infof("Status %q: %s", result.Status.Code, result.Status.Message)
+			return nil
+		})
 	}
 
 	err = stmt.Print(ctx, result)
@@ -413,3 +570,29 @@ func setDefaultTimeRange(args *query.Args) query.Args {
 	}
 	return *args
 }
+
+// parseIfaceGroups parses interface group definitions of the form <group>=<iface>[,<iface>...]
+func parseIfaceGroups(defs []string) (info.Groups, error) {
+	groups := make(info.Groups, len(defs))
+	for _, def := range defs {
+		group, members, found := strings.Cut(def, "=")
+		if !found {
+			return nil, fmt.Errorf("%w: `%s` (expected <group>=<iface>[,<iface>...])", info.ErrInvalidGroup, def)
+		}
+		groups[group] = strings.Split(members, ",")
+	}
+	return groups, nil
+}
+
+// registerPrefixEnricher registers an enricher attaching the values of the prefixes read from file
+// (if provided)
+func registerPrefixEnricher(name, path string) error {
+	if path == "" {
+		return nil
+	}
+	enricher, err := results.LoadPrefixEnricher(name, path)
+	if err != nil {
+		return err
+	}
+	return results.RegisterEnricher(name, enricher)
+}
@@ -9,12 +9,18 @@ const (
 	QueryTimeout         = queryKey + ".timeout"
 	QueryHostsResolution = queryKey + ".hosts-resolution"
 	QueryLog             = queryKey + ".log"
+	QueryMacros          = queryKey + ".macros"
+	QueryIfaceGroups     = queryKey + ".iface-groups"
 
 	dbKey       = "db"
 	QueryDBPath = dbKey + ".path"
 
 	StoredQuery = "stored-query"
 
+	// Remote query execution
+	QueryRemoteAddr = "remote"
+	QueryRemoteKey  = "remote-key"
+
 	// logging
 	loggingKey = "logging"
 	LogLevel   = loggingKey + ".level"
@@ -25,6 +31,15 @@ const (
 	DNSResolutionMaxRows = dnsKey + ".max-rows"
 	DNSResolutionTimeout = dnsKey + ".timeout"
 
+	DNSResolutionServers          = dnsKey + ".servers"
+	DNSResolutionMaxConcurrency   = dnsKey + ".max-concurrency"
+	DNSResolutionNegativeCacheTTL = dnsKey + ".negative-cache-ttl"
+
+	// Enrichment
+	enrichmentKey    = "enrichment"
+	EnrichmentLabels = enrichmentKey + ".labels"
+	EnrichmentASN    = enrichmentKey + ".asn"
+
 	// Sorting
 	sortKey       = "sort"
 	SortBy        = sortKey + ".by"
@@ -40,6 +55,11 @@ const (
 	MemoryMaxPct  = memoryKey + ".max-pct"
 	MemoryLowMode = memoryKey + ".low-mode"
 
+	// I/O
+	ioKey             = "io"
+	IOMaxBlocksPerSec = ioKey + ".max-blocks-per-sec"
+	IOWorkers         = ioKey + ".workers"
+
 	// Time
 	First = "first"
 	Last  = "last"
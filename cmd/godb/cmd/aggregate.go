@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/els0r/goProbe/cmd/godb/pkg/conf"
+	"github.com/els0r/goProbe/pkg/aggregator"
+	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/els0r/telemetry/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var aggregateCmd = &cobra.Command{
+	Use:   "aggregate",
+	Short: "Receive flows from goProbe hosts and write them to the DB",
+	Long: `Receive flows from goProbe hosts and write them to the DB
+
+Runs an aggregator accepting the flows streamed by goProbe hosts upon each
+writeout (see the "aggregator" section of the goProbe configuration). The
+flows of each host are written to a dedicated subtree of the DB named after
+its hostname (e.g. /usr/local/goProbe/db/sensor-01), which can be queried
+like the DB of the host itself:
+
+  goQuery -d /usr/local/goProbe/db/sensor-01 -i eth0 sip,dip
+
+Listens on localhost unless told otherwise. Outside of trusted networks,
+connections should be secured by TLS (optionally requiring client certificates
+signed by --tls-client-ca) and hosts be required to present a shared token.
+
+Runs until interrupted.
+`,
+	Args: cobra.NoArgs,
+	RunE: aggregateEntrypoint,
+}
+
+var (
+	aggregateListen  string
+	aggregateEncoder string
+
+	aggregateTLS       aggregator.TLSConfig
+	aggregateTokenFile string
+)
+
+func init() {
+	rootCmd.AddCommand(aggregateCmd)
+
+	flags := aggregateCmd.Flags()
+	flags.StringVarP(&aggregateListen, "listen", "l", "localhost:8147", "address to accept connections from goProbe hosts on")
+	flags.StringVarP(&aggregateEncoder, "encoder", "e", "lz4", "encoder the flows are stored with")
+	flags.StringVar(&aggregateTLS.CertFile, "tls-cert", "", "certificate to secure connections by TLS with")
+	flags.StringVar(&aggregateTLS.KeyFile, "tls-key", "", "private key of the TLS certificate")
+	flags.StringVar(&aggregateTLS.CAFile, "tls-client-ca", "", "CA client certificates must be signed by (enforces mutual TLS)")
+	flags.StringVar(&aggregateTokenFile, "token-file", "", "file storing the token goProbe hosts must present")
+}
+
+func aggregateEntrypoint(_ *cobra.Command, _ []string) error {
+	encoderType, err := encoders.GetTypeByString(aggregateEncoder)
+	if err != nil {
+		return fmt.Errorf("failed to get encoder type from %s: %w", aggregateEncoder, err)
+	}
+
+	var opts []aggregator.ServerOption
+	if aggregateTLS != (aggregator.TLSConfig{}) {
+		tlsConfig, err := aggregateTLS.ServerConfig()
+		if err != nil {
+			return fmt.Errorf("failed to set up TLS: %w", err)
+		}
+		opts = append(opts, aggregator.WithServerTLS(tlsConfig))
+	}
+	if aggregateTokenFile != "" {
+		token, err := os.ReadFile(aggregateTokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read token: %w", err)
+		}
+		if token = bytes.TrimSpace(token); len(token) == 0 {
+			return fmt.Errorf("token file %s is empty", aggregateTokenFile)
+		}
+		opts = append(opts, aggregator.WithServerToken(string(token)))
+	}
+
+	listener, err := net.Listen("tcp", aggregateListen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", aggregateListen, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := aggregator.NewServer(viper.GetString(conf.DBPath), encoderType, opts...)
+	go func() {
+		<-ctx.Done()
+		server.Shutdown()
+	}()
+
+	logging.FromContext(ctx).With("addr", listener.Addr().String()).Warn("aggregator accepting flows")
+	return server.Serve(listener)
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/els0r/goProbe/pkg/goDB/engine/bench"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/spf13/cobra"
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Benchmark the query engine against a generated reference DB",
+	Long: `Benchmark the query engine against a generated reference DB
+
+Generates a reproducible reference DB (using the given encoder) and runs a fixed
+set of query scenarios covering attribute combinations and conditions against it.
+The mean latency of each scenario is reported and its results are verified against
+the golden results, so that both performance and correctness regressions in the
+storage or engine layers surface.
+
+The reference DB is written to a temporary directory (removed afterwards) unless
+--path is provided. Note that the DB used by other commands (--db.path) is not
+touched.
+`,
+	RunE: benchEntrypoint,
+}
+
+var (
+	benchEncoder string
+	benchPath    string
+	benchRepeat  int
+	benchJSON    bool
+)
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+
+	flags := benchCmd.Flags()
+	flags.StringVar(&benchEncoder, "encoder", encoders.EncoderTypeLZ4.String(), "encoder used to store the reference DB")
+	flags.StringVar(&benchPath, "path", "", "directory to write the reference DB to (kept after the run)")
+	flags.IntVarP(&benchRepeat, "repeat", "n", 5, "number of times each scenario is run")
+	flags.BoolVar(&benchJSON, "json", false, "print report in JSON format")
+}
+
+type benchScenarioReport struct {
+	Scenario string `json:"scenario"`
+	Mean     int64  `json:"mean_ns"`
+	Hits     int    `json:"hits"`
+	Error    string `json:"error,omitempty"`
+}
+
+func benchEntrypoint(_ *cobra.Command, _ []string) error {
+	if benchRepeat < 1 {
+		return errors.New("repeat must be at least 1")
+	}
+	encoderType, err := encoders.GetTypeByString(benchEncoder)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	path := benchPath
+	if path == "" {
+		if path, err = os.MkdirTemp("", "godb_bench"); err != nil {
+			return fmt.Errorf("failed to create reference DB directory: %w", err)
+		}
+		defer os.RemoveAll(path)
+	}
+	if err := bench.DefaultReference.Generate(path, encoderType); err != nil {
+		return fmt.Errorf("failed to generate reference DB: %w", err)
+	}
+
+	golden, err := bench.DefaultGolden()
+	if err != nil {
+		return err
+	}
+
+	var (
+		reports    []benchScenarioReport
+		mismatches int
+	)
+	for _, scenario := range bench.Scenarios() {
+		report := benchScenarioReport{
+			Scenario: scenario.Name(),
+		}
+
+		var total time.Duration
+		for i := 0; i < benchRepeat; i++ {
+			start := time.Now()
+			res, err := scenario.Run(ctx, bench.DefaultReference, path)
+			if err != nil {
+				return err
+			}
+			total += time.Since(start)
+
+			// the results are only verified once, the remaining runs only measure the latency
+			if i > 0 {
+				continue
+			}
+			report.Hits = len(res.Rows)
+
+			digest, err := bench.NewDigest(res)
+			if err != nil {
+				return err
+			}
+			if err := golden.Verify(scenario, digest); err != nil {
+				report.Error = err.Error()
+				mismatches++
+			}
+		}
+		report.Mean = (total / time.Duration(benchRepeat)).Nanoseconds()
+
+		reports = append(reports, report)
+	}
+
+	if benchJSON {
+		err = jsoniter.NewEncoder(os.Stdout).Encode(reports)
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, tableSep, tabwriter.AlignRight)
+		fmt.Fprintln(tw, "scenario\tmean\thits\tresults\t")
+		for _, report := range reports {
+			status := "ok"
+			if report.Error != "" {
+				status = "MISMATCH"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t\n", report.Scenario, time.Duration(report.Mean).Round(time.Microsecond), report.Hits, status)
+		}
+		err = tw.Flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	if mismatches > 0 {
+		return fmt.Errorf("%w in %d of %d scenarios", bench.ErrGoldenMismatch, mismatches, len(reports))
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/els0r/goProbe/cmd/godb/pkg/conf"
+	"github.com/els0r/goProbe/pkg/formatting"
+	"github.com/els0r/goProbe/pkg/goDB"
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/types"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var duCmd = &cobra.Command{
+	Use:   "du [IFACES]",
+	Short: "Report disk usage of the DB",
+	Long: `Report disk usage of the DB
+
+If the (list of) interface(s) is provided as an argument, only their
+disk usage is reported. Otherwise, all interfaces in the DB are covered.
+Interfaces assigned to a tenant are listed as @<tenant>/<iface>. By default,
+the usage is summed up per interface
+`,
+	RunE: duEntrypoint,
+}
+
+var (
+	duPerDay bool
+	duJSON   bool
+)
+
+func init() {
+	rootCmd.AddCommand(duCmd)
+
+	flags := duCmd.Flags()
+	flags.BoolVarP(&duPerDay, "per-day", "v", false, "report disk usage for each day directory")
+	flags.BoolVar(&duJSON, "json", false, "print disk usage in JSON format")
+}
+
+const (
+	tableSep = ' '
+	itemSep  = "\t"
+)
+
+func duEntrypoint(_ *cobra.Command, args []string) error {
+	usages, err := goDB.DiskUsage(viper.GetString(conf.DBPath), args...)
+	if err != nil {
+		return fmt.Errorf("failed to determine disk usage: %w", err)
+	}
+
+	if !duPerDay {
+		usages = sumPerIface(usages)
+	}
+
+	if duJSON {
+		return jsoniter.NewEncoder(os.Stdout).Encode(usages)
+	}
+
+	return printUsages(usages, duPerDay)
+}
+
+// sumPerIface accumulates the day directories of each interface. The timestamp is set to
+// the first day covered
+func sumPerIface(usages goDB.DayUsages) goDB.DayUsages {
+	var perIface goDB.DayUsages
+	for _, usage := range usages {
+		if len(perIface) == 0 || perIface[len(perIface)-1].Iface != usage.Iface || perIface[len(perIface)-1].Tenant != usage.Tenant {
+			usage.Path = ""
+			perIface = append(perIface, usage)
+			continue
+		}
+		perIface[len(perIface)-1].Size += usage.Size
+		perIface[len(perIface)-1].NumFiles += usage.NumFiles
+	}
+	return perIface
+}
+
+func printUsages(usages goDB.DayUsages, perDay bool) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 4, tableSep, tabwriter.AlignRight)
+
+	header := "iface" + itemSep + "since" + itemSep
+	if perDay {
+		header = "iface" + itemSep + "day" + itemSep
+	}
+	fmt.Fprintln(tw, header+"files"+itemSep+"size"+itemSep)
+	for _, usage := range usages {
+		fmt.Fprintln(tw, ifaceName(usage.Iface, usage.Tenant)+itemSep+
+			time.Unix(usage.Timestamp, 0).Format(types.DefaultTimeOutputFormat)+itemSep+
+			strconv.Itoa(usage.NumFiles)+itemSep+
+			formatting.Size(uint64(usage.Size))+itemSep)
+	}
+	fmt.Fprintln(tw, itemSep+itemSep+itemSep+itemSep)
+	fmt.Fprintln(tw, "Total"+itemSep+itemSep+itemSep+formatting.Size(uint64(usages.Size()))+itemSep)
+
+	return tw.Flush()
+}
+
+// ifaceName denotes an interface along with the tenant it belongs to (if any), following the layout
+// of the DB
+func ifaceName(iface, tenant string) string {
+	if tenant == "" {
+		return iface
+	}
+	return info.TenantDirPrefix + tenant + "/" + iface
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/els0r/goProbe/cmd/godb/pkg/conf"
+	"github.com/els0r/goProbe/pkg/formatting"
+	"github.com/els0r/goProbe/pkg/goDB"
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var expireCmd = &cobra.Command{
+	Use:   "expire --before <time> [IFACES]",
+	Short: "Delete data older than a cutoff",
+	Long: `Delete data older than a cutoff
+
+Removes all day directories which exclusively hold data older than the time
+provided via --before. The time can be absolute or relative (e.g. "-30d").
+Since goDB stores data in directories per day, the cutoff is effectively
+rounded down to the start of the day it falls into.
+
+If the (list of) interface(s) is provided as an argument, only their data
+is removed. Otherwise, all interfaces in the DB (including those assigned to
+a tenant) are covered.
+`,
+	RunE: expireEntrypoint,
+}
+
+var (
+	expireBefore string
+	expireDryRun bool
+)
+
+func init() {
+	rootCmd.AddCommand(expireCmd)
+
+	flags := expireCmd.Flags()
+	flags.StringVar(&expireBefore, "before", "", "delete data older than this time (e.g. \"-30d\" or \"2023-01-01 00:00\")")
+	flags.BoolVar(&expireDryRun, "dry-run", false, "only print the directories that would be removed")
+}
+
+func expireEntrypoint(_ *cobra.Command, args []string) error {
+	if expireBefore == "" {
+		return errors.New("no cutoff provided, use --before")
+	}
+	cutoff, err := query.ParseTimeArgument(expireBefore)
+	if err != nil {
+		return fmt.Errorf("failed to parse cutoff: %w", err)
+	}
+
+	expired, err := goDB.Expire(viper.GetString(conf.DBPath), cutoff, expireDryRun, args...)
+	if err != nil {
+		return fmt.Errorf("failed to expire data: %w", err)
+	}
+
+	action := "Removed"
+	if expireDryRun {
+		action = "Would remove"
+	}
+	for _, usage := range expired {
+		fmt.Fprintf(os.Stdout, "%s %s (%s, %s)\n", action, usage.Path, ifaceName(usage.Iface, usage.Tenant),
+			time.Unix(usage.Timestamp, 0).Format(types.DefaultTimeOutputFormat),
+		)
+	}
+	fmt.Fprintf(os.Stdout, "%s %d directories older than %s (%s)\n", action, len(expired),
+		time.Unix(cutoff, 0).Format(types.DefaultTimeOutputFormat), formatting.Size(uint64(expired.Size())),
+	)
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/els0r/goProbe/cmd/godb/pkg/conf"
+	"github.com/els0r/goProbe/pkg/formatting"
+	"github.com/els0r/goProbe/pkg/goDB"
+	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var packCmd = &cobra.Command{
+	Use:   "pack [--since <time>] [--until <time>] ARCHIVE",
+	Short: "Pack the blocks of a time range into a single archive",
+	Long: `Pack the blocks of a time range into a single archive
+
+Writes all blocks of the DB within the time range provided via --since and
+--until (absolute or relative, e.g. "-7d") to a single, compressed archive,
+which can be added to another DB via "godb unpack". Each block is checksummed,
+as is the archive as a whole, hence it can be shipped between sites safely.
+
+Unless restricted via --iface, all interfaces in the DB are covered (including
+those in the subtrees of tenants, which are unpacked into the subtrees of the
+same tenants). Use "-"
+to write the archive to stdout, e.g. to ship it via ssh:
+
+  godb pack --since -1d - | ssh archive godb unpack -
+`,
+	Args: cobra.ExactArgs(1),
+	RunE: packEntrypoint,
+}
+
+var (
+	packIfaces  []string
+	packSince   string
+	packUntil   string
+	packEncoder string
+)
+
+func init() {
+	rootCmd.AddCommand(packCmd)
+
+	flags := packCmd.Flags()
+	flags.StringSliceVar(&packIfaces, "iface", nil, "interface(s) to pack (comma-separated)")
+	flags.StringVar(&packSince, "since", "", "only pack data since this time (e.g. \"-7d\" or \"2023-01-01 00:00\")")
+	flags.StringVar(&packUntil, "until", "", "only pack data until this time (e.g. \"-1d\" or \"2023-01-31 00:00\")")
+	flags.StringVarP(&packEncoder, "encoder", "e", "zstd", "encoder the blocks are compressed with")
+}
+
+func packEntrypoint(_ *cobra.Command, args []string) (err error) {
+	encoderType, err := encoders.GetTypeByString(packEncoder)
+	if err != nil {
+		return fmt.Errorf("failed to get encoder type from %s: %w", packEncoder, err)
+	}
+
+	tfirst, tlast := int64(0), types.MaxTime.Unix()
+	if packSince != "" {
+		if tfirst, err = query.ParseTimeArgument(packSince); err != nil {
+			return fmt.Errorf("failed to parse start time: %w", err)
+		}
+	}
+	if packUntil != "" {
+		if tlast, err = query.ParseTimeArgument(packUntil); err != nil {
+			return fmt.Errorf("failed to parse end time: %w", err)
+		}
+	}
+	if tfirst > tlast {
+		return errors.New("start time is after end time")
+	}
+
+	// the archive is only put in place once it was written completely
+	var w io.Writer = os.Stdout
+	if path := args[0]; path != "-" {
+		f, err := os.CreateTemp(filepath.Dir(path), ".tmp-"+filepath.Base(path))
+		if err != nil {
+			return fmt.Errorf("failed to create archive: %w", err)
+		}
+		defer func() {
+			if cerr := f.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+			if err == nil {
+				err = os.Rename(f.Name(), path)
+			}
+			if err != nil {
+				_ = os.Remove(f.Name())
+			}
+		}()
+		w = f
+	}
+
+	info, err := goDB.Pack(viper.GetString(conf.DBPath), w, tfirst, tlast, encoderType, packIfaces...)
+	if err != nil {
+		return fmt.Errorf("failed to pack data: %w", err)
+	}
+	printPackInfo("Packed", info)
+
+	return nil
+}
+
+// printPackInfo summarizes a pack on stderr (since the pack itself may be written to stdout)
+func printPackInfo(action string, info goDB.PackInfo) {
+	if info.NumBlocks == 0 {
+		fmt.Fprintf(os.Stderr, "%s 0 blocks\n", action)
+		return
+	}
+	var tenants string
+	if len(info.Tenants) > 0 {
+		tenants = fmt.Sprintf(" (%d tenant(s))", len(info.Tenants))
+	}
+	fmt.Fprintf(os.Stderr, "%s %d blocks of %d interface(s)%s between %s and %s (%s)\n", action,
+		info.NumBlocks-info.NumSkipped, len(info.Ifaces), tenants,
+		time.Unix(info.First, 0).Format(types.DefaultTimeOutputFormat),
+		time.Unix(info.Last, 0).Format(types.DefaultTimeOutputFormat),
+		formatting.Size(uint64(info.Size)),
+	)
+	if info.NumSkipped > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped %d blocks already present in the DB\n", info.NumSkipped)
+	}
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/els0r/goProbe/cmd/godb/pkg/conf"
+	"github.com/els0r/goProbe/pkg/goDB/engine"
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/query/replay"
+	"github.com/els0r/telemetry/logging"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay AUDIT_LOG",
+	Short: "Replay queries from a query audit log and report latencies",
+	Long: `Replay queries from a query audit log and report latencies
+
+Reads the queries recorded in a JSON query audit log (e.g. written by goQuery
+via --query.log or by the goProbe / global-query API) and runs them against the
+DB. Results are discarded and only the latency percentiles are reported, which
+makes it possible to compare storage or hardware changes using real-world queries.
+
+Note that relative time arguments (e.g. "-24h") are evaluated at the time of the
+replay. DNS resolution is disabled for all queries so that only the DB access
+is measured.
+`,
+	Args: cobra.ExactArgs(1),
+	RunE: replayEntrypoint,
+}
+
+var (
+	replayConcurrency int
+	replayRepeat      int
+	replayJSON        bool
+)
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	flags := replayCmd.Flags()
+	flags.IntVarP(&replayConcurrency, "concurrency", "c", 1, "number of queries to run in parallel")
+	flags.IntVarP(&replayRepeat, "repeat", "n", 1, "number of times the queries are replayed")
+	flags.BoolVar(&replayJSON, "json", false, "print report in JSON format")
+}
+
+var replayPercentiles = []float64{50, 90, 95, 99, 100}
+
+type replayReport struct {
+	*replay.Report
+
+	Concurrency int              `json:"concurrency"`
+	Mean        int64            `json:"mean_ns"`
+	QPS         float64          `json:"qps"`
+	Percentiles map[string]int64 `json:"percentiles_ns"`
+}
+
+func replayEntrypoint(_ *cobra.Command, args []string) error {
+	if replayConcurrency < 1 {
+		return errors.New("concurrency must be at least 1")
+	}
+	if replayRepeat < 1 {
+		return errors.New("repeat must be at least 1")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger := logging.FromContext(ctx)
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	queries, err := replay.ReadAuditLog(f)
+	if err != nil {
+		return err
+	}
+	for _, q := range queries {
+		q.DNSResolution.Enabled = false
+	}
+
+	report, err := replay.New(engine.NewQueryRunner(viper.GetString(conf.DBPath)),
+		replay.WithConcurrency(replayConcurrency),
+		replay.WithRepeat(replayRepeat),
+		replay.WithErrorFn(func(args *query.Args, err error) {
+			logger.With("args", args).Errorf("query failed: %v", err)
+		}),
+	).Run(ctx, queries)
+	if err != nil {
+		return fmt.Errorf("failed to replay queries: %w", err)
+	}
+
+	if replayJSON {
+		out := replayReport{
+			Report:      report,
+			Concurrency: replayConcurrency,
+			Mean:        report.Mean().Nanoseconds(),
+			QPS:         report.QueriesPerSecond(),
+			Percentiles: make(map[string]int64, len(replayPercentiles)),
+		}
+		for _, p := range replayPercentiles {
+			out.Percentiles[percentileLabel(p)] = report.Percentile(p).Nanoseconds()
+		}
+		return jsoniter.NewEncoder(os.Stdout).Encode(out)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, tableSep, tabwriter.AlignRight)
+	fmt.Fprintf(tw, "Queries\t%d\t\n", report.NumQueries)
+	fmt.Fprintf(tw, "Errors\t%d\t\n", report.NumErrors)
+	fmt.Fprintf(tw, "Concurrency\t%d\t\n", replayConcurrency)
+	fmt.Fprintf(tw, "Duration\t%s\t\n", report.Duration.Round(time.Millisecond))
+	fmt.Fprintf(tw, "Throughput\t%.2f qps\t\n", report.QueriesPerSecond())
+	fmt.Fprintf(tw, "Mean\t%s\t\n", report.Mean())
+	for _, p := range replayPercentiles {
+		fmt.Fprintf(tw, "%s\t%s\t\n", percentileLabel(p), report.Percentile(p))
+	}
+	return tw.Flush()
+}
+
+func percentileLabel(p float64) string {
+	if p >= 100 {
+		return "max"
+	}
+	return fmt.Sprintf("p%v", p)
+}
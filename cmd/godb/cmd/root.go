@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/els0r/goProbe/cmd/godb/pkg/conf"
+	"github.com/els0r/goProbe/pkg/defaults"
+	"github.com/els0r/goProbe/pkg/version"
+	"github.com/els0r/telemetry/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var cfgFile string
+
+// rootCmd represents the base command when called without any subcommands
+var rootCmd = &cobra.Command{
+	Use:   "godb",
+	Short: "goDB maintenance CLI tool",
+	Long: `godb goDB maintenance CLI tool
+
+Operates directly on the DB tree and hence doesn't require a running
+goProbe instance (e.g. on archive servers)
+`,
+	RunE:          rootEntrypoint,
+	SilenceErrors: true,
+}
+
+// Execute is the main entrypoint and runs the CLI tool
+func Execute() {
+	err := rootCmd.Execute()
+	if err != nil {
+		logger, logErr := logging.New(logging.LevelError, logging.EncodingPlain,
+			logging.WithOutput(os.Stderr),
+		)
+		if logErr != nil {
+			fmt.Fprintf(os.Stderr, "Failed to instantiate CLI logger: %v\n", logErr)
+			fmt.Fprintf(os.Stderr, "Error running command: %s\n", err)
+			os.Exit(1)
+		}
+		logger.Fatalf("Error running command: %s", err)
+	}
+}
+
+func init() {
+	cobra.OnInitialize(initConfig)
+	cobra.OnInitialize(initLogger)
+
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file location")
+
+	rootCmd.PersistentFlags().StringP(conf.DBPath, "d", defaults.DBPath, "path to goDB database directory")
+
+	_ = viper.BindPFlags(rootCmd.PersistentFlags())
+}
+
+func initLogger() {
+	// since this is a command line tool, only warnings and errors should be printed and they
+	// shouldn't go to a dedicated file
+	err := logging.Init(logging.LevelWarn, logging.EncodingLogfmt,
+		logging.WithVersion(version.Short()),
+		logging.WithOutput(os.Stdout),
+		logging.WithErrorOutput(os.Stderr),
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// initConfig reads in config file and ENV variables if set
+func initConfig() {
+	if cfgFile != "" {
+		// Use config file from the flag.
+		viper.SetConfigFile(cfgFile)
+
+		viper.AutomaticEnv() // read in environment variables that match
+
+		// If a config file is found, read it in.
+		if err := viper.ReadInConfig(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read in config: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func rootEntrypoint(_ *cobra.Command, _ []string) error {
+	return fmt.Errorf("no sub-command provided")
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/els0r/goProbe/cmd/godb/pkg/conf"
+	"github.com/els0r/goProbe/pkg/formatting"
+	"github.com/els0r/goProbe/pkg/goDB"
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/types"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Report column-level statistics of the DB",
+	Long: `Report column-level statistics of the DB
+
+Reports the number of flows, average block sizes per column, the estimated
+number of distinct source / destination IPs and ports as well as the top
+protocols per interface. Flow counts and block sizes are taken from the
+block headers, while cardinalities and protocols are estimated from every
+n-th block (see --sample). This helps to design conditions (e.g. to judge
+how selective a filter on an attribute is) and to plan capacity.
+
+Unless restricted via --iface, all interfaces in the DB are covered.
+`,
+	RunE: statsEntrypoint,
+}
+
+const defaultStatsTopProtocols = 5
+
+var (
+	statsIfaces       []string
+	statsSince        string
+	statsSampleEvery  int
+	statsTopProtocols int
+	statsJSON         bool
+)
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+
+	flags := statsCmd.Flags()
+	flags.StringSliceVar(&statsIfaces, "iface", nil, "interface(s) to report statistics for (comma-separated)")
+	flags.StringVar(&statsSince, "since", "", "only cover data since this time (e.g. \"-7d\" or \"2023-01-01 00:00\")")
+	flags.IntVar(&statsSampleEvery, "sample", 10, "read every n-th block to estimate cardinalities (1 reads all blocks)")
+	flags.IntVar(&statsTopProtocols, "top", defaultStatsTopProtocols, "number of protocols to report (0 reports all)")
+	flags.BoolVar(&statsJSON, "json", false, "print statistics in JSON format")
+}
+
+func statsEntrypoint(_ *cobra.Command, _ []string) error {
+	var since int64
+	if statsSince != "" {
+		var err error
+		if since, err = query.ParseTimeArgument(statsSince); err != nil {
+			return fmt.Errorf("failed to parse start time: %w", err)
+		}
+	}
+
+	allStats, err := goDB.ComputeColumnStats(viper.GetString(conf.DBPath), since, statsSampleEvery, statsIfaces...)
+	if err != nil {
+		return fmt.Errorf("failed to compute column statistics: %w", err)
+	}
+
+	if statsTopProtocols > 0 {
+		for _, stats := range allStats {
+			if len(stats.Protocols) > statsTopProtocols {
+				stats.Protocols = stats.Protocols[:statsTopProtocols]
+			}
+		}
+	}
+
+	if statsJSON {
+		return jsoniter.NewEncoder(os.Stdout).Encode(allStats)
+	}
+
+	for i, stats := range allStats {
+		if i > 0 {
+			fmt.Fprintln(os.Stdout)
+		}
+		if err := printColumnStats(stats); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printColumnStats(stats *goDB.ColumnStats) error {
+	fmt.Fprintf(os.Stdout, "Interface %s: %s to %s (%d days, %d blocks, %d sampled)\n\n", ifaceName(stats.Iface, stats.Tenant),
+		time.Unix(stats.First, 0).Format(types.DefaultTimeOutputFormat),
+		time.Unix(stats.Last, 0).Format(types.DefaultTimeOutputFormat),
+		stats.NumDays, stats.NumBlocks, stats.NumBlocksSampled,
+	)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 4, tableSep, tabwriter.AlignRight)
+
+	fmt.Fprintln(tw, "flows"+itemSep+"ipv4"+itemSep+"ipv6"+itemSep+"drops"+itemSep+"flows / block"+itemSep)
+	fmt.Fprintln(tw, formatting.Count(stats.Traffic.NumFlows())+itemSep+
+		formatting.Count(stats.Traffic.NumV4Entries)+itemSep+
+		formatting.Count(stats.Traffic.NumV6Entries)+itemSep+
+		formatting.Count(stats.Traffic.NumDrops)+itemSep+
+		strconv.FormatFloat(stats.AvgFlowsPerBlock(), 'f', 1, 64)+itemSep)
+	fmt.Fprintln(tw, itemSep+itemSep+itemSep+itemSep+itemSep)
+
+	fmt.Fprintln(tw, "distinct"+itemSep+"sip"+itemSep+"dip"+itemSep+"dport"+itemSep+"proto"+itemSep)
+	fmt.Fprintln(tw, "(estimate)"+itemSep+
+		formatting.Count(stats.Distinct.SIP)+itemSep+
+		formatting.Count(stats.Distinct.DIP)+itemSep+
+		formatting.Count(stats.Distinct.Dport)+itemSep+
+		formatting.Count(stats.Distinct.Proto)+itemSep)
+	fmt.Fprintln(tw, itemSep+itemSep+itemSep+itemSep+itemSep)
+
+	fmt.Fprintln(tw, "proto"+itemSep+"flows"+itemSep+"bytes"+itemSep+itemSep+itemSep)
+	for _, proto := range stats.Protocols {
+		fmt.Fprintln(tw, proto.Proto+itemSep+
+			formatting.Count(proto.Flows)+itemSep+
+			formatting.Size(proto.Bytes)+itemSep+itemSep+itemSep)
+	}
+	fmt.Fprintln(tw, itemSep+itemSep+itemSep+itemSep+itemSep)
+
+	fmt.Fprintln(tw, "column"+itemSep+"avg block size"+itemSep+"avg raw size"+itemSep+itemSep+itemSep)
+	for _, col := range stats.Columns {
+		fmt.Fprintln(tw, col.Column+itemSep+
+			formatting.Size(uint64(col.AvgSize))+itemSep+
+			formatting.Size(uint64(col.AvgRawSize))+itemSep+itemSep+itemSep)
+	}
+
+	return tw.Flush()
+}
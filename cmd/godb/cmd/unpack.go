@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/els0r/goProbe/cmd/godb/pkg/conf"
+	"github.com/els0r/goProbe/pkg/goDB"
+	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/els0r/goProbe/pkg/privileges"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var unpackCmd = &cobra.Command{
+	Use:   "unpack ARCHIVE",
+	Short: "Add the blocks of an archive to the DB",
+	Long: `Add the blocks of an archive to the DB
+
+Adds all blocks of an archive written by "godb pack" to the DB. Each block is
+only written once its checksum was verified, the checksum of the archive as a
+whole is verified at the end. Blocks already present in the DB are skipped,
+hence an archive can be unpacked repeatedly (e.g. after an interrupted import).
+Use --dry-run to only verify the archive. Use "-" to read it from stdin.
+
+The archive is verified as a whole before anything is written (an archive
+read from stdin is buffered in a temporary file in the meantime). The
+interfaces of the archive are locked while they are written, hence interfaces
+captured by a running goProbe instance can't be unpacked. Blocks can't be
+added to a day directory already holding later blocks.
+
+Files and directories are created with the permissions and owner configured
+by "db.permissions", "db.dir_permissions" and "db.owner" of the goProbe
+configuration provided via --config.
+`,
+	Args: cobra.ExactArgs(1),
+	RunE: unpackEntrypoint,
+}
+
+var (
+	unpackEncoder string
+	unpackDryRun  bool
+)
+
+func init() {
+	rootCmd.AddCommand(unpackCmd)
+
+	flags := unpackCmd.Flags()
+	flags.StringVarP(&unpackEncoder, "encoder", "e", "lz4", "encoder the blocks are stored with")
+	flags.BoolVar(&unpackDryRun, "dry-run", false, "only verify the archive")
+}
+
+func unpackEntrypoint(_ *cobra.Command, args []string) error {
+	encoderType, err := encoders.GetTypeByString(unpackEncoder)
+	if err != nil {
+		return fmt.Errorf("failed to get encoder type from %s: %w", unpackEncoder, err)
+	}
+
+	var r io.Reader = os.Stdin
+	if path := args[0]; path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open archive: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	unpacker := goDB.NewUnpacker(viper.GetString(conf.DBPath), encoderType).DryRun(unpackDryRun)
+	if permissions := viper.GetUint32(conf.DBPermissions); permissions != 0 {
+		unpacker.Permissions(fs.FileMode(permissions))
+	}
+	if permissions := viper.GetUint32(conf.DBDirPermissions); permissions != 0 {
+		unpacker.DirPermissions(fs.FileMode(permissions))
+	}
+	if spec := viper.GetString(conf.DBOwner); spec != "" {
+		owner, err := privileges.Lookup(spec)
+		if err != nil {
+			return fmt.Errorf("failed to determine owner of the DB: %w", err)
+		}
+		unpacker.Owner(owner.UID, owner.GID)
+	}
+
+	info, err := unpacker.Unpack(r)
+	if err != nil {
+		return fmt.Errorf("failed to unpack archive: %w", err)
+	}
+
+	action := "Unpacked"
+	if unpackDryRun {
+		action = "Verified"
+	}
+	printPackInfo(action, info)
+
+	return nil
+}
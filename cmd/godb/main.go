@@ -0,0 +1,7 @@
+package main
+
+import "github.com/els0r/goProbe/cmd/godb/cmd"
+
+func main() {
+	cmd.Execute()
+}
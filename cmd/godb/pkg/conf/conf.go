@@ -0,0 +1,10 @@
+package conf
+
+const (
+	dbKey = "db"
+
+	DBPath           = dbKey + ".path"            // DBPath : The path to the goDB directory
+	DBPermissions    = dbKey + ".permissions"     // DBPermissions : The permissions of files written to the goDB
+	DBDirPermissions = dbKey + ".dir_permissions" // DBDirPermissions : The permissions of directories created in the goDB
+	DBOwner          = dbKey + ".owner"           // DBOwner : The user (and optionally group) files written to the goDB are assigned to
+)
@@ -40,6 +40,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/els0r/goProbe/pkg/results"
 	"github.com/els0r/goProbe/pkg/version"
 )
 
@@ -172,13 +173,16 @@ func bashCompletion(args []string) {
 		// handled by wrapper bash script
 		return
 	case "-e":
-		printlns(filterPrefix(last(args), "txt", "json", "csv", "influxdb"))
+		printlns(filterPrefix(last(args), results.Formats()...))
 		return
 	case "-f", "-l", "-h", "--help":
 		return
 	case "-i":
 		printlns(ifaces(args))
 		return
+	case "--ip-version":
+		printlns(filterPrefix(last(args), "4", "6"))
+		return
 	case "-n":
 		return
 	case "-resolve-rows", "-resolve-timeout":
@@ -186,6 +190,9 @@ func bashCompletion(args []string) {
 	case "-s":
 		printlns(filterPrefix(last(args), "bytes", "packets", "time"))
 		return
+	case "--tenant":
+		printlns(tenants(args))
+		return
 	}
 
 	switch {
@@ -14,23 +14,8 @@ import (
 	"strings"
 
 	"github.com/els0r/goProbe/pkg/goDB/conditions"
-	"github.com/els0r/goProbe/pkg/goDB/protocols"
-	"github.com/els0r/goProbe/pkg/types"
 )
 
-func openParens(tokens []string) int {
-	open := 0
-	for _, token := range tokens {
-		switch token {
-		case "(":
-			open++
-		case ")":
-			open--
-		}
-	}
-	return open
-}
-
 func nextAll(prevprev, prev string, openParens int) []suggestion {
 	s := func(sugg string, accept bool) suggestion {
 		if accept {
@@ -39,93 +24,19 @@ func nextAll(prevprev, prev string, openParens int) []suggestion {
 		return suggestion{sugg, sugg + " ...  ", accept}
 	}
 
-	switch prev {
-	case "", "(", "&", "|":
-		return []suggestion{
-			s("!", false),
-			s("(", false),
-			s(types.DIPName, false),
-			s(types.SIPName, false),
-			s("dnet", false),
-			s("snet", false),
-			s("dst", false),
-			s("src", false),
-			s("host", false),
-			s("net", false),
-			s(types.DportName, false),
-			s("port", false),
-			s(types.ProtoName, false),
-		}
-	case "!":
-		return []suggestion{
-			s("(", false),
-			s(types.DIPName, false),
-			s(types.SIPName, false),
-			s("dnet", false),
-			s("snet", false),
-			s("dst", false),
-			s("src", false),
-			s("host", false),
-			s("net", false),
-			s(types.DportName, false),
-			s("port", false),
-			s(types.ProtoName, false),
-		}
-	case types.DIPName, types.SIPName, "dnet", "snet", "dst", "src", "host", "net":
-		return []suggestion{
-			s("=", false),
-			s("!=", false),
-		}
-	case types.DportName, "port", types.ProtoName:
-		return []suggestion{
-			s("=", false),
-			s("!=", false),
-			s("<", false),
-			s(">", false),
-			s("<=", false),
-			s(">=", false),
-		}
-	case "=", "!=", "<", ">", "<=", ">=":
-		switch prevprev {
-		case types.ProtoName:
-			var result []suggestion
-			for name := range protocols.IPProtocolIDs {
-				result = append(result, suggestion{name, name + " ...", openParens == 0})
-			}
-			return result
+	var result []suggestion
+	for _, token := range conditions.NextTokens(prevprev, prev, openParens) {
+		switch {
+		case conditions.IsComparator(prev):
+			// protocol names are the only values for which suggestions are available
+			result = append(result, suggestion{token, token + " ...", openParens == 0})
+		case token == ")":
+			result = append(result, s(token, openParens == 1))
 		default:
-			return nil
-		}
-	case ")":
-		if openParens > 0 {
-			return []suggestion{
-				s(")", openParens == 1),
-				s("&", false),
-				s("|", false),
-			}
-		}
-		return []suggestion{
-			s("&", false),
-			s("|", false),
-		}
-	default:
-		switch prevprev {
-		case "=", "!=", "<", ">", "<=", ">=":
-			if openParens > 0 {
-				return []suggestion{
-					s(")", openParens == 1),
-					s("&", false),
-					s("|", false),
-				}
-			}
-			return []suggestion{
-				s("&", false),
-				s("|", false),
-			}
-		default:
-			return nil
+			result = append(result, s(token, false))
 		}
 	}
+	return result
 }
 
 func conditional(args []string) []string {
@@ -152,7 +63,7 @@ func conditional(args []string) []string {
 
 	next := func(tokens []string) suggestions {
 		var suggs []suggestion
-		for _, sugg := range nextAll(antepenultimate(tokens), penultimate(tokens), openParens(tokens)) {
+		for _, sugg := range nextAll(antepenultimate(tokens), penultimate(tokens), conditions.OpenParens(tokens)) {
 			if strings.HasPrefix(sugg.token, last(tokens)) {
 				suggs = append(suggs, sugg)
 			}
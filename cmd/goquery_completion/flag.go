@@ -17,20 +17,26 @@ var flags = map[string]suggestion{
 	"-c":               {"-c", "-c <condition>", true},
 	"-d":               {"-d", "-d <db path>", true},
 	"-e":               {"-e", "-e <output format>", true},
+	"--explain":        {"--explain", "--explain (show query plan)", true},
 	"-f":               {"-f", "-f <start time>", true},
 	"-l":               {"-l", "-l <end time>", true},
 	"-h":               {"-h", "-h (show help)", true},
 	"-help":            {"-help", "-help (show help)", true},
 	"-i":               {"-i", "-i <interface(s)>", true},
 	"-in":              {"-in", "-in (only incoming)", true},
+	"--ip-version":     {"--ip-version", "--ip-version <4|6>", true},
 	"-list":            {"-list", "-list (list interfaces)", true},
 	"-n":               {"-n", "-n <# of results to print>", true},
 	"-out":             {"-out", "-out (only outgoing)", true},
 	"-resolve":         {"-resolve", "-resolve (run RDNS)", true},
 	"-resolve-rows":    {"-resolve-rows", "-resolve-rows", true},
 	"-resolve-timeout": {"-resolve-timeout", "-resolve-timeout", true},
+	"--raw-units":      {"--raw-units", "--raw-units (print exact integers)", true},
 	"-s":               {"-s", "-s <sort by>", true},
 	"-sum":             {"-sum", "-sum (sum incoming & outgoing)", true},
+	"--subtotals":      {"--subtotals", "--subtotals (print subtotal rows)", true},
+	"--total-row":      {"--total-row", "--total-row (print total row)", true},
+	"--tenant":         {"--tenant", "--tenant <tenant>", true},
 }
 
 func flag(args []string) []string {
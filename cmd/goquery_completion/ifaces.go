@@ -35,6 +35,26 @@ func dbPath(args []string) string {
 	return result
 }
 
+// tenant returns the tenant provided via --tenant (if any)
+func tenant(args []string) string {
+	result := ""
+	for i, arg := range args[:max(len(args)-1, 0)] {
+		if arg == "--tenant" {
+			result = args[i+1]
+		}
+	}
+	return result
+}
+
+// tenants returns the tenants in the DB which match the last argument
+func tenants(args []string) []string {
+	dbTenants, err := info.GetTenants(dbPath(args))
+	if err != nil {
+		return nil
+	}
+	return filterPrefix(last(args), dbTenants...)
+}
+
 func ifaces(args []string) []string {
 	tokenize := func(qt string) []string {
 		return strings.Split(qt, ",")
@@ -44,14 +64,20 @@ func ifaces(args []string) []string {
 		return strings.Join(attribs, ",")
 	}
 
-	dbpath := dbPath(args)
+	dbpath := info.TenantPath(dbPath(args), tenant(args))
 
 	dbIfaces, err := info.GetInterfaces(dbpath)
 	if err != nil {
 		return nil
 	}
 
-	tunnels := util.TunnelInfos()
+	// aliases are stored for the DB as a whole, but are only suggested for the interfaces
+	// of the queried tenant
+	aliases, _ := info.ReadAliases(dbPath(args))
+	groups, _ := info.ReadGroups(dbPath(args))
+
+	// completion works without the tunnel metadata if it can't be read
+	tunnels, _ := util.TunnelInfos()
 
 	next := func(ifaces []string) suggestions {
 		used := map[string]struct{}{}
@@ -71,10 +97,21 @@ func ifaces(args []string) []string {
 			}
 		}
 
+		for group, members := range groups {
+			if _, used := used[group]; !used && strings.HasPrefix(group, last(ifaces)) {
+				suggs = append(suggs, suggestion{group, fmt.Sprintf("%s (%s)", group, strings.Join(members, "+")), true})
+			}
+		}
+
 		for _, iface := range dbIfaces {
+			if alias := aliases.Alias(iface); alias != iface {
+				if _, used := used[alias]; !used && strings.HasPrefix(alias, last(ifaces)) {
+					suggs = append(suggs, suggestion{alias, fmt.Sprintf("%s (%s)", alias, iface), true})
+				}
+			}
 			if _, used := used[iface]; !used && strings.HasPrefix(iface, last(ifaces)) {
 				if info, isTunnel := tunnels[iface]; isTunnel {
-					suggs = append(suggs, suggestion{iface, fmt.Sprintf("%s (%s: %s)   ", iface, info.PhysicalIface, info.Peer), true})
+					suggs = append(suggs, suggestion{iface, fmt.Sprintf("%s (%s: %s, %s)   ", iface, info.PhysicalIface, info.Peer, info.Site), true})
 				} else {
 					suggs = append(suggs, suggestion{iface, iface, true})
 				}
@@ -27,22 +27,24 @@ func queryType(args []string) []string {
 
 	unusedAttribs := func(attribs []string) []string {
 		attribUnused := map[string]bool{
-			"time":          true,
-			"iface":         true,
-			types.SIPName:   true,
-			types.DIPName:   true,
-			types.DportName: true,
-			types.ProtoName: true,
+			types.TimeName:      true,
+			types.IfaceName:     true,
+			types.ProcessName:   true,
+			types.DirectionName: true,
+		}
+		for _, spec := range types.AttributeSpecs() {
+			if spec.New != nil || spec.LabelColumn {
+				attribUnused[spec.Name] = true
+			}
 		}
 
 		for _, attrib := range attribs {
 			switch attrib {
 			case "talk_conv", "talk_src", "talk_dst", "apps_port", "agg_talk_port", "raw":
 				return nil
-			case "src":
-				attrib = types.SIPName
-			case "dst":
-				attrib = types.DIPName
+			}
+			if spec, exists := types.LookupAttribute(attrib); exists {
+				attrib = spec.Name
 			}
 			attribUnused[attrib] = false
 		}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/els0r/goProbe/cmd/gpctl/pkg/conf"
+	"github.com/els0r/goProbe/pkg/api/goprobe/client"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const flagOutput = "output"
+
+var bundleOutput string
+
+// bundleCmd represents the bundle command
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Download a support bundle from goProbe",
+	Long: `Download a support bundle from goProbe
+
+The bundle is a tar.gz archive holding goProbe's version, its configuration
+(with secrets redacted), the capture status, the most recent errors, information
+about the database and runtime profiles. Attach it to bug reports.
+
+If no output file is provided, the bundle is written to the current directory.
+`,
+	Args:          cobra.NoArgs,
+	RunE:          wrapCancellationContext(bundleEntrypoint),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.AddCommand(bundleCmd)
+
+	bundleCmd.Flags().StringVarP(&bundleOutput, flagOutput, "o", "", "file the support bundle is written to")
+}
+
+func bundleEntrypoint(ctx context.Context, _ *cobra.Command, _ []string) (err error) {
+	client := client.New(viper.GetString(conf.GoProbeServerAddr))
+
+	path := bundleOutput
+	if path == "" {
+		path = fmt.Sprintf("goprobe-bundle-%s.tar.gz", time.Now().UTC().Format("20060102T150405Z"))
+	}
+
+	// #nosec G304
+	f, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create support bundle file: %w", err)
+	}
+	defer func() {
+		err = errors.Join(err, f.Close())
+
+		// don't leave a partial bundle behind
+		if err != nil {
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	if err = client.GetSupportBundle(ctx, f); err != nil {
+		return fmt.Errorf("failed to download support bundle: %w", err)
+	}
+
+	fmt.Printf("Wrote support bundle to %s\n", path)
+	return nil
+}
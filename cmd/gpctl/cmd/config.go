@@ -23,6 +23,7 @@ const (
 	flagFile   = "file"
 	flagReload = "reload"
 	flagSilent = "silent"
+	flagDryRun = "dry-run"
 
 	defaultRequestTimeout = 3 * time.Second
 )
@@ -31,6 +32,7 @@ var (
 	file   string
 	silent bool
 	reload bool
+	dryRun bool
 )
 
 // configCmd represents the config command
@@ -45,6 +47,9 @@ show the configuration for them. Otherwise, all configurations are printed
 The list of interfaces is ignored if -f|--file or -r|--reload is provided (which
 are mutually exclusive and both trigger a change of goprobe's runtime configuration,
 either from the provided file or reloading the on-disk configuration).
+
+With --dry-run, the changes applying the file would cause are shown without
+applying them.
 `,
 	RunE:          wrapCancellationContext(configEntrypoint),
 	SilenceUsage:  true,
@@ -57,6 +62,7 @@ func init() {
 	configCmd.Flags().StringVarP(&file, flagFile, "f", "", "apply config file to goprobe's runtime configuration")
 	configCmd.Flags().BoolVarP(&reload, flagReload, "r", false, "reload on-disk config file and apply it to goprobe's runtime configuration")
 	configCmd.Flags().BoolVar(&silent, flagSilent, false, "don't output interface changes after update")
+	configCmd.Flags().BoolVar(&dryRun, flagDryRun, false, "only show the changes applying the config file (-f|--file) would cause")
 }
 
 func configEntrypoint(ctx context.Context, cmd *cobra.Command, args []string) error {
@@ -67,11 +73,15 @@ func configEntrypoint(ctx context.Context, cmd *cobra.Command, args []string) er
 		cmd.SilenceUsage = false
 		return errors.New("cannot perform both config reload from disk and apply external runtime configuration")
 	}
+	if dryRun && file == "" {
+		cmd.SilenceUsage = false
+		return errors.New("dry run requires a config file to be provided")
+	}
 	if reload {
 		return reloadConfig(ctx)
 	}
 	if file != "" {
-		return updateConfig(ctx, file, silent)
+		return updateConfig(ctx, file, silent, dryRun)
 	}
 
 	ifaces := args
@@ -144,7 +154,7 @@ func reloadConfig(ctx context.Context) error {
 	return nil
 }
 
-func updateConfig(ctx context.Context, file string, silent bool) error {
+func updateConfig(ctx context.Context, file string, silent, dryRun bool) error {
 	client := client.New(viper.GetString(conf.GoProbeServerAddr))
 
 	// get the config from disk
@@ -159,6 +169,20 @@ func updateConfig(ctx context.Context, file string, silent bool) error {
 		return fmt.Errorf("invalid configuration provided: %w", err)
 	}
 
+	if dryRun {
+		res, err := client.PreviewInterfaceConfigs(ctx, gpConfig.Interfaces)
+		if err != nil {
+			return fmt.Errorf("failed to preview update of goprobe's runtime configuration: %w", err)
+		}
+		printIfaceChanges(res.Enabled, res.Updated, res.Disabled)
+		for _, iface := range res.Updated {
+			for _, field := range res.Changes[iface] {
+				fmt.Printf("    %s: %s: %v -> %v\n", iface, field.Field, field.Old, field.New)
+			}
+		}
+		return nil
+	}
+
 	// send update call
 	enabled, updated, disabled, err := client.UpdateInterfaceConfigs(ctx, gpConfig.Interfaces)
 	if err != nil {
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/els0r/goProbe/cmd/gpctl/pkg/conf"
+	"github.com/els0r/goProbe/pkg/api/goprobe/client"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// snapshotCmd represents the snapshot command
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot [NAME]",
+	Short: "Create a consistent snapshot of goProbe's database",
+	Long: `Create a consistent snapshot of goProbe's database
+
+Writeouts are briefly suspended while the database tree is hard-linked into
+the snapshot directory of goProbe (capturing continues unaffected). The snapshot
+can then be backed up or analyzed offline (e.g. via goQuery -d <snapshot path>).
+
+If no name is provided, the snapshot is named after the current time.
+`,
+	Args:          cobra.MaximumNArgs(1),
+	RunE:          wrapCancellationContext(snapshotEntrypoint),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+}
+
+func snapshotEntrypoint(ctx context.Context, _ *cobra.Command, args []string) error {
+	client := client.New(viper.GetString(conf.GoProbeServerAddr))
+
+	var name string
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	snapshot, err := client.CreateSnapshot(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	fmt.Printf("Created snapshot at %s (%d files)\n", snapshot.Path, snapshot.NumFiles)
+	return nil
+}
@@ -92,8 +92,8 @@ func statusEntrypoint(ctx context.Context, cmd *cobra.Command, args []string) er
 	table.UTF8Box()
 	table.AddTitle(shellformat.FormatShell("Interface Statuses", shellformat.Bold))
 
-	headerRow1 := []interface{}{"", "total", "", "total", "", "total", "", "active"}
-	headerRow2 := []interface{}{"iface",
+	headerRow1 := []interface{}{"", "", "total", "", "total", "", "total", "", "active"}
+	headerRow2 := []interface{}{"iface", "link",
 		"received", "+ received",
 		"processed", "+ processed",
 		"dropped", "+ dropped", "for"}
@@ -127,11 +127,25 @@ func statusEntrypoint(ctx context.Context, cmd *cobra.Command, args []string) er
 			dropped = shellformat.FormatShell(ifaceStatus.Dropped, shellformat.Bold, shellformat.Red)
 		}
 
-		ifaceRow := []interface{}{st.iface,
+		link := "-"
+		if ifaceStatus.LinkState != capturetypes.LinkStateUnknown {
+			link = string(ifaceStatus.LinkState)
+		}
+		if ifaceStatus.LinkState.IsDown() {
+			link = shellformat.FormatShell(link, shellformat.Bold, shellformat.Red)
+		}
+
+		// interfaces without a running capture (e.g. because they vanished) aren't active
+		activeFor := "-"
+		if !ifaceStatus.StartedAt.IsZero() {
+			activeFor = time.Since(ifaceStatus.StartedAt).Round(time.Second).String()
+		}
+
+		ifaceRow := []interface{}{st.iface, link,
 			formatting.Countable(ifaceStatus.ReceivedTotal), formatting.Countable(ifaceStatus.Received),
 			formatting.Countable(ifaceStatus.ProcessedTotal), formatting.Countable(ifaceStatus.Processed),
 			formatting.Countable(ifaceStatus.DroppedTotal), dropped,
-			time.Since(ifaceStatus.StartedAt).Round(time.Second).String()}
+			activeFor}
 		if detailed {
 			for _, parsingErrno := range ifaceStatus.ParsingErrors {
 				ifaceRow = append(ifaceRow, tablewriter.CreateCell(formatting.Countable(parsingErrno), &tablewriter.CellStyle{Alignment: tablewriter.AlignRight}))
@@ -143,7 +157,8 @@ func statusEntrypoint(ctx context.Context, cmd *cobra.Command, args []string) er
 
 	// set alignment before rendering
 	table.SetAlign(tablewriter.AlignLeft, 1)
-	for i := 2; i <= 8; i++ {
+	table.SetAlign(tablewriter.AlignLeft, 2)
+	for i := 3; i <= 9; i++ {
 		table.SetAlign(tablewriter.AlignRight, i)
 	}
 
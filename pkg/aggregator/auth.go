@@ -0,0 +1,121 @@
+package aggregator
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// tokenMetadataKey denotes the metadata key the shared token is presented in
+const tokenMetadataKey = "authorization"
+
+// ErrInvalidTLSConfig is returned if the certificates of a TLS configuration are incomplete
+var ErrInvalidTLSConfig = errors.New("invalid TLS configuration")
+
+// TLSConfig stores the certificates securing the connections between goProbe hosts and the aggregator
+type TLSConfig struct {
+	// CAFile: path to the PEM encoded CA certificate(s) the certificate of the peer is verified against.
+	// On the client, the system's CAs are used if unset. On the aggregator, setting it requires
+	// clients to present a certificate signed by one of the CAs (mutual TLS). Example: /etc/goprobe/ca.pem
+	CAFile string `json:"ca_file,omitempty" yaml:"ca_file,omitempty"`
+
+	// CertFile: path to the PEM encoded certificate presented to the peer. Mandatory for the aggregator,
+	// required on the client if the aggregator enforces mutual TLS. Example: /etc/goprobe/cert.pem
+	CertFile string `json:"cert_file,omitempty" yaml:"cert_file,omitempty"`
+
+	// KeyFile: path to the PEM encoded private key of the certificate. Example: /etc/goprobe/key.pem
+	KeyFile string `json:"key_file,omitempty" yaml:"key_file,omitempty"`
+}
+
+// Validate checks that certificate and key are provided together
+func (c *TLSConfig) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("%w: certificate and key must be provided together", ErrInvalidTLSConfig)
+	}
+	return nil
+}
+
+// ServerConfig loads the certificates for use by the aggregator
+func (c *TLSConfig) ServerConfig() (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, fmt.Errorf("%w: the aggregator requires a certificate and key", ErrInvalidTLSConfig)
+	}
+	cfg, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.RootCAs != nil {
+		cfg.ClientCAs, cfg.RootCAs = cfg.RootCAs, nil
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// ClientConfig loads the certificates for use by a goProbe host
+func (c *TLSConfig) ClientConfig() (*tls.Config, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c.load()
+}
+
+func (c *TLSConfig) load() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificates: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: no CA certificates found in %s", ErrInvalidTLSConfig, c.CAFile)
+		}
+	}
+	return cfg, nil
+}
+
+// tokenCredentials presents the shared token to the aggregator upon each call
+type tokenCredentials struct {
+	token string
+	tls   bool
+}
+
+// GetRequestMetadata implements credentials.PerRPCCredentials
+func (t tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{tokenMetadataKey: t.token}, nil
+}
+
+// RequireTransportSecurity implements credentials.PerRPCCredentials. The token is sent in plain text
+// if the connection isn't secured by TLS, which is only advisable in trusted networks
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return t.tls
+}
+
+// tokenInterceptor rejects all calls which don't present the shared token
+func tokenInterceptor(token string) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		md, _ := metadata.FromIncomingContext(stream.Context())
+		for _, presented := range md.Get(tokenMetadataKey) {
+			if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1 {
+				return handler(srv, stream)
+			}
+		}
+		return status.Error(codes.Unauthenticated, "missing or invalid token")
+	}
+}
@@ -0,0 +1,239 @@
+package aggregator
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/goprobe/writeout"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/goProbe/pkg/wire"
+	"github.com/els0r/telemetry/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	// DefaultBatchSize denotes the default maximum number of flows sent per message. With a few
+	// dozen bytes per flow, it keeps the messages well below the default size limit of gRPC (4 MiB)
+	DefaultBatchSize = 16384
+
+	// DefaultWriteTimeout denotes the default time allotted to stream the flows of a writeout
+	DefaultWriteTimeout = time.Minute
+)
+
+// Client streams the flows of each writeout to an aggregator. It implements writeout.Handler and
+// may forward the writeouts to a local handler as well (e.g. to keep writing them to a local goDB)
+type Client struct {
+	conn     *grpc.ClientConn
+	identity info.Identity
+
+	batchSize    int
+	writeTimeout time.Duration
+	local        writeout.Handler
+}
+
+// ClientOption configures the connection of the client
+type ClientOption func(*clientConfig)
+
+type clientConfig struct {
+	tlsConfig *tls.Config
+	token     string
+}
+
+// WithClientTLS secures the connection by TLS (see TLSConfig.ClientConfig()). Without it, the flows
+// are sent in plain text
+func WithClientTLS(cfg *tls.Config) ClientOption {
+	return func(c *clientConfig) {
+		c.tlsConfig = cfg
+	}
+}
+
+// WithClientToken presents the shared token to the aggregator upon each call
+func WithClientToken(token string) ClientOption {
+	return func(c *clientConfig) {
+		c.token = token
+	}
+}
+
+// NewClient instantiates a new client streaming the flows to the aggregator at address (host:port).
+// The flows are attributed to the given identity of the goProbe host. The connection is established
+// lazily (and re-established on failure)
+func NewClient(address string, identity info.Identity, opts ...ClientOption) (*Client, error) {
+	var cfg clientConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	creds := insecure.NewCredentials()
+	if cfg.tlsConfig != nil {
+		creds = credentials.NewTLS(cfg.tlsConfig)
+	}
+	dialOpts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(codec{})),
+	}
+	if cfg.token != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(tokenCredentials{
+			token: cfg.token,
+			tls:   cfg.tlsConfig != nil,
+		}))
+	}
+
+	conn, err := grpc.Dial(address, dialOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up connection to aggregator at %s: %w", address, err)
+	}
+	return &Client{
+		conn:         conn,
+		identity:     identity,
+		batchSize:    DefaultBatchSize,
+		writeTimeout: DefaultWriteTimeout,
+	}, nil
+}
+
+// WithBatchSize sets the maximum number of flows sent per message. A value <= 0 resets it to
+// DefaultBatchSize
+func (c *Client) WithBatchSize(n int) *Client {
+	c.batchSize = DefaultBatchSize
+	if n > 0 {
+		c.batchSize = n
+	}
+	return c
+}
+
+// WithWriteTimeout sets the time allotted to stream the flows of a writeout. A value <= 0 resets it
+// to DefaultWriteTimeout
+func (c *Client) WithWriteTimeout(timeout time.Duration) *Client {
+	c.writeTimeout = DefaultWriteTimeout
+	if timeout > 0 {
+		c.writeTimeout = timeout
+	}
+	return c
+}
+
+// WithLocalHandler forwards all writeouts to the handler (in addition to streaming them to the
+// aggregator)
+func (c *Client) WithLocalHandler(h writeout.Handler) *Client {
+	c.local = h
+	return c
+}
+
+// Close terminates the connection to the aggregator and closes the local handler (if it supports it)
+func (c *Client) Close() error {
+	err := c.conn.Close()
+	if closer, ok := c.local.(io.Closer); ok {
+		err = errors.Join(err, closer.Close())
+	}
+	return err
+}
+
+// HandleWriteout streams the flows of all interfaces of a writeout to the aggregator (using a single
+// call). Flows that couldn't be delivered are dropped, since sensors may not be able to buffer them
+func (c *Client) HandleWriteout(ctx context.Context, timestamp time.Time, writeoutChan <-chan capturetypes.TaggedAggFlowMap) <-chan struct{} {
+
+	doneChan := make(chan struct{})
+	go func() {
+		logger := logging.FromContext(ctx)
+		t0 := time.Now()
+
+		var (
+			localChan chan capturetypes.TaggedAggFlowMap
+			localDone <-chan struct{}
+		)
+		if c.local != nil {
+			localChan = make(chan capturetypes.TaggedAggFlowMap, cap(writeoutChan))
+			localDone = c.local.HandleWriteout(ctx, timestamp, localChan)
+		}
+
+		sendCtx, cancel := context.WithTimeout(ctx, c.writeTimeout)
+		defer cancel()
+
+		stream, err := c.conn.NewStream(sendCtx, &serviceDesc.Streams[0], writeMethod)
+		for taggedMap := range writeoutChan {
+			if localChan != nil {
+				localChan <- taggedMap
+			}
+
+			// the channel is drained even if the flows can't be sent, so that the writeout completes
+			if err == nil {
+				err = c.send(stream, timestamp, taggedMap)
+			}
+		}
+		if localChan != nil {
+			close(localChan)
+		}
+
+		var summary wire.WriteSummary
+		if err == nil {
+			if err = stream.CloseSend(); err == nil {
+				err = stream.RecvMsg(&summary)
+			}
+		}
+		if err != nil {
+			logger.Errorf("failed to stream flows to aggregator: %s", err)
+		} else {
+			logger.With(
+				"flows", summary.Flows,
+				"elapsed", time.Since(t0).Round(time.Millisecond).String(),
+			).Debug("streamed flows to aggregator")
+		}
+
+		if localDone != nil {
+			<-localDone
+		}
+		doneChan <- struct{}{}
+	}()
+
+	return doneChan
+}
+
+// send streams the flows of an interface to the aggregator, split into batches of at most batchSize flows
+func (c *Client) send(stream grpc.ClientStream, timestamp time.Time, taggedMap capturetypes.TaggedAggFlowMap) error {
+	batch := wire.FlowBatch{
+		Hostname:  c.identity.Hostname,
+		HostID:    c.identity.HostID,
+		Timestamp: timestamp.Unix(),
+		Iface:     taggedMap.Iface,
+		Tenant:    taggedMap.Tenant,
+		Stats:     taggedMap.Stats,
+	}
+
+	// interfaces without flows are sent nonetheless, so that their stats are stored
+	var numFlows int
+	if taggedMap.Map != nil {
+		numFlows = taggedMap.Map.Len()
+	}
+	batch.Flows = make([]wire.Flow, 0, min(numFlows, c.batchSize))
+	if numFlows == 0 {
+		return stream.SendMsg(&batch)
+	}
+
+	for i := taggedMap.Map.Iter(); i.Next(); {
+		flow := wire.Flow{
+			Key:      types.Key(i.Key()),
+			Counters: i.Val(),
+		}
+		if taggedMap.Processes != nil {
+			flow.Process = taggedMap.Processes(flow.Key)
+		}
+		batch.Flows = append(batch.Flows, flow)
+
+		if len(batch.Flows) == c.batchSize {
+			if err := stream.SendMsg(&batch); err != nil {
+				return err
+			}
+			batch.Flows = batch.Flows[:0]
+		}
+	}
+	if len(batch.Flows) > 0 {
+		return stream.SendMsg(&batch)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// Package aggregator implements the central aggregation of flows from many goProbe hosts. Instead of
+// (or in addition to) writing its flows to a local goDB, a goProbe host streams the flows of each
+// writeout to the aggregator via gRPC (see Client), which writes them to a fleet-wide goDB (see
+// Server). This is useful for small sensors without (sufficient) local storage, where distributed
+// querying isn't an option.
+//
+// The service (goprobe.wire.Aggregator) and its messages are defined in pkg/wire/wire.proto. Since
+// the messages are encoded by package wire, the service description and codec are provided here
+// instead of being generated by protoc
+package aggregator
+
+import (
+	"fmt"
+
+	"github.com/els0r/goProbe/pkg/wire"
+	"google.golang.org/grpc"
+)
+
+const (
+	serviceName     = "goprobe.wire.Aggregator"
+	writeMethodName = "Write"
+	writeMethod     = "/" + serviceName + "/" + writeMethodName
+
+	// codecName denotes the name of the codec (i.e. the content subtype of the gRPC messages)
+	codecName = "goprobe-wire"
+)
+
+// codec encodes the messages of the aggregator service using package wire
+type codec struct{}
+
+// Marshal encodes a message of the aggregator service
+func (codec) Marshal(v any) ([]byte, error) {
+	switch msg := v.(type) {
+	case *wire.FlowBatch:
+		return wire.MarshalFlowBatch(msg)
+	case *wire.WriteSummary:
+		return wire.MarshalWriteSummary(msg)
+	}
+	return nil, fmt.Errorf("cannot marshal message of unsupported type %T", v)
+}
+
+// Unmarshal decodes a message of the aggregator service
+func (codec) Unmarshal(data []byte, v any) error {
+	switch msg := v.(type) {
+	case *wire.FlowBatch:
+		return wire.UnmarshalFlowBatch(data, msg)
+	case *wire.WriteSummary:
+		return wire.UnmarshalWriteSummary(data, msg)
+	}
+	return fmt.Errorf("cannot unmarshal message of unsupported type %T", v)
+}
+
+// Name returns the name of the codec
+func (codec) Name() string {
+	return codecName
+}
+
+// writeHandler implements the server side of the Write method
+type writeHandler interface {
+	write(stream grpc.ServerStream) error
+}
+
+// serviceDesc describes the aggregator service (service Aggregator in wire.proto)
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*writeHandler)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: writeMethodName,
+			Handler: func(srv any, stream grpc.ServerStream) error {
+				return srv.(writeHandler).write(stream)
+			},
+			ClientStreams: true,
+		},
+	},
+	Metadata: "pkg/wire/wire.proto",
+}
@@ -0,0 +1,291 @@
+package aggregator
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/goProbe/pkg/goDB"
+	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/goprobe/writeout"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/goProbe/pkg/types/hashmap"
+	"github.com/els0r/goProbe/pkg/wire"
+	"github.com/els0r/telemetry/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// ErrInvalidBatch is returned for batches of flows which can't be stored, e.g. since their hostname
+// or interface can't be used as (part of) a DB path
+var ErrInvalidBatch = errors.New("invalid flow batch")
+
+var (
+	// hostnames and interface names are used as directory names, hence they must not contain path separators
+	hostnameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]{0,252}$`)
+	ifaceRegexp    = regexp.MustCompile(`^[a-zA-Z0-9.:_-]{1,15}$`)
+)
+
+// Server receives the flows streamed by goProbe hosts and writes them to a goDB. The flows of each host
+// are stored in a dedicated subtree "<path>/<hostname>" (along with the identity of the host), which
+// can be queried like the goDB of the host itself
+type Server struct {
+	path        string
+	encoderType encoders.Type
+	permissions fs.FileMode
+
+	sensors    map[string]*sensor // keyed by hostname
+	grpcServer *grpc.Server
+
+	sync.Mutex
+}
+
+// sensor denotes the subtree of a goProbe host. Writes are serialized per host, since a host may
+// (temporarily) stream multiple writeouts at once
+type sensor struct {
+	handler  *writeout.GoDBHandler
+	identity info.Identity
+
+	sync.Mutex
+}
+
+// ServerOption configures the server
+type ServerOption func(*serverConfig)
+
+type serverConfig struct {
+	tlsConfig *tls.Config
+	token     string
+}
+
+// WithServerTLS secures the connections by TLS (see TLSConfig.ServerConfig())
+func WithServerTLS(cfg *tls.Config) ServerOption {
+	return func(c *serverConfig) {
+		c.tlsConfig = cfg
+	}
+}
+
+// WithServerToken rejects all goProbe hosts which don't present the shared token
+func WithServerToken(token string) ServerOption {
+	return func(c *serverConfig) {
+		c.token = token
+	}
+}
+
+// NewServer instantiates a new server writing the flows to the goDB at path
+func NewServer(path string, encoderType encoders.Type, opts ...ServerOption) *Server {
+	s := &Server{
+		path:        path,
+		encoderType: encoderType,
+		permissions: goDB.DefaultPermissions,
+		sensors:     make(map[string]*sensor),
+	}
+
+	var cfg serverConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	grpcOpts := []grpc.ServerOption{grpc.ForceServerCodec(codec{})}
+	if cfg.tlsConfig != nil {
+		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(cfg.tlsConfig)))
+	}
+	if cfg.token != "" {
+		grpcOpts = append(grpcOpts, grpc.StreamInterceptor(tokenInterceptor(cfg.token)))
+	}
+	s.grpcServer = grpc.NewServer(grpcOpts...)
+	s.grpcServer.RegisterService(&serviceDesc, s)
+	return s
+}
+
+// WithPermissions sets explicit permissions for the underlying goDB
+func (s *Server) WithPermissions(permissions fs.FileMode) *Server {
+	s.permissions = permissions
+	return s
+}
+
+// Serve accepts connections from goProbe hosts on the listener. It blocks until Shutdown() is called
+func (s *Server) Serve(listener net.Listener) error {
+	return s.grpcServer.Serve(listener)
+}
+
+// Shutdown stops accepting connections and waits until all pending writes have completed
+func (s *Server) Shutdown() {
+	s.grpcServer.GracefulStop()
+}
+
+// ifaceKey identifies the flows of an interface within the batches of a writeout
+type ifaceKey struct {
+	tenant, iface string
+}
+
+// write receives the batches of a writeout and stores their flows once the client has sent all of them
+func (s *Server) write(stream grpc.ServerStream) error {
+	ctx := stream.Context()
+
+	var (
+		summary   wire.WriteSummary
+		identity  info.Identity
+		timestamp int64
+
+		taggedMaps = make(map[ifaceKey]*capturetypes.TaggedAggFlowMap)
+		processes  = make(map[ifaceKey]map[string]string)
+	)
+	for {
+		var batch wire.FlowBatch
+		err := stream.RecvMsg(&batch)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := validateBatch(&batch); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		// all batches of a call belong to the same writeout
+		if summary.Batches == 0 {
+			identity = info.Identity{Hostname: batch.Hostname, HostID: batch.HostID}
+			timestamp = batch.Timestamp
+		} else if batch.Hostname != identity.Hostname || batch.Timestamp != timestamp {
+			return status.Errorf(codes.InvalidArgument, "%s: batches of host `%s` at %d and host `%s` at %d can't be mixed",
+				ErrInvalidBatch, identity.Hostname, timestamp, batch.Hostname, batch.Timestamp)
+		}
+		summary.Batches++
+		summary.Flows += len(batch.Flows)
+
+		key := ifaceKey{batch.Tenant, batch.Iface}
+		taggedMap, exists := taggedMaps[key]
+		if !exists {
+			taggedMap = &capturetypes.TaggedAggFlowMap{
+				Map:    hashmap.NewAggFlowMap(len(batch.Flows)),
+				Iface:  batch.Iface,
+				Tenant: batch.Tenant,
+			}
+			taggedMaps[key] = taggedMap
+		}
+		taggedMap.Stats = batch.Stats
+
+		for _, flow := range batch.Flows {
+			taggedMap.Map.SetOrUpdate(flow.Key, flow.Key.IsIPv4(),
+				flow.Counters.BytesRcvd, flow.Counters.BytesSent, flow.Counters.PacketsRcvd, flow.Counters.PacketsSent)
+			if flow.Process != "" {
+				if processes[key] == nil {
+					processes[key] = make(map[string]string)
+				}
+				processes[key][string(flow.Key)] = flow.Process
+			}
+		}
+	}
+
+	if summary.Batches > 0 {
+		for key, labels := range processes {
+			taggedMaps[key].Processes = func(key types.Key) string {
+				return labels[string(key)]
+			}
+		}
+		if err := s.store(ctx, identity, time.Unix(timestamp, 0), taggedMaps); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+	return stream.SendMsg(&summary)
+}
+
+// store writes the flows of all interfaces of a writeout to the subtree of the host. Interfaces which
+// can't be written don't prevent the others from being written, but fail the call
+func (s *Server) store(ctx context.Context, identity info.Identity, timestamp time.Time, taggedMaps map[ifaceKey]*capturetypes.TaggedAggFlowMap) error {
+	host, err := s.sensor(identity)
+	if err != nil {
+		return err
+	}
+
+	ctx = logging.WithFields(ctx, slog.String("hostname", identity.Hostname))
+
+	host.Lock()
+	defer host.Unlock()
+
+	// the interfaces are written in a deterministic order
+	keys := make([]ifaceKey, 0, len(taggedMaps))
+	for key := range taggedMaps {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].tenant != keys[j].tenant {
+			return keys[i].tenant < keys[j].tenant
+		}
+		return keys[i].iface < keys[j].iface
+	})
+
+	var errs []error
+	for _, key := range keys {
+		if err := host.handler.WriteIface(timestamp, *taggedMaps[key]); err != nil {
+			logging.FromContext(ctx).With("iface", key.iface, "tenant", key.tenant).Errorf("failed to write flows: %s", err)
+			errs = append(errs, fmt.Errorf("failed to write flows of interface `%s`: %w", key.iface, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sensor returns the subtree of the host, creating it (and storing the identity of the host) if necessary
+func (s *Server) sensor(identity info.Identity) (*sensor, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	host, exists := s.sensors[identity.Hostname]
+	if exists && host.identity == identity {
+		return host, nil
+	}
+
+	path := filepath.Join(s.path, identity.Hostname)
+	if !exists {
+		// #nosec G301
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create DB of host `%s`: %w", identity.Hostname, err)
+		}
+	}
+	if err := info.WriteIdentity(path, identity); err != nil {
+		return nil, fmt.Errorf("failed to store identity of host `%s`: %w", identity.Hostname, err)
+	}
+
+	if !exists {
+		host = &sensor{
+			handler: writeout.NewGoDBHandler(path, s.encoderType).WithPermissions(s.permissions),
+		}
+		s.sensors[identity.Hostname] = host
+	}
+	host.identity = identity
+	return host, nil
+}
+
+// validateBatch makes sure that the batch can be written to the goDB
+func validateBatch(batch *wire.FlowBatch) error {
+	if !hostnameRegexp.MatchString(batch.Hostname) {
+		return fmt.Errorf("%w: hostname `%s` is invalid", ErrInvalidBatch, batch.Hostname)
+	}
+	if !ifaceRegexp.MatchString(batch.Iface) || batch.Iface == "." || batch.Iface == ".." {
+		return fmt.Errorf("%w: interface name `%s` is invalid", ErrInvalidBatch, batch.Iface)
+	}
+	if err := info.ValidateTenant(batch.Tenant); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBatch, err)
+	}
+	for _, flow := range batch.Flows {
+		if len(flow.Key) != types.KeyWidthIPv4 && len(flow.Key) != types.KeyWidthIPv6 {
+			return fmt.Errorf("%w: flow key of length %d is invalid", ErrInvalidBatch, len(flow.Key))
+		}
+	}
+	return nil
+}
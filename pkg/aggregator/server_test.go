@@ -0,0 +1,225 @@
+package aggregator
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/fs"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/goprobe/writeout"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/goProbe/pkg/types/hashmap"
+	"github.com/els0r/goProbe/pkg/wire"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// dbFiles returns the contents of the files of the DB at path (keyed by their path relative to it),
+// except for the identity of the host
+func dbFiles(t *testing.T, path string) map[string][]byte {
+	files := make(map[string][]byte)
+	require.Nil(t, filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || d.Name() == "host.identity" {
+			return err
+		}
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+		files[rel], err = os.ReadFile(p)
+		return err
+	}))
+	return files
+}
+
+func TestAggregator(t *testing.T) {
+	aggregatorPath, localPath := t.TempDir(), t.TempDir()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	server := NewServer(aggregatorPath, encoders.EncoderTypeNull)
+	go func() {
+		require.Nil(t, server.Serve(listener))
+	}()
+	defer server.Shutdown()
+
+	identity := info.Identity{Hostname: "sensor-01", HostID: "2a0c4d1b"}
+	client, err := NewClient(listener.Addr().String(), identity)
+	require.Nil(t, err)
+	defer client.Close()
+
+	// the flows are sent in batches of a single flow, which are merged again by the aggregator
+	client.WithBatchSize(1).WithLocalHandler(writeout.NewGoDBHandler(localPath, encoders.EncoderTypeNull))
+
+	flows := hashmap.NewAggFlowMap()
+	nginx := types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6)
+	flows.PrimaryMap.Set(nginx, hashmap.Val{BytesRcvd: 100, PacketsRcvd: 1})
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 3}, [4]byte{10, 0, 0, 2}, []byte{0, 53}, 17), hashmap.Val{BytesSent: 200, PacketsSent: 2})
+	flows.SecondaryMap.Set(types.NewV6KeyStatic([16]byte{0xfe, 0x80, 15: 1}, [16]byte{0xfe, 0x80, 15: 2}, []byte{1, 187}, 6), hashmap.Val{BytesRcvd: 300, PacketsRcvd: 3})
+
+	writeoutChan := make(chan capturetypes.TaggedAggFlowMap, 3)
+	writeoutChan <- capturetypes.TaggedAggFlowMap{Map: flows, Iface: "eth0", Processes: func(key types.Key) string {
+		if string(key) == string(nginx) {
+			return "nginx.service"
+		}
+		return ""
+	}}
+	writeoutChan <- capturetypes.TaggedAggFlowMap{Map: flows, Iface: "eth1", Tenant: "acme"}
+	writeoutChan <- capturetypes.TaggedAggFlowMap{Map: hashmap.NewAggFlowMap(), Iface: "eth2"}
+	close(writeoutChan)
+	<-client.HandleWriteout(context.Background(), time.Now(), writeoutChan)
+
+	// the subtree of the host holds the same data as the local DB
+	sensorPath := filepath.Join(aggregatorPath, identity.Hostname)
+	localFiles := dbFiles(t, localPath)
+	require.NotEmpty(t, localFiles)
+	require.Equal(t, localFiles, dbFiles(t, sensorPath))
+	for path, data := range localFiles {
+		require.Equal(t, data, dbFiles(t, sensorPath)[path], path)
+	}
+
+	stored, err := info.ReadIdentity(sensorPath)
+	require.Nil(t, err)
+	require.Equal(t, identity, stored)
+
+	// batches which can't be stored are rejected
+	for _, batch := range []*capturetypes.TaggedAggFlowMap{
+		{Map: flows, Iface: "../eth0"},
+		{Map: flows, Iface: "eth0", Tenant: "acme corp"},
+	} {
+		stream, err := client.conn.NewStream(context.Background(), &serviceDesc.Streams[0], writeMethod)
+		require.Nil(t, err)
+		require.Nil(t, client.send(stream, time.Now(), *batch))
+		require.Nil(t, stream.CloseSend())
+		require.Equal(t, codes.InvalidArgument, status.Code(stream.RecvMsg(&wire.WriteSummary{})))
+	}
+}
+
+func TestAggregatorStoreErrors(t *testing.T) {
+	aggregatorPath := t.TempDir()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	server := NewServer(aggregatorPath, encoders.EncoderTypeNull)
+	go func() {
+		require.Nil(t, server.Serve(listener))
+	}()
+	defer server.Shutdown()
+
+	identity := info.Identity{Hostname: "sensor-01", HostID: "2a0c4d1b"}
+	client, err := NewClient(listener.Addr().String(), identity)
+	require.Nil(t, err)
+	defer client.Close()
+
+	// the directory of the interface can't be created
+	require.Nil(t, os.MkdirAll(filepath.Join(aggregatorPath, identity.Hostname), 0755))
+	require.Nil(t, os.WriteFile(filepath.Join(aggregatorPath, identity.Hostname, "eth0"), nil, 0644))
+
+	flows := hashmap.NewAggFlowMap()
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6), hashmap.Val{BytesRcvd: 100, PacketsRcvd: 1})
+
+	stream, err := client.conn.NewStream(context.Background(), &serviceDesc.Streams[0], writeMethod)
+	require.Nil(t, err)
+	require.Nil(t, client.send(stream, time.Now(), capturetypes.TaggedAggFlowMap{Map: flows, Iface: "eth0"}))
+	require.Nil(t, client.send(stream, time.Now(), capturetypes.TaggedAggFlowMap{Map: flows, Iface: "eth1"}))
+	require.Nil(t, stream.CloseSend())
+	require.Equal(t, codes.Internal, status.Code(stream.RecvMsg(&wire.WriteSummary{})))
+
+	// the other interfaces are written nonetheless
+	require.NotEmpty(t, dbFiles(t, filepath.Join(aggregatorPath, identity.Hostname, "eth1")))
+}
+
+// writeCertificate writes a self-signed certificate for localhost (which serves as its own CA) and
+// its key to dir
+func writeCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.Nil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.Nil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func TestAggregatorAuth(t *testing.T) {
+	certFile, keyFile := writeCertificate(t, t.TempDir())
+	serverTLS, err := (&TLSConfig{CertFile: certFile, KeyFile: keyFile, CAFile: certFile}).ServerConfig()
+	require.Nil(t, err)
+	require.Equal(t, tls.RequireAndVerifyClientCert, serverTLS.ClientAuth)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	server := NewServer(t.TempDir(), encoders.EncoderTypeNull, WithServerTLS(serverTLS), WithServerToken("s3cr3t"))
+	go func() {
+		require.Nil(t, server.Serve(listener))
+	}()
+	defer server.Shutdown()
+
+	var tests = []struct {
+		name     string
+		tls      TLSConfig
+		token    string
+		expected codes.Code
+	}{
+		{"valid", TLSConfig{CAFile: certFile, CertFile: certFile, KeyFile: keyFile}, "s3cr3t", codes.OK},
+		{"invalid token", TLSConfig{CAFile: certFile, CertFile: certFile, KeyFile: keyFile}, "guess", codes.Unauthenticated},
+		{"missing token", TLSConfig{CAFile: certFile, CertFile: certFile, KeyFile: keyFile}, "", codes.Unauthenticated},
+		{"missing client certificate", TLSConfig{CAFile: certFile}, "s3cr3t", codes.Unavailable},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clientTLS, err := test.tls.ClientConfig()
+			require.Nil(t, err)
+
+			client, err := NewClient(listener.Addr().String(), info.Identity{Hostname: "sensor-01"},
+				WithClientTLS(clientTLS), WithClientToken(test.token))
+			require.Nil(t, err)
+			defer client.Close()
+
+			stream, err := client.conn.NewStream(context.Background(), &serviceDesc.Streams[0], writeMethod)
+			if err == nil {
+				require.Nil(t, client.send(stream, time.Now(), capturetypes.TaggedAggFlowMap{Iface: "eth0"}))
+				require.Nil(t, stream.CloseSend())
+				err = stream.RecvMsg(&wire.WriteSummary{})
+			}
+			require.Equal(t, test.expected, status.Code(err))
+		})
+	}
+
+	// certificate and key must be provided together
+	require.ErrorIs(t, (&TLSConfig{CertFile: certFile}).Validate(), ErrInvalidTLSConfig)
+	_, err = (&TLSConfig{CAFile: certFile}).ServerConfig()
+	require.ErrorIs(t, err, ErrInvalidTLSConfig)
+}
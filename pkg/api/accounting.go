@@ -0,0 +1,225 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	queryResultContextKey = "queryResult"
+
+	// keyIDLen denotes the number of hex characters of the SHA256 hash of a key identifying it
+	keyIDLen = 12
+
+	secondsPerDay = 24 * 60 * 60
+)
+
+// ErrQuotaExceeded is returned if an API key has used up its daily query quota
+var ErrQuotaExceeded = errors.New("daily query quota of API key exceeded")
+
+// KeyID returns the identifier of an API key by which its usage is reported (the leading characters of
+// the hex encoded SHA256 hash of the key), so that the key itself isn't revealed
+func KeyID(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])[:keyIDLen]
+}
+
+// KeyQuota denotes the daily limits of the queries run with an API key. Limits which aren't set are
+// not enforced
+type KeyQuota struct {
+	Queries      uint64 `json:"queries,omitempty" yaml:"queries,omitempty"`             // Queries: maximum number of queries per day. Example: 1000
+	BytesScanned uint64 `json:"bytes_scanned,omitempty" yaml:"bytes_scanned,omitempty"` // BytesScanned: maximum number of (decompressed) bytes read from the DB per day. Example: 107374182400
+	Runtime      int    `json:"runtime,omitempty" yaml:"runtime,omitempty"`             // Runtime: maximum accumulated runtime of the queries per day in seconds. Example: 3600
+}
+
+// exceededBy returns which limit of the quota (if any) is exhausted by the usage
+func (q KeyQuota) exceededBy(u KeyUsage) string {
+	switch {
+	case q.Queries > 0 && u.Queries >= q.Queries:
+		return fmt.Sprintf("%d queries", q.Queries)
+	case q.BytesScanned > 0 && u.BytesScanned >= q.BytesScanned:
+		return fmt.Sprintf("%d bytes scanned", q.BytesScanned)
+	case q.Runtime > 0 && u.Runtime >= time.Duration(q.Runtime)*time.Second:
+		return fmt.Sprintf("%ds runtime", q.Runtime)
+	}
+	return ""
+}
+
+// KeyUsage denotes the resources consumed by the queries run with an API key
+type KeyUsage struct {
+	Queries      uint64        `json:"queries"`       // Queries: the number of queries run. Example: 42
+	Rejected     uint64        `json:"rejected"`      // Rejected: the number of queries rejected because the quota was exceeded. Example: 0
+	BytesScanned uint64        `json:"bytes_scanned"` // BytesScanned: the number of (decompressed) bytes read from the DB. Example: 52428800
+	Runtime      time.Duration `json:"runtime_ns"`    // Runtime: the accumulated runtime of the queries in nanoseconds. Example: 1500000000
+}
+
+func (u KeyUsage) add(u2 KeyUsage) KeyUsage {
+	return KeyUsage{
+		Queries:      u.Queries + u2.Queries,
+		Rejected:     u.Rejected + u2.Rejected,
+		BytesScanned: u.BytesScanned + u2.BytesScanned,
+		Runtime:      u.Runtime + u2.Runtime,
+	}
+}
+
+// KeyAccount summarizes the usage of an API key
+type KeyAccount struct {
+	Key   string    `json:"key"`             // Key: the identifier of the API key (see KeyID()). Example: 9f86d081884c
+	Today KeyUsage  `json:"today"`           // Today: the usage of the current day (UTC), which the quota is applied to
+	Total KeyUsage  `json:"total"`           // Total: the usage since the server was started
+	Quota *KeyQuota `json:"quota,omitempty"` // Quota: the daily quota of the key (if any)
+}
+
+// UsageResponse is the response to a usage request
+type UsageResponse struct {
+	StatusCode int          `json:"status_code"` // StatusCode: stores the HTTP status code of the response. Example: 200
+	Keys       []KeyAccount `json:"keys"`        // Keys: the usage of all API keys which ran queries (or have a quota)
+}
+
+type keyAccount struct {
+	today, total KeyUsage
+}
+
+// Accounting tracks the queries run per API key and enforces their daily quotas (reset at midnight UTC)
+type Accounting struct {
+	sync.Mutex
+
+	quotas   map[string]KeyQuota
+	accounts map[string]*keyAccount
+	day      int64
+
+	now func() time.Time
+}
+
+// NewAccounting creates a new accounting of the queries run per API key, enforcing the quotas of the
+// keys listed in quotas
+func NewAccounting(quotas map[string]KeyQuota) *Accounting {
+	return &Accounting{
+		quotas:   quotas,
+		accounts: make(map[string]*keyAccount),
+		now:      time.Now,
+	}
+}
+
+// account returns the account of the key, resetting the daily usage of all keys once a new day started.
+// The caller must hold the lock
+func (a *Accounting) account(key string) *keyAccount {
+	if day := a.now().Unix() / secondsPerDay; day != a.day {
+		for _, acc := range a.accounts {
+			acc.today = KeyUsage{}
+		}
+		a.day = day
+	}
+	acc, exists := a.accounts[key]
+	if !exists {
+		acc = &keyAccount{}
+		a.accounts[key] = acc
+	}
+	return acc
+}
+
+// Admit checks whether the key may run another query today. If its quota is exceeded, the query is
+// accounted as rejected and ErrQuotaExceeded is returned
+func (a *Accounting) Admit(key string) error {
+	a.Lock()
+	defer a.Unlock()
+
+	acc := a.account(key)
+	quota, hasQuota := a.quotas[key]
+	if !hasQuota {
+		return nil
+	}
+	if limit := quota.exceededBy(acc.today); limit != "" {
+		acc.today.Rejected++
+		acc.total.Rejected++
+		return fmt.Errorf("%w (limit: %s)", ErrQuotaExceeded, limit)
+	}
+	return nil
+}
+
+// Record accounts the resources consumed by a query run with the key
+func (a *Accounting) Record(key string, usage KeyUsage) {
+	a.Lock()
+	defer a.Unlock()
+
+	acc := a.account(key)
+	acc.today = acc.today.add(usage)
+	acc.total = acc.total.add(usage)
+}
+
+// Accounts returns the usage of all keys which ran queries or have a quota (ordered by their identifier)
+func (a *Accounting) Accounts() []KeyAccount {
+	a.Lock()
+	defer a.Unlock()
+
+	for key := range a.quotas {
+		a.account(key)
+	}
+
+	accounts := make([]KeyAccount, 0, len(a.accounts))
+	for key, acc := range a.accounts {
+		account := KeyAccount{
+			Key:   KeyID(key),
+			Today: acc.today,
+			Total: acc.total,
+		}
+		if quota, hasQuota := a.quotas[key]; hasQuota {
+			account.Quota = &quota
+		}
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Key < accounts[j].Key
+	})
+	return accounts
+}
+
+// AccountingMiddleware accounts the queries run with each API key (see APIKey()) and rejects them with
+// status 429 once the daily quota of the key is exceeded. Requests without an API key aren't accounted
+func AccountingMiddleware(accounting *Accounting) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, authorized := APIKey(c)
+		if !authorized {
+			c.Next()
+			return
+		}
+		if err := accounting.Admit(key); err != nil {
+			LogAndAbort(c.Request.Context(), c, http.StatusTooManyRequests, err)
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		usage := KeyUsage{
+			Queries: 1,
+			Runtime: time.Since(start),
+		}
+		if res, exists := c.Get(queryResultContextKey); exists {
+			if resources := res.(*results.Result).Summary.Resources; resources != nil {
+				usage.BytesScanned = resources.BytesDecompressed
+			}
+		}
+		accounting.Record(key, usage)
+	}
+}
+
+// UsageHandler serves the usage of all API keys
+func UsageHandler(accounting *Accounting) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		resp := &UsageResponse{
+			StatusCode: http.StatusOK,
+			Keys:       accounting.Accounts(),
+		}
+		c.JSON(resp.StatusCode, resp)
+	}
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccounting(t *testing.T) {
+	now := time.Date(2024, 1, 8, 23, 0, 0, 0, time.UTC)
+	a := NewAccounting(map[string]KeyQuota{
+		"limited": {Queries: 2, BytesScanned: 1000},
+	})
+	a.now = func() time.Time { return now }
+
+	// keys without quota are accounted, but never rejected
+	for i := 0; i < 5; i++ {
+		require.Nil(t, a.Admit("unlimited"))
+		a.Record("unlimited", KeyUsage{Queries: 1, BytesScanned: 1000, Runtime: time.Second})
+	}
+
+	// the query quota is exhausted after two queries
+	for i := 0; i < 2; i++ {
+		require.Nil(t, a.Admit("limited"))
+		a.Record("limited", KeyUsage{Queries: 1, BytesScanned: 100})
+	}
+	require.ErrorIs(t, a.Admit("limited"), ErrQuotaExceeded)
+
+	accounts := a.Accounts()
+	require.Len(t, accounts, 2)
+	for _, account := range accounts {
+		switch account.Key {
+		case KeyID("limited"):
+			require.Equal(t, KeyUsage{Queries: 2, Rejected: 1, BytesScanned: 200}, account.Today)
+			require.Equal(t, account.Today, account.Total)
+			require.Equal(t, &KeyQuota{Queries: 2, BytesScanned: 1000}, account.Quota)
+		case KeyID("unlimited"):
+			require.Equal(t, KeyUsage{Queries: 5, BytesScanned: 5000, Runtime: 5 * time.Second}, account.Today)
+			require.Nil(t, account.Quota)
+		default:
+			t.Fatalf("unexpected key %s", account.Key)
+		}
+	}
+
+	// the daily usage is reset at midnight (UTC), the total usage is retained
+	now = now.Add(2 * time.Hour)
+	require.Nil(t, a.Admit("limited"))
+	a.Record("limited", KeyUsage{Queries: 1, BytesScanned: 1000})
+
+	// the bytes quota is exhausted as well
+	require.ErrorIs(t, a.Admit("limited"), ErrQuotaExceeded)
+	for _, account := range a.Accounts() {
+		if account.Key == KeyID("limited") {
+			require.Equal(t, KeyUsage{Queries: 1, Rejected: 1, BytesScanned: 1000}, account.Today)
+			require.Equal(t, KeyUsage{Queries: 3, Rejected: 2, BytesScanned: 1200}, account.Total)
+		}
+	}
+}
+
+func TestAccountingRuntimeQuota(t *testing.T) {
+	a := NewAccounting(map[string]KeyQuota{
+		"limited": {Runtime: 2},
+	})
+
+	// the runtime quota is given in seconds
+	require.Nil(t, a.Admit("limited"))
+	a.Record("limited", KeyUsage{Queries: 1, Runtime: 1500 * time.Millisecond})
+	require.Nil(t, a.Admit("limited"))
+	a.Record("limited", KeyUsage{Queries: 1, Runtime: 500 * time.Millisecond})
+	require.ErrorIs(t, a.Admit("limited"), ErrQuotaExceeded)
+}
+
+func TestAccountingMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	res := results.New()
+	res.Summary.Resources = &results.Resources{BytesDecompressed: 4096}
+	runner := &staticRunner{result: res}
+
+	accounting := NewAccounting(map[string]KeyQuota{"key1": {Queries: 1}})
+	router := gin.New()
+	router.Use(AuthMiddleware("key1", "key2"))
+	router.POST("/query", AccountingMiddleware(accounting), func(c *gin.Context) {
+		RunQuery("test", "static", runner, c)
+	})
+	router.GET("/usage", UsageHandler(accounting))
+
+	request := func(method, path, key string) *httptest.ResponseRecorder {
+		var body []byte
+		if method == http.MethodPost {
+			var err error
+			body, err = jsoniter.Marshal(query.NewArgs("sip", "eth0"))
+			require.Nil(t, err)
+		}
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(authHeaderKey, authTokenPrefix+key)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, request(http.MethodPost, "/query", "key1").Code)
+	require.Equal(t, http.StatusTooManyRequests, request(http.MethodPost, "/query", "key1").Code)
+	require.Equal(t, http.StatusOK, request(http.MethodPost, "/query", "key2").Code)
+
+	w := request(http.MethodGet, "/usage", "key2")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp UsageResponse
+	require.Nil(t, jsoniter.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Keys, 2)
+	for _, account := range resp.Keys {
+		require.NotContains(t, []string{"key1", "key2"}, account.Key)
+		require.EqualValues(t, 1, account.Today.Queries)
+		require.EqualValues(t, 4096, account.Today.BytesScanned)
+		if account.Key == KeyID("key1") {
+			require.EqualValues(t, 1, account.Today.Rejected)
+		}
+	}
+}
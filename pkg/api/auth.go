@@ -0,0 +1,136 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	authHeaderKey    = "Authorization"
+	authTokenPrefix  = "digest "
+	apiKeyContextKey = "apiKey"
+)
+
+var (
+	// ErrUnauthorized is returned if a request doesn't carry a valid API key
+	ErrUnauthorized = errors.New("missing or invalid API key")
+
+	// ErrForbiddenTenant is returned if an API key isn't permitted to query a tenant
+	ErrForbiddenTenant = errors.New("API key is not permitted to query tenant")
+
+	// ErrForbiddenRole is returned if an API key lacks the role required by an endpoint
+	ErrForbiddenRole = errors.New("API key is not permitted to access endpoint")
+
+	// ErrInvalidRole is returned if a role is unknown
+	ErrInvalidRole = errors.New("invalid role")
+)
+
+// Role denotes the scope of access granted to an API key
+type Role string
+
+const (
+	// RoleRead permits read-only access, e.g. running queries or fetching the capture status
+	RoleRead Role = "read"
+
+	// RoleAdmin permits full access, including endpoints modifying the running capture (such
+	// as updating or reloading the configuration)
+	RoleAdmin Role = "admin"
+)
+
+// Validate checks whether the role is known
+func (r Role) Validate() error {
+	switch r {
+	case RoleRead, RoleAdmin:
+		return nil
+	}
+	return fmt.Errorf("%w `%s` (permitted: %s,%s)", ErrInvalidRole, r, RoleRead, RoleAdmin)
+}
+
+// Permits checks whether the role grants access to endpoints requiring role required
+func (r Role) Permits(required Role) bool {
+	return r == RoleAdmin || r == required
+}
+
+// AuthMiddleware rejects all requests that don't carry one of the keys (as sent by the
+// API clients, i.e. "Authorization: digest <key>"). The key is made available to subsequent
+// handlers via APIKey()
+func AuthMiddleware(keys ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, found := strings.CutPrefix(c.GetHeader(authHeaderKey), authTokenPrefix)
+		if !found || !containsKey(keys, token) {
+			LogAndAbort(c.Request.Context(), c, http.StatusUnauthorized, ErrUnauthorized)
+			return
+		}
+		c.Set(apiKeyContextKey, token)
+		c.Next()
+	}
+}
+
+// APIKey returns the API key the request was authorized with (if any)
+func APIKey(c *gin.Context) (string, bool) {
+	return c.GetString(apiKeyContextKey), c.GetString(apiKeyContextKey) != ""
+}
+
+// ArgsCheck authorizes the query arguments of a request. It is run before the query is
+// executed and causes the request to be rejected with status 403 if it returns an error
+type ArgsCheck func(c *gin.Context, args *query.Args) error
+
+// TenantScope restricts the tenants an API key may query to the ones listed in keyTenants.
+// Keys that aren't listed may query any tenant
+func TenantScope(keyTenants map[string][]string) ArgsCheck {
+	return func(c *gin.Context, args *query.Args) error {
+		key, authorized := APIKey(c)
+		if !authorized {
+			return nil
+		}
+		tenants, restricted := keyTenants[key]
+		if !restricted || slices.Contains(tenants, args.Tenant) {
+			return nil
+		}
+		if args.Tenant == "" {
+			return fmt.Errorf("%w: no tenant specified (permitted: %s)", ErrForbiddenTenant, strings.Join(tenants, ","))
+		}
+		return fmt.Errorf("%w `%s`", ErrForbiddenTenant, args.Tenant)
+	}
+}
+
+// RequireRole rejects requests with status 403 if the API key they were authorized with lacks
+// the role required. Roles are assigned to keys via keyRoles. Keys that aren't listed are granted
+// RoleRead only, hence RoleAdmin (e.g. for a key restricted to tenants) has to be assigned explicitly.
+// All requests are granted RoleAdmin if API keys aren't enabled
+func RequireRole(keyRoles map[string]Role, required Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, authorized := APIKey(c)
+		if !authorized {
+			return
+		}
+		role, assigned := keyRoles[key]
+		if !assigned {
+			role = RoleRead
+		}
+		if role.Permits(required) {
+			return
+		}
+		LogAndAbort(c.Request.Context(), c, http.StatusForbidden,
+			fmt.Errorf("%w: role `%s` required (granted: %s)", ErrForbiddenRole, required, role),
+		)
+	}
+}
+
+// containsKey checks in constant time (per key) whether the token is among the keys
+func containsKey(keys []string, token string) bool {
+	var found bool
+	for _, key := range keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(token)) == 1 {
+			found = true
+		}
+	}
+	return found
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	keyRoles := map[string]Role{
+		"admin-key": RoleAdmin,
+		"read-key":  RoleRead,
+	}
+
+	var tests = []struct {
+		key      string
+		required Role
+		expected int
+	}{
+		{"admin-key", RoleAdmin, http.StatusOK},
+		{"admin-key", RoleRead, http.StatusOK},
+		{"read-key", RoleAdmin, http.StatusForbidden},
+		{"read-key", RoleRead, http.StatusOK},
+		{"unlisted-key", RoleAdmin, http.StatusForbidden},
+		{"unlisted-key", RoleRead, http.StatusOK},
+		{"", RoleAdmin, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.key+"/"+string(test.required), func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", func(c *gin.Context) {
+				if test.key != "" {
+					c.Set(apiKeyContextKey, test.key)
+				}
+			}, RequireRole(keyRoles, test.required), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			require.Equal(t, test.expected, rec.Code)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package client
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
 	"net/http"
@@ -238,3 +239,19 @@ func (c *DefaultClient) Modify(_ context.Context, req *httpc.Request) *httpc.Req
 func (c *DefaultClient) NewURL(path string) string {
 	return c.scheme + filepath.Join(c.hostAddr, path)
 }
+
+// maxErrorBodyLen limits the amount of the response body included in an error
+const maxErrorBodyLen = 512
+
+// QueryErrorFn converts a failed query response into an error. If the status code
+// denotes a known error class (see api.ErrorFromStatusCode), the error wraps it so
+// that callers can branch on it using errors.Is()
+func QueryErrorFn(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyLen))
+
+	class := api.ErrorFromStatusCode(resp.StatusCode)
+	if class == nil {
+		return fmt.Errorf("%s [body=%s]", resp.Status, body)
+	}
+	return fmt.Errorf("%w: %s [body=%s]", class, resp.Status, body)
+}
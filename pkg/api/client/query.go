@@ -0,0 +1,55 @@
+package client
+
+import (
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/wire"
+	"github.com/fako1024/httpc"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// argsEncoder encodes query arguments in the wire format
+type argsEncoder struct {
+	args *query.Args
+}
+
+// Encode fulfills the httpc.Encoder interface, performing the actual encoding
+func (e argsEncoder) Encode() ([]byte, error) {
+	return wire.MarshalArgs(e.args)
+}
+
+// ContentType fulfills the httpc.Encoder interface, providing the required content-type header
+func (e argsEncoder) ContentType() string {
+	return wire.ContentType
+}
+
+// EncodeArgs returns an encoder for the query arguments in the wire format
+func EncodeArgs(args *query.Args) httpc.Encoder {
+	return argsEncoder{args: args}
+}
+
+// AcceptResult requests the result of a query in the wire format (falling back to JSON for servers
+// not supporting it)
+func AcceptResult(req *http.Request) error {
+	req.Header.Set("Accept", wire.ContentType+", application/json;q=0.9")
+	return nil
+}
+
+// ParseResult parses the result of a query, depending on its content type either in the wire format
+// or as JSON
+func ParseResult(res *results.Result) func(*http.Response) error {
+	return func(resp *http.Response) error {
+		if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != wire.ContentType {
+			return jsoniter.NewDecoder(resp.Body).Decode(res)
+		}
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return wire.UnmarshalResult(data, res)
+	}
+}
@@ -0,0 +1,244 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// supported content encodings
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
+// DefaultMaxRequestBodySize denotes the default maximum size of a decompressed request body in bytes
+const DefaultMaxRequestBodySize = 32 << 20 // 32 MiB
+
+var (
+	// ErrUnsupportedEncoding is returned if a request body is compressed with an unknown encoding
+	ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+
+	// ErrRequestBodyTooLarge is returned if a compressed request body exceeds the maximum size once
+	// decompressed
+	ErrRequestBodyTooLarge = errors.New("decompressed request body too large")
+)
+
+// content types which are already compressed (and hence aren't compressed again)
+var compressedContentTypes = []string{
+	"application/gzip",
+	"application/zip",
+	"application/zstd",
+}
+
+var (
+	gzipWriterPool = sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }}
+	zlibWriterPool = sync.Pool{New: func() any { return zlib.NewWriter(io.Discard) }}
+)
+
+// compressor is implemented by the gzip and zlib writers
+type compressor interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+	Flush() error
+}
+
+// CompressionMiddleware compresses responses with gzip or deflate if the client accepts them
+// (via the Accept-Encoding header) and transparently decompresses request bodies sent with a
+// gzip or deflate Content-Encoding. Decompressed request bodies exceeding maxBodySize bytes are
+// rejected (if non-positive, DefaultMaxRequestBodySize applies). Responses already carrying a
+// Content-Encoding (or being compressed archives) are passed through
+func CompressionMiddleware(maxBodySize int64) gin.HandlerFunc {
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxRequestBodySize
+	}
+	return func(c *gin.Context) {
+		if err := decompressBody(c.Request, maxBodySize); err != nil {
+			code := http.StatusBadRequest
+			switch {
+			case errors.Is(err, ErrUnsupportedEncoding):
+				code = http.StatusUnsupportedMediaType
+			case errors.Is(err, ErrRequestBodyTooLarge):
+				code = http.StatusRequestEntityTooLarge
+			}
+			LogAndAbort(c.Request.Context(), c, code, err)
+			return
+		}
+
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		cw := &compressedWriter{ResponseWriter: c.Writer, encoding: encoding}
+		c.Writer = cw
+		defer func() {
+			cw.close()
+			c.Writer = cw.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// decompressBody replaces the body of the request by its decompressed content if it is compressed.
+// The body is decompressed up front (reading at most maxBodySize bytes), so that an excessive body
+// (e.g. a decompression bomb) is rejected before it reaches the handler
+func decompressBody(req *http.Request, maxBodySize int64) error {
+	encoding := strings.ToLower(strings.TrimSpace(req.Header.Get("Content-Encoding")))
+	if req.Body == nil || req.Body == http.NoBody || encoding == "" || encoding == "identity" {
+		return nil
+	}
+
+	var (
+		body io.ReadCloser
+		err  error
+	)
+	switch encoding {
+	case encodingGzip:
+		body, err = gzip.NewReader(req.Body)
+	case encodingDeflate:
+		body, err = zlib.NewReader(req.Body)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedEncoding, encoding)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decompress %s request body: %w", encoding, err)
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, maxBodySize+1))
+	if err != nil {
+		return fmt.Errorf("failed to decompress %s request body: %w", encoding, err)
+	}
+	if int64(len(data)) > maxBodySize {
+		return fmt.Errorf("%w: exceeds %d bytes", ErrRequestBodyTooLarge, maxBodySize)
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.Header.Del("Content-Encoding")
+	req.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	req.ContentLength = int64(len(data))
+	return nil
+}
+
+// negotiateEncoding picks the encoding of the response from the Accept-Encoding header, preferring
+// gzip over deflate. If neither is accepted, no encoding is returned
+func negotiateEncoding(acceptEncoding string) string {
+	var gzipOK, deflateOK bool
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if weight, err := strconv.ParseFloat(q, 64); err != nil || weight <= 0 {
+				continue
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case encodingGzip, "*":
+			gzipOK = true
+		case encodingDeflate:
+			deflateOK = true
+		}
+	}
+
+	switch {
+	case gzipOK:
+		return encodingGzip
+	case deflateOK:
+		return encodingDeflate
+	}
+	return ""
+}
+
+// compressedWriter compresses the response body. Whether the response is compressed is decided
+// upon the first write, when the headers set by the handler are known
+type compressedWriter struct {
+	gin.ResponseWriter
+
+	encoding string
+	decided  bool
+	w        compressor
+}
+
+func (cw *compressedWriter) decide() {
+	if cw.decided {
+		return
+	}
+	cw.decided = true
+
+	header := cw.Header()
+	if header.Get("Content-Encoding") != "" || isCompressedContentType(header.Get("Content-Type")) {
+		return
+	}
+	if status := cw.Status(); status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		return
+	}
+
+	header.Set("Content-Encoding", cw.encoding)
+	header.Add("Vary", "Accept-Encoding")
+	header.Del("Content-Length")
+
+	if cw.encoding == encodingGzip {
+		cw.w = gzipWriterPool.Get().(*gzip.Writer)
+	} else {
+		cw.w = zlibWriterPool.Get().(*zlib.Writer)
+	}
+	cw.w.Reset(cw.ResponseWriter)
+}
+
+// Write compresses the data (if applicable) and writes it to the response
+func (cw *compressedWriter) Write(data []byte) (int, error) {
+	cw.decide()
+	if cw.w == nil {
+		return cw.ResponseWriter.Write(data)
+	}
+	return cw.w.Write(data)
+}
+
+// WriteString compresses the string (if applicable) and writes it to the response
+func (cw *compressedWriter) WriteString(s string) (int, error) {
+	return cw.Write([]byte(s))
+}
+
+// Flush flushes the compressed data written so far to the client
+func (cw *compressedWriter) Flush() {
+	if cw.w != nil {
+		_ = cw.w.Flush()
+	}
+	cw.ResponseWriter.Flush()
+}
+
+// close completes the compressed stream and returns the compressor to its pool
+func (cw *compressedWriter) close() {
+	if cw.w == nil {
+		return
+	}
+	_ = cw.w.Close()
+	cw.w.Reset(io.Discard)
+
+	switch w := cw.w.(type) {
+	case *gzip.Writer:
+		gzipWriterPool.Put(w)
+	case *zlib.Writer:
+		zlibWriterPool.Put(w)
+	}
+	cw.w = nil
+}
+
+func isCompressedContentType(contentType string) bool {
+	for _, ct := range compressedContentTypes {
+		if strings.HasPrefix(contentType, ct) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressionMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	payload := strings.Repeat("goProbe ", 1024)
+
+	router := gin.New()
+	router.Use(CompressionMiddleware(int64(len(payload))))
+	router.GET("/data", func(c *gin.Context) {
+		c.String(http.StatusOK, payload)
+	})
+	router.GET("/bundle", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/gzip", []byte(payload))
+	})
+	router.POST("/echo", func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		require.Nil(t, err)
+		c.Data(http.StatusOK, "text/plain", body)
+	})
+
+	request := func(method, path, acceptEncoding, contentEncoding string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		if contentEncoding != "" {
+			req.Header.Set("Content-Encoding", contentEncoding)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("gzip", func(t *testing.T) {
+		rec := request(http.MethodGet, "/data", "deflate, gzip;q=0.8", "", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+		require.Less(t, rec.Body.Len(), len(payload))
+
+		r, err := gzip.NewReader(rec.Body)
+		require.Nil(t, err)
+		data, err := io.ReadAll(r)
+		require.Nil(t, err)
+		require.Equal(t, payload, string(data))
+	})
+
+	t.Run("deflate", func(t *testing.T) {
+		rec := request(http.MethodGet, "/data", "deflate, gzip;q=0", "", nil)
+		require.Equal(t, "deflate", rec.Header().Get("Content-Encoding"))
+
+		r, err := zlib.NewReader(rec.Body)
+		require.Nil(t, err)
+		data, err := io.ReadAll(r)
+		require.Nil(t, err)
+		require.Equal(t, payload, string(data))
+	})
+
+	t.Run("identity", func(t *testing.T) {
+		for _, acceptEncoding := range []string{"", "br", "identity"} {
+			rec := request(http.MethodGet, "/data", acceptEncoding, "", nil)
+			require.Empty(t, rec.Header().Get("Content-Encoding"))
+			require.Equal(t, payload, rec.Body.String())
+		}
+	})
+
+	t.Run("already compressed", func(t *testing.T) {
+		rec := request(http.MethodGet, "/bundle", "gzip", "", nil)
+		require.Empty(t, rec.Header().Get("Content-Encoding"))
+		require.Equal(t, payload, rec.Body.String())
+	})
+
+	t.Run("compressed request body", func(t *testing.T) {
+		var gzipped, deflated bytes.Buffer
+		gw := gzip.NewWriter(&gzipped)
+		_, err := gw.Write([]byte(payload))
+		require.Nil(t, err)
+		require.Nil(t, gw.Close())
+		zw := zlib.NewWriter(&deflated)
+		_, err = zw.Write([]byte(payload))
+		require.Nil(t, err)
+		require.Nil(t, zw.Close())
+
+		for encoding, body := range map[string][]byte{"gzip": gzipped.Bytes(), "deflate": deflated.Bytes(), "": []byte(payload)} {
+			rec := request(http.MethodPost, "/echo", "", encoding, body)
+			require.Equal(t, http.StatusOK, rec.Code)
+			require.Equal(t, payload, rec.Body.String())
+		}
+
+		rec := request(http.MethodPost, "/echo", "", "br", []byte(payload))
+		require.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+		rec = request(http.MethodPost, "/echo", "", "gzip", []byte(payload))
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+
+		// bodies exceeding the maximum size once decompressed are rejected
+		var bomb bytes.Buffer
+		gw = gzip.NewWriter(&bomb)
+		_, err = gw.Write([]byte(payload + "!"))
+		require.Nil(t, err)
+		require.Nil(t, gw.Close())
+		rec = request(http.MethodPost, "/echo", "", "gzip", bomb.Bytes())
+		require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+}
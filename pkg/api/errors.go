@@ -0,0 +1,39 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/els0r/goProbe/pkg/query"
+)
+
+// StatusCodeFromError maps an error returned by query preparation / execution onto the
+// HTTP status code it should be reported with
+func StatusCodeFromError(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case query.IsClientError(err):
+		return http.StatusBadRequest
+	case errors.Is(err, query.ErrQueryTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, query.ErrMemoryBreach):
+		return http.StatusInsufficientStorage
+	}
+	return http.StatusInternalServerError
+}
+
+// ErrorFromStatusCode returns the error class matching a status code returned by a query
+// endpoint (the inverse of StatusCodeFromError), allowing clients to branch on it using errors.Is()
+func ErrorFromStatusCode(code int) error {
+	switch code {
+	case http.StatusBadRequest:
+		return query.ErrInvalidArgs
+	case http.StatusGatewayTimeout:
+		return query.ErrQueryTimeout
+	case http.StatusInsufficientStorage:
+		return query.ErrMemoryBreach
+	}
+	return nil
+}
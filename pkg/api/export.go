@@ -0,0 +1,267 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/s3"
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	exporterContextKey = "exporter"
+
+	s3Scheme   = "s3://"
+	fileScheme = "file://"
+
+	exportContentType = "application/json"
+)
+
+var (
+	// ErrExportDisabled is returned if the export of a query result is requested from a server
+	// without configured export destinations
+	ErrExportDisabled = errors.New("export of query results not enabled")
+
+	// ErrInvalidExportDestination is returned if the requested export destination isn't permitted
+	// by the configuration of the server
+	ErrInvalidExportDestination = errors.New("invalid export destination")
+
+	// ErrInvalidExportConfig is returned if the export configuration lacks a destination or is incomplete
+	ErrInvalidExportConfig = errors.New("invalid export config")
+)
+
+// ExportConfig denotes the destinations the results of queries may be exported to on the server side
+// (see query.Args.Export), instead of returning them to the client
+type ExportConfig struct {
+	// Path: directory results may be exported to. Local destinations are relative to it and must not
+	// leave it. Example: /var/lib/goprobe/exports
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+
+	// S3: object store results may be exported to (via s3://<bucket>/<key>)
+	S3 *S3ExportConfig `json:"s3,omitempty" yaml:"s3,omitempty"`
+}
+
+// S3ExportConfig denotes an S3 compatible object store results may be exported to
+type S3ExportConfig struct {
+	// Endpoint: URL of the object store. Objects are addressed path-style. Example: https://s3.eu-central-1.amazonaws.com
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+
+	// Region: region of the buckets. Example: eu-central-1
+	Region string `json:"region" yaml:"region"`
+
+	// Buckets: buckets results may be exported to. If empty, any bucket may be used. Example: ["results"]
+	Buckets []string `json:"buckets,omitempty" yaml:"buckets,omitempty"`
+
+	// AccessKeyID and SecretAccessKey: credentials used to sign requests
+	AccessKeyID     string `json:"access_key_id" yaml:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key" yaml:"secret_access_key"`
+}
+
+// Validate checks that the configuration permits at least one (complete) destination
+func (c ExportConfig) Validate() error {
+	if c.Path == "" && c.S3 == nil {
+		return fmt.Errorf("%w: neither path nor S3 configured", ErrInvalidExportConfig)
+	}
+	if c.S3 != nil && (c.S3.Endpoint == "" || c.S3.Region == "") {
+		return fmt.Errorf("%w: S3 endpoint and region required", ErrInvalidExportConfig)
+	}
+	return nil
+}
+
+// ExportHandle references a query result exported on the server side
+type ExportHandle struct {
+	Destination string          `json:"destination"` // Destination: the destination as requested. Example: s3://results/top-talkers.json
+	Location    string          `json:"location"`    // Location: where the result was stored (the S3 URI or the path on the server). Example: s3://results/top-talkers.json
+	Size        int64           `json:"size"`        // Size: the size of the exported result in bytes. Example: 1073741824
+	Rows        int             `json:"rows"`        // Rows: the number of rows of the exported result. Example: 250000
+	Summary     results.Summary `json:"summary"`     // Summary: the summary of the exported result
+}
+
+// Exporter writes query results to the destinations permitted by its configuration
+type Exporter struct {
+	path string
+
+	s3        *s3.Client
+	s3Buckets []string
+}
+
+// NewExporter creates a new exporter from its configuration
+func NewExporter(cfg ExportConfig) *Exporter {
+	e := &Exporter{
+		path: cfg.Path,
+	}
+	if cfg.S3 != nil {
+		e.s3 = s3.New(cfg.S3.Endpoint, cfg.S3.Region, cfg.S3.AccessKeyID, cfg.S3.SecretAccessKey)
+		e.s3Buckets = cfg.S3.Buckets
+	}
+	return e
+}
+
+// exportTarget denotes a validated export destination (either a local path or an S3 object)
+type exportTarget struct {
+	path        string
+	bucket, key string
+}
+
+func (t exportTarget) location() string {
+	if t.path != "" {
+		return t.path
+	}
+	return s3Scheme + t.bucket + "/" + t.key
+}
+
+// target validates the destination of an export, which is either an S3 URI (s3://<bucket>/<key>)
+// or a path relative to the export directory (optionally prefixed by file://). The results of the
+// queries of a tenant are namespaced by it, i.e. they are stored below @<tenant>/ in the export
+// directory or bucket (see info.TenantPath()), which untenanted destinations must not start with
+func (e *Exporter) target(destination, tenant string) (exportTarget, error) {
+	if err := info.ValidateTenant(tenant); err != nil {
+		return exportTarget{}, fmt.Errorf("%w: %w", ErrInvalidExportDestination, err)
+	}
+	namespace := func(path string) (string, error) {
+		if tenant != "" {
+			// the namespace of the tenant must not be left (e.g. via .. elements of S3 keys)
+			if !filepath.IsLocal(path) {
+				return "", fmt.Errorf("%w: `%s` leaves the namespace of tenant `%s`", ErrInvalidExportDestination, destination, tenant)
+			}
+			return info.TenantDirPrefix + tenant + "/" + path, nil
+		}
+		if strings.HasPrefix(path, info.TenantDirPrefix) {
+			return "", fmt.Errorf("%w: `%s` is reserved for the results of tenants", ErrInvalidExportDestination, destination)
+		}
+		return path, nil
+	}
+
+	if bucketKey, isS3 := strings.CutPrefix(destination, s3Scheme); isS3 {
+		if e.s3 == nil {
+			return exportTarget{}, fmt.Errorf("%w: export to S3 not enabled", ErrInvalidExportDestination)
+		}
+		bucket, key, _ := strings.Cut(bucketKey, "/")
+		if bucket == "" || key == "" {
+			return exportTarget{}, fmt.Errorf("%w: `%s` lacks bucket or key", ErrInvalidExportDestination, destination)
+		}
+		if len(e.s3Buckets) > 0 && !slices.Contains(e.s3Buckets, bucket) {
+			return exportTarget{}, fmt.Errorf("%w: bucket `%s` not permitted", ErrInvalidExportDestination, bucket)
+		}
+		key, err := namespace(key)
+		if err != nil {
+			return exportTarget{}, err
+		}
+		return exportTarget{bucket: bucket, key: key}, nil
+	}
+
+	if e.path == "" {
+		return exportTarget{}, fmt.Errorf("%w: export to local paths not enabled", ErrInvalidExportDestination)
+	}
+	path := strings.TrimPrefix(destination, fileScheme)
+	if !filepath.IsLocal(path) {
+		return exportTarget{}, fmt.Errorf("%w: `%s` isn't a path within the export directory", ErrInvalidExportDestination, destination)
+	}
+	path, err := namespace(filepath.Clean(path))
+	if err != nil {
+		return exportTarget{}, err
+	}
+	return exportTarget{path: filepath.Join(e.path, filepath.FromSlash(path))}, nil
+}
+
+// Export writes the result (in JSON format) of a query of the tenant (if any) to the destination,
+// returning a handle referencing it
+func (e *Exporter) Export(ctx context.Context, destination, tenant string, result *results.Result) (*ExportHandle, error) {
+	target, err := e.target(destination, tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	var size int64
+	encode := func(w io.Writer) error {
+		cw := &countingWriter{w: w}
+		if err := jsoniter.NewEncoder(cw).Encode(result); err != nil {
+			return fmt.Errorf("failed to encode result: %w", err)
+		}
+		size = cw.n
+		return nil
+	}
+
+	if target.path != "" {
+		if err := os.MkdirAll(filepath.Dir(target.path), 0750); err != nil {
+			return nil, fmt.Errorf("failed to create export directory: %w", err)
+		}
+		if err := query.WriteFileAtomic(target.path, encode); err != nil {
+			return nil, err
+		}
+	} else if err := e.exportS3(ctx, target, encode); err != nil {
+		return nil, err
+	}
+
+	return &ExportHandle{
+		Destination: destination,
+		Location:    target.location(),
+		Size:        size,
+		Rows:        len(result.Rows),
+		Summary:     result.Summary,
+	}, nil
+}
+
+// exportS3 uploads the result to S3. Since large results shouldn't be held in memory twice, the
+// result is spooled to a temporary file first (which also provides the payload hash required for
+// signing the upload)
+func (e *Exporter) exportS3(ctx context.Context, target exportTarget, encode func(w io.Writer) error) error {
+	f, err := os.CreateTemp("", "goprobe-export-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary export file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+
+	if err := encode(f); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := e.s3.Put(ctx, target.bucket, target.key, exportContentType, f); err != nil {
+		return fmt.Errorf("failed to upload result: %w", err)
+	}
+	return nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ExportMiddleware allows the queries handled by the subsequent handlers to export their results
+// using the exporter (see RunQuery())
+func ExportMiddleware(exporter *Exporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(exporterContextKey, exporter)
+		c.Next()
+	}
+}
+
+// exporterFromContext returns the exporter set by the ExportMiddleware (if any)
+func exporterFromContext(c *gin.Context) (*Exporter, bool) {
+	exporter, exists := c.Get(exporterContextKey)
+	if !exists {
+		return nil, false
+	}
+	return exporter.(*Exporter), true
+}
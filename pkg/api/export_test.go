@@ -0,0 +1,163 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportTarget(t *testing.T) {
+	exporter := NewExporter(ExportConfig{
+		Path: "/var/lib/goprobe/exports",
+		S3:   &S3ExportConfig{Endpoint: "https://s3.example.com", Region: "eu-central-1", Buckets: []string{"results"}},
+	})
+
+	for destination, expected := range map[string]string{
+		"top-talkers.json":              "/var/lib/goprobe/exports/top-talkers.json",
+		"file://daily/top-talkers.json": "/var/lib/goprobe/exports/daily/top-talkers.json",
+		"s3://results/daily/top.json":   "s3://results/daily/top.json",
+	} {
+		target, err := exporter.target(destination, "")
+		require.Nil(t, err, destination)
+		require.Equal(t, expected, target.location())
+	}
+
+	// the results of tenants are namespaced
+	for destination, expected := range map[string]string{
+		"top-talkers.json":              "/var/lib/goprobe/exports/@acme/top-talkers.json",
+		"file://daily/top-talkers.json": "/var/lib/goprobe/exports/@acme/daily/top-talkers.json",
+		"s3://results/daily/top.json":   "s3://results/@acme/daily/top.json",
+	} {
+		target, err := exporter.target(destination, "acme")
+		require.Nil(t, err, destination)
+		require.Equal(t, expected, target.location())
+	}
+	for destination, tenant := range map[string]string{
+		"@acme/top-talkers.json":        "",
+		"s3://results/@acme/top.json":   "",
+		"s3://results/../@other/x.json": "acme",
+		"s3://results//top.json":        "acme",
+		"top-talkers.json":              "../acme",
+	} {
+		_, err := exporter.target(destination, tenant)
+		require.ErrorIs(t, err, ErrInvalidExportDestination, destination)
+	}
+
+	for _, destination := range []string{
+		"/etc/passwd",
+		"../top-talkers.json",
+		"file:///etc/passwd",
+		"daily/../../top-talkers.json",
+		"",
+		"s3://other/top.json",
+		"s3://results",
+		"s3://results/",
+	} {
+		_, err := exporter.target(destination, "")
+		require.ErrorIs(t, err, ErrInvalidExportDestination, destination)
+	}
+
+	// destinations which aren't configured are rejected
+	_, err := NewExporter(ExportConfig{Path: "/tmp"}).target("s3://results/top.json", "")
+	require.ErrorIs(t, err, ErrInvalidExportDestination)
+	_, err = NewExporter(ExportConfig{S3: &S3ExportConfig{}}).target("top.json", "")
+	require.ErrorIs(t, err, ErrInvalidExportDestination)
+
+	require.ErrorIs(t, ExportConfig{}.Validate(), ErrInvalidExportConfig)
+	require.ErrorIs(t, ExportConfig{S3: &S3ExportConfig{Region: "eu-central-1"}}.Validate(), ErrInvalidExportConfig)
+	require.Nil(t, ExportConfig{Path: "/tmp"}.Validate())
+}
+
+func TestRunQueryExport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	res := results.New()
+	res.Start()
+	res.Rows = results.Rows{{
+		Attributes: results.Attributes{SrcIP: netip.MustParseAddr("10.0.0.1")},
+		Counters:   types.Counters{BytesRcvd: 42},
+	}}
+	res.End()
+
+	var uploaded []byte
+	s3Server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		uploaded, _ = io.ReadAll(r.Body)
+	}))
+	defer s3Server.Close()
+
+	exportDir := t.TempDir()
+	runner := &staticRunner{result: res}
+	newRouter := func(exporter *Exporter) *gin.Engine {
+		router := gin.New()
+		if exporter != nil {
+			router.Use(ExportMiddleware(exporter))
+		}
+		router.POST("/query", func(c *gin.Context) {
+			RunQuery("test", "static", runner, c)
+		})
+		return router
+	}
+	router := newRouter(NewExporter(ExportConfig{
+		Path: exportDir,
+		S3:   &S3ExportConfig{Endpoint: s3Server.URL, Region: "eu-central-1"},
+	}))
+
+	request := func(router *gin.Engine, export string) *httptest.ResponseRecorder {
+		runner.args = nil
+		body, err := jsoniter.Marshal(query.NewArgs("sip", "eth0", query.WithExport(export)))
+		require.Nil(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/query", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// the result is written to the destination, the response only holds a handle
+	for destination, read := range map[string]func() []byte{
+		"daily/top-talkers.json": func() []byte {
+			data, err := os.ReadFile(filepath.Join(exportDir, "daily", "top-talkers.json"))
+			require.Nil(t, err)
+			return data
+		},
+		"s3://results/top-talkers.json": func() []byte {
+			return uploaded
+		},
+	} {
+		rec := request(router, destination)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		var handle ExportHandle
+		require.Nil(t, jsoniter.Unmarshal(rec.Body.Bytes(), &handle))
+		require.Equal(t, destination, handle.Destination)
+		require.Equal(t, 1, handle.Rows)
+
+		data := read()
+		require.Equal(t, int64(len(data)), handle.Size)
+		var exported results.Result
+		require.Nil(t, jsoniter.Unmarshal(data, &exported))
+		require.Equal(t, res.Rows, exported.Rows)
+	}
+
+	// invalid destinations are rejected before running the query, as are exports from servers without exporter
+	for _, rec := range []*httptest.ResponseRecorder{
+		request(router, "../top-talkers.json"),
+		request(newRouter(nil), "top-talkers.json"),
+	} {
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		require.Nil(t, runner.args)
+	}
+}
@@ -1,7 +1,28 @@
 package globalquery
 
+import "github.com/els0r/goProbe/cmd/global-query/pkg/reports"
+
 const (
 
 	// QueryRoute denotes the route / URI path to the query endpoint
 	QueryRoute = "/_query"
+
+	// ReportsRoute denotes the route / URI path to the scheduled reports
+	ReportsRoute = "/reports"
+
+	// UsageRoute denotes the route / URI path to the queries run (and resources consumed) per API key
+	UsageRoute = "/usage"
 )
+
+type response struct {
+	StatusCode int    `json:"status_code"`     // StatusCode: stores the HTTP status code of the response. Example: 200
+	Error      string `json:"error,omitempty"` // Error: stores the error message if the request failed. Example: "report not found"
+}
+
+// ReportsResponse is the response to a scheduled reports request
+type ReportsResponse struct {
+	response
+
+	// Reports: the scheduled reports along with their run history
+	Reports []reports.Info `json:"reports"`
+}
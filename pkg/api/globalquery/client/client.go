@@ -37,8 +37,10 @@ func (c *Client) Query(ctx context.Context, args *query.Args) (*results.Result,
 	// use a copy of the arguments, since some fields are modified by the client
 	queryArgs := *args
 
-	// whatever happens, the results are expected to be returned in json
+	// whatever happens, the raw results are expected to be returned (in the wire format)
 	queryArgs.Format = "json"
+	// enrichers run upon printing the result, i.e. on the caller's side (where they are registered)
+	queryArgs.Enrich = nil
 
 	if queryArgs.Caller == "" {
 		queryArgs.Caller = clientName
@@ -48,8 +50,10 @@ func (c *Client) Query(ctx context.Context, args *query.Args) (*results.Result,
 
 	req := c.Modify(ctx,
 		httpc.NewWithClient("POST", c.NewURL(gqapi.QueryRoute), c.Client()).
-			EncodeJSON(queryArgs).
-			ParseJSON(res),
+			Encode(client.EncodeArgs(&queryArgs)).
+			ModifyRequest(client.AcceptResult).
+			ParseFn(client.ParseResult(res)).
+			ErrorFn(client.QueryErrorFn),
 	)
 
 	err := req.RunWithContext(ctx)
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/els0r/goProbe/cmd/global-query/pkg/reports"
+	gqapi "github.com/els0r/goProbe/pkg/api/globalquery"
+	"github.com/fako1024/httpc"
+)
+
+// GetReports returns the scheduled reports along with their run history. If names are provided,
+// only the reports with these names are returned
+func (c *Client) GetReports(ctx context.Context, names ...string) ([]reports.Info, error) {
+	if len(names) == 0 {
+		return c.getReports(ctx, gqapi.ReportsRoute)
+	}
+
+	var infos []reports.Info
+	for _, name := range names {
+		res, err := c.getReports(ctx, gqapi.ReportsRoute+"/"+name)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, res...)
+	}
+	return infos, nil
+}
+
+func (c *Client) getReports(ctx context.Context, route string) ([]reports.Info, error) {
+	var res = new(gqapi.ReportsResponse)
+
+	req := c.Modify(ctx,
+		httpc.NewWithClient("GET", c.NewURL(route), c.Client()).
+			ParseJSON(res),
+	)
+	err := req.RunWithContext(ctx)
+	if err != nil {
+		if res.Error != "" {
+			err = fmt.Errorf("%d: %s", res.StatusCode, res.Error)
+		}
+		return nil, err
+	}
+	return res.Reports, nil
+}
@@ -0,0 +1,23 @@
+package client
+
+import (
+	"context"
+
+	"github.com/els0r/goProbe/pkg/api"
+	gqapi "github.com/els0r/goProbe/pkg/api/globalquery"
+	"github.com/fako1024/httpc"
+)
+
+// GetUsage returns the queries run (and resources consumed) per API key of the global-query server
+func (c *Client) GetUsage(ctx context.Context) ([]api.KeyAccount, error) {
+	var res = new(api.UsageResponse)
+
+	req := c.Modify(ctx,
+		httpc.NewWithClient("GET", c.NewURL(gqapi.UsageRoute), c.Client()).
+			ParseJSON(res),
+	)
+	if err := req.RunWithContext(ctx); err != nil {
+		return nil, err
+	}
+	return res.Keys, nil
+}
@@ -12,13 +12,20 @@ import (
 
 // RegisterQueryHandler hooks up the distributed query endpoint to an existing gin engine. It is meant for third-party
 // APIs as a means to integrate query capabilities
-func RegisterQueryHandler(engine *gin.Engine, route string, resolver hosts.Resolver, querier distributed.Querier) {
+func RegisterQueryHandler(engine gin.IRoutes, route string, resolver hosts.Resolver, querier distributed.Querier, opts ...distributed.QueryOption) {
+	registerQueryHandler(engine, route, resolver, querier, opts)
+}
+
+// registerQueryHandler hooks up the distributed query endpoint, authorizing the query arguments of each
+// request with the checks provided (see api.RunQuery())
+func registerQueryHandler(engine gin.IRoutes, route string, resolver hosts.Resolver, querier distributed.Querier, opts []distributed.QueryOption, checks ...api.ArgsCheck) {
 	handler := func(c *gin.Context) {
 		api.RunQuery(
 			fmt.Sprintf("global-query/%s", version.Short()),
 			"distributed",
-			distributed.NewQueryRunner(resolver, querier),
+			distributed.NewQueryRunner(resolver, querier, opts...),
 			c,
+			checks...,
 		)
 	}
 
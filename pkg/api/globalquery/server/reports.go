@@ -0,0 +1,47 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/els0r/goProbe/cmd/global-query/pkg/reports"
+	gqapi "github.com/els0r/goProbe/pkg/api/globalquery"
+	"github.com/gin-gonic/gin"
+)
+
+const reportKey = "report"
+
+var errorReportNotFound = errors.New("report not found")
+
+func (server *Server) getReports(c *gin.Context) {
+	resp := &gqapi.ReportsResponse{Reports: []reports.Info{}}
+	resp.StatusCode = http.StatusOK
+
+	name := c.Param(reportKey)
+	if name == "" {
+		if server.reportScheduler != nil {
+			resp.Reports = server.reportScheduler.Reports()
+		}
+		c.JSON(resp.StatusCode, resp)
+		return
+	}
+
+	var (
+		info   reports.Info
+		exists bool
+	)
+	if server.reportScheduler != nil {
+		info, exists = server.reportScheduler.Report(name)
+	}
+	if !exists {
+		resp.StatusCode = http.StatusNotFound
+		resp.Error = fmt.Errorf("%w: %s", errorReportNotFound, name).Error()
+
+		c.AbortWithStatusJSON(resp.StatusCode, resp)
+		return
+	}
+	resp.Reports = append(resp.Reports, info)
+
+	c.JSON(resp.StatusCode, resp)
+}
@@ -4,23 +4,52 @@ import (
 	"github.com/els0r/goProbe/cmd/global-query/pkg/conf"
 	"github.com/els0r/goProbe/cmd/global-query/pkg/distributed"
 	"github.com/els0r/goProbe/cmd/global-query/pkg/hosts"
+	"github.com/els0r/goProbe/cmd/global-query/pkg/reports"
+	"github.com/els0r/goProbe/pkg/api"
 	gqapi "github.com/els0r/goProbe/pkg/api/globalquery"
 	"github.com/els0r/goProbe/pkg/api/server"
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/gin-gonic/gin"
 )
 
 // Server runs a global-query API server
 type Server struct {
 	hostListResolver hosts.Resolver
 	querier          distributed.Querier
+	queryOpts        []distributed.QueryOption
+	reportScheduler  *reports.Scheduler
+	keyTenants       map[string][]string
+	keyRoles         map[string]api.Role
 
 	*server.DefaultServer
 }
 
-// New creates a new global-query API server
-func New(addr string, resolver hosts.Resolver, querier distributed.Querier, opts ...server.Option) *Server {
+// SetKeyTenants restricts the tenants API keys may query (see api.TenantScope())
+func (server *Server) SetKeyTenants(keyTenants map[string][]string) *Server {
+	server.keyTenants = keyTenants
+	return server
+}
+
+// SetKeyRoles sets the roles of API keys. Endpoints revealing the usage of all keys require
+// api.RoleAdmin (see api.RequireRole())
+func (server *Server) SetKeyRoles(keyRoles map[string]api.Role) *Server {
+	server.keyRoles = keyRoles
+	return server
+}
+
+// SetReportScheduler exposes the scheduled reports (and their run history) via the API
+func (server *Server) SetReportScheduler(scheduler *reports.Scheduler) *Server {
+	server.reportScheduler = scheduler
+	return server
+}
+
+// New creates a new global-query API server. The query options are applied to the query runner of
+// every query handled by the server
+func New(addr string, resolver hosts.Resolver, querier distributed.Querier, queryOpts []distributed.QueryOption, opts ...server.Option) *Server {
 	server := &Server{
 		hostListResolver: resolver,
 		querier:          querier,
+		queryOpts:        queryOpts,
 		DefaultServer:    server.NewDefault(conf.ServiceName, addr, opts...),
 	}
 
@@ -29,6 +58,36 @@ func New(addr string, resolver hosts.Resolver, querier distributed.Querier, opts
 	return server
 }
 
+// tenantScope restricts the tenants API keys may query. The key tenants are looked up at request
+// time since they are set after the routes have been registered
+func (server *Server) tenantScope(c *gin.Context, args *query.Args) error {
+	return api.TenantScope(server.keyTenants)(c, args)
+}
+
+// requireAdmin restricts access to the endpoint to API keys with the admin role. The key roles are
+// looked up at request time since they are set after the routes have been registered
+func (server *Server) requireAdmin(c *gin.Context) {
+	api.RequireRole(server.keyRoles, api.RoleAdmin)(c)
+}
+
 func (server *Server) registerRoutes() {
-	RegisterQueryHandler(server.Router(), gqapi.QueryRoute, server.hostListResolver, server.querier)
+	router := server.Router()
+
+	// queries are accounted per API key (if configured) and may export their results (if enabled)
+	var queryMiddlewares gin.HandlersChain
+	if accounting, hasAccounting := server.Accounting(); hasAccounting {
+		queryMiddlewares = append(queryMiddlewares, api.AccountingMiddleware(accounting))
+		router.GET(gqapi.UsageRoute, server.requireAdmin, api.UsageHandler(accounting))
+	}
+	if exporter, hasExporter := server.Exporter(); hasExporter {
+		queryMiddlewares = append(queryMiddlewares, api.ExportMiddleware(exporter))
+	}
+	registerQueryHandler(router.Group("", queryMiddlewares...), gqapi.QueryRoute, server.hostListResolver, server.querier, server.queryOpts,
+		server.tenantScope,
+	)
+
+	// scheduled reports
+	reportRoutes := router.Group(gqapi.ReportsRoute)
+	reportRoutes.GET("", server.getReports)
+	reportRoutes.GET("/:"+reportKey, server.getReports)
 }
@@ -4,7 +4,9 @@ import (
 	"time"
 
 	"github.com/els0r/goProbe/cmd/goProbe/config"
+	"github.com/els0r/goProbe/pkg/capture"
 	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/goProbe/pkg/goDB"
 )
 
 const (
@@ -25,6 +27,9 @@ type response struct {
 	Error      string `json:"error,omitempty"` // Error: stores the error message if the request failed. Example: "interface not found"
 }
 
+// UsageRoute is the route to query the queries run (and resources consumed) per API key
+const UsageRoute = "/usage"
+
 // StatusRoute is the route to query the current status
 const StatusRoute = "/status"
 
@@ -42,9 +47,32 @@ type StatusResponse struct {
 	Statuses capturetypes.InterfaceStats `json:"statuses"`
 }
 
+// StatusHistoryRoute is the route (below StatusRoute/:interface) to query the statistics of the most
+// recent rotations of an interface
+const StatusHistoryRoute = "/history"
+
+const (
+	// StatusHistoryLastQueryParam is the query parameter to specify the period covered by the history
+	StatusHistoryLastQueryParam = "last"
+
+	// DefaultStatusHistoryLast is the default period covered by the history
+	DefaultStatusHistoryLast = 24 * time.Hour
+)
+
+// StatusHistoryResponse is the response to a status history query
+type StatusHistoryResponse struct {
+	response
+	Iface   string                    `json:"iface"`   // Iface: the interface the statistics were recorded for. Example: "eth0"
+	History capturetypes.StatsHistory `json:"history"` // History: the statistics of the rotations within the requested period (oldest first)
+}
+
 // ConfigRoute is the route to query/modify the current configuration
 const ConfigRoute = "/config"
 
+// ConfigDryRunQueryParam is the query parameter to only preview the changes of a config update
+// instead of applying them
+const ConfigDryRunQueryParam = "dry_run"
+
 // ConfigReloadRoute is the route to trigger a config reload
 const ConfigReloadRoute = "/_reload"
 
@@ -54,14 +82,106 @@ type ConfigResponse struct {
 	Ifaces config.Ifaces `json:"ifaces"` // Ifaces: stores the current configuration for each interface
 }
 
+// ConfigDiffRoute is the route (below ConfigRoute) to compare the running configuration with the
+// config file on disk
+const ConfigDiffRoute = "/diff"
+
+// ConfigDiffResponse is the response to a config diff. Interfaces added / removed are only present in
+// the config file / the running configuration, respectively. The old values of changed fields denote
+// the running configuration, the new values the one of the config file
+type ConfigDiffResponse struct {
+	response
+	Path   string `json:"path"`    // Path: the config file the running configuration was compared with. Example: "/etc/goprobe.conf"
+	InSync bool   `json:"in_sync"` // InSync: denotes if the running configuration matches the config file. Example: false
+	config.IfacesDiff
+}
+
 // ConfigUpdateResponse is the response to a config update
 type ConfigUpdateResponse struct {
 	response
 	Enabled  []string `json:"enabled"`  // Enabled: stores the interfaces that were enabled. Example: ["eth0", "eth1"]
 	Updated  []string `json:"updated"`  // Updated: stores the interfaces that were updated. Example: ["eth2"]
 	Disabled []string `json:"disabled"` // Disabled: stores the interfaces that were disabled. Example: ["eth5"]
+
+	// DryRun: denotes that the update was only previewed. In this case, the interfaces above denote
+	// the ones which would be enabled / updated / disabled. Example: true
+	DryRun bool `json:"dry_run,omitempty"`
+	// Changes: the differing fields of the interfaces which would be updated (only set for a dry run)
+	Changes map[string][]config.FieldDiff `json:"changes,omitempty"`
 }
 
 // ConfigUpdateRequest is the payload to update the configuration of all
 // interfaces stored in it
 type ConfigUpdateRequest config.Ifaces
+
+// SnapshotRoute is the route to create a snapshot of the DB
+const SnapshotRoute = "/_snapshot"
+
+// SnapshotRequest is the payload to create a snapshot of the DB
+type SnapshotRequest struct {
+	// Name: name of the snapshot directory (within the snapshot path of goProbe). If empty,
+	// the current time is used. Example: "before-upgrade"
+	Name string `json:"name,omitempty"`
+}
+
+// SnapshotResponse is the response to a snapshot creation
+type SnapshotResponse struct {
+	response
+	goDB.SnapshotInfo
+}
+
+// FlowsRoute is the route to query the top flows currently held in the flow log of an interface
+const FlowsRoute = "/flows"
+
+const (
+	// FlowsTopQueryParam is the query parameter to specify the number of flows to return
+	FlowsTopQueryParam = "top"
+	// FlowsSortQueryParam is the query parameter to specify the metric the flows are sorted by
+	FlowsSortQueryParam = "sort"
+
+	// DefaultFlowsTop is the default number of flows returned
+	DefaultFlowsTop = 50
+	// DefaultFlowsSort is the default metric the flows are sorted by
+	DefaultFlowsSort = "bytes"
+)
+
+// FlowsResponse is the response to a flows query
+type FlowsResponse struct {
+	response
+	Iface string            `json:"iface"` // Iface: the interface the flows were captured on. Example: "eth0"
+	Total int               `json:"total"` // Total: the number of flows currently held in the flow log. Example: 2048
+	Flows capture.FlowInfos `json:"flows"` // Flows: the top flows of the flow log, in descending order of the sort metric
+}
+
+// DBMetaRoute is the route to query the metadata of the blocks stored in the DB (without reading any flows)
+const DBMetaRoute = "/db/meta"
+
+const (
+	// DBMetaFirstQueryParam is the query parameter to specify the start of the time range covered
+	DBMetaFirstQueryParam = "first"
+	// DBMetaLastQueryParam is the query parameter to specify the end of the time range covered
+	DBMetaLastQueryParam = "last"
+	// DBMetaTenantQueryParam is the query parameter to specify the tenant whose interfaces are covered
+	DBMetaTenantQueryParam = "tenant"
+
+	// DefaultDBMetaFirst is the default start of the time range covered
+	DefaultDBMetaFirst = "-24h"
+)
+
+// DBMetaResponse is the response to a DB metadata query
+type DBMetaResponse struct {
+	response
+	Days []goDB.DayMetadata `json:"days"` // Days: the metadata of the days holding blocks within the requested time range, ordered by interface and time
+}
+
+// SupportBundleRoute is the route to download a support bundle
+const SupportBundleRoute = "/_bundle"
+
+// SupportBundleContentType is the content type of a support bundle (a gzip compressed tar archive)
+const SupportBundleContentType = "application/gzip"
+
+// SupportBundleResponse is the response to a failed support bundle download (a successful
+// download returns the bundle itself)
+type SupportBundleResponse struct {
+	response
+}
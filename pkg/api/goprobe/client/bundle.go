@@ -0,0 +1,34 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	gpapi "github.com/els0r/goProbe/pkg/api/goprobe"
+	"github.com/fako1024/httpc"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// GetSupportBundle downloads a support bundle (a gzip compressed tar archive) from the running
+// goProbe instance and writes it to w
+func (c *Client) GetSupportBundle(ctx context.Context, w io.Writer) error {
+	url := c.NewURL(gpapi.SupportBundleRoute)
+
+	req := c.Modify(ctx,
+		httpc.NewWithClient("GET", url, c.Client()).
+			ParseFn(func(resp *http.Response) error {
+				_, err := io.Copy(w, resp.Body)
+				return err
+			}).
+			ErrorFn(func(resp *http.Response) error {
+				var res = new(gpapi.SupportBundleResponse)
+				if err := jsoniter.NewDecoder(resp.Body).Decode(res); err != nil || res.Error == "" {
+					return fmt.Errorf("%d: %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+				}
+				return fmt.Errorf("%d: %s", res.StatusCode, res.Error)
+			}),
+	)
+	return req.RunWithContext(ctx)
+}
@@ -57,6 +57,31 @@ func (c *Client) UpdateInterfaceConfigs(ctx context.Context, ifaceConfigs config
 	return res.Enabled, res.Updated, res.Disabled, nil
 }
 
+// PreviewInterfaceConfigs reports the changes updating goprobe's runtime configuration with the provided
+// interfaces would cause, without applying them
+func (c *Client) PreviewInterfaceConfigs(ctx context.Context, ifaceConfigs config.Ifaces) (*gpapi.ConfigUpdateResponse, error) {
+	var res = new(gpapi.ConfigUpdateResponse)
+
+	url := c.NewURL(gpapi.ConfigRoute)
+
+	req := c.Modify(ctx,
+		httpc.NewWithClient("PUT", url, c.Client()).
+			QueryParams(httpc.Params{
+				gpapi.ConfigDryRunQueryParam: "true",
+			}).
+			EncodeJSON(ifaceConfigs).
+			ParseJSON(res),
+	)
+	err := req.RunWithContext(ctx)
+	if err != nil {
+		if res.Error != "" {
+			err = fmt.Errorf("%d: %s", res.StatusCode, res.Error)
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
 // ReloadConfig reads / updates goprobe's runtime configuration with the one from disk
 func (c *Client) ReloadConfig(ctx context.Context) (enabled, updated, disabled []string, err error) {
 	var res = new(gpapi.ConfigUpdateResponse)
@@ -76,3 +101,23 @@ func (c *Client) ReloadConfig(ctx context.Context) (enabled, updated, disabled [
 	}
 	return res.Enabled, res.Updated, res.Disabled, nil
 }
+
+// GetConfigDiff compares goprobe's runtime configuration with the one from disk
+func (c *Client) GetConfigDiff(ctx context.Context) (*gpapi.ConfigDiffResponse, error) {
+	var res = new(gpapi.ConfigDiffResponse)
+
+	url := c.NewURL(gpapi.ConfigRoute + gpapi.ConfigDiffRoute)
+
+	req := c.Modify(ctx,
+		httpc.NewWithClient("GET", url, c.Client()).
+			ParseJSON(res),
+	)
+	err := req.RunWithContext(ctx)
+	if err != nil {
+		if res.Error != "" {
+			err = fmt.Errorf("%d: %s", res.StatusCode, res.Error)
+		}
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	gpapi "github.com/els0r/goProbe/pkg/api/goprobe"
+	"github.com/els0r/goProbe/pkg/capture"
+	"github.com/fako1024/httpc"
+)
+
+// GetTopFlows returns the top flows currently held in the flow log of an interface of the running goProbe
+// instance, sorted by sortBy ("bytes" or "packets"), along with the total number of flows in the flow log
+func (c *Client) GetTopFlows(ctx context.Context, iface string, top int, sortBy string) (flows capture.FlowInfos, total int, err error) {
+	var res = new(gpapi.FlowsResponse)
+
+	url := c.NewURL(gpapi.FlowsRoute + "/" + iface)
+
+	req := c.Modify(ctx,
+		httpc.NewWithClient("GET", url, c.Client()).
+			QueryParams(httpc.Params{
+				gpapi.FlowsTopQueryParam:  strconv.Itoa(top),
+				gpapi.FlowsSortQueryParam: sortBy,
+			}).
+			ParseJSON(res),
+	)
+	err = req.RunWithContext(ctx)
+	if err != nil {
+		if res.Error != "" {
+			err = fmt.Errorf("%d: %s", res.StatusCode, res.Error)
+		}
+		return nil, 0, err
+	}
+	return res.Flows, res.Total, nil
+}
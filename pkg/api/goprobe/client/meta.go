@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	gpapi "github.com/els0r/goProbe/pkg/api/goprobe"
+	"github.com/els0r/goProbe/pkg/goDB"
+	"github.com/fako1024/httpc"
+)
+
+// GetDBMetadata returns the metadata of the blocks the interfaces of the tenant (all interfaces if none are
+// provided) stored in the DB of the goProbe instance within the time range [first, last], per day
+func (c *Client) GetDBMetadata(ctx context.Context, first, last, tenant string, ifaces ...string) (days []goDB.DayMetadata, err error) {
+	var res = new(gpapi.DBMetaResponse)
+
+	params := httpc.Params{}
+	if first != "" {
+		params[gpapi.DBMetaFirstQueryParam] = first
+	}
+	if last != "" {
+		params[gpapi.DBMetaLastQueryParam] = last
+	}
+	if tenant != "" {
+		params[gpapi.DBMetaTenantQueryParam] = tenant
+	}
+	if len(ifaces) > 0 {
+		params[gpapi.IfacesQueryParam] = strings.Join(ifaces, ",")
+	}
+
+	req := c.Modify(ctx,
+		httpc.NewWithClient("GET", c.NewURL(gpapi.DBMetaRoute), c.Client()).
+			QueryParams(params).
+			ParseJSON(res),
+	)
+	err = req.RunWithContext(ctx)
+	if err != nil {
+		if res.Error != "" {
+			err = fmt.Errorf("%d: %s", res.StatusCode, res.Error)
+		}
+		return nil, err
+	}
+	return res.Days, nil
+}
@@ -3,6 +3,7 @@ package client
 import (
 	"context"
 
+	"github.com/els0r/goProbe/pkg/api/client"
 	gpapi "github.com/els0r/goProbe/pkg/api/goprobe"
 	"github.com/els0r/goProbe/pkg/query"
 	"github.com/els0r/goProbe/pkg/results"
@@ -18,24 +19,23 @@ func (c *Client) Run(ctx context.Context, args *query.Args) (*results.Result, er
 func (c *Client) Query(ctx context.Context, args *query.Args) (*results.Result, error) {
 	// use a copy of the arguments, since some fields are modified by the client
 	queryArgs := *args
-	// whatever happens, the results are expected to be returned in json
+	// whatever happens, the raw results are expected to be returned (in the wire format)
 	queryArgs.Format = "json"
+	// enrichers run upon printing the result, i.e. on the caller's side (where they are registered)
+	queryArgs.Enrich = nil
 
 	if queryArgs.Caller == "" {
 		queryArgs.Caller = clientName
 	}
 
-	// we need more results before truncating
-	if args.NumResults < query.DefaultNumResults {
-		args.NumResults = query.DefaultNumResults
-	}
-
 	var res = new(results.Result)
 
 	req := c.Modify(ctx,
 		httpc.NewWithClient("POST", c.NewURL(gpapi.QueryRoute), c.Client()).
-			EncodeJSON(queryArgs).
-			ParseJSON(res),
+			Encode(client.EncodeArgs(&queryArgs)).
+			ModifyRequest(client.AcceptResult).
+			ParseFn(client.ParseResult(res)).
+			ErrorFn(client.QueryErrorFn),
 	)
 	err := req.RunWithContext(ctx)
 	if err != nil {
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	gpapi "github.com/els0r/goProbe/pkg/api/goprobe"
+	"github.com/els0r/goProbe/pkg/goDB"
+	"github.com/fako1024/httpc"
+)
+
+// CreateSnapshot creates a snapshot of the DB of the running goProbe instance. If name is empty,
+// goProbe names the snapshot after the current time
+func (c *Client) CreateSnapshot(ctx context.Context, name string) (snapshot goDB.SnapshotInfo, err error) {
+	var res = new(gpapi.SnapshotResponse)
+
+	url := c.NewURL(gpapi.SnapshotRoute)
+
+	req := c.Modify(ctx,
+		httpc.NewWithClient("POST", url, c.Client()).
+			EncodeJSON(gpapi.SnapshotRequest{Name: name}).
+			ParseJSON(res),
+	)
+	err = req.RunWithContext(ctx)
+	if err != nil {
+		if res.Error != "" {
+			err = fmt.Errorf("%d: %s", res.StatusCode, res.Error)
+		}
+		return
+	}
+	return res.SnapshotInfo, nil
+}
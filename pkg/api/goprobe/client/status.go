@@ -36,3 +36,27 @@ func (c *Client) GetInterfaceStatus(ctx context.Context, ifaces ...string) (stat
 
 	return res.Statuses, res.LastWriteout, res.StartedAt, nil
 }
+
+// GetInterfaceHistory returns the statistics of the rotations of an interface of the running goProbe
+// instance within the last period (oldest first)
+func (c *Client) GetInterfaceHistory(ctx context.Context, iface string, last time.Duration) (history capturetypes.StatsHistory, err error) {
+	var res = new(gpapi.StatusHistoryResponse)
+
+	url := c.NewURL(gpapi.StatusRoute + "/" + iface + gpapi.StatusHistoryRoute)
+
+	req := c.Modify(ctx,
+		httpc.NewWithClient("GET", url, c.Client()).
+			QueryParams(httpc.Params{
+				gpapi.StatusHistoryLastQueryParam: last.String(),
+			}).
+			ParseJSON(res),
+	)
+	err = req.RunWithContext(ctx)
+	if err != nil {
+		if res.Error != "" {
+			err = fmt.Errorf("%d: %s", res.StatusCode, res.Error)
+		}
+		return nil, err
+	}
+	return res.History, nil
+}
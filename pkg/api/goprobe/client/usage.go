@@ -0,0 +1,23 @@
+package client
+
+import (
+	"context"
+
+	"github.com/els0r/goProbe/pkg/api"
+	gpapi "github.com/els0r/goProbe/pkg/api/goprobe"
+	"github.com/fako1024/httpc"
+)
+
+// GetUsage returns the queries run (and resources consumed) per API key of the running goProbe instance
+func (c *Client) GetUsage(ctx context.Context) ([]api.KeyAccount, error) {
+	var res = new(api.UsageResponse)
+
+	req := c.Modify(ctx,
+		httpc.NewWithClient("GET", c.NewURL(gpapi.UsageRoute), c.Client()).
+			ParseJSON(res),
+	)
+	if err := req.RunWithContext(ctx); err != nil {
+		return nil, err
+	}
+	return res.Keys, nil
+}
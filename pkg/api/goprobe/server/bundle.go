@@ -0,0 +1,195 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"runtime/pprof"
+	"strings"
+	"time"
+
+	gpapi "github.com/els0r/goProbe/pkg/api/goprobe"
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/version"
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// bundleTimeFormat denotes the format of the timestamp in the file name of a support bundle
+const bundleTimeFormat = "20060102T150405Z"
+
+// bundleProfiles denotes the runtime profiles included in a support bundle, along with the
+// debug level they are written with
+var bundleProfiles = []struct {
+	name  string
+	debug int
+	file  string
+}{
+	{"goroutine", 2, "goroutine.txt"},
+	{"heap", 0, "heap.pprof"},
+	{"allocs", 0, "allocs.pprof"},
+	{"block", 0, "block.pprof"},
+	{"mutex", 0, "mutex.pprof"},
+}
+
+// bundleDBInfo summarizes the DB in a support bundle
+type bundleDBInfo struct {
+	Path       string        `json:"path"`
+	Identity   info.Identity `json:"identity"`
+	Interfaces []string      `json:"interfaces"`
+	Tenants    []string      `json:"tenants,omitempty"`
+	Aliases    info.Aliases  `json:"aliases,omitempty"`
+}
+
+// supportBundle collects the files of a support bundle. Failures to collect individual files
+// are recorded in the bundle instead of failing it as a whole
+type supportBundle struct {
+	dir    string
+	tw     *tar.Writer
+	now    time.Time
+	errors []string
+}
+
+func newSupportBundle(buf *bytes.Buffer, dir string, now time.Time) (*supportBundle, *gzip.Writer) {
+	gw := gzip.NewWriter(buf)
+	return &supportBundle{
+		dir: dir,
+		tw:  tar.NewWriter(gw),
+		now: now,
+	}, gw
+}
+
+func (b *supportBundle) add(name string, data []byte) error {
+	if err := b.tw.WriteHeader(&tar.Header{
+		Name:    b.dir + "/" + name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: b.now,
+	}); err != nil {
+		return err
+	}
+	_, err := b.tw.Write(data)
+	return err
+}
+
+func (b *supportBundle) addJSON(name string, v interface{}, err error) error {
+	if err != nil {
+		b.errors = append(b.errors, fmt.Sprintf("%s: %v", name, err))
+		return nil
+	}
+	data, err := jsoniter.MarshalIndent(v, "", "  ")
+	if err != nil {
+		b.errors = append(b.errors, fmt.Sprintf("%s: %v", name, err))
+		return nil
+	}
+	return b.add(name, data)
+}
+
+func (b *supportBundle) close() error {
+	if len(b.errors) > 0 {
+		if err := b.add("bundle_errors.txt", []byte(strings.Join(b.errors, "\n")+"\n")); err != nil {
+			return err
+		}
+	}
+	return b.tw.Close()
+}
+
+func (server *Server) getSupportBundle(c *gin.Context) {
+	now := time.Now().UTC()
+	name := "goprobe-bundle-" + now.Format(bundleTimeFormat)
+	if server.identity.Hostname != "" {
+		name = fmt.Sprintf("goprobe-bundle-%s-%s", server.identity.Hostname, now.Format(bundleTimeFormat))
+	}
+
+	buf := new(bytes.Buffer)
+	bundle, gw := newSupportBundle(buf, name, now)
+	if err := server.writeSupportBundle(c, bundle); err != nil {
+		resp := &gpapi.SupportBundleResponse{}
+		resp.StatusCode = http.StatusInternalServerError
+		resp.Error = fmt.Sprintf("failed to create support bundle: %v", err)
+
+		c.AbortWithStatusJSON(resp.StatusCode, resp)
+		return
+	}
+	if err := gw.Close(); err != nil {
+		resp := &gpapi.SupportBundleResponse{}
+		resp.StatusCode = http.StatusInternalServerError
+		resp.Error = fmt.Sprintf("failed to compress support bundle: %v", err)
+
+		c.AbortWithStatusJSON(resp.StatusCode, resp)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".tar.gz"))
+	c.Data(http.StatusOK, gpapi.SupportBundleContentType, buf.Bytes())
+}
+
+func (server *Server) writeSupportBundle(c *gin.Context, bundle *supportBundle) error {
+	ctx := c.Request.Context()
+
+	if err := bundle.add("version.txt", []byte(version.Version())); err != nil {
+		return err
+	}
+
+	// configuration (without secrets)
+	if server.configMonitor != nil {
+		cfg, err := server.configMonitor.GetConfig().Redacted()
+		if err := bundle.addJSON("config.json", cfg, err); err != nil {
+			return err
+		}
+	}
+
+	// capture status
+	if server.captureManager != nil {
+		status := &gpapi.StatusResponse{
+			Statuses: server.captureManager.Status(ctx),
+		}
+		status.StatusCode = http.StatusOK
+		status.StartedAt, status.LastWriteout = server.captureManager.GetTimestamps()
+		if err := bundle.addJSON("status.json", status, nil); err != nil {
+			return err
+		}
+	}
+
+	// recent errors
+	if server.recentErrors != nil {
+		if err := bundle.add("errors.log", []byte(strings.Join(server.recentErrors.Lines(), "\n")+"\n")); err != nil {
+			return err
+		}
+	}
+
+	// DB info
+	dbInfo := bundleDBInfo{
+		Path:     server.dbPath,
+		Identity: server.identity,
+	}
+	var err error
+	if dbInfo.Interfaces, err = info.GetInterfaces(server.dbPath); err == nil {
+		if dbInfo.Tenants, err = info.GetTenants(server.dbPath); err == nil {
+			dbInfo.Aliases, err = info.ReadAliases(server.dbPath)
+		}
+	}
+	if err := bundle.addJSON("db.json", dbInfo, err); err != nil {
+		return err
+	}
+
+	// runtime profiles
+	for _, profile := range bundleProfiles {
+		p := pprof.Lookup(profile.name)
+		if p == nil {
+			continue
+		}
+		pbuf := new(bytes.Buffer)
+		if err := p.WriteTo(pbuf, profile.debug); err != nil {
+			bundle.errors = append(bundle.errors, fmt.Sprintf("%s: %v", profile.file, err))
+			continue
+		}
+		if err := bundle.add("profiles/"+profile.file, pbuf.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return bundle.close()
+}
@@ -1,15 +1,25 @@
 package server
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/els0r/goProbe/cmd/goProbe/config"
 	gpapi "github.com/els0r/goProbe/pkg/api/goprobe"
+	"github.com/els0r/goProbe/pkg/capture"
 	"github.com/gin-gonic/gin"
 )
 
+var (
+	errorNoConfigMonitor = errors.New("no config file is monitored")
+	errorInvalidDryRun   = errors.New("invalid dry run parameter")
+)
+
 func (server *Server) getConfig(c *gin.Context) {
 	iface := c.Param(ifaceKey)
 	ifaces := c.Request.URL.Query().Get(gpapi.IfacesQueryParam)
@@ -27,15 +37,31 @@ func (server *Server) getConfig(c *gin.Context) {
 		return
 	}
 
+	var requested []string
 	if iface != "" {
-		resp.Ifaces = server.captureManager.Config(iface)
-	} else {
-		if ifaces != "" {
-			// fetch all specified
-			resp.Ifaces = server.captureManager.Config(strings.Split(ifaces, ",")...)
-		} else {
-			// otherwise, fetch all
-			resp.Ifaces = server.captureManager.Config()
+		requested = []string{iface}
+	} else if ifaces != "" {
+		// fetch all specified (otherwise, fetch all)
+		requested = strings.Split(ifaces, ",")
+	}
+
+	// interfaces of tenants the API key isn't permitted to query are rejected if requested explicitly
+	// and omitted otherwise
+	for _, requestedIface := range requested {
+		if err := server.ifaceTenantScope(c, requestedIface); err != nil {
+			resp.StatusCode = http.StatusForbidden
+			resp.Error = err.Error()
+
+			c.AbortWithStatusJSON(resp.StatusCode, resp)
+			return
+		}
+	}
+	resp.Ifaces = server.captureManager.Config(requested...)
+	if len(requested) == 0 {
+		for cfgIface := range resp.Ifaces {
+			if err := server.ifaceTenantScope(c, cfgIface); err != nil {
+				delete(resp.Ifaces, cfgIface)
+			}
 		}
 	}
 
@@ -72,10 +98,46 @@ func (server *Server) putConfig(c *gin.Context) {
 		return
 	}
 
+	dryRun, err := strconv.ParseBool(c.DefaultQuery(gpapi.ConfigDryRunQueryParam, "false"))
+	if err != nil {
+		resp.StatusCode = http.StatusBadRequest
+		resp.Error = fmt.Errorf("%w: %q", errorInvalidDryRun, c.Query(gpapi.ConfigDryRunQueryParam)).Error()
+
+		c.AbortWithStatusJSON(resp.StatusCode, resp)
+		return
+	}
+
+	// report the changes the update would cause without applying them. Interfaces are updated if
+	// their configuration differs from the running one in any way (see capture.Manager.Update())
+	if dryRun {
+		diff := server.captureManager.Config().Diff(ifaceConfigs)
+		resp.Enabled, resp.Disabled = diff.Added, diff.Removed
+		for iface := range diff.Changed {
+			resp.Updated = append(resp.Updated, iface)
+		}
+		sort.Strings(resp.Updated)
+		resp.DryRun, resp.Changes = true, diff.Changed
+
+		// report the same conflict as applying the update would
+		if err := server.captureManager.CheckUpdate(resp.Enabled, resp.Updated); err != nil {
+			resp.StatusCode = http.StatusConflict
+			resp.Error = err.Error()
+
+			c.AbortWithStatusJSON(resp.StatusCode, resp)
+			return
+		}
+
+		c.JSON(resp.StatusCode, resp)
+		return
+	}
+
 	// update the captures
 	server.configMonitor.PutIfaceConfig(ifaceConfigs)
 	if resp.Enabled, resp.Updated, resp.Disabled, err = server.configMonitor.Apply(c.Request.Context(), server.captureManager.Update); err != nil {
 		resp.StatusCode = http.StatusBadRequest
+		if errors.Is(err, capture.ErrPrivilegesDropped) {
+			resp.StatusCode = http.StatusConflict
+		}
 		resp.Error = err.Error()
 
 		c.AbortWithStatusJSON(resp.StatusCode, resp)
@@ -92,12 +154,41 @@ func (server *Server) reloadConfig(c *gin.Context) {
 	// Reload the configuration and trigger an update for the CaptureManager
 	var err error
 	if resp.Enabled, resp.Updated, resp.Disabled, err = server.configMonitor.Reload(c.Request.Context(), server.captureManager.Update); err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		if errors.Is(err, capture.ErrPrivilegesDropped) {
+			resp.StatusCode = http.StatusConflict
+		}
+		resp.Error = err.Error()
+
+		c.AbortWithStatusJSON(resp.StatusCode, resp)
+		return
+	}
+
+	c.JSON(resp.StatusCode, resp)
+}
+
+func (server *Server) getConfigDiff(c *gin.Context) {
+	resp := &gpapi.ConfigDiffResponse{}
+	resp.StatusCode = http.StatusOK
+
+	if server.configMonitor == nil {
+		resp.StatusCode = http.StatusNotFound
+		resp.Error = errorNoConfigMonitor.Error()
+
+		c.AbortWithStatusJSON(resp.StatusCode, resp)
+		return
+	}
+	resp.Path = server.configMonitor.Path()
+
+	var err error
+	if resp.IfacesDiff, err = server.configMonitor.Diff(server.captureManager.Config()); err != nil {
 		resp.StatusCode = http.StatusInternalServerError
 		resp.Error = err.Error()
 
 		c.AbortWithStatusJSON(resp.StatusCode, resp)
 		return
 	}
+	resp.InSync = resp.IfacesDiff.InSync()
 
 	c.JSON(resp.StatusCode, resp)
 }
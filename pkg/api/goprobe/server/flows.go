@@ -0,0 +1,60 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	gpapi "github.com/els0r/goProbe/pkg/api/goprobe"
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errorInvalidTop   = errors.New("invalid number of flows")
+	errorInvalidSort  = errors.New("invalid sort order")
+	errorIfaceMissing = errors.New("interface is not being captured")
+)
+
+func (server *Server) getFlows(c *gin.Context) {
+	iface := c.Param(ifaceKey)
+
+	resp := &gpapi.FlowsResponse{Iface: iface}
+	resp.StatusCode = http.StatusOK
+
+	abort := func(statusCode int, err error) {
+		resp.StatusCode = statusCode
+		resp.Error = err.Error()
+
+		c.AbortWithStatusJSON(resp.StatusCode, resp)
+	}
+
+	if err := server.ifaceTenantScope(c, iface); err != nil {
+		abort(http.StatusForbidden, err)
+		return
+	}
+
+	top, err := strconv.Atoi(c.DefaultQuery(gpapi.FlowsTopQueryParam, strconv.Itoa(gpapi.DefaultFlowsTop)))
+	if err != nil || top <= 0 {
+		abort(http.StatusBadRequest, fmt.Errorf("%w: %q", errorInvalidTop, c.Query(gpapi.FlowsTopQueryParam)))
+		return
+	}
+
+	// only counters are available for live flows
+	sortParam := c.DefaultQuery(gpapi.FlowsSortQueryParam, gpapi.DefaultFlowsSort)
+	sortBy := results.SortOrderFromString(sortParam)
+	if sortBy != results.SortTraffic && sortBy != results.SortPackets {
+		abort(http.StatusBadRequest, fmt.Errorf("%w: %q", errorInvalidSort, sortParam))
+		return
+	}
+
+	var exists bool
+	resp.Flows, resp.Total, exists = server.captureManager.TopFlows(c.Request.Context(), iface, top, sortBy)
+	if !exists {
+		abort(http.StatusNotFound, fmt.Errorf("%w: %s", errorIfaceMissing, iface))
+		return
+	}
+
+	c.JSON(resp.StatusCode, resp)
+}
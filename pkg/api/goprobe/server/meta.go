@@ -0,0 +1,81 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/els0r/goProbe/pkg/api"
+	gpapi "github.com/els0r/goProbe/pkg/api/goprobe"
+	"github.com/els0r/goProbe/pkg/goDB"
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/gin-gonic/gin"
+)
+
+var errorDataMissing = errors.New("no data available")
+
+func (server *Server) getDBMeta(c *gin.Context) {
+	resp := &gpapi.DBMetaResponse{}
+	resp.StatusCode = http.StatusOK
+
+	abort := func(statusCode int, err error) {
+		resp.StatusCode = statusCode
+		resp.Error = err.Error()
+
+		c.AbortWithStatusJSON(resp.StatusCode, resp)
+	}
+
+	first, last, err := query.ParseTimeRange(
+		c.DefaultQuery(gpapi.DBMetaFirstQueryParam, gpapi.DefaultDBMetaFirst),
+		c.Query(gpapi.DBMetaLastQueryParam),
+	)
+	if err != nil {
+		abort(http.StatusBadRequest, err)
+		return
+	}
+
+	// the same tenant restrictions as for queries apply
+	tenant := c.Query(gpapi.DBMetaTenantQueryParam)
+	if err := info.ValidateTenant(tenant); err != nil {
+		abort(http.StatusBadRequest, err)
+		return
+	}
+	if err := api.TenantScope(server.keyTenants)(c, &query.Args{Tenant: tenant}); err != nil {
+		abort(http.StatusForbidden, err)
+		return
+	}
+
+	// only interfaces present in the DB are accepted (which also rules out names escaping it)
+	dbPath := info.TenantPath(server.dbPath, tenant)
+	available, err := info.GetInterfaces(dbPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			abort(http.StatusNotFound, errorDataMissing)
+			return
+		}
+		abort(http.StatusInternalServerError, err)
+		return
+	}
+	var ifaces []string
+	if ifaceList := c.Query(gpapi.IfacesQueryParam); ifaceList != "" {
+		ifaces = strings.Split(ifaceList, ",")
+		for _, iface := range ifaces {
+			if !slices.Contains(available, iface) {
+				abort(http.StatusNotFound, fmt.Errorf("%w for interface %s", errorDataMissing, iface))
+				return
+			}
+		}
+	}
+
+	resp.Days, err = goDB.ReadDayMetadata(dbPath, first, last, ifaces...)
+	if err != nil {
+		abort(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(resp.StatusCode, resp)
+}
@@ -13,7 +13,12 @@ func (server *Server) postQuery(c *gin.Context) {
 	api.RunQuery(
 		fmt.Sprintf("goProbe/%s", version.Short()),
 		"local DB",
-		engine.NewQueryRunnerWithLiveData(server.dbPath, server.captureManager),
+		engine.NewQueryRunnerWithLiveData(server.dbPath, server.captureManager,
+			engine.WithIdentity(server.identity),
+			engine.WithMaxBlocksPerSec(server.queryMaxBlocksPerSec),
+			engine.WithWorkers(server.queryWorkers, server.queryMaxWorkers),
+		),
 		c,
+		api.TenantScope(server.keyTenants),
 	)
 }
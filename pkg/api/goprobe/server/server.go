@@ -7,6 +7,10 @@ import (
 	"github.com/els0r/goProbe/pkg/api/server"
 	"github.com/els0r/goProbe/pkg/capture"
 	"github.com/els0r/goProbe/pkg/defaults"
+	"github.com/els0r/goProbe/pkg/goDB"
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	gplogging "github.com/els0r/goProbe/pkg/logging"
+	"github.com/els0r/goProbe/pkg/query"
 	"github.com/gin-gonic/gin"
 )
 
@@ -15,8 +19,17 @@ type Server struct {
 
 	// goprobe specific variables
 	dbPath         string
+	identity       info.Identity
+	keyTenants     map[string][]string
+	keyRoles       map[string]api.Role
 	captureManager *capture.Manager
 	configMonitor  *config.Monitor
+	snapshotPath   string
+	recentErrors   *gplogging.RecentLines
+
+	queryMaxBlocksPerSec int
+
+	queryWorkers, queryMaxWorkers int
 
 	*server.DefaultServer
 }
@@ -27,10 +40,58 @@ func (server *Server) SetDBPath(path string) *Server {
 	return server
 }
 
-// New creates a new goprobe API server
+// SetIdentity sets the host identity the query results are labeled with
+func (server *Server) SetIdentity(identity info.Identity) *Server {
+	server.identity = identity
+	return server
+}
+
+// SetKeyTenants restricts the tenants API keys may query (see api.TenantScope())
+func (server *Server) SetKeyTenants(keyTenants map[string][]string) *Server {
+	server.keyTenants = keyTenants
+	return server
+}
+
+// SetKeyRoles sets the roles of API keys. Endpoints modifying the running capture require
+// api.RoleAdmin (see api.RequireRole())
+func (server *Server) SetKeyRoles(keyRoles map[string]api.Role) *Server {
+	server.keyRoles = keyRoles
+	return server
+}
+
+// SetSnapshotPath sets the directory snapshots of the DB are created in
+func (server *Server) SetSnapshotPath(path string) *Server {
+	server.snapshotPath = path
+	return server
+}
+
+// SetRecentErrors sets the buffer of recent error log lines included in support bundles
+func (server *Server) SetRecentErrors(recentErrors *gplogging.RecentLines) *Server {
+	server.recentErrors = recentErrors
+	return server
+}
+
+// SetQueryMaxBlocksPerSec throttles the disk reads of queries which don't limit their read rate
+// themselves and caps the read rate of the ones that do (see engine.WithMaxBlocksPerSec())
+func (server *Server) SetQueryMaxBlocksPerSec(n int) *Server {
+	server.queryMaxBlocksPerSec = n
+	return server
+}
+
+// SetQueryWorkers sets the default number of workers per interface of queries which don't set it
+// themselves and the maximum a query may request (see engine.WithWorkers())
+func (server *Server) SetQueryWorkers(defaultWorkers, maxWorkers int) *Server {
+	server.queryWorkers = defaultWorkers
+	server.queryMaxWorkers = maxWorkers
+	return server
+}
+
+// New creates a new goprobe API server. If captureManager is nil (e.g. when serving an archived
+// DB), only the query and support bundle endpoints are available
 func New(addr string, captureManager *capture.Manager, configMonitor *config.Monitor, opts ...server.Option) *Server {
 	server := &Server{
 		dbPath:         defaults.DBPath,
+		snapshotPath:   goDB.DefaultSnapshotPath(defaults.DBPath),
 		captureManager: captureManager,
 		configMonitor:  configMonitor,
 		DefaultServer:  server.NewDefault(config.ServiceName, addr, opts...),
@@ -51,18 +112,64 @@ func (server *Server) registerRoutes() {
 	if limiter, hasLimiter := server.QueryRateLimiter(); hasLimiter {
 		queryHandlers = append(gin.HandlersChain{api.RateLimitMiddleware(limiter)}, queryHandlers...)
 	}
+	if accounting, hasAccounting := server.Accounting(); hasAccounting {
+		queryHandlers = append(gin.HandlersChain{api.AccountingMiddleware(accounting)}, queryHandlers...)
+	}
+	if exporter, hasExporter := server.Exporter(); hasExporter {
+		queryHandlers = append(gin.HandlersChain{api.ExportMiddleware(exporter)}, queryHandlers...)
+	}
 	router.GET(gpapi.QueryRoute, queryHandlers...)  // support for URL-encoded form data GET requests
 	router.POST(gpapi.QueryRoute, queryHandlers...) // support for JSON or form-data body POST requests
 
+	// support bundle
+	router.GET(gpapi.SupportBundleRoute, server.requireAdmin, server.getSupportBundle)
+
+	// block metadata
+	router.GET(gpapi.DBMetaRoute, server.getDBMeta)
+
+	// query usage per API key
+	if accounting, hasAccounting := server.Accounting(); hasAccounting {
+		router.GET(gpapi.UsageRoute, server.requireAdmin, api.UsageHandler(accounting))
+	}
+
+	// without a capture manager (archive mode), only the DB can be queried
+	if server.captureManager == nil {
+		return
+	}
+
 	// stats
 	statsRoutes := router.Group(gpapi.StatusRoute)
 	statsRoutes.GET("", server.getStatus)
 	statsRoutes.GET("/:"+ifaceKey, server.getStatus)
+	statsRoutes.GET("/:"+ifaceKey+gpapi.StatusHistoryRoute, server.getStatusHistory)
 
 	// config
 	configRoutes := router.Group(gpapi.ConfigRoute)
 	configRoutes.GET("", server.getConfig)
+	configRoutes.GET(gpapi.ConfigDiffRoute, server.requireAdmin, server.getConfigDiff)
 	configRoutes.GET("/:"+ifaceKey, server.getConfig)
-	configRoutes.PUT("", server.putConfig)
-	configRoutes.POST(gpapi.ConfigReloadRoute, server.reloadConfig)
+	configRoutes.PUT("", server.requireAdmin, server.putConfig)
+	configRoutes.POST(gpapi.ConfigReloadRoute, server.requireAdmin, server.reloadConfig)
+
+	// snapshots
+	router.POST(gpapi.SnapshotRoute, server.requireAdmin, server.postSnapshot)
+
+	// live flows
+	router.GET(gpapi.FlowsRoute+"/:"+ifaceKey, server.getFlows)
+}
+
+// ifaceTenantScope restricts access to the runtime state of an interface (e.g. its status or live
+// flows) to API keys permitted to query the tenant it is configured for (see api.TenantScope())
+func (server *Server) ifaceTenantScope(c *gin.Context, iface string) error {
+	var tenant string
+	if cfg, exists := server.captureManager.Config(iface)[iface]; exists {
+		tenant = cfg.Tenant
+	}
+	return api.TenantScope(server.keyTenants)(c, &query.Args{Tenant: tenant})
+}
+
+// requireAdmin restricts access to the endpoint to API keys with the admin role. The key roles are
+// looked up at request time since they are set after the routes have been registered
+func (server *Server) requireAdmin(c *gin.Context) {
+	api.RequireRole(server.keyRoles, api.RoleAdmin)(c)
 }
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/els0r/goProbe/cmd/goProbe/config"
+	gpapi "github.com/els0r/goProbe/pkg/api/goprobe"
+	"github.com/els0r/goProbe/pkg/api/server"
+	"github.com/els0r/goProbe/pkg/capture"
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/els0r/goProbe/pkg/goprobe/writeout"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIfaceTenantScope(t *testing.T) {
+	captureManager := capture.NewManager(writeout.NewGoDBHandler(t.TempDir(), encoders.EncoderTypeLZ4),
+		capture.WithSkipWriteoutSchedule(true),
+		capture.WithSourceInitFn(func(c *capture.Capture) (capture.Source, error) {
+			return nil, errors.New("no source available")
+		}),
+		capture.WithLinkState(func(iface string) capturetypes.LinkState {
+			return capturetypes.LinkStateDown
+		}),
+	)
+	defer captureManager.Close(context.Background())
+
+	ifaceCfg := func(tenant string) config.CaptureConfig {
+		return config.CaptureConfig{
+			RingBuffer: &config.RingBufferConfig{
+				BlockSize: config.DefaultRingBufferBlockSize,
+				NumBlocks: config.DefaultRingBufferNumBlocks,
+			},
+			Tenant: tenant,
+		}
+	}
+	_, _, _, err := captureManager.Update(context.Background(), config.Ifaces{
+		"eth0": ifaceCfg("customer-a"),
+		"eth1": ifaceCfg("customer-b"),
+	})
+	require.Nil(t, err)
+
+	s := New("127.0.0.1:0", captureManager, nil, server.WithKeys("restricted", "unrestricted")).
+		SetKeyTenants(map[string][]string{"restricted": {"customer-a"}})
+
+	get := func(key, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "digest "+key)
+		rec := httptest.NewRecorder()
+		s.Router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, route := range []string{
+		gpapi.FlowsRoute + "/eth1",
+		gpapi.StatusRoute + "/eth1",
+		gpapi.StatusRoute + "/eth1" + gpapi.StatusHistoryRoute,
+		gpapi.StatusRoute + "?" + gpapi.IfacesQueryParam + "=eth0,eth1",
+		gpapi.ConfigRoute + "/eth1",
+		gpapi.ConfigRoute + "?" + gpapi.IfacesQueryParam + "=eth0,eth1",
+	} {
+		t.Run(route, func(t *testing.T) {
+			require.Equal(t, http.StatusForbidden, get("restricted", route).Code)
+			require.NotEqual(t, http.StatusForbidden, get("unrestricted", route).Code)
+		})
+	}
+
+	// interfaces of the permitted tenant remain accessible
+	for _, route := range []string{
+		gpapi.FlowsRoute + "/eth0",
+		gpapi.StatusRoute + "/eth0",
+		gpapi.StatusRoute + "/eth0" + gpapi.StatusHistoryRoute,
+		gpapi.ConfigRoute + "/eth0",
+	} {
+		require.NotEqual(t, http.StatusForbidden, get("restricted", route).Code, route)
+	}
+
+	// interfaces of other tenants are omitted when fetching the status of all interfaces
+	var resp gpapi.StatusResponse
+	rec := get("restricted", gpapi.StatusRoute)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Nil(t, jsoniter.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Contains(t, resp.Statuses, "eth0")
+	require.NotContains(t, resp.Statuses, "eth1")
+
+	// the same applies to the configuration (only the interfaces with a running capture are listed,
+	// hence none of them in this setup)
+	var cfgResp gpapi.ConfigResponse
+	rec = get("restricted", gpapi.ConfigRoute)
+	require.NotEqual(t, http.StatusForbidden, rec.Code)
+	if rec.Code == http.StatusOK {
+		require.Nil(t, jsoniter.Unmarshal(rec.Body.Bytes(), &cfgResp))
+	}
+	require.NotContains(t, cfgResp.Ifaces, "eth1")
+
+	// the configuration diff covers all interfaces, hence it requires the admin role
+	require.Equal(t, http.StatusForbidden, get("restricted", gpapi.ConfigRoute+gpapi.ConfigDiffRoute).Code)
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	gpapi "github.com/els0r/goProbe/pkg/api/goprobe"
+	"github.com/els0r/goProbe/pkg/goDB"
+	"github.com/gin-gonic/gin"
+)
+
+// snapshotNameFormat denotes the format of the default snapshot name (derived from the current time)
+const snapshotNameFormat = "20060102T150405Z"
+
+var snapshotNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+var errorInvalidSnapshotName = errors.New("invalid snapshot name")
+
+func (server *Server) postSnapshot(c *gin.Context) {
+	resp := &gpapi.SnapshotResponse{}
+	resp.StatusCode = http.StatusOK
+
+	// the request body is optional
+	var req gpapi.SnapshotRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		resp.StatusCode = http.StatusBadRequest
+		resp.Error = err.Error()
+
+		c.AbortWithStatusJSON(resp.StatusCode, resp)
+		return
+	}
+	if req.Name == "" {
+		req.Name = time.Now().UTC().Format(snapshotNameFormat)
+	}
+	if !snapshotNameRegexp.MatchString(req.Name) {
+		resp.StatusCode = http.StatusBadRequest
+		resp.Error = fmt.Errorf("%w: %q", errorInvalidSnapshotName, req.Name).Error()
+
+		c.AbortWithStatusJSON(resp.StatusCode, resp)
+		return
+	}
+
+	var err error
+	if resp.SnapshotInfo, err = server.captureManager.Snapshot(c.Request.Context(), filepath.Join(server.snapshotPath, req.Name)); err != nil {
+		resp.StatusCode = http.StatusInternalServerError
+		if errors.Is(err, goDB.ErrSnapshotExists) {
+			resp.StatusCode = http.StatusConflict
+		}
+		resp.Error = err.Error()
+
+		c.AbortWithStatusJSON(resp.StatusCode, resp)
+		return
+	}
+
+	c.JSON(resp.StatusCode, resp)
+}
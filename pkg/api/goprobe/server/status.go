@@ -1,14 +1,19 @@
 package server
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	gpapi "github.com/els0r/goProbe/pkg/api/goprobe"
 	"github.com/gin-gonic/gin"
 )
 
+var errorInvalidHistoryPeriod = errors.New("invalid history period")
+
 func (server *Server) getStatus(c *gin.Context) {
 	iface := c.Param(ifaceKey)
 	ifaces := c.Request.URL.Query().Get(gpapi.IfacesQueryParam)
@@ -29,15 +34,31 @@ func (server *Server) getStatus(c *gin.Context) {
 
 	ctx := c.Request.Context()
 
+	var requested []string
 	if iface != "" {
-		resp.Statuses = server.captureManager.Status(ctx, iface)
-	} else {
-		if ifaces != "" {
-			// fetch all specified
-			resp.Statuses = server.captureManager.Status(ctx, strings.Split(ifaces, ",")...)
-		} else {
-			// otherwise, fetch all
-			resp.Statuses = server.captureManager.Status(ctx)
+		requested = []string{iface}
+	} else if ifaces != "" {
+		// fetch all specified
+		requested = strings.Split(ifaces, ",")
+	}
+
+	// interfaces of tenants the API key isn't permitted to query are rejected if requested explicitly
+	// and omitted otherwise
+	for _, requestedIface := range requested {
+		if err := server.ifaceTenantScope(c, requestedIface); err != nil {
+			resp.StatusCode = http.StatusForbidden
+			resp.Error = err.Error()
+
+			c.AbortWithStatusJSON(resp.StatusCode, resp)
+			return
+		}
+	}
+	resp.Statuses = server.captureManager.Status(ctx, requested...)
+	if len(requested) == 0 {
+		for statusIface := range resp.Statuses {
+			if err := server.ifaceTenantScope(c, statusIface); err != nil {
+				delete(resp.Statuses, statusIface)
+			}
 		}
 	}
 
@@ -47,3 +68,37 @@ func (server *Server) getStatus(c *gin.Context) {
 
 	c.JSON(resp.StatusCode, resp)
 }
+
+func (server *Server) getStatusHistory(c *gin.Context) {
+	iface := c.Param(ifaceKey)
+
+	resp := &gpapi.StatusHistoryResponse{Iface: iface}
+	resp.StatusCode = http.StatusOK
+
+	abort := func(statusCode int, err error) {
+		resp.StatusCode = statusCode
+		resp.Error = err.Error()
+
+		c.AbortWithStatusJSON(resp.StatusCode, resp)
+	}
+
+	if err := server.ifaceTenantScope(c, iface); err != nil {
+		abort(http.StatusForbidden, err)
+		return
+	}
+
+	last, err := time.ParseDuration(c.DefaultQuery(gpapi.StatusHistoryLastQueryParam, gpapi.DefaultStatusHistoryLast.String()))
+	if err != nil || last <= 0 {
+		abort(http.StatusBadRequest, fmt.Errorf("%w: %q", errorInvalidHistoryPeriod, c.Query(gpapi.StatusHistoryLastQueryParam)))
+		return
+	}
+
+	var exists bool
+	resp.History, exists = server.captureManager.History(iface, time.Now().Add(-last))
+	if !exists {
+		abort(http.StatusNotFound, fmt.Errorf("%w: %s", errorIfaceMissing, iface))
+		return
+	}
+
+	c.JSON(resp.StatusCode, resp)
+}
@@ -3,9 +3,13 @@ package api
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/wire"
 	"github.com/els0r/telemetry/logging"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
@@ -17,15 +21,23 @@ func LogAndAbort(ctx context.Context, c *gin.Context, code int, err error) {
 	logging.FromContext(ctx).Error(c.AbortWithError(code, err))
 }
 
-// RunQuery executes the query and returns its result
-func RunQuery(caller, sourceData string, querier query.Runner, c *gin.Context) {
+// RunQuery executes the query and returns its result. The query arguments are authorized by
+// the (optional) checks before the query is run. If the query requests an export of its result
+// (permitted by the ExportMiddleware), the result is written to the destination and only a handle
+// referencing it is returned
+func RunQuery(caller, sourceData string, querier query.Runner, c *gin.Context, checks ...ArgsCheck) {
 	ctx := c.Request.Context()
 
 	// Initialize default query args
 	var queryArgs = query.DefaultArgs()
 
-	// Attempt to parse args from request JSON body
-	if err := jsoniter.NewDecoder(c.Request.Body).Decode(queryArgs); err != nil {
+	// Attempt to parse args from request body (wire format or JSON)
+	if c.ContentType() == wire.ContentType {
+		if err := unmarshalWireArgs(c.Request.Body, queryArgs); err != nil {
+			LogAndAbort(ctx, c, http.StatusBadRequest, fmt.Errorf("failed to decode query args: %w", err))
+			return
+		}
+	} else if err := jsoniter.NewDecoder(c.Request.Body).Decode(queryArgs); err != nil {
 
 		// If that failed, attempt to bind the URL form data
 		if err = binding.Form.Bind(c.Request, queryArgs); err != nil {
@@ -42,20 +54,95 @@ func RunQuery(caller, sourceData string, querier query.Runner, c *gin.Context) {
 
 	logger := logging.FromContext(ctx)
 
+	for _, check := range checks {
+		if err := check(c, queryArgs); err != nil {
+			LogAndAbort(ctx, c, http.StatusForbidden, err)
+			return
+		}
+	}
+
+	// Validate the export destination (if any) before running a potentially expensive query
+	exporter, hasExporter := exporterFromContext(c)
+	if queryArgs.Export != "" {
+		if !hasExporter {
+			LogAndAbort(ctx, c, http.StatusBadRequest, ErrExportDisabled)
+			return
+		}
+		if _, err := exporter.target(queryArgs.Export, queryArgs.Tenant); err != nil {
+			LogAndAbort(ctx, c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
 	// Check if the statement can be created
 	logger.With("args", queryArgs).Info("running query")
-	_, err := queryArgs.Prepare()
+	stmt, err := queryArgs.Prepare()
 	if err != nil {
-		LogAndAbort(ctx, c, http.StatusBadRequest, fmt.Errorf("failed to prepare query statement: %w", err))
+		LogAndAbort(ctx, c, StatusCodeFromError(err), fmt.Errorf("failed to prepare query statement: %w", err))
 		return
 	}
 
 	result, err := querier.Run(ctx, queryArgs)
 	if err != nil {
-		LogAndAbort(ctx, c, http.StatusInternalServerError, fmt.Errorf("%s query failed: %w", sourceData, err))
+		LogAndAbort(ctx, c, StatusCodeFromError(err), fmt.Errorf("%s query failed: %w", sourceData, err))
+		return
+	}
+	// make the result available to the accounting of the resources used by the query
+	c.Set(queryResultContextKey, result)
+
+	// the enrichers run on the rows of the final result (they aren't forwarded by the runner)
+	if _, err := stmt.EnrichRows(ctx, result.Rows); err != nil {
+		LogAndAbort(ctx, c, http.StatusInternalServerError, fmt.Errorf("failed to enrich %s query result: %w", sourceData, err))
+		return
+	}
+
+	if queryArgs.Export != "" {
+		handle, err := exporter.Export(ctx, queryArgs.Export, queryArgs.Tenant, result)
+		if err != nil {
+			LogAndAbort(ctx, c, http.StatusInternalServerError, fmt.Errorf("failed to export %s query result: %w", sourceData, err))
+			return
+		}
+		logger.With("location", handle.Location, "size", handle.Size).Info("exported query result")
+		c.JSON(http.StatusCreated, handle)
 		return
 	}
 
-	// serialize raw result if json is selected
+	// serialize the result in the wire format if the client accepts it (e.g. another goProbe component),
+	// otherwise as JSON
+	if AcceptsContentType(c.GetHeader("Accept"), wire.ContentType) {
+		data, err := wire.MarshalResult(result)
+		if err != nil {
+			LogAndAbort(ctx, c, http.StatusInternalServerError, fmt.Errorf("failed to encode %s query result: %w", sourceData, err))
+			return
+		}
+		c.Data(http.StatusOK, wire.ContentType, data)
+		return
+	}
 	c.JSON(http.StatusOK, result)
 }
+
+func unmarshalWireArgs(body io.Reader, args *query.Args) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	return wire.UnmarshalArgs(data, args)
+}
+
+// AcceptsContentType returns whether the content type is explicitly listed in the Accept header
+// (wildcards aren't considered, since they denote JSON for all clients predating the wire format)
+func AcceptsContentType(accept, contentType string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(mediaType), contentType) {
+			continue
+		}
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if weight, err := strconv.ParseFloat(q, 64); err != nil || weight <= 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
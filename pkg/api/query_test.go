@@ -0,0 +1,95 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/goProbe/pkg/wire"
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+type staticRunner struct {
+	args   *query.Args
+	result *results.Result
+}
+
+func (r *staticRunner) Run(_ context.Context, args *query.Args) (*results.Result, error) {
+	r.args = args
+	return r.result, nil
+}
+
+func TestRunQueryWireFormat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	res := results.New()
+	res.Start()
+	res.Rows = results.Rows{{
+		Attributes: results.Attributes{SrcIP: netip.MustParseAddr("10.0.0.1")},
+		Counters:   types.Counters{BytesRcvd: 42},
+	}}
+	res.End()
+
+	runner := &staticRunner{result: res}
+	router := gin.New()
+	router.POST("/query", func(c *gin.Context) {
+		RunQuery("test", "static", runner, c)
+	})
+
+	args := query.NewArgs("sip", "eth0", query.WithCondition("dport = 443"))
+	request := func(contentType, accept string) *httptest.ResponseRecorder {
+		var (
+			body []byte
+			err  error
+		)
+		if contentType == wire.ContentType {
+			body, err = wire.MarshalArgs(args)
+		} else {
+			body, err = jsoniter.Marshal(args)
+		}
+		require.Nil(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/query", bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		require.Equal(t, args.Condition, runner.args.Condition)
+		return rec
+	}
+
+	for _, contentType := range []string{wire.ContentType, "application/json"} {
+		rec := request(contentType, wire.ContentType+", application/json;q=0.9")
+		require.Equal(t, wire.ContentType, rec.Header().Get("Content-Type"))
+		var decoded results.Result
+		require.Nil(t, wire.UnmarshalResult(rec.Body.Bytes(), &decoded))
+		require.Equal(t, res.Rows, decoded.Rows)
+
+		// clients not (explicitly) accepting the wire format receive JSON
+		for _, accept := range []string{"", "*/*", "application/json", wire.ContentType + ";q=0"} {
+			rec = request(contentType, accept)
+			require.Contains(t, rec.Header().Get("Content-Type"), "application/json")
+			decoded = results.Result{}
+			require.Nil(t, jsoniter.Unmarshal(rec.Body.Bytes(), &decoded))
+			require.Equal(t, res.Rows, decoded.Rows)
+		}
+	}
+
+	// invalid args in the wire format are rejected
+	req := httptest.NewRequest(http.MethodPost, "/query", bytes.NewReader([]byte{0xff}))
+	req.Header.Set("Content-Type", wire.ContentType)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -9,9 +9,11 @@ import (
 
 	"github.com/els0r/goProbe/pkg/api"
 	"github.com/els0r/goProbe/pkg/goDB/info"
+	imetrics "github.com/els0r/goProbe/pkg/metrics"
 	"github.com/els0r/goProbe/pkg/telemetry/metrics"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"golang.org/x/time/rate"
 )
 
@@ -30,8 +32,12 @@ type Option func(*DefaultServer)
 // re-used across binaries serving an API
 type DefaultServer struct {
 	// api handling
-	// TODO: authorize API access
-	keys []string
+	keys       []string
+	keyQuotas  map[string]api.KeyQuota
+	accounting *api.Accounting
+
+	// exporter writes query results to server-side destinations (if enabled)
+	exporter *api.Exporter
 
 	debug bool
 
@@ -46,10 +52,14 @@ type DefaultServer struct {
 	// global rate limiting for queries
 	queryRateLimiter *rate.Limiter
 
+	// maxRequestBodySize denotes the maximum size of decompressed request bodies
+	maxRequestBodySize int64
+
 	srv    *http.Server
 	router *gin.Engine
 
 	unixSocketFile string
+	listener       net.Listener
 }
 
 // WithDebugMode runs the gin server in debug mode (e.g. not setting the release mode)
@@ -84,6 +94,46 @@ func WithQueryRateLimit(r rate.Limit, b int) Option {
 	}
 }
 
+// WithMaxRequestBodySize limits the size of compressed request bodies once decompressed (in bytes).
+// If unset (or non-positive), api.DefaultMaxRequestBodySize applies
+func WithMaxRequestBodySize(size int64) Option {
+	return func(server *DefaultServer) {
+		server.maxRequestBodySize = size
+	}
+}
+
+// WithKeys restricts API access to requests authorized with one of the keys. If no keys
+// are provided, access is unrestricted
+func WithKeys(keys ...string) Option {
+	return func(server *DefaultServer) {
+		server.keys = keys
+	}
+}
+
+// WithKeyQuotas enforces daily quotas on the queries run with the respective API keys. It
+// only takes effect if API keys are configured (see WithKeys)
+func WithKeyQuotas(quotas map[string]api.KeyQuota) Option {
+	return func(server *DefaultServer) {
+		server.keyQuotas = quotas
+	}
+}
+
+// WithExport allows queries to export their results to the destinations permitted by the
+// configuration instead of returning them (see query.Args.Export)
+func WithExport(cfg api.ExportConfig) Option {
+	return func(server *DefaultServer) {
+		server.exporter = api.NewExporter(cfg)
+	}
+}
+
+// WithListener serves the API on an existing listener (e.g. one passed via systemd socket activation)
+// instead of binding to the server address
+func WithListener(listener net.Listener) Option {
+	return func(server *DefaultServer) {
+		server.listener = listener
+	}
+}
+
 // NewDefault creates a new API server
 func NewDefault(serviceName, addr string, opts ...Option) *DefaultServer {
 	s := &DefaultServer{
@@ -110,6 +160,11 @@ func NewDefault(serviceName, addr string, opts ...Option) *DefaultServer {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	// queries are accounted per API key, so accounting requires keys to be configured
+	if len(s.keys) > 0 {
+		s.accounting = api.NewAccounting(s.keyQuotas)
+	}
+
 	s.registerMiddlewares()
 
 	return s
@@ -125,25 +180,45 @@ func (server *DefaultServer) QueryRateLimiter() (*rate.Limiter, bool) {
 	return server.queryRateLimiter, server.queryRateLimiter != nil
 }
 
+// Exporter returns the exporter of query results, if enabled (if not it returns nil and false)
+func (server *DefaultServer) Exporter() (*api.Exporter, bool) {
+	return server.exporter, server.exporter != nil
+}
+
+// Accounting returns the accounting of the queries per API key, if enabled (if not it returns nil and false)
+func (server *DefaultServer) Accounting() (*api.Accounting, bool) {
+	return server.accounting, server.accounting != nil
+}
+
 func (server *DefaultServer) registerMiddlewares() {
 	server.router.Use(
 		api.TraceIDMiddleware(),
 		api.RequestLoggingMiddleware(),
 		api.RecursionDetectorMiddleware(RuntimeIDHeaderKey, info.RuntimeID()),
 	)
+	if len(server.keys) > 0 {
+		server.router.Use(api.AuthMiddleware(server.keys...))
+	}
 
 	if server.metrics {
 		buckets := prometheus.DefBuckets
 		if len(server.requestDurationBuckets) > 0 {
 			buckets = server.requestDurationBuckets
 		}
-		metrics.NewPrometheus(server.serviceName, "api").
+		// the internal metrics of all modules are exposed alongside the API metrics
+		metrics.NewPrometheus(server.serviceName, "api",
+			imetrics.NewPrometheusCollector(imetrics.DefaultRegistry),
+		).
 			WithRequestDurationBuckets(buckets).
 			Register(server.router)
 	}
 	if server.profiling {
 		api.RegisterProfiling(server.router)
 	}
+
+	// query results easily reach tens of MB, so responses are compressed if the client supports it. Since
+	// it is registered last, the sizes reported by the metrics and logs are the ones of the compressed responses
+	server.router.Use(api.CompressionMiddleware(server.maxRequestBodySize))
 }
 
 const headerTimeout = 30 * time.Second
@@ -151,10 +226,16 @@ const headerTimeout = 30 * time.Second
 // Serve starts the API server after adding additional (optional) routes
 func (server *DefaultServer) Serve() error {
 	server.srv = &http.Server{
-		Handler:           server.router.Handler(),
+		// extract the trace context of incoming requests (if any)
+		Handler:           otelhttp.NewHandler(server.router.Handler(), server.serviceName),
 		ReadHeaderTimeout: headerTimeout,
 	}
 
+	// serve on externally provided listener
+	if server.listener != nil {
+		return server.srv.Serve(server.listener)
+	}
+
 	// listen on UNIX socket
 	if server.unixSocketFile != "" {
 		listener, err := net.Listen("unix", server.unixSocketFile)
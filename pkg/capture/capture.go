@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"runtime"
 	"sync"
 	"time"
 
 	"github.com/els0r/goProbe/cmd/goProbe/config"
 	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/results"
 	"github.com/els0r/goProbe/pkg/types/hashmap"
 	"github.com/els0r/telemetry/logging"
 	"github.com/fako1024/slimcap/capture"
@@ -108,6 +111,10 @@ type Capture struct {
 	// stats from the last rotation or reset (needed for Status)
 	stats capturetypes.CaptureStats
 
+	// restoredStats holds the stats accumulated before a restart whose flows were restored (they
+	// are reported along with the first rotation)
+	restoredStats *capturetypes.CaptureStats
+
 	// Rotation state synchronization
 	capLock *captureLock
 
@@ -115,6 +122,10 @@ type Capture struct {
 	// flows are retained even after Rotate has been called)
 	flowLog *FlowLog
 
+	// Packets matching any of the exclusion rules are dropped before being added to the flow log
+	exclusions  exclusionFilter
+	numExcluded uint64
+
 	// Generic handle / source for packet capture
 	captureHandle Source
 	sourceInitFn  sourceInitFn
@@ -140,6 +151,20 @@ func newCapture(iface string, config config.CaptureConfig) *Capture {
 	}
 }
 
+// restoreTotals continues the total counters of the capture from a persisted state
+func (c *Capture) restoreTotals(state info.IfaceCaptureState) {
+	c.stats.ReceivedTotal = state.ReceivedTotal
+	c.stats.ProcessedTotal = state.ProcessedTotal
+	c.stats.DroppedTotal = state.DroppedTotal
+}
+
+// restoreFlows continues the flow log of the capture from a persisted state (along with the stats
+// accumulated alongside it)
+func (c *Capture) restoreFlows(state ifaceFlowState) {
+	c.flowLog = state.flowLog
+	c.restoredStats = &state.Stats
+}
+
 // SetSourceInitFn sets a custom function used to initialize a new capture
 func (c *Capture) SetSourceInitFn(fn sourceInitFn) *Capture {
 	c.sourceInitFn = fn
@@ -153,6 +178,12 @@ func (c *Capture) Iface() string {
 
 func (c *Capture) run() (err error) {
 
+	// Prepare the exclusion rules (if any)
+	c.exclusions, err = newExclusionFilter(c.config.Exclude)
+	if err != nil {
+		return fmt.Errorf("failed to parse exclusion rules: %w", err)
+	}
+
 	// Set up the packet source and capturing
 	c.captureHandle, err = c.sourceInitFn(c)
 	if err != nil {
@@ -181,7 +212,9 @@ func (c *Capture) close() error {
 	return nil
 }
 
-func (c *Capture) rotate(ctx context.Context) (agg *hashmap.AggFlowMap) {
+// rotate rotates the flow log of the capture. If sampleRate is positive, a 1:sampleRate sample of
+// the individual flows is returned in addition (see FlowLog.RotateSampled)
+func (c *Capture) rotate(ctx context.Context, sampleRate int) (agg *hashmap.ShardedAggFlowMap, samples []results.ExtendedRow) {
 
 	logger := logging.FromContext(ctx)
 
@@ -189,12 +222,12 @@ func (c *Capture) rotate(ctx context.Context) (agg *hashmap.AggFlowMap) {
 		logger.Debug("there are currently no flow records available")
 		return
 	}
-	agg = c.flowLog.Rotate()
+	agg, samples = c.flowLog.RotateSampled(sampleRate)
 
 	return
 }
 
-func (c *Capture) flowMap(ctx context.Context) (agg *hashmap.AggFlowMap) {
+func (c *Capture) flowMap(ctx context.Context) (agg *hashmap.ShardedAggFlowMap) {
 
 	logger := logging.FromContext(ctx)
 
@@ -224,6 +257,16 @@ func (c *Capture) process() <-chan error {
 			c.wgProc.Done()
 		}()
 
+		// The CPU affinity and niceness apply to the OS thread, hence the capture is locked to
+		// it. The thread isn't unlocked, so that it is terminated along with the capture instead
+		// of being reused for other goroutines
+		if c.config.Scheduling != nil {
+			runtime.LockOSThread()
+			if err := applyScheduling(*c.config.Scheduling); err != nil {
+				captureErrors <- err
+			}
+		}
+
 		// Main packet capture loop which an interface should be in most of the time
 		localBuf := new(LocalBuffer)
 		for {
@@ -332,6 +375,13 @@ func (c *Capture) capturePacket() error {
 
 func (c *Capture) addToFlowLog(epHash capturetypes.EPHash, pktType byte, pktSize uint32, isIPv4 bool, auxInfo byte, errno capturetypes.ParsingErrno) {
 
+	// Drop excluded traffic before it is aggregated
+	if c.exclusions != nil && errno == capturetypes.ErrnoOK && c.exclusions.excludes(&epHash, isIPv4) {
+		c.numExcluded++
+		c.stats.Processed++
+		return
+	}
+
 	// Parse / add the received data to the map of flows
 	errno = c.flowLog.Add(epHash, pktType, pktSize, isIPv4, auxInfo, errno)
 	c.stats.Processed++
@@ -363,6 +413,8 @@ func (c *Capture) status() (*capturetypes.CaptureStats, error) {
 	packetsProcessed.Add(float64(c.stats.Processed))
 	packetsDropped.Add(float64(stats.PacketsDropped))
 	captureErrors.Add(float64(c.stats.ParsingErrors.Sum()))
+	packetsExcluded.Add(float64(c.numExcluded))
+	c.numExcluded = 0
 
 	res := capturetypes.CaptureStats{
 		StartedAt:      c.startedAt,
@@ -372,8 +424,19 @@ func (c *Capture) status() (*capturetypes.CaptureStats, error) {
 		ProcessedTotal: c.stats.ProcessedTotal,
 		Dropped:        stats.PacketsDropped,
 		DroppedTotal:   c.stats.DroppedTotal,
+		Overruns:       stats.QueueFreezes,
 		ParsingErrors:  c.stats.ParsingErrors,
 	}
+	if c.restoredStats != nil {
+		res.Received += c.restoredStats.Received
+		res.Processed += c.restoredStats.Processed
+		res.Dropped += c.restoredStats.Dropped
+		res.Overruns += c.restoredStats.Overruns
+		for i, n := range c.restoredStats.ParsingErrors {
+			res.ParsingErrors[i] += n
+		}
+		c.restoredStats = nil
+	}
 
 	c.stats.Processed = 0
 	c.stats.ParsingErrors.Reset()
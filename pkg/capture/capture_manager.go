@@ -2,21 +2,45 @@ package capture
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/els0r/goProbe/cmd/goProbe/config"
+	"github.com/els0r/goProbe/pkg/aggregator"
 	"github.com/els0r/goProbe/pkg/capture/capturetypes"
 	"github.com/els0r/goProbe/pkg/goDB"
 	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/els0r/goProbe/pkg/goDB/info"
 	"github.com/els0r/goProbe/pkg/goprobe/writeout"
+	"github.com/els0r/goProbe/pkg/privileges"
+	"github.com/els0r/goProbe/pkg/results"
 	"github.com/els0r/goProbe/pkg/types/hashmap"
 	"github.com/els0r/telemetry/logging"
 )
 
-const allowedWriteoutDurationFraction = 0.1
+const (
+	allowedWriteoutDurationFraction = 0.1
+
+	// maxMissedWriteouts denotes the number of scheduled writeouts that may be missed before
+	// the capture manager is considered unhealthy
+	maxMissedWriteouts = 2
+)
+
+var (
+	errorWriteoutsStalled = errors.New("scheduled writeouts stalled")
+	errorNoDBPath         = errors.New("no DB path configured")
+
+	// ErrPrivilegesDropped is returned if interfaces are added or changed after the privileges
+	// required to open them were dropped
+	ErrPrivilegesDropped = errors.New("interfaces can't be opened after dropping privileges")
+)
 
 // Manager manages a set of Capture instances.
 // Each interface can be associated with up to one Capture.
@@ -29,9 +53,73 @@ type Manager struct {
 
 	lastAppliedConfig config.Ifaces
 
+	// dbPath denotes the DB the interface aliases are stored in upon each configuration update
+	// (if empty, they aren't stored)
+	dbPath string
+
+	// instance denotes the name of this instance if multiple goProbe instances write to the same DB,
+	// telling apart the files stored by each of them. ifaceLocks holds the locks of the subtrees of
+	// the captured interfaces (only acquired if a DB path is set)
+	instance   string
+	ifaceLocks map[string]*goDB.IfaceLock
+
+	// lockOpts apply the permissions and owner of the DB to the lock files created in it
+	lockOpts []goDB.LockOption
+
 	lastRotation time.Time
 	startedAt    time.Time
 
+	// ifaceStates tracks the runtime stats of each interface as of its last writeout, so that they
+	// can be persisted upon shutdown. restoredStates holds the stats persisted by the previous run
+	// (consumed when the interface capture is first started)
+	ifaceStates    map[string]info.IfaceCaptureState
+	restoredStates map[string]info.IfaceCaptureState
+	stateLock      sync.Mutex
+
+	// statsHistories holds the statistics of the most recent rotations of each interface (up to
+	// statsHistorySize rotations per interface), protected by the stateLock
+	statsHistories   map[string]*statsHistory
+	statsHistorySize int
+
+	// flowStateMaxAge denotes the maximum age of the flows persisted upon shutdown for them to be
+	// restored upon the next start (if zero, the flows are written out upon shutdown instead).
+	// restoredFlows holds the flows restored (consumed when the interface capture is first started)
+	flowStateMaxAge time.Duration
+	restoredFlows   map[string]ifaceFlowState
+
+	// writeoutQueueLength denotes the number of rotated interfaces which may be queued for the
+	// writeout handler before the rotation of further interfaces blocks
+	writeoutQueueLength int
+
+	// writeoutStagger denotes the duration the scheduled rotations and writeouts of the interfaces
+	// are spread across (if zero, all interfaces are written out at once)
+	writeoutStagger time.Duration
+
+	// writeoutLock serializes writeouts, allowing them to be suspended while a snapshot of the DB
+	// is taken
+	writeoutLock sync.Mutex
+
+	// selfTraffic identifies the flows caused by goProbe itself, which are separated from the flows
+	// of the captured interfaces upon rotation (if set)
+	selfTraffic *SelfTraffic
+
+	// processAttributor attributes the flows of the interfaces with process attribution enabled to
+	// the local processes owning their sockets (if supported on this platform)
+	processAttributor ProcessAttributor
+
+	// flowSampleRate denotes the rate (1:N) at which individual flows are sampled upon rotation
+	// in addition to their aggregation (if zero, no flows are sampled)
+	flowSampleRate int
+
+	// linkStateFn determines the state of the link of an interface upon rotation. linkStates holds
+	// the last known state of each interface, protected by the stateLock
+	linkStateFn linkStateFn
+	linkStates  map[string]capturetypes.LinkState
+
+	// privilegesDropped denotes that the process can no longer open interfaces, hence the set of
+	// captured interfaces is fixed (they may only be removed)
+	privilegesDropped bool
+
 	skipWriteoutSchedule bool
 }
 
@@ -58,9 +146,105 @@ func InitManager(ctx context.Context, config *config.Config, opts ...ManagerOpti
 	writeoutHandler := writeout.NewGoDBHandler(config.DB.Path, encoderType).
 		WithSyslogWriting(config.SyslogFlows).
 		WithPermissions(dbPermissions)
+	if config.DB.DirPermissions != 0 {
+		writeoutHandler.WithDirPermissions(config.DB.DirPermissions)
+	}
+	lockOpts := []goDB.LockOption{goDB.WithLockPermissions(dbPermissions, config.DB.DirPermissions)}
+	if config.DB.Owner != "" {
+		owner, err := privileges.Lookup(config.DB.Owner)
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine owner of the database: %w", err)
+		}
+		writeoutHandler.WithOwner(owner.UID, owner.GID)
+		lockOpts = append(lockOpts, goDB.WithLockOwner(owner.UID, owner.GID))
+	} else if config.RunAs != "" {
+		// the lock files (and tenant directories) are created before the privileges are dropped, hence
+		// they have to be accessible by the user goProbe runs as afterwards
+		runAs, err := privileges.Lookup(config.RunAs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine user to run as: %w", err)
+		}
+		lockOpts = append(lockOpts, goDB.WithLockOwner(runAs.UID, runAs.GID))
+	}
+	if config.DB.Preallocate {
+		writeoutHandler.WithPreallocation(true)
+	}
+	if config.DB.MirrorPath != "" {
+		writeoutHandler.WithMirror(config.DB.MirrorPath)
+	}
+	if config.DB.MaxPendingWriteouts != 0 {
+		writeoutHandler.WithWriteoutRetries(config.DB.MaxPendingWriteouts)
+	}
+	if config.DB.WriteoutWorkers > 0 {
+		writeoutHandler.WithWorkers(config.DB.WriteoutWorkers)
+	}
+	if config.DB.FlowSampling != nil {
+		samplesPath := config.DB.FlowSampling.Path
+		if samplesPath == "" {
+			samplesPath = writeout.DefaultSamplesPath(config.DB.Path)
+		}
+		writeoutHandler.WithSampleSink(writeout.NewSampleSink(samplesPath).WithPermissions(dbPermissions))
+	}
+	if config.Stream != nil {
+		writeoutHandler.WithStreamSink(writeout.NewStreamSink(
+			writeout.NewNATSPublisher(config.Stream.Address), config.Stream.Subject),
+		)
+	}
+
+	if len(config.DB.WriteoutHooks) > 0 {
+		writeoutHandler.WithHooks(writeoutHooks(config.DB.WriteoutHooks)...)
+	}
+
+	// Stream the flows to a central aggregator (if configured), writing them to the local DB as well
+	// unless the host has no storage for it
+	var handler writeout.Handler = writeoutHandler
+	if config.Aggregator != nil {
+		client, err := newAggregatorClient(config)
+		if err != nil {
+			return nil, err
+		}
+		if !config.Aggregator.SkipLocalDB {
+			client.WithLocalHandler(writeoutHandler)
+		}
+		handler = client
+	}
 
 	// Initialize the CaptureManager
-	captureManager := NewManager(writeoutHandler, opts...)
+	captureManager := NewManager(handler, opts...)
+	captureManager.dbPath = config.DB.Path
+	captureManager.instance = config.DB.Instance
+	captureManager.lockOpts = lockOpts
+	if config.DB.WriteoutQueueLength > 0 {
+		captureManager.writeoutQueueLength = config.DB.WriteoutQueueLength
+	}
+	if config.DB.WriteoutStagger > 0 {
+		captureManager.writeoutStagger = time.Duration(config.DB.WriteoutStagger) * time.Second
+	}
+	if config.DB.FlowStateMaxAge > 0 {
+		captureManager.flowStateMaxAge = time.Duration(config.DB.FlowStateMaxAge) * time.Second
+	}
+	if config.DB.FlowSampling != nil {
+		captureManager.flowSampleRate = config.DB.FlowSampling.Rate
+	}
+
+	// Separate goProbe's own traffic from the captured one (if configured)
+	if config.SelfMonitoring != nil && captureManager.selfTraffic == nil {
+		captureManager.selfTraffic = newSelfTrafficFromConfig(ctx, config)
+		logging.FromContext(ctx).With(
+			"mode", config.SelfMonitoring.Mode,
+			"endpoints", captureManager.selfTraffic.Len(),
+		).Info("enabled self monitoring")
+	}
+
+	// Attribute flows to local processes (for the interfaces enabling it) using the proc filesystem
+	// unless a custom attributor is provided
+	if captureManager.processAttributor == nil {
+		captureManager.processAttributor = newDefaultProcessAttributor()
+	}
+
+	// Restore the runtime state and flows persisted upon the last shutdown (if any)
+	captureManager.restoreState(ctx)
+	captureManager.restoreFlows(ctx, config.Interfaces)
 
 	// Update (i.e. start) all capture routines (implicitly by reloading all configurations) and schedule
 	// DB writeouts
@@ -79,12 +263,71 @@ func InitManager(ctx context.Context, config *config.Config, opts ...ManagerOpti
 	return captureManager, nil
 }
 
+// newAggregatorClient creates the client streaming the flows to the aggregator, attributing them to
+// the identity of the host
+func newAggregatorClient(config *config.Config) (*aggregator.Client, error) {
+	var identity info.Identity
+	if config.Identity != nil {
+		identity = info.Identity{
+			Hostname: config.Identity.Hostname,
+			HostID:   config.Identity.HostID,
+		}
+	}
+	identity, err := info.DetectIdentity(config.DB.Path, identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine host identity for aggregator: %w", err)
+	}
+
+	var opts []aggregator.ClientOption
+	if config.Aggregator.TLS != nil {
+		tlsConfig, err := config.Aggregator.TLS.ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up TLS for aggregator: %w", err)
+		}
+		opts = append(opts, aggregator.WithClientTLS(tlsConfig))
+	}
+	if config.Aggregator.Token != "" {
+		opts = append(opts, aggregator.WithClientToken(config.Aggregator.Token))
+	}
+
+	client, err := aggregator.NewClient(config.Aggregator.Address, identity, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return client.WithBatchSize(config.Aggregator.BatchSize), nil
+}
+
+// writeoutHooks creates the writeout hooks from their configuration
+func writeoutHooks(cfgs []config.WriteoutHookConfig) []writeout.Hook {
+	hooks := make([]writeout.Hook, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		timeout := writeout.DefaultHookTimeout
+		if cfg.Timeout > 0 {
+			timeout = time.Duration(cfg.Timeout) * time.Second
+		}
+		if len(cfg.Exec) > 0 {
+			hooks = append(hooks, writeout.NewExecHook(cfg.Exec...).WithTimeout(timeout))
+			continue
+		}
+		hooks = append(hooks, writeout.NewWebhookHook(cfg.URL).WithHeaders(cfg.Headers).WithTimeout(timeout))
+	}
+	return hooks
+}
+
 // NewManager creates a new CaptureManager
 func NewManager(writeoutHandler writeout.Handler, opts ...ManagerOption) *Manager {
 	captureManager := &Manager{
 		captures:        newCaptures(),
 		writeoutHandler: writeoutHandler,
 		sourceInitFn:    defaultSourceInitFn,
+		ifaceStates:     make(map[string]info.IfaceCaptureState),
+		ifaceLocks:      make(map[string]*goDB.IfaceLock),
+		statsHistories:  make(map[string]*statsHistory),
+		linkStateFn:     ifaceLinkState,
+		linkStates:      make(map[string]capturetypes.LinkState),
+
+		statsHistorySize:    DefaultStatsHistorySize,
+		writeoutQueueLength: writeout.WriteoutsChanDepth,
 	}
 	for _, opt := range opts {
 		opt(captureManager)
@@ -121,6 +364,31 @@ func (cm *Manager) GetTimestamps() (startedAt, lastRotation time.Time) {
 	return
 }
 
+// CheckHealth verifies that the capture manager is responsive: all captures must provide their
+// status (i.e. they are not stuck while holding their lock) and scheduled writeouts must not have
+// stalled. Intended for periodic liveness checks (e.g. a systemd watchdog)
+func (cm *Manager) CheckHealth(ctx context.Context) error {
+	startedAt, lastRotation := cm.GetTimestamps()
+
+	if !cm.skipWriteoutSchedule {
+		lastActivity := lastRotation
+		if lastActivity.Before(startedAt) {
+			lastActivity = startedAt
+		}
+
+		// the first writeout happens once the next writeout interval is reached, hence an
+		// additional interval is tolerated
+		maxAge := time.Duration(maxMissedWriteouts+1) * time.Duration(goDB.DBWriteInterval) * time.Second
+		if age := time.Since(lastActivity); age > maxAge {
+			return fmt.Errorf("%w: last writeout %s ago", errorWriteoutsStalled, age.Round(time.Second))
+		}
+	}
+
+	_ = cm.Status(ctx)
+
+	return ctx.Err()
+}
+
 // ScheduleWriteouts creates a new goroutine that executes a DB writeout in defined time
 // intervals
 func (cm *Manager) ScheduleWriteouts(ctx context.Context, interval time.Duration) {
@@ -153,8 +421,10 @@ func (cm *Manager) ScheduleWriteouts(ctx context.Context, interval time.Duration
 				return
 			default:
 				t0 := time.Now()
-				cm.performWriteout(ctx, t)
-				if elapsed := float64(time.Since(t0)); elapsed > allowedWriteoutDurationFraction*float64(interval) {
+				cm.performStaggeredWriteout(ctx, t, cm.writeoutStagger)
+
+				// the stagger is intended, hence it doesn't count towards the writeout duration
+				if elapsed := float64(time.Since(t0) - cm.writeoutStagger); elapsed > allowedWriteoutDurationFraction*float64(interval) {
 					logger.Warnf("writeouts took longer than %.1f%% of the writeout interval (%.1f%%)",
 						100*allowedWriteoutDurationFraction,
 						100.*elapsed/float64(interval))
@@ -184,6 +454,65 @@ func WithSkipWriteoutSchedule(skip bool) ManagerOption {
 	}
 }
 
+// WithWriteoutQueueLength sets the number of rotated interfaces which may be queued for the
+// writeout handler before the rotation of further interfaces blocks
+func WithWriteoutQueueLength(n int) ManagerOption {
+	return func(cm *Manager) {
+		if n > 0 {
+			cm.writeoutQueueLength = n
+		}
+	}
+}
+
+// WithWriteoutStagger spreads the scheduled rotations and writeouts of the interfaces across the given
+// duration (which should be well below the writeout interval) instead of writing them out at once,
+// smoothing the disk I/O spikes of hosts with many interfaces
+func WithWriteoutStagger(stagger time.Duration) ManagerOption {
+	return func(cm *Manager) {
+		cm.writeoutStagger = max(stagger, 0)
+	}
+}
+
+// WithSelfTraffic separates the flows caused by goProbe itself from the flows of the captured
+// interfaces upon rotation (recording them in a pseudo-interface or discarding them)
+func WithSelfTraffic(s *SelfTraffic) ManagerOption {
+	return func(cm *Manager) {
+		cm.selfTraffic = s
+	}
+}
+
+// WithProcessAttributor sets the attributor used to attribute the flows of the interfaces with
+// process attribution enabled to local processes (replacing the default, proc filesystem based one)
+func WithProcessAttributor(a ProcessAttributor) ManagerOption {
+	return func(cm *Manager) {
+		cm.processAttributor = a
+	}
+}
+
+// WithStatsHistorySize sets the number of rotations kept in the statistics history of each
+// interface (if zero, no history is kept)
+func WithStatsHistorySize(n int) ManagerOption {
+	return func(cm *Manager) {
+		cm.statsHistorySize = n
+	}
+}
+
+// WithFlowSampling samples 1:rate individual flows upon rotation, which are passed on to the
+// writeout handler along with the aggregated flows. A rate <= 0 disables sampling
+func WithFlowSampling(rate int) ManagerOption {
+	return func(cm *Manager) {
+		cm.flowSampleRate = max(rate, 0)
+	}
+}
+
+// WithLinkState sets a custom function used to determine the state of the link of an interface
+// (replacing the default, interface flag based one)
+func WithLinkState(fn func(iface string) capturetypes.LinkState) ManagerOption {
+	return func(cm *Manager) {
+		cm.linkStateFn = fn
+	}
+}
+
 // Config returns the runtime config of the capture manager for all (or a set of) interfaces
 func (cm *Manager) Config(ifaces ...string) (ifaceConfigs config.Ifaces) {
 	cm.RLock()
@@ -212,6 +541,14 @@ func (cm *Manager) Status(ctx context.Context, ifaces ...string) (statusmap capt
 
 	statusmap = make(capturetypes.InterfaceStats)
 
+	// Configured interfaces without a running capture are reported along with the state of their link
+	// (if known), e.g. in case they vanished
+	for _, iface := range cm.missingIfaces(ifaces...) {
+		if linkState := cm.linkState(iface); linkState != capturetypes.LinkStateUnknown {
+			statusmap[iface] = capturetypes.CaptureStats{LinkState: linkState}
+		}
+	}
+
 	// Build list of interfaces to process (either from all interfaces or from explicit list)
 	// If none are provided / are available, return empty map
 	if ifaces = cm.captures.Ifaces(ifaces...); len(ifaces) == 0 {
@@ -243,6 +580,7 @@ func (cm *Manager) Status(ctx context.Context, ifaces ...string) (statusmap capt
 				logging.FromContext(runCtx).Errorf("failed to get capture stats: %v", err)
 				return
 			}
+			status.LinkState = cm.linkState(mc.iface)
 
 			statusmapMutex.Lock()
 			statusmap[mc.iface] = *status
@@ -259,6 +597,29 @@ func (cm *Manager) Status(ctx context.Context, ifaces ...string) (statusmap capt
 	return
 }
 
+// SetPrivilegesDropped marks the privileges required to open interfaces as dropped. From then on,
+// updates adding or changing interfaces are rejected with ErrPrivilegesDropped
+func (cm *Manager) SetPrivilegesDropped() {
+	cm.Lock()
+	cm.privilegesDropped = true
+	cm.Unlock()
+}
+
+// CheckUpdate returns ErrPrivilegesDropped if an update enabling or updating (i.e. re-opening) any of the
+// given interfaces can't be applied since the privileges were dropped
+func (cm *Manager) CheckUpdate(enabled, updated []string) error {
+	cm.Lock()
+	privilegesDropped := cm.privilegesDropped
+	cm.Unlock()
+
+	if privilegesDropped && len(enabled)+len(updated) > 0 {
+		sort.Strings(enabled)
+		sort.Strings(updated)
+		return fmt.Errorf("%w: added %v, updated %v", ErrPrivilegesDropped, enabled, updated)
+	}
+	return nil
+}
+
 // Update the configuration for all (or a set of) interfaces
 func (cm *Manager) Update(ctx context.Context, ifaces config.Ifaces) (enabled, updated, disabled []string, err error) {
 	// Validate the config before doing anything else
@@ -292,6 +653,10 @@ func (cm *Manager) Update(ctx context.Context, ifaces config.Ifaces) (enabled, u
 	}
 	cm.Unlock()
 
+	if err = cm.CheckUpdate(enableIfaces, updateIfaces); err != nil {
+		return nil, nil, nil, err
+	}
+
 	for iface := range cm.captures.Map {
 		if _, exists := ifaceSet[iface]; !exists {
 			disableIfaces = append(disableIfaces, iface)
@@ -301,8 +666,40 @@ func (cm *Manager) Update(ctx context.Context, ifaces config.Ifaces) (enabled, u
 	var disable = append(disableIfaces, updateIfaces...)
 	var enable = append(enableIfaces, updateIfaces...)
 
+	// make sure that no other goProbe instance writes to the subtrees of the interfaces to be captured
+	locks, err := cm.lockIfaces(ifaces, enable)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
 	cm.update(ctx, ifaces, enable, disable)
 
+	// the locks of updated interfaces are replaced if their subtree changed (e.g. due to a new tenant),
+	// the ones of interfaces no longer configured are released (whether their capture ran or not)
+	cm.Lock()
+	for iface, lock := range locks {
+		if prevLock, exists := cm.ifaceLocks[iface]; exists {
+			_ = prevLock.Unlock()
+		}
+		cm.ifaceLocks[iface] = lock
+	}
+	for iface := range cm.ifaceLocks {
+		if _, configured := ifaces[iface]; !configured {
+			cm.unlockIface(ctx, iface)
+		}
+	}
+	cm.Unlock()
+
+	// store the aliases and groups so that queries accept / report them in place of the interface names
+	if cm.dbPath != "" {
+		if aerr := info.WriteAliases(cm.dbPath, cm.instance, ifaces.Aliases()); aerr != nil {
+			logger.Errorf("failed to store interface aliases: %v", aerr)
+		}
+		if gerr := info.WriteGroups(cm.dbPath, cm.instance, ifaces.Groups()); gerr != nil {
+			logger.Errorf("failed to store interface groups: %v", gerr)
+		}
+	}
+
 	logger.With(
 		"elapsed", time.Since(t0).Round(time.Millisecond).String(),
 		slog.Group("ifaces",
@@ -316,11 +713,13 @@ func (cm *Manager) Update(ctx context.Context, ifaces config.Ifaces) (enabled, u
 
 }
 
-func (cm *Manager) update(ctx context.Context, ifaces config.Ifaces, enable, disable []string) {
+// update enables / disables the interfaces in the respective lists, returning the number of flows
+// written out for the disabled interfaces
+func (cm *Manager) update(ctx context.Context, ifaces config.Ifaces, enable, disable []string) (numFlushed int) {
 
 	// execute a final writeout of all disabled interfaces in the list
 	if len(disable) > 0 {
-		cm.performWriteout(ctx, time.Now().Add(time.Second), disable...)
+		numFlushed = cm.performWriteout(ctx, time.Now().Add(time.Second), disable...)
 	}
 
 	// To avoid any interference the update() logic is protected as a whole
@@ -365,6 +764,12 @@ func (cm *Manager) update(ctx context.Context, ifaces config.Ifaces, enable, dis
 			logger.Info("initializing capture / running packet processing")
 
 			newCap := newCapture(iface, ifaces[iface]).SetSourceInitFn(cm.sourceInitFn)
+			if state, exists := cm.takeRestoredState(iface); exists {
+				newCap.restoreTotals(state)
+			}
+			if flows, exists := cm.takeRestoredFlows(iface); exists {
+				newCap.restoreFlows(flows)
+			}
 			if err := newCap.run(); err != nil {
 				logger.Errorf("failed to start capture: %s", err)
 				return
@@ -379,6 +784,71 @@ func (cm *Manager) update(ctx context.Context, ifaces config.Ifaces, enable, dis
 		})
 	}
 	rg.Wait()
+
+	return numFlushed
+}
+
+// lockIfaces acquires the locks of the subtrees of the interfaces (unless already held), returning the
+// locks acquired. If any of them is held by another goProbe instance, none are acquired
+func (cm *Manager) lockIfaces(ifaces config.Ifaces, enable []string) (map[string]*goDB.IfaceLock, error) {
+	if cm.dbPath == "" {
+		return nil, nil
+	}
+
+	holder := fmt.Sprintf("pid %d", os.Getpid())
+	if cm.instance != "" {
+		holder = fmt.Sprintf("instance %s, %s", cm.instance, holder)
+	}
+
+	cm.RLock()
+	defer cm.RUnlock()
+
+	locks := make(map[string]*goDB.IfaceLock)
+	for _, iface := range enable {
+		dbPath := info.TenantPath(cm.dbPath, ifaces[iface].Tenant)
+		if lock, exists := cm.ifaceLocks[iface]; exists && lock.Path() == filepath.Join(dbPath, iface+goDB.LockFileSuffix) {
+			continue
+		}
+
+		lock, err := goDB.LockIface(dbPath, iface, holder, cm.lockOpts...)
+		if err != nil {
+			for _, lock := range locks {
+				_ = lock.Unlock()
+			}
+			return nil, err
+		}
+		locks[iface] = lock
+	}
+	return locks, nil
+}
+
+// unlockIfaces releases the locks of the subtrees of the interfaces (or all of them if none are given)
+func (cm *Manager) unlockIfaces(ctx context.Context, ifaces ...string) {
+	cm.Lock()
+	defer cm.Unlock()
+
+	if len(ifaces) == 0 {
+		for iface := range cm.ifaceLocks {
+			cm.unlockIface(ctx, iface)
+		}
+		return
+	}
+	for _, iface := range ifaces {
+		cm.unlockIface(ctx, iface)
+	}
+}
+
+// unlockIface releases the lock of the subtree of the interface (if held). The caller must hold the lock
+// of the capture manager
+func (cm *Manager) unlockIface(ctx context.Context, iface string) {
+	lock, exists := cm.ifaceLocks[iface]
+	if !exists {
+		return
+	}
+	if err := lock.Unlock(); err != nil {
+		logging.FromContext(ctx).With("iface", iface).Errorf("failed to release interface lock: %v", err)
+	}
+	delete(cm.ifaceLocks, iface)
 }
 
 // GetFlowMaps extracts a copy of all active flows and sends them on the provided channel (compatible with normal query
@@ -401,10 +871,16 @@ func (cm *Manager) GetFlowMaps(ctx context.Context, filterFn goDB.FilterFn, writ
 
 			// Lock the running capture and perform the rotation
 			mc.lock()
-			flowMap := mc.flowMap(runCtx)
+			shardedFlowMap := mc.flowMap(runCtx)
 			mc.unlock()
 
-			if flowMap != nil {
+			if shardedFlowMap != nil {
+				flowMap := shardedFlowMap.Join()
+
+				// goProbe's own flows are only available (in their pseudo-interface) once written out
+				if cm.selfTraffic != nil {
+					_, flowMap = cm.selfTraffic.split(flowMap)
+				}
 				if filterFn != nil {
 					flowMap = filterFn(flowMap)
 				}
@@ -423,42 +899,138 @@ func (cm *Manager) GetFlowMaps(ctx context.Context, filterFn goDB.FilterFn, writ
 	).Debug("fetched flow maps")
 }
 
-// Close stops / closes all (or a set of) interfaces
+// TopFlows returns the n flows with the most bytes or packets (depending on sortBy) currently held in
+// the flow log of an interface, along with the total number of flows in the flow log. If the interface
+// isn't being captured, exists is false
+func (cm *Manager) TopFlows(ctx context.Context, iface string, n int, sortBy results.SortOrder) (flows FlowInfos, total int, exists bool) {
+
+	logger, t0 := logging.FromContext(withIfaceContext(ctx, iface)), time.Now()
+
+	mc, exists := cm.captures.Get(iface)
+	if !exists {
+		return nil, 0, false
+	}
+
+	// Lock the running capture only as long as it takes to select the top flows (which retains
+	// no more than n of them, instead of copying the whole flow log)
+	mc.lock()
+	flows, total = mc.flowLog.TopFlows(n, sortBy), mc.flowLog.Len()
+	mc.unlock()
+
+	logger.With(
+		"elapsed", time.Since(t0).Round(time.Microsecond).String(),
+		"flows", total,
+	).Debug("fetched top flows")
+
+	return flows, total, true
+}
+
+// Close stops / closes all (or a set of) interfaces, performing a final rotation and writeout of
+// their flows (in order not to lose the flows captured since the last scheduled writeout). If flows
+// are persisted across restarts, they are stored in the DB instead (to be restored upon the next
+// start). If the context is done before the final writeout has completed (e.g. because the shutdown
+// grace period expired), Close returns without waiting for it
 func (cm *Manager) Close(ctx context.Context, ifaces ...string) {
 
 	logger, t0 := logging.FromContext(ctx), time.Now()
 
-	// Build list of interfaces to process (either from all interfaces or from explicit list)
+	// Once all interfaces are closed, the writeout handler is closed as well (e.g. terminating the
+	// connection to the aggregator)
+	if len(ifaces) == 0 {
+		defer cm.closeWriteoutHandler(ctx)
+	}
+
+	// Build list of interfaces to process (either from all interfaces or from explicit list). The locks
+	// of interfaces whose capture failed to start are released as well
+	lockedIfaces := ifaces
 	if ifaces = cm.captures.Ifaces(ifaces...); len(ifaces) == 0 {
+		cm.unlockIfaces(ctx, lockedIfaces...)
 		return
 	}
 
 	// Close all interfaces in the list using update() with the respective list of
 	// interfaces to remove
-	cm.update(ctx, nil, nil, ifaces)
+	persistFlows := cm.flowStateMaxAge > 0 && cm.dbPath != ""
+	flushed := make(chan int, 1)
+	go func() {
+		if persistFlows {
+			flushed <- cm.persistFlows(ctx, ifaces)
+			return
+		}
+		flushed <- cm.update(ctx, nil, nil, ifaces)
+	}()
 
-	logger.With(
-		"elapsed", time.Since(t0).Round(time.Millisecond).String(),
-		"ifaces", ifaces,
-	).Debug("closed interfaces")
+	select {
+	case numFlushed := <-flushed:
+
+		// Persist the runtime state of the closed interfaces (as of their final writeout) so that it
+		// can be restored upon the next start
+		cm.persistState(ctx, ifaces)
+		cm.unlockIfaces(ctx, lockedIfaces...)
+
+		msg := "closed interfaces after final writeout"
+		if persistFlows {
+			msg = "closed interfaces after persisting flows"
+		}
+		logger.With(
+			"elapsed", time.Since(t0).Round(time.Millisecond).String(),
+			"ifaces", ifaces,
+			"flows", numFlushed,
+		).Info(msg)
+	case <-ctx.Done():
+		logger.With(
+			"elapsed", time.Since(t0).Round(time.Millisecond).String(),
+			"ifaces", ifaces,
+		).Errorf("final writeout did not complete in time, flows may be lost: %v", ctx.Err())
+	}
+}
+
+// closeWriteoutHandler closes the writeout handler if it holds any resources
+func (cm *Manager) closeWriteoutHandler(ctx context.Context) {
+	closer, ok := cm.writeoutHandler.(io.Closer)
+	if !ok {
+		return
+	}
+	if err := closer.Close(); err != nil {
+		logging.FromContext(ctx).Errorf("failed to close writeout handler: %s", err)
+	}
 }
 
 func withIfaceContext(ctx context.Context, iface string) context.Context {
 	return logging.WithFields(ctx, slog.String("iface", iface))
 }
 
-func (cm *Manager) rotate(ctx context.Context, writeoutChan chan<- capturetypes.TaggedAggFlowMap, ifaces ...string) {
+// rotate rotates all (or a set of) interfaces, putting their flows on the writeoutChan. It returns the
+// number of flows rotated
+func (cm *Manager) rotate(ctx context.Context, timestamp time.Time, writeoutChan chan<- capturetypes.TaggedAggFlowMap, ifaces ...string) (numFlows int) {
 
-	logger, t0 := logging.FromContext(ctx), time.Now()
+	// Configured interfaces without a running capture (e.g. because they vanished) are flagged in
+	// the DB while their link is down, telling apart a link outage from a capture outage
+	missing := cm.missingIfaces(ifaces...)
 
 	// Build list of interfaces to process (either from all interfaces or from explicit list)
 	// If none are provided / are available, return empty map
-	if ifaces = cm.captures.Ifaces(ifaces...); len(ifaces) == 0 {
+	if ifaces = cm.captures.Ifaces(ifaces...); len(ifaces) == 0 && len(missing) == 0 {
 		return
 	}
+	interfacesCapturing.Set(float64(len(ifaces)))
+
+	numFlows, ownFlowMap := cm.rotateIfaces(ctx, timestamp, writeoutChan, ifaces)
+	return numFlows + cm.writeoutMarkers(writeoutChan, missing, ownFlowMap)
+}
+
+// rotateIfaces rotates the running captures of the interfaces, putting their flows on the writeoutChan.
+// It returns the number of flows rotated, as well as goProbe's own flows (which are collected across all
+// interfaces)
+func (cm *Manager) rotateIfaces(ctx context.Context, timestamp time.Time, writeoutChan chan<- capturetypes.TaggedAggFlowMap, ifaces []string) (numFlows int, ownFlowMap *hashmap.AggFlowMap) {
+
+	logger, t0 := logging.FromContext(ctx), time.Now()
+
+	// The sockets of local processes are captured once for all interfaces attributing their flows
+	processes := cm.processAttribution(ctx, ifaces)
 
 	// Iteratively rotate all interfaces. Since the rotation results are put on the writeoutChan for
-	// writeout by the DBWriter (which is sequential and certainly slower than the actual in-memory rotation)
+	// writeout by the DBWriter (which is certainly slower than the actual in-memory rotation)
 	// there is no significant benefit from running the rotations in parallel, thus allowing us to minimize
 	// congestion _and_ use a single shared local memory buffer
 	for _, iface := range ifaces {
@@ -474,29 +1046,102 @@ func (cm *Manager) rotate(ctx context.Context, writeoutChan chan<- capturetypes.
 			statsRes := mc.fetchStatusInBackground(runCtx)
 
 			// Perform the rotation
-			rotateResult := mc.rotate(runCtx)
+			rotateResult, samples := mc.rotate(runCtx, cm.flowSampleRate)
 
 			stats := <-statsRes
 			mc.unlock()
+			if stats != nil {
+				stats.LinkState = cm.linkState(mc.iface)
+			}
+			cm.trackState(mc.iface, stats)
 			logger.With("elapsed", time.Since(lockStart).Round(time.Microsecond).String()).Debug("interface locked")
 
-			writeoutChan <- capturetypes.TaggedAggFlowMap{
-				Map:   rotateResult,
-				Stats: *stats,
-				Iface: mc.iface,
+			// Join the shards of the rotation result only after the capture has been unlocked
+			// again in order to minimize the time the capture is blocked
+			var flowMap *hashmap.AggFlowMap
+			if rotateResult != nil {
+				flowMap = rotateResult.Join()
+				if cm.selfTraffic != nil {
+					var own *hashmap.AggFlowMap
+					if own, flowMap = cm.selfTraffic.split(flowMap); own != nil {
+						ownFlowMap = mergeFlowMaps(ownFlowMap, own)
+					}
+				}
+				if flowMap != nil && !flowMap.IsNil() {
+					numFlows += flowMap.Len()
+				}
+			}
+			if cm.selfTraffic != nil {
+				samples = cm.selfTraffic.filterSamples(samples)
 			}
+			cm.trackHistory(mc.iface, newRotationStats(timestamp, stats, flowMap))
+
+			taggedMap := capturetypes.TaggedAggFlowMap{
+				Map:     flowMap,
+				Stats:   *stats,
+				Iface:   mc.iface,
+				Tenant:  mc.config.Tenant,
+				Encoder: ifaceEncoder(mc.config),
+				Samples: samples,
+			}
+			if mc.config.ProcessAttribution {
+				taggedMap.Processes = processes
+			}
+			writeoutChan <- taggedMap
 		}
 	}
 
 	// observe rotation duration
 	t1 := time.Since(t0)
-	rotationDuration.Observe(float64(t1) / float64(time.Second))
-	interfacesCapturing.Set(float64(len(ifaces)))
+	rotationDuration.ObserveDuration(t1)
 
 	logger.With(
 		"elapsed", t1.Round(time.Microsecond).String(),
 		"ifaces", ifaces,
+		"flows", numFlows,
 	).Debug("rotated interfaces")
+
+	return numFlows, ownFlowMap
+}
+
+// writeoutMarkers puts (empty) blocks marking the link of missing interfaces as down, as well as
+// goProbe's own flows (unless they are discarded) on the writeoutChan. It returns the number of own
+// flows
+func (cm *Manager) writeoutMarkers(writeoutChan chan<- capturetypes.TaggedAggFlowMap, missing []string, ownFlowMap *hashmap.AggFlowMap) (numFlows int) {
+
+	// write an (empty) block marking the link of missing interfaces as down
+	for _, iface := range missing {
+		linkState := cm.linkState(iface)
+		if !linkState.IsDown() {
+			continue
+		}
+		cfg := cm.Config(iface)[iface]
+		writeoutChan <- capturetypes.TaggedAggFlowMap{
+			Stats:   capturetypes.CaptureStats{LinkState: linkState},
+			Iface:   iface,
+			Tenant:  cfg.Tenant,
+			Encoder: ifaceEncoder(cfg),
+		}
+	}
+
+	// record goProbe's own flows in their pseudo-interface (unless they are discarded)
+	if ownFlowMap != nil && cm.selfTraffic.iface != "" {
+		numFlows = ownFlowMap.Len()
+		writeoutChan <- capturetypes.TaggedAggFlowMap{
+			Map:   ownFlowMap,
+			Iface: cm.selfTraffic.iface,
+		}
+	}
+	return numFlows
+}
+
+// mergeFlowMaps merges the flows of b into a (which may be nil)
+func mergeFlowMaps(a, b *hashmap.AggFlowMap) *hashmap.AggFlowMap {
+	if a == nil {
+		return b
+	}
+	a.Merge(*b, nil)
+	return a
 }
 
 func (cm *Manager) logErrors(ctx context.Context, iface string, errsChan <-chan error) {
@@ -529,11 +1174,83 @@ func (cm *Manager) logErrors(ctx context.Context, iface string, errsChan <-chan
 	}
 }
 
-func (cm *Manager) performWriteout(ctx context.Context, timestamp time.Time, ifaces ...string) {
-	writeoutChan := make(chan capturetypes.TaggedAggFlowMap, writeout.WriteoutsChanDepth)
+// performWriteout rotates all (or a set of) interfaces and writes out their flows, returning the
+// number of flows written
+func (cm *Manager) performWriteout(ctx context.Context, timestamp time.Time, ifaces ...string) (numFlows int) {
+	return cm.performStaggeredWriteout(ctx, timestamp, 0, ifaces...)
+}
+
+// performStaggeredWriteout rotates all (or a set of) interfaces and writes out their flows, returning
+// the number of flows written. If a stagger duration is set, the interfaces are rotated and written out
+// one after another at evenly spread offsets within the stagger duration (all of them using the same
+// timestamp). Since the offsets are relative to the start of the writeout, the time spent writing out an
+// interface (which may delay the next one) is absorbed instead of adding up. The writeout lock is released
+// in between, so that snapshots aren't delayed by the stagger. goProbe's own flows and the markers of
+// missing interfaces are written out along with the last interface. Upon cancellation of the context,
+// the remaining interfaces are rotated right away
+func (cm *Manager) performStaggeredWriteout(ctx context.Context, timestamp time.Time, stagger time.Duration, ifaces ...string) (numFlows int) {
+	running := cm.captures.Ifaces(ifaces...)
+	if stagger <= 0 || len(running) < 2 {
+		return cm.writeout(ctx, timestamp, func(writeoutChan chan<- capturetypes.TaggedAggFlowMap) int {
+			return cm.rotate(ctx, timestamp, writeoutChan, ifaces...)
+		})
+	}
+
+	missing := cm.missingIfaces(ifaces...)
+	interfacesCapturing.Set(float64(len(running)))
+
+	var ownFlowMap *hashmap.AggFlowMap
+	start, step := time.Now(), stagger/time.Duration(len(running))
+	for i, iface := range running {
+		if wait := time.Until(start.Add(time.Duration(i) * step)); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+			}
+		}
+
+		numFlows += cm.writeout(ctx, timestamp, func(writeoutChan chan<- capturetypes.TaggedAggFlowMap) int {
+			n, own := cm.rotateIfaces(ctx, timestamp, writeoutChan, []string{iface})
+			if own != nil {
+				ownFlowMap = mergeFlowMaps(ownFlowMap, own)
+			}
+			if i == len(running)-1 {
+				n += cm.writeoutMarkers(writeoutChan, missing, ownFlowMap)
+			}
+			return n
+		})
+	}
+	return numFlows
+}
+
+// writeout performs a single writeout of the flows put on the writeout channel by rotate, returning
+// the number of flows written. If other goProbe instances share the DB, their snapshots suspend the
+// writeout as well (see goDB.LockWriteout())
+func (cm *Manager) writeout(ctx context.Context, timestamp time.Time, rotate func(writeoutChan chan<- capturetypes.TaggedAggFlowMap) int) (numFlows int) {
+	cm.writeoutLock.Lock()
+	defer cm.writeoutLock.Unlock()
+
+	// the flows are written out regardless of whether the DB wide lock could be acquired, since a
+	// snapshot is less important than the flows themselves
+	if cm.dbPath != "" {
+		dbLock, err := goDB.LockWriteout(cm.dbPath, false, cm.lockOpts...)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("failed to acquire DB writeout lock: %v", err)
+		} else {
+			defer func() {
+				if err := dbLock.Unlock(); err != nil {
+					logging.FromContext(ctx).Errorf("failed to release DB writeout lock: %v", err)
+				}
+			}()
+		}
+	}
+
+	writeoutChan := make(chan capturetypes.TaggedAggFlowMap, cm.writeoutQueueLength)
 	doneChan := cm.writeoutHandler.HandleWriteout(ctx, timestamp, writeoutChan)
 
-	cm.rotate(ctx, writeoutChan, ifaces...)
+	numFlows = rotate(writeoutChan)
 	close(writeoutChan)
 
 	<-doneChan
@@ -541,4 +1258,289 @@ func (cm *Manager) performWriteout(ctx context.Context, timestamp time.Time, ifa
 	cm.Lock()
 	cm.lastRotation = timestamp
 	cm.Unlock()
+
+	return numFlows
+}
+
+// Snapshot creates a consistent snapshot of the DB at dest (see goDB.Snapshot()). Writeouts are
+// suspended while the snapshot is taken (including the ones of other goProbe instances sharing the
+// DB), while capturing continues unaffected
+func (cm *Manager) Snapshot(ctx context.Context, dest string) (goDB.SnapshotInfo, error) {
+	if cm.dbPath == "" {
+		return goDB.SnapshotInfo{}, errorNoDBPath
+	}
+
+	cm.writeoutLock.Lock()
+	defer cm.writeoutLock.Unlock()
+
+	dbLock, err := goDB.LockWriteout(cm.dbPath, true, cm.lockOpts...)
+	if err != nil {
+		return goDB.SnapshotInfo{}, err
+	}
+	defer func() {
+		if err := dbLock.Unlock(); err != nil {
+			logging.FromContext(ctx).Errorf("failed to release DB writeout lock: %v", err)
+		}
+	}()
+
+	snapshot, err := goDB.Snapshot(cm.dbPath, dest)
+	if err != nil {
+		return snapshot, err
+	}
+	logging.FromContext(ctx).With("path", snapshot.Path, "files", snapshot.NumFiles).Info("created DB snapshot")
+
+	return snapshot, nil
+}
+
+// ifaceEncoder returns the encoder override of the interface configuration (if any)
+func ifaceEncoder(cfg config.CaptureConfig) *capturetypes.Encoder {
+	if cfg.EncoderType == "" {
+		return nil
+	}
+
+	// the configuration has been validated before, hence the encoder type is known
+	encoderType, err := encoders.GetTypeByString(cfg.EncoderType)
+	if err != nil {
+		return nil
+	}
+	return &capturetypes.Encoder{
+		Type:  encoderType,
+		Level: cfg.EncoderLevel,
+	}
+}
+
+// restoreState loads the runtime state persisted by the previous run of the capture manager
+func (cm *Manager) restoreState(ctx context.Context) {
+	if cm.dbPath == "" {
+		return
+	}
+
+	state, err := info.ReadCaptureState(cm.dbPath, cm.instance)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("failed to restore capture state: %v", err)
+		return
+	}
+
+	cm.Lock()
+	cm.lastRotation = state.LastWriteout
+	cm.Unlock()
+
+	cm.stateLock.Lock()
+	cm.restoredStates = state.Ifaces
+	cm.stateLock.Unlock()
+}
+
+// takeRestoredState returns (and consumes) the persisted state of an interface
+func (cm *Manager) takeRestoredState(iface string) (info.IfaceCaptureState, bool) {
+	cm.stateLock.Lock()
+	defer cm.stateLock.Unlock()
+
+	state, exists := cm.restoredStates[iface]
+	delete(cm.restoredStates, iface)
+
+	return state, exists
+}
+
+// trackState records the runtime stats of an interface as of its latest rotation
+func (cm *Manager) trackState(iface string, stats *capturetypes.CaptureStats) {
+	if stats == nil {
+		return
+	}
+
+	cm.stateLock.Lock()
+	cm.ifaceStates[iface] = info.IfaceCaptureState{
+		ReceivedTotal:  stats.ReceivedTotal,
+		ProcessedTotal: stats.ProcessedTotal,
+		DroppedTotal:   stats.DroppedTotal,
+	}
+	cm.stateLock.Unlock()
+}
+
+// trackHistory appends the statistics of a rotation to the history of the interface
+func (cm *Manager) trackHistory(iface string, stats capturetypes.RotationStats) {
+	if cm.statsHistorySize <= 0 {
+		return
+	}
+
+	cm.stateLock.Lock()
+	history, exists := cm.statsHistories[iface]
+	if !exists {
+		history = newStatsHistory(cm.statsHistorySize)
+		cm.statsHistories[iface] = history
+	}
+	history.add(stats)
+	cm.stateLock.Unlock()
+}
+
+// History returns the statistics of the rotations of an interface at or after since (oldest
+// first). If no history is kept for the interface, false is returned
+func (cm *Manager) History(iface string, since time.Time) (capturetypes.StatsHistory, bool) {
+	cm.stateLock.Lock()
+	defer cm.stateLock.Unlock()
+
+	history, exists := cm.statsHistories[iface]
+	if !exists {
+		return nil, false
+	}
+	return history.since(since), true
+}
+
+// persistState stores the runtime state of the interfaces in the DB
+func (cm *Manager) persistState(ctx context.Context, ifaces []string) {
+	if cm.dbPath == "" {
+		return
+	}
+
+	state := info.CaptureState{
+		LastWriteout: cm.LastRotation(),
+		Ifaces:       make(map[string]info.IfaceCaptureState),
+	}
+	cm.stateLock.Lock()
+	for _, iface := range ifaces {
+		if ifaceState, exists := cm.ifaceStates[iface]; exists {
+			state.Ifaces[iface] = ifaceState
+		}
+	}
+	cm.stateLock.Unlock()
+
+	if err := info.WriteCaptureState(cm.dbPath, cm.instance, state); err != nil {
+		logging.FromContext(ctx).Errorf("failed to persist capture state: %v", err)
+	}
+}
+
+// persistFlows closes the captures of the interfaces and stores their flow logs (along with the stats
+// accumulated since the last writeout) in the DB instead of writing them out, returning the number of
+// flows persisted. If the flows cannot be stored, they are written out instead
+func (cm *Manager) persistFlows(ctx context.Context, ifaces []string) (numFlows int) {
+	state := &flowState{Timestamp: time.Now()}
+
+	cm.Lock()
+	cm.lastAppliedConfig = nil
+	for _, iface := range ifaces {
+		mc, exists := cm.captures.Get(iface)
+		if !exists {
+			continue
+		}
+		logger := logging.FromContext(withIfaceContext(ctx, iface))
+
+		// Fetch the stats accumulated since the last writeout
+		mc.lock()
+		stats, err := mc.status()
+		mc.unlock()
+		if err != nil {
+			logger.Errorf("failed to get capture stats: %v", err)
+			stats = &capturetypes.CaptureStats{}
+		}
+		cm.trackState(iface, stats)
+
+		// Once processing has stopped, the flow log isn't modified anymore
+		logger.Info("closing capture / stopping packet processing")
+		if err := mc.close(); err != nil {
+			logger.Errorf("failed to close capture: %s", err)
+		}
+		cm.captures.Delete(iface)
+
+		state.add(ifaceFlowStateHdr{
+			Iface:   iface,
+			Tenant:  mc.config.Tenant,
+			Encoder: ifaceEncoder(mc.config),
+			Stats:   *stats,
+		}, mc.flowLog)
+		numFlows += mc.flowLog.Len()
+	}
+	cm.Unlock()
+
+	if err := writeFlowState(cm.dbPath, cm.instance, state); err != nil {
+		logging.FromContext(ctx).Errorf("failed to persist flows, writing them out instead: %v", err)
+		return cm.writeoutFlowState(ctx, state.Timestamp, state.flowStates())
+	}
+	return numFlows
+}
+
+// restoreFlows loads the flows persisted upon the last shutdown (if any). Flows older than the maximum
+// age (or of interfaces which are no longer configured) are written out instead
+func (cm *Manager) restoreFlows(ctx context.Context, ifaces config.Ifaces) {
+	if cm.dbPath == "" {
+		return
+	}
+	logger := logging.FromContext(ctx)
+
+	state, err := takeFlowState(cm.dbPath, cm.instance)
+	if err != nil {
+		logger.Errorf("failed to restore flows: %v", err)
+		return
+	}
+	if state == nil {
+		return
+	}
+
+	age := time.Since(state.Timestamp)
+	restored, stale := make(map[string]ifaceFlowState), make(map[string]ifaceFlowState)
+	for iface, ifaceState := range state.flowStates() {
+		if _, configured := ifaces[iface]; configured && age <= cm.flowStateMaxAge {
+			restored[iface] = ifaceState
+		} else {
+			stale[iface] = ifaceState
+		}
+	}
+
+	if len(stale) > 0 {
+		numFlows := cm.writeoutFlowState(ctx, state.Timestamp, stale)
+		logger.With(
+			"age", age.Round(time.Second).String(),
+			"flows", numFlows,
+		).Info("wrote out persisted flows which are too old to be restored")
+	}
+	if len(restored) > 0 {
+		logger.With(
+			"age", age.Round(time.Second).String(),
+			"ifaces", len(restored),
+		).Info("restored persisted flows")
+	}
+
+	cm.stateLock.Lock()
+	cm.restoredFlows = restored
+	cm.stateLock.Unlock()
+}
+
+// takeRestoredFlows returns (and consumes) the persisted flows of an interface
+func (cm *Manager) takeRestoredFlows(iface string) (ifaceFlowState, bool) {
+	cm.stateLock.Lock()
+	defer cm.stateLock.Unlock()
+
+	flows, exists := cm.restoredFlows[iface]
+	delete(cm.restoredFlows, iface)
+
+	return flows, exists
+}
+
+// writeoutFlowState writes out persisted flows (as of the time they were persisted), returning the
+// number of flows written
+func (cm *Manager) writeoutFlowState(ctx context.Context, timestamp time.Time, states map[string]ifaceFlowState) (numFlows int) {
+	cm.writeoutLock.Lock()
+	defer cm.writeoutLock.Unlock()
+
+	writeoutChan := make(chan capturetypes.TaggedAggFlowMap, cm.writeoutQueueLength)
+	doneChan := cm.writeoutHandler.HandleWriteout(ctx, timestamp, writeoutChan)
+
+	for iface, state := range states {
+		var flowMap *hashmap.AggFlowMap
+		if state.flowLog.Len() > 0 {
+			if flowMap = state.flowLog.Rotate().Join(); flowMap != nil && !flowMap.IsNil() {
+				numFlows += flowMap.Len()
+			}
+		}
+		writeoutChan <- capturetypes.TaggedAggFlowMap{
+			Map:     flowMap,
+			Stats:   state.Stats,
+			Iface:   iface,
+			Tenant:  state.Tenant,
+			Encoder: state.Encoder,
+		}
+	}
+	close(writeoutChan)
+
+	<-doneChan
+
+	return numFlows
 }
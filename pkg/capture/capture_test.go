@@ -5,6 +5,7 @@ package capture
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"math/rand"
@@ -18,8 +19,10 @@ import (
 
 	"github.com/els0r/goProbe/cmd/goProbe/config"
 	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/goProbe/pkg/goDB"
 	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
 	"github.com/els0r/goProbe/pkg/goprobe/writeout"
+	"github.com/els0r/goProbe/pkg/results"
 	"github.com/fako1024/slimcap/capture"
 	"github.com/fako1024/slimcap/capture/afpacket/afring"
 	"github.com/fako1024/slimcap/link"
@@ -92,7 +95,7 @@ func testConcurrentMethodAccess(t *testing.T, nIfaces, nIterations int) {
 		prng := rand.New(rand.NewSource(randSeed)) // #nosec G404
 		for i := 0; i < nIterations; i++ {
 			ifaceIdx := prng.Int63n(int64(nIfaces))
-			captureManager.rotate(ctx, writeoutChan, fmt.Sprintf("mock%00d", ifaceIdx))
+			captureManager.rotate(ctx, time.Now(), writeoutChan, fmt.Sprintf("mock%00d", ifaceIdx))
 			<-writeoutChan
 		}
 		wg.Done()
@@ -209,6 +212,166 @@ func TestMockPacketCapturePerformance(t *testing.T) {
 	require.Nil(t, mockC.close())
 }
 
+func TestFlowLogSharding(t *testing.T) {
+
+	nFlows := uint64(10000)
+
+	var tests = []struct {
+		name string
+		sip  string
+		dip  string
+	}{
+		{"IPv4", "1.2.3.4", "4.5.6.7"},
+		{"IPv6", "2001:db8::1", "2001:db8::2"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+
+			// Generate outgoing packets and their return packets for many flows
+			var pkts []capture.Packet
+			for i := uint64(0); i < nFlows; i++ {
+				sip, dip := net.ParseIP(test.sip), net.ParseIP(test.dip)
+				if sip.To4() != nil {
+					sip, dip = sip.To4(), dip.To4()
+				}
+				binary.BigEndian.PutUint16(sip[len(sip)-2:], uint16(i))
+				binary.BigEndian.PutUint16(dip[len(dip)-3:], uint16(i*7))
+
+				pkt, err := capture.BuildPacket(sip, dip, 55555, 80, 17, []byte{1, 2}, capture.PacketOutgoing, 128)
+				require.Nil(t, err)
+				pktRet, err := capture.BuildPacket(dip, sip, 80, 55555, 17, []byte{1, 2}, capture.PacketThisHost, 64)
+				require.Nil(t, err)
+				pkts = append(pkts, pkt, pktRet)
+			}
+
+			singleLog, shardedLog := newFlowLog(1), newFlowLog(8)
+			for _, pkt := range pkts {
+				epHash, isIPv4, auxInfo, errno := ParsePacket(pkt.IPLayer())
+				require.Equal(t, capturetypes.ErrnoOK, singleLog.Add(epHash, pkt.Type(), pkt.TotalLen(), isIPv4, auxInfo, errno))
+				require.Equal(t, capturetypes.ErrnoOK, shardedLog.Add(epHash, pkt.Type(), pkt.TotalLen(), isIPv4, auxInfo, errno))
+			}
+
+			// Both directions of a flow must end up in the same shard
+			require.EqualValues(t, nFlows, singleLog.Len())
+			require.EqualValues(t, nFlows, shardedLog.Len())
+			for _, flowMap := range shardedLog.flowMaps {
+				require.NotEmpty(t, flowMap)
+			}
+
+			// The aggregated results must be identical to the non-sharded case
+			aggSingle, aggSharded := singleLog.Rotate(), shardedLog.Rotate()
+			require.Equal(t, 8, aggSharded.NumShards())
+
+			singleMap, shardedMap := aggSingle.Join(), aggSharded.Join()
+			require.EqualValues(t, nFlows, shardedMap.Len())
+			for it := singleMap.Iter(); it.Next(); {
+				val, exists := shardedMap.PrimaryMap.Get(it.Key())
+				if !exists {
+					val, exists = shardedMap.SecondaryMap.Get(it.Key())
+				}
+				require.True(t, exists)
+				require.Equal(t, it.Val(), val)
+			}
+		})
+	}
+}
+
+func TestFlowLogTopFlows(t *testing.T) {
+
+	flowLog := newFlowLog(4)
+
+	// flow i consists of i outgoing packets of size 1100 - 200*i, i.e. the flow with the most packets
+	// has the fewest bytes
+	for i := 1; i <= 5; i++ {
+		pkt, err := capture.BuildPacket(net.ParseIP("1.2.3.4"), net.ParseIP(fmt.Sprintf("4.5.6.%d", i)), 55555, 80, 17, []byte{1, 2}, capture.PacketOutgoing, 1100-200*i)
+		require.Nil(t, err)
+		for j := 0; j < i; j++ {
+			epHash, isIPv4, auxInfo, errno := ParsePacket(pkt.IPLayer())
+			require.Equal(t, capturetypes.ErrnoOK, flowLog.Add(epHash, pkt.Type(), pkt.TotalLen(), isIPv4, auxInfo, errno))
+		}
+	}
+
+	dips := func(flows FlowInfos) (res []string) {
+		for _, flow := range flows {
+			res = append(res, flow.Flow.Attributes.DstIP.String())
+		}
+		return
+	}
+
+	byBytes := flowLog.TopFlows(3, results.SortTraffic)
+	require.Equal(t, []string{"4.5.6.3", "4.5.6.2", "4.5.6.4"}, dips(byBytes))
+	require.EqualValues(t, 3*500, byBytes[0].Flow.Counters.BytesSent)
+	require.EqualValues(t, 55555, byBytes[0].Flow.Attributes.SrcPort)
+
+	byPackets := flowLog.TopFlows(0, results.SortPackets)
+	require.Equal(t, []string{"4.5.6.5", "4.5.6.4", "4.5.6.3", "4.5.6.2", "4.5.6.1"}, dips(byPackets))
+
+	// flows retained across a rotation are reported as idle
+	flowLog.Rotate()
+	idleFlows := flowLog.TopFlows(0, results.SortTraffic)
+	require.NotEmpty(t, idleFlows)
+	for _, flow := range idleFlows {
+		require.True(t, flow.Idle)
+	}
+}
+
+func TestFlowLogSampling(t *testing.T) {
+
+	nFlows, rate := 10000, 10
+
+	var pkts []capture.Packet
+	for i := 0; i < nFlows; i++ {
+		dip := net.IPv4(10, byte(i>>16), byte(i>>8), byte(i))
+		pkt, err := capture.BuildPacket(net.ParseIP("1.2.3.4"), dip, 55555, 8080, 17, []byte{1, 2}, capture.PacketOutgoing, 128)
+		require.Nil(t, err)
+		pkts = append(pkts, pkt)
+	}
+
+	flowLog := newFlowLog(4)
+	addPkts := func() {
+		for _, pkt := range pkts {
+			epHash, isIPv4, auxInfo, errno := ParsePacket(pkt.IPLayer())
+			require.Equal(t, capturetypes.ErrnoOK, flowLog.Add(epHash, pkt.Type(), pkt.TotalLen(), isIPv4, auxInfo, errno))
+		}
+		for _, flowMap := range flowLog.flowMaps {
+			for _, flow := range flowMap {
+				flow.directionConfidenceHigh = true
+			}
+		}
+	}
+
+	sampledDIPs := func(samples []results.ExtendedRow) map[string]struct{} {
+		dips := make(map[string]struct{}, len(samples))
+		for _, sample := range samples {
+			require.EqualValues(t, 55555, sample.Attributes.SrcPort)
+			require.EqualValues(t, 8080, sample.Attributes.DstPort)
+			require.EqualValues(t, 128, sample.Counters.BytesSent)
+			dips[sample.Attributes.DstIP.String()] = struct{}{}
+		}
+		return dips
+	}
+
+	// the aggregated flows are unaffected by the sampling
+	addPkts()
+	agg, samples := flowLog.RotateSampled(rate)
+	require.Equal(t, nFlows, agg.Join().Len())
+	require.InDelta(t, nFlows/rate, len(samples), float64(nFlows/rate)/5)
+	firstDIPs := sampledDIPs(samples)
+	require.Len(t, firstDIPs, len(samples))
+
+	// the same flows are sampled upon the next rotation
+	addPkts()
+	_, samples = flowLog.RotateSampled(rate)
+	require.Equal(t, firstDIPs, sampledDIPs(samples))
+
+	// all flows are sampled at a rate of 1
+	addPkts()
+	_, samples = flowLog.RotateSampled(1)
+	require.Len(t, samples, nFlows)
+}
+
 func BenchmarkRotation(b *testing.B) {
 
 	nFlows := uint64(100000)
@@ -223,53 +386,56 @@ func BenchmarkRotation(b *testing.B) {
 	require.Nil(b, err)
 	ipLayer := pkt.IPLayer()
 
-	flowLog := NewFlowLog()
-	for i := uint64(0); i < nFlows; i++ {
-		*(*uint64)(unsafe.Pointer(&ipLayer[16])) = i // #nosec G103
-		epHash, isIPv4, auxInfo, errno := ParsePacket(ipLayer)
-		require.Equal(b, capturetypes.ErrnoOK, flowLog.Add(epHash, capture.PacketOutgoing, 128, isIPv4, auxInfo, errno))
-	}
-	for _, flow := range flowLog.flowMap {
-		flow.directionConfidenceHigh = true
-	}
-
-	b.Run("rotation", func(b *testing.B) {
-
-		benchData := make([]*FlowLog, b.N)
-		for i := 0; i < len(benchData); i++ {
-			benchData[i] = flowLog.clone()
+	for _, nShards := range []int{1, 4, maxFlowLogShards} {
+		flowLog := newFlowLog(nShards)
+		for i := uint64(0); i < nFlows; i++ {
+			*(*uint64)(unsafe.Pointer(&ipLayer[16])) = i // #nosec G103
+			epHash, isIPv4, auxInfo, errno := ParsePacket(ipLayer)
+			require.Equal(b, capturetypes.ErrnoOK, flowLog.Add(epHash, capture.PacketOutgoing, 128, isIPv4, auxInfo, errno))
+		}
+		for _, flowMap := range flowLog.flowMaps {
+			for _, flow := range flowMap {
+				flow.directionConfidenceHigh = true
+			}
 		}
 
-		b.ReportAllocs()
-		b.ResetTimer()
-		for i := 0; i < b.N; i++ {
+		b.Run(fmt.Sprintf("rotation_%d_shards", nShards), func(b *testing.B) {
 
-			// Run best-case scenario (keep all flows)
-			aggMap := benchData[i].transferAndAggregate()
-			require.EqualValues(b, nFlows, len(benchData[i].flowMap))
-			require.EqualValues(b, nFlows, aggMap.Len())
+			benchData := make([]*FlowLog, b.N)
+			for i := 0; i < len(benchData); i++ {
+				benchData[i] = flowLog.clone()
+			}
 
-			// Run worst-case scenario (keep no flows)
-			aggMap = benchData[i].transferAndAggregate()
-			require.EqualValues(b, 0, len(benchData[i].flowMap))
-			require.EqualValues(b, 0, aggMap.Len())
-		}
-	})
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
 
-	b.Run("post_add", func(b *testing.B) {
-		testLog := flowLog.clone()
+				// Run best-case scenario (keep all flows)
+				aggMap := benchData[i].Rotate()
+				require.EqualValues(b, nFlows, benchData[i].Len())
+				require.EqualValues(b, nFlows, aggMap.Len())
 
-		testLog.transferAndAggregate()
-		testLog.transferAndAggregate()
+				// Run worst-case scenario (keep no flows)
+				aggMap = benchData[i].Rotate()
+				require.EqualValues(b, 0, benchData[i].Len())
+				require.EqualValues(b, 0, aggMap.Len())
+			}
+		})
 
-		b.ReportAllocs()
-		b.ResetTimer()
-		for i := 0; i < b.N; i++ {
-			epHash, isIPv4, auxInfo, errno := ParsePacket(pkt.IPLayer())
-			require.Equal(b, capturetypes.ErrnoOK, testLog.Add(epHash, capture.PacketOutgoing, 128, isIPv4, auxInfo, errno))
-		}
-	})
+		b.Run(fmt.Sprintf("post_add_%d_shards", nShards), func(b *testing.B) {
+			testLog := flowLog.clone()
 
+			testLog.Rotate()
+			testLog.Rotate()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				epHash, isIPv4, auxInfo, errno := ParsePacket(pkt.IPLayer())
+				require.Equal(b, capturetypes.ErrnoOK, testLog.Add(epHash, capture.PacketOutgoing, 128, isIPv4, auxInfo, errno))
+			}
+		})
+	}
 }
 
 func testDeadlockLowTraffic(t *testing.T, maxPkts int) {
@@ -299,7 +465,7 @@ func testDeadlockLowTraffic(t *testing.T, maxPkts int) {
 	time.AfterFunc(100*time.Millisecond, func() {
 		for i := 0; i < 20; i++ {
 			mockC.lock()
-			mockC.rotate(ctx)
+			mockC.rotate(ctx, 0)
 			mockC.unlock()
 			time.Sleep(10 * time.Millisecond)
 		}
@@ -347,7 +513,7 @@ func testDeadlockHighTraffic(t *testing.T) {
 	time.AfterFunc(100*time.Millisecond, func() {
 		for i := 0; i < 20; i++ {
 			mockC.lock()
-			mockC.rotate(ctx)
+			mockC.rotate(ctx, 0)
 			mockC.unlock()
 			time.Sleep(10 * time.Millisecond)
 		}
@@ -407,3 +573,563 @@ func initMockSrc(t *testing.T, iface string) (*afring.MockSourceNoDrain, <-chan
 
 	return mockSrc, errChan
 }
+
+func TestStatePersistence(t *testing.T) {
+	tempDir := t.TempDir()
+	ctx := context.Background()
+
+	captureManager := NewManager(writeout.NewGoDBHandler(tempDir, encoders.EncoderTypeLZ4))
+	captureManager.dbPath = tempDir
+	captureManager.lastRotation = time.Unix(1700000000, 0)
+	captureManager.trackState("mock0", &capturetypes.CaptureStats{ReceivedTotal: 100, ProcessedTotal: 98, DroppedTotal: 2})
+	captureManager.trackState("mock1", &capturetypes.CaptureStats{ReceivedTotal: 10})
+	captureManager.persistState(ctx, []string{"mock0"})
+
+	// the state is restored by the next instance of the capture manager (once per interface)
+	restoredManager := NewManager(writeout.NewGoDBHandler(tempDir, encoders.EncoderTypeLZ4))
+	restoredManager.dbPath = tempDir
+	restoredManager.restoreState(ctx)
+	require.True(t, restoredManager.LastRotation().Equal(time.Unix(1700000000, 0)))
+
+	state, exists := restoredManager.takeRestoredState("mock0")
+	require.True(t, exists)
+	_, exists = restoredManager.takeRestoredState("mock0")
+	require.False(t, exists)
+	_, exists = restoredManager.takeRestoredState("mock1")
+	require.False(t, exists)
+
+	c := newCapture("mock0", defaultMockIfaceConfig)
+	c.restoreTotals(state)
+	require.Equal(t, uint64(100), c.stats.ReceivedTotal)
+	require.Equal(t, uint64(98), c.stats.ProcessedTotal)
+	require.Equal(t, uint64(2), c.stats.DroppedTotal)
+}
+
+// stalledWriteoutHandler consumes writeouts without ever completing them
+type stalledWriteoutHandler struct{}
+
+func (stalledWriteoutHandler) HandleWriteout(_ context.Context, _ time.Time, writeoutChan <-chan capturetypes.TaggedAggFlowMap) <-chan struct{} {
+	go func() {
+		for range writeoutChan {
+		}
+	}()
+	return make(chan struct{})
+}
+
+func TestCloseDeadline(t *testing.T) {
+	mockSrc, errChan := initMockSrc(t, "mock0")
+
+	captureManager := NewManager(stalledWriteoutHandler{},
+		WithSourceInitFn(func(c *Capture) (capture.SourceZeroCopy, error) {
+			return mockSrc, nil
+		}),
+	)
+	_, _, _, err := captureManager.Update(context.Background(), config.Ifaces{"mock0": defaultMockIfaceConfig})
+	require.Nil(t, err)
+
+	// the final writeout never completes, hence closing has to give up once the context expires
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	t0 := time.Now()
+	captureManager.Close(ctx)
+	require.Less(t, time.Since(t0), 5*time.Second)
+
+	mockSrc.Done()
+	require.Nil(t, <-errChan)
+}
+
+// recordingWriteoutHandler records all writeouts
+type recordingWriteoutHandler struct {
+	timestamps []time.Time
+	maps       map[string]capturetypes.TaggedAggFlowMap
+}
+
+func (h *recordingWriteoutHandler) HandleWriteout(_ context.Context, timestamp time.Time, writeoutChan <-chan capturetypes.TaggedAggFlowMap) <-chan struct{} {
+	done := make(chan struct{})
+	h.timestamps = append(h.timestamps, timestamp)
+	go func() {
+		for taggedMap := range writeoutChan {
+			h.maps[taggedMap.Iface] = taggedMap
+		}
+		close(done)
+	}()
+	return done
+}
+
+func genFlowLog(t *testing.T, nFlows int) *FlowLog {
+	flowLog := NewFlowLog()
+	for i := 0; i < nFlows; i++ {
+		sip, dip := net.ParseIP("10.0.0.1").To4(), net.ParseIP("10.0.0.2").To4()
+		if i%2 == 1 {
+			sip, dip = net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")
+		}
+		binary.BigEndian.PutUint16(sip[len(sip)-2:], uint16(i))
+
+		pkt, err := capture.BuildPacket(sip, dip, 55555, 443, 17, []byte{1, 2}, capture.PacketOutgoing, 128)
+		require.Nil(t, err)
+		epHash, isIPv4, auxInfo, errno := ParsePacket(pkt.IPLayer())
+		require.Equal(t, capturetypes.ErrnoOK, flowLog.Add(epHash, pkt.Type(), pkt.TotalLen(), isIPv4, auxInfo, errno))
+	}
+	return flowLog
+}
+
+func TestFlowStatePersistence(t *testing.T) {
+	tempDir := t.TempDir()
+
+	flowLog := genFlowLog(t, 1000)
+	stats := capturetypes.CaptureStats{Received: 1000, Processed: 1000, Dropped: 3}
+
+	state := &flowState{Timestamp: time.Unix(1700000000, 0)}
+	state.add(ifaceFlowStateHdr{Iface: "mock0", Tenant: "acme", Stats: stats}, flowLog)
+	state.add(ifaceFlowStateHdr{Iface: "mock1"}, NewFlowLog())
+	require.Nil(t, writeFlowState(tempDir, "", state))
+
+	restored, err := takeFlowState(tempDir, "")
+	require.Nil(t, err)
+	require.True(t, restored.Timestamp.Equal(state.Timestamp))
+
+	flowStates := restored.flowStates()
+	require.Len(t, flowStates, 2)
+	require.Equal(t, "acme", flowStates["mock0"].Tenant)
+	require.Equal(t, stats, flowStates["mock0"].Stats)
+	require.Equal(t, flowLog.Flows(), flowStates["mock0"].flowLog.Flows())
+	require.Zero(t, flowStates["mock1"].flowLog.Len())
+
+	// the flows are restored at most once
+	restored, err = takeFlowState(tempDir, "")
+	require.Nil(t, err)
+	require.Nil(t, restored)
+}
+
+func TestRestoreFlows(t *testing.T) {
+	ctx := context.Background()
+	ifaces := config.Ifaces{"mock0": defaultMockIfaceConfig}
+
+	persist := func(t *testing.T, dbPath string, timestamp time.Time) {
+		state := &flowState{Timestamp: timestamp}
+		state.add(ifaceFlowStateHdr{Iface: "mock0", Stats: capturetypes.CaptureStats{Received: 10, Processed: 10}}, genFlowLog(t, 10))
+		state.add(ifaceFlowStateHdr{Iface: "mock1"}, genFlowLog(t, 5))
+		require.Nil(t, writeFlowState(dbPath, "", state))
+	}
+
+	t.Run("fresh", func(t *testing.T) {
+		tempDir := t.TempDir()
+		persist(t, tempDir, time.Now().Add(-time.Minute))
+
+		handler := &recordingWriteoutHandler{maps: make(map[string]capturetypes.TaggedAggFlowMap)}
+		captureManager := NewManager(handler)
+		captureManager.dbPath = tempDir
+		captureManager.flowStateMaxAge = 5 * time.Minute
+		captureManager.restoreFlows(ctx, ifaces)
+
+		// the flows of configured interfaces are restored, all others are written out
+		flows, exists := captureManager.takeRestoredFlows("mock0")
+		require.True(t, exists)
+		require.Equal(t, 10, flows.flowLog.Len())
+		_, exists = captureManager.takeRestoredFlows("mock0")
+		require.False(t, exists)
+
+		require.Len(t, handler.maps, 1)
+		require.Equal(t, 5, handler.maps["mock1"].Map.Len())
+
+		// the stats accumulated before the restart are reported along with the first rotation
+		c := newCapture("mock0", defaultMockIfaceConfig)
+		c.restoreFlows(flows)
+		require.Equal(t, 10, c.flowLog.Len())
+		require.Equal(t, uint64(10), c.restoredStats.Received)
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		tempDir := t.TempDir()
+		timestamp := time.Now().Add(-time.Hour).Truncate(time.Second)
+		persist(t, tempDir, timestamp)
+
+		handler := &recordingWriteoutHandler{maps: make(map[string]capturetypes.TaggedAggFlowMap)}
+		captureManager := NewManager(handler)
+		captureManager.dbPath = tempDir
+		captureManager.flowStateMaxAge = 5 * time.Minute
+		captureManager.restoreFlows(ctx, ifaces)
+
+		// all flows are written out as of the time they were persisted
+		_, exists := captureManager.takeRestoredFlows("mock0")
+		require.False(t, exists)
+		require.Len(t, handler.timestamps, 1)
+		require.True(t, handler.timestamps[0].Equal(timestamp))
+		require.Equal(t, 10, handler.maps["mock0"].Map.Len())
+		require.Equal(t, uint64(10), handler.maps["mock0"].Stats.Received)
+		require.Equal(t, 5, handler.maps["mock1"].Map.Len())
+	})
+}
+
+func TestCloseWithPersistedFlows(t *testing.T) {
+	tempDir := t.TempDir()
+	mockSrc, errChan := initMockSrc(t, "mock0")
+
+	handler := &recordingWriteoutHandler{maps: make(map[string]capturetypes.TaggedAggFlowMap)}
+	captureManager := NewManager(handler,
+		WithSourceInitFn(func(c *Capture) (capture.SourceZeroCopy, error) {
+			return mockSrc, nil
+		}),
+	)
+	captureManager.dbPath = tempDir
+	captureManager.flowStateMaxAge = 5 * time.Minute
+
+	_, _, _, err := captureManager.Update(context.Background(), config.Ifaces{"mock0": defaultMockIfaceConfig})
+	require.Nil(t, err)
+
+	// wait until the capture has processed packets
+	require.Eventually(t, func() bool {
+		return captureManager.Status(context.Background(), "mock0")["mock0"].ProcessedTotal > 0
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// the flows are persisted instead of being written out
+	captureManager.Close(context.Background())
+	require.Empty(t, handler.timestamps)
+
+	mockSrc.Done()
+	require.Nil(t, <-errChan)
+
+	state, err := takeFlowState(tempDir, "")
+	require.Nil(t, err)
+	require.NotNil(t, state)
+	require.Equal(t, 1, state.flowStates()["mock0"].flowLog.Len())
+}
+
+// timedWriteoutHandler records the time each interface was handed over for writeout, as well as the
+// number of writeouts
+type timedWriteoutHandler struct {
+	received  map[string]time.Time
+	writeouts int
+}
+
+func (h *timedWriteoutHandler) HandleWriteout(_ context.Context, _ time.Time, writeoutChan <-chan capturetypes.TaggedAggFlowMap) <-chan struct{} {
+	h.writeouts++
+	done := make(chan struct{})
+	go func() {
+		for taggedMap := range writeoutChan {
+			h.received[taggedMap.Iface] = time.Now()
+		}
+		close(done)
+	}()
+	return done
+}
+
+func TestStaggeredWriteout(t *testing.T) {
+	mockSrcs := make(map[string]*afring.MockSourceNoDrain)
+	errChans := make(map[string]<-chan error)
+	for _, iface := range []string{"mock0", "mock1"} {
+		mockSrcs[iface], errChans[iface] = initMockSrc(t, iface)
+	}
+
+	handler := &timedWriteoutHandler{received: make(map[string]time.Time)}
+	captureManager := NewManager(handler,
+		WithSourceInitFn(func(c *Capture) (capture.SourceZeroCopy, error) {
+			return mockSrcs[c.iface], nil
+		}),
+	)
+	_, _, _, err := captureManager.Update(context.Background(), config.Ifaces{
+		"mock0": defaultMockIfaceConfig,
+		"mock1": defaultMockIfaceConfig,
+	})
+	require.Nil(t, err)
+
+	// the second interface is rotated and handed over in a separate writeout halfway through the
+	// stagger duration, while the writeout lock is released in between
+	const stagger = 400 * time.Millisecond
+	t0 := time.Now()
+	receivedAmidStagger := make(chan int, 1)
+	go func() {
+		time.Sleep(stagger / 4)
+		captureManager.writeoutLock.Lock()
+		receivedAmidStagger <- len(handler.received)
+		captureManager.writeoutLock.Unlock()
+	}()
+	captureManager.performStaggeredWriteout(context.Background(), t0, stagger)
+	require.Equal(t, 1, <-receivedAmidStagger)
+	require.Len(t, handler.received, 2)
+	require.Equal(t, 2, handler.writeouts)
+	first, last := handler.received["mock0"], handler.received["mock1"]
+	if last.Before(first) {
+		first, last = last, first
+	}
+	require.Less(t, first.Sub(t0), stagger/2)
+	require.GreaterOrEqual(t, last.Sub(t0), stagger/2)
+
+	// cancelling the context hands over the remaining interfaces right away
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	t0 = time.Now()
+	captureManager.performStaggeredWriteout(ctx, t0, time.Minute)
+	require.Less(t, time.Since(t0), 30*time.Second)
+
+	captureManager.Close(context.Background())
+	for iface, mockSrc := range mockSrcs {
+		mockSrc.Done()
+		require.Nil(t, <-errChans[iface])
+	}
+}
+
+func TestLinkState(t *testing.T) {
+	mockSrc, errChan := initMockSrc(t, "mock0")
+
+	// the capture of the second interface fails to start (e.g. because it vanished)
+	var linkStates = map[string]capturetypes.LinkState{
+		"mock0": capturetypes.LinkStateUp,
+		"mock1": capturetypes.LinkStateUp,
+	}
+	handler := &recordingWriteoutHandler{maps: make(map[string]capturetypes.TaggedAggFlowMap)}
+	captureManager := NewManager(handler,
+		WithSourceInitFn(func(c *Capture) (capture.SourceZeroCopy, error) {
+			if c.iface != "mock0" {
+				return nil, errors.New("no such interface")
+			}
+			return mockSrc, nil
+		}),
+		WithLinkState(func(iface string) capturetypes.LinkState {
+			return linkStates[iface]
+		}),
+	)
+	_, _, _, err := captureManager.Update(context.Background(), config.Ifaces{
+		"mock0": defaultMockIfaceConfig,
+		"mock1": defaultMockIfaceConfig,
+	})
+	require.Nil(t, err)
+
+	// interfaces whose link is up are written out as usual (respectively not at all if the capture
+	// isn't running)
+	captureManager.performWriteout(context.Background(), time.Now())
+	require.Len(t, handler.maps, 1)
+	require.Equal(t, capturetypes.LinkStateUp, handler.maps["mock0"].Stats.LinkState)
+
+	status := captureManager.Status(context.Background())
+	require.Equal(t, capturetypes.LinkStateUp, status["mock0"].LinkState)
+	require.Equal(t, capturetypes.LinkStateUp, status["mock1"].LinkState)
+	require.True(t, status["mock1"].StartedAt.IsZero())
+
+	// interfaces whose link is down (or which are absent) are marked accordingly
+	linkStates["mock0"], linkStates["mock1"] = capturetypes.LinkStateDown, capturetypes.LinkStateAbsent
+	captureManager.performWriteout(context.Background(), time.Now())
+	require.Len(t, handler.maps, 2)
+	require.Equal(t, capturetypes.LinkStateDown, handler.maps["mock0"].Stats.LinkState)
+	require.Equal(t, capturetypes.LinkStateAbsent, handler.maps["mock1"].Stats.LinkState)
+	require.Nil(t, handler.maps["mock1"].Map)
+
+	// interfaces which have never been seen aren't considered absent
+	linkStates["mock2"] = capturetypes.LinkStateAbsent
+	require.Equal(t, capturetypes.LinkStateUnknown, captureManager.linkState("mock2"))
+
+	captureManager.Close(context.Background())
+	mockSrc.Done()
+	require.Nil(t, <-errChan)
+}
+
+func TestSelfTrafficSplit(t *testing.T) {
+	flowMap := genFlowLog(t, 100).Rotate().Join()
+
+	// no endpoints / no matching endpoints leave the flow map untouched
+	own, others := NewSelfTraffic("goprobe").split(flowMap)
+	require.Nil(t, own)
+	require.Same(t, flowMap, others)
+
+	own, others = NewSelfTraffic("goprobe").AddEndpoint(net.ParseIP("10.0.0.2"), 443, protoTCP).split(flowMap)
+	require.Nil(t, own)
+	require.Same(t, flowMap, others)
+
+	// the IPv4 flows are attributed to goProbe, the IPv6 ones aren't
+	selfTraffic := NewSelfTraffic("goprobe").
+		AddEndpoint(net.ParseIP("10.0.0.2"), 443, protoUDP).
+		AddEndpoint(net.ParseIP("2001:db8::1"), 443, protoUDP)
+	own, others = selfTraffic.split(flowMap)
+	require.NotNil(t, own)
+	require.Equal(t, 50, own.PrimaryMap.Len())
+	require.Zero(t, own.SecondaryMap.Len())
+	require.Zero(t, others.PrimaryMap.Len())
+	require.Equal(t, 50, others.SecondaryMap.Len())
+
+	// the flows towards endpoints goProbe connects to are only attributed to it if they originate
+	// from a local address
+	selfTraffic = NewSelfTraffic("goprobe").
+		AddRemoteEndpoint(net.ParseIP("10.0.0.2"), 443, protoUDP).
+		AddRemoteEndpoint(net.ParseIP("2001:db8::2"), 443, protoUDP)
+	own, others = selfTraffic.split(flowMap)
+	require.Nil(t, own)
+	require.Same(t, flowMap, others)
+
+	own, others = selfTraffic.WithLocalIPs(net.ParseIP("10.0.0.4"), net.ParseIP("2001:db8::5")).split(flowMap)
+	require.NotNil(t, own)
+	require.Equal(t, 1, own.PrimaryMap.Len())
+	require.Equal(t, 1, own.SecondaryMap.Len())
+	require.Equal(t, 98, others.Len())
+
+	// the own flows of several interfaces are merged
+	merged := mergeFlowMaps(nil, own)
+	merged = mergeFlowMaps(merged, genFlowLog(t, 100).Rotate().Join())
+	require.Equal(t, 100, merged.Len())
+}
+
+func TestResolveSelfEndpoint(t *testing.T) {
+	var tests = []struct {
+		addr        string
+		defaultPort string
+		expectedIP  string
+		expected    uint16
+	}{
+		{"192.0.2.1:8145", "", "192.0.2.1", 8145},
+		{"[2001:db8::1]:4222", "4222", "2001:db8::1", 4222},
+		{"user:secret@192.0.2.1:4222", "4222", "192.0.2.1", 4222},
+		{"192.0.2.1", "4318", "192.0.2.1", 4318},
+		{"2001:db8::1", "514", "2001:db8::1", 514},
+	}
+	for _, test := range tests {
+		t.Run(test.addr, func(t *testing.T) {
+			ips, port, err := resolveEndpoint(context.Background(), test.addr, test.defaultPort, false)
+			require.Nil(t, err)
+			require.Len(t, ips, 1)
+			require.True(t, net.ParseIP(test.expectedIP).Equal(ips[0]))
+			require.Equal(t, test.expected, port)
+		})
+	}
+
+	// an API listening on all addresses is reachable via all local IPs
+	ips, port, err := resolveEndpoint(context.Background(), ":8145", "", true)
+	require.Nil(t, err)
+	require.NotEmpty(t, ips)
+	require.Equal(t, uint16(8145), port)
+
+	_, _, err = resolveEndpoint(context.Background(), "192.0.2.1", "", true)
+	require.NotNil(t, err)
+}
+
+func TestStatsHistory(t *testing.T) {
+	cm := NewManager(nil, WithStatsHistorySize(3))
+
+	_, exists := cm.History("eth0", time.Time{})
+	require.False(t, exists)
+
+	start := time.Unix(1704067200, 0)
+	for i := 0; i < 5; i++ {
+		cm.trackHistory("eth0", capturetypes.RotationStats{
+			Timestamp: start.Add(time.Duration(i) * 5 * time.Minute),
+			Flows:     uint64(i),
+		})
+	}
+
+	// only the most recent rotations are kept (oldest first)
+	history, exists := cm.History("eth0", time.Time{})
+	require.True(t, exists)
+	require.Len(t, history, 3)
+	for i, stats := range history {
+		require.Equal(t, uint64(i+2), stats.Flows)
+	}
+
+	history, _ = cm.History("eth0", start.Add(15*time.Minute))
+	require.Len(t, history, 2)
+	require.Equal(t, uint64(3), history[0].Flows)
+
+	history, _ = cm.History("eth0", start.Add(time.Hour))
+	require.NotNil(t, history)
+	require.Empty(t, history)
+
+	// the history can be disabled
+	cm = NewManager(nil, WithStatsHistorySize(0))
+	cm.trackHistory("eth0", capturetypes.RotationStats{Timestamp: start})
+	_, exists = cm.History("eth0", time.Time{})
+	require.False(t, exists)
+}
+
+func TestIfaceLocks(t *testing.T) {
+	tempDir := t.TempDir()
+	ctx := context.Background()
+
+	// the captures aren't started, but the subtrees of their interfaces are locked nonetheless
+	newInstance := func(instance string) *Manager {
+		captureManager := NewManager(writeout.NewGoDBHandler(tempDir, encoders.EncoderTypeLZ4),
+			WithSourceInitFn(func(c *Capture) (capture.SourceZeroCopy, error) {
+				return nil, errors.New("no source available")
+			}),
+		)
+		captureManager.dbPath, captureManager.instance = tempDir, instance
+		return captureManager
+	}
+	instanceA, instanceB := newInstance("a"), newInstance("b")
+
+	_, _, _, err := instanceA.Update(ctx, config.Ifaces{"mock0": defaultMockIfaceConfig})
+	require.Nil(t, err)
+
+	// an interface captured by another instance is rejected (along with all others of the update)
+	_, _, _, err = instanceB.Update(ctx, config.Ifaces{"mock0": defaultMockIfaceConfig, "mock1": defaultMockIfaceConfig})
+	require.ErrorIs(t, err, goDB.ErrIfaceLocked)
+	require.ErrorContains(t, err, "instance a")
+	require.Empty(t, instanceB.ifaceLocks)
+
+	enabled, _, _, err := instanceB.Update(ctx, config.Ifaces{"mock1": defaultMockIfaceConfig})
+	require.Nil(t, err)
+	require.Equal(t, []string{"mock1"}, enabled)
+
+	// the same interface of another tenant resides in a different subtree
+	tenantCfg := defaultMockIfaceConfig
+	tenantCfg.Tenant = "acme"
+	_, _, _, err = instanceB.Update(ctx, config.Ifaces{"mock0": tenantCfg, "mock1": defaultMockIfaceConfig})
+	require.Nil(t, err)
+	require.Len(t, instanceB.ifaceLocks, 2)
+
+	// releasing an interface allows another instance to capture it
+	_, _, _, err = instanceA.Update(ctx, config.Ifaces{"mock2": defaultMockIfaceConfig})
+	require.Nil(t, err)
+	_, _, _, err = instanceB.Update(ctx, config.Ifaces{"mock0": defaultMockIfaceConfig, "mock1": defaultMockIfaceConfig})
+	require.Nil(t, err)
+
+	// the lock of the previous (tenant) subtree was released
+	_, _, _, err = instanceA.Update(ctx, config.Ifaces{"mock0": tenantCfg})
+	require.Nil(t, err)
+
+	instanceA.Close(ctx)
+	instanceB.Close(ctx)
+	require.Empty(t, instanceA.ifaceLocks)
+	require.Empty(t, instanceB.ifaceLocks)
+}
+
+func TestUpdateAfterPrivilegeDrop(t *testing.T) {
+	mockSrc, errChan := initMockSrc(t, "mock0")
+
+	var opened []string
+	captureManager := NewManager(&recordingWriteoutHandler{maps: make(map[string]capturetypes.TaggedAggFlowMap)},
+		WithSourceInitFn(func(c *Capture) (capture.SourceZeroCopy, error) {
+			opened = append(opened, c.Iface())
+			return mockSrc, nil
+		}),
+	)
+	_, _, _, err := captureManager.Update(context.Background(), config.Ifaces{"mock0": defaultMockIfaceConfig})
+	require.Nil(t, err)
+	captureManager.SetPrivilegesDropped()
+
+	// adding an interface requires opening it, which is no longer possible
+	_, _, _, err = captureManager.Update(context.Background(), config.Ifaces{
+		"mock0": defaultMockIfaceConfig,
+		"mock1": defaultMockIfaceConfig,
+	})
+	require.ErrorIs(t, err, ErrPrivilegesDropped)
+
+	// the same applies to changing an interface, since it is re-opened
+	changedCfg := defaultMockIfaceConfig
+	changedCfg.Promisc = true
+	_, _, _, err = captureManager.Update(context.Background(), config.Ifaces{"mock0": changedCfg})
+	require.ErrorIs(t, err, ErrPrivilegesDropped)
+	require.ErrorIs(t, captureManager.CheckUpdate([]string{"mock1"}, nil), ErrPrivilegesDropped)
+	require.Nil(t, captureManager.CheckUpdate(nil, nil))
+
+	// the running capture is left untouched
+	require.Equal(t, []string{"mock0"}, opened)
+	require.Equal(t, config.Ifaces{"mock0": defaultMockIfaceConfig}, captureManager.Config())
+
+	enabled, updated, disabled, err := captureManager.Update(context.Background(), config.Ifaces{"mock0": defaultMockIfaceConfig})
+	require.Nil(t, err)
+	require.Empty(t, enabled)
+	require.Empty(t, updated)
+	require.Empty(t, disabled)
+
+	captureManager.Close(context.Background())
+	mockSrc.Done()
+	require.Nil(t, <-errChan)
+}
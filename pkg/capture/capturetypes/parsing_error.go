@@ -50,7 +50,7 @@ func (e ParsingErrTracker) Sum() (res int) {
 }
 
 // Reset resets all error counters in the error table (for reuse)
-func (e ParsingErrTracker) Reset() {
+func (e *ParsingErrTracker) Reset() {
 	for i := ErrnoInvalidIPHeader; i < NumParsingErrors; i++ {
 		e[i] = 0
 	}
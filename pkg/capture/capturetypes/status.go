@@ -3,6 +3,9 @@ package capturetypes
 import (
 	"time"
 
+	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/types"
 	"github.com/els0r/goProbe/pkg/types/hashmap"
 )
 
@@ -16,6 +19,29 @@ type TaggedAggFlowMap struct {
 	Map   *hashmap.AggFlowMap
 	Stats CaptureStats `json:"stats,omitempty"`
 	Iface string       `json:"iface"`
+
+	// Tenant denotes the tenant the interface is assigned to (if any)
+	Tenant string `json:"tenant,omitempty"`
+
+	// Encoder overrides the encoder the flows are stored with (if set)
+	Encoder *Encoder `json:"encoder,omitempty"`
+
+	// Samples denotes a sample of the individual flows prior to their aggregation (if flow
+	// sampling is enabled)
+	Samples []results.ExtendedRow `json:"samples,omitempty"`
+
+	// Processes provides the local process each flow is attributed to (if process attribution
+	// is enabled)
+	Processes FlowLabeler `json:"-"`
+}
+
+// FlowLabeler provides the label of a flow (or an empty string if it is unknown)
+type FlowLabeler func(key types.Key) string
+
+// Encoder denotes the encoder (and its compression level) used to store the flows of an interface
+type Encoder struct {
+	Type  encoders.Type `json:"type"`
+	Level int           `json:"level,omitempty"` // Level: compression level (zero denotes the default level of the encoder)
 }
 
 // InterfaceStats stores the statistics for each interface
@@ -23,19 +49,40 @@ type InterfaceStats map[string]CaptureStats
 
 // CaptureStats stores the capture stores its statistics
 type CaptureStats struct {
-	StartedAt      time.Time `json:"started_at"`      // StartedAt: denotes the time when the capture was started. Example: "2021-01-01T00:00:00Z"
-	Received       uint64    `json:"received"`        // Received: denotes the number of packets received. Example: 69
-	ReceivedTotal  uint64    `json:"received_total"`  // ReceivedTotal: denotes the number of packets received since the capture was started. Example: 69000
-	Processed      uint64    `json:"processed"`       // Processed: denotes the number of packets processed by the capture. Example: 70
-	ProcessedTotal uint64    `json:"processed_total"` // ProcessedTotal denotes the number of packets processed since the capture was started. Example: 70000
-	Dropped        uint64    `json:"dropped"`         // Dropped: denotes the number of packets dropped. Example: 3
-	DroppedTotal   uint64    `json:"dropped_total"`   // DroppedTotal: denotes the number of packets dropped since the capture was started. Example: 20
+	StartedAt      time.Time `json:"started_at"`           // StartedAt: denotes the time when the capture was started. Example: "2021-01-01T00:00:00Z"
+	Received       uint64    `json:"received"`             // Received: denotes the number of packets received. Example: 69
+	ReceivedTotal  uint64    `json:"received_total"`       // ReceivedTotal: denotes the number of packets received since the capture was started. Example: 69000
+	Processed      uint64    `json:"processed"`            // Processed: denotes the number of packets processed by the capture. Example: 70
+	ProcessedTotal uint64    `json:"processed_total"`      // ProcessedTotal denotes the number of packets processed since the capture was started. Example: 70000
+	Dropped        uint64    `json:"dropped"`              // Dropped: denotes the number of packets dropped. Example: 3
+	DroppedTotal   uint64    `json:"dropped_total"`        // DroppedTotal: denotes the number of packets dropped since the capture was started. Example: 20
+	Overruns       uint64    `json:"overruns"`             // Overruns: denotes the number of ring buffer overruns (queue freezes). Example: 1
+	LinkState      LinkState `json:"link_state,omitempty"` // LinkState: denotes the state of the link of the interface (as of the status / rotation). Example: "up"
 
 	// ParsingErrors: denotes all packet parsing errors / failures encountered
 	// Example: [23, 0]
 	ParsingErrors ParsingErrTracker `json:"parsing_errors,omitempty"`
 }
 
+// LinkState denotes the state of the link of an interface
+type LinkState string
+
+const (
+	// LinkStateUnknown denotes that the state of the link couldn't be determined
+	LinkStateUnknown LinkState = ""
+	// LinkStateUp denotes that the link is up
+	LinkStateUp LinkState = "up"
+	// LinkStateDown denotes that the link is down (e.g. administratively or due to a lack of carrier)
+	LinkStateDown LinkState = "down"
+	// LinkStateAbsent denotes that the interface doesn't exist (anymore)
+	LinkStateAbsent LinkState = "absent"
+)
+
+// IsDown returns whether no traffic can be captured on the link
+func (s LinkState) IsDown() bool {
+	return s == LinkStateDown || s == LinkStateAbsent
+}
+
 // AddStats is a convenience method to total capture stats. This is relevant in the scope of
 // adding statistics from the two directions. The result of the addition is written back
 // to a to reduce allocations
@@ -57,3 +104,16 @@ func SubStats(a, b *CaptureStats) {
 	a.Received -= b.Received
 	a.Dropped -= b.Dropped
 }
+
+// RotationStats stores the statistics of an interface for a single rotation / writeout interval
+type RotationStats struct {
+	Timestamp time.Time `json:"timestamp"` // Timestamp: denotes the time of the rotation. Example: "2021-01-01T00:05:00Z"
+	Received  uint64    `json:"received"`  // Received: denotes the number of packets received during the interval. Example: 69
+	Processed uint64    `json:"processed"` // Processed: denotes the number of packets processed during the interval. Example: 70
+	Dropped   uint64    `json:"dropped"`   // Dropped: denotes the number of packets dropped during the interval. Example: 3
+	Flows     uint64    `json:"flows"`     // Flows: denotes the number of flows written out for the interval. Example: 512
+	Bytes     uint64    `json:"bytes"`     // Bytes: denotes the number of bytes (in both directions) written out for the interval. Example: 1048576
+}
+
+// StatsHistory stores the statistics of consecutive rotations of an interface (oldest first)
+type StatsHistory []RotationStats
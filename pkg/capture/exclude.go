@@ -0,0 +1,102 @@
+package capture
+
+import (
+	"github.com/els0r/goProbe/cmd/goProbe/config"
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+)
+
+// exclusionRule denotes a pre-parsed config.ExclusionRule, allowing for fast matching against the
+// EPHash of a packet
+type exclusionRule struct {
+	hasNet    bool
+	netIPv4   bool
+	netPrefix [16]byte
+	netMask   [16]byte
+
+	hasPort bool
+	port    [2]byte
+
+	hasProto bool
+	proto    byte
+}
+
+// exclusionFilter drops the packets matching any of its rules before they are added to the flow log
+type exclusionFilter []exclusionRule
+
+// newExclusionFilter parses the (validated) exclusion rules of an interface. If there are none, nil
+// is returned
+func newExclusionFilter(rules []config.ExclusionRule) (exclusionFilter, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	filter := make(exclusionFilter, 0, len(rules))
+	for _, rule := range rules {
+		var r exclusionRule
+
+		ipNet, err := rule.ParseNet()
+		if err != nil {
+			return nil, err
+		}
+		if ipNet != nil {
+			r.hasNet, r.netIPv4 = true, len(ipNet.IP) == 4
+			copy(r.netPrefix[:], ipNet.IP)
+			copy(r.netMask[:], ipNet.Mask)
+		}
+
+		if rule.Port != 0 {
+			r.hasPort = true
+			r.port = [2]byte{byte(rule.Port >> 8), byte(rule.Port)}
+		}
+
+		if r.proto, r.hasProto, err = rule.ParseProto(); err != nil {
+			return nil, err
+		}
+		filter = append(filter, r)
+	}
+	return filter, nil
+}
+
+// excludes determines if the packet denoted by epHash matches any of the rules
+func (f exclusionFilter) excludes(epHash *capturetypes.EPHash, isIPv4 bool) bool {
+	for i := range f {
+		if f[i].matches(epHash, isIPv4) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *exclusionRule) matches(epHash *capturetypes.EPHash, isIPv4 bool) bool {
+	if r.hasProto && epHash[36] != r.proto {
+		return false
+	}
+
+	// the EPHash holds the destination port first, followed by the source port (either of which
+	// may be unset for common ports, e.g. the source port of a request towards port 443)
+	if r.hasPort && !(epHash[32] == r.port[0] && epHash[33] == r.port[1]) &&
+		!(epHash[34] == r.port[0] && epHash[35] == r.port[1]) {
+		return false
+	}
+
+	if r.hasNet {
+		if r.netIPv4 != isIPv4 {
+			return false
+		}
+		ipLen := 16
+		if isIPv4 {
+			ipLen = 4
+		}
+		return r.containsIP(epHash[0:ipLen]) || r.containsIP(epHash[16:16+ipLen])
+	}
+	return true
+}
+
+func (r *exclusionRule) containsIP(ip []byte) bool {
+	for i := range ip {
+		if ip[i]&r.netMask[i] != r.netPrefix[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -13,8 +13,13 @@ package capture
 //
 /////////////////////////////////////////////////////////////////////////////////
 import (
+	"container/heap"
+	"encoding/binary"
 	"fmt"
 	"io"
+	"runtime"
+	"slices"
+	"sync"
 	"text/tabwriter"
 
 	"github.com/els0r/goProbe/pkg/capture/capturetypes"
@@ -24,6 +29,7 @@ import (
 	"github.com/els0r/goProbe/pkg/types/hashmap"
 	"github.com/fako1024/slimcap/capture"
 	jsoniter "github.com/json-iterator/go"
+	"github.com/zeebo/xxh3"
 	"golang.org/x/net/ipv4"
 	"golang.org/x/net/ipv6"
 )
@@ -34,32 +40,96 @@ const (
 )
 
 // FlowLog stores flows. It is NOT threadsafe.
+//
+// Flows are distributed across several independent shards (based on their IP addresses, so
+// both directions of a flow as well as all flows aggregated into the same entry upon rotation
+// end up in the same shard). This allows to rotate / aggregate the shards in parallel, which
+// minimizes the time the capture has to be locked
 type FlowLog struct {
-	flowMap map[string]*Flow
+	flowMaps  []map[string]*Flow
+	shardMask uint32
 }
 
+// maxFlowLogShards limits the number of shards of a FlowLog (and hence the number of goroutines
+// spawned during rotation)
+const maxFlowLogShards = 16
+
+// numFlowLogShards denotes the number of shards used by default (the largest power of two not
+// exceeding the number of CPUs)
+var numFlowLogShards = func() int {
+	n := 1
+	for n*2 <= runtime.NumCPU() && n*2 <= maxFlowLogShards {
+		n *= 2
+	}
+	return n
+}()
+
 // NewFlowLog creates a new flow log for storing flows.
 func NewFlowLog() *FlowLog {
-	return &FlowLog{make(map[string]*Flow)}
+	return newFlowLog(numFlowLogShards)
+}
+
+// newFlowLog creates a new flow log with nShards shards (which must be a power of two)
+func newFlowLog(nShards int) *FlowLog {
+	f := &FlowLog{
+		flowMaps:  make([]map[string]*Flow, nShards),
+		shardMask: uint32(nShards - 1),
+	}
+	for i := range f.flowMaps {
+		f.flowMaps[i] = make(map[string]*Flow)
+	}
+	return f
+}
+
+// shard determines the flow map a flow is stored in. The result only depends on the (unordered)
+// pair of IP addresses, i.e. it is identical for an EPHash and its reverse
+func (f *FlowLog) shard(epHash *capturetypes.EPHash, isIPv4 bool) map[string]*Flow {
+	if f.shardMask == 0 {
+		return f.flowMaps[0]
+	}
+
+	var h uint32
+	if isIPv4 {
+		h = binary.LittleEndian.Uint32(epHash[0:4]) ^ binary.LittleEndian.Uint32(epHash[16:20])
+	} else {
+		h64 := binary.LittleEndian.Uint64(epHash[0:8]) ^ binary.LittleEndian.Uint64(epHash[8:16]) ^
+			binary.LittleEndian.Uint64(epHash[16:24]) ^ binary.LittleEndian.Uint64(epHash[24:32])
+		h = uint32(h64) ^ uint32(h64>>32)
+	}
+	h ^= h >> 16
+	h ^= h >> 8
+
+	return f.flowMaps[h&f.shardMask]
 }
 
 // MarshalJSON implements the jsoniter.Marshaler interface
 func (f *FlowLog) MarshalJSON() ([]byte, error) {
 	var toMarshal []interface{}
-	for _, v := range f.flowMap {
-		toMarshal = append(toMarshal, v)
+	for _, flowMap := range f.flowMaps {
+		for _, v := range flowMap {
+			toMarshal = append(toMarshal, v)
+		}
 	}
 	return jsoniter.Marshal(toMarshal)
 }
 
 // Len returns the number of flows in the FlowLog
-func (f *FlowLog) Len() int {
-	return len(f.flowMap)
+func (f *FlowLog) Len() (l int) {
+	for _, flowMap := range f.flowMaps {
+		l += len(flowMap)
+	}
+	return
 }
 
-// Flows provides an iterator for the internal flow map
+// Flows returns all flows of the FlowLog (across all shards)
 func (f *FlowLog) Flows() map[string]*Flow {
-	return f.flowMap
+	flows := make(map[string]*Flow, f.Len())
+	for _, flowMap := range f.flowMaps {
+		for k, v := range flowMap {
+			flows[k] = v
+		}
+	}
+	return flows
 }
 
 // ParsePacket processes / extracts all information contained in the IP layer received
@@ -184,14 +254,15 @@ func (f *FlowLog) Add(epHash capturetypes.EPHash, pktType byte, pktSize uint32,
 	}
 
 	// update or assign the flow
-	if flowToUpdate, existsHash := f.flowMap[string(epHash[:])]; existsHash {
+	flowMap := f.shard(&epHash, isIPv4)
+	if flowToUpdate, existsHash := flowMap[string(epHash[:])]; existsHash {
 		flowToUpdate.UpdateFlow(epHash, auxInfo, pktType, pktSize)
 	} else {
 		epHashReverse := epHash.Reverse()
-		if flowToUpdate, existsReverseHash := f.flowMap[string(epHashReverse[:])]; existsReverseHash {
+		if flowToUpdate, existsReverseHash := flowMap[string(epHashReverse[:])]; existsReverseHash {
 			flowToUpdate.UpdateFlow(epHashReverse, auxInfo, pktType, pktSize)
 		} else {
-			f.flowMap[string(epHash[:])] = NewFlow(epHash, isIPv4, auxInfo, pktType, pktSize)
+			flowMap[string(epHash[:])] = NewFlow(epHash, isIPv4, auxInfo, pktType, pktSize)
 		}
 	}
 
@@ -202,22 +273,75 @@ func (f *FlowLog) Add(epHash capturetypes.EPHash, pktType byte, pktSize uint32,
 // Moreover, any flows not worth keeping (according to Flow.IsWorthKeeping)
 // are discarded.
 //
-// Returns an AggFlowMap containing all flows since the last call to Rotate.
-func (f *FlowLog) Rotate() *hashmap.AggFlowMap {
-	return f.transferAndAggregate()
+// Returns a ShardedAggFlowMap containing all flows since the last call to Rotate. Joining
+// the shards can (and should) be done after the capture has been unlocked.
+func (f *FlowLog) Rotate() *hashmap.ShardedAggFlowMap {
+	return f.forEachShard(func(_ int, flowMap map[string]*Flow, agg *hashmap.AggFlowMap) {
+		transferAndAggregate(flowMap, agg, nil)
+	})
 }
 
-// Aggregate extracts an AggFlowMap from the currently active flowMap. The flowMap
+// RotateSampled rotates the flow log (see Rotate), additionally returning a 1:rate sample of the
+// individual flows (including their source port) prior to their aggregation. Flows are selected
+// based on their hash, hence a sampled flow remains sampled across rotations
+func (f *FlowLog) RotateSampled(rate int) (*hashmap.ShardedAggFlowMap, []results.ExtendedRow) {
+	if rate < 1 {
+		return f.Rotate(), nil
+	}
+
+	shardSamples := make([][]results.ExtendedRow, len(f.flowMaps))
+	agg := f.forEachShard(func(i int, flowMap map[string]*Flow, agg *hashmap.AggFlowMap) {
+		transferAndAggregate(flowMap, agg, func(flow *Flow) {
+			if flow.isSampled(rate) {
+				shardSamples[i] = append(shardSamples[i], flow.toExtendedRow())
+			}
+		})
+	})
+
+	var samples []results.ExtendedRow
+	for _, s := range shardSamples {
+		samples = append(samples, s...)
+	}
+	return agg, samples
+}
+
+// Aggregate extracts a ShardedAggFlowMap from the currently active flowMap. The flowMap
 // itself is not modified in the process.
 //
-// Returns an AggFlowMap containing all flows since the last call to Rotate.
-func (f *FlowLog) Aggregate() (agg *hashmap.AggFlowMap) {
+// Returns a ShardedAggFlowMap containing all flows since the last call to Rotate.
+func (f *FlowLog) Aggregate() *hashmap.ShardedAggFlowMap {
+	return f.forEachShard(func(_ int, flowMap map[string]*Flow, agg *hashmap.AggFlowMap) {
+		aggregate(flowMap, agg)
+	})
+}
+
+// forEachShard runs fn on every shard of the flow log (in parallel), using the corresponding
+// shard of the result map
+func (f *FlowLog) forEachShard(fn func(shardIdx int, flowMap map[string]*Flow, agg *hashmap.AggFlowMap)) *hashmap.ShardedAggFlowMap {
+	agg := hashmap.NewShardedAggFlowMap(len(f.flowMaps))
+	if len(f.flowMaps) == 1 {
+		fn(0, f.flowMaps[0], agg.Shard(0))
+		return agg
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(f.flowMaps))
+	for i, flowMap := range f.flowMaps {
+		go func(i int, flowMap map[string]*Flow, shard *hashmap.AggFlowMap) {
+			defer wg.Done()
+			fn(i, flowMap, shard)
+		}(i, flowMap, agg.Shard(i))
+	}
+	wg.Wait()
+
+	return agg
+}
 
-	agg = hashmap.NewAggFlowMap()
+func aggregate(flowMap map[string]*Flow, agg *hashmap.AggFlowMap) {
 
 	// Reusable key conversion buffers
 	keyBufV4, keyBufV6 := types.NewEmptyV4Key(), types.NewEmptyV6Key()
-	for _, v := range f.flowMap {
+	for _, v := range flowMap {
 
 		// Check if the flow actually has any interesting information for us
 		if v.packetsRcvd != 0 || v.packetsSent != 0 {
@@ -232,19 +356,16 @@ func (f *FlowLog) Aggregate() (agg *hashmap.AggFlowMap) {
 			}
 		}
 	}
-
-	return
 }
 
-func (f *FlowLog) transferAndAggregate() (agg *hashmap.AggFlowMap) {
-
-	// Initialize aggregate flow map / result
-	agg = hashmap.NewAggFlowMap()
+// transferAndAggregate aggregates all active flows of the flow map and resets (or discards) them.
+// If set, sample is called for each active flow prior to its reset
+func transferAndAggregate(flowMap map[string]*Flow, agg *hashmap.AggFlowMap, sample func(flow *Flow)) {
 
 	// Create reusable key conversion buffers
 	keyBufV4, keyBufV6 := types.NewEmptyV4Key(), types.NewEmptyV6Key()
 
-	for k, v := range f.flowMap {
+	for k, v := range flowMap {
 
 		// Check if the flow actually has any interesting information for us, otherwise
 		// delete it from the FlowMap
@@ -258,6 +379,9 @@ func (f *FlowLog) transferAndAggregate() (agg *hashmap.AggFlowMap) {
 				keyBufV6.PutAllV6(v.epHash[0:16], v.epHash[16:32], v.epHash[32:34], v.epHash[36])
 				agg.SetOrUpdate(keyBufV6, false, v.bytesRcvd, v.bytesSent, v.packetsRcvd, v.packetsSent)
 			}
+			if sample != nil {
+				sample(v)
+			}
 
 			// Check whether the flow should be retained / reset for the next interval
 			// or thrown away
@@ -266,21 +390,100 @@ func (f *FlowLog) transferAndAggregate() (agg *hashmap.AggFlowMap) {
 				// Reset the flow
 				v.Reset()
 			} else {
-				delete(f.flowMap, k)
+				delete(flowMap, k)
 			}
 		} else {
-			delete(f.flowMap, k)
+			delete(flowMap, k)
 		}
 	}
+}
 
-	return
+// TopFlows returns the n flows of the FlowLog with the most bytes or packets (in both directions,
+// depending on sortBy), in descending order. If n isn't positive, all flows are returned. Only the
+// top n flows are retained (in a bounded heap) while iterating over the FlowLog, hence it doesn't
+// have to be copied beforehand
+func (f *FlowLog) TopFlows(n int, sortBy results.SortOrder) FlowInfos {
+	metric := types.Counters.SumBytes
+	if sortBy == results.SortPackets {
+		metric = types.Counters.SumPackets
+	}
+	if l := f.Len(); n <= 0 || n > l {
+		n = l
+	}
+	if n == 0 {
+		return FlowInfos{}
+	}
+
+	// flows are ranked by the metric, ties are broken by their attributes
+	compare := func(a, b *FlowInfo) int {
+		ma, mb := metric(a.Flow.Counters), metric(b.Flow.Counters)
+		switch {
+		case ma > mb:
+			return -1
+		case ma < mb:
+			return 1
+		}
+		if a.Flow.Attributes.Attributes != b.Flow.Attributes.Attributes {
+			if a.Flow.Attributes.Less(b.Flow.Attributes.Attributes) {
+				return -1
+			}
+			return 1
+		}
+		return int(a.Flow.Attributes.SrcPort) - int(b.Flow.Attributes.SrcPort)
+	}
+
+	top := &flowInfoHeap{flows: make(FlowInfos, 0, n), compare: compare}
+	for _, flowMap := range f.flowMaps {
+		for _, flow := range flowMap {
+
+			// skip flows ranking below the lowest ranked top flow without converting them
+			if len(top.flows) == n && metric(flow.counters()) < metric(top.flows[0].Flow.Counters) {
+				continue
+			}
+
+			info := FlowInfo{
+				Idle:                    flow.packetsRcvd == 0 && flow.packetsSent == 0,
+				DirectionConfidenceHigh: flow.directionConfidenceHigh,
+				Flow:                    flow.toExtendedRow(),
+			}
+			if len(top.flows) < n {
+				heap.Push(top, info)
+			} else if compare(&info, &top.flows[0]) < 0 {
+				top.flows[0] = info
+				heap.Fix(top, 0)
+			}
+		}
+	}
+
+	slices.SortFunc(top.flows, func(a, b FlowInfo) int {
+		return compare(&a, &b)
+	})
+	return top.flows
+}
+
+// flowInfoHeap is a min-heap of flows, the root being the lowest ranked flow (see TopFlows())
+type flowInfoHeap struct {
+	flows   FlowInfos
+	compare func(a, b *FlowInfo) int
+}
+
+func (h *flowInfoHeap) Len() int           { return len(h.flows) }
+func (h *flowInfoHeap) Less(i, j int) bool { return h.compare(&h.flows[i], &h.flows[j]) > 0 }
+func (h *flowInfoHeap) Swap(i, j int)      { h.flows[i], h.flows[j] = h.flows[j], h.flows[i] }
+func (h *flowInfoHeap) Push(x any)         { h.flows = append(h.flows, x.(FlowInfo)) }
+func (h *flowInfoHeap) Pop() any {
+	last := h.flows[len(h.flows)-1]
+	h.flows = h.flows[:len(h.flows)-1]
+	return last
 }
 
 func (f *FlowLog) clone() (f2 *FlowLog) {
-	f2 = NewFlowLog()
-	for k, v := range f.flowMap {
-		vCopy := *v
-		f2.flowMap[k] = &vCopy
+	f2 = newFlowLog(len(f.flowMaps))
+	for i, flowMap := range f.flowMaps {
+		for k, v := range flowMap {
+			vCopy := *v
+			f2.flowMaps[i][k] = &vCopy
+		}
 	}
 	return
 }
@@ -419,6 +622,7 @@ func (f *Flow) updateDirection(epHash capturetypes.EPHash, auxInfo byte) {
 
 func (f *Flow) toExtendedRow() results.ExtendedRow {
 	return results.ExtendedRow{
+		Counters: f.counters(),
 		Attributes: results.ExtendedAttributes{
 			SrcPort: types.PortToUint16(f.epHash[34:36]),
 			Attributes: results.Attributes{
@@ -428,15 +632,24 @@ func (f *Flow) toExtendedRow() results.ExtendedRow {
 				IPProto: f.epHash[36],
 			},
 		},
-		Counters: types.Counters{
-			BytesRcvd:   f.bytesRcvd,
-			BytesSent:   f.bytesSent,
-			PacketsRcvd: f.packetsRcvd,
-			PacketsSent: f.packetsSent,
-		},
 	}
 }
 
+// counters returns the counters of the flow
+func (f *Flow) counters() types.Counters {
+	return types.Counters{
+		BytesRcvd:   f.bytesRcvd,
+		BytesSent:   f.bytesSent,
+		PacketsRcvd: f.packetsRcvd,
+		PacketsSent: f.packetsSent,
+	}
+}
+
+// isSampled determines if the flow is part of a 1:rate sample of all flows
+func (f *Flow) isSampled(rate int) bool {
+	return xxh3.Hash(f.epHash[:])%uint64(rate) == 0
+}
+
 func isCommonPort(port []byte, proto byte) bool {
 	// Fast path for neither of the below
 	if port[0] > 1 {
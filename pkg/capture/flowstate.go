@@ -0,0 +1,231 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	flowStateFileName = "flows.state"
+	flowStateVersion  = 1
+
+	// flowRecordSize denotes the size of a serialized flow (EPHash, flags and four counters)
+	flowRecordSize = capturetypes.EPHashSize + 1 + 4*8
+
+	flowFlagIPv4                    = 1 << 0
+	flowFlagDirectionConfidenceHigh = 1 << 1
+)
+
+var errorFlowStateVersion = errors.New("unsupported flow state version")
+
+// flowState denotes the flow logs of a set of interfaces persisted upon shutdown, so that the flows
+// accumulated since the last writeout can be restored upon the next start. It is stored as JSON
+// header (prefixed by its length), followed by the serialized flows of all interfaces (in order)
+type flowState struct {
+	Version   int                 `json:"version"`
+	Timestamp time.Time           `json:"timestamp"` // Timestamp: the time the flows were persisted
+	Ifaces    []ifaceFlowStateHdr `json:"ifaces"`
+
+	flowLogs []*FlowLog
+}
+
+// ifaceFlowStateHdr denotes the metadata of the persisted flow log of an interface
+type ifaceFlowStateHdr struct {
+	Iface    string                    `json:"iface"`
+	Tenant   string                    `json:"tenant,omitempty"`
+	Encoder  *capturetypes.Encoder     `json:"encoder,omitempty"`
+	NumFlows int                       `json:"num_flows"`
+	Stats    capturetypes.CaptureStats `json:"stats"` // Stats: the capture stats since the last writeout
+}
+
+// ifaceFlowState denotes the restored flow log of an interface
+type ifaceFlowState struct {
+	ifaceFlowStateHdr
+	flowLog *FlowLog
+}
+
+func (s *flowState) add(hdr ifaceFlowStateHdr, flowLog *FlowLog) {
+	hdr.NumFlows = flowLog.Len()
+	s.Ifaces = append(s.Ifaces, hdr)
+	s.flowLogs = append(s.flowLogs, flowLog)
+}
+
+// writeFlowState stores the flow state of a goProbe instance in the DB at dbPath (replacing any state
+// stored previously by the instance)
+func writeFlowState(dbPath, instance string, state *flowState) (err error) {
+	if err := info.CheckDBExists(dbPath); err != nil {
+		return err
+	}
+	state.Version = flowStateVersion
+
+	fileName := info.InstanceFileName(flowStateFileName, instance)
+	path := filepath.Join(dbPath, fileName)
+	f, err := os.CreateTemp(dbPath, fileName+".*")
+	if err != nil {
+		return fmt.Errorf("failed to store flow state: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	w := bufio.NewWriter(f)
+	if err = state.encode(w); err != nil {
+		return fmt.Errorf("failed to store flow state: %w", err)
+	}
+	if err = w.Flush(); err != nil {
+		return fmt.Errorf("failed to store flow state: %w", err)
+	}
+	if err = f.Sync(); err != nil {
+		return fmt.Errorf("failed to store flow state: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("failed to store flow state: %w", err)
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to store flow state: %w", err)
+	}
+	return nil
+}
+
+// takeFlowState reads and removes the flow state of a goProbe instance stored in the DB at dbPath, so
+// that the flows are restored at most once. If none was stored, nil is returned
+func takeFlowState(dbPath, instance string) (*flowState, error) {
+	path := filepath.Clean(filepath.Join(dbPath, info.InstanceFileName(flowStateFileName, instance)))
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read flow state: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(path)
+	}()
+
+	state := new(flowState)
+	if err := state.decode(bufio.NewReader(f)); err != nil {
+		return nil, fmt.Errorf("failed to parse flow state: %w", err)
+	}
+	return state, nil
+}
+
+// flowStates returns the persisted flow logs per interface
+func (s *flowState) flowStates() map[string]ifaceFlowState {
+	states := make(map[string]ifaceFlowState, len(s.Ifaces))
+	for i, hdr := range s.Ifaces {
+		states[hdr.Iface] = ifaceFlowState{
+			ifaceFlowStateHdr: hdr,
+			flowLog:           s.flowLogs[i],
+		}
+	}
+	return states
+}
+
+func (s *flowState) encode(w io.Writer) error {
+	hdr, err := jsoniter.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint32(len(hdr))); err != nil {
+		return err
+	}
+	if _, err := w.Write(hdr); err != nil {
+		return err
+	}
+
+	var record [flowRecordSize]byte
+	for _, flowLog := range s.flowLogs {
+		for _, flowMap := range flowLog.flowMaps {
+			for _, flow := range flowMap {
+				flow.encode(record[:])
+				if _, err := w.Write(record[:]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *flowState) decode(r io.Reader) error {
+	var hdrLen uint32
+	if err := binary.Read(r, binary.BigEndian, &hdrLen); err != nil {
+		return err
+	}
+	hdr := make([]byte, hdrLen)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return err
+	}
+	if err := jsoniter.Unmarshal(hdr, s); err != nil {
+		return err
+	}
+	if s.Version != flowStateVersion {
+		return fmt.Errorf("%w: %d", errorFlowStateVersion, s.Version)
+	}
+
+	var record [flowRecordSize]byte
+	s.flowLogs = make([]*FlowLog, len(s.Ifaces))
+	for i, ifaceHdr := range s.Ifaces {
+		flowLog := NewFlowLog()
+		for j := 0; j < ifaceHdr.NumFlows; j++ {
+			if _, err := io.ReadFull(r, record[:]); err != nil {
+				return err
+			}
+			flow := new(Flow)
+			flow.decode(record[:])
+			flowLog.shard(&flow.epHash, flow.isIPv4)[string(flow.epHash[:])] = flow
+		}
+		s.flowLogs[i] = flowLog
+	}
+	return nil
+}
+
+func (f *Flow) encode(record []byte) {
+	_ = record[flowRecordSize-1] // bounds check hint to compiler
+
+	copy(record, f.epHash[:])
+	var flags byte
+	if f.isIPv4 {
+		flags |= flowFlagIPv4
+	}
+	if f.directionConfidenceHigh {
+		flags |= flowFlagDirectionConfidenceHigh
+	}
+	record[capturetypes.EPHashSize] = flags
+
+	counters := record[capturetypes.EPHashSize+1:]
+	binary.BigEndian.PutUint64(counters[0:8], f.bytesRcvd)
+	binary.BigEndian.PutUint64(counters[8:16], f.bytesSent)
+	binary.BigEndian.PutUint64(counters[16:24], f.packetsRcvd)
+	binary.BigEndian.PutUint64(counters[24:32], f.packetsSent)
+}
+
+func (f *Flow) decode(record []byte) {
+	_ = record[flowRecordSize-1] // bounds check hint to compiler
+
+	copy(f.epHash[:], record)
+	flags := record[capturetypes.EPHashSize]
+	f.isIPv4 = flags&flowFlagIPv4 != 0
+	f.directionConfidenceHigh = flags&flowFlagDirectionConfidenceHigh != 0
+
+	counters := record[capturetypes.EPHashSize+1:]
+	f.bytesRcvd = binary.BigEndian.Uint64(counters[0:8])
+	f.bytesSent = binary.BigEndian.Uint64(counters[8:16])
+	f.packetsRcvd = binary.BigEndian.Uint64(counters[16:24])
+	f.packetsSent = binary.BigEndian.Uint64(counters[24:32])
+}
@@ -0,0 +1,69 @@
+package capture
+
+import (
+	"time"
+
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/goProbe/pkg/types/hashmap"
+)
+
+// DefaultStatsHistorySize denotes the number of rotations kept in the statistics history of each
+// interface by default (one day at the default writeout interval of five minutes)
+const DefaultStatsHistorySize = 288
+
+// statsHistory is a ring buffer holding the statistics of the most recent rotations of an interface
+type statsHistory struct {
+	entries []capturetypes.RotationStats
+	next    int
+	full    bool
+}
+
+func newStatsHistory(size int) *statsHistory {
+	return &statsHistory{
+		entries: make([]capturetypes.RotationStats, size),
+	}
+}
+
+// add appends the statistics of a rotation, overwriting the oldest entry once the buffer is full
+func (h *statsHistory) add(stats capturetypes.RotationStats) {
+	h.entries[h.next] = stats
+	if h.next++; h.next == len(h.entries) {
+		h.next, h.full = 0, true
+	}
+}
+
+// since returns the statistics of all rotations at or after t (oldest first)
+func (h *statsHistory) since(t time.Time) capturetypes.StatsHistory {
+	var ordered []capturetypes.RotationStats
+	if h.full {
+		ordered = append(ordered, h.entries[h.next:]...)
+	}
+	ordered = append(ordered, h.entries[:h.next]...)
+
+	history := make(capturetypes.StatsHistory, 0, len(ordered))
+	for _, stats := range ordered {
+		if !stats.Timestamp.Before(t) {
+			history = append(history, stats)
+		}
+	}
+	return history
+}
+
+// newRotationStats summarizes the capture statistics and the flows of a rotation
+func newRotationStats(timestamp time.Time, stats *capturetypes.CaptureStats, flowMap *hashmap.AggFlowMap) capturetypes.RotationStats {
+	rotationStats := capturetypes.RotationStats{
+		Timestamp: timestamp,
+	}
+	if stats != nil {
+		rotationStats.Received = stats.Received
+		rotationStats.Processed = stats.Processed
+		rotationStats.Dropped = stats.Dropped
+	}
+	if flowMap != nil && !flowMap.IsNil() {
+		rotationStats.Flows = uint64(flowMap.Len())
+		for it := flowMap.Iter(); it.Next(); {
+			rotationStats.Bytes += it.Val().SumBytes()
+		}
+	}
+	return rotationStats
+}
@@ -0,0 +1,73 @@
+package capture
+
+import (
+	"net"
+	"sort"
+
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+)
+
+// linkStateFn determines the state of the link of an interface
+type linkStateFn func(iface string) capturetypes.LinkState
+
+// ifaceLinkState determines the state of the link of an interface from its flags. An interface is
+// considered up if it is both administratively up and has a carrier
+func ifaceLinkState(iface string) capturetypes.LinkState {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return capturetypes.LinkStateUnknown
+	}
+	for _, netIface := range ifaces {
+		if netIface.Name != iface {
+			continue
+		}
+		if netIface.Flags&net.FlagUp != 0 && netIface.Flags&net.FlagRunning != 0 {
+			return capturetypes.LinkStateUp
+		}
+		return capturetypes.LinkStateDown
+	}
+	return capturetypes.LinkStateAbsent
+}
+
+// linkState determines the state of the link of an interface. Interfaces which have never been seen
+// are not considered absent (but of unknown state) in order to not flag interfaces which aren't
+// backed by a network interface of the host (e.g. in case of a custom capture source)
+func (cm *Manager) linkState(iface string) capturetypes.LinkState {
+	state := cm.linkStateFn(iface)
+
+	cm.stateLock.Lock()
+	defer cm.stateLock.Unlock()
+
+	if state == capturetypes.LinkStateAbsent {
+		if _, seen := cm.linkStates[iface]; !seen {
+			return capturetypes.LinkStateUnknown
+		}
+	}
+	if state != capturetypes.LinkStateUnknown {
+		cm.linkStates[iface] = state
+	}
+	return state
+}
+
+// missingIfaces returns the configured interfaces (out of all or a set of interfaces) without a
+// running capture
+func (cm *Manager) missingIfaces(ifaces ...string) (missing []string) {
+	cm.RLock()
+	defer cm.RUnlock()
+
+	if len(ifaces) == 0 {
+		for iface := range cm.lastAppliedConfig {
+			ifaces = append(ifaces, iface)
+		}
+	}
+	for _, iface := range ifaces {
+		if _, configured := cm.lastAppliedConfig[iface]; !configured {
+			continue
+		}
+		if _, running := cm.captures.Get(iface); !running {
+			missing = append(missing, iface)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
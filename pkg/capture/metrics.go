@@ -1,8 +1,8 @@
 package capture
 
 import (
-	"github.com/els0r/goProbe/cmd/goProbe/config"
-	"github.com/prometheus/client_golang/prometheus"
+	"github.com/els0r/goProbe/pkg/defaults"
+	"github.com/els0r/goProbe/pkg/metrics"
 )
 
 const (
@@ -10,47 +10,45 @@ const (
 	captureManagerSubsystem = "capture_manager"
 )
 
-var packetsProcessed = prometheus.NewCounter(prometheus.CounterOpts{
-	Namespace: config.ServiceName,
+var packetsProcessed = metrics.NewCounter(metrics.Opts{
+	Namespace: defaults.ServiceName,
 	Subsystem: captureSubsystem,
 	Name:      "packets_processed_total",
 	Help:      "Number of packets processed, aggregated over all interfaces",
 })
-var packetsDropped = prometheus.NewCounter(prometheus.CounterOpts{
-	Namespace: config.ServiceName,
+var packetsDropped = metrics.NewCounter(metrics.Opts{
+	Namespace: defaults.ServiceName,
 	Subsystem: captureSubsystem,
 	Name:      "packets_dropped_total",
 	Help:      "Number of packets dropped, aggregated over all interfaces",
 })
-var captureErrors = prometheus.NewCounter(prometheus.CounterOpts{
-	Namespace: config.ServiceName,
+var packetsExcluded = metrics.NewCounter(metrics.Opts{
+	Namespace: defaults.ServiceName,
+	Subsystem: captureSubsystem,
+	Name:      "packets_excluded_total",
+	Help:      "Number of packets dropped due to exclusion rules, aggregated over all interfaces",
+})
+var captureErrors = metrics.NewCounter(metrics.Opts{
+	Namespace: defaults.ServiceName,
 	Subsystem: captureSubsystem,
 	Name:      "errors_total",
 	Help:      "Number of errors encountered during packet capture, aggregated over all interfaces",
 })
 
-var interfacesCapturing = prometheus.NewGauge(prometheus.GaugeOpts{
-	Namespace: config.ServiceName,
+var interfacesCapturing = metrics.NewGauge(metrics.Opts{
+	Namespace: defaults.ServiceName,
 	Subsystem: captureManagerSubsystem,
 	Name:      "interfaces_capturing_total",
 	Help:      "Number of interfaces that are actively capturing traffic",
 })
 
-var rotationDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
-	Namespace: config.ServiceName,
-	Subsystem: captureManagerSubsystem,
-	Name:      "rotation_duration_seconds",
-	Help:      "Total flow map rotation time, aggregated across all interfaces",
+var rotationDuration = metrics.NewHistogram(metrics.HistogramOpts{
+	Opts: metrics.Opts{
+		Namespace: defaults.ServiceName,
+		Subsystem: captureManagerSubsystem,
+		Name:      "rotation_duration_seconds",
+		Help:      "Total flow map rotation time, aggregated across all interfaces",
+	},
 	// rotation is significantly faster than the writeout. Hence the small buckets
 	Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 0.75, 1},
 })
-
-func init() {
-	prometheus.MustRegister(
-		packetsProcessed,
-		packetsDropped,
-		captureErrors,
-		interfacesCapturing,
-		rotationDuration,
-	)
-}
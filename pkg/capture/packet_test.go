@@ -6,6 +6,7 @@ import (
 	"net/netip"
 	"testing"
 
+	"github.com/els0r/goProbe/cmd/goProbe/config"
 	"github.com/els0r/goProbe/pkg/capture/capturetypes"
 	"github.com/fako1024/slimcap/capture"
 	"github.com/stretchr/testify/require"
@@ -198,3 +199,38 @@ func uint16ToPort(p uint16) (res []byte) {
 	binary.BigEndian.PutUint16(res, p)
 	return
 }
+
+func TestExclusionFilter(t *testing.T) {
+	filter, err := newExclusionFilter([]config.ExclusionRule{
+		{Net: "10.20.0.0/16"},
+		{Port: 873, Proto: "tcp"},
+		{Net: "2001:db8::1", Port: 3260},
+	})
+	require.Nil(t, err)
+
+	for _, c := range []struct {
+		params   testParams
+		excluded bool
+	}{
+		{testParams{sip: "10.20.1.1", dip: "4.5.6.7", sport: 33561, dport: 444, proto: capturetypes.UDP}, true},
+		{testParams{sip: "4.5.6.7", dip: "10.20.255.1", sport: 0, dport: 443, proto: capturetypes.TCP}, true},
+		{testParams{sip: "10.21.1.1", dip: "4.5.6.7", sport: 33561, dport: 444, proto: capturetypes.UDP}, false},
+		{testParams{sip: "10.0.0.1", dip: "10.0.0.2", sport: 37485, dport: 873, proto: capturetypes.TCP}, true},
+		{testParams{sip: "10.0.0.2", dip: "10.0.0.1", sport: 873, dport: 37485, proto: capturetypes.TCP}, true},
+		{testParams{sip: "10.0.0.1", dip: "10.0.0.2", sport: 37485, dport: 873, proto: capturetypes.UDP}, false},
+		{testParams{sip: "2001:db8::2", dip: "2001:db8::1", sport: 37485, dport: 3260, proto: capturetypes.TCP}, true},
+		{testParams{sip: "2001:db8::2", dip: "2001:db8::3", sport: 37485, dport: 3260, proto: capturetypes.TCP}, false},
+		{testParams{sip: "2001:db8::2", dip: "2001:db8::1", sport: 37485, dport: 3261, proto: capturetypes.TCP}, false},
+	} {
+		t.Run(c.params.String(), func(t *testing.T) {
+			testPacket := c.params.genDummyPacket(0)
+			epHash, isIPv4, _, errno := ParsePacket(testPacket.IPLayer())
+			require.Equal(t, capturetypes.ErrnoOK, errno)
+			require.Equal(t, c.excluded, filter.excludes(&epHash, isIPv4))
+		})
+	}
+
+	filter, err = newExclusionFilter(nil)
+	require.Nil(t, err)
+	require.Nil(t, filter)
+}
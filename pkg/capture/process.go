@@ -0,0 +1,371 @@
+package capture
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/telemetry/logging"
+)
+
+const (
+	// tcpStateListen denotes the state of a listening TCP socket in /proc/net/tcp(6)
+	tcpStateListen = 0x0A
+
+	socketLinkPrefix = "socket:["
+)
+
+// ProcessAttributor attributes flows to the local processes owning their sockets. Attribution is
+// performed upon each rotation, hence it covers the connections present at that time (as well as the
+// sockets processes are listening on). An attributor could hence e.g. also be implemented by means of
+// eBPF socket tracing (catching short-lived connections as well)
+type ProcessAttributor interface {
+
+	// Snapshot captures the sockets currently owned by local processes. The returned labeler provides
+	// the label of the process a flow is attributed to (empty if it can't be attributed)
+	Snapshot(ctx context.Context) (capturetypes.FlowLabeler, error)
+}
+
+// socketKey identifies a socket of a local process, as far as it can be matched against a flow (which
+// doesn't retain the source port)
+type socketKey struct {
+	proto  byte
+	local  netip.Addr
+	remote netip.Addr // remote IP of a connection (unset for listening sockets)
+	port   uint16     // remote port of a connection / local port of a listening socket
+}
+
+// ProcessSockets maps the sockets of local processes to the labels of the processes
+type ProcessSockets struct {
+	sockets    map[socketKey]string
+	localAddrs map[netip.Addr]struct{}
+}
+
+// NewProcessSockets creates a new (empty) set of sockets of the processes running on a host with the
+// given local IPs (which are required to attribute flows to processes listening on any IP)
+func NewProcessSockets(localAddrs ...netip.Addr) *ProcessSockets {
+	p := &ProcessSockets{
+		sockets:    make(map[socketKey]string),
+		localAddrs: make(map[netip.Addr]struct{}, len(localAddrs)),
+	}
+	for _, addr := range localAddrs {
+		p.localAddrs[addr.Unmap()] = struct{}{}
+	}
+	return p
+}
+
+// Add adds a socket of a process. Listening sockets are matched by their local IP and port, connections
+// by their local IP, remote IP and remote port. If a socket is shared by multiple processes, the lowest
+// label is retained (keeping the attribution deterministic)
+func (p *ProcessSockets) Add(proto byte, local, remote netip.AddrPort, listening bool, label string) {
+	key := socketKey{
+		proto: proto,
+		local: local.Addr().Unmap(),
+		port:  local.Port(),
+	}
+	if !listening {
+		key.remote, key.port = remote.Addr().Unmap(), remote.Port()
+	}
+	if existing, exists := p.sockets[key]; !exists || label < existing {
+		p.sockets[key] = label
+	}
+}
+
+// Label returns the label of the process a flow is attributed to, i.e. the process which either
+// connected to the destination of the flow (from its source IP) or listens on it. If none of the
+// processes matches, an empty string is returned
+func (p *ProcessSockets) Label(key types.Key) string {
+	proto := key.GetProto()
+	if proto != protoTCP && proto != protoUDP {
+		return ""
+	}
+	sip, dip := types.RawIPToAddr(key.GetSIP()).Unmap(), types.RawIPToAddr(key.GetDIP()).Unmap()
+	dport := types.PortToUint16(key.GetDport())
+
+	// the flow originates from a local process
+	if label, exists := p.sockets[socketKey{proto: proto, local: sip, remote: dip, port: dport}]; exists {
+		return label
+	}
+
+	// the flow is destined to a process listening on the destination IP or on any (local) IP
+	if label, exists := p.sockets[socketKey{proto: proto, local: dip, port: dport}]; exists {
+		return label
+	}
+	if _, isLocal := p.localAddrs[dip]; !isLocal {
+		return ""
+	}
+	if dip.Is4() {
+		if label, exists := p.sockets[socketKey{proto: proto, local: netip.IPv4Unspecified(), port: dport}]; exists {
+			return label
+		}
+	}
+	return p.sockets[socketKey{proto: proto, local: netip.IPv6Unspecified(), port: dport}]
+}
+
+// ProcfsAttributor attributes flows to local processes by means of the socket tables and the file
+// descriptors exposed by the proc filesystem. It is limited to the network namespace goProbe runs
+// in, and reading the file descriptors of other users' processes requires the respective privileges
+// (e.g. CAP_SYS_PTRACE)
+type ProcfsAttributor struct {
+	root string
+}
+
+// NewProcfsAttributor creates a new attributor reading the proc filesystem mounted at root
+func NewProcfsAttributor(root string) *ProcfsAttributor {
+	return &ProcfsAttributor{root: root}
+}
+
+// Snapshot captures the sockets currently owned by local processes (see ProcessAttributor)
+func (p *ProcfsAttributor) Snapshot(ctx context.Context) (capturetypes.FlowLabeler, error) {
+	owners, err := p.socketOwners(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sockets := NewProcessSockets(localAddrs(ctx)...)
+	for _, table := range []struct {
+		name  string
+		proto byte
+	}{
+		{"tcp", protoTCP}, {"tcp6", protoTCP},
+		{"udp", protoUDP}, {"udp6", protoUDP},
+	} {
+		f, err := os.Open(filepath.Join(p.root, "net", table.name))
+		if err != nil {
+			// the IPv6 tables are missing if IPv6 is disabled
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		procSockets, err := parseProcNetSockets(f, table.proto)
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse socket table `%s`: %w", table.name, err)
+		}
+		for _, s := range procSockets {
+			if label, exists := owners[s.inode]; exists {
+				sockets.Add(table.proto, s.local, s.remote, s.listening, label)
+			}
+		}
+	}
+
+	return sockets.Label, nil
+}
+
+// socketOwners returns the labels of the processes owning the sockets (by inode). Processes whose file
+// descriptors can't be read (e.g. due to missing privileges or having terminated) are skipped
+func (p *ProcfsAttributor) socketOwners(ctx context.Context) (map[uint64]string, error) {
+	entries, err := os.ReadDir(p.root)
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make(map[uint64]string)
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, err := strconv.Atoi(entry.Name()); err != nil || !entry.IsDir() {
+			continue
+		}
+
+		pidPath := filepath.Join(p.root, entry.Name())
+		fds, err := os.ReadDir(filepath.Join(pidPath, "fd"))
+		if err != nil {
+			continue
+		}
+
+		var label string
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(pidPath, "fd", fd.Name()))
+			if err != nil || !strings.HasPrefix(link, socketLinkPrefix) {
+				continue
+			}
+			inode, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(link, socketLinkPrefix), "]"), 10, 64)
+			if err != nil {
+				continue
+			}
+
+			// the label is only determined for processes owning sockets
+			if label == "" {
+				if label = processLabel(pidPath); label == "" {
+					break
+				}
+			}
+			if existing, exists := owners[inode]; !exists || label < existing {
+				owners[inode] = label
+			}
+		}
+	}
+
+	return owners, nil
+}
+
+// processLabel returns the label of a process, i.e. its systemd unit (if it runs as part of a service)
+// or its command name
+func processLabel(pidPath string) string {
+	if cgroup, err := os.ReadFile(filepath.Join(pidPath, "cgroup")); err == nil {
+		if unit := systemdUnit(string(cgroup)); unit != "" {
+			return unit
+		}
+	}
+	comm, err := os.ReadFile(filepath.Join(pidPath, "comm"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(comm))
+}
+
+// systemdUnit extracts the systemd service from the cgroup membership of a process (e.g.
+// `0::/system.slice/nginx.service`)
+func systemdUnit(cgroup string) string {
+	for _, line := range strings.Split(cgroup, "\n") {
+		path := line[strings.LastIndexByte(line, ':')+1:]
+		elements := strings.Split(path, "/")
+		for i := len(elements) - 1; i >= 0; i-- {
+			if strings.HasSuffix(elements[i], ".service") {
+				return elements[i]
+			}
+		}
+	}
+	return ""
+}
+
+// procNetSocket denotes a socket listed in one of the socket tables of the proc filesystem
+type procNetSocket struct {
+	local, remote netip.AddrPort
+	listening     bool
+	inode         uint64
+}
+
+// parseProcNetSockets parses a socket table of the proc filesystem (/proc/net/{tcp,tcp6,udp,udp6}).
+// UDP sockets without remote endpoint are treated as listening sockets
+func parseProcNetSockets(r io.Reader, proto byte) ([]procNetSocket, error) {
+	var sockets []procNetSocket
+
+	scanner := bufio.NewScanner(r)
+	for line := 0; scanner.Scan(); line++ {
+
+		// skip the header
+		if line == 0 {
+			continue
+		}
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			return nil, fmt.Errorf("invalid number of fields in line %d: %d", line, len(fields))
+		}
+
+		var (
+			s   procNetSocket
+			err error
+		)
+		if s.local, err = parseProcNetAddr(fields[1]); err != nil {
+			return nil, err
+		}
+		if s.remote, err = parseProcNetAddr(fields[2]); err != nil {
+			return nil, err
+		}
+		state, err := strconv.ParseUint(fields[3], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid socket state `%s`: %w", fields[3], err)
+		}
+		if s.inode, err = strconv.ParseUint(fields[9], 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid socket inode `%s`: %w", fields[9], err)
+		}
+
+		// sockets without inode (e.g. in TIME_WAIT state) aren't owned by any process anymore
+		if s.inode == 0 {
+			continue
+		}
+		if proto == protoTCP {
+			s.listening = state == tcpStateListen
+		} else {
+			s.listening = s.remote.Port() == 0
+		}
+		sockets = append(sockets, s)
+	}
+
+	return sockets, scanner.Err()
+}
+
+// parseProcNetAddr parses an IP / port pair of a socket table (e.g. `0100007F:0277`). The IP is
+// printed as a sequence of 32 bit words in host byte order, the port in network byte order
+func parseProcNetAddr(s string) (netip.AddrPort, error) {
+	ipHex, portHex, found := strings.Cut(s, ":")
+	if !found {
+		return netip.AddrPort{}, fmt.Errorf("invalid socket address `%s`", s)
+	}
+	port, err := strconv.ParseUint(portHex, 16, 16)
+	if err != nil {
+		return netip.AddrPort{}, fmt.Errorf("invalid socket port `%s`: %w", portHex, err)
+	}
+	raw, err := hex.DecodeString(ipHex)
+	if err != nil || (len(raw) != 4 && len(raw) != 16) {
+		return netip.AddrPort{}, fmt.Errorf("invalid socket IP `%s`", ipHex)
+	}
+	for i := 0; i < len(raw); i += 4 {
+		binary.NativeEndian.PutUint32(raw[i:i+4], binary.BigEndian.Uint32(raw[i:i+4]))
+	}
+	ip, _ := netip.AddrFromSlice(raw)
+
+	return netip.AddrPortFrom(ip, uint16(port)), nil
+}
+
+// localAddrs returns the IPs of all local interfaces
+func localAddrs(ctx context.Context) (addrs []netip.Addr) {
+	ifaceAddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		logging.FromContext(ctx).Warnf("failed to get local IPs for process attribution: %v", err)
+		return nil
+	}
+	for _, ifaceAddr := range ifaceAddrs {
+		if prefix, err := netip.ParsePrefix(ifaceAddr.String()); err == nil {
+			addrs = append(addrs, prefix.Addr())
+		}
+	}
+	return addrs
+}
+
+// processAttribution captures the sockets of local processes if process attribution is enabled for any
+// of the interfaces. If it isn't (or the sockets can't be captured), nil is returned
+func (cm *Manager) processAttribution(ctx context.Context, ifaces []string) capturetypes.FlowLabeler {
+	enabled := false
+	for _, iface := range ifaces {
+		if mc, exists := cm.captures.Get(iface); exists && mc.config.ProcessAttribution {
+			enabled = true
+			break
+		}
+	}
+	if !enabled {
+		return nil
+	}
+
+	logger := logging.FromContext(ctx)
+	if cm.processAttributor == nil {
+		logger.Warn("process attribution is not supported on this platform")
+		return nil
+	}
+	labeler, err := cm.processAttributor.Snapshot(ctx)
+	if err != nil {
+		logger.Warnf("failed to capture the sockets of local processes, flows are not attributed: %v", err)
+		return nil
+	}
+	return labeler
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package capture
+
+// Process attribution relies on the proc filesystem, which is only available on Linux
+func newDefaultProcessAttributor() ProcessAttributor {
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build linux
+// +build linux
+
+package capture
+
+const procRoot = "/proc"
+
+func newDefaultProcessAttributor() ProcessAttributor {
+	return NewProcfsAttributor(procRoot)
+}
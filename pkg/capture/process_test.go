@@ -0,0 +1,149 @@
+package capture
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+// procNetAddr formats an IP / port pair the way it is listed in the socket tables of the proc filesystem
+func procNetAddr(addr string, port uint16) string {
+	raw := netip.MustParseAddr(addr).AsSlice()
+	for i := 0; i < len(raw); i += 4 {
+		binary.BigEndian.PutUint32(raw[i:i+4], binary.NativeEndian.Uint32(raw[i:i+4]))
+	}
+	return fmt.Sprintf("%s:%04X", strings.ToUpper(hex.EncodeToString(raw)), port)
+}
+
+// procNetTable generates a socket table of the proc filesystem from lines of the form
+// `<local> <remote> <state> <inode>`
+func procNetTable(sockets ...[4]string) string {
+	table := "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+	for i, s := range sockets {
+		table += fmt.Sprintf("%4d: %s %s %s 00000000:00000000 00:00000000 00000000     0        0 %s 1 0000000000000000 100 0 0 10 0\n",
+			i, s[0], s[1], s[2], s[3])
+	}
+	return table
+}
+
+func TestParseProcNetSockets(t *testing.T) {
+	sockets, err := parseProcNetSockets(strings.NewReader(procNetTable(
+		[4]string{procNetAddr("0.0.0.0", 80), procNetAddr("0.0.0.0", 0), "0A", "1001"},
+		[4]string{procNetAddr("10.0.0.1", 50000), procNetAddr("10.0.0.2", 443), "01", "1002"},
+		[4]string{procNetAddr("10.0.0.1", 50001), procNetAddr("10.0.0.2", 443), "06", "0"},
+		[4]string{procNetAddr("::ffff:10.0.0.1", 50002), procNetAddr("::ffff:10.0.0.2", 443), "01", "1003"},
+	)), protoTCP)
+	require.Nil(t, err)
+	require.Equal(t, []procNetSocket{
+		{local: netip.MustParseAddrPort("0.0.0.0:80"), remote: netip.MustParseAddrPort("0.0.0.0:0"), listening: true, inode: 1001},
+		{local: netip.MustParseAddrPort("10.0.0.1:50000"), remote: netip.MustParseAddrPort("10.0.0.2:443"), inode: 1002},
+		{local: netip.MustParseAddrPort("[::ffff:10.0.0.1]:50002"), remote: netip.MustParseAddrPort("[::ffff:10.0.0.2]:443"), inode: 1003},
+	}, sockets)
+
+	// unconnected UDP sockets are considered to be listening
+	sockets, err = parseProcNetSockets(strings.NewReader(procNetTable(
+		[4]string{procNetAddr("127.0.0.53", 53), procNetAddr("0.0.0.0", 0), "07", "1004"},
+		[4]string{procNetAddr("10.0.0.1", 40000), procNetAddr("10.0.0.3", 123), "01", "1005"},
+	)), protoUDP)
+	require.Nil(t, err)
+	require.Len(t, sockets, 2)
+	require.True(t, sockets[0].listening)
+	require.False(t, sockets[1].listening)
+
+	_, err = parseProcNetSockets(strings.NewReader(procNetTable(
+		[4]string{"0100007F", procNetAddr("0.0.0.0", 0), "0A", "1001"},
+	)), protoTCP)
+	require.NotNil(t, err)
+}
+
+func TestSystemdUnit(t *testing.T) {
+	require.Equal(t, "nginx.service", systemdUnit("0::/system.slice/nginx.service\n"))
+	require.Equal(t, "docker.service", systemdUnit("12:pids:/system.slice/docker.service\n0::/system.slice/docker.service/init.scope\n"))
+	require.Equal(t, "", systemdUnit("0::/user.slice/user-1000.slice/session-2.scope\n"))
+	require.Equal(t, "", systemdUnit(""))
+}
+
+func TestProcessSockets(t *testing.T) {
+	sockets := NewProcessSockets(netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("fe80::1"))
+	sockets.Add(protoTCP, netip.MustParseAddrPort("0.0.0.0:80"), netip.AddrPort{}, true, "nginx.service")
+	sockets.Add(protoTCP, netip.MustParseAddrPort("[::]:8080"), netip.AddrPort{}, true, "app")
+	sockets.Add(protoUDP, netip.MustParseAddrPort("127.0.0.53:53"), netip.AddrPort{}, true, "systemd-resolved.service")
+	sockets.Add(protoTCP, netip.MustParseAddrPort("10.0.0.1:50000"), netip.MustParseAddrPort("10.0.0.2:443"), false, "curl")
+	sockets.Add(protoTCP, netip.MustParseAddrPort("10.0.0.1:50001"), netip.MustParseAddrPort("10.0.0.2:443"), false, "apt")
+
+	for _, test := range []struct {
+		sip, dip string
+		dport    uint16
+		proto    byte
+		expected string
+	}{
+		{"10.0.0.2", "10.0.0.1", 80, protoTCP, "nginx.service"},
+		{"10.0.0.2", "10.0.0.9", 80, protoTCP, ""}, // not a local IP (e.g. forwarded traffic)
+		{"10.0.0.2", "10.0.0.1", 8080, protoTCP, "app"},
+		{"fe80::2", "fe80::1", 8080, protoTCP, "app"},
+		{"10.0.0.2", "10.0.0.1", 8080, protoUDP, ""},
+		{"127.0.0.1", "127.0.0.53", 53, protoUDP, "systemd-resolved.service"},
+		{"10.0.0.1", "10.0.0.2", 443, protoTCP, "apt"}, // lowest label of multiple connections
+		{"10.0.0.1", "10.0.0.3", 443, protoTCP, ""},
+		{"10.0.0.2", "10.0.0.1", 0, 1, ""},
+	} {
+		t.Run(fmt.Sprintf("%s->%s:%d/%d", test.sip, test.dip, test.dport, test.proto), func(t *testing.T) {
+			key := types.NewKey(netip.MustParseAddr(test.sip).AsSlice(), netip.MustParseAddr(test.dip).AsSlice(),
+				[]byte{byte(test.dport >> 8), byte(test.dport)}, test.proto)
+			require.Equal(t, test.expected, sockets.Label(key))
+		})
+	}
+}
+
+func TestProcfsAttributor(t *testing.T) {
+	root := t.TempDir()
+
+	writeFile := func(path, content string) {
+		require.Nil(t, os.MkdirAll(filepath.Dir(filepath.Join(root, path)), 0750))
+		require.Nil(t, os.WriteFile(filepath.Join(root, path), []byte(content), 0600))
+	}
+	addProcess := func(pid int, cgroup, comm string, links ...string) {
+		writeFile(fmt.Sprintf("%d/cgroup", pid), cgroup)
+		writeFile(fmt.Sprintf("%d/comm", pid), comm+"\n")
+		require.Nil(t, os.MkdirAll(filepath.Join(root, fmt.Sprint(pid), "fd"), 0750))
+		for fd, link := range links {
+			require.Nil(t, os.Symlink(link, filepath.Join(root, fmt.Sprint(pid), "fd", fmt.Sprint(fd))))
+		}
+	}
+
+	addProcess(1234, "0::/system.slice/nginx.service\n", "nginx", "/dev/null", "socket:[1001]")
+	addProcess(2345, "0::/user.slice/user-1000.slice/session-2.scope\n", "curl", "socket:[1002]", "pipe:[42]")
+	addProcess(3456, "0::/system.slice/postgresql.service\n", "postgres", "/var/lib/postgresql/data")
+	writeFile("net/tcp", procNetTable(
+		[4]string{procNetAddr("127.0.0.1", 80), procNetAddr("0.0.0.0", 0), "0A", "1001"},
+		[4]string{procNetAddr("127.0.0.1", 50000), procNetAddr("127.0.0.2", 443), "01", "1002"},
+		[4]string{procNetAddr("127.0.0.1", 5432), procNetAddr("0.0.0.0", 0), "0A", "1003"},
+	))
+	writeFile("net/udp", procNetTable())
+
+	labeler, err := NewProcfsAttributor(root).Snapshot(context.Background())
+	require.Nil(t, err)
+
+	for _, test := range []struct {
+		key      types.Key
+		expected string
+	}{
+		{types.NewV4KeyStatic([4]byte{127, 0, 0, 3}, [4]byte{127, 0, 0, 1}, []byte{0, 80}, protoTCP), "nginx.service"},
+		{types.NewV4KeyStatic([4]byte{127, 0, 0, 1}, [4]byte{127, 0, 0, 2}, []byte{1, 187}, protoTCP), "curl"},
+		{types.NewV4KeyStatic([4]byte{127, 0, 0, 3}, [4]byte{127, 0, 0, 1}, []byte{0x15, 0x38}, protoTCP), ""},
+	} {
+		require.Equal(t, test.expected, labeler(test.key))
+	}
+
+	_, err = NewProcfsAttributor(filepath.Join(root, "missing")).Snapshot(context.Background())
+	require.NotNil(t, err)
+}
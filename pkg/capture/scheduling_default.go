@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package capture
+
+import (
+	"errors"
+
+	"github.com/els0r/goProbe/cmd/goProbe/config"
+)
+
+// Setting the CPU affinity / niceness of individual threads is only supported on Linux
+func applyScheduling(_ config.SchedulingConfig) error {
+	return errors.New("capture scheduling is only supported on Linux")
+}
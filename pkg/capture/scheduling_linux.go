@@ -0,0 +1,33 @@
+//go:build linux
+// +build linux
+
+package capture
+
+import (
+	"fmt"
+
+	"github.com/els0r/goProbe/cmd/goProbe/config"
+	"golang.org/x/sys/unix"
+)
+
+// applyScheduling pins the calling thread to the configured CPUs and sets its niceness. The caller
+// must be locked to its OS thread (see runtime.LockOSThread())
+func applyScheduling(cfg config.SchedulingConfig) error {
+	if len(cfg.CPUs) > 0 {
+		var set unix.CPUSet
+		for _, cpu := range cfg.CPUs {
+			set.Set(cpu)
+		}
+		if err := unix.SchedSetaffinity(0, &set); err != nil {
+			return fmt.Errorf("failed to pin capture to CPUs %v: %w", cfg.CPUs, err)
+		}
+	}
+
+	// on Linux, the niceness of a thread (rather than the whole process) is set via its thread ID
+	if cfg.Nice != 0 {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, unix.Gettid(), cfg.Nice); err != nil {
+			return fmt.Errorf("failed to set niceness of capture to %d: %w", cfg.Nice, err)
+		}
+	}
+	return nil
+}
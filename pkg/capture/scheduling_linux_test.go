@@ -0,0 +1,53 @@
+//go:build linux
+// +build linux
+
+package capture
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/els0r/goProbe/cmd/goProbe/config"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestApplyScheduling(t *testing.T) {
+	var available unix.CPUSet
+	require.Nil(t, unix.SchedGetaffinity(0, &available))
+	cpu := 0
+	for !available.IsSet(cpu) {
+		cpu++
+	}
+
+	// the niceness can only be increased without privileges (the raw getpriority syscall returns
+	// 20 - niceness)
+	prio, err := unix.Getpriority(unix.PRIO_PROCESS, 0)
+	require.Nil(t, err)
+	nice := min(20-prio+1, 19)
+
+	// the scheduling is applied to a dedicated thread, which is terminated along with the goroutine
+	type result struct {
+		set  unix.CPUSet
+		prio int
+		err  error
+	}
+	resChan := make(chan result)
+	go func() {
+		runtime.LockOSThread()
+
+		var res result
+		if res.err = applyScheduling(config.SchedulingConfig{CPUs: []int{cpu}, Nice: nice}); res.err == nil {
+			if res.err = unix.SchedGetaffinity(0, &res.set); res.err == nil {
+				res.prio, res.err = unix.Getpriority(unix.PRIO_PROCESS, unix.Gettid())
+			}
+		}
+		resChan <- res
+	}()
+
+	res := <-resChan
+	require.Nil(t, res.err)
+	require.Equal(t, 1, res.set.Count())
+	require.True(t, res.set.IsSet(cpu))
+	require.Equal(t, 20-nice, res.prio)
+}
@@ -0,0 +1,292 @@
+package capture
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/els0r/goProbe/cmd/goProbe/config"
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/goProbe/pkg/types/hashmap"
+	"github.com/els0r/telemetry/logging"
+)
+
+const (
+	protoTCP = 6
+	protoUDP = 17
+
+	// selfTrafficResolveTimeout denotes the time allotted to resolving the hostnames of the endpoints
+	// goProbe connects to
+	selfTrafficResolveTimeout = 5 * time.Second
+)
+
+// selfAddr denotes an IPv4 or IPv6 address usable as map key
+type selfAddr struct {
+	ip    [16]byte
+	ipLen uint8
+}
+
+func newSelfAddr(ip []byte) (addr selfAddr) {
+	addr.ipLen = uint8(copy(addr.ip[:], ip))
+	return
+}
+
+// selfEndpoint denotes a destination (IP, port and protocol) of traffic caused by goProbe itself
+type selfEndpoint struct {
+	selfAddr
+	dport [2]byte
+	proto byte
+}
+
+func newSelfEndpoint(ip []byte, dport []byte, proto byte) (ep selfEndpoint) {
+	ep.selfAddr = newSelfAddr(ip)
+	copy(ep.dport[:], dport)
+	ep.proto = proto
+	return
+}
+
+// SelfTraffic identifies the flows caused by goProbe itself, i.e. the flows towards its API and the
+// connections it establishes (e.g. to stream the flows or export metrics). Since the source port of a
+// flow is not retained upon aggregation, a flow is attributed to goProbe if its destination IP, port
+// and protocol match one of the endpoints. For the connections goProbe establishes, the source of the
+// flow must be one of the local addresses in addition, so that traffic of other hosts towards the same
+// endpoint isn't attributed to goProbe
+type SelfTraffic struct {
+	endpoints map[selfEndpoint]bool // denotes if the source must be a local address
+	localIPs  map[selfAddr]struct{}
+
+	// iface denotes the pseudo-interface goProbe's own flows are recorded in (if empty, they
+	// are discarded)
+	iface string
+}
+
+// NewSelfTraffic creates a new (empty) set of endpoints of goProbe's own traffic. If iface is
+// set, the traffic is recorded in the pseudo-interface of that name, otherwise it is discarded
+func NewSelfTraffic(iface string) *SelfTraffic {
+	return &SelfTraffic{
+		endpoints: make(map[selfEndpoint]bool),
+		localIPs:  make(map[selfAddr]struct{}),
+		iface:     iface,
+	}
+}
+
+// AddEndpoint attributes all traffic towards ip / port using the given IP protocol to goProbe. It is
+// meant for the local endpoints goProbe listens on (e.g. its API), whose port is owned by goProbe
+func (s *SelfTraffic) AddEndpoint(ip net.IP, port uint16, proto byte) *SelfTraffic {
+	s.addEndpoint(ip, port, proto, false)
+	return s
+}
+
+// AddRemoteEndpoint attributes the traffic towards ip / port using the given IP protocol to goProbe
+// if it originates from one of the local addresses (see WithLocalIPs()). It is meant for the endpoints
+// goProbe connects to
+func (s *SelfTraffic) AddRemoteEndpoint(ip net.IP, port uint16, proto byte) *SelfTraffic {
+	s.addEndpoint(ip, port, proto, true)
+	return s
+}
+
+// WithLocalIPs sets the local addresses the connections established by goProbe originate from
+func (s *SelfTraffic) WithLocalIPs(ips ...net.IP) *SelfTraffic {
+	for _, ip := range ips {
+		s.localIPs[newSelfAddr(normalizeIP(ip))] = struct{}{}
+	}
+	return s
+}
+
+func (s *SelfTraffic) addEndpoint(ip net.IP, port uint16, proto byte, requireLocalSource bool) {
+	ep := newSelfEndpoint(normalizeIP(ip), []byte{byte(port >> 8), byte(port)}, proto)
+
+	// a local endpoint takes precedence if it's also a remote one
+	if local, exists := s.endpoints[ep]; exists && !local {
+		return
+	}
+	s.endpoints[ep] = requireLocalSource
+}
+
+func normalizeIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
+
+// Len returns the number of endpoints
+func (s *SelfTraffic) Len() int {
+	return len(s.endpoints)
+}
+
+func (s *SelfTraffic) isOwn(key types.Key) bool {
+	return s.matches(key.GetSIP(), key.GetDIP(), key.GetDport(), key.GetProto())
+}
+
+func (s *SelfTraffic) matches(sip, dip []byte, dport []byte, proto byte) bool {
+	requireLocalSource, exists := s.endpoints[newSelfEndpoint(dip, dport, proto)]
+	if !exists || !requireLocalSource {
+		return exists
+	}
+	_, isLocal := s.localIPs[newSelfAddr(sip)]
+	return isLocal
+}
+
+// split separates goProbe's own flows from the flows of the flow map. If the flow map doesn't
+// contain any, own is nil and the flow map is returned as is
+func (s *SelfTraffic) split(flowMap *hashmap.AggFlowMap) (own, others *hashmap.AggFlowMap) {
+	if flowMap == nil || flowMap.IsNil() || len(s.endpoints) == 0 {
+		return nil, flowMap
+	}
+
+	// most of the time, the bulk of the flows is not caused by goProbe, hence the flow map is
+	// only copied if necessary
+	numOwn := 0
+	for it := flowMap.Iter(); it.Next(); {
+		if s.isOwn(it.Key()) {
+			numOwn++
+		}
+	}
+	if numOwn == 0 {
+		return nil, flowMap
+	}
+
+	own, others = hashmap.NewAggFlowMap(), hashmap.NewAggFlowMap(flowMap.Len()-numOwn)
+	for it := flowMap.Iter(); it.Next(); {
+		key, val := it.Key(), it.Val()
+		target := others
+		if s.isOwn(key) {
+			target = own
+		}
+		target.SetOrUpdate(key, types.Key(key).IsIPv4(), val.BytesRcvd, val.BytesSent, val.PacketsRcvd, val.PacketsSent)
+	}
+	return own, others
+}
+
+// filterSamples removes goProbe's own flows from a sample of individual flows (in place)
+func (s *SelfTraffic) filterSamples(samples []results.ExtendedRow) []results.ExtendedRow {
+	if len(s.endpoints) == 0 {
+		return samples
+	}
+	return slices.DeleteFunc(samples, func(row results.ExtendedRow) bool {
+		attr := row.Attributes.Attributes
+		return s.matches(attr.SrcIP.AsSlice(), attr.DstIP.AsSlice(), []byte{byte(attr.DstPort >> 8), byte(attr.DstPort)}, attr.IPProto)
+	})
+}
+
+// newSelfTrafficFromConfig determines the endpoints of goProbe's own traffic from its configuration:
+// the API (on all local addresses if it listens on all of them) and the message bus, webhooks, tracing /
+// metrics collectors and syslog daemon it connects to (from any of the local addresses). Endpoints
+// which can't be resolved are skipped
+func newSelfTrafficFromConfig(ctx context.Context, cfg *config.Config) *SelfTraffic {
+	logger := logging.FromContext(ctx)
+
+	s := NewSelfTraffic(cfg.SelfMonitoring.PseudoIface())
+	if ips, err := localIPs(); err == nil {
+		s.WithLocalIPs(ips...)
+	} else {
+		logger.Warnf("failed to determine local addresses, own connections can't be identified: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, selfTrafficResolveTimeout)
+	defer cancel()
+
+	add := func(addr, defaultPort string, proto byte, local bool) {
+		ips, port, err := resolveEndpoint(ctx, addr, defaultPort, local)
+		if err != nil {
+			logger.With("addr", addr).Warnf("failed to determine endpoint of own traffic: %v", err)
+			return
+		}
+		for _, ip := range ips {
+			if local {
+				s.AddEndpoint(ip, port, proto)
+			} else {
+				s.AddRemoteEndpoint(ip, port, proto)
+			}
+		}
+	}
+
+	// a unix socket doesn't cause any network traffic
+	if cfg.API != nil && !strings.HasPrefix(cfg.API.Addr, "unix:") {
+		add(cfg.API.Addr, "", protoTCP, true)
+	}
+	if cfg.Stream != nil && cfg.Stream.Type == config.StreamTypeNATS {
+		add(strings.TrimPrefix(cfg.Stream.Address, "nats://"), "4222", protoTCP, false)
+	}
+	for _, hook := range cfg.DB.WriteoutHooks {
+		if u, err := url.Parse(hook.URL); err == nil && u.Host != "" {
+			defaultPort := "80"
+			if u.Scheme == "https" {
+				defaultPort = "443"
+			}
+			add(u.Host, defaultPort, protoTCP, false)
+		}
+	}
+	if cfg.Tracing != nil {
+		add(cfg.Tracing.Endpoint, "4318", protoTCP, false)
+	}
+	if cfg.Metrics != nil && cfg.Metrics.OTLP != nil {
+		add(cfg.Metrics.OTLP.Endpoint, "4318", protoTCP, false)
+	}
+	if syslog := cfg.Logging.Syslog; syslog != nil && syslog.Address != "" {
+		switch syslog.Network {
+		case "udp":
+			add(syslog.Address, "514", protoUDP, false)
+		case "tcp":
+			add(syslog.Address, "514", protoTCP, false)
+		}
+	}
+	return s
+}
+
+// resolveEndpoint determines the IPs and port of an address of the form [user[:password]@]host[:port].
+// If local is set, an empty or unspecified host denotes all addresses of the local interfaces
+func resolveEndpoint(ctx context.Context, addr, defaultPort string, local bool) ([]net.IP, uint16, error) {
+	if i := strings.LastIndex(addr, "@"); i >= 0 {
+		addr = addr[i+1:]
+	}
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		if defaultPort == "" {
+			return nil, 0, err
+		}
+		host, portStr = strings.Trim(addr, "[]"), defaultPort
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if ip := net.ParseIP(host); ip != nil && !ip.IsUnspecified() {
+		return []net.IP{ip}, uint16(port), nil
+	}
+	if local && (host == "" || net.ParseIP(host) != nil) {
+		ips, err := localIPs()
+		return ips, uint16(port), err
+	}
+
+	ipAddrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, 0, err
+	}
+	ips := make([]net.IP, 0, len(ipAddrs))
+	for _, ipAddr := range ipAddrs {
+		ips = append(ips, ipAddr.IP)
+	}
+	return ips, uint16(port), nil
+}
+
+func localIPs() ([]net.IP, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			ips = append(ips, ipNet.IP)
+		}
+	}
+	return ips, nil
+}
@@ -4,6 +4,10 @@ import "time"
 
 const (
 
+	// ServiceName denotes the name of goProbe as it shows up in telemetry (e.g. as the namespace
+	// of its metrics)
+	ServiceName = "goprobe"
+
 	// DBPath denotes the default path on disk where the DB resides
 	DBPath = "/usr/local/goProbe/db"
 
@@ -15,8 +15,10 @@ import (
 
 	"github.com/els0r/goProbe/cmd/goProbe/config"
 	"github.com/els0r/goProbe/pkg/capture"
+	"github.com/els0r/goProbe/pkg/e2etest/loadgen"
 	"github.com/els0r/goProbe/pkg/goDB"
 	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/els0r/goProbe/pkg/goDB/info"
 	"github.com/els0r/goProbe/pkg/goprobe/writeout"
 	"github.com/fako1024/slimcap/capture/afpacket/afring"
 	"github.com/fako1024/slimcap/link"
@@ -90,6 +92,70 @@ func runBenchmarkCaptureThroughput(t *testing.T, runtime time.Duration, randomiz
 	cancel()
 }
 
+func TestBenchmarkSyntheticLoad(t *testing.T) {
+
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	for _, test := range []struct {
+		name string
+		cfg  loadgen.Config
+	}{
+		{"low-cardinality", loadgen.Config{NumFlows: 16, Bidirectional: true, PacketSize: 128}},
+		{"high-cardinality", loadgen.Config{NumFlows: 100000, IPv6Fraction: 0.2, Bidirectional: true, PacketSize: 128}},
+		{"rate-limited", loadgen.Config{NumFlows: 100000, IPv6Fraction: 0.5, Rate: 200000, Bidirectional: true, PacketSize: 512}},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			runBenchmarkSyntheticLoad(t, 5*time.Second, time.Second, test.cfg)
+		})
+	}
+}
+
+// runBenchmarkSyntheticLoad runs synthetic traffic through capture, rotation and writeout
+// (the latter being triggered in the provided interval)
+func runBenchmarkSyntheticLoad(t *testing.T, runtime, writeoutInterval time.Duration, cfg loadgen.Config) {
+
+	tempDir := t.TempDir()
+
+	writeoutHandler := writeout.NewGoDBHandler(tempDir, encoders.EncoderTypeLZ4).
+		WithPermissions(goDB.DefaultPermissions)
+
+	stats := new(loadgen.Stats)
+	initFn, err := loadgen.SourceInitFn(cfg, stats)
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), runtime)
+	defer cancel()
+
+	captureManager := capture.NewManager(writeoutHandler, capture.WithSourceInitFn(initFn))
+	_, _, _, err = captureManager.Update(ctx, config.Ifaces{
+		"mock": defaultCaptureConfig,
+	})
+	require.Nil(t, err)
+	captureManager.ScheduleWriteouts(ctx, writeoutInterval)
+
+	<-ctx.Done()
+
+	ifaceStats := captureManager.Status(context.Background(), "mock")["mock"]
+
+	shutDownCtx, shutDownCancel := context.WithTimeout(context.Background(), 3*time.Second)
+	captureManager.Close(shutDownCtx)
+	shutDownCancel()
+
+	fmt.Printf("%d flows (%.0f%% IPv6, rate %d/s): %d packets processed after %v (%v/pkt), %d dropped, %d generated, %d skipped\n",
+		cfg.NumFlows, 100*cfg.IPv6Fraction, cfg.Rate, ifaceStats.ProcessedTotal, runtime,
+		runtime/time.Duration(ifaceStats.ProcessedTotal+1), ifaceStats.Dropped, stats.Generated(), stats.Skipped())
+
+	require.Greater(t, ifaceStats.ProcessedTotal, uint64(0))
+
+	// data must have been written to the DB
+	ifaces, err := info.GetInterfaces(tempDir)
+	require.Nil(t, err)
+	require.Equal(t, []string{"mock"}, ifaces)
+}
+
 func setupSyntheticUnblockingSource(t testing.TB, randomize, addReturn bool) func(c *capture.Capture) (capture.Source, error) {
 	return func(c *capture.Capture) (capture.Source, error) {
 
@@ -276,6 +276,25 @@ func testE2E(t *testing.T, datasets ...[]byte) {
 	require.Equalf(t, mockIfaces.NProcessed(), resGoQuery.Summary.Totals.PacketsRcvd, "expected: %d, actual %d", mockIfaces.NProcessed(), resGoQuery.Summary.Totals.PacketsRcvd)
 	require.Equalf(t, mockIfaces.NProcessed(), mockIfaces.NRead()-mockIfaces.NErr(), "expected: %d, actual %d - %d", mockIfaces.NProcessed(), mockIfaces.NRead(), mockIfaces.NErr())
 
+	// Resource accounting checks
+	require.NotNil(t, resGoQuery.Summary.Resources)
+	require.NotZero(t, resGoQuery.Summary.Resources.PeakMemory)
+	if resGoQuery.Summary.Totals.PacketsRcvd > 0 {
+		require.NotZero(t, resGoQuery.Summary.Resources.BlocksScanned)
+		require.NotZero(t, resGoQuery.Summary.Resources.BytesDecompressed)
+	}
+
+	// The days / size of the DB on disk cannot be reproduced by the synthetic test
+	for i := range listReference {
+		for _, listed := range resGoQueryList {
+			if listed.Iface == listReference[i].Iface {
+				require.NotZero(t, listed.Days)
+				require.NotZero(t, listed.DiskSize)
+				listReference[i].Days, listReference[i].DiskSize = listed.Days, listed.DiskSize
+			}
+		}
+	}
+
 	// List target consistency check (do not fail yet to show details in the next check)
 	if !reflect.DeepEqual(listReference, resGoQueryList) {
 		t.Errorf("Mismatch on goQuery list target, want %+v, have %+v", listReference, resGoQueryList)
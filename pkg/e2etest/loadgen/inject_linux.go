@@ -0,0 +1,94 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/fako1024/slimcap/link"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86dd
+
+	// injectBatchInterval denotes the interval in which the rate limit is enforced during injection
+	injectBatchInterval = time.Millisecond
+)
+
+// Inject sends packets from the generator on the provided interface via an AF_PACKET socket
+// (requiring CAP_NET_RAW) until ctx is done, e.g. to benchmark a goProbe instance capturing
+// on a dummy or veth interface. If the generator is configured without a rate, packets are
+// sent as fast as possible. The number of packets sent is returned
+func Inject(ctx context.Context, iface string, gen *Generator) (sent uint64, err error) {
+	netIface, err := net.InterfaceByName(iface)
+	if err != nil {
+		return 0, err
+	}
+
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create AF_PACKET socket: %w", err)
+	}
+	defer unix.Close(fd)
+
+	addr := &unix.SockaddrLinklayer{
+		Ifindex: netIface.Index,
+		Halen:   6,
+	}
+	copy(addr.Addr[:], netIface.HardwareAddr)
+	if err := unix.Bind(fd, addr); err != nil {
+		return 0, fmt.Errorf("failed to bind AF_PACKET socket to %s: %w", iface, err)
+	}
+
+	frames := buildFrames(gen, netIface.HardwareAddr)
+
+	rate := float64(gen.Config().Rate)
+	start, pos := time.Now(), 0
+	for {
+		if ctx.Err() != nil {
+			return sent, nil
+		}
+
+		// send as many packets as required to reach the target rate (or a batch in unlimited mode)
+		target := sent + uint64(len(frames))
+		if rate > 0 {
+			target = uint64(time.Since(start).Seconds() * rate)
+		}
+		for ; sent < target; sent++ {
+			if _, err := unix.Write(fd, frames[pos]); err != nil {
+				return sent, fmt.Errorf("failed to send packet on %s: %w", iface, err)
+			}
+			if pos++; pos == len(frames) {
+				pos = 0
+			}
+		}
+		if rate > 0 {
+			time.Sleep(injectBatchInterval)
+		}
+	}
+}
+
+// buildFrames converts all packets of the generator into ethernet frames
+func buildFrames(gen *Generator, srcMAC net.HardwareAddr) [][]byte {
+	frames := make([][]byte, gen.NumPackets())
+	for i := range frames {
+		pkt := gen.Next()
+
+		frame := make([]byte, len(pkt.Payload()))
+		copy(frame, pkt.Payload())
+		copy(frame[6:12], srcMAC)
+
+		etherType := uint16(etherTypeIPv4)
+		if frame[link.IPLayerOffsetEthernet]>>4 == 6 {
+			etherType = etherTypeIPv6
+		}
+		binary.BigEndian.PutUint16(frame[12:14], etherType)
+
+		frames[i] = frame
+	}
+	return frames
+}
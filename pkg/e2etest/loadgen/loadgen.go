@@ -0,0 +1,175 @@
+// Package loadgen provides a synthetic packet / flow generator, which can be used to benchmark
+// capture, rotation and writeout end-to-end, either by feeding a mock capture source or by
+// injecting the packets into a (e.g. dummy / veth) interface
+package loadgen
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+
+	"github.com/fako1024/slimcap/capture"
+)
+
+const (
+	// DefaultNumFlows denotes the default number of distinct flows generated
+	DefaultNumFlows = 1024
+
+	// DefaultPacketSize denotes the default (total) size of generated packets
+	DefaultPacketSize = 128
+
+	protoTCP = 6
+	protoUDP = 17
+
+	minEphemeralPort = 32768
+
+	// maxNumFlows is limited by the IPv4 address range used for the flows (10.0.0.0/8)
+	maxNumFlows = 1 << 24
+)
+
+// tcpPayload provides the remainder of the TCP header (following the ports), with the flags
+// set to PSH+ACK (as would be expected from an established connection)
+var tcpPayload = []byte{0, 0, 0, 0, 0, 0, 0, 0, 0x50, 0x18}
+
+// commonDstPorts is used to obtain realistic destination port distributions
+var commonDstPorts = []uint16{443, 80, 53, 22, 123, 8080, 25, 993}
+
+var (
+	errorInvalidNumFlows     = fmt.Errorf("number of flows must be in (0, %d]", maxNumFlows)
+	errorInvalidIPv6Fraction = errors.New("fraction of IPv6 flows must be in [0, 1]")
+	errorInvalidRate         = errors.New("packet rate must not be negative")
+	errorInvalidPacketSize   = errors.New("packet size must be greater than 0")
+)
+
+// Config denotes the characteristics of the generated traffic
+type Config struct {
+	NumFlows      int     // NumFlows: number of distinct flows (flow cardinality). Example: 100000
+	IPv6Fraction  float64 // IPv6Fraction: fraction of flows using IPv6 addresses. Example: 0.2
+	Rate          int     // Rate: packets per second, 0 means "as fast as possible". Example: 1000000
+	Bidirectional bool    // Bidirectional: generate a return packet for each packet. Example: true
+	PacketSize    int     // PacketSize: total size of each packet (in bytes). Example: 128
+	Seed          int64   // Seed: seed for the (deterministic) packet order. Example: 42
+}
+
+// DefaultConfig returns a basic traffic configuration
+func DefaultConfig() Config {
+	return Config{
+		NumFlows:      DefaultNumFlows,
+		Bidirectional: true,
+		PacketSize:    DefaultPacketSize,
+	}
+}
+
+func (c Config) validate() error {
+	if c.NumFlows <= 0 || c.NumFlows > maxNumFlows {
+		return errorInvalidNumFlows
+	}
+	if c.IPv6Fraction < 0 || c.IPv6Fraction > 1 {
+		return errorInvalidIPv6Fraction
+	}
+	if c.Rate < 0 {
+		return errorInvalidRate
+	}
+	if c.PacketSize <= 0 {
+		return errorInvalidPacketSize
+	}
+	return nil
+}
+
+// Generator continuously provides packets belonging to a fixed set of synthetic flows
+type Generator struct {
+	cfg Config
+
+	packets []capture.Packet
+	pos     int
+}
+
+// New creates a new generator for the provided traffic configuration. All packets are built
+// upfront, so retrieving them doesn't cause any allocations
+func New(cfg Config) (*Generator, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	rnd := rand.New(rand.NewSource(cfg.Seed)) // #nosec G404
+
+	numIPv6 := int(cfg.IPv6Fraction * float64(cfg.NumFlows))
+	packetsPerFlow := 1
+	if cfg.Bidirectional {
+		packetsPerFlow = 2
+	}
+
+	g := &Generator{
+		cfg:     cfg,
+		packets: make([]capture.Packet, 0, cfg.NumFlows*packetsPerFlow),
+	}
+	for i := 0; i < cfg.NumFlows; i++ {
+		sip, dip := flowIPs(i, i < numIPv6)
+		sport, dport := uint16(minEphemeralPort+i%(65536-minEphemeralPort)), commonDstPorts[i%len(commonDstPorts)]
+		proto, payload := byte(protoTCP), tcpPayload
+		if dport == 53 || dport == 123 {
+			proto, payload = protoUDP, nil
+		}
+
+		pkt, err := capture.BuildPacket(sip, dip, sport, dport, proto, payload, capture.PacketOutgoing, cfg.PacketSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build packet for flow %d: %w", i, err)
+		}
+		g.packets = append(g.packets, pkt)
+
+		if cfg.Bidirectional {
+			pktRet, err := capture.BuildPacket(dip, sip, dport, sport, proto, payload, capture.PacketThisHost, cfg.PacketSize)
+			if err != nil {
+				return nil, fmt.Errorf("failed to build return packet for flow %d: %w", i, err)
+			}
+			g.packets = append(g.packets, pktRet)
+		}
+	}
+
+	// interleave the flows (and the IP versions) instead of emitting them in sequence. The
+	// order is deterministic for a given seed
+	rnd.Shuffle(len(g.packets), func(i, j int) {
+		g.packets[i], g.packets[j] = g.packets[j], g.packets[i]
+	})
+
+	return g, nil
+}
+
+// Config returns the traffic configuration of the generator
+func (g *Generator) Config() Config {
+	return g.cfg
+}
+
+// NumPackets returns the number of distinct packets the generator cycles through
+func (g *Generator) NumPackets() int {
+	return len(g.packets)
+}
+
+// Next returns the next packet. Once all packets have been returned, the generator starts over.
+// The returned packet must not be modified. Next is not safe for concurrent use
+func (g *Generator) Next() capture.Packet {
+	pkt := g.packets[g.pos]
+	if g.pos++; g.pos == len(g.packets) {
+		g.pos = 0
+	}
+	return pkt
+}
+
+// flowIPs derives a unique address pair for the i-th flow
+func flowIPs(i int, isIPv6 bool) (sip, dip net.IP) {
+	if isIPv6 {
+		sip, dip = make(net.IP, net.IPv6len), make(net.IP, net.IPv6len)
+		copy(sip, []byte{0xfd, 0x00})
+		copy(dip, []byte{0xfd, 0x01})
+		binary.BigEndian.PutUint32(sip[12:], uint32(i))
+		binary.BigEndian.PutUint32(dip[12:], uint32(i%256))
+		return
+	}
+
+	sip, dip = net.IPv4(10, 0, 0, 0).To4(), net.IPv4(192, 168, 0, 0).To4()
+	binary.BigEndian.PutUint32(sip, binary.BigEndian.Uint32(sip)|uint32(i&0xffffff))
+	binary.BigEndian.PutUint32(dip, binary.BigEndian.Uint32(dip)|uint32(i%256))
+	return
+}
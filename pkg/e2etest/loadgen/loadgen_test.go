@@ -0,0 +1,104 @@
+package loadgen
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/els0r/goProbe/pkg/capture"
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidation(t *testing.T) {
+	var tests = []struct {
+		name     string
+		modifyFn func(cfg *Config)
+		err      error
+	}{
+		{"default", func(_ *Config) {}, nil},
+		{"no flows", func(cfg *Config) { cfg.NumFlows = 0 }, errorInvalidNumFlows},
+		{"too many flows", func(cfg *Config) { cfg.NumFlows = maxNumFlows + 1 }, errorInvalidNumFlows},
+		{"negative IPv6 fraction", func(cfg *Config) { cfg.IPv6Fraction = -0.1 }, errorInvalidIPv6Fraction},
+		{"IPv6 fraction too large", func(cfg *Config) { cfg.IPv6Fraction = 1.1 }, errorInvalidIPv6Fraction},
+		{"negative rate", func(cfg *Config) { cfg.Rate = -1 }, errorInvalidRate},
+		{"no packet size", func(cfg *Config) { cfg.PacketSize = 0 }, errorInvalidPacketSize},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			test.modifyFn(&cfg)
+
+			_, err := New(cfg)
+			require.ErrorIs(t, err, test.err)
+		})
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	var tests = []struct {
+		numFlows      int
+		ipv6Fraction  float64
+		bidirectional bool
+	}{
+		{1, 0, false},
+		{100, 0, true},
+		{100, 0.25, true},
+		{1000, 1, false},
+		{70000, 0.5, true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("", func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.NumFlows, cfg.IPv6Fraction, cfg.Bidirectional = test.numFlows, test.ipv6Fraction, test.bidirectional
+
+			gen, err := New(cfg)
+			require.Nil(t, err)
+
+			expectedPackets := test.numFlows
+			if test.bidirectional {
+				expectedPackets *= 2
+			}
+			require.Equal(t, expectedPackets, gen.NumPackets())
+
+			flows := make(map[capturetypes.EPHash]struct{})
+			nIPv6 := 0
+			for i := 0; i < gen.NumPackets(); i++ {
+				pkt := gen.Next()
+				require.Equal(t, uint32(cfg.PacketSize), pkt.TotalLen())
+
+				hash, isIPv4, _, errno := capture.ParsePacket(pkt.IPLayer())
+				require.Equal(t, capturetypes.ErrnoOK, errno)
+				if !isIPv4 {
+					nIPv6++
+				}
+
+				// map return packets onto their originating flow
+				if hashReverse := hash.Reverse(); bytes.Compare(hashReverse[:], hash[:]) < 0 {
+					hash = hashReverse
+				}
+				flows[hash] = struct{}{}
+			}
+			require.Equal(t, test.numFlows, len(flows))
+			require.Equal(t, int(test.ipv6Fraction*float64(test.numFlows))*expectedPackets/test.numFlows, nIPv6)
+		})
+	}
+}
+
+func TestGeneratorDeterministic(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed, cfg.IPv6Fraction = 42, 0.5
+
+	gen1, err := New(cfg)
+	require.Nil(t, err)
+	gen2, err := New(cfg)
+	require.Nil(t, err)
+
+	// cycle through the packets twice to cover the wrap-around
+	for i := 0; i < 2*gen1.NumPackets(); i++ {
+		require.Equal(t, gen1.Next(), gen2.Next())
+	}
+}
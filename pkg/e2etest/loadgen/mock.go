@@ -0,0 +1,180 @@
+//go:build !slimcap_nomock
+// +build !slimcap_nomock
+
+package loadgen
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/capture"
+	"github.com/fako1024/slimcap/capture/afpacket/afring"
+	"github.com/fako1024/slimcap/link"
+)
+
+const (
+	// feedInterval denotes the interval in which packets are added to a rate limited mock source
+	feedInterval = 10 * time.Millisecond
+
+	// releaseInterval denotes the interval in which blocks are released to the consumer by an
+	// unlimited (non-draining) mock source
+	releaseInterval = time.Microsecond
+
+	mockBlockSize = 1024 * 1024
+	mockNumBlocks = 4
+)
+
+// Stats tracks the packets fed into mock sources
+type Stats struct {
+	generated atomic.Uint64
+	skipped   atomic.Uint64
+}
+
+// Generated returns the number of packets added to the mock source(s). For unlimited sources,
+// this only denotes the packets required to fill the ring buffer (which is then consumed in a loop)
+func (s *Stats) Generated() uint64 {
+	return s.generated.Load()
+}
+
+// Skipped returns the number of packets which couldn't be added at the configured rate because
+// the ring buffer of a mock source was full (i.e. the capture didn't keep up)
+func (s *Stats) Skipped() uint64 {
+	return s.skipped.Load()
+}
+
+// SourceInitFn returns a source initialization function for the capture manager (see
+// capture.WithSourceInitFn) which feeds synthetic traffic into a mock source for each interface.
+//
+// If a rate is configured, packets are continuously added to the ring buffer at that rate. Otherwise,
+// the ring buffer is filled once and consumed in a loop (as fast as possible), in which case the
+// effective flow cardinality is limited by the number of packets fitting into the ring buffer
+func SourceInitFn(cfg Config, stats *Stats) (func(c *capture.Capture) (capture.Source, error), error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if stats == nil {
+		stats = &Stats{}
+	}
+
+	return func(c *capture.Capture) (capture.Source, error) {
+		gen, err := New(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		opts := []afring.Option{
+			afring.CaptureLength(link.CaptureLengthMinimalIPv6Transport),
+			afring.Promiscuous(false),
+			afring.BufferSize(mockBlockSize, mockNumBlocks),
+		}
+		if cfg.Rate == 0 {
+			return newUnlimitedSource(c.Iface(), gen, stats, opts...)
+		}
+		return newRateLimitedSource(c.Iface(), gen, stats, opts...)
+	}, nil
+}
+
+func newUnlimitedSource(iface string, gen *Generator, stats *Stats, opts ...afring.Option) (capture.Source, error) {
+	mockSrc, err := afring.NewMockSourceNoDrain(iface, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	for mockSrc.CanAddPackets() {
+		if err := mockSrc.AddPacket(gen.Next()); err != nil {
+			return nil, err
+		}
+		stats.generated.Add(1)
+	}
+
+	if _, err := mockSrc.Run(releaseInterval); err != nil {
+		return nil, err
+	}
+	return mockSrc, nil
+}
+
+// rateLimitedSource wraps a mock source which is continuously fed with packets in the background
+type rateLimitedSource struct {
+	*afring.MockSource
+
+	stop     context.CancelFunc
+	wgFeed   sync.WaitGroup
+	errsChan <-chan error
+}
+
+func newRateLimitedSource(iface string, gen *Generator, stats *Stats, opts ...afring.Option) (capture.Source, error) {
+	mockSrc, err := afring.NewMockSource(iface, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	src := &rateLimitedSource{
+		MockSource: mockSrc,
+		stop:       stop,
+		errsChan:   mockSrc.Run(),
+	}
+
+	src.wgFeed.Add(1)
+	go func() {
+		defer src.wgFeed.Done()
+		src.feed(ctx, gen, stats)
+	}()
+
+	return src, nil
+}
+
+func (s *rateLimitedSource) feed(ctx context.Context, gen *Generator, stats *Stats) {
+	ticker := time.NewTicker(feedInterval)
+	defer ticker.Stop()
+
+	rate := float64(gen.Config().Rate)
+	start, sent := time.Now(), uint64(0)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			target := uint64(now.Sub(start).Seconds() * rate)
+			for ; sent < target; sent++ {
+				if ctx.Err() != nil {
+					return
+				}
+
+				// never block on a full ring buffer, the packets are considered to be lost instead
+				// (just like the kernel would drop them)
+				if !s.CanAddPackets() {
+					stats.skipped.Add(target - sent)
+					sent = target
+					break
+				}
+				if err := s.AddPacket(gen.Next()); err != nil {
+					return
+				}
+				stats.generated.Add(1)
+			}
+
+			// make the packets available to the consumer
+			s.FinalizeBlock(false)
+		}
+	}
+}
+
+// Close stops feeding packets and closes the underlying mock source
+func (s *rateLimitedSource) Close() error {
+	s.stop()
+
+	// releasing all blocks unblocks the feeding routine in case it waits for a block to be consumed
+	s.ForceBlockRelease()
+	s.wgFeed.Wait()
+
+	// terminate the mock ring buffer routine and make sure that no block remains in userland
+	s.Done()
+	for range s.errsChan {
+	}
+	s.ForceBlockRelease()
+
+	return s.MockSource.Close()
+}
@@ -179,6 +179,7 @@ func (m mockIfaces) BuildResults(t *testing.T, testDir string, resGoQuery *resul
 	res.Summary.First = resGoQuery.Summary.First
 	res.Summary.Last = resGoQuery.Summary.Last
 	res.Summary.Timings = resGoQuery.Summary.Timings
+	res.Summary.Resources = resGoQuery.Summary.Resources
 
 	return res, ifaceMetadata
 }
@@ -15,9 +15,11 @@ package goDB
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -27,11 +29,15 @@ import (
 	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
 	"github.com/els0r/goProbe/pkg/goDB/storage"
 	"github.com/els0r/goProbe/pkg/goDB/storage/gpfile"
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/telemetry/tracing"
 	"github.com/els0r/goProbe/pkg/types"
 	"github.com/els0r/goProbe/pkg/types/hashmap"
 	"github.com/els0r/telemetry/logging"
 	"github.com/fako1024/gotools/bitpack"
 	"github.com/fako1024/gotools/concurrency"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -62,8 +68,30 @@ type DBWorkManager struct {
 
 	tFirstCovered, tLastCovered int64
 
+	nDirs               int
 	nWorkloads          uint64
 	nWorkloadsProcessed atomic.Uint64
+	nBlocksSkipped      atomic.Uint64
+	nBlocksExcluded     atomic.Uint64
+	nBlocksScanned      atomic.Uint64
+	nBytesDecompressed  atomic.Uint64
+
+	// captureQuality sums up the capture quality indicators of all blocks within the queried range
+	// (except for the excluded ones)
+	captureQuality     gpfile.TrafficMetadata
+	captureQualityLock sync.Mutex
+
+	// blockTimestamps tracks the timestamps of all blocks within the queried range (in order to
+	// detect gaps in the data coverage), linkDownTimestamps the ones of the blocks written while the
+	// link of the interface was down
+	blockTimestamps     []int64
+	linkDownTimestamps  []int64
+	blockTimestampsLock sync.Mutex
+
+	// corruptions tracks the inconsistent columns of all blocks within the queried range (only if
+	// the blocks are verified, see Query.Verify())
+	corruptions     []results.Corruption
+	corruptionsLock sync.Mutex
 }
 
 // NewDBWorkManager sets up a new work manager for executing queries
@@ -88,6 +116,141 @@ func (w *DBWorkManager) GetNumWorkers() uint64 {
 	return w.nWorkloads
 }
 
+// GetNumDirs returns the number of daily directories read by the workloads
+func (w *DBWorkManager) GetNumDirs() int {
+	return w.nDirs
+}
+
+// GetNumBlocksSkipped returns the number of blocks skipped because their range of attribute values
+// cannot satisfy the conditional of the query
+func (w *DBWorkManager) GetNumBlocksSkipped() uint64 {
+	return w.nBlocksSkipped.Load()
+}
+
+// GetNumBlocksExcluded returns the number of blocks excluded because of their capture quality (see
+// Query.MaxDropRate())
+func (w *DBWorkManager) GetNumBlocksExcluded() uint64 {
+	return w.nBlocksExcluded.Load()
+}
+
+// GetCaptureQuality returns the capture quality indicators (drops, overruns, truncated packets) summed
+// up over all blocks the results are based on
+func (w *DBWorkManager) GetCaptureQuality() gpfile.TrafficMetadata {
+	w.captureQualityLock.Lock()
+	defer w.captureQualityLock.Unlock()
+	return w.captureQuality
+}
+
+// GetNumBlocksScanned returns the number of blocks read from disk and evaluated
+func (w *DBWorkManager) GetNumBlocksScanned() uint64 {
+	return w.nBlocksScanned.Load()
+}
+
+// GetNumBytesDecompressed returns the total size of all column blocks read (after decompression)
+func (w *DBWorkManager) GetNumBytesDecompressed() uint64 {
+	return w.nBytesDecompressed.Load()
+}
+
+// GetCorruptions returns the inconsistent columns found while verifying the blocks (see Query.Verify()),
+// ordered by the timestamp of their block
+func (w *DBWorkManager) GetCorruptions() []results.Corruption {
+	w.corruptionsLock.Lock()
+	corruptions := slices.Clone(w.corruptions)
+	w.corruptionsLock.Unlock()
+
+	slices.SortStableFunc(corruptions, func(a, b results.Corruption) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+	return corruptions
+}
+
+// GetCoverageGaps returns the intervals within [tfirst, tlast] for which no blocks were found in
+// the DB while processing the workloads (e.g. due to capture downtime), allowing to distinguish
+// "no traffic" from "no data". Deviations of less than half a writeout interval are tolerated.
+// Since the covered time interval already reflects where the data begins, the interval before the
+// first block is not considered a gap
+func (w *DBWorkManager) GetCoverageGaps(tfirst, tlast int64) []results.TimeRange {
+	w.blockTimestampsLock.Lock()
+	timestamps := slices.Clone(w.blockTimestamps)
+	w.blockTimestampsLock.Unlock()
+	slices.Sort(timestamps)
+
+	if len(w.query.timeWindows) == 0 {
+		return coverageGaps(timestamps, tfirst, tlast)
+	}
+
+	// if the query is restricted to time windows, the intervals in between them aren't gaps
+	var gaps []results.TimeRange
+	for _, window := range w.query.timeWindows {
+		lo, _ := slices.BinarySearch(timestamps, window.First)
+		hi, _ := slices.BinarySearch(timestamps, window.Last+1)
+		gaps = append(gaps, coverageGaps(timestamps[lo:hi], max(window.First, tfirst), min(window.Last, tlast))...)
+	}
+	return gaps
+}
+
+func coverageGaps(timestamps []int64, tfirst, tlast int64) (gaps []results.TimeRange) {
+	const tolerance = DBWriteInterval + DBWriteInterval/2
+
+	addGap := func(first, last int64) {
+		gaps = append(gaps, results.TimeRange{First: time.Unix(first, 0), Last: time.Unix(last, 0)})
+	}
+
+	if len(timestamps) == 0 {
+		if tlast > tfirst {
+			addGap(tfirst, tlast)
+		}
+		return gaps
+	}
+
+	// a block covers the writeout interval preceding its timestamp
+	for i := 1; i < len(timestamps); i++ {
+		if timestamps[i]-timestamps[i-1] > tolerance {
+			addGap(timestamps[i-1], timestamps[i]-DBWriteInterval)
+		}
+	}
+	if last := timestamps[len(timestamps)-1]; tlast-last > tolerance {
+		addGap(last, tlast)
+	}
+
+	return gaps
+}
+
+// GetLinkDownIntervals returns the intervals within [tfirst, tlast] during which the link of the
+// interface was down (as flagged by the blocks found while processing the workloads). Consecutive
+// blocks are merged into a single interval
+func (w *DBWorkManager) GetLinkDownIntervals(tfirst, tlast int64) (intervals []results.TimeRange) {
+	w.blockTimestampsLock.Lock()
+	timestamps := slices.Clone(w.linkDownTimestamps)
+	w.blockTimestampsLock.Unlock()
+	slices.Sort(timestamps)
+
+	const tolerance = DBWriteInterval + DBWriteInterval/2
+
+	// a block covers the writeout interval preceding its timestamp
+	for i := 0; i < len(timestamps); i++ {
+		first, last := timestamps[i]-DBWriteInterval, timestamps[i]
+		for i+1 < len(timestamps) && timestamps[i+1]-last <= tolerance {
+			i++
+			last = timestamps[i]
+		}
+		if len(w.query.timeWindows) == 0 {
+			if first, last := max(first, tfirst), min(last, tlast); last > first {
+				intervals = append(intervals, results.TimeRange{First: time.Unix(first, 0), Last: time.Unix(last, 0)})
+			}
+			continue
+		}
+
+		// if the query is restricted to time windows, only the parts within them are considered
+		for _, window := range w.query.timeWindows {
+			if first, last := max(first, tfirst, window.First), min(last, tlast, window.Last); last > first {
+				intervals = append(intervals, results.TimeRange{First: time.Unix(first, 0), Last: time.Unix(last, 0)})
+			}
+		}
+	}
+	return intervals
+}
+
 // GetCoveredTimeInterval can be used to determine the time span actually covered by the query
 func (w *DBWorkManager) GetCoveredTimeInterval() (time.Time, time.Time) {
 	return time.Unix(w.tFirstCovered-DBWriteInterval, 0), time.Unix(w.tLastCovered, 0)
@@ -116,9 +279,11 @@ func (w *DBWorkManager) CreateWorkerJobs(tfirst int64, tlast int64) (nonempty bo
 			if err := curDir.Open(); err != nil {
 				return fmt.Errorf("failed to open first GPDir %s to ascertain query block timing: %w", curDir.Path(), err)
 			}
-			dirFirst, _ := curDir.TimeRange()
-			if tfirst < dirFirst {
-				w.tFirstCovered = dirFirst
+			if curDir.NBlocks() > 0 {
+				dirFirst, _ := curDir.TimeRange()
+				if tfirst < dirFirst {
+					w.tFirstCovered = dirFirst
+				}
 			}
 			if err := curDir.Close(); err != nil {
 				return fmt.Errorf("failed to close first GPDir %s after ascertaining query block timing: %w", curDir.Path(), err)
@@ -135,6 +300,7 @@ func (w *DBWorkManager) CreateWorkerJobs(tfirst int64, tlast int64) (nonempty bo
 		return nil
 	}
 	numDirs, err := w.walkDB(tfirst, tlast, walkFunc)
+	w.nDirs = numDirs
 
 	// Flush any remaining work
 	if len(workloadBulk) > 0 {
@@ -151,9 +317,11 @@ func (w *DBWorkManager) CreateWorkerJobs(tfirst int64, tlast int64) (nonempty bo
 		if err := curDir.Open(); err != nil {
 			return false, fmt.Errorf("failed to open last GPDir %s to ascertain query block timing: %w", curDir.Path(), err)
 		}
-		_, dirLast := curDir.TimeRange()
-		if tlast > dirLast {
-			w.tLastCovered = dirLast
+		if curDir.NBlocks() > 0 {
+			_, dirLast := curDir.TimeRange()
+			if tlast > dirLast {
+				w.tLastCovered = dirLast
+			}
 		}
 		if err := curDir.Close(); err != nil {
 			return false, fmt.Errorf("failed to close last GPDir %s after ascertaining query block timing: %w", curDir.Path(), err)
@@ -230,8 +398,15 @@ func (w *DBWorkManager) walkDB(tfirst, tlast int64, fn dbWalkFunc) (numDirs int,
 					return numDirs, fmt.Errorf("failed to parse epoch timestamp from directory `%s`: %w", file.Name(), err)
 				}
 
-				// check if the directory is within time frame of interest
-				if tfirst < dayTimestamp+gpfile.EpochDay && dayTimestamp < tlast+DBWriteInterval {
+				// check if the directory is within time frame (and time windows) of interest
+				if tfirst < dayTimestamp+gpfile.EpochDay && dayTimestamp < tlast+DBWriteInterval && w.query.coversDay(dayTimestamp) {
+
+					// skip directories without any fully written blocks (e.g. the current day while its
+					// first block is being written)
+					if !gpfile.NewDir(w.dbIfaceDir, dayTimestamp, gpfile.ModeRead).HasMetadata() {
+						continue
+					}
+
 					// actual processing upon a match
 					err := fn(numDirs, dayTimestamp)
 					if err != nil {
@@ -254,7 +429,7 @@ func (w *DBWorkManager) ReadMetadata(tfirst int64, tlast int64) (*InterfaceMetad
 	// loop over directory list in order to create the timestamp pairs
 	var gpFileOptions []gpfile.Option
 	if !query.lowMem {
-		memPool := concurrency.NewMemPool(len(query.columnIndices))
+		memPool := concurrency.NewMemPool(query.numColumns())
 		gpFileOptions = append(gpFileOptions, gpfile.WithReadAll(memPool))
 		defer memPool.Clear()
 	}
@@ -276,10 +451,12 @@ func (w *DBWorkManager) ReadMetadata(tfirst int64, tlast int64) (*InterfaceMetad
 
 		// do the metadata compuation based on the metadata
 		aggMetadata.Stats = aggMetadata.Stats.Add(curDir.Stats)
+		aggMetadata.Days++
+		aggMetadata.DiskSize += curDir.DataSize()
 
 		// compute the metadata for the first day. If a "first" time argument is given,
 		// the partial day has to be computed
-		if numDirs == 0 {
+		if numDirs == 0 && curDir.NBlocks() > 0 {
 			dirFirst, _ := curDir.TimeRange()
 			if tfirst >= dirFirst {
 				// subtract all entries that are smaller than w.tFirstCovered because they were added in the day loop
@@ -326,27 +503,29 @@ func (w *DBWorkManager) ReadMetadata(tfirst int64, tlast int64) (*InterfaceMetad
 		if err := curDir.Open(); err != nil {
 			return nil, fmt.Errorf("failed to open last GPDir %s to ascertain query block timing: %w", curDir.Path(), err)
 		}
-		_, dirLast := curDir.TimeRange()
-
-		if tlast <= dirLast {
-			// subtract all entries that are smaller than w.tLastCovered because they were added in the day loop
-			var (
-				blocks, offset = curDir.BlockMetadata[0].BlocksAfter(tlast)
-				tLastBlockInd  = len(curDir.BlockMetadata[0].BlockList) - len(blocks) - 1
-			)
-
-			aggMetadata, err = w.readMetadataAndEvaluate(curDir,
-				blocks, offset,
-				aggMetadata, func(metadata *InterfaceMetadata, stats gpfile.Stats) gpfile.Stats {
-					return metadata.Stats.Sub(stats)
-				},
-			)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read block metadata: %w", err)
+		if curDir.NBlocks() > 0 {
+			_, dirLast := curDir.TimeRange()
+
+			if tlast <= dirLast {
+				// subtract all entries that are smaller than w.tLastCovered because they were added in the day loop
+				var (
+					blocks, offset = curDir.BlockMetadata[0].BlocksAfter(tlast)
+					tLastBlockInd  = len(curDir.BlockMetadata[0].BlockList) - len(blocks) - 1
+				)
+
+				aggMetadata, err = w.readMetadataAndEvaluate(curDir,
+					blocks, offset,
+					aggMetadata, func(metadata *InterfaceMetadata, stats gpfile.Stats) gpfile.Stats {
+						return metadata.Stats.Sub(stats)
+					},
+				)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read block metadata: %w", err)
+				}
+				w.tLastCovered = curDir.BlockMetadata[0].BlockList[tLastBlockInd].Timestamp
+			} else {
+				w.tLastCovered = dirLast
 			}
-			w.tLastCovered = curDir.BlockMetadata[0].BlockList[tLastBlockInd].Timestamp
-		} else {
-			w.tLastCovered = dirLast
 		}
 
 		if err := curDir.Close(); err != nil {
@@ -457,9 +636,11 @@ func (w *DBWorkManager) grabAndProcessWorkload(ctx context.Context, wg *sync.Wai
 			mapChan <- hashmap.NilAggFlowMapWithMetadata
 		}
 
+		// The pool only holds buffers for the columns read by the query, hence it can't be used if all
+		// columns (and an unknown number of label columns) are read to verify the blocks
 		var memPool concurrency.MemPoolGCable
-		if !w.query.lowMem {
-			memPool = concurrency.NewMemPool(len(w.query.columnIndices))
+		if !w.query.lowMem && !w.query.verify {
+			memPool = concurrency.NewMemPool(w.query.numColumns())
 		}
 		defer func() {
 			if memPool != nil {
@@ -480,14 +661,25 @@ func (w *DBWorkManager) grabAndProcessWorkload(ctx context.Context, wg *sync.Wai
 			case workload, chanOpen = <-workloadChan:
 				if chanOpen {
 					resultMap := hashmap.NewAggFlowMapWithMetadata()
-					for _, workDir := range workload.workDirs {
+					for i, workDir := range workload.workDirs {
 
 						if memPool != nil {
 							workDir.SetMemPool(memPool)
+
+							// the files are read at once, hence the ones of the next directory are
+							// prefetched while this one is processed
+							if w.query.readAhead > 0 && i+1 < len(workload.workDirs) {
+								workload.workDirs[i+1].Prefetch(w.query.columnIndices)
+							}
 						}
 
 						// if there is an error during one of the read jobs, throw a syslog message and terminate
-						err := w.readBlocksAndEvaluate(workDir, enc, &resultMap)
+						err := w.readBlocksAndEvaluate(ctx, workDir, enc, &resultMap)
+						if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+							// query was cancelled while waiting for a (throttled) read, exit
+							logger.Infof("Query cancelled (workload %d / %d)...", w.nWorkloadsProcessed.Load(), w.nWorkloads)
+							return
+						}
 						if err != nil {
 							logger.Error(err)
 							mapChan <- hashmap.NilAggFlowMapWithMetadata
@@ -508,6 +700,12 @@ func (w *DBWorkManager) grabAndProcessWorkload(ctx context.Context, wg *sync.Wai
 
 // ExecuteWorkerReadJobs runs the query concurrently with multiple sprocessing units
 func (w *DBWorkManager) ExecuteWorkerReadJobs(ctx context.Context, mapChan chan hashmap.AggFlowMapWithMetadata) {
+	ctx, span := tracing.Start(ctx, "DBWorkManager.ExecuteWorkerReadJobs", trace.WithAttributes(
+		attribute.String("iface", w.iface),
+		attribute.Int64("workloads", int64(w.nWorkloads)),
+		attribute.Int("workers", w.numProcessingUnits),
+	))
+	defer span.End()
 
 	var wg = new(sync.WaitGroup)
 	wg.Add(w.numProcessingUnits)
@@ -522,8 +720,17 @@ func (w *DBWorkManager) ExecuteWorkerReadJobs(ctx context.Context, mapChan chan
 
 // Block evaluation and aggregation -----------------------------------------------------
 // this is where the actual reading and aggregation magic happens
-func (w *DBWorkManager) readBlocksAndEvaluate(workDir *gpfile.GPDir, enc encoder.Encoder, resultMap *hashmap.AggFlowMapWithMetadata) (err error) {
-	logger := logging.Logger()
+func (w *DBWorkManager) readBlocksAndEvaluate(ctx context.Context, workDir *gpfile.GPDir, enc encoder.Encoder, resultMap *hashmap.AggFlowMapWithMetadata) (err error) {
+	logger := logging.FromContext(ctx)
+
+	_, span := tracing.Start(ctx, "DBWorkManager.readBlocksAndEvaluate", trace.WithAttributes(
+		attribute.String("iface", w.iface),
+		attribute.String("dir", workDir.Path()),
+	))
+	defer func() {
+		tracing.Error(span, err)
+		span.End()
+	}()
 
 	var (
 		v4Key, v4ComparisonValue                                         = types.NewEmptyV4Key().ExtendEmpty(), types.NewEmptyV4Key().ExtendEmpty()
@@ -532,7 +739,7 @@ func (w *DBWorkManager) readBlocksAndEvaluate(workDir *gpfile.GPDir, enc encoder
 	)
 
 	// Open GPDir (reading metadata in the process)
-	if err := workDir.Open(gpfile.WithEncoder(enc)); err != nil {
+	if err := workDir.Open(gpfile.WithEncoder(enc), gpfile.WithReadAhead(w.query.readAhead)); err != nil {
 		return err
 	}
 	defer func() {
@@ -540,6 +747,25 @@ func (w *DBWorkManager) readBlocksAndEvaluate(workDir *gpfile.GPDir, enc encoder
 			err = cerr
 		}
 	}()
+	span.SetAttributes(attribute.Int("blocks", workDir.NBlocks()))
+
+	// If any label columns are queried, the keys are extended by their labels (which are set per entry)
+	var (
+		labels      *dirLabels
+		labelBlocks [][]byte
+	)
+	if w.query.hasAttrLabels {
+		if labels, err = w.query.dirLabels(workDir); err != nil {
+			return err
+		}
+		labelBlocks = make([][]byte, len(w.query.labelColumns))
+		v4Key, v6Key = v4Key.ExtendLabels(), v6Key.ExtendLabels()
+	}
+
+	// If the flow direction is queried, the keys are extended by it (which is derived per entry)
+	if w.query.hasAttrDir {
+		v4Key, v6Key = v4Key.ExtendDirection(), v6Key.ExtendDirection()
+	}
 
 	// Set map metadata (and cross-check consistency for consecutive workloads)
 	if resultMap.Interface == "" {
@@ -549,6 +775,20 @@ func (w *DBWorkManager) readBlocksAndEvaluate(workDir *gpfile.GPDir, enc encoder
 	}
 
 	// Process the workload, looping over all blocks in this directory
+	var (
+		blockTimestamps, linkDownTimestamps []int64
+		captureQuality                      gpfile.TrafficMetadata
+	)
+	defer func() {
+		w.blockTimestampsLock.Lock()
+		w.blockTimestamps = append(w.blockTimestamps, blockTimestamps...)
+		w.linkDownTimestamps = append(w.linkDownTimestamps, linkDownTimestamps...)
+		w.blockTimestampsLock.Unlock()
+
+		w.captureQualityLock.Lock()
+		w.captureQuality = w.captureQuality.Add(captureQuality)
+		w.captureQualityLock.Unlock()
+	}()
 	for b, block := range workDir.BlockMetadata[0].Blocks() {
 
 		// If this block is outside of the rannge, skip it (only happens at the very first
@@ -557,19 +797,59 @@ func (w *DBWorkManager) readBlocksAndEvaluate(workDir *gpfile.GPDir, enc encoder
 			continue
 		}
 
+		// Skip blocks in between the time windows of the query (if any)
+		if !w.query.coversTimestamp(block.Timestamp) {
+			continue
+		}
+		blockTimestamps = append(blockTimestamps, block.Timestamp)
+
+		// Blocks written while the link was down hold no data (rather than no traffic)
+		traffic := workDir.TrafficAtIndex(b)
+		if traffic.NumLinkDown > 0 {
+			linkDownTimestamps = append(linkDownTimestamps, block.Timestamp)
+		}
+
+		// Skip blocks whose capture quality doesn't meet the requirements of the query (if any)
+		if w.query.excludesBlock(traffic) {
+			w.nBlocksExcluded.Add(1)
+			continue
+		}
+		captureQuality = captureQuality.Add(traffic)
+
+		// If none of the flows in this block can satisfy the conditional, skip it before reading
+		// and decompressing any of its columns (unless all blocks are verified)
+		if !w.query.verify && w.query.skipsBlock(workDir.BlockRangeAtIndex(b)) {
+			w.nBlocksSkipped.Add(1)
+			continue
+		}
+
+		// Throttle disk reads (if limited by the query)
+		if err := w.query.waitForRead(ctx); err != nil {
+			return err
+		}
+
 		var (
 			blocks      [types.ColIdxCount][]byte
 			blockBroken bool
 		)
 
-		// Read the blocks from their files
-		for _, colIdx := range w.query.columnIndices {
+		// Read the blocks from their files (all of them if the block is verified, in which case inconsistent
+		// blocks are skipped right away)
+		w.nBlocksScanned.Add(1)
+		if w.query.verify {
+			if !w.verifyBlock(ctx, workDir, b, block.Timestamp, &blocks) {
+				continue
+			}
+		} else {
+			for _, colIdx := range w.query.columnIndices {
 
-			// Read the block from the file
-			if blocks[colIdx], err = workDir.ReadBlockAtIndex(colIdx, b); err != nil {
-				blockBroken = true
-				logger.With("day", workDir, "block", block.Timestamp, "column", types.ColumnFileNames[colIdx]).Warnf("Failed to read column: %s", err)
-				break
+				// Read the block from the file
+				if blocks[colIdx], err = workDir.ReadBlockAtIndex(colIdx, b); err != nil {
+					blockBroken = true
+					logger.With("day", workDir, "block", block.Timestamp, "column", types.ColumnFileNames[colIdx]).Warnf("Failed to read column: %s", err)
+					break
+				}
+				w.nBytesDecompressed.Add(uint64(len(blocks[colIdx])))
 			}
 		}
 
@@ -606,6 +886,21 @@ func (w *DBWorkManager) readBlocksAndEvaluate(workDir *gpfile.GPDir, enc encoder
 			}
 		}
 
+		// Read the labels of the block (if any were stored along with it)
+		for c, name := range w.query.labelColumns {
+			if blockBroken {
+				break
+			}
+			if labelBlocks[c], err = workDir.ReadLabelBlockAtIndex(name, b); err != nil {
+				blockBroken = true
+				logger.With("day", workDir, "block", block.Timestamp, "column", name).Warnf("Failed to read label column: %s", err)
+			} else if l := len(labelBlocks[c]); l > 0 && l != numEntries*gpfile.DictionaryIDWidth {
+				blockBroken = true
+				logger.With("block", b, "column", name).Warnf("Incorrect number of entries in label column file. Expected %d, found %d", numEntries, l/gpfile.DictionaryIDWidth)
+			}
+			w.nBytesDecompressed.Add(uint64(len(labelBlocks[c])))
+		}
+
 		// In case any error was observed during above sanity checks, skip this whole block
 		if blockBroken {
 			continue
@@ -615,6 +910,12 @@ func (w *DBWorkManager) readBlocksAndEvaluate(workDir *gpfile.GPDir, enc encoder
 		if w.query.hasAttrTime {
 			v4Key = types.NewEmptyV4Key().Extend(block.Timestamp)
 			v6Key = types.NewEmptyV6Key().Extend(block.Timestamp)
+			if w.query.hasAttrLabels {
+				v4Key, v6Key = v4Key.ExtendLabels(), v6Key.ExtendLabels()
+			}
+			if w.query.hasAttrDir {
+				v4Key, v6Key = v4Key.ExtendDirection(), v6Key.ExtendDirection()
+			}
 			if w.query.Conditional == nil {
 				v4ComparisonValue = types.NewEmptyV4Key().Extend(block.Timestamp)
 				v6ComparisonValue = types.NewEmptyV6Key().Extend(block.Timestamp)
@@ -631,13 +932,15 @@ func (w *DBWorkManager) readBlocksAndEvaluate(workDir *gpfile.GPDir, enc encoder
 		dportBlocks := blocks[types.DportColIdx]
 		protoBlocks := blocks[types.ProtoColIdx]
 
-		// Determine start / end of block perusal - If the query is limited to either IPv4 or IPv6, adjust
-		// accordingly to skip irrelevant data that wouldn't satisfy the condition anyway
+		// Determine start / end of block perusal - If the query (or its condition) is limited to either IPv4
+		// or IPv6, adjust accordingly to skip irrelevant data that wouldn't satisfy the query anyway. If it's
+		// limited to both (contradicting each other), no entry is considered
 		key, comparisonValue := v4Key, v4ComparisonValue
 		startEntry, isIPv4, condIsIPv4 := 0, true, true
-		if w.query.ipVersion == types.IPVersionV6 {
+		if w.query.skipsIPv4() {
 			startEntry = numV4Entries
-		} else if w.query.ipVersion == types.IPVersionV4 {
+		}
+		if w.query.skipsIPv6() {
 			numEntries = numV4Entries
 		}
 		for i := startEntry; i < numEntries; i++ {
@@ -677,6 +980,16 @@ func (w *DBWorkManager) readBlocksAndEvaluate(workDir *gpfile.GPDir, enc encoder
 			if w.query.hasAttrDport {
 				key.PutDportV(dportBlocks[i*types.DportSizeof:i*types.DportSizeof+types.DportSizeof], isIPv4)
 			}
+			if w.query.hasAttrLabels {
+				key.PutLabels(labels.id(labelBlocks, i))
+			}
+
+			// The direction is determined per entry, i.e. the same flow may be attributed to different
+			// directions in different blocks
+			dir := w.query.flowDirection(pktsRcvdValues[i], pktsSentValues[i])
+			if w.query.hasAttrDir {
+				key.PutDirection(dir)
+			}
 
 			// Check whether conditional is satisfied for current entry
 			var conditionalSatisfied = (w.query.Conditional == nil)
@@ -704,7 +1017,7 @@ func (w *DBWorkManager) readBlocksAndEvaluate(workDir *gpfile.GPDir, enc encoder
 					comparisonValue.PutDportV(dportBlocks[i*types.DportSizeof:i*types.DportSizeof+types.DportSizeof], condIsIPv4)
 				}
 
-				conditionalSatisfied = w.query.Conditional.Evaluate(comparisonValue.Key())
+				conditionalSatisfied = w.query.Conditional.Evaluate(comparisonValue.Key(), dir)
 			}
 
 			if conditionalSatisfied {
@@ -722,5 +1035,92 @@ func (w *DBWorkManager) readBlocksAndEvaluate(workDir *gpfile.GPDir, enc encoder
 	return nil
 }
 
+// verifyBlock reads all columns of a block (including its label columns) into blocks and checks that each
+// of them holds as many entries as the block according to its metadata. Inconsistent columns are recorded
+// as corruptions. Returns whether the block is consistent
+func (w *DBWorkManager) verifyBlock(ctx context.Context, workDir *gpfile.GPDir, b int, timestamp int64, blocks *[types.ColIdxCount][]byte) bool {
+	numV4Entries, numV6Entries := int(workDir.NumIPv4EntriesAtIndex(b)), int(workDir.NumIPv6EntriesAtIndex(b))
+	numEntries := numV4Entries + numV6Entries
+
+	var corruptions []results.Corruption
+	addCorruption := func(column string, found int, err error) {
+		corruption := results.Corruption{
+			Iface:     w.iface,
+			Timestamp: time.Unix(timestamp, 0),
+			Column:    column,
+			Expected:  numEntries,
+			Found:     found,
+		}
+		if err != nil {
+			corruption.Error = err.Error()
+		}
+		logging.FromContext(ctx).With("day", workDir, "block", timestamp, "column", column).Errorf("Corrupt column: expected %d entries, found %d", numEntries, found)
+		corruptions = append(corruptions, corruption)
+	}
+
+	for colIdx := types.ColumnIndex(0); colIdx < types.ColIdxCount; colIdx++ {
+		block, err := workDir.ReadBlockAtIndex(colIdx, b)
+		if err != nil {
+			addCorruption(types.ColumnFileNames[colIdx], 0, err)
+			continue
+		}
+		blocks[colIdx] = block
+		w.nBytesDecompressed.Add(uint64(len(block)))
+
+		if found, consistent := columnEntries(colIdx, block, numV4Entries, numV6Entries); !consistent {
+			addCorruption(types.ColumnFileNames[colIdx], found, nil)
+		}
+	}
+
+	// Label columns may lack the labels of a block (if none were written along with it)
+	for _, name := range workDir.LabelColumns() {
+		block, err := workDir.ReadLabelBlockAtIndex(name, b)
+		if err != nil {
+			addCorruption(name, 0, err)
+			continue
+		}
+		w.nBytesDecompressed.Add(uint64(len(block)))
+		if l := len(block); l > 0 && l != numEntries*gpfile.DictionaryIDWidth {
+			addCorruption(name, l/gpfile.DictionaryIDWidth, nil)
+		}
+	}
+
+	if len(corruptions) == 0 {
+		return true
+	}
+
+	w.corruptionsLock.Lock()
+	w.corruptions = append(w.corruptions, corruptions...)
+	w.corruptionsLock.Unlock()
+
+	return false
+}
+
+// columnEntries returns the number of entries of a column block and whether it is consistent with the
+// number of IPv4 / IPv6 entries of the block
+func columnEntries(colIdx types.ColumnIndex, block []byte, numV4Entries, numV6Entries int) (found int, consistent bool) {
+	l, numEntries := len(block), numV4Entries+numV6Entries
+	switch {
+	case colIdx.IsCounterCol():
+
+		// Counters are bit-packed, the first byte denoting the width of the entries
+		width := bitpack.ByteWidth(block)
+		if width > 0 {
+			found = bitpack.Len(block)
+		}
+		return found, found == numEntries && (width == 0 || (l-1)%width == 0)
+	case types.ColumnSizeofs[colIdx] == types.IPSizeOf:
+
+		// IPv4 entries precede the (wider) IPv6 entries
+		found = l / types.IPv4Width
+		if l > numV4Entries*types.IPv4Width {
+			found = numV4Entries + (l-numV4Entries*types.IPv4Width)/types.IPv6Width
+		}
+		return found, l == numV4Entries*types.IPv4Width+numV6Entries*types.IPv6Width
+	default:
+		return l / types.ColumnSizeofs[colIdx], l == numEntries*types.ColumnSizeofs[colIdx]
+	}
+}
+
 // Close releases all resources claimed by the DBWorkManager
 func (w *DBWorkManager) Close() {}
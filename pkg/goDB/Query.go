@@ -16,10 +16,22 @@
 package goDB
 
 import (
+	"context"
+	"encoding/binary"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/els0r/goProbe/pkg/goDB/conditions/node"
+	"github.com/els0r/goProbe/pkg/goDB/storage/gpfile"
 	"github.com/els0r/goProbe/pkg/types"
+	"golang.org/x/time/rate"
 )
 
+// DefaultReadAhead denotes the default number of blocks prefetched while a block is decompressed
+const DefaultReadAhead = 4
+
 // Query stores all relevant parameters for data selection
 type Query struct {
 	// list of attributes that will be compared, e.g. "dip" "sip"
@@ -29,10 +41,15 @@ type Query struct {
 
 	// Explicity attribute flags that allow granular processing logic
 	// without having to rely on array loops
-	hasAttrTime, hasAttrIface                          bool
+	hasAttrTime, hasAttrIface, hasAttrLabels           bool
 	hasAttrSIP, hasAttrDIP, hasAttrDport, hasAttrProto bool
+	hasAttrDir                                         bool
 	hasCondSIP, hasCondDIP, hasCondDport, hasCondProto bool
-	ipVersion                                          types.IPVersion
+	hasCondDir                                         bool
+	ipVersion                                          types.IPVersion // ipVersion: IP version(s) of the conditional
+
+	// ipVersionFilter restricts the query to flows of one IP version (if limited)
+	ipVersionFilter types.IPVersion
 
 	// metadataOnly will determine if all relevant information to answer the query can be
 	// derived solely from metadata inside GPDir
@@ -55,6 +72,81 @@ type Query struct {
 
 	// Enables memory-saving mode
 	lowMem bool
+
+	// readAhead denotes the number of blocks prefetched while a block is decompressed (if zero,
+	// nothing is prefetched)
+	readAhead int
+
+	// timeWindows restricts the query to the blocks within any of the windows (if set)
+	timeWindows types.TimeWindows
+
+	// maxDropRate excludes the blocks whose capture dropped a larger fraction of packets (if set)
+	maxDropRate float64
+
+	// verify checks the consistency of all columns of each block (reporting inconsistent blocks as
+	// corruptions) instead of only the columns required by the query
+	verify bool
+
+	// readLimiter throttles the rate at which blocks are read from disk (shared by all
+	// interfaces / workers of the query). If nil, reads aren't throttled
+	readLimiter *rate.Limiter
+
+	// labelColumns denotes the queried label columns (i.e. the process and / or the label column
+	// attributes, see types.AttributeSpec.LabelColumn)
+	labelColumns []string
+
+	// labels assigns query-wide IDs to the (combined) labels of the queried label columns of the
+	// flows (if any are queried)
+	labels *labelTable
+}
+
+// labelSep separates the labels of multiple label columns in a labelTable
+const labelSep = "\x00"
+
+// labelTable assigns IDs to labels, which are shared by all interfaces / workers of a query (the
+// IDs of a dictionary only apply to the GPDir it is stored in, see gpfile.GPDir.Dictionary()). The
+// empty label (denoting an unknown label) has ID 0
+type labelTable struct {
+	sync.RWMutex
+
+	ids    map[string]uint32
+	values []string
+}
+
+func newLabelTable() *labelTable {
+	return &labelTable{
+		ids:    map[string]uint32{"": 0},
+		values: []string{""},
+	}
+}
+
+// id returns the ID of the label (assigning a new one if required)
+func (t *labelTable) id(value string) uint32 {
+	t.RLock()
+	id, exists := t.ids[value]
+	t.RUnlock()
+	if exists {
+		return id
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	if id, exists = t.ids[value]; !exists {
+		id = uint32(len(t.values))
+		t.ids[value] = id
+		t.values = append(t.values, value)
+	}
+	return id
+}
+
+// value returns the label with the given ID (or the empty label if the ID is unknown)
+func (t *labelTable) value(id uint32) string {
+	t.RLock()
+	defer t.RUnlock()
+	if int(id) >= len(t.values) {
+		return ""
+	}
+	return t.values[id]
 }
 
 // Computes a columnIndex from a column name. In principle we could merge
@@ -62,31 +154,27 @@ type Query struct {
 // we wouldn't "fail early" if an snet or dnet entry somehow made it into
 // the condition attributes.
 func queryAttributeNameToColumnIndex(name string) (colIdx types.ColumnIndex) {
-	colIdx, ok := map[string]types.ColumnIndex{
-		types.SIPName:   types.SIPColIdx,
-		types.DIPName:   types.DIPColIdx,
-		types.ProtoName: types.ProtoColIdx,
-		types.DportName: types.DportColIdx}[name]
-	if !ok {
+	spec, ok := types.LookupAttribute(name)
+	if !ok || spec.Name != name {
 		panic("Unknown query attribute " + name)
 	}
-	return
+	return spec.ColIdx
 }
 
 // Computes a columnIndex from a column name. Different from queryAttributeNameToColumnIndex
 // because snet and dnet are only allowed in conditionals.
 func conditionalAttributeNameToColumnIndex(name string) (colIdx types.ColumnIndex) {
-	colIdx, ok := map[string]types.ColumnIndex{
-		types.SIPName:   types.SIPColIdx,
-		"snet":          types.SIPColIdx,
-		types.DIPName:   types.DIPColIdx,
-		"dnet":          types.DIPColIdx,
-		types.ProtoName: types.ProtoColIdx,
-		types.DportName: types.DportColIdx}[name]
-	if !ok {
+	switch name {
+	case "snet":
+		return types.SIPColIdx
+	case "dnet":
+		return types.DIPColIdx
+	}
+	spec, ok := types.LookupAttribute(name)
+	if !ok || spec.Name != name {
 		panic("Unknown conditional attribute " + name)
 	}
-	return
+	return spec.ColIdx
 }
 
 var queryAttributeColumnFlagSetters = [types.ColIdxAttributeCount]func(q *Query){
@@ -113,11 +201,26 @@ func NewMetadataQuery() *Query {
 
 // NewQuery creates a new Query object based on the parsed command line parameters
 func NewQuery(attributes []types.Attribute, conditional node.Node, selector types.LabelSelector) *Query {
+
+	// a conditional which is always satisfied doesn't have to be evaluated at all
+	if node.AlwaysSatisfied(conditional) {
+		conditional = nil
+	}
+
 	q := &Query{
 		Attributes:   attributes,
 		Conditional:  conditional,
 		hasAttrTime:  selector.Timestamp,
 		hasAttrIface: selector.Iface,
+		hasAttrDir:   selector.Direction,
+		readAhead:    DefaultReadAhead,
+	}
+	if selector.Process {
+		q.labelColumns = append(q.labelColumns, types.ProcessName)
+	}
+	q.labelColumns = append(q.labelColumns, selector.Columns...)
+	if q.hasAttrLabels = len(q.labelColumns) > 0; q.hasAttrLabels {
+		q.labels = newLabelTable()
 	}
 
 	// Compute index sets
@@ -132,6 +235,13 @@ func NewQuery(attributes []types.Attribute, conditional node.Node, selector type
 
 	if q.Conditional != nil {
 		for attribName, ipVersion := range q.Conditional.Attributes() {
+
+			// The flow direction is derived from the counters (which are always read)
+			if attribName == types.DirectionName {
+				q.hasCondDir = true
+				continue
+			}
+
 			colIdx := conditionalAttributeNameToColumnIndex(attribName)
 			q.conditionalAttributeIndices = append(q.conditionalAttributeIndices, colIdx)
 			isAttributeIndex[colIdx] = true
@@ -150,6 +260,94 @@ func NewQuery(attributes []types.Attribute, conditional node.Node, selector type
 	return q
 }
 
+// IPVersion restricts the query to flows of one IP version. If the IP version
+// isn't limited (i.e. none or both), flows of both versions are considered
+func (q *Query) IPVersion(v types.IPVersion) *Query {
+	if v.IsLimited() {
+		q.ipVersionFilter = v
+	}
+	return q
+}
+
+// skipsIPv4 returns whether IPv4 flows can't satisfy the query
+func (q *Query) skipsIPv4() bool {
+	return q.ipVersion == types.IPVersionV6 || q.ipVersionFilter == types.IPVersionV6
+}
+
+// skipsIPv6 returns whether IPv6 flows can't satisfy the query
+func (q *Query) skipsIPv6() bool {
+	return q.ipVersion == types.IPVersionV4 || q.ipVersionFilter == types.IPVersionV4
+}
+
+// TimeWindows restricts the query to the blocks whose timestamps lie within any of the windows, in
+// addition to the time range the work managers are set up with. Day directories outside of all
+// windows aren't read at all
+func (q *Query) TimeWindows(windows types.TimeWindows) *Query {
+	q.timeWindows = windows
+	return q
+}
+
+// coversTimestamp returns whether a block with the given timestamp lies within the time windows
+// of the query (always true if there are none)
+func (q *Query) coversTimestamp(timestamp int64) bool {
+	return len(q.timeWindows) == 0 || q.timeWindows.Contains(timestamp)
+}
+
+// coversDay returns whether any block of the day directory with the given timestamp may lie within
+// the time windows of the query (always true if there are none)
+func (q *Query) coversDay(dayTimestamp int64) bool {
+	return len(q.timeWindows) == 0 || q.timeWindows.Overlaps(dayTimestamp, dayTimestamp+gpfile.EpochDay+DBWriteInterval)
+}
+
+// MaxDropRate excludes the blocks whose capture dropped a larger fraction of the packets (e.g. 0.01 for
+// 1%) from the query. Blocks with an unknown drop rate (written prior to the introduction of the capture
+// quality indicators) aren't excluded
+func (q *Query) MaxDropRate(rate float64) *Query {
+	q.maxDropRate = rate
+	return q
+}
+
+// excludesBlock returns whether a block is excluded due to its capture quality
+func (q *Query) excludesBlock(traffic gpfile.TrafficMetadata) bool {
+	if q.maxDropRate <= 0 {
+		return false
+	}
+	dropRate, known := traffic.DropRate()
+	return known && dropRate > q.maxDropRate
+}
+
+// skipsBlock returns whether none of the flows in a block with the given range of attribute
+// values can satisfy the query (always false if the range is unknown)
+func (q *Query) skipsBlock(blockRange *types.BlockRange) bool {
+	if q.Conditional == nil || blockRange == nil {
+		return false
+	}
+
+	// Only the IP versions actually evaluated by the query are relevant
+	r := *blockRange
+	r.HasV4 = r.HasV4 && !q.skipsIPv4()
+	r.HasV6 = r.HasV6 && !q.skipsIPv6()
+
+	return !q.Conditional.MayMatch(&r)
+}
+
+// Verify enables the verification of all columns of each block of the query, i.e. checks that each
+// of them (including the label columns) holds as many entries as the block according to its metadata.
+// Inconsistent blocks (e.g. due to partial writes) are skipped and reported (see DBWorkManager.GetCorruptions()).
+// Since blocks can't be skipped based on their range of attribute values, verified queries are slower
+func (q *Query) Verify(enable bool) *Query {
+	q.verify = enable
+	return q
+}
+
+// flowDirection returns the direction of a flow (if queried or required to evaluate the conditional)
+func (q *Query) flowDirection(pktsRcvd, pktsSent uint64) types.FlowDirection {
+	if !q.hasCondDir && !q.hasAttrDir {
+		return types.FlowDirectionUnknown
+	}
+	return types.ClassifyDirection(pktsRcvd, pktsSent)
+}
+
 // LowMem enables memory-saving mode
 func (q *Query) LowMem(enable bool) *Query {
 	q.lowMem = enable
@@ -161,6 +359,135 @@ func (q *Query) IsLowMem() bool {
 	return q.lowMem
 }
 
+// ReadAhead sets the number of blocks prefetched from disk while a block is decompressed (or, if the
+// files are read into memory at once, whether the files of the next directory are prefetched while a
+// directory is processed). Values <= 0 disable prefetching
+func (q *Query) ReadAhead(n int) *Query {
+	q.readAhead = max(n, 0)
+	return q
+}
+
+// MaxBlocksPerSec limits the number of blocks read from disk per second (across all interfaces),
+// so that a query doesn't starve other consumers of the disk (e.g. the writeouts of the capture
+// process). Values <= 0 disable the limit
+func (q *Query) MaxBlocksPerSec(n int) *Query {
+	q.readLimiter = nil
+	if n > 0 {
+		q.readLimiter = rate.NewLimiter(rate.Limit(n), 1)
+	}
+	return q
+}
+
+// waitForRead blocks until the next block may be read from disk (or the context is done)
+func (q *Query) waitForRead(ctx context.Context) error {
+	if q.readLimiter == nil {
+		return nil
+	}
+
+	reservation := q.readLimiter.Reserve()
+	delay := reservation.Delay()
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		reservation.Cancel()
+		return ctx.Err()
+	}
+}
+
+// Columns returns the names of the columns read from the DB by the query, i.e. the columns of
+// the queried and conditional attributes along with the counter columns (and the queried label
+// columns, if any)
+func (q *Query) Columns() []string {
+	s := make([]string, len(q.columnIndices), q.numColumns())
+	for i, colIdx := range q.columnIndices {
+		s[i] = types.ColumnFileNames[colIdx]
+	}
+	return append(s, q.labelColumns...)
+}
+
+// numColumns returns the number of columns read from the DB by the query
+func (q *Query) numColumns() int {
+	return len(q.columnIndices) + len(q.labelColumns)
+}
+
+// LabelColumns returns the names of the queried label columns (i.e. the process and / or the label
+// column attributes, in the order of their values returned by Labels())
+func (q *Query) LabelColumns() []string {
+	return q.labelColumns
+}
+
+// Labels returns the values of the queried label columns with the given ID, as stored in the labels
+// extension of the keys of the query results (see types.ExtendedKey.AttrLabels())
+func (q *Query) Labels(id uint32) []string {
+	values := make([]string, len(q.labelColumns))
+	if q.labels != nil {
+		copy(values, strings.Split(q.labels.value(id), labelSep))
+	}
+	return values
+}
+
+// dirLabels maps the IDs stored in the label columns of a GPDir to the query-wide IDs of the
+// (combined) labels. It is used by a single worker at a time
+type dirLabels struct {
+	table  *labelTable
+	values [][]string        // values: the dictionary of each label column (indexed by dictionary ID)
+	ids    map[string]uint32 // ids: the query-wide IDs by the (concatenated) dictionary IDs of an entry
+	buf    []byte
+}
+
+// dirLabels reads the dictionaries of the queried label columns of the GPDir
+func (q *Query) dirLabels(dir *gpfile.GPDir) (*dirLabels, error) {
+	l := &dirLabels{
+		table:  q.labels,
+		values: make([][]string, len(q.labelColumns)),
+		ids:    make(map[string]uint32),
+		buf:    make([]byte, len(q.labelColumns)*gpfile.DictionaryIDWidth),
+	}
+	for i, name := range q.labelColumns {
+		dict, err := dir.Dictionary(name)
+		if err != nil {
+			return nil, err
+		}
+		l.values[i] = make([]string, dict.Len())
+		for id := range l.values[i] {
+			l.values[i][id], _ = dict.Lookup(uint32(id))
+		}
+	}
+	return l, nil
+}
+
+// id returns the query-wide ID of the labels of an entry, given the blocks of the label columns
+// (in the order of the queried label columns). Empty blocks denote unknown labels
+func (l *dirLabels) id(blocks [][]byte, entry int) uint32 {
+	for i, block := range blocks {
+		dictID := uint32(math.MaxUint32)
+		if len(block) > 0 {
+			dictID = binary.BigEndian.Uint32(block[entry*gpfile.DictionaryIDWidth:])
+		}
+		binary.BigEndian.PutUint32(l.buf[i*gpfile.DictionaryIDWidth:], dictID)
+	}
+	if id, exists := l.ids[string(l.buf)]; exists {
+		return id
+	}
+
+	values := make([]string, len(blocks))
+	for i := range blocks {
+		if dictID := binary.BigEndian.Uint32(l.buf[i*gpfile.DictionaryIDWidth:]); int(dictID) < len(l.values[i]) {
+			values[i] = l.values[i][dictID]
+		}
+	}
+	id := l.table.id(strings.Join(values, labelSep))
+	l.ids[string(l.buf)] = id
+	return id
+}
+
 // AttributesToString is a convenience method for translating the query attributes
 // into a human-readable name
 func (q *Query) AttributesToString() []string {
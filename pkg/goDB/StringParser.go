@@ -46,7 +46,7 @@ func NewStringKeyParser(kind string) StringKeyParser {
 		return &DportStringParser{}
 	case types.ProtoName:
 		return &ProtoStringParser{}
-	case "time":
+	case types.TimeName:
 		return &TimeStringParser{}
 	}
 	return &NOPStringParser{}
@@ -55,13 +55,13 @@ func NewStringKeyParser(kind string) StringKeyParser {
 // NewStringValParser selects a string parser based on a supported goDB counter
 func NewStringValParser(kind string) StringValParser {
 	switch kind {
-	case "packets sent":
+	case "packets sent", types.PktsSentName:
 		return &PacketsSentStringParser{}
-	case "data vol. sent":
+	case "data vol. sent", types.BytesSentName:
 		return &BytesSentStringParser{}
-	case "packets received":
+	case "packets received", types.PktsRcvdName:
 		return &PacketsRecStringParser{}
-	case "data vol. received":
+	case "data vol. received", types.BytesRcvdName:
 		return &BytesRecStringParser{}
 	}
 	return &NOPStringParser{}
@@ -0,0 +1,186 @@
+package conditions
+
+import (
+	"github.com/els0r/goProbe/pkg/goDB/protocols"
+	"github.com/els0r/goProbe/pkg/types"
+)
+
+// sugarAttributes denotes the attributes which are only available in conditions (and are desugared
+// into conditions on the registered attributes, see types.AttributeSpec)
+var sugarAttributes = []string{"snet", "dnet", "host", "net"}
+
+// derivedAttributes denotes the attributes which are not stored in a column, but derived from the
+// counters of a flow (when queried, they are reported as labels rather than attributes)
+var derivedAttributes = []string{types.DirectionName}
+
+// IP protocol numbers of ICMP and ICMPv6
+const (
+	protoICMP   = 1
+	protoICMPv6 = 58
+)
+
+// IsICMP returns whether the IP protocol is ICMP or ICMPv6. Flows of these protocols carry neither
+// ports nor (since they aren't stored) ICMP types / codes, hence dport conditions can't be applied
+// to them
+func IsICMP(proto uint8) bool {
+	return proto == protoICMP || proto == protoICMPv6
+}
+
+// Attributes returns all attributes (including their aliases and syntactic sugar) that can be
+// used in a condition. Attributes stored in label columns can't be used in conditions
+func Attributes() []string {
+	var attributes []string
+	for _, spec := range types.AttributeSpecs() {
+		if spec.LabelColumn {
+			continue
+		}
+		attributes = append(attributes, spec.Name)
+		attributes = append(attributes, spec.Aliases...)
+	}
+	attributes = append(attributes, sugarAttributes...)
+	return append(attributes, derivedAttributes...)
+}
+
+// IsAttribute returns whether the token is an attribute (or one of its aliases)
+func IsAttribute(token string) bool {
+	if spec, exists := types.LookupAttribute(token); exists {
+		return !spec.LabelColumn
+	}
+	return contains(sugarAttributes, token) || contains(derivedAttributes, token)
+}
+
+// IsComparator returns whether the token is a comparison operator
+func IsComparator(token string) bool {
+	return contains(types.OrderedComparators, token)
+}
+
+// Comparators returns the comparison operators permitted for an attribute (nil if the attribute
+// is unknown)
+func Comparators(attribute string) []string {
+	if spec, exists := types.LookupAttribute(attribute); exists {
+		return spec.Comparators
+	}
+	if contains(sugarAttributes, attribute) || contains(derivedAttributes, attribute) {
+		return types.EqualityComparators
+	}
+	return nil
+}
+
+// OpenParens returns the number of parentheses opened (and not yet closed) by the tokens
+func OpenParens(tokens []string) int {
+	open := 0
+	for _, token := range tokens {
+		switch token {
+		case "(":
+			open++
+		case ")":
+			open--
+		}
+	}
+	return open
+}
+
+// NextTokens returns the tokens permitted by the condition grammar after the (last two)
+// tokens prevprev and prev, given the number of currently open parentheses. If the next
+// token is a value, only the names of IP protocols (for the proto attribute) and the flow
+// directions (for the dir attribute) are returned, since all other values are free-form
+func NextTokens(prevprev, prev string, openParens int) []string {
+	switch prev {
+	case "", "(", "&", "|":
+		return append([]string{"!", "("}, Attributes()...)
+	case "!":
+		return append([]string{"("}, Attributes()...)
+	case ")":
+		return continuations(openParens)
+	}
+
+	if IsComparator(prev) {
+		if spec, exists := types.LookupAttribute(prevprev); exists && spec.Name == types.ProtoName {
+			var result []string
+			for name := range protocols.IPProtocolIDs {
+				result = append(result, name)
+			}
+			return result
+		}
+		if prevprev == types.DirectionName {
+			return types.FlowDirectionNames()
+		}
+		return nil
+	}
+
+	// prev is a value (which may coincide with the name of an attribute, e.g. "host")
+	if IsComparator(prevprev) {
+		return continuations(openParens)
+	}
+
+	return Comparators(prev)
+}
+
+// continuations returns the tokens that may follow a complete condition
+func continuations(openParens int) []string {
+	if openParens > 0 {
+		return []string{")", "&", "|"}
+	}
+	return []string{"&", "|"}
+}
+
+// maxSuggestionDistance denotes the maximum edit distance for a candidate to be suggested
+const maxSuggestionDistance = 2
+
+// Suggest returns the candidate closest to the (misspelled) token, e.g. "dport" for "dprot".
+// If no candidate is sufficiently close, an empty string is returned
+func Suggest(token string, candidates []string) string {
+	if token == "" {
+		return ""
+	}
+
+	var (
+		best     string
+		bestDist = maxSuggestionDistance + 1
+	)
+	for _, candidate := range candidates {
+		dist := editDistance(token, candidate)
+
+		// the token has to be longer than the distance, otherwise any short token would match
+		if dist < bestDist && dist < len(token) {
+			best, bestDist = candidate, dist
+		}
+	}
+	return best
+}
+
+// editDistance computes the optimal string alignment distance between a and b, i.e. the
+// Levenshtein distance also counting transpositions of adjacent characters as a single edit
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func contains(list []string, token string) bool {
+	for _, item := range list {
+		if item == token {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package conditions
+
+import (
+	"testing"
+
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggest(t *testing.T) {
+	candidates := Attributes()
+
+	var tests = []struct {
+		token    string
+		expected string
+	}{
+		{"dprot", "dport"},
+		{"sipp", "sip"},
+		{"prot", "port"},
+		{"portocol", "protocol"},
+		{"hots", "host"},
+		{"dport", "dport"},
+		{"d", ""},
+		{"xyzabc", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.token, func(t *testing.T) {
+			require.Equal(t, test.expected, Suggest(test.token, candidates))
+		})
+	}
+}
+
+func TestNextTokens(t *testing.T) {
+	var tests = []struct {
+		name       string
+		tokens     []string
+		expected   []string
+		numAtLeast int
+	}{
+		{"start", []string{}, append([]string{"!", "("}, Attributes()...), 0},
+		{"negation", []string{"!"}, append([]string{"("}, Attributes()...), 0},
+		{"ip attribute", []string{"sip"}, types.EqualityComparators, 0},
+		{"ip sugar", []string{"dnet"}, types.EqualityComparators, 0},
+		{"port attribute", []string{"(", "dport"}, types.OrderedComparators, 0},
+		{"protocol alias", []string{"ipproto"}, types.OrderedComparators, 0},
+		{"value", []string{"dport", "=", "80"}, []string{"&", "|"}, 0},
+		{"value in parens", []string{"(", "dport", "=", "80"}, []string{")", "&", "|"}, 0},
+		{"attribute as value", []string{"sip", "=", "host"}, []string{"&", "|"}, 0},
+		{"free-form value", []string{"sip", "="}, nil, 0},
+		{"protocol value", []string{"proto", "="}, nil, 10},
+		{"protocol alias value", []string{"protocol", "="}, nil, 10},
+		{"direction attribute", []string{"dir"}, types.EqualityComparators, 0},
+		{"direction value", []string{"dport", "=", "80", "&", "dir", "!="}, types.FlowDirectionNames(), 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var prevprev, prev string
+			if n := len(test.tokens); n > 0 {
+				prev = test.tokens[n-1]
+				if n > 1 {
+					prevprev = test.tokens[n-2]
+				}
+			}
+
+			next := NextTokens(prevprev, prev, OpenParens(test.tokens))
+			if test.numAtLeast > 0 {
+				require.GreaterOrEqual(t, len(next), test.numAtLeast)
+				return
+			}
+			require.Equal(t, test.expected, next)
+		})
+	}
+}
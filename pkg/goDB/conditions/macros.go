@@ -0,0 +1,122 @@
+package conditions
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	macroPrefix     = "$"
+	macroDefinition = ":="
+	macroComment    = "#"
+)
+
+var (
+	errorInvalidMacro = errors.New("invalid macro definition")
+	errorUnknownMacro = errors.New("unknown macro")
+	errorMacroCycle   = errors.New("macro references itself")
+
+	macroNameRegex      = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+	macroReferenceRegex = regexp.MustCompile(`\$[a-zA-Z_][a-zA-Z0-9_]*`)
+)
+
+// Macros denotes a set of named conditions which can be referenced in a conditional via $name,
+// e.g. "$internal & dport = 443". Names are case-insensitive
+type Macros map[string]string
+
+// LoadMacros reads macro definitions from a file (see ParseMacros for the format)
+func LoadMacros(path string) (Macros, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read condition macros: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	macros, err := ParseMacros(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse condition macros from %s: %w", path, err)
+	}
+	return macros, nil
+}
+
+// ParseMacros reads macro definitions of the form
+//
+//	internal := snet = 10.0.0.0/8 | snet = 192.168.0.0/16
+//
+// one per line. Empty lines and lines starting with "#" are ignored. A macro may reference other
+// macros (regardless of the order of their definitions), as long as it doesn't reference itself
+func ParseMacros(r io.Reader) (Macros, error) {
+	macros := make(Macros)
+
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, macroComment) {
+			continue
+		}
+
+		name, condition, found := strings.Cut(line, macroDefinition)
+		name, condition = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(condition)
+		if !found || condition == "" {
+			return nil, fmt.Errorf("%w in line %d: expected `name := condition`", errorInvalidMacro, lineNum)
+		}
+		if !macroNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("%w in line %d: invalid name `%s`", errorInvalidMacro, lineNum, name)
+		}
+		if _, exists := macros[name]; exists {
+			return nil, fmt.Errorf("%w in line %d: duplicate name `%s`", errorInvalidMacro, lineNum, name)
+		}
+		macros[name] = condition
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// make sure that all macros can be expanded, so that errors surface upon loading
+	for name := range macros {
+		if _, err := macros.Expand(macroPrefix + name); err != nil {
+			return nil, err
+		}
+	}
+	return macros, nil
+}
+
+// Expand replaces all macro references in the conditional by their (bracketed) conditions
+func (m Macros) Expand(conditional string) (string, error) {
+	return m.expand(conditional, nil)
+}
+
+func (m Macros) expand(conditional string, expanding []string) (string, error) {
+	var err error
+	expanded := macroReferenceRegex.ReplaceAllStringFunc(conditional, func(ref string) string {
+		if err != nil {
+			return ref
+		}
+
+		name := strings.ToLower(strings.TrimPrefix(ref, macroPrefix))
+		condition, exists := m[name]
+		if !exists {
+			err = fmt.Errorf("%w `%s`", errorUnknownMacro, ref)
+			return ref
+		}
+		for _, parent := range expanding {
+			if parent == name {
+				err = fmt.Errorf("%w: %s", errorMacroCycle, strings.Join(append(expanding, name), " -> "))
+				return ref
+			}
+		}
+
+		// bracketing the condition retains its precedence in the surrounding conditional
+		condition, err = m.expand(condition, append(expanding, name))
+		return "(" + condition + ")"
+	})
+	return expanded, err
+}
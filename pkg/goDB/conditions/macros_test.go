@@ -0,0 +1,67 @@
+package conditions
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testMacros = `
+# networks
+internal := snet = 10.0.0.0/8 | snet = 192.168.0.0/16
+Web      := dport = 80 or dport = 443
+internal_web := $internal & $WEB
+`
+
+func TestParseMacros(t *testing.T) {
+	macros, err := ParseMacros(strings.NewReader(testMacros))
+	require.Nil(t, err)
+	require.Equal(t, Macros{
+		"internal":     "snet = 10.0.0.0/8 | snet = 192.168.0.0/16",
+		"web":          "dport = 80 or dport = 443",
+		"internal_web": "$internal & $WEB",
+	}, macros)
+
+	for _, def := range []string{
+		"internal = snet = 10.0.0.0/8",
+		"internal :=",
+		"in-ternal := snet = 10.0.0.0/8",
+		"a := dport = 80\na := dport = 443",
+		"a := $b",
+		"a := $b\nb := $a",
+	} {
+		t.Run(def, func(t *testing.T) {
+			_, err := ParseMacros(strings.NewReader(def))
+			require.NotNil(t, err)
+		})
+	}
+}
+
+func TestSanitizeWithMacros(t *testing.T) {
+	macros, err := ParseMacros(strings.NewReader(testMacros))
+	require.Nil(t, err)
+
+	var tests = []struct {
+		input  string
+		output string
+	}{
+		{"dport = 80", "dport = 80"},
+		{"$internal", "(snet = 10.0.0.0/8 | snet = 192.168.0.0/16)"},
+		{"$web & proto = tcp", "(dport = 80|dport = 443) & proto = tcp"},
+		{"not $Internal_Web", "!((snet = 10.0.0.0/8 | snet = 192.168.0.0/16) & (dport = 80|dport = 443))"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			sanitized, err := SanitizeUserInput(test.input, WithMacros(macros))
+			require.Nil(t, err)
+			require.Equal(t, test.output, sanitized)
+		})
+	}
+
+	_, err = SanitizeUserInput("$external", WithMacros(macros))
+	require.ErrorIs(t, err, errorUnknownMacro)
+
+	_, err = SanitizeUserInput("$internal")
+	require.ErrorIs(t, err, errorUnknownMacro)
+}
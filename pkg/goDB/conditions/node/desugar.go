@@ -52,15 +52,12 @@ func desugarConditionNode(node conditionNode) (Node, error) {
 	}
 
 	// map aliases to proper attribute names
+	if spec, exists := types.LookupAttribute(node.attribute); exists {
+		node.attribute = spec.Name
+		return node, nil
+	}
+
 	switch node.attribute {
-	case "src":
-		node.attribute = types.SIPName
-	case "dst":
-		node.attribute = types.DIPName
-	case "port":
-		node.attribute = types.DportName
-	case "ipproto", "protocol":
-		node.attribute = types.ProtoName
 	case "host":
 		return helper("host", types.SIPName, types.DIPName, node.comparator, node.value)
 	case "net":
@@ -0,0 +1,69 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/els0r/goProbe/pkg/goDB/conditions"
+	"github.com/els0r/goProbe/pkg/types"
+)
+
+var errICMPPortCondition = errors.New("dport conditions cannot be applied to ICMP / ICMPv6 flows since they carry no ports (nor are their types / codes stored)")
+
+// checkICMP rejects conditionals in which a dport condition is conjoined with a condition
+// restricting the flows to ICMP / ICMPv6 (e.g. "proto = icmp & dport = 8"). Since the dport of
+// such flows is always zero, the dport condition would either match all or none of them instead
+// of being interpreted as ICMP type / code. Must be called on a conditional in negation normal form
+func checkICMP(node Node) error {
+	var helper func(node Node, icmp bool) error
+	helper = func(node Node, icmp bool) error {
+		switch node := node.(type) {
+		case conditionNode:
+			if icmp && node.attribute == types.DportName {
+				return fmt.Errorf("%w: %s", errICMPPortCondition, node)
+			}
+		case andNode:
+			conjuncts := flattenAnd(node)
+			for _, conjunct := range conjuncts {
+				icmp = icmp || isICMPCondition(conjunct)
+			}
+			for _, conjunct := range conjuncts {
+				if err := helper(conjunct, icmp); err != nil {
+					return err
+				}
+			}
+		case orNode:
+			if err := helper(node.left, icmp); err != nil {
+				return err
+			}
+			return helper(node.right, icmp)
+		case notNode:
+			return helper(node.node, icmp)
+		}
+		return nil
+	}
+	return helper(node, false)
+}
+
+// flattenAnd returns the operands of a chain of conjunctions, e.g. [a, b, c] for "(a & b) & c"
+func flattenAnd(node Node) []Node {
+	if and, isAnd := node.(andNode); isAnd {
+		return append(flattenAnd(and.left), flattenAnd(and.right)...)
+	}
+	return []Node{node}
+}
+
+// isICMPCondition returns whether the node is a condition restricting the flows to ICMP / ICMPv6
+func isICMPCondition(node Node) bool {
+	condition, isCondition := node.(conditionNode)
+	if !isCondition || condition.attribute != types.ProtoName || condition.comparator != "=" {
+		return false
+	}
+
+	// invalid values are reported upon instrumentation
+	value, _, _, err := conditionBytesAndNetmask(condition)
+	if err != nil {
+		return false
+	}
+	return conditions.IsICMP(value[0])
+}
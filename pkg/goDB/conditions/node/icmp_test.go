@@ -0,0 +1,39 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckICMP(t *testing.T) {
+	var tests = []struct {
+		conditional string
+		valid       bool
+	}{
+		{"proto = icmp", true},
+		{"dport = 8", true},
+		{"proto = tcp & dport = 443", true},
+		{"proto != icmp & dport = 443", true},
+		{"proto = icmp | dport = 443", true},
+		{"(proto = icmp & sip = 10.0.0.1) | (proto = udp & dport = 53)", true},
+		{"proto = icmp & dport = 8", false},
+		{"proto = 1 & dport = 0", false},
+		{"proto = ipv6-icmp & dport > 0", false},
+		{"dport = 8 & sip = 10.0.0.1 & proto = icmp", false},
+		{"proto = icmp & (dport = 0 | dport = 8)", false},
+		{"!(proto != icmp | dport != 8)", false},
+		{"sip = 10.0.0.1 & (proto = udp | (proto = icmp & dport = 3))", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.conditional, func(t *testing.T) {
+			_, err := ParseAndInstrument(test.conditional, 0)
+			if test.valid {
+				require.Nil(t, err)
+			} else {
+				require.ErrorIs(t, err, errICMPPortCondition)
+			}
+		})
+	}
+}
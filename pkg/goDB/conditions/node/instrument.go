@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -32,7 +33,10 @@ import (
 // evaluation.
 func instrument(node Node) (Node, error) {
 	return node.transform(func(cn conditionNode) (Node, error) {
-		err := generateCompareValue(&cn)
+		if err := generateCompareValue(&cn); err != nil {
+			return cn, err
+		}
+		err := generateMayMatch(&cn)
 		return cn, err
 	})
 }
@@ -43,6 +47,12 @@ func instrument(node Node) (Node, error) {
 // and then never change throughout program execution. This reduces branching
 // during query evaluation.
 func generateCompareValue(condition *conditionNode) error {
+
+	// the flow direction is not part of the flow key (but derived from its counters)
+	if condition.attribute == types.DirectionName {
+		return generateCompareDirection(condition)
+	}
+
 	var (
 		value     []byte
 		netmask   int
@@ -92,122 +102,10 @@ func generateCompareValue(condition *conditionNode) error {
 		}
 	case "snet":
 		condition.ipVersion = ipVersion
-		// in case of matching networks, only the relevant bytes (e.g. those
-		// at which the netmask is non-zero) have to be checked. This form of
-		// lazy checking can be applied to both IPv4 and IPv6 networks
-		var lenBytes int
-		index := netmask / 8
-		toShift := uint8(8 - netmask%8) // number of zeros in netmask
-
-		// check if the netmask does not describe an RFC network class
-		if toShift != 8 {
-			// calculate relevant portion of netmask
-			netmaskByte := uint8(0xff) << toShift
-			lenBytes = index + 1
-
-			// handle comparator operator. For IP based checks only EQUALS TO and
-			// NOT EQUALS TO makes sense
-			switch condition.comparator {
-			case "=":
-				condition.compareValue = func(currentValue types.Key) bool {
-					ip := currentValue
-					// apply the netmask on the relevant byte in order to obtain
-					// the network address
-					ip[index] = ip[index] & netmaskByte
-
-					return bytes.Equal(ip.GetSIP()[:lenBytes], value[:lenBytes])
-				}
-				return nil
-			case "!=":
-				condition.compareValue = func(currentValue types.Key) bool {
-					ip := currentValue
-					// apply the netmask on the relevant byte in order to obtain
-					// the network address
-					ip[index] = ip[index] & netmaskByte
-
-					return !bytes.Equal(ip.GetSIP()[:lenBytes], value[:lenBytes])
-				}
-				return nil
-			default:
-				return fmt.Errorf("comparator %q not allowed for attribute %q", condition.comparator, condition.attribute)
-			}
-		} else {
-			// in case we have a multiple of 8, some bytes are left out of the
-			// comparison
-			switch condition.comparator {
-			case "=":
-				condition.compareValue = func(currentValue types.Key) bool {
-					return bytes.Equal(currentValue.GetSIP()[:index], value[:index])
-				}
-				return nil
-			case "!=":
-				condition.compareValue = func(currentValue types.Key) bool {
-					return !bytes.Equal(currentValue.GetSIP()[:index], value[:index])
-				}
-				return nil
-			default:
-				return fmt.Errorf("comparator %q not allowed for attribute %q", condition.comparator, condition.attribute)
-			}
-		}
+		return generateCompareNetwork(condition, types.Key.GetSIP, value, netmask)
 	case "dnet":
 		condition.ipVersion = ipVersion
-		// in case of matching networks, only the relevant bytes (e.g. those
-		// at which the netmask is non-zero) have to be checked. This form of
-		// lazy checking can be applied to both IPv4 and IPv6 networks
-		var lenBytes int
-		index := netmask / 8
-		toShift := uint8(8 - netmask%8) // number of zeros in netmask
-
-		// check if the netmask does not describe an RFC network class
-		if toShift != 8 {
-			// calculate relevant portion of netmask
-			netmaskByte := uint8(0xff) << toShift
-			lenBytes = index + 1
-
-			// handle comparator operator. For IP based checks only EQUALS TO and
-			// NOT EQUALS TO makes sense
-			switch condition.comparator {
-			case "=":
-				condition.compareValue = func(currentValue types.Key) bool {
-					ip := currentValue
-					// apply the netmask on the relevant byte in order to obtain
-					// the network address
-					ip[index] = ip[index] & netmaskByte
-
-					return bytes.Equal(ip.GetDIP()[:lenBytes], value[:lenBytes])
-				}
-				return nil
-			case "!=":
-				condition.compareValue = func(currentValue types.Key) bool {
-					ip := currentValue
-					// apply the netmask on the relevant byte in order to obtain
-					// the network address
-					ip[index] = ip[index] & netmaskByte
-
-					return !bytes.Equal(ip.GetDIP()[:lenBytes], value[:lenBytes])
-				}
-				return nil
-			default:
-				return fmt.Errorf("comparator %q not allowed for attribute %q", condition.comparator, condition.attribute)
-			}
-		} else {
-			// in case we have a multiple of 8, some bytes are left out of the
-			// comparison
-			switch condition.comparator {
-			case "=":
-				condition.compareValue = func(currentValue types.Key) bool {
-					return bytes.Equal(currentValue.GetDIP()[:index], value[:index])
-				}
-				return nil
-			case "!=":
-				condition.compareValue = func(currentValue types.Key) bool {
-					return !bytes.Equal(currentValue.GetDIP()[:index], value[:index])
-				}
-				return nil
-			default:
-				return fmt.Errorf("comparator %q not allowed for attribute %q", condition.comparator, condition.attribute)
-			}
-		}
+		return generateCompareNetwork(condition, types.Key.GetDIP, value, netmask)
 	case types.DportName:
 		switch condition.comparator {
 		case "=":
@@ -279,8 +177,116 @@ func generateCompareValue(condition *conditionNode) error {
 			return fmt.Errorf("comparator %q not allowed for attribute %q", condition.comparator, condition.attribute)
 		}
 	default:
+		return generateGenericCompareValue(condition, value)
+	}
+}
+
+// Generates a comparison closure for the flow direction
+func generateCompareDirection(condition *conditionNode) error {
+	var matches func(types.FlowDirection) bool
+	switch condition.value {
+	case types.FlowDirectionInName:
+		matches = func(dir types.FlowDirection) bool { return dir == types.FlowDirectionIn }
+	case types.FlowDirectionOutName:
+		matches = func(dir types.FlowDirection) bool { return dir == types.FlowDirectionOut }
+	case types.FlowDirectionUniName:
+		matches = types.FlowDirection.IsUnidirectional
+	case types.FlowDirectionBiName:
+		matches = func(dir types.FlowDirection) bool { return dir == types.FlowDirectionBi }
+	default:
+		return fmt.Errorf("invalid direction %q. Supported values: %s", condition.value, strings.Join(types.FlowDirectionNames(), ", "))
+	}
+
+	switch condition.comparator {
+	case "=":
+		condition.compareDir = matches
+	case "!=":
+		condition.compareDir = func(dir types.FlowDirection) bool { return !matches(dir) }
+	default:
+		return fmt.Errorf("comparator %q not allowed for attribute %q", condition.comparator, condition.attribute)
+	}
+	return nil
+}
+
+// Generates a comparison closure for any registered attribute without a dedicated
+// (optimized) implementation above, based on the attribute's spec
+func generateGenericCompareValue(condition *conditionNode, value []byte) error {
+	spec, exists := types.LookupAttribute(condition.attribute)
+	if !exists {
 		return fmt.Errorf("unknown attribute %q", condition.attribute)
 	}
+
+	var cmp func(int) bool
+	switch condition.comparator {
+	case "=":
+		cmp = func(c int) bool { return c == 0 }
+	case "!=":
+		cmp = func(c int) bool { return c != 0 }
+	case "<":
+		cmp = func(c int) bool { return c < 0 }
+	case ">":
+		cmp = func(c int) bool { return c > 0 }
+	case "<=":
+		cmp = func(c int) bool { return c <= 0 }
+	case ">=":
+		cmp = func(c int) bool { return c >= 0 }
+	}
+	if cmp == nil || !slices.Contains(spec.Comparators, condition.comparator) {
+		return fmt.Errorf("comparator %q not allowed for attribute %q", condition.comparator, condition.attribute)
+	}
+
+	extract := spec.Extract
+	condition.compareValue = func(currentValue types.Key) bool {
+		return cmp(bytes.Compare(extract(currentValue), value))
+	}
+	return nil
+}
+
+// Generates the comparison closure for network conditions (snet / dnet). Only the
+// relevant bytes (e.g. those at which the netmask is non-zero) have to be checked,
+// with the netmask being applied to the last one if the prefix length is not a multiple
+// of eight (e.g. 10.0.0.0/22 or 2001:db8::/29). This form of lazy checking applies to
+// both IPv4 and IPv6 networks. Addresses of the other IP version never match
+func generateCompareNetwork(condition *conditionNode, getIP func(types.Key) []byte, value []byte, netmask int) error {
+	var (
+		index       = netmask / 8
+		netmaskByte = uint8(0xff) << uint8(8-netmask%8)
+		ipLen       = len(value)
+	)
+
+	var contains func(ip []byte) bool
+	if netmask%8 == 0 {
+		// in case we have a multiple of 8, the remaining bytes are left out of the
+		// comparison
+		contains = func(ip []byte) bool {
+			return len(ip) == ipLen && bytes.Equal(ip[:index], value[:index])
+		}
+	} else {
+		// apply the netmask on the relevant byte in order to obtain the network address
+		// (without modifying the key itself)
+		contains = func(ip []byte) bool {
+			return len(ip) == ipLen &&
+				ip[index]&netmaskByte == value[index] &&
+				bytes.Equal(ip[:index], value[:index])
+		}
+	}
+
+	// handle comparator operator. For IP based checks only EQUALS TO and
+	// NOT EQUALS TO makes sense
+	switch condition.comparator {
+	case "=":
+		condition.compareValue = func(currentValue types.Key) bool {
+			return contains(getIP(currentValue))
+		}
+		return nil
+	case "!=":
+		condition.compareValue = func(currentValue types.Key) bool {
+			return !contains(getIP(currentValue))
+		}
+		return nil
+	default:
+		return fmt.Errorf("comparator %q not allowed for attribute %q", condition.comparator, condition.attribute)
+	}
 }
 
 // conditionBytesAndNetmask returns the database's binary representation of the
@@ -386,7 +392,13 @@ func conditionBytesAndNetmask(condition conditionNode) ([]byte, int, types.IPVer
 
 			condBytes = []byte{uint8(num >> 8), uint8(num & 0xff)}
 		default:
-			return nil, 0, types.IPVersionNone, fmt.Errorf("unknown attribute: %s", attribute)
+			spec, exists := types.LookupAttribute(attribute)
+			if !exists {
+				return nil, 0, types.IPVersionNone, fmt.Errorf("unknown attribute: %s", attribute)
+			}
+			if condBytes, err = spec.Parse(value); err != nil {
+				return nil, 0, types.IPVersionNone, fmt.Errorf("could not parse %s value: %w", attribute, err)
+			}
 		}
 	default:
 		return nil, 0, types.IPVersionNone, fmt.Errorf("unknown comparator: %s", comparator)
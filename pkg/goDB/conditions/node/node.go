@@ -55,14 +55,34 @@ func ParseAndInstrument(conditional string, dnsTimeout time.Duration) (Node, err
 
 		conditionalNode = negationNormalForm(conditionalNode)
 
+		if err = checkICMP(conditionalNode); err != nil {
+			return nil, err
+		}
+
 		if conditionalNode, err = instrument(conditionalNode); err != nil {
 			return nil, err
 		}
+
+		// the folded tree is only used for evaluation: a conditional folding to a constant is still
+		// reported as provided (see constNode.String())
+		original := conditionalNode.String()
+		conditionalNode = optimize(conditionalNode)
+		if constant, isConst := conditionalNode.(constNode); isConst {
+			constant.condition = original
+			conditionalNode = constant
+		}
 	}
 
 	return conditionalNode, nil
 }
 
+// AlwaysSatisfied returns whether the conditional is satisfied by any flow without limiting the IP
+// versions queried, i.e. whether it is equivalent to no conditional at all
+func AlwaysSatisfied(n Node) bool {
+	constant, isConst := n.(constNode)
+	return isConst && constant.value && !constant.limitsIPVersion()
+}
+
 // Node describes an AST node for the conditional grammar
 // This interface is not meant to be implemented by structs
 // outside of this package.
@@ -75,12 +95,18 @@ type Node interface {
 	// to the caller.
 	transform(func(conditionNode) (Node, error)) (Node, error)
 
-	// Evaluates the conditional. Make sure that you called
-	// instrument before calling this.
-	Evaluate(types.Key) bool
+	// Evaluates the conditional for a flow (given by its key and its
+	// direction). Make sure that you called instrument before calling this.
+	Evaluate(types.Key, types.FlowDirection) bool
 
 	// Returns the set of attributes used in the conditional.
 	Attributes() map[string]types.IPVersion
+
+	// Determines whether the conditional can match any flow of a block
+	// with the given range of attribute values. If false is returned, the
+	// block can be skipped entirely. Make sure that you called instrument
+	// before calling this.
+	MayMatch(*types.BlockRange) bool
 }
 
 type conditionNode struct {
@@ -90,10 +116,12 @@ type conditionNode struct {
 	ipVersion    types.IPVersion
 	currentValue []byte
 	compareValue func(types.Key) bool
+	compareDir   func(types.FlowDirection) bool
+	mayMatch     func(*types.BlockRange) bool
 }
 
 func newConditionNode(attribute, comparator, value string) conditionNode {
-	return conditionNode{attribute, comparator, value, types.IPVersionNone, nil, nil}
+	return conditionNode{attribute, comparator, value, types.IPVersionNone, nil, nil, nil, nil}
 }
 func (n conditionNode) String() string {
 	return fmt.Sprintf("%s %s %s", n.attribute, n.comparator, n.value)
@@ -105,12 +133,24 @@ func (n conditionNode) desugar() (Node, error) {
 	return desugarConditionNode(n)
 }
 func (n conditionNode) instrument() (Node, error) {
-	err := generateCompareValue(&n)
+	if err := generateCompareValue(&n); err != nil {
+		return n, err
+	}
+	err := generateMayMatch(&n)
 	return n, err
 }
-func (n conditionNode) Evaluate(comparisonValue types.Key) bool {
+func (n conditionNode) Evaluate(comparisonValue types.Key, dir types.FlowDirection) bool {
+	if n.compareDir != nil {
+		return n.compareDir(dir)
+	}
 	return n.compareValue(comparisonValue)
 }
+func (n conditionNode) MayMatch(r *types.BlockRange) bool {
+	if n.mayMatch == nil {
+		return true
+	}
+	return n.mayMatch(r)
+}
 func (n conditionNode) Attributes() map[string]types.IPVersion {
 	return map[string]types.IPVersion{
 		n.attribute: n.ipVersion,
@@ -135,8 +175,12 @@ func (n notNode) transform(transformer func(conditionNode) (Node, error)) (Node,
 	n.node, err = n.node.transform(transformer)
 	return n, err
 }
-func (n notNode) Evaluate(comparisonValue types.Key) bool {
-	return !n.node.Evaluate(comparisonValue)
+func (n notNode) Evaluate(comparisonValue types.Key, dir types.FlowDirection) bool {
+	return !n.node.Evaluate(comparisonValue, dir)
+}
+func (n notNode) MayMatch(_ *types.BlockRange) bool {
+	// the negation of a (conservative) estimate cannot be used to rule out a match
+	return true
 }
 func (n notNode) Attributes() map[string]types.IPVersion {
 	return n.node.Attributes()
@@ -170,8 +214,11 @@ func (n andNode) transform(transformer func(conditionNode) (Node, error)) (Node,
 	n.right, err = n.right.transform(transformer)
 	return n, err
 }
-func (n andNode) Evaluate(comparisonValue types.Key) bool {
-	return n.left.Evaluate(comparisonValue) && n.right.Evaluate(comparisonValue)
+func (n andNode) Evaluate(comparisonValue types.Key, dir types.FlowDirection) bool {
+	return n.left.Evaluate(comparisonValue, dir) && n.right.Evaluate(comparisonValue, dir)
+}
+func (n andNode) MayMatch(r *types.BlockRange) bool {
+	return n.left.MayMatch(r) && n.right.MayMatch(r)
 }
 func (n andNode) Attributes() map[string]types.IPVersion {
 	result := n.left.Attributes()
@@ -210,8 +257,12 @@ func (n orNode) transform(transformer func(conditionNode) (Node, error)) (Node,
 	return n, err
 }
 
-func (n orNode) Evaluate(comparisonValue types.Key) bool {
-	return n.left.Evaluate(comparisonValue) || n.right.Evaluate(comparisonValue)
+func (n orNode) Evaluate(comparisonValue types.Key, dir types.FlowDirection) bool {
+	return n.left.Evaluate(comparisonValue, dir) || n.right.Evaluate(comparisonValue, dir)
+}
+
+func (n orNode) MayMatch(r *types.BlockRange) bool {
+	return n.left.MayMatch(r) || n.right.MayMatch(r)
 }
 
 func (n orNode) Attributes() map[string]types.IPVersion {
@@ -15,6 +15,9 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/stretchr/testify/require"
 )
 
 var negationNormalFormTests = []struct {
@@ -106,3 +109,93 @@ func TestListToTree(t *testing.T) {
 		}
 	}
 }
+
+func TestEvaluateDirection(t *testing.T) {
+	key := types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6)
+
+	var tests = []struct {
+		conditional string
+		matching    []types.FlowDirection
+	}{
+		{"dir = in", []types.FlowDirection{types.FlowDirectionIn}},
+		{"dir = out", []types.FlowDirection{types.FlowDirectionOut}},
+		{"dir = uni", []types.FlowDirection{types.FlowDirectionIn, types.FlowDirectionOut}},
+		{"dir = bi", []types.FlowDirection{types.FlowDirectionBi}},
+		{"dir != bi", []types.FlowDirection{types.FlowDirectionUnknown, types.FlowDirectionIn, types.FlowDirectionOut}},
+		{"!(dir = in | dir = out)", []types.FlowDirection{types.FlowDirectionUnknown, types.FlowDirectionBi}},
+		{"dir = in & dport = 80", []types.FlowDirection{types.FlowDirectionIn}},
+		{"dir = in & dport = 443", nil},
+		{"dir = out | dport = 80", []types.FlowDirection{types.FlowDirectionUnknown, types.FlowDirectionIn, types.FlowDirectionOut, types.FlowDirectionBi}},
+	}
+	for _, test := range tests {
+		t.Run(test.conditional, func(t *testing.T) {
+			node, err := ParseAndInstrument(test.conditional, 0)
+			require.Nil(t, err)
+
+			var matching []types.FlowDirection
+			for _, dir := range []types.FlowDirection{types.FlowDirectionUnknown, types.FlowDirectionIn, types.FlowDirectionOut, types.FlowDirectionBi} {
+				if node.Evaluate(key, dir) {
+					matching = append(matching, dir)
+				}
+			}
+			require.Equal(t, test.matching, matching)
+		})
+	}
+
+	for _, conditional := range []string{"dir = sideways", "dir < in"} {
+		_, err := ParseAndInstrument(conditional, 0)
+		require.NotNil(t, err, conditional)
+	}
+}
+
+func TestEvaluateNetwork(t *testing.T) {
+	var (
+		v4Key = types.NewV4KeyStatic([4]byte{10, 0, 3, 1}, [4]byte{192, 168, 7, 200}, []byte{0, 80}, 6)
+		v6Key = types.NewV6KeyStatic(
+			[16]byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+			[16]byte{0x20, 0x01, 0x0d, 0xbf, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2},
+			[]byte{0, 80}, 6,
+		)
+	)
+
+	var tests = []struct {
+		conditional string
+		matchV4     bool
+		matchV6     bool
+	}{
+		{"snet = 10.0.0.0/22", true, false},
+		{"snet = 10.0.4.0/22", false, false},
+		{"snet != 10.0.4.0/22", true, true},
+		{"dnet = 192.168.4.0/22", true, false},
+		{"dnet = 192.168.0.0/22", false, false},
+		{"dnet = 192.168.7.192/26", true, false},
+		{"dnet = 192.168.7.128/26", false, false},
+		{"dnet != 192.168.0.0/22", true, true},
+		{"snet = 10.0.0.0/8", true, false},
+		{"snet = 0.0.0.0/0", true, false},
+		{"dnet = 192.168.7.200/32", true, false},
+		{"snet = 2001:db8::/29", false, true},
+		{"dnet = 2001:db8::/29", false, true},
+		{"dnet = 2001:db8::/32", false, false},
+		{"dnet = 2001:dbf:ffff::/47", false, true},
+		{"dnet = 2001:dbf:ff00::/47", false, false},
+		{"dnet != 2001:db8::/29", true, false},
+		{"snet = 2001:db8::1/128", false, true},
+		{"snet = ::/0", false, true},
+		{"snet = 10.0.0.0/22 | dnet = 2001:db8::/29", true, true},
+	}
+	for _, test := range tests {
+		t.Run(test.conditional, func(t *testing.T) {
+			node, err := ParseAndInstrument(test.conditional, 0)
+			require.Nil(t, err)
+
+			v4KeyBefore, v6KeyBefore := v4Key.Clone(), v6Key.Clone()
+			require.Equal(t, test.matchV4, node.Evaluate(v4Key, types.FlowDirectionUnknown), "IPv4 key")
+			require.Equal(t, test.matchV6, node.Evaluate(v6Key, types.FlowDirectionUnknown), "IPv6 key")
+
+			// evaluation must not modify the key
+			require.Equal(t, v4KeyBefore, v4Key)
+			require.Equal(t, v6KeyBefore, v6Key)
+		})
+	}
+}
@@ -0,0 +1,447 @@
+package node
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/els0r/goProbe/pkg/types"
+)
+
+// minSetSize denotes the minimum number of equality conditions on the same attribute within a
+// disjunction (or inequality conditions within a conjunction) which are merged into a set lookup
+const minSetSize = 4
+
+// optimize returns a logically equivalent version of an instrumented conditional which is cheaper
+// to evaluate. This is mostly relevant for machine-generated conditionals (e.g. long lists of
+// hosts or ports). Chains of conjunctions / disjunctions are flattened and
+//   - duplicate operands are removed (e.g. "dport = 80 | dport = 80")
+//   - tautologies and contradictions are folded into constants (e.g. "dport = 80 & dport = 443")
+//   - four or more equalities on the same attribute are merged into a set lookup (e.g.
+//     "dport = 80 | dport = 443 | ...", or "dport != 80 & dport != 443 & ..." respectively)
+//   - cheap checks (e.g. proto, dport) are moved before expensive ones (e.g. IPs, networks)
+//
+// The IP versions the query is limited to by the attributes of the conditional are retained. Must
+// be called on an instrumented conditional in negation normal form
+func optimize(node Node) Node {
+	switch node := node.(type) {
+	case conditionNode:
+		if folded, isConst := foldCondition(node); isConst {
+			return folded
+		}
+	case andNode:
+		return optimizeChain(true, flattenAnd(node))
+	case orNode:
+		return optimizeChain(false, flattenOr(node))
+	}
+	return node
+}
+
+// optimizeChain optimizes the operands of a chain of conjunctions (and = true) or disjunctions
+func optimizeChain(and bool, operands []Node) Node {
+	var flattened []Node
+	for _, operand := range operands {
+		operand = optimize(operand)
+		if and {
+			flattened = append(flattened, flattenAnd(operand)...)
+		} else {
+			flattened = append(flattened, flattenOr(operand)...)
+		}
+	}
+
+	// the constant deciding the chain on its own (false for conjunctions, true for disjunctions)
+	decisive := constNode{value: !and, attributes: mergeAttributes(flattened)}
+
+	var (
+		result  []Node
+		neutral *constNode
+		seen    = make(map[string]struct{})
+	)
+	for _, operand := range flattened {
+		if constant, isConst := operand.(constNode); isConst {
+			if constant.value == decisive.value {
+				return decisive
+			}
+			if neutral == nil {
+				neutral = &constNode{value: constant.value}
+			}
+			neutral.attributes = mergeAttributeMaps(neutral.attributes, constant.attributes)
+			continue
+		}
+
+		key := operand.String()
+		if condition, isCondition := operand.(conditionNode); isCondition {
+			key = conditionKey(condition)
+		}
+		if _, exists := seen[key]; exists {
+			continue
+		}
+		seen[key] = struct{}{}
+		result = append(result, operand)
+	}
+
+	if isDecided(and, result) {
+		return decisive
+	}
+
+	// the neutral constants can't affect the outcome of the chain, but may limit the IP versions
+	if neutral != nil && (len(result) == 0 || neutral.limitsIPVersion()) {
+		result = append(result, *neutral)
+	}
+
+	result = mergeSets(and, result)
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return cost(result[i]) < cost(result[j])
+	})
+
+	return listToTree(and, result)
+}
+
+// isDecided checks if the conditions of a chain of conjunctions (and = true) or disjunctions
+// contradict each other (e.g. "dport = 80 & dport = 443") or form a tautology (e.g. "dport != 80 |
+// dport != 443"), respectively
+func isDecided(and bool, operands []Node) bool {
+
+	// the comparator of which two conditions with distinct values on the same attribute decide the chain
+	exclusive := "="
+	if !and {
+		exclusive = "!="
+	}
+
+	values := make(map[string]map[string]string) // attribute -> value -> comparator
+	for _, operand := range operands {
+		condition, isCondition := operand.(conditionNode)
+		if !isCondition || !isExactAttribute(condition.attribute) ||
+			(condition.comparator != "=" && condition.comparator != "!=") {
+			continue
+		}
+
+		attributeValues, exists := values[condition.attribute]
+		if !exists {
+			attributeValues = make(map[string]string)
+			values[condition.attribute] = attributeValues
+		}
+
+		value := conditionValue(condition)
+		if comparator, exists := attributeValues[value]; exists && comparator != condition.comparator {
+			return true
+		}
+		if condition.comparator == exclusive {
+			for other, comparator := range attributeValues {
+				if other != value && comparator == exclusive {
+					return true
+				}
+			}
+		}
+		attributeValues[value] = condition.comparator
+	}
+	return false
+}
+
+// mergeSets replaces the equalities (inequalities for conjunctions) on the same attribute in a
+// chain by a set lookup if there are at least minSetSize of them
+func mergeSets(and bool, operands []Node) []Node {
+	comparator := "="
+	if and {
+		comparator = "!="
+	}
+
+	members := make(map[string][]conditionNode)
+	for _, operand := range operands {
+		if condition, isCondition := operand.(conditionNode); isCondition && isSetCondition(condition, comparator) {
+			members[condition.attribute] = append(members[condition.attribute], condition)
+		}
+	}
+
+	sets := make(map[string]Node)
+	for attribute, conditions := range members {
+		if len(conditions) < minSetSize {
+			continue
+		}
+		if set, err := newSetNode(and, conditions); err == nil {
+			sets[attribute] = set
+		}
+	}
+
+	result := make([]Node, 0, len(operands))
+	for _, operand := range operands {
+		condition, isCondition := operand.(conditionNode)
+		if !isCondition || !isSetCondition(condition, comparator) {
+			result = append(result, operand)
+			continue
+		}
+		set, exists := sets[condition.attribute]
+		if !exists {
+			result = append(result, operand)
+			continue
+		}
+
+		// the set takes the place of its first member
+		if set != nil {
+			result = append(result, set)
+			sets[condition.attribute] = nil
+		}
+	}
+	return result
+}
+
+func isSetCondition(condition conditionNode, comparator string) bool {
+	return condition.comparator == comparator && isExactAttribute(condition.attribute)
+}
+
+// isExactAttribute returns whether the conditions on an attribute compare its values byte by byte
+// (as opposed to e.g. networks or flow directions)
+func isExactAttribute(attribute string) bool {
+	switch attribute {
+	case types.DirectionName, "snet", "dnet":
+		return false
+	}
+	return true
+}
+
+// conditionValue returns the canonical representation of the value of a condition, e.g. in order
+// to tell that "dport = 80" and "dport = 080" are equivalent
+func conditionValue(condition conditionNode) string {
+	if condition.attribute == types.DirectionName {
+		return condition.value
+	}
+	value, netmask, _, err := conditionBytesAndNetmask(condition)
+	if err != nil {
+		return condition.value
+	}
+	return fmt.Sprintf("%x/%d", value, netmask)
+}
+
+// conditionKey identifies equivalent conditions
+func conditionKey(condition conditionNode) string {
+	return condition.attribute + " " + condition.comparator + " " + conditionValue(condition)
+}
+
+// foldCondition folds conditions comparing against the smallest / largest value of an attribute
+// (e.g. "dport >= 0" or "proto > 255") into a constant
+func foldCondition(condition conditionNode) (constNode, bool) {
+	switch condition.comparator {
+	case "<", ">", "<=", ">=":
+	default:
+		return constNode{}, false
+	}
+
+	value, _, _, err := conditionBytesAndNetmask(condition)
+	if err != nil || len(value) == 0 {
+		return constNode{}, false
+	}
+	if condition.attribute == types.DportName {
+		value = value[:types.DPortWidth]
+	}
+
+	folded := constNode{attributes: condition.Attributes()}
+	switch {
+	case isFilled(value, 0x00) && (condition.comparator == "<" || condition.comparator == ">="):
+		folded.value = condition.comparator == ">="
+	case isFilled(value, 0xff) && (condition.comparator == ">" || condition.comparator == "<="):
+		folded.value = condition.comparator == "<="
+	default:
+		return constNode{}, false
+	}
+	return folded, true
+}
+
+func isFilled(value []byte, b byte) bool {
+	for _, v := range value {
+		if v != b {
+			return false
+		}
+	}
+	return true
+}
+
+// cost estimates the relative cost of evaluating a node
+func cost(node Node) int {
+	switch node := node.(type) {
+	case conditionNode:
+		return attributeCost(node.attribute)
+	case setNode:
+		return attributeCost(node.attribute) + 1
+	case notNode:
+		return cost(node.node)
+	case andNode:
+		return cost(node.left) + cost(node.right)
+	case orNode:
+		return cost(node.left) + cost(node.right)
+	}
+	return 0
+}
+
+func attributeCost(attribute string) int {
+	switch attribute {
+	case types.DirectionName, types.ProtoName:
+		return 1
+	case types.DportName:
+		return 2
+	case types.SIPName, types.DIPName:
+		return 4
+	case "snet", "dnet":
+		return 5
+	}
+	return 3
+}
+
+// flattenOr returns the operands of a chain of disjunctions, e.g. [a, b, c] for "(a | b) | c"
+func flattenOr(node Node) []Node {
+	if or, isOr := node.(orNode); isOr {
+		return append(flattenOr(or.left), flattenOr(or.right)...)
+	}
+	return []Node{node}
+}
+
+func mergeAttributes(nodes []Node) map[string]types.IPVersion {
+	result := make(map[string]types.IPVersion)
+	for _, node := range nodes {
+		result = mergeAttributeMaps(result, node.Attributes())
+	}
+	return result
+}
+
+func mergeAttributeMaps(result, attributes map[string]types.IPVersion) map[string]types.IPVersion {
+	if result == nil {
+		result = make(map[string]types.IPVersion, len(attributes))
+	}
+	for attribute, ipVersion := range attributes {
+		result[attribute] = result[attribute].Merge(ipVersion)
+	}
+	return result
+}
+
+// constNode is a conditional which is always (or never) satisfied, resulting from folding a
+// tautology / contradiction. It retains the attributes of the folded conditions, since they
+// determine the IP versions the query is limited to. If the whole conditional was folded, the
+// constant represents it as the original conditional
+type constNode struct {
+	value      bool
+	attributes map[string]types.IPVersion
+	condition  string
+}
+
+func (n constNode) String() string {
+	if n.condition != "" {
+		return n.condition
+	}
+	return strconv.FormatBool(n.value)
+}
+func (n constNode) transform(_ func(conditionNode) (Node, error)) (Node, error) {
+	return n, nil
+}
+func (n constNode) Evaluate(_ types.Key, _ types.FlowDirection) bool {
+	return n.value
+}
+func (n constNode) MayMatch(_ *types.BlockRange) bool {
+	return n.value
+}
+func (n constNode) Attributes() map[string]types.IPVersion {
+	return mergeAttributeMaps(nil, n.attributes)
+}
+
+// limitsIPVersion returns whether the constant limits the IP versions a query is evaluated on
+func (n constNode) limitsIPVersion() bool {
+	for _, ipVersion := range n.attributes {
+		if ipVersion != types.IPVersionNone {
+			return true
+		}
+	}
+	return false
+}
+
+// setNode checks whether the value of an attribute is one of a set of values (or none of them if
+// negated), replacing a chain of equalities (inequalities) on the attribute
+type setNode struct {
+	attribute  string
+	negate     bool
+	conditions []conditionNode
+	contains   func(types.Key) bool
+}
+
+// newSetNode creates a set lookup from instrumented conditions, which must either all be
+// equalities or inequalities (negate = true) on the same attribute
+func newSetNode(negate bool, conditions []conditionNode) (setNode, error) {
+	n := setNode{
+		attribute:  conditions[0].attribute,
+		negate:     negate,
+		conditions: conditions,
+	}
+
+	if n.attribute == types.ProtoName {
+		var protos [256]bool
+		for _, condition := range conditions {
+			value, _, _, err := conditionBytesAndNetmask(condition)
+			if err != nil {
+				return n, err
+			}
+			protos[value[0]] = true
+		}
+		n.contains = func(key types.Key) bool {
+			return protos[key.GetProto()]
+		}
+		return n, nil
+	}
+
+	spec, exists := types.LookupAttribute(n.attribute)
+	if !exists {
+		return n, fmt.Errorf("unknown attribute %q", n.attribute)
+	}
+	values := make(map[string]struct{}, len(conditions))
+	for _, condition := range conditions {
+		value, _, _, err := conditionBytesAndNetmask(condition)
+		if err != nil {
+			return n, err
+		}
+		if n.attribute == types.DportName {
+			value = value[:types.DPortWidth]
+		}
+		values[string(value)] = struct{}{}
+	}
+	extract := spec.Extract
+	n.contains = func(key types.Key) bool {
+		_, exists := values[string(extract(key))]
+		return exists
+	}
+	return n, nil
+}
+
+func (n setNode) String() string {
+	separator := " | "
+	if n.negate {
+		separator = " & "
+	}
+	conditions := make([]string, 0, len(n.conditions))
+	for _, condition := range n.conditions {
+		conditions = append(conditions, condition.String())
+	}
+	return "(" + strings.Join(conditions, separator) + ")"
+}
+func (n setNode) transform(transformer func(conditionNode) (Node, error)) (Node, error) {
+	nodes := make([]Node, 0, len(n.conditions))
+	for _, condition := range n.conditions {
+		nodes = append(nodes, condition)
+	}
+	return listToTree(n.negate, nodes).transform(transformer)
+}
+func (n setNode) Evaluate(comparisonValue types.Key, _ types.FlowDirection) bool {
+	return n.contains(comparisonValue) != n.negate
+}
+func (n setNode) MayMatch(r *types.BlockRange) bool {
+	for _, condition := range n.conditions {
+		if condition.MayMatch(r) != n.negate {
+			return !n.negate
+		}
+	}
+	return n.negate
+}
+func (n setNode) Attributes() map[string]types.IPVersion {
+	result := make(map[string]types.IPVersion)
+	for _, condition := range n.conditions {
+		result = mergeAttributeMaps(result, condition.Attributes())
+	}
+	return result
+}
@@ -0,0 +1,154 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/els0r/goProbe/pkg/goDB/conditions"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+// instrumentUnoptimized prepares a conditional for evaluation like ParseAndInstrument(), but without
+// optimizing it
+func instrumentUnoptimized(t *testing.T, conditional string) Node {
+	tokens, err := conditions.Tokenize(conditional)
+	require.Nil(t, err)
+	node, err := parseConditional(tokens)
+	require.Nil(t, err)
+	node, err = desugar(node)
+	require.Nil(t, err)
+	node, err = instrument(negationNormalForm(node))
+	require.Nil(t, err)
+	return node
+}
+
+func TestOptimize(t *testing.T) {
+	var tests = []struct {
+		conditional string
+		expected    string
+	}{
+		{"dport = 80", "dport = 80"},
+		{"sip = 10.0.0.1 & dport = 80", "(dport = 80 & sip = 10.0.0.1)"},
+		{"snet = 10.0.0.0/8 | dip = 10.0.0.1 | proto = udp", "(proto = udp | (dip = 10.0.0.1 | snet = 10.0.0.0/8))"},
+		{"dport = 80 | dport = 080 | dport = 80", "dport = 80"},
+		{"dport = 80 & (proto = 6 & dport = 80)", "(proto = 6 & dport = 80)"},
+		{"dport = 80 & dport = 443", "false"},
+		{"dport = 80 & !(dport = 80)", "false"},
+		{"sip = 10.0.0.1 | sip != 10.0.0.1", "true"}, // retained since it limits the query to IPv4
+		{"dport < 0", "false"},
+		{"proto > 255 | dport = 80", "dport = 80"},
+		{"proto <= 255 & dport = 80", "dport = 80"},
+		{"(dport = 80 & dport = 443) | proto = 17", "proto = 17"},
+		{"dport = 80 | dport = 443 | dport = 8080", "(dport = 80 | (dport = 443 | dport = 8080))"},
+		{"dport = 80 | dport = 443 | dport = 8080 | dport = 53", "(dport = 80 | dport = 443 | dport = 8080 | dport = 53)"},
+		{"sip = 10.0.0.1 | dport = 80 | dport = 443 | dport = 8080 | dport = 53", "((dport = 80 | dport = 443 | dport = 8080 | dport = 53) | sip = 10.0.0.1)"},
+		{"!(proto = 1 | proto = 2 | proto = 3 | proto = 4) & dport = 80", "((proto != 1 & proto != 2 & proto != 3 & proto != 4) & dport = 80)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.conditional, func(t *testing.T) {
+			node := optimize(instrumentUnoptimized(t, test.conditional))
+			require.Equal(t, test.expected, node.String())
+
+			// conditionals folding to a constant are reported as provided
+			parsed, err := ParseAndInstrument(test.conditional, 0)
+			require.Nil(t, err)
+			if _, isConst := node.(constNode); isConst {
+				require.Equal(t, instrumentUnoptimized(t, test.conditional).String(), parsed.String())
+				return
+			}
+			require.Equal(t, test.expected, parsed.String())
+		})
+	}
+
+	// conditionals which are always satisfied (without limiting the IP versions) are equivalent to
+	// none at all
+	for _, conditional := range []string{"dport >= 0", "dport = 80 | dport != 80", "dir = in | proto <= 255"} {
+		node, err := ParseAndInstrument(conditional, 0)
+		require.Nil(t, err)
+		require.True(t, AlwaysSatisfied(node), conditional)
+		require.Equal(t, instrumentUnoptimized(t, conditional).String(), node.String())
+	}
+	for _, conditional := range []string{"dport = 80", "dport < 0", "sip = 10.0.0.1 | sip != 10.0.0.1"} {
+		node, err := ParseAndInstrument(conditional, 0)
+		require.Nil(t, err)
+		require.False(t, AlwaysSatisfied(node), conditional)
+	}
+}
+
+func TestOptimizeEquivalence(t *testing.T) {
+	var keys = []types.Key{
+		types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6),
+		types.NewV4KeyStatic([4]byte{10, 0, 0, 3}, [4]byte{10, 0, 0, 2}, []byte{0, 53}, 17),
+		types.NewV4KeyStatic([4]byte{192, 168, 0, 1}, [4]byte{10, 0, 0, 4}, []byte{1, 187}, 6),
+		types.NewV6KeyStatic([16]byte{0xfe, 0x80, 15: 1}, [16]byte{0xfe, 0x80, 15: 2}, []byte{1, 187}, 6),
+		types.NewV6KeyStatic([16]byte{0xfe, 0x80, 15: 3}, [16]byte{0xfe, 0x80, 15: 1}, []byte{0, 53}, 17),
+	}
+	var directions = []types.FlowDirection{
+		types.FlowDirectionUnknown, types.FlowDirectionIn, types.FlowDirectionOut, types.FlowDirectionBi,
+	}
+
+	// A block with the IPv4 flows above
+	blockRange, ok := types.NewBlockRange(3,
+		[]byte{10, 0, 0, 1, 10, 0, 0, 3, 192, 168, 0, 1},
+		[]byte{10, 0, 0, 2, 10, 0, 0, 2, 10, 0, 0, 4},
+		[]byte{0, 80, 0, 53, 1, 187},
+		[]byte{6, 17, 6},
+	)
+	require.True(t, ok)
+
+	for _, conditional := range []string{
+		"dport = 80 | dport = 443 | dport = 53 | dport = 22",
+		"dport != 80 & dport != 443 & dport != 53 & dport != 22",
+		"sip = 10.0.0.1 | sip = 10.0.0.3 | sip = fe80::1 | sip = 192.168.0.2",
+		"!(dip = 10.0.0.2 | dip = fe80::1 | dip = 10.0.0.9 | dip = 10.0.0.10)",
+		"proto = tcp | proto = udp | proto = icmp | proto = gre",
+		"(dport = 443 | dport = 8443 | dport = 4443 | dport = 9443) & snet = 192.168.0.0/16",
+		"dport = 80 & dport = 53",
+		"(sip = 10.0.0.1 | sip != 10.0.0.1) & dport = 53",
+		"dir = in & (dport = 80 | dport = 80 | proto = 17)",
+		"host = 10.0.0.2 & !(dport < 0 | dport = 53)",
+		"dport >= 0 | sip = fe80::1",
+	} {
+		t.Run(conditional, func(t *testing.T) {
+			unoptimized := instrumentUnoptimized(t, conditional)
+			optimized := optimize(unoptimized)
+
+			require.Equal(t, unoptimized.Attributes(), optimized.Attributes())
+			for _, key := range keys {
+				for _, dir := range directions {
+					require.Equal(t, unoptimized.Evaluate(key, dir), optimized.Evaluate(key, dir), "key %v, direction %v", key, dir)
+				}
+			}
+
+			// pruning may only become more precise, never skip blocks which may match
+			if !optimized.MayMatch(&blockRange) {
+				for _, key := range keys[:3] {
+					require.False(t, unoptimized.Evaluate(key, types.FlowDirectionUnknown))
+				}
+			}
+		})
+	}
+}
+
+func TestOptimizeMayMatch(t *testing.T) {
+	blockRange, ok := types.NewBlockRange(2,
+		[]byte{10, 0, 0, 1, 10, 0, 0, 3},
+		[]byte{10, 0, 0, 2, 10, 0, 0, 2},
+		[]byte{0, 80, 0, 53},
+		[]byte{6, 17},
+	)
+	require.True(t, ok)
+
+	for conditional, mayMatch := range map[string]bool{
+		"dport = 22 | dport = 443 | dport = 8080 | dport = 53":     true,
+		"dport = 22 | dport = 443 | dport = 8080 | dport = 8443":   false,
+		"dport != 22 & dport != 443 & dport != 8080 & dport != 53": true,
+		"proto = 1 | proto = 2 | proto = 3 | proto = 4":            false,
+		"dport = 80 & dport = 53":                                  false,
+	} {
+		node, err := ParseAndInstrument(conditional, 0)
+		require.Nil(t, err)
+		require.Equal(t, mayMatch, node.MayMatch(&blockRange), conditional)
+	}
+}
@@ -33,8 +33,9 @@ package node
 import (
 	"errors"
 	"fmt"
+	"strings"
 
-	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/goProbe/pkg/goDB/conditions"
 )
 
 // errEmptyConditional is a sentinel error indicating that an emoty conditional was parsed
@@ -56,7 +57,7 @@ func parseConditional(tokens []string) (conditionalNode Node, err error) {
 	if !p.success() {
 		return nil, p.err
 	} else if !p.eof() {
-		p.die("Input unexpectyly continues")
+		p.die("Input unexpectedly continues")
 		return nil, p.err
 	}
 
@@ -108,34 +109,81 @@ func (p parser) success() bool {
 	return p.err == nil
 }
 
-// Creates a parser error with the given description and a nice error
-// message pointing to current token in the token stream.
-// Example of an error message created by this method:
+// ParseError denotes a syntax error in a conditional. It points to the offending token and
+// lists the tokens which would have been permitted at its position
+type ParseError struct {
+	Tokens []string // Tokens: the tokenized conditional
+	Pos    int      // Pos: index of the offending token (len(Tokens) if the input ended prematurely)
+	Column int      // Column: character offset of the offending token in the (reassembled) conditional
+
+	Description string   // Description: description of the error
+	Expected    []string // Expected: tokens permitted at Pos (empty if any value is permitted)
+	Suggestion  string   // Suggestion: close match of a misspelled token (if any)
+}
+
+// Error implements the error interface, creating a nice error message pointing to the
+// offending token in the token stream, e.g.:
 //
 //	( sip = 192.168.1.1
 //	                    ^
 //	Expected ), but didn't get it.
+//	Expected one of: ), &, |
+func (e *ParseError) Error() string {
+	var sb strings.Builder
+
+	// Reassemble the tokens and draw an arrow pointing to the offending one
+	for _, token := range e.Tokens {
+		sb.WriteString(token + " ")
+	}
+	sb.WriteString("\n" + strings.Repeat(" ", e.Column) + "^\n")
+	sb.WriteString(e.Description)
+
+	if len(e.Expected) > 0 {
+		sb.WriteString("\nExpected one of: " + strings.Join(e.Expected, ", "))
+	}
+	if e.Suggestion != "" {
+		sb.WriteString(fmt.Sprintf("\nDid you mean %q?", e.Suggestion))
+	}
+	return sb.String()
+}
+
+// Creates a parser error with the given description pointing to current token in the token
+// stream. The tokens permitted at this position are determined from the condition grammar
+// (sharing the logic used for command line completion)
 func (p *parser) die(description string, args ...interface{}) {
-	// Reassemble the tokens.
-	final := ""
-	for i := 0; i < p.pos; i++ {
-		final += p.tokens[i] + " "
+	column := 0
+	for i := 0; i < p.pos && i < len(p.tokens); i++ {
+		column += len(p.tokens[i]) + 1
 	}
-	// Remember position of current token in reassembled string
-	offset := len(final)
-	// Add remaining tokens
-	for i := p.pos; i < len(p.tokens); i++ {
-		final += p.tokens[i] + " "
+
+	perr := &ParseError{
+		Tokens:      p.tokens,
+		Pos:         p.pos,
+		Column:      column,
+		Description: strings.TrimSuffix(fmt.Sprintf(description, args...), "\n"),
+		Expected:    p.expectedTokens(),
 	}
-	final += "\n"
-	// Draw arrow
-	for i := 0; i < offset; i++ {
-		final += " "
+	if !p.eof() {
+		perr.Suggestion = conditions.Suggest(p.tokens[p.pos], perr.Expected)
 	}
-	final += "^\n"
-	// Add error description
-	final += description
-	p.err = fmt.Errorf(final, args...)
+	p.err = perr
+}
+
+// Returns the tokens permitted at the current position in the token stream
+func (p *parser) expectedTokens() []string {
+	var prevprev, prev string
+	if p.pos > 0 && p.pos <= len(p.tokens) {
+		prev = p.tokens[p.pos-1]
+	}
+	if p.pos > 1 && p.pos <= len(p.tokens) {
+		prevprev = p.tokens[p.pos-2]
+	}
+
+	// values are free-form, listing all protocol names wouldn't help either
+	if conditions.IsComparator(prev) {
+		return nil
+	}
+	return conditions.NextTokens(prevprev, prev, conditions.OpenParens(p.tokens[:p.pos]))
 }
 
 // Returns the token at the current position in the token stream
@@ -317,17 +365,8 @@ func (p *parser) condition() (result Node) {
 
 // Corresponds to grammar rule "attribute"
 func (p *parser) attribute() (result string) {
-	attributes := []string{
-		types.DIPName, types.SIPName, "dnet", "snet", types.DportName, types.ProtoName, // non-sugar
-		"dst", "src", "host", "net", "port", "protocol", "ipproto", // sugar
-	}
-	for _, attrib := range attributes {
-		if p.accept(attrib) {
-			if !p.success() {
-				return
-			}
-			return attrib
-		}
+	if !p.eof() && conditions.IsAttribute(p.tokens[p.pos]) {
+		return p.advance()
 	}
 
 	p.die("Expected attribute")
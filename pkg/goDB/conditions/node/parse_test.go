@@ -13,7 +13,10 @@ package node
 
 import (
 	"errors"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
 var parseConditionalTests = []struct {
@@ -71,3 +74,53 @@ func TestParseConditionalEmpty(t *testing.T) {
 		t.Fatalf("TestParseConditionalEmpty expected: nil, nil Got: %v, %v", ast, err)
 	}
 }
+
+func TestParseConditionalError(t *testing.T) {
+	var tests = []struct {
+		inTokens   []string
+		pos        int
+		expected   []string
+		suggestion string
+		msg        string
+	}{
+		{
+			[]string{"dprot", "=", "80"}, 0,
+			[]string{"!", "(", "sip", "src", "dip", "dst", "dport", "port", "proto", "protocol", "ipproto", "snet", "dnet", "host", "net", "dir"},
+			"dport",
+			"dprot = 80 \n^\nExpected attribute\nExpected one of: !, (, sip, src, dip, dst, dport, port, proto, protocol, ipproto, snet, dnet, host, net, dir\nDid you mean \"dport\"?",
+		},
+		{
+			[]string{"(", "sip", "=", "192.168.1.1"}, 4,
+			[]string{")", "&", "|"},
+			"",
+			"( sip = 192.168.1.1 \n                    ^\nExpected ), but didn't get it.\nExpected one of: ), &, |",
+		},
+		{
+			[]string{"sip", "==", "192.168.1.1"}, 1,
+			[]string{"=", "!="},
+			"=",
+			"",
+		},
+		{
+			[]string{"dport", "="}, 2,
+			nil,
+			"",
+			"dport = \n        ^\nUnexpected end of input",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.inTokens, " "), func(t *testing.T) {
+			_, err := parseConditional(test.inTokens)
+
+			var perr *ParseError
+			require.ErrorAs(t, err, &perr)
+			require.Equal(t, test.pos, perr.Pos)
+			require.Equal(t, test.expected, perr.Expected)
+			require.Equal(t, test.suggestion, perr.Suggestion)
+			if test.msg != "" {
+				require.Equal(t, test.msg, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package node
+
+import (
+	"bytes"
+
+	"github.com/els0r/goProbe/pkg/types"
+)
+
+// Generates a closure determining whether the condition can match any flow of a block
+// based on the range of attribute values in the block (see types.BlockRange). The closure
+// errs on the side of caution, i.e. it only reports false if it is guaranteed that no flow
+// in the block satisfies the condition. Attributes without a dedicated implementation are
+// always assumed to (potentially) match
+func generateMayMatch(condition *conditionNode) error {
+	if condition.attribute == types.DirectionName {
+		return nil
+	}
+
+	value, netmask, ipVersion, err := conditionBytesAndNetmask(*condition)
+	if err != nil {
+		return err
+	}
+
+	switch condition.attribute {
+	case types.SIPName, types.DIPName, "snet", "dnet":
+		ipRange := (*types.BlockRange).SIP
+		if condition.attribute == types.DIPName || condition.attribute == "dnet" {
+			ipRange = (*types.BlockRange).DIP
+		}
+
+		// A single IP address is handled like a host network
+		isNet := condition.attribute == "snet" || condition.attribute == "dnet"
+		if !isNet {
+			netmask = len(value) * 8
+		}
+		lower, upper := networkBounds(value, netmask)
+		isIPv4 := ipVersion == types.IPVersionV4
+
+		switch condition.comparator {
+		case "=":
+			condition.mayMatch = func(r *types.BlockRange) bool {
+				if ips, exists := ipRange(r, isIPv4); exists && ips.Overlaps(lower, upper) {
+					return true
+				}
+
+				// An IP address of the other IP version can never be equal, but a network
+				// might (partially) match addresses of the other IP version
+				_, otherExists := ipRange(r, !isIPv4)
+				return isNet && otherExists
+			}
+		case "!=":
+			condition.mayMatch = func(r *types.BlockRange) bool {
+				if _, otherExists := ipRange(r, !isIPv4); otherExists {
+					return true
+				}
+				ips, exists := ipRange(r, isIPv4)
+				return exists && !ips.Within(lower, upper)
+			}
+		}
+	case types.DportName:
+		condition.mayMatch = mayMatchRange(condition.comparator, value[:types.DPortWidth], func(r *types.BlockRange) ([]byte, []byte) {
+			return r.DportMin[:], r.DportMax[:]
+		})
+	case types.ProtoName:
+		condition.mayMatch = mayMatchRange(condition.comparator, value[:types.ProtoWidth], func(r *types.BlockRange) ([]byte, []byte) {
+			return []byte{r.ProtoMin}, []byte{r.ProtoMax}
+		})
+	}
+
+	return nil
+}
+
+// mayMatchRange generates a closure checking a comparison against the (inclusive) range of
+// values in a block as provided by bounds
+func mayMatchRange(comparator string, value []byte, bounds func(*types.BlockRange) ([]byte, []byte)) func(*types.BlockRange) bool {
+	var inRange func(lower, upper []byte) bool
+	switch comparator {
+	case "=":
+		inRange = func(lower, upper []byte) bool {
+			return bytes.Compare(lower, value) <= 0 && bytes.Compare(upper, value) >= 0
+		}
+	case "!=":
+		inRange = func(lower, upper []byte) bool {
+			return !bytes.Equal(lower, value) || !bytes.Equal(upper, value)
+		}
+	case "<":
+		inRange = func(lower, _ []byte) bool {
+			return bytes.Compare(lower, value) < 0
+		}
+	case ">":
+		inRange = func(_, upper []byte) bool {
+			return bytes.Compare(upper, value) > 0
+		}
+	case "<=":
+		inRange = func(lower, _ []byte) bool {
+			return bytes.Compare(lower, value) <= 0
+		}
+	case ">=":
+		inRange = func(_, upper []byte) bool {
+			return bytes.Compare(upper, value) >= 0
+		}
+	default:
+		return nil
+	}
+
+	return func(r *types.BlockRange) bool {
+		if !r.HasV4 && !r.HasV6 {
+			return false
+		}
+		return inRange(bounds(r))
+	}
+}
+
+// networkBounds returns the lowest and highest address of the network denoted by the
+// (already masked) IP address and netmask
+func networkBounds(ip []byte, netmask int) ([]byte, []byte) {
+	upper := make([]byte, len(ip))
+	copy(upper, ip)
+	for i := range upper {
+		switch {
+		case (i+1)*8 <= netmask:
+			continue
+		case i*8 >= netmask:
+			upper[i] = 0xff
+		default:
+			upper[i] |= 0xff >> uint8(netmask-i*8)
+		}
+	}
+	return ip, upper
+}
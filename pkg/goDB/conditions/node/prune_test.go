@@ -0,0 +1,76 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMayMatch(t *testing.T) {
+
+	// A block with two IPv4 flows (10.0.0.1 -> 10.0.0.2:80/tcp, 10.0.0.3 -> 10.0.0.2:53/udp) and
+	// a single IPv6 flow (fe80::1 -> fe80::2:443/tcp)
+	blockRange, ok := types.NewBlockRange(2,
+		[]byte{10, 0, 0, 1, 10, 0, 0, 3, 0xfe, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+		[]byte{10, 0, 0, 2, 10, 0, 0, 2, 0xfe, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2},
+		[]byte{0, 80, 0, 53, 1, 187},
+		[]byte{6, 17, 6},
+	)
+	require.True(t, ok)
+
+	v4Only := blockRange
+	v4Only.HasV6 = false
+
+	var tests = []struct {
+		conditional string
+		blockRange  types.BlockRange
+		mayMatch    bool
+	}{
+		{"dport = 80", blockRange, true},
+		{"dport = 53", blockRange, true},
+		{"dport = 100", blockRange, true}, // within the range (no exact match required)
+		{"dport = 8080", blockRange, false},
+		{"dport < 53", blockRange, false},
+		{"dport <= 53", blockRange, true},
+		{"dport > 443", blockRange, false},
+		{"dport >= 443", blockRange, true},
+		{"dport != 80", blockRange, true},
+		{"proto = 17", blockRange, true},
+		{"proto = 1", blockRange, false},
+		{"proto > 17", blockRange, false},
+		{"sip = 10.0.0.2", blockRange, true},
+		{"sip = 10.0.0.4", blockRange, false},
+		{"sip = 192.168.0.1", blockRange, false},
+		{"dip = 10.0.0.2", blockRange, true},
+		{"dip = 10.0.0.1", blockRange, false},
+		{"sip = fe80::1", blockRange, true},
+		{"sip = fe80::3", blockRange, false},
+		{"snet = 10.0.0.0/8", blockRange, true},
+		{"snet = 10.0.0.0/31", blockRange, true},
+		{"snet = 10.0.0.4/30", v4Only, false},
+		{"snet = 192.168.0.0/16", v4Only, false},
+		{"dnet = fe80::/16", blockRange, true},
+		{"dnet = 2001:db8::/32", blockRange, true},
+		{"dnet = 10.0.0.2/32", v4Only, true},
+		{"sip != 10.0.0.1", blockRange, true},
+		{"dip != 10.0.0.2", blockRange, true},
+		{"dip != 10.0.0.2", v4Only, false},
+		{"dnet != 10.0.0.0/8", v4Only, false},
+		{"host = 10.0.0.10", blockRange, false},
+		{"host = 10.0.0.2", blockRange, true},
+		{"dport = 8080 | proto = 17", blockRange, true},
+		{"dport = 8080 | proto = 1", blockRange, false},
+		{"dport = 80 & proto = 2", blockRange, false},
+		{"!(dport != 8080)", blockRange, false},
+		{"dport = 80", types.BlockRange{}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.conditional, func(t *testing.T) {
+			node, err := ParseAndInstrument(test.conditional, 0)
+			require.Nil(t, err)
+			require.Equal(t, test.mayMatch, node.MayMatch(&test.blockRange))
+		})
+	}
+}
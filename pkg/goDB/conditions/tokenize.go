@@ -24,6 +24,20 @@ import (
 	"strings"
 )
 
+// SanitizeOption configures the sanitization of a conditional
+type SanitizeOption func(*sanitizeConfig)
+
+type sanitizeConfig struct {
+	macros Macros
+}
+
+// WithMacros expands references to the provided macros during sanitization
+func WithMacros(macros Macros) SanitizeOption {
+	return func(cfg *sanitizeConfig) {
+		cfg.macros = macros
+	}
+}
+
 // SanitizeUserInput sanitizes a conditional string provided by the user. Its main purpose
 // is to convert other forms of precedence and logical operators to the condition grammar
 // used.
@@ -34,6 +48,7 @@ import (
 // Input:
 //
 //	conditional: string containing the conditional specified in "user grammar"
+//	opts:        functional options, e.g. the macros to expand (see WithMacros)
 //
 // Output:
 //
@@ -45,14 +60,26 @@ import (
 // NOTE:  the current implementation of GPDPIProtocols.go has to make sure that the map keys
 //
 //	of "proto" to numbers are all lower case
-func SanitizeUserInput(conditional string) (string, error) {
+func SanitizeUserInput(conditional string, opts ...SanitizeOption) (string, error) {
 
 	var (
 		sanitized string
 		r         *regexp.Regexp
 		err       error
+		cfg       sanitizeConfig
 	)
 
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	// expand macros first, so that their conditions may be written in "user grammar" as well. References
+	// to macros which weren't provided are reported here instead of failing the parser later on
+	conditional, err = cfg.macros.Expand(conditional)
+	if err != nil {
+		return sanitized, err
+	}
+
 	// expressions that count as "user grammar" for the different parts of the conditional
 	var grammarConversionMap = map[string][]string{
 		"!":  {"(^|\\s+)not\\s+"},
@@ -26,23 +26,33 @@ import (
 // DefaultPermissions denotes the default permissions used during writeout
 const DefaultPermissions = fs.FileMode(0644)
 
+// DefaultWriteConcurrency denotes the default number of columns compressed and written in parallel
+const DefaultWriteConcurrency = 4
+
 // DBWriter writes goProbe flows to goDB database files
 type DBWriter struct {
 	dbpath string
 	iface  string
 
-	encoderType  encoders.Type
-	encoderLevel int
-	permissions  fs.FileMode
+	encoderType      encoders.Type
+	encoderLevel     int
+	permissions      fs.FileMode
+	dirPermissions   fs.FileMode
+	uid, gid         int
+	writeConcurrency int
+	preallocate      bool
 }
 
 // NewDBWriter initializes a new DBWriter
 func NewDBWriter(dbpath string, iface string, encoderType encoders.Type) (w *DBWriter) {
 	return &DBWriter{
-		dbpath:      dbpath,
-		iface:       iface,
-		encoderType: encoderType,
-		permissions: DefaultPermissions,
+		dbpath:           dbpath,
+		iface:            iface,
+		encoderType:      encoderType,
+		permissions:      DefaultPermissions,
+		uid:              -1,
+		gid:              -1,
+		writeConcurrency: DefaultWriteConcurrency,
 	}
 }
 
@@ -52,31 +62,61 @@ func (w *DBWriter) Permissions(permissions fs.FileMode) *DBWriter {
 	return w
 }
 
+// DirPermissions overrides the permissions of directories created in the DB (by default, they are
+// derived from the file permissions)
+func (w *DBWriter) DirPermissions(permissions fs.FileMode) *DBWriter {
+	w.dirPermissions = permissions
+	return w
+}
+
+// Owner assigns files / directories created in the DB to the given user / group (e.g. in order
+// to allow reading the DB as a different user). A negative UID or GID retains the one of the process
+func (w *DBWriter) Owner(uid, gid int) *DBWriter {
+	w.uid, w.gid = uid, gid
+	return w
+}
+
 // EncoderLevel overrides the default encoder / compressor level for files / directories in the DB
 func (w *DBWriter) EncoderLevel(level int) *DBWriter {
 	w.encoderLevel = level
 	return w
 }
 
+// WriteConcurrency overrides the default number of columns compressed and written in parallel
+func (w *DBWriter) WriteConcurrency(n int) *DBWriter {
+	w.writeConcurrency = n
+	return w
+}
+
+// Preallocate reserves the disk space of the files of each daily directory upon its first write, based on
+// the sizes of the preceding days
+func (w *DBWriter) Preallocate(enabled bool) *DBWriter {
+	w.preallocate = enabled
+	return w
+}
+
 // Write takes an aggregated flow map and its metadata and writes it to disk for a given timestamp
 func (w *DBWriter) Write(flowmap *hashmap.AggFlowMap, captureStats capturetypes.CaptureStats, timestamp int64) error {
+	return w.WriteWithLabels(flowmap, nil, captureStats, timestamp)
+}
+
+// WriteWithLabels writes the flows (see Write()) along with their labels, which are stored in a
+// label column per labeler (by name, e.g. types.ProcessName)
+func (w *DBWriter) WriteWithLabels(flowmap *hashmap.AggFlowMap, labelers map[string]capturetypes.FlowLabeler, captureStats capturetypes.CaptureStats, timestamp int64) error {
 	var (
 		data   [types.ColIdxCount][]byte
+		labels map[string][]string
 		update gpfile.Stats
 		err    error
 	)
 
-	dir := gpfile.NewDir(filepath.Join(w.dbpath, w.iface), timestamp, gpfile.ModeWrite, gpfile.WithPermissions(w.permissions), gpfile.WithEncoderTypeLevel(w.encoderType, w.encoderLevel))
+	dir := gpfile.NewDir(filepath.Join(w.dbpath, w.iface), timestamp, gpfile.ModeWrite, w.dirOptions()...)
 	if err = dir.Open(); err != nil {
 		return fmt.Errorf("failed to create / open daily directory: %w", err)
 	}
 
-	data, update = dbData(flowmap)
-	if err := dir.WriteBlocks(timestamp, gpfile.TrafficMetadata{
-		NumV4Entries: update.Traffic.NumV4Entries,
-		NumV6Entries: update.Traffic.NumV6Entries,
-		NumDrops:     captureStats.Dropped,
-	}, update.Counts, data); err != nil {
+	data, labels, update = dbData(flowmap, labelers)
+	if err := dir.WriteBlocksWithLabels(timestamp, blockTraffic(update, captureStats), update.Counts, data, labels); err != nil {
 		return err
 	}
 
@@ -97,18 +137,14 @@ func (w *DBWriter) WriteBulk(workloads []BulkWorkload, dirTimestamp int64) (err
 		update gpfile.Stats
 	)
 
-	dir := gpfile.NewDir(filepath.Join(w.dbpath, w.iface), dirTimestamp, gpfile.ModeWrite, gpfile.WithPermissions(w.permissions), gpfile.WithEncoderTypeLevel(w.encoderType, w.encoderLevel))
+	dir := gpfile.NewDir(filepath.Join(w.dbpath, w.iface), dirTimestamp, gpfile.ModeWrite, w.dirOptions()...)
 	if err = dir.Open(); err != nil {
 		return fmt.Errorf("failed to create / open daily directory: %w", err)
 	}
 
 	for _, workload := range workloads {
-		data, update = dbData(workload.FlowMap)
-		if err := dir.WriteBlocks(workload.Timestamp, gpfile.TrafficMetadata{
-			NumV4Entries: update.Traffic.NumV4Entries,
-			NumV6Entries: update.Traffic.NumV6Entries,
-			NumDrops:     workload.CaptureStats.Dropped,
-		}, update.Counts, data); err != nil {
+		data, _, update = dbData(workload.FlowMap, nil)
+		if err := dir.WriteBlocks(workload.Timestamp, blockTraffic(update, workload.CaptureStats), update.Counts, data); err != nil {
 			return err
 		}
 	}
@@ -116,7 +152,35 @@ func (w *DBWriter) WriteBulk(workloads []BulkWorkload, dirTimestamp int64) (err
 	return dir.Close()
 }
 
-func dbData(aggFlowMap *hashmap.AggFlowMap) ([types.ColIdxCount][]byte, gpfile.Stats) {
+// blockTraffic assembles the traffic metadata of a block, including the capture quality indicators
+// of the interval it covers and whether the link was down at its end
+func blockTraffic(update gpfile.Stats, captureStats capturetypes.CaptureStats) gpfile.TrafficMetadata {
+	traffic := gpfile.TrafficMetadata{
+		NumV4Entries: update.Traffic.NumV4Entries,
+		NumV6Entries: update.Traffic.NumV6Entries,
+		NumDrops:     captureStats.Dropped,
+		NumPackets:   captureStats.Received,
+		NumOverruns:  captureStats.Overruns,
+		NumTruncated: uint64(captureStats.ParsingErrors[capturetypes.ErrnoPacketTruncated]),
+	}
+	if captureStats.LinkState.IsDown() {
+		traffic.NumLinkDown = 1
+	}
+	return traffic
+}
+
+func (w *DBWriter) dirOptions() []gpfile.Option {
+	return []gpfile.Option{
+		gpfile.WithPermissions(w.permissions),
+		gpfile.WithDirPermissions(w.dirPermissions),
+		gpfile.WithOwner(w.uid, w.gid),
+		gpfile.WithEncoderTypeLevel(w.encoderType, w.encoderLevel),
+		gpfile.WithWriteConcurrency(w.writeConcurrency),
+		gpfile.WithPreallocation(w.preallocate),
+	}
+}
+
+func dbData(aggFlowMap *hashmap.AggFlowMap, labelers map[string]capturetypes.FlowLabeler) ([types.ColIdxCount][]byte, map[string][]string, gpfile.Stats) {
 	var dbData [types.ColIdxCount][]byte
 	var summUpdate gpfile.Stats
 
@@ -159,6 +223,21 @@ func dbData(aggFlowMap *hashmap.AggFlowMap) ([types.ColIdxCount][]byte, gpfile.S
 		}
 	}
 
+	// labels (aligned with the attributes)
+	var labels map[string][]string
+	if len(labelers) > 0 {
+		labels = make(map[string][]string, len(labelers))
+		for name, labeler := range labelers {
+			values := make([]string, 0, len(v4List)+len(v6List))
+			for _, list := range []hashmap.List{v4List, v6List} {
+				for _, flow := range list {
+					values = append(values, labeler(flow.Key))
+				}
+			}
+			labels[name] = values
+		}
+	}
+
 	// Perform bit packing on the counter columns
 	dbData[types.BytesRcvdColIdx] = bitpack.Pack(bytesRcvd)
 	dbData[types.BytesSentColIdx] = bitpack.Pack(bytesSent)
@@ -168,5 +247,5 @@ func dbData(aggFlowMap *hashmap.AggFlowMap) ([types.ColIdxCount][]byte, gpfile.S
 	summUpdate.Traffic.NumV4Entries = uint64(len(v4List))
 	summUpdate.Traffic.NumV6Entries = uint64(len(v6List))
 
-	return dbData, summUpdate
+	return dbData, labels, summUpdate
 }
@@ -2,6 +2,7 @@ package encoder
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"testing"
 
@@ -54,6 +55,38 @@ func TestNewByString(t *testing.T) {
 	}
 }
 
+func TestValidateLevel(t *testing.T) {
+	var tests = []struct {
+		encoderType encoders.Type
+		level       int
+		shouldFail  bool
+	}{
+		{encoders.EncoderTypeNull, 0, false},
+		{encoders.EncoderTypeNull, 1, true},
+		{encoders.EncoderTypeLZ4Custom, 4, true},
+		{encoders.EncoderTypeLZ4, 0, false},
+		{encoders.EncoderTypeLZ4, lz4.MaxCompressionLevel, false},
+		{encoders.EncoderTypeLZ4, lz4.MaxCompressionLevel + 1, true},
+		{encoders.EncoderTypeLZ4, -1, true},
+		{encoders.EncoderTypeZSTD, 1, false},
+		{encoders.EncoderTypeZSTD, zstd.MaxCompressionLevel, false},
+		{encoders.EncoderTypeZSTD, zstd.MaxCompressionLevel + 1, true},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s-%d", test.encoderType, test.level), func(t *testing.T) {
+			err := encoders.ValidateLevel(test.encoderType, test.level)
+			if test.shouldFail {
+				if !errors.Is(err, encoders.ErrInvalidLevel) {
+					t.Fatalf("expected to fail with %v, have: %v", encoders.ErrInvalidLevel, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
 func TestCompressionDecompression(t *testing.T) {
 	var nBytes = int64(len(encodingCorpus))
 
@@ -1,6 +1,7 @@
 package encoders
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
@@ -21,6 +22,20 @@ const (
 	MaxEncoderType = EncoderTypeLZ4
 )
 
+// Maximum useful compression levels of the encoders supporting levels
+const (
+	MaxLevelLZ4  = 12 // MaxLevelLZ4 : maximum useful compression level of the LZ4 encoder
+	MaxLevelZSTD = 19 // MaxLevelZSTD : maximum useful compression level of the ZSTD encoder
+)
+
+var maxLevels = map[Type]int{
+	EncoderTypeLZ4:  MaxLevelLZ4,
+	EncoderTypeZSTD: MaxLevelZSTD,
+}
+
+// ErrInvalidLevel is returned if a compression level isn't supported by an encoder
+var ErrInvalidLevel = errors.New("invalid compression level")
+
 var encoderNames = map[Type]string{
 	EncoderTypeLZ4:       "lz4",
 	EncoderTypeLZ4Custom: "lz4cust",
@@ -48,3 +63,20 @@ func GetTypeByString(t string) (Type, error) {
 		return EncoderTypeNull, fmt.Errorf("unsupported encoder: %v", t)
 	}
 }
+
+// ValidateLevel checks if the compression level is supported by the encoder type. A level of
+// zero denotes the default level of the encoder and is hence always supported
+func ValidateLevel(t Type, level int) error {
+	if level == 0 {
+		return nil
+	}
+
+	maxLevel, supported := maxLevels[t]
+	if !supported {
+		return fmt.Errorf("%w: encoder `%s` doesn't support setting a compression level", ErrInvalidLevel, t)
+	}
+	if level < 1 || level > maxLevel {
+		return fmt.Errorf("%w: level %d not in [1, %d] for encoder `%s`", ErrInvalidLevel, level, maxLevel, t)
+	}
+	return nil
+}
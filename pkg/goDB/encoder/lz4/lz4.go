@@ -6,7 +6,7 @@ import (
 )
 
 const (
-	MaxCompressionLevel     = 12 // MaxCompressionLevel denotes the maximum useful compression level
+	MaxCompressionLevel     = encoders.MaxLevelLZ4 // MaxCompressionLevel denotes the maximum useful compression level
 	defaultCompressionLevel = 6
 )
 
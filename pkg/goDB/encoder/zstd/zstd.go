@@ -6,7 +6,7 @@ import (
 )
 
 const (
-	MaxCompressionLevel     = 19 // MaxCompressionLevel denotes the maximum useful compression level
+	MaxCompressionLevel     = encoders.MaxLevelZSTD // MaxCompressionLevel denotes the maximum useful compression level
 	defaultCompressionLevel = 6
 )
 
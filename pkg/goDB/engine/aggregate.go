@@ -21,7 +21,6 @@ type internalError int
 // enumeration of processing errors
 const (
 	errorNoResults internalError = iota + 1
-	errorMemoryBreach
 	errorInternalProcessing
 	errorMismatchingHosts
 )
@@ -29,8 +28,6 @@ const (
 // Error implements the error interface for query processing errors
 func (i internalError) Error() string {
 	switch i {
-	case errorMemoryBreach:
-		return "memory limit exceeded"
 	case errorInternalProcessing:
 		return "internal error during query processing"
 	}
@@ -40,8 +37,9 @@ func (i internalError) Error() string {
 // receive maps on mapChan until mapChan gets closed.
 // Then send aggregation result over resultChan.
 // If an error occurs, aggregate may return prematurely.
+// If a sketch is provided, the flows are aggregated approximately (only tracking the top flows).
 // Closes resultChan on termination.
-func aggregate(mapChan <-chan hashmap.AggFlowMapWithMetadata, ifaces []string, isLowMem bool) chan aggregateResult {
+func aggregate(mapChan <-chan hashmap.AggFlowMapWithMetadata, ifaces []string, isLowMem bool, sketch *topKSketch) chan aggregateResult {
 
 	// create channel that returns the final aggregate result
 	resultChan := make(chan aggregateResult, 1)
@@ -65,10 +63,13 @@ func aggregate(mapChan <-chan hashmap.AggFlowMapWithMetadata, ifaces []string, i
 				return
 			}
 
-			finalMap := finalMaps[item.Interface]
-
-			// Merge the item into the final map for this interface, then update the aggregation counter
-			finalMap.Merge(item, &totals)
+			// Merge the item into the final map for this interface (or the sketch), then update the
+			// aggregation counter
+			if sketch != nil {
+				sketch.addMap(item, &totals)
+			} else {
+				finalMaps[item.Interface].Merge(item, &totals)
+			}
 			nAgg[item.Interface] = nAgg[item.Interface] + 1
 
 			// Cleanup the now unused item / map
@@ -79,6 +80,10 @@ func aggregate(mapChan <-chan hashmap.AggFlowMapWithMetadata, ifaces []string, i
 			}
 		}
 
+		if sketch != nil {
+			finalMaps = sketch.flowMaps(ifaces)
+		}
+
 		// Push the final result
 		if finalMaps.Len() == 0 {
 			resultChan <- aggregateResult{}
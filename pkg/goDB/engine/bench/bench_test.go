@@ -0,0 +1,90 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden results")
+
+var referenceEncoders = []encoders.Type{
+	encoders.EncoderTypeLZ4,
+	encoders.EncoderTypeZSTD,
+	encoders.EncoderTypeNull,
+}
+
+// TestGolden verifies that the results of all scenarios match the golden ones, regardless of the
+// encoder used to store the reference DB. Run with -update to record the golden results
+func TestGolden(t *testing.T) {
+	golden, err := DefaultGolden()
+	require.Nil(t, err)
+
+	encoderTypes := referenceEncoders
+	if *updateGolden {
+		golden, encoderTypes = make(Golden), encoderTypes[:1]
+	}
+
+	for _, encoderType := range encoderTypes {
+		t.Run(encoderType.String(), func(t *testing.T) {
+			path := t.TempDir()
+			require.Nil(t, DefaultReference.Generate(path, encoderType))
+
+			for _, scenario := range Scenarios() {
+				res, err := scenario.Run(context.Background(), DefaultReference, path)
+				require.Nil(t, err)
+				require.NotEmpty(t, res.Rows, scenario.Name())
+
+				digest, err := NewDigest(res)
+				require.Nil(t, err)
+
+				if *updateGolden {
+					golden[scenario.Name()] = digest
+					continue
+				}
+				require.Nil(t, golden.Verify(scenario, digest))
+			}
+		})
+	}
+
+	if *updateGolden {
+		f, err := os.Create("golden.json")
+		require.Nil(t, err)
+		defer f.Close()
+
+		// keys are sorted and conditions not escaped in order to keep the golden results reviewable
+		enc := json.NewEncoder(f)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		require.Nil(t, enc.Encode(golden))
+	}
+}
+
+// BenchmarkScenarios benchmarks all scenarios against the reference DB stored using each of the
+// encoders, e.g.
+//
+//	go test -bench 'Scenarios/zstd/sip,dip' ./pkg/goDB/engine/bench/
+func BenchmarkScenarios(b *testing.B) {
+	for _, encoderType := range referenceEncoders {
+		path := b.TempDir()
+		require.Nil(b, DefaultReference.Generate(path, encoderType))
+
+		b.Run(encoderType.String(), func(b *testing.B) {
+			for _, scenario := range Scenarios() {
+				b.Run(scenario.Name(), func(b *testing.B) {
+					b.ReportAllocs()
+					for i := 0; i < b.N; i++ {
+						if _, err := scenario.Run(context.Background(), DefaultReference, path); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Package bench provides a reproducible benchmark and regression-test harness for the query engine.
+It generates a reference DB from a seeded pseudo-random flow distribution and runs a fixed set of
+query scenarios (covering attribute combinations and conditions) against it. Since the generated
+data only depends on the reference parameters, the results of all scenarios are compared to golden
+digests, regardless of the encoder used to store the data
+*/
+package bench
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"os"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/goProbe/pkg/goDB"
+	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/goProbe/pkg/types/hashmap"
+)
+
+// Reference denotes the parameters of a generated reference DB. Given identical parameters, the
+// flows stored in the DB (and hence the results of all queries) are identical
+type Reference struct {
+	Ifaces        []string  // Ifaces: the interfaces to generate data for
+	Start         time.Time // Start: the timestamp of the first block
+	Blocks        int       // Blocks: the number of blocks per interface
+	FlowsPerBlock int       // FlowsPerBlock: the number of flows generated per block (before aggregation)
+	Seed          int64     // Seed: the seed of the flow distribution
+}
+
+// DefaultReference denotes the reference DB the golden results were recorded for. It spans two
+// days in order to cover queries across daily directories
+var DefaultReference = Reference{
+	Ifaces:        []string{"eth0", "eth1"},
+	Start:         time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC),
+	Blocks:        96,
+	FlowsPerBlock: 500,
+	Seed:          1,
+}
+
+// Identity is the host identity stored in the reference DB, so that query results don't depend on
+// the host running them
+var Identity = info.Identity{
+	Hostname: "reference",
+	HostID:   "reference",
+}
+
+// End returns the timestamp of the last block
+func (r Reference) End() time.Time {
+	return r.Start.Add(time.Duration(r.Blocks-1) * time.Duration(goDB.DBWriteInterval) * time.Second)
+}
+
+// Generate writes the reference DB to path using the given encoder
+func (r Reference) Generate(path string, encoderType encoders.Type) error {
+	if err := os.MkdirAll(path, goDB.DefaultPermissions|0111); err != nil {
+		return fmt.Errorf("failed to create reference DB directory: %w", err)
+	}
+
+	for i, iface := range r.Ifaces {
+
+		// each interface draws from its own source in order to keep its flows independent of the
+		// number of interfaces
+		rng := rand.New(rand.NewSource(r.Seed + int64(i))) // #nosec G404
+
+		writer := goDB.NewDBWriter(path, iface, encoderType)
+		for block := 0; block < r.Blocks; block++ {
+			timestamp := r.Start.Unix() + int64(block)*goDB.DBWriteInterval
+			if err := writer.Write(r.flowMap(rng), capturetypes.CaptureStats{}, timestamp); err != nil {
+				return fmt.Errorf("failed to write block %d of %s: %w", block, iface, err)
+			}
+		}
+	}
+	return info.WriteIdentity(path, Identity)
+}
+
+// the ports and their weights the flows are distributed over. Ports not listed are drawn from the
+// ephemeral range
+var (
+	referencePorts   = []uint16{443, 80, 53, 22, 123, 8080, 25, 3389}
+	referenceWeights = []int{40, 15, 15, 5, 5, 5, 3, 2}
+)
+
+const (
+	numInternalHosts = 64
+	numExternalHosts = 32
+	numV6Hosts       = 16
+
+	ephemeralPortsPct = 10
+	icmpPct           = 5
+	v6Pct             = 20
+	inboundPct        = 30
+)
+
+func (r Reference) flowMap(rng *rand.Rand) *hashmap.AggFlowMap {
+	flowMap := hashmap.NewAggFlowMap()
+	for i := 0; i < r.FlowsPerBlock; i++ {
+		var sip, dip netip.Addr
+		if rng.Intn(100) < v6Pct {
+			sip, dip = v6Host(rng.Intn(numV6Hosts)), v6Host(numV6Hosts+rng.Intn(numV6Hosts))
+		} else {
+			sip, dip = internalHost(rng.Intn(numInternalHosts)), externalHost(rng.Intn(numExternalHosts))
+		}
+		if rng.Intn(100) < inboundPct {
+			sip, dip = dip, sip
+		}
+
+		proto, dport := uint8(6), referencePort(rng)
+		switch {
+		case rng.Intn(100) < icmpPct:
+			proto, dport = 1, 0
+			if sip.Is6() {
+				proto = 58
+			}
+		case dport == 53 || dport == 123:
+			proto = 17
+		}
+
+		// a share of the flows never receives an answer
+		pktsSent, pktsRcvd := uint64(1+rng.Intn(100)), uint64(rng.Intn(100))
+		bytesSent, bytesRcvd := pktsSent*uint64(60+rng.Intn(1400)), pktsRcvd*uint64(60+rng.Intn(1400))
+
+		dportBytes := make([]byte, types.DPortWidth)
+		binary.BigEndian.PutUint16(dportBytes, dport)
+		flowMap.SetOrUpdate(types.NewKey(sip.AsSlice(), dip.AsSlice(), dportBytes, proto), sip.Is4(),
+			bytesRcvd, bytesSent, pktsRcvd, pktsSent)
+	}
+	return flowMap
+}
+
+func referencePort(rng *rand.Rand) uint16 {
+	if rng.Intn(100) < ephemeralPortsPct {
+		return uint16(32768 + rng.Intn(28232))
+	}
+
+	total := 0
+	for _, weight := range referenceWeights {
+		total += weight
+	}
+	n := rng.Intn(total)
+	for i, weight := range referenceWeights {
+		if n < weight {
+			return referencePorts[i]
+		}
+		n -= weight
+	}
+	return referencePorts[0]
+}
+
+func internalHost(i int) netip.Addr {
+	return netip.AddrFrom4([4]byte{10, 0, byte(i / 16), byte(i%16 + 1)})
+}
+
+func externalHost(i int) netip.Addr {
+	return netip.AddrFrom4([4]byte{198, 51, 100, byte(i + 1)})
+}
+
+func v6Host(i int) netip.Addr {
+	return netip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, 15: byte(i + 1)})
+}
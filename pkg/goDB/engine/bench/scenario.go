@@ -0,0 +1,130 @@
+package bench
+
+import (
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/els0r/goProbe/pkg/goDB/engine"
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/types"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// ErrGoldenMismatch is returned if the results of a scenario don't match the golden ones
+var ErrGoldenMismatch = errors.New("results don't match golden results")
+
+// Scenario denotes a query run against the reference DB
+type Scenario struct {
+	Query     string // Query: the attributes to group by
+	Condition string // Condition: the conditional to filter flows by (if any)
+}
+
+// the attribute combinations and conditions covered by the scenarios
+var (
+	scenarioQueries = []string{
+		"sip", "dip", "dport", "proto", "sip,dip", "sip,dip,dport,proto", "time", "iface,sip",
+	}
+	scenarioConditions = []string{
+		"",
+		"dport = 443",
+		"proto = udp & snet = 10.0.0.0/8",
+		"dnet = 2001:db8::/32 | dport < 1024",
+		"!(dport = 443 | dport = 80) & proto = tcp",
+	}
+)
+
+// Scenarios returns all combinations of the covered attributes and conditions
+func Scenarios() []Scenario {
+	scenarios := make([]Scenario, 0, len(scenarioQueries)*len(scenarioConditions))
+	for _, q := range scenarioQueries {
+		for _, condition := range scenarioConditions {
+			scenarios = append(scenarios, Scenario{Query: q, Condition: condition})
+		}
+	}
+	return scenarios
+}
+
+// Name returns the name of the scenario (as used in the golden results)
+func (s Scenario) Name() string {
+	if s.Condition == "" {
+		return s.Query
+	}
+	return s.Query + " where " + s.Condition
+}
+
+// Args returns the query arguments of the scenario covering the full time range of the reference DB
+func (s Scenario) Args(ref Reference) *query.Args {
+	return query.NewArgs(s.Query, strings.Join(ref.Ifaces, ","),
+		query.WithFirst(strconv.FormatInt(ref.Start.Unix(), 10)),
+		query.WithLast(strconv.FormatInt(ref.End().Unix(), 10)),
+		query.WithCondition(s.Condition),
+		query.WithNumResults(query.MaxResults),
+		query.WithSortBy(results.SortTraffic.String()),
+	).AddOutputs(io.Discard)
+}
+
+// Run runs the scenario against the reference DB at path
+func (s Scenario) Run(ctx context.Context, ref Reference, path string) (*results.Result, error) {
+	res, err := engine.NewQueryRunner(path, engine.WithIdentity(Identity)).Run(ctx, s.Args(ref))
+	if err != nil {
+		return nil, fmt.Errorf("failed to run scenario `%s`: %w", s.Name(), err)
+	}
+	return res, nil
+}
+
+// Digest summarizes the results of a scenario for comparison with the golden results
+type Digest struct {
+	Hits   int            `json:"hits"`   // Hits: the number of rows
+	Totals types.Counters `json:"totals"` // Totals: the total counters of all flows
+	Rows   string         `json:"rows"`   // Rows: the SHA-256 hash of the (ordered) rows
+}
+
+// NewDigest computes the digest of the results
+func NewDigest(res *results.Result) (Digest, error) {
+	rows, err := jsoniter.Marshal(res.Rows)
+	if err != nil {
+		return Digest{}, fmt.Errorf("failed to serialize rows: %w", err)
+	}
+	hash := sha256.Sum256(rows)
+
+	return Digest{
+		Hits:   len(res.Rows),
+		Totals: res.Summary.Totals,
+		Rows:   hex.EncodeToString(hash[:]),
+	}, nil
+}
+
+// Golden maps the names of the scenarios to the digests of their results
+type Golden map[string]Digest
+
+//go:embed golden.json
+var goldenData []byte
+
+// DefaultGolden returns the golden results of the scenarios run against DefaultReference
+func DefaultGolden() (Golden, error) {
+	var golden Golden
+	if err := jsoniter.Unmarshal(goldenData, &golden); err != nil {
+		return nil, fmt.Errorf("failed to parse golden results: %w", err)
+	}
+	return golden, nil
+}
+
+// Verify compares the digest of a scenario to the golden one
+func (g Golden) Verify(s Scenario, digest Digest) error {
+	expected, exists := g[s.Name()]
+	if !exists {
+		return fmt.Errorf("%w: no golden results for scenario `%s`", ErrGoldenMismatch, s.Name())
+	}
+	if digest != expected {
+		return fmt.Errorf("%w for scenario `%s`: expected %+v, got %+v", ErrGoldenMismatch, s.Name(), expected, digest)
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"text/template"
 
 	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/results"
 )
 
 var (
@@ -276,7 +277,7 @@ func main() {
 				for cname := range testConditions {
 
 					// iterate over all formats
-					for format := range query.PermittedFormats {
+					for _, format := range results.Formats() {
 						tuples = append(tuples, TestTuple{
 							ID:        benchNum,
 							Iface:     iarg,
@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/goProbe/pkg/types/hashmap"
+)
+
+// dedupTolerance denotes the maximum relative difference of each counter for which two flows
+// are considered to be the same flow seen on different interfaces (allowing for packets dropped
+// by one of the mirror ports)
+const dedupTolerance = 0.01
+
+// deduplicate collapses flows which were seen identically (same key, matching counters) on
+// several interfaces, e.g. if both sides of a link are mirrored to different capture ports.
+// Each flow is kept on the first interface (in order of ifaces) it was seen on, the duplicates
+// on subsequent interfaces are zeroed in place (and hence have to be skipped upon building the
+// rows). The flows / counters removed are returned
+func deduplicate(maps hashmap.NamedAggFlowMapWithMetadata, ifaces []string) (dedup results.Deduplication) {
+	var duplicates []hashmap.Key
+	for i, iface := range ifaces {
+		aggMap, exists := maps[iface]
+		if !exists || aggMap.IsNil() {
+			continue
+		}
+
+		for _, m := range []*hashmap.Map{aggMap.PrimaryMap, aggMap.SecondaryMap} {
+			duplicates = duplicates[:0]
+			for it := m.Iter(); it.Next(); {
+				if isDuplicate(maps, ifaces[:i], it.Key(), it.Val(), m == aggMap.PrimaryMap) {
+					duplicates = append(duplicates, it.Key())
+					dedup.Flows++
+					dedup.Counters = dedup.Counters.Add(it.Val())
+				}
+			}
+
+			// the counters are only reset once the iteration is done (since modifying the map
+			// while iterating over it isn't safe)
+			for _, key := range duplicates {
+				m.Set(key, types.Counters{})
+			}
+		}
+	}
+	return
+}
+
+// isDuplicate checks if a flow was already seen on any of the preceding interfaces
+func isDuplicate(maps hashmap.NamedAggFlowMapWithMetadata, preceding []string, key hashmap.Key, val types.Counters, isPrimary bool) bool {
+	for _, iface := range preceding {
+		aggMap, exists := maps[iface]
+		if !exists || aggMap.IsNil() {
+			continue
+		}
+		m := aggMap.SecondaryMap
+		if isPrimary {
+			m = aggMap.PrimaryMap
+		}
+		if seen, found := m.Get(key); found && countersMatch(seen, val) {
+			return true
+		}
+	}
+	return false
+}
+
+// countersMatch checks if all counters of two flows are within the deduplication tolerance
+func countersMatch(a, b types.Counters) bool {
+	return withinTolerance(a.BytesRcvd, b.BytesRcvd) &&
+		withinTolerance(a.BytesSent, b.BytesSent) &&
+		withinTolerance(a.PacketsRcvd, b.PacketsRcvd) &&
+		withinTolerance(a.PacketsSent, b.PacketsSent)
+}
+
+func withinTolerance(a, b uint64) bool {
+	diff := max(a, b) - min(a, b)
+	return float64(diff) <= dedupTolerance*float64(max(a, b))
+}
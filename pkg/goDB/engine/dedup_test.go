@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/goProbe/pkg/types/hashmap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeduplicate(t *testing.T) {
+	key := func(i int) []byte {
+		var ip [4]byte
+		binary.BigEndian.PutUint32(ip[:], uint32(i))
+		return types.NewV4KeyStatic(ip, [4]byte{10, 0, 0, 1}, []byte{0, 80}, 6)
+	}
+	counters := func(bytes uint64) types.Counters {
+		return types.Counters{BytesRcvd: bytes, BytesSent: bytes / 2, PacketsRcvd: bytes / 100, PacketsSent: bytes / 200}
+	}
+
+	ifaces := []string{"eth0", "eth1", "eth2"}
+	maps := hashmap.NewNamedAggFlowMapWithMetadata(ifaces)
+
+	// flow 0 is mirrored to all interfaces, flow 1 to eth0 and eth2 (with a few packets lost on
+	// eth2), flow 2 has the same key on eth0 / eth1 but different counters
+	maps["eth0"].PrimaryMap.Set(key(0), counters(100000))
+	maps["eth1"].PrimaryMap.Set(key(0), counters(100000))
+	maps["eth2"].PrimaryMap.Set(key(0), counters(100000))
+	maps["eth0"].PrimaryMap.Set(key(1), counters(200000))
+	maps["eth2"].PrimaryMap.Set(key(1), counters(199000))
+	maps["eth0"].PrimaryMap.Set(key(2), counters(100000))
+	maps["eth1"].PrimaryMap.Set(key(2), counters(50000))
+	maps["eth1"].PrimaryMap.Set(key(3), counters(1000))
+
+	dedup := deduplicate(maps, ifaces)
+	require.Equal(t, 3, dedup.Flows)
+	require.Equal(t, counters(100000).Add(counters(100000)).Add(counters(199000)), dedup.Counters)
+
+	for _, iface := range ifaces {
+		var remaining int
+		for it := maps[iface].Iter(); it.Next(); {
+			if it.Val() != (types.Counters{}) {
+				remaining++
+			}
+		}
+		require.Equal(t, map[string]int{"eth0": 3, "eth1": 2, "eth2": 0}[iface], remaining, iface)
+	}
+
+	require.True(t, countersMatch(types.Counters{}, types.Counters{}))
+	require.False(t, countersMatch(types.Counters{PacketsRcvd: 1}, types.Counters{PacketsRcvd: 2}))
+}
@@ -0,0 +1,39 @@
+package engine
+
+import (
+	"github.com/els0r/goProbe/pkg/defaults"
+	"github.com/els0r/goProbe/pkg/metrics"
+)
+
+const (
+	querySubsystem = "query"
+)
+
+var queriesTotal = metrics.NewCounter(metrics.Opts{
+	Namespace: defaults.ServiceName,
+	Subsystem: querySubsystem,
+	Name:      "queries_total",
+	Help:      "Number of queries run against the DB",
+})
+var queryErrors = metrics.NewCounter(metrics.Opts{
+	Namespace: defaults.ServiceName,
+	Subsystem: querySubsystem,
+	Name:      "errors_total",
+	Help:      "Number of queries that failed",
+})
+var blocksSkipped = metrics.NewCounter(metrics.Opts{
+	Namespace: defaults.ServiceName,
+	Subsystem: querySubsystem,
+	Name:      "blocks_skipped_total",
+	Help:      "Number of DB blocks skipped because their attribute value ranges cannot satisfy the query condition",
+})
+
+var queryDuration = metrics.NewHistogram(metrics.HistogramOpts{
+	Opts: metrics.Opts{
+		Namespace: defaults.ServiceName,
+		Subsystem: querySubsystem,
+		Name:      "duration_seconds",
+		Help:      "Query execution time (excluding the preparation of the query statement)",
+	},
+	Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+})
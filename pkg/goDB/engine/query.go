@@ -4,10 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
+	"path"
 	"regexp"
 	"runtime"
 	"runtime/debug"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -17,11 +18,18 @@ import (
 	"github.com/els0r/goProbe/pkg/goDB"
 	"github.com/els0r/goProbe/pkg/goDB/conditions/node"
 	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/goDB/storage/gpfile"
 	"github.com/els0r/goProbe/pkg/query"
 	"github.com/els0r/goProbe/pkg/query/heap"
 	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/telemetry/tracing"
 	"github.com/els0r/goProbe/pkg/types"
 	"github.com/els0r/goProbe/pkg/types/hashmap"
+	"github.com/els0r/goProbe/pkg/types/hll"
+	"github.com/els0r/goProbe/pkg/util"
+	"github.com/els0r/telemetry/logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // QueryRunner implements the Runner interface to execute queries
@@ -30,85 +38,247 @@ type QueryRunner struct {
 	query          *goDB.Query
 	captureManager *capture.Manager
 	dbPath         string
+	identity       info.Identity
+
+	// maxBlocksPerSec is the read rate limit applied to queries not specifying one themselves (or
+	// exceeding it)
+	maxBlocksPerSec int
+
+	// defaultWorkers is the number of workers per interface used by queries not specifying it
+	// themselves, maxWorkers caps the number of workers a query may request
+	defaultWorkers, maxWorkers int
+}
+
+// QueryRunnerOption configures the query runner
+type QueryRunnerOption func(*QueryRunner)
+
+// WithIdentity sets the host identity the results are labeled with. Fields left empty are
+// taken from the identity stored in the DB (if any) or detected from the system
+func WithIdentity(identity info.Identity) QueryRunnerOption {
+	return func(qr *QueryRunner) {
+		qr.identity = identity
+	}
+}
+
+// WithMaxBlocksPerSec sets the number of blocks a query may read from disk per second. It applies to
+// queries that don't limit their read rate themselves and caps the limit of the ones that do
+func WithMaxBlocksPerSec(n int) QueryRunnerOption {
+	return func(qr *QueryRunner) {
+		qr.maxBlocksPerSec = n
+	}
+}
+
+// WithWorkers sets the number of workers reading the blocks of each interface concurrently for
+// queries not specifying it themselves (0: one per CPU) and the maximum a query may request
+// (0: one per CPU), e.g. to keep queries on a capturing host from competing with the capture
+func WithWorkers(defaultWorkers, maxWorkers int) QueryRunnerOption {
+	return func(qr *QueryRunner) {
+		qr.defaultWorkers = defaultWorkers
+		qr.maxWorkers = maxWorkers
+	}
 }
 
 // NewQueryRunner creates a new query runner
-func NewQueryRunner(dbPath string) *QueryRunner {
-	return &QueryRunner{
+func NewQueryRunner(dbPath string, opts ...QueryRunnerOption) *QueryRunner {
+	qr := &QueryRunner{
 		dbPath: dbPath,
 	}
+	for _, opt := range opts {
+		opt(qr)
+	}
+	return qr
 }
 
 // NewQueryRunnerWithLiveData creates a new query runner that acts on both DB and live data
-func NewQueryRunnerWithLiveData(dbPath string, captureManager *capture.Manager) *QueryRunner {
-	return &QueryRunner{
-		dbPath:         dbPath,
-		captureManager: captureManager,
+func NewQueryRunnerWithLiveData(dbPath string, captureManager *capture.Manager, opts ...QueryRunnerOption) *QueryRunner {
+	qr := NewQueryRunner(dbPath, opts...)
+	qr.captureManager = captureManager
+	return qr
+}
+
+// hostIdentity determines the identity of the host the queried flows were captured on
+func (qr *QueryRunner) hostIdentity(ctx context.Context) (info.Identity, error) {
+	identity := qr.identity
+	if identity.IsComplete() {
+		return identity, nil
+	}
+
+	// fill in what the capturing host stored in the DB
+	stored, err := info.ReadIdentity(qr.dbPath)
+	if err != nil {
+		logging.FromContext(ctx).With("error", err).Warn("failed to read host identity from DB, detecting it instead")
 	}
+	if identity.Hostname == "" {
+		identity.Hostname = stored.Hostname
+	}
+	if identity.HostID == "" {
+		identity.HostID = stored.HostID
+	}
+
+	return info.DetectIdentity(qr.dbPath, identity)
 }
 
 // Run implements the query.Runner interface
 func (qr *QueryRunner) Run(ctx context.Context, args *query.Args) (res *results.Result, err error) {
-	stmt, err := args.Prepare()
+	ctx, span := tracing.Start(ctx, "QueryRunner.Run", trace.WithAttributes(
+		attribute.String("query", args.Query),
+		attribute.String("ifaces", args.Ifaces),
+		attribute.String("condition", args.Condition),
+	))
+	defer func() {
+		tracing.Error(span, err)
+		span.End()
+	}()
+
+	stmt, err := prepareStatement(ctx, qr.dbPath, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return qr.RunStatement(ctx, stmt)
+}
+
+func prepareStatement(ctx context.Context, dbPath string, args *query.Args) (stmt *query.Statement, err error) {
+	_, span := tracing.Start(ctx, "Args.Prepare")
+	defer func() {
+		tracing.Error(span, err)
+		span.End()
+	}()
+
+	stmt, err = args.Prepare()
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare query statement: %w", err)
 	}
 
-	// get list of available interfaces in the local DB
-	stmt.Ifaces, err = parseIfaceList(qr.dbPath, args.Ifaces)
+	// interfaces may be referred to by their aliases
+	aliases, err := info.ReadAliases(dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare query statement: %w", err)
 	}
 
-	return qr.RunStatement(ctx, stmt)
+	// interface groups are expanded to their members (the groups provided in the query take
+	// precedence over the ones stored in the DB)
+	groups, err := info.ReadGroups(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare query statement: %w", err)
+	}
+	ifacelist, ifaceGroups, err := expandIfaceGroups(args.Ifaces, groups.With(args.IfaceGroups), aliases)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare query statement: %w", err)
+	}
+
+	// get list of available interfaces in the local DB (of the queried tenant)
+	stmt.Ifaces, err = parseIfaceList(info.TenantPath(dbPath, stmt.Tenant), ifacelist, aliases)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare query statement: %w", err)
+	}
+
+	// the rows of grouped interfaces are labeled with the group's name
+	if len(ifaceGroups) > 0 {
+		stmt.IfaceGroups = ifaceGroups
+		stmt.LabelSelector.Iface = true
+	}
+	return stmt, nil
 }
 
 // RunStatement executes the prepared statement and generates the results
 func (qr *QueryRunner) RunStatement(ctx context.Context, stmt *query.Statement) (res *results.Result, err error) {
+	queriesTotal.Inc()
+	defer func(start time.Time) {
+		queryDuration.ObserveDuration(time.Since(start))
+		if err != nil {
+			queryErrors.Inc()
+		}
+	}(time.Now())
+
 	result := results.New()
 	result.Start()
 	defer result.End()
 
 	// cross-check parameters
 	if len(stmt.Ifaces) == 0 {
-		return res, errors.New("no interfaces provided")
+		return res, query.ErrNoInterfaces
 	}
 
 	sort.Slice(stmt.Ifaces, func(i, j int) bool {
 		return stmt.Ifaces[i] < stmt.Ifaces[j]
 	})
-	result.Summary.Interfaces = stmt.Ifaces
+
+	// the results show the aliases of the interfaces (if assigned) or the groups they were selected by
+	aliases, aliasErr := info.ReadAliases(qr.dbPath)
+	if aliasErr != nil {
+		logging.FromContext(ctx).With("error", aliasErr).Warn("failed to read interface aliases from DB, showing interface names instead")
+	}
+	ifaceLabel := func(iface string) string {
+		if group, isGrouped := stmt.IfaceGroups[iface]; isGrouped {
+			return group
+		}
+		return aliases.Alias(iface)
+	}
+	result.Summary.Interfaces = make([]string, 0, len(stmt.Ifaces))
+	for _, iface := range stmt.Ifaces {
+		if label := ifaceLabel(iface); !slices.Contains(result.Summary.Interfaces, label) {
+			result.Summary.Interfaces = append(result.Summary.Interfaces, label)
+		}
+	}
 
 	// parse query
 	queryAttributes, _, err := types.ParseQueryType(stmt.QueryType)
 	if err != nil {
-		return res, fmt.Errorf("failed to parse query type: %w", err)
+		return res, fmt.Errorf("%w: %w", query.ErrInvalidQueryType, err)
 	}
 
 	// build condition tree to check if there is a syntax error before starting processing
 	queryConditional, parseErr := node.ParseAndInstrument(stmt.Condition, stmt.DNSResolution.Timeout)
 	if parseErr != nil {
-		return res, fmt.Errorf("conditions parsing error: %w", parseErr)
+		return res, fmt.Errorf("%w: %w", query.ErrInvalidCondition, parseErr)
+	}
+
+	// the distinct attribute is queried in addition to the query attributes. The rows are collapsed
+	// by it after aggregation
+	dbAttributes := queryAttributes
+	var distinctValue func(types.Key) []byte
+	if stmt.Distinct != "" {
+		distinct, err := types.NewAttribute(stmt.Distinct)
+		if err != nil {
+			return res, fmt.Errorf("%w: %w", query.ErrInvalidArgs, err)
+		}
+		dbAttributes = append(slices.Clip(queryAttributes), distinct)
+		distinctValue = distinctValueFunc(distinct)
+	}
+
+	// the time distribution is computed from the per-block flows, which are collapsed into the
+	// rows after aggregation
+	dbSelector := stmt.LabelSelector
+	if stmt.Distribution {
+		dbSelector.Timestamp = true
 	}
 
-	qr.query = goDB.NewQuery(queryAttributes, queryConditional, stmt.LabelSelector).LowMem(stmt.LowMem)
+	qr.query = goDB.NewQuery(dbAttributes, queryConditional, dbSelector).LowMem(stmt.LowMem).IPVersion(stmt.IPVersion).TimeWindows(stmt.Windows).MaxDropRate(stmt.MaxDropPct / 100).Verify(stmt.Verify)
 	if qr.query == nil {
 		return res, errors.New("query is not executable")
 	}
 
+	maxBlocksPerSec := stmt.MaxBlocksPerSec
+	if maxBlocksPerSec == 0 || (qr.maxBlocksPerSec > 0 && maxBlocksPerSec > qr.maxBlocksPerSec) {
+		maxBlocksPerSec = qr.maxBlocksPerSec
+	}
+	qr.query.MaxBlocksPerSec(maxBlocksPerSec)
+
 	result.Query = results.Query{
-		Attributes: qr.query.AttributesToString(),
+		Attributes: qr.query.AttributesToString()[:len(queryAttributes)],
+		Distinct:   stmt.Distinct,
 	}
-	if qr.query.Conditional != nil {
-		result.Query.Condition = qr.query.Conditional.String()
+	if queryConditional != nil {
+		result.Query.Condition = queryConditional.String()
 	}
 
 	// get hostname and host ID if available
-	hostname, err := os.Hostname()
+	identity, err := qr.hostIdentity(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get system hostname: %w", err)
+		return nil, err
 	}
-	hostID := info.GetHostID(qr.dbPath)
+	hostname, hostID := identity.Hostname, identity.HostID
 
 	// assign the hostname to the list of hosts handled in this query. Here, the only one
 	defer func() {
@@ -119,19 +289,24 @@ func (qr *QueryRunner) RunStatement(ctx context.Context, stmt *query.Statement)
 	heapWatchCtx, cancelHeapWatch := context.WithCancel(ctx)
 	defer cancelHeapWatch()
 
-	memErrors := heap.Watch(heapWatchCtx, stmt.MaxMemPct)
+	memUsage := new(heap.Usage)
+	memErrors := heap.Watch(heapWatchCtx, stmt.MaxMemPct, memUsage)
 
 	queryCtx, cancelQuery := context.WithCancel(ctx)
 	defer cancelQuery()
 
 	// Channel for handling of returned maps
 	mapChan := make(chan hashmap.AggFlowMapWithMetadata, 1024)
-	aggregateChan := aggregate(mapChan, stmt.Ifaces, stmt.LowMem)
+	var sketch *topKSketch
+	if stmt.Approx {
+		sketch = newTopKSketch(stmt.NumResults, stmt.SortBy, stmt.Direction)
+	}
+	aggregateChan := aggregate(mapChan, stmt.Ifaces, stmt.LowMem, sketch)
 
 	go func() {
 		select {
 		case err = <-memErrors:
-			err = fmt.Errorf("%w: %w", errorMemoryBreach, err)
+			err = fmt.Errorf("%w: %w", query.ErrMemoryBreach, err)
 			cancelQuery()
 
 			// close the map channel. This will make sure that the aggregation routine
@@ -153,16 +328,18 @@ func (qr *QueryRunner) RunStatement(ctx context.Context, stmt *query.Statement)
 	}()
 
 	result.Query = results.Query{
-		Attributes: qr.query.AttributesToString(),
+		Attributes: qr.query.AttributesToString()[:len(queryAttributes)],
+		Distinct:   stmt.Distinct,
 	}
-	if qr.query.Conditional != nil {
-		result.Query.Condition = qr.query.Conditional.String()
+	if queryConditional != nil {
+		result.Query.Condition = queryConditional.String()
 	}
 
 	// create work managers
+	workers := qr.numWorkers(stmt.Workers)
 	workManagers := map[string]*goDB.DBWorkManager{} // map interfaces to workManagers
 	for _, iface := range stmt.Ifaces {
-		wm, nonempty, err := createWorkManager(qr.dbPath, iface, stmt.First, stmt.Last, qr.query, numProcessingUnits)
+		wm, nonempty, err := createWorkManager(info.TenantPath(qr.dbPath, stmt.Tenant), iface, stmt.First, stmt.Last, qr.query, workers)
 		if err != nil {
 			return res, err
 		}
@@ -187,7 +364,25 @@ func (qr *QueryRunner) RunStatement(ctx context.Context, stmt *query.Statement)
 	result.Summary.First = tSpanFirst
 	result.Summary.Last = tSpanLast
 
+	// explained queries only report which data would be read
+	if stmt.Explain {
+		close(mapChan)
+		<-aggregateChan
+
+		plan := &results.QueryPlan{
+			Columns:     qr.query.Columns(),
+			Directories: make(map[string]int),
+			Workers:     workers,
+		}
+		for iface, workManager := range workManagers {
+			plan.Directories[aliases.Alias(iface)] = workManager.GetNumDirs()
+		}
+		result.Query.Plan = plan
+		return result, nil
+	}
+
 	// If enabled, run a live query in the background / parallel to the DB query and put the results on the same output channel
+	scanStart := time.Now()
 	liveQueryWG := qr.runLiveQuery(ctx, mapChan, stmt)
 
 	// spawn reader processing units and make them work on the individual DB blocks
@@ -204,7 +399,26 @@ func (qr *QueryRunner) RunStatement(ctx context.Context, stmt *query.Statement)
 
 	// wait for the job to complete, then call a garbage collection
 	agg := <-aggregateChan
+	result.Summary.Timings.ScanDuration = time.Since(scanStart)
+
+	// the aggregated maps are still held at this point, i.e. the memory usage is close to its peak
+	memUsage.Sample()
+	resources := &results.Resources{}
+	var captureQuality results.CaptureQuality
 	for _, workManager := range workManagers {
+		blocksSkipped.Add(float64(workManager.GetNumBlocksSkipped()))
+		resources.BlocksScanned += workManager.GetNumBlocksScanned()
+		resources.BlocksSkipped += workManager.GetNumBlocksSkipped()
+		resources.BytesDecompressed += workManager.GetNumBytesDecompressed()
+
+		traffic := workManager.GetCaptureQuality()
+		captureQuality = captureQuality.Merge(results.CaptureQuality{
+			Packets:        traffic.NumPackets,
+			Dropped:        traffic.NumDrops,
+			Overruns:       traffic.NumOverruns,
+			Truncated:      traffic.NumTruncated,
+			BlocksExcluded: workManager.GetNumBlocksExcluded(),
+		})
 		workManager.Close()
 		workManager = nil
 	}
@@ -215,14 +429,27 @@ func (qr *QueryRunner) RunStatement(ctx context.Context, stmt *query.Statement)
 		return res, err
 	}
 
+	// a query that was interrupted by its deadline has incomplete results
+	if ctxErr := ctx.Err(); errors.Is(ctxErr, context.DeadlineExceeded) {
+		return res, fmt.Errorf("%w: %w", query.ErrQueryTimeout, ctxErr)
+	}
+
 	// check aggregation for errors
 	if agg.err != nil {
 		return res, agg.err
 	}
 
 	/// RESULTS PREPARATION ///
+	finalizeStart := time.Now()
+
+	// flows seen identically on several interfaces (e.g. mirrored traffic) are only counted once
+	if stmt.Dedup && agg.aggregatedMaps != nil {
+		dedup := deduplicate(agg.aggregatedMaps, stmt.Ifaces)
+		agg.totals = agg.totals.Sub(dedup.Counters)
+		result.Summary.Deduplication = &dedup
+	}
 	var sip, dip, dport, proto types.Attribute
-	for _, attribute := range qr.query.Attributes {
+	for _, attribute := range queryAttributes {
 		switch attribute.Name() {
 		case types.SIPName:
 			sip = attribute
@@ -238,38 +465,88 @@ func (qr *QueryRunner) RunStatement(ctx context.Context, stmt *query.Statement)
 	var rs = make(results.Rows, agg.aggregatedMaps.Len())
 	count := 0
 
+	// rows differing only in the distinct attribute or timestamp (or stemming from interfaces of
+	// the same group) are collapsed into a single row
+	var mergedRows map[results.MergeableAttributes]int
+	if distinctValue != nil || stmt.Distribution || len(stmt.IfaceGroups) > 0 {
+		mergedRows = make(map[results.MergeableAttributes]int)
+	}
+
+	// the distribution spans the queried range, which is capped at the current time (so that
+	// open-ended queries don't end up in the first interval)
+	distFirst, distLast := stmt.First, min(stmt.Last, time.Now().Unix())
+
 	for iface, aggMap := range agg.aggregatedMaps {
+		ifaceLabel := ifaceLabel(iface)
 		for i := aggMap.Iter(); i.Next(); {
 
 			key := types.ExtendedKey(i.Key())
 			val := i.Val()
 
-			if ts, hasTS := key.AttrTime(); hasTS {
-				rs[count].Labels.Timestamp = time.Unix(ts, 0)
+			// skip duplicates removed by deduplicate()
+			if stmt.Dedup && val == (types.Counters{}) {
+				continue
+			}
+
+			var row results.Row
+			ts, hasTS := key.AttrTime()
+			if hasTS && !stmt.Distribution {
+				row.Labels.Timestamp = time.Unix(ts, 0)
+			}
+			row.Labels.Iface = ifaceLabel
+			if labelsID, hasLabels := key.AttrLabels(); hasLabels {
+				setLabels(&row.Labels, qr.query.LabelColumns(), qr.query.Labels(labelsID))
+			}
+			if dir, hasDir := key.AttrDirection(); hasDir {
+				row.Labels.Direction = dir.String()
 			}
-			rs[count].Labels.Iface = iface
 
 			// the host ID and hostname are statically assigned since a goDB is inherently limited to the
 			// system it runs on. The two parameters never change during query execution
-			rs[count].Labels.HostID = hostID
-			rs[count].Labels.Hostname = hostname
+			row.Labels.HostID = hostID
+			row.Labels.Hostname = hostname
 
 			if sip != nil {
-				rs[count].Attributes.SrcIP = types.RawIPToAddr(key.Key().GetSIP())
+				row.Attributes.SrcIP = types.RawIPToAddr(key.Key().GetSIP())
 			}
 			if dip != nil {
-				rs[count].Attributes.DstIP = types.RawIPToAddr(key.Key().GetDIP())
+				row.Attributes.DstIP = types.RawIPToAddr(key.Key().GetDIP())
 			}
 			if proto != nil {
-				rs[count].Attributes.IPProto = key.Key().GetProto()
+				row.Attributes.IPProto = key.Key().GetProto()
 			}
 			if dport != nil {
-				rs[count].Attributes.DstPort = types.PortToUint16(key.Key().GetDport())
+				row.Attributes.DstPort = types.PortToUint16(key.Key().GetDport())
+			}
+
+			if mergedRows == nil {
+				row.Counters = val
+				rs[count] = row
+				count++
+				continue
 			}
 
-			// assign / update counters
-			rs[count].Counters = rs[count].Counters.Add(val)
-			count++
+			// assign / update counters and count the distinct value (if any)
+			idx, exists := mergedRows[results.MergeableAttributes{Labels: row.Labels, Attributes: row.Attributes}]
+			if !exists {
+				idx = count
+				mergedRows[results.MergeableAttributes{Labels: row.Labels, Attributes: row.Attributes}] = idx
+				if distinctValue != nil {
+					row.Distinct = hll.New()
+				}
+				if stmt.Distribution {
+					row.Distribution = new(results.Distribution)
+				}
+				rs[idx] = row
+				count++
+			}
+			rs[idx].Counters = rs[idx].Counters.Add(val)
+			if distinctValue != nil {
+				rs[idx].Distinct.Add(distinctValue(key.Key()))
+			}
+			if stmt.Distribution {
+				rs[idx].Distribution.Add(results.Bucket(ts, distFirst, distLast), val.SumBytes())
+			}
 		}
 
 		// Now is a good time to release memory one last time for the final processing step
@@ -281,11 +558,38 @@ func (qr *QueryRunner) RunStatement(ctx context.Context, stmt *query.Statement)
 		runtime.GC()
 	}
 
+	rs = rs[:count]
+
 	result.Summary.Totals = agg.totals
+	result.Summary.Gaps = coverageGaps(stmt, workManagers, aliases, hostname)
+	result.Summary.Corruptions = corruptions(stmt, workManagers, aliases, hostname)
+	if stmt.Anomalies {
+		result.Summary.Anomalies, err = anomalies(info.TenantPath(qr.dbPath, stmt.Tenant), stmt, aliases, hostname)
+		if err != nil {
+			logging.FromContext(ctx).With("error", err).Warn("failed to compare traffic with baseline, omitting anomalies")
+			err = nil
+		}
+	}
+	if captureQuality != (results.CaptureQuality{}) {
+		result.Summary.CaptureQuality = &captureQuality
+	}
+	if sketch != nil {
+		result.Summary.Approximation = &results.Approximation{
+			Capacity: sketch.capacity,
+			MaxError: sketch.maxError(),
+		}
+	}
 
 	// sort the results
 	results.By(stmt.SortBy, stmt.Direction, stmt.SortAscending).Sort(rs)
 
+	// apply the filters on hostnames / enriched columns (if any) before the rows are limited
+	result.Rows = rs
+	if err := stmt.FilterRows(ctx, result); err != nil {
+		return nil, fmt.Errorf("failed to filter rows: %w", err)
+	}
+	rs = result.Rows
+
 	// stop timing everything related to the query and store the hits
 	result.Summary.Hits.Total = len(rs)
 
@@ -295,19 +599,212 @@ func (qr *QueryRunner) RunStatement(ctx context.Context, stmt *query.Statement)
 	result.Summary.Hits.Displayed = len(rs)
 	result.Rows = rs
 
+	result.Summary.Timings.FinalizeDuration = time.Since(finalizeStart)
+	resources.PeakMemory = memUsage.Peak()
+	result.Summary.Resources = resources
+
 	return result, nil
 }
 
+// numWorkers determines the number of workers reading the blocks of each interface. Requests
+// exceeding the maximum of the runner (or the number of CPUs) are capped
+func (qr *QueryRunner) numWorkers(requested int) int {
+	workers := requested
+	if workers == 0 {
+		workers = qr.defaultWorkers
+	}
+	if workers == 0 || workers > numProcessingUnits {
+		workers = numProcessingUnits
+	}
+	if qr.maxWorkers > 0 && workers > qr.maxWorkers {
+		workers = qr.maxWorkers
+	}
+	return workers
+}
+
+// distinctValueFunc returns a function extracting the raw value of the distinct attribute from a key
+func distinctValueFunc(distinct types.Attribute) func(types.Key) []byte {
+	switch distinct.Name() {
+	case types.SIPName:
+		return types.Key.GetSIP
+	case types.DIPName:
+		return types.Key.GetDIP
+	case types.DportName:
+		return types.Key.GetDport
+	default:
+		return func(k types.Key) []byte {
+			return []byte{k.GetProto()}
+		}
+	}
+}
+
+// setLabels assigns the values of the queried label columns (see goDB.Query.LabelColumns()) to the
+// labels of a row
+func setLabels(labels *results.Labels, columns, values []string) {
+	var columnValues map[string]string
+	for i, column := range columns {
+		if column == types.ProcessName {
+			labels.Process = values[i]
+			continue
+		}
+		if columnValues == nil {
+			columnValues = make(map[string]string, len(columns))
+		}
+		columnValues[column] = values[i]
+	}
+	labels.Columns = results.NewLabelColumns(columnValues)
+}
+
+// coverageGaps collects the intervals of the queried range (or its time windows) for which the interfaces
+// lack data (including the ones during which their link was down). Interfaces without any data in the
+// queried range lack it for the whole range
+func coverageGaps(stmt *query.Statement, workManagers map[string]*goDB.DBWorkManager, aliases info.Aliases, hostname string) (gaps []results.CoverageGap) {
+	tfirst, tlast := stmt.First, min(stmt.Last, time.Now().Unix())
+	for _, iface := range stmt.Ifaces {
+		var ifaceGaps, linkDown []results.TimeRange
+		if workManager, exists := workManagers[iface]; exists {
+			ifaceGaps = workManager.GetCoverageGaps(tfirst, tlast)
+			linkDown = workManager.GetLinkDownIntervals(tfirst, tlast)
+		} else if len(stmt.Windows) > 0 {
+			for _, window := range stmt.Windows {
+				if wlast := min(window.Last, tlast); wlast > window.First {
+					ifaceGaps = append(ifaceGaps, results.TimeRange{First: time.Unix(window.First, 0), Last: time.Unix(wlast, 0)})
+				}
+			}
+		} else if tlast > tfirst {
+			ifaceGaps = []results.TimeRange{{First: time.Unix(tfirst, 0), Last: time.Unix(tlast, 0)}}
+		}
+
+		for _, gap := range ifaceGaps {
+			gaps = append(gaps, results.CoverageGap{
+				Iface:     aliases.Alias(iface),
+				Hostname:  hostname,
+				TimeRange: gap,
+			})
+		}
+		for _, interval := range linkDown {
+			gaps = append(gaps, results.CoverageGap{
+				Iface:     aliases.Alias(iface),
+				Hostname:  hostname,
+				LinkDown:  true,
+				TimeRange: interval,
+			})
+		}
+	}
+	return gaps
+}
+
+// corruptions collects the inconsistent columns found while verifying the blocks of the interfaces (if
+// requested)
+func corruptions(stmt *query.Statement, workManagers map[string]*goDB.DBWorkManager, aliases info.Aliases, hostname string) (corruptions []results.Corruption) {
+	if !stmt.Verify {
+		return nil
+	}
+	for _, iface := range stmt.Ifaces {
+		workManager, exists := workManagers[iface]
+		if !exists {
+			continue
+		}
+		for _, corruption := range workManager.GetCorruptions() {
+			corruption.Iface = aliases.Alias(iface)
+			corruption.Hostname = hostname
+			corruptions = append(corruptions, corruption)
+		}
+	}
+	return corruptions
+}
+
+const (
+	// anomalyBaselineDays denotes the number of preceding days the baseline of an interface is computed from
+	anomalyBaselineDays = 7
+
+	// anomalyFactor denotes the factor by which the traffic of an interface has to deviate from its baseline
+	// (in either direction) to be flagged as unusual
+	anomalyFactor = 2.0
+)
+
+// anomalies compares the traffic of each interface over (the last day of) the queried range with its baseline,
+// i.e. the average traffic over the same period of the preceding days (with data). Interfaces without a baseline
+// aren't assessed
+func anomalies(dbPath string, stmt *query.Statement, aliases info.Aliases, hostname string) ([]results.Anomaly, error) {
+	var anomalies []results.Anomaly
+	tlast := min(stmt.Last, time.Now().Unix())
+	tfirst := max(stmt.First, tlast-gpfile.EpochDay)
+	for _, iface := range stmt.Ifaces {
+		totals, numBlocks, lastBlock, err := goDB.IfaceTraffic(dbPath, iface, tfirst, tlast)
+		if err != nil {
+			return nil, err
+		}
+
+		// the traffic since the last writeout isn't in the DB yet, hence the window (and the ones of
+		// the baseline) end with the last block written
+		ifaceTlast := tlast
+		if numBlocks > 0 {
+			ifaceTlast = lastBlock
+		}
+
+		var baseline types.Counters
+		baselineDays := 0
+		for day := int64(1); day <= anomalyBaselineDays; day++ {
+			shift := day * gpfile.EpochDay
+			dayTotals, numBlocks, _, err := goDB.IfaceTraffic(dbPath, iface, tfirst-shift, ifaceTlast-shift)
+			if err != nil {
+				return nil, err
+			}
+			if numBlocks > 0 {
+				baseline = baseline.Add(dayTotals)
+				baselineDays++
+			}
+		}
+		if baseline.SumBytes() == 0 {
+			continue
+		}
+
+		n := uint64(baselineDays)
+		baseline = types.Counters{
+			BytesRcvd:   baseline.BytesRcvd / n,
+			BytesSent:   baseline.BytesSent / n,
+			PacketsRcvd: baseline.PacketsRcvd / n,
+			PacketsSent: baseline.PacketsSent / n,
+		}
+		ratio := float64(totals.SumBytes()) / float64(max(baseline.SumBytes(), 1))
+		anomalies = append(anomalies, results.Anomaly{
+			Iface:        aliases.Alias(iface),
+			Hostname:     hostname,
+			TimeRange:    results.TimeRange{First: time.Unix(tfirst, 0), Last: time.Unix(ifaceTlast, 0)},
+			Totals:       totals,
+			Baseline:     baseline,
+			BaselineDays: baselineDays,
+			Ratio:        ratio,
+			Unusual:      ratio >= anomalyFactor || ratio <= 1/anomalyFactor,
+		})
+	}
+	return anomalies, nil
+}
+
 func (qr *QueryRunner) runLiveQuery(ctx context.Context, mapChan chan hashmap.AggFlowMapWithMetadata, stmt *query.Statement) (wg *sync.WaitGroup) {
 	wg = new(sync.WaitGroup)
 
-	if !stmt.Live {
+	// without a capture manager, there is no live data to be queried
+	if !stmt.Live || qr.captureManager == nil {
+		return
+	}
+
+	// only the interfaces assigned to the queried tenant are considered (note that an empty
+	// list of interfaces would cause all of them to be fetched)
+	var ifaces []string
+	for iface, cfg := range qr.captureManager.Config(stmt.Ifaces...) {
+		if cfg.Tenant == stmt.Tenant {
+			ifaces = append(ifaces, iface)
+		}
+	}
+	if len(ifaces) == 0 {
 		return
 	}
 
 	wg.Add(1)
 	go func() {
-		qr.captureManager.GetFlowMaps(ctx, goDB.QueryFilter(qr.query), mapChan, stmt.Ifaces...)
+		qr.captureManager.GetFlowMaps(ctx, goDB.QueryFilter(qr.query), mapChan, ifaces...)
 		wg.Done()
 	}()
 
@@ -323,9 +820,13 @@ func createWorkManager(dbPath string, iface string, tfirst, tlast int64, query *
 	return
 }
 
-func parseIfaceList(dbPath string, ifacelist string) ([]string, error) {
+// parseIfaceList parses the interface list of a query. Apart from interface names (or their
+// aliases), it supports selecting all interfaces ("any"), wildcards (e.g. "eth*") and negations
+// (e.g. "!docker0"), which are resolved against the interfaces present in the DB. A list containing
+// negations only selects all interfaces except the negated ones
+func parseIfaceList(dbPath string, ifacelist string, aliases info.Aliases) ([]string, error) {
 	if ifacelist == "" {
-		return nil, errors.New("no interface(s) specified")
+		return nil, query.ErrNoInterfaces
 	}
 
 	if strings.ToLower(ifacelist) == "any" {
@@ -336,34 +837,209 @@ func parseIfaceList(dbPath string, ifacelist string) ([]string, error) {
 		return ifaces, nil
 	}
 
-	ifaces, err := validateIfaceNames(ifacelist)
+	includes, excludes, err := parseIfaceSelectors(ifacelist)
 	if err != nil {
 		return nil, err
 	}
 
+	// a plain list of interfaces doesn't require to resolve anything against the DB
+	if len(excludes) == 0 && !slices.ContainsFunc(includes, isIfaceSelector) {
+		for i, iface := range includes {
+			includes[i] = aliases.Resolve(iface)
+		}
+		return includes, nil
+	}
+
+	available, err := info.GetInterfaces(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(includes) == 0 {
+		includes = []string{"any"}
+	}
+
+	// the tunnel metadata is only required (and read once) if tunnels are selected by it
+	var tunnels map[string]util.TunnelInfo
+	if slices.ContainsFunc(includes, isTunnelSelector) || slices.ContainsFunc(excludes, isTunnelSelector) {
+		if tunnels, err = util.TunnelInfos(); err != nil {
+			return nil, err
+		}
+	}
+
+	var ifaces []string
+	for _, iface := range available {
+		matches := func(selector string) bool {
+			return matchIface(selector, iface, aliases, tunnels)
+		}
+		if slices.ContainsFunc(includes, matches) && !slices.ContainsFunc(excludes, matches) {
+			ifaces = append(ifaces, iface)
+		}
+	}
+	if len(ifaces) == 0 {
+		return nil, fmt.Errorf("%w: no interface in the DB matches `%s`", query.ErrNoInterfaces, ifacelist)
+	}
+
 	return ifaces, nil
 }
 
-var ifaceNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9\.:_-]{1,15}$`)
+// expandIfaceGroups replaces the interface groups in the interface list by their members (which are
+// selected or negated like the group). The returned map assigns the interfaces selected via a
+// group to the group. An interface can't be selected via more than one group, since its flows
+// would have to be attributed to both
+func expandIfaceGroups(ifacelist string, groups info.Groups, aliases info.Aliases) (string, map[string]string, error) {
+	if len(groups) == 0 || ifacelist == "" {
+		return ifacelist, nil, nil
+	}
+
+	var (
+		expanded    []string
+		ifaceGroups map[string]string
+	)
+	for _, selector := range strings.Split(ifacelist, ",") {
+		name, isNegated := strings.CutPrefix(selector, "!")
+		members, isGroup := groups[name]
+		if !isGroup {
+			expanded = append(expanded, selector)
+			continue
+		}
+		for _, member := range members {
+			if isNegated {
+				expanded = append(expanded, "!"+member)
+				continue
+			}
+
+			iface := aliases.Resolve(member)
+			if group, isGrouped := ifaceGroups[iface]; isGrouped {
+				if group == name {
+					continue
+				}
+				return "", nil, fmt.Errorf("%w: interface `%s` is selected via groups `%s` and `%s`", query.ErrInvalidInterface, member, group, name)
+			}
+			if ifaceGroups == nil {
+				ifaceGroups = make(map[string]string)
+			}
+			ifaceGroups[iface] = name
+			if !slices.Contains(expanded, member) {
+				expanded = append(expanded, member)
+			}
+		}
+	}
+	return strings.Join(expanded, ","), ifaceGroups, nil
+}
+
+// parseIfaceSelectors splits the interface list into the selected and negated entries
+func parseIfaceSelectors(ifacelist string) (includes, excludes []string, err error) {
+	for _, selector := range strings.Split(ifacelist, ",") {
+		negated, isNegated := strings.CutPrefix(selector, "!")
+		if err := validateIfaceSelector(negated); err != nil {
+			return nil, nil, err
+		}
+		if isNegated {
+			excludes = append(excludes, negated)
+		} else {
+			includes = append(includes, selector)
+		}
+	}
+	return includes, excludes, nil
+}
+
+// isIfaceSelector returns whether an entry of the interface list selects interfaces by pattern
+// (or by the metadata of tunnel interfaces)
+func isIfaceSelector(selector string) bool {
+	return strings.EqualFold(selector, "any") || strings.ContainsAny(selector, ifaceWildcards) || isTunnelSelector(selector)
+}
+
+// tunnel interfaces can be selected by their metadata, e.g. "site=zurich" or "peer=198.51.100.*"
+const (
+	tunnelFieldPeer = "peer"
+	tunnelFieldSite = "site"
+)
+
+func isTunnelSelector(selector string) bool {
+	field, _, found := strings.Cut(selector, "=")
+	return found && (field == tunnelFieldPeer || field == tunnelFieldSite)
+}
+
+// matchTunnel determines whether an interface (or its alias) is a tunnel whose metadata matches
+// a tunnel selector
+func matchTunnel(selector, iface string, aliases info.Aliases, tunnels map[string]util.TunnelInfo) bool {
+	tunnel, isTunnel := tunnels[iface]
+	if !isTunnel {
+		if tunnel, isTunnel = tunnels[aliases.Alias(iface)]; !isTunnel {
+			return false
+		}
+	}
+
+	field, pattern, _ := strings.Cut(selector, "=")
+	value := tunnel.Peer
+	if field == tunnelFieldSite {
+		value = tunnel.Site
+	}
+	matched, _ := path.Match(pattern, value)
+	return matched
+}
+
+// matchIface determines whether an interface (or its alias) is selected by an entry of the
+// interface list
+func matchIface(selector, iface string, aliases info.Aliases, tunnels map[string]util.TunnelInfo) bool {
+	if strings.EqualFold(selector, "any") {
+		return true
+	}
+	if isTunnelSelector(selector) {
+		return matchTunnel(selector, iface, aliases, tunnels)
+	}
+	if matched, _ := path.Match(selector, iface); matched {
+		return true
+	}
+	if alias := aliases.Alias(iface); alias != iface {
+		matched, _ := path.Match(selector, alias)
+		return matched
+	}
+	return false
+}
+
+var (
+	ifaceNameRegexp    = regexp.MustCompile(`^[a-zA-Z0-9\.:_-]{1,15}$`)
+	ifacePatternRegexp = regexp.MustCompile(`^[a-zA-Z0-9\.:_\-*?\[\]^]{1,64}$`)
+)
+
+// ifaceWildcards denotes the characters turning an entry of the interface list into a pattern
+const ifaceWildcards = "*?["
 
 func validateIfaceName(iface string) error {
 	if iface == "" {
-		return errors.New("interface list contains empty interface name")
+		return fmt.Errorf("%w: interface list contains empty interface name", query.ErrInvalidInterface)
 	}
 
 	if !ifaceNameRegexp.MatchString(iface) {
-		return fmt.Errorf("interface name `%s` is invalid", iface)
+		return fmt.Errorf("%w: interface name `%s` is invalid", query.ErrInvalidInterface, iface)
 	}
 
 	return nil
 }
 
-func validateIfaceNames(ifacelist string) ([]string, error) {
-	ifaces := strings.Split(ifacelist, ",")
-	for _, iface := range ifaces {
-		if err := validateIfaceName(iface); err != nil {
-			return nil, err
+// validateIfaceSelector validates an interface name or pattern (see path.Match for its syntax)
+func validateIfaceSelector(selector string) error {
+	if !isIfaceSelector(selector) {
+		return validateIfaceName(selector)
+	}
+	if isTunnelSelector(selector) {
+		_, pattern, _ := strings.Cut(selector, "=")
+		if pattern == "" {
+			return fmt.Errorf("%w: tunnel selector `%s` is empty", query.ErrInvalidInterface, selector)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%w: tunnel selector `%s` is invalid: %w", query.ErrInvalidInterface, selector, err)
 		}
+		return nil
 	}
-	return ifaces, nil
+
+	if !ifacePatternRegexp.MatchString(selector) {
+		return fmt.Errorf("%w: interface pattern `%s` is invalid", query.ErrInvalidInterface, selector)
+	}
+	if _, err := path.Match(selector, ""); err != nil {
+		return fmt.Errorf("%w: interface pattern `%s` is invalid: %w", query.ErrInvalidInterface, selector, err)
+	}
+
+	return nil
 }
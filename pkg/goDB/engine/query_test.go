@@ -3,11 +3,27 @@ package engine
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"testing"
+	"time"
 
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/goProbe/pkg/goDB"
+	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/goDB/storage/gpfile"
 	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/results"
 	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/goProbe/pkg/types/hashmap"
+	"github.com/els0r/goProbe/pkg/util"
+	"github.com/fako1024/gotools/bitpack"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -97,19 +113,19 @@ func TestInterfaceValidation(t *testing.T) {
 	}{
 		{
 			"",
-			errors.New("interface list contains empty interface name"),
+			errors.New("invalid interface: interface list contains empty interface name"),
 		},
 		{
 			"eth/0",
-			errors.New("interface name `eth/0` is invalid"),
+			errors.New("invalid interface: interface name `eth/0` is invalid"),
 		},
 		{
 			"eth 0",
-			errors.New("interface name `eth 0` is invalid"),
+			errors.New("invalid interface: interface name `eth 0` is invalid"),
 		},
 		{
 			"thisinterfacenameisfartoolongtobesupported",
-			errors.New("interface name `thisinterfacenameisfartoolongtobesupported` is invalid"),
+			errors.New("invalid interface: interface name `thisinterfacenameisfartoolongtobesupported` is invalid"),
 		},
 		{
 			"eth.15",
@@ -129,6 +145,9 @@ func TestInterfaceValidation(t *testing.T) {
 				if err == nil || err.Error() != test.expectedErr.Error() {
 					t.Fatalf("unexpected result for interface name validation: %s", err)
 				}
+				if !errors.Is(err, query.ErrInvalidInterface) {
+					t.Fatalf("unexpected error class for interface name validation: %s", err)
+				}
 			} else {
 				if err != nil {
 					t.Fatalf("unexpected result for interface name validation: %s", err)
@@ -137,3 +156,1021 @@ func TestInterfaceValidation(t *testing.T) {
 		})
 	}
 }
+
+// writeTestFlows writes two IPv4 flows and one IPv6 flow for the interface to the DB at dbPath
+func writeTestFlows(t *testing.T, dbPath, iface string) {
+	flows := hashmap.NewAggFlowMap()
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6), hashmap.Val{PacketsRcvd: 1})
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 3}, [4]byte{10, 0, 0, 2}, []byte{0, 53}, 17), hashmap.Val{PacketsRcvd: 2})
+	flows.SecondaryMap.Set(types.NewV6KeyStatic([16]byte{0xfe, 0x80, 15: 1}, [16]byte{0xfe, 0x80, 15: 2}, []byte{1, 187}, 6), hashmap.Val{PacketsRcvd: 3})
+	require.Nil(t, goDB.NewDBWriter(dbPath, iface, encoders.EncoderTypeNull).Write(flows, capturetypes.CaptureStats{}, time.Now().Unix()))
+}
+
+func TestIPVersionQuery(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestFlows(t, tempDir, "eth1")
+
+	run := func(opts ...query.Option) results.Rows {
+		a := query.NewArgs("sip", "eth1",
+			append([]query.Option{query.WithFirst("-1d"), query.WithNumResults(query.MaxResults), query.WithFormat("json")}, opts...)...,
+		)
+		res, err := NewQueryRunner(tempDir).Run(context.Background(), a)
+		require.Nil(t, err)
+		return res.Rows
+	}
+
+	require.Len(t, run(), 3)
+
+	v4 := run(query.WithIPVersion(4))
+	require.Len(t, v4, 2)
+	for _, row := range v4 {
+		require.True(t, row.Attributes.SrcIP.Is4())
+	}
+	v6 := run(query.WithIPVersion(6))
+	require.Len(t, v6, 1)
+	require.True(t, v6[0].Attributes.SrcIP.Is6())
+
+	// a contradicting condition yields no flows at all
+	require.Empty(t, run(query.WithIPVersion(6), query.WithCondition("snet = 0.0.0.0/0")))
+
+	_, err := query.NewArgs("sip", "eth1", query.WithIPVersion(5)).Prepare()
+	require.ErrorIs(t, err, query.ErrInvalidArgs)
+}
+
+func TestColumnProjection(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestFlows(t, tempDir, "eth1")
+
+	run := func(queryType string, opts ...query.Option) *results.Result {
+		a := query.NewArgs(queryType, "eth1",
+			append([]query.Option{query.WithFirst("-1d"), query.WithNumResults(query.MaxResults), query.WithFormat("json")}, opts...)...,
+		)
+		res, err := NewQueryRunner(tempDir).Run(context.Background(), a)
+		require.Nil(t, err)
+		return res
+	}
+
+	plan := run("sip", query.WithExplain()).Query.Plan
+	require.NotNil(t, plan)
+	require.Equal(t, []string{types.SIPName, types.BytesRcvdName, types.BytesSentName, types.PktsRcvdName, types.PktsSentName}, plan.Columns)
+	require.Equal(t, map[string]int{"eth1": 1}, plan.Directories)
+
+	plan = run("sip", query.WithExplain(), query.WithCondition("dport=80")).Query.Plan
+	require.Equal(t, []string{types.SIPName, types.DportName, types.BytesRcvdName, types.BytesSentName, types.PktsRcvdName, types.PktsSentName}, plan.Columns)
+
+	// explained queries don't read any flows
+	require.Empty(t, run("sip", query.WithExplain()).Rows)
+
+	// removing the columns not referenced by the query mustn't affect it
+	for _, column := range []string{types.DportName, types.ProtoName} {
+		files, err := filepath.Glob(filepath.Join(tempDir, "eth1", "*", "*", "*", column+".gpf"))
+		require.Nil(t, err)
+		require.Len(t, files, 1)
+		require.Nil(t, os.Remove(files[0]))
+	}
+	require.Len(t, run("sip").Rows, 3)
+
+	// cross-check that queries referencing the removed columns do touch them
+	_, err := NewQueryRunner(tempDir).Run(context.Background(), query.NewArgs("dport", "eth1", query.WithFirst("-1d"), query.WithFormat("json")))
+	require.NotNil(t, err)
+}
+
+func TestBlockSkipping(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestFlows(t, tempDir, "eth1")
+
+	run := func(condition string) ([]string, error) {
+		a := query.NewArgs("sip", "eth1", query.WithFirst("-1d"), query.WithNumResults(query.MaxResults), query.WithFormat("json"), query.WithCondition(condition))
+		res, err := NewQueryRunner(tempDir).Run(context.Background(), a)
+		if err != nil {
+			return nil, err
+		}
+		var sips []string
+		for _, row := range res.Rows {
+			sips = append(sips, row.Attributes.SrcIP.String())
+		}
+		sort.Strings(sips)
+		return sips, nil
+	}
+
+	var tests = []struct {
+		condition string
+		expected  []string
+	}{
+		{"dport = 80", []string{"10.0.0.1"}},
+		{"dport = 8080", nil},
+		{"dport > 443", nil},
+		{"dport != 80", []string{"10.0.0.3", "fe80::1"}},
+		{"proto = 17", []string{"10.0.0.3"}},
+		{"proto = 1", nil},
+		{"snet = 10.0.0.0/8", []string{"10.0.0.1", "10.0.0.3"}},
+		{"sip = 192.168.0.1", nil},
+		{"dnet = fe80::/16", []string{"fe80::1"}},
+		{"dport = 53 & proto = 6", nil},
+		{"proto = 17 | dport = 443", []string{"10.0.0.3", "fe80::1"}},
+	}
+	for _, test := range tests {
+		t.Run(test.condition, func(t *testing.T) {
+			sips, err := run(test.condition)
+			require.Nil(t, err)
+			require.Equal(t, test.expected, sips)
+		})
+	}
+
+	// skipped blocks aren't read at all, hence removing their (conditional) columns mustn't affect
+	// queries that cannot match
+	for _, column := range []string{types.DportName, types.ProtoName} {
+		files, err := filepath.Glob(filepath.Join(tempDir, "eth1", "*", "*", "*", column+".gpf"))
+		require.Nil(t, err)
+		require.Len(t, files, 1)
+		require.Nil(t, os.Remove(files[0]))
+	}
+	for _, condition := range []string{"dport = 8080", "proto = 1", "dport < 53 | proto > 17"} {
+		sips, err := run(condition)
+		require.Nil(t, err)
+		require.Empty(t, sips)
+	}
+
+	// cross-check that queries which may match do read the removed columns
+	_, err := run("dport = 80")
+	require.NotNil(t, err)
+}
+
+func TestResourcesInSummary(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestFlows(t, tempDir, "eth1")
+
+	run := func(condition string) *results.Result {
+		a := query.NewArgs("sip", "eth1", query.WithFirst("-1d"), query.WithFormat("json"), query.WithCondition(condition))
+		res, err := NewQueryRunner(tempDir).Run(context.Background(), a)
+		require.Nil(t, err)
+		require.NotNil(t, res.Summary.Resources)
+		return res
+	}
+
+	// the block is read (and all queried columns decompressed)
+	res := run("")
+	require.Equal(t, uint64(1), res.Summary.Resources.BlocksScanned)
+	require.Zero(t, res.Summary.Resources.BlocksSkipped)
+	require.NotZero(t, res.Summary.Resources.BytesDecompressed)
+	require.NotZero(t, res.Summary.Resources.PeakMemory)
+	require.NotZero(t, res.Summary.Timings.ScanDuration)
+	require.NotZero(t, res.Summary.Timings.FinalizeDuration)
+
+	// the block cannot match the condition and is skipped without being read
+	res = run("dport = 8080")
+	require.Zero(t, res.Summary.Resources.BlocksScanned)
+	require.Equal(t, uint64(1), res.Summary.Resources.BlocksSkipped)
+	require.Zero(t, res.Summary.Resources.BytesDecompressed)
+}
+
+func TestDirectionCondition(t *testing.T) {
+	tempDir := t.TempDir()
+
+	flows := hashmap.NewAggFlowMap()
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6), hashmap.Val{PacketsRcvd: 1})
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 3}, [4]byte{10, 0, 0, 2}, []byte{0, 53}, 17), hashmap.Val{PacketsSent: 2})
+	flows.SecondaryMap.Set(types.NewV6KeyStatic([16]byte{0xfe, 0x80, 15: 1}, [16]byte{0xfe, 0x80, 15: 2}, []byte{1, 187}, 6), hashmap.Val{PacketsRcvd: 3, PacketsSent: 3})
+	require.Nil(t, goDB.NewDBWriter(tempDir, "eth1", encoders.EncoderTypeNull).Write(flows, capturetypes.CaptureStats{}, time.Now().Unix()))
+
+	var tests = []struct {
+		condition string
+		expected  []string
+	}{
+		{"dir = in", []string{"10.0.0.1"}},
+		{"dir = out", []string{"10.0.0.3"}},
+		{"dir = uni", []string{"10.0.0.1", "10.0.0.3"}},
+		{"dir = bi", []string{"fe80::1"}},
+		{"dir != in", []string{"10.0.0.3", "fe80::1"}},
+		{"dir = in | dir = bi", []string{"10.0.0.1", "fe80::1"}},
+		{"dir = uni & proto = udp", []string{"10.0.0.3"}},
+	}
+	for _, test := range tests {
+		t.Run(test.condition, func(t *testing.T) {
+			a := query.NewArgs("sip", "eth1", query.WithFirst("-1d"), query.WithNumResults(query.MaxResults), query.WithFormat("json"), query.WithCondition(test.condition))
+			res, err := NewQueryRunner(tempDir).Run(context.Background(), a)
+			require.Nil(t, err)
+
+			var sips []string
+			for _, row := range res.Rows {
+				sips = append(sips, row.Attributes.SrcIP.String())
+			}
+			sort.Strings(sips)
+			require.Equal(t, test.expected, sips)
+		})
+	}
+
+	// the direction can be queried as a label, both on its own and along with other attributes
+	a := query.NewArgs("dir", "eth1", query.WithFirst("-1d"), query.WithNumResults(query.MaxResults), query.WithFormat("json"))
+	res, err := NewQueryRunner(tempDir).Run(context.Background(), a)
+	require.Nil(t, err)
+	packets := make(map[string]uint64)
+	for _, row := range res.Rows {
+		packets[row.Labels.Direction] = row.Counters.PacketsRcvd + row.Counters.PacketsSent
+	}
+	require.Equal(t, map[string]uint64{"in": 1, "out": 2, "bi": 6}, packets)
+
+	a = query.NewArgs("sip,dir,time", "eth1", query.WithFirst("-1d"), query.WithNumResults(query.MaxResults), query.WithFormat("json"), query.WithCondition("dir = uni"))
+	res, err = NewQueryRunner(tempDir).Run(context.Background(), a)
+	require.Nil(t, err)
+	directions := make(map[string]string)
+	for _, row := range res.Rows {
+		directions[row.Attributes.SrcIP.String()] = row.Labels.Direction
+	}
+	require.Equal(t, map[string]string{"10.0.0.1": "in", "10.0.0.3": "out"}, directions)
+}
+
+func TestProcessQuery(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// the first block is written without process attribution
+	flows := hashmap.NewAggFlowMap()
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6), hashmap.Val{PacketsRcvd: 1})
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 3}, [4]byte{10, 0, 0, 2}, []byte{0, 53}, 17), hashmap.Val{PacketsRcvd: 2})
+	tNow := time.Now().Unix()
+	require.Nil(t, goDB.NewDBWriter(tempDir, "eth1", encoders.EncoderTypeNull).Write(flows, capturetypes.CaptureStats{}, tNow-300))
+	require.Nil(t, goDB.NewDBWriter(tempDir, "eth1", encoders.EncoderTypeNull).WriteWithLabels(flows, map[string]capturetypes.FlowLabeler{
+		types.ProcessName: func(key types.Key) string {
+			if types.PortToUint16(key.GetDport()) == 80 {
+				return "nginx.service"
+			}
+			return ""
+		},
+	}, capturetypes.CaptureStats{}, tNow))
+
+	run := func(queryType string, opts ...query.Option) *results.Result {
+		a := query.NewArgs(queryType, "eth1",
+			append([]query.Option{query.WithFirst("-1d"), query.WithNumResults(query.MaxResults), query.WithFormat("json")}, opts...)...,
+		)
+		res, err := NewQueryRunner(tempDir).Run(context.Background(), a)
+		require.Nil(t, err)
+		return res
+	}
+
+	rows := make(map[string]uint64)
+	for _, row := range run("dport,process").Rows {
+		rows[fmt.Sprintf("%d/%s", row.Attributes.DstPort, row.Labels.Process)] = row.Counters.PacketsRcvd
+	}
+	require.Equal(t, map[string]uint64{
+		"80/nginx.service": 1,
+		"80/":              1,
+		"53/":              4,
+	}, rows)
+
+	// the process label is ignored unless it is queried
+	res := run("dport")
+	require.Len(t, res.Rows, 2)
+	for _, row := range res.Rows {
+		require.Empty(t, row.Labels.Process)
+	}
+
+	// the rows are filtered by the runner before they are limited
+	res = run("dport,process", query.WithFilter("process=nginx*"), query.WithNumResults(1))
+	require.Len(t, res.Rows, 1)
+	require.Equal(t, "nginx.service", res.Rows[0].Labels.Process)
+	require.Equal(t, 1, res.Summary.Hits.Total)
+
+	// filtering on the process label requires it to be queried
+	_, err := query.NewArgs("sip,process", "eth1", query.WithFilter("process=nginx*")).Prepare()
+	require.Nil(t, err)
+	_, err = query.NewArgs("sip", "eth1", query.WithFilter("process=nginx*")).Prepare()
+	require.ErrorIs(t, err, query.ErrInvalidArgs)
+}
+
+func TestLabelColumnQuery(t *testing.T) {
+	if _, exists := types.LookupAttribute("vlan"); !exists {
+		types.MustRegisterAttribute(types.AttributeSpec{Name: "vlan", LabelColumn: true})
+	}
+
+	tempDir := t.TempDir()
+	flows := hashmap.NewAggFlowMap()
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6), hashmap.Val{PacketsRcvd: 1})
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 3}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6), hashmap.Val{PacketsRcvd: 2})
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 4}, [4]byte{10, 0, 0, 2}, []byte{0, 53}, 17), hashmap.Val{PacketsRcvd: 4})
+	require.Nil(t, goDB.NewDBWriter(tempDir, "eth1", encoders.EncoderTypeNull).WriteWithLabels(flows, map[string]capturetypes.FlowLabeler{
+		types.ProcessName: func(key types.Key) string {
+			if types.PortToUint16(key.GetDport()) == 80 {
+				return "nginx.service"
+			}
+			return ""
+		},
+		"vlan": func(key types.Key) string {
+			if key.GetSIP()[3] == 1 {
+				return "42"
+			}
+			return "43"
+		},
+	}, capturetypes.CaptureStats{}, time.Now().Unix()))
+
+	run := func(queryType string, opts ...query.Option) *results.Result {
+		a := query.NewArgs(queryType, "eth1",
+			append([]query.Option{query.WithFirst("-1d"), query.WithNumResults(query.MaxResults), query.WithFormat("json")}, opts...)...,
+		)
+		res, err := NewQueryRunner(tempDir).Run(context.Background(), a)
+		require.Nil(t, err)
+		return res
+	}
+
+	for _, test := range []struct {
+		queryType string
+		expected  map[string]uint64
+	}{
+		{"dport,vlan", map[string]uint64{"80//42": 1, "80//43": 2, "53//43": 4}},
+		{"vlan,process", map[string]uint64{"0/nginx.service/42": 1, "0/nginx.service/43": 2, "0//43": 4}},
+	} {
+		rows := make(map[string]uint64)
+		for _, row := range run(test.queryType).Rows {
+			rows[fmt.Sprintf("%d/%s/%s", row.Attributes.DstPort, row.Labels.Process, row.Labels.Columns.Get("vlan"))] = row.Counters.PacketsRcvd
+		}
+		require.Equal(t, test.expected, rows, test.queryType)
+	}
+
+	// the rows can be filtered on label columns (but they can't be used in conditions)
+	res := run("sip,vlan", query.WithFilter("vlan=42"))
+	require.Len(t, res.Rows, 1)
+	require.Equal(t, "10.0.0.1", res.Rows[0].Attributes.SrcIP.String())
+	_, err := query.NewArgs("sip", "eth1", query.WithFilter("vlan=42")).Prepare()
+	require.ErrorIs(t, err, query.ErrInvalidArgs)
+	_, err = NewQueryRunner(tempDir).Run(context.Background(), query.NewArgs("sip,vlan", "eth1", query.WithFirst("-1d"), query.WithCondition("vlan = 42")))
+	require.NotNil(t, err)
+}
+
+func TestAliasQuery(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestFlows(t, tempDir, "eth1")
+	writeTestFlows(t, tempDir, "eth2")
+	require.Nil(t, info.WriteAliases(tempDir, "", info.Aliases{"wan": "eth1"}))
+
+	var tests = []struct {
+		ifaces         string
+		expectedIfaces []string
+	}{
+		{"wan", []string{"wan"}},
+		{"eth1", []string{"wan"}},
+		{"wan,eth2", []string{"eth2", "wan"}},
+		{"any", []string{"eth2", "wan"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ifaces, func(t *testing.T) {
+			a := query.NewArgs("iface", test.ifaces, query.WithFirst("-1d"), query.WithNumResults(query.MaxResults), query.WithFormat("json"))
+			res, err := NewQueryRunner(tempDir).Run(context.Background(), a)
+			require.Nil(t, err)
+
+			require.Equal(t, test.expectedIfaces, res.Summary.Interfaces)
+			require.Len(t, res.Rows, len(test.expectedIfaces))
+			for _, row := range res.Rows {
+				require.Contains(t, test.expectedIfaces, row.Labels.Iface)
+			}
+		})
+	}
+}
+
+func TestIfaceGroupQuery(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, iface := range []string{"eth0", "eth1", "eth2", "eth3"} {
+		writeTestFlows(t, tempDir, iface)
+	}
+	require.Nil(t, info.WriteAliases(tempDir, "", info.Aliases{"wan": "eth2"}))
+	require.Nil(t, info.WriteGroups(tempDir, "", info.Groups{"uplinks": {"eth0", "wan"}}))
+
+	var tests = []struct {
+		name           string
+		ifaces         string
+		groups         info.Groups
+		expectedIfaces []string
+		expectedPkts   map[string]uint64
+		expectedErr    error
+	}{
+		{"stored group", "uplinks", nil,
+			[]string{"uplinks"}, map[string]uint64{"uplinks": 12}, nil},
+		{"group and interface", "uplinks,eth1", nil,
+			[]string{"eth1", "uplinks"}, map[string]uint64{"uplinks": 12, "eth1": 6}, nil},
+		{"member selected individually", "uplinks,eth0", nil,
+			[]string{"uplinks"}, map[string]uint64{"uplinks": 12}, nil},
+		{"negated group", "!uplinks", nil,
+			[]string{"eth1", "eth3"}, map[string]uint64{"eth1": 6, "eth3": 6}, nil},
+		{"query group", "core", info.Groups{"core": {"eth1", "eth3"}},
+			[]string{"core"}, map[string]uint64{"core": 12}, nil},
+		{"query group overrides stored one", "uplinks", info.Groups{"uplinks": {"eth3"}},
+			[]string{"uplinks"}, map[string]uint64{"uplinks": 6}, nil},
+		{"overlapping groups", "uplinks,edge", info.Groups{"edge": {"eth2", "eth3"}},
+			nil, nil, query.ErrInvalidInterface},
+		{"invalid group", "uplinks", info.Groups{"uplinks": nil},
+			nil, nil, query.ErrInvalidArgs},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := query.NewArgs("sip", test.ifaces, query.WithFirst("-1d"), query.WithNumResults(query.MaxResults),
+				query.WithFormat("json"), query.WithIfaceGroups(test.groups))
+			res, err := NewQueryRunner(tempDir).Run(context.Background(), a)
+			if test.expectedErr != nil {
+				require.ErrorIs(t, err, test.expectedErr)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, test.expectedIfaces, res.Summary.Interfaces)
+
+			// the flows of the members are aggregated, i.e. there is one row per source IP and group
+			require.Len(t, res.Rows, 3*len(test.expectedIfaces))
+			pkts := make(map[string]uint64)
+			for _, row := range res.Rows {
+				pkts[row.Labels.Iface] += row.Counters.PacketsRcvd
+			}
+			require.Equal(t, test.expectedPkts, pkts)
+		})
+	}
+}
+
+func TestIfaceSelectors(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, iface := range []string{"eth1", "eth2", "docker0"} {
+		writeTestFlows(t, tempDir, iface)
+	}
+	aliases := info.Aliases{"wan": "eth1"}
+
+	var tests = []struct {
+		ifaces         string
+		expectedIfaces []string
+		expectedErr    error
+	}{
+		{"eth*", []string{"eth1", "eth2"}, nil},
+		{"any,!docker0", []string{"eth1", "eth2"}, nil},
+		{"!eth1", []string{"docker0", "eth2"}, nil},
+		{"!eth*,!docker0", nil, query.ErrNoInterfaces},
+		{"eth*,!wan", []string{"eth2"}, nil},
+		{"w*", []string{"eth1"}, nil},
+		{"eth[12],docker?", []string{"docker0", "eth1", "eth2"}, nil},
+		{"eth1,eth*", []string{"eth1", "eth2"}, nil},
+		{"ppp*", nil, query.ErrNoInterfaces},
+		{"eth[", nil, query.ErrInvalidInterface},
+		{"!eth/0", nil, query.ErrInvalidInterface},
+		{"eth1,", nil, query.ErrInvalidInterface},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ifaces, func(t *testing.T) {
+			ifaces, err := parseIfaceList(tempDir, test.ifaces, aliases)
+			if test.expectedErr != nil {
+				require.ErrorIs(t, err, test.expectedErr)
+				return
+			}
+			require.Nil(t, err)
+			require.ElementsMatch(t, test.expectedIfaces, ifaces)
+		})
+	}
+}
+
+type testTunnels map[string]util.TunnelInfo
+
+func (t testTunnels) TunnelInfos() (map[string]util.TunnelInfo, error) {
+	return t, nil
+}
+
+type failingTunnels struct{}
+
+func (failingTunnels) TunnelInfos() (map[string]util.TunnelInfo, error) {
+	return nil, errTunnelsUnavailable
+}
+
+var errTunnelsUnavailable = errors.New("tunnel metadata unavailable")
+
+func TestTunnelSelectors(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, iface := range []string{"eth1", "t4_zrh", "t4_gva", "t4_ber"} {
+		writeTestFlows(t, tempDir, iface)
+	}
+	aliases := info.Aliases{"berlin": "t4_ber"}
+
+	util.SetTunnelProvider(testTunnels{
+		"t4_zrh": {PhysicalIface: "eth1", Peer: "198.51.100.1", Site: "zurich"},
+		"t4_gva": {PhysicalIface: "eth1", Peer: "198.51.100.2", Site: "geneva"},
+		"berlin": {PhysicalIface: "eth1", Peer: "203.0.113.1", Site: "berlin"},
+	})
+	defer util.SetTunnelProvider(nil)
+
+	var tests = []struct {
+		ifaces         string
+		expectedIfaces []string
+		expectedErr    error
+	}{
+		{"site=zurich", []string{"t4_zrh"}, nil},
+		{"peer=198.51.100.*", []string{"t4_gva", "t4_zrh"}, nil},
+		{"site=berlin", []string{"t4_ber"}, nil},
+		{"t4_*,!site=geneva", []string{"t4_ber", "t4_zrh"}, nil},
+		{"eth1,site=*", []string{"eth1", "t4_ber", "t4_gva", "t4_zrh"}, nil},
+		{"site=paris", nil, query.ErrNoInterfaces},
+		{"site=", nil, query.ErrInvalidInterface},
+		{"peer=[", nil, query.ErrInvalidInterface},
+	}
+
+	for _, test := range tests {
+		t.Run(test.ifaces, func(t *testing.T) {
+			ifaces, err := parseIfaceList(tempDir, test.ifaces, aliases)
+			if test.expectedErr != nil {
+				require.ErrorIs(t, err, test.expectedErr)
+				return
+			}
+			require.Nil(t, err)
+			require.ElementsMatch(t, test.expectedIfaces, ifaces)
+		})
+	}
+
+	// failures to read the tunnel metadata are reported (instead of matching no tunnels), but only
+	// affect queries selecting tunnels by it
+	util.SetTunnelProvider(failingTunnels{})
+	_, err := parseIfaceList(tempDir, "site=zurich", aliases)
+	require.ErrorIs(t, err, errTunnelsUnavailable)
+	ifaces, err := parseIfaceList(tempDir, "t4_*", aliases)
+	require.Nil(t, err)
+	require.Len(t, ifaces, 3)
+}
+
+func TestReadThrottling(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// write three blocks (five minutes apart) to the DB
+	flows := hashmap.NewAggFlowMap()
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6), hashmap.Val{PacketsRcvd: 1})
+	tNow := time.Now().Unix()
+	for i := int64(2); i >= 0; i-- {
+		require.Nil(t, goDB.NewDBWriter(tempDir, "eth1", encoders.EncoderTypeNull).Write(flows, capturetypes.CaptureStats{}, tNow-i*300))
+	}
+
+	run := func(ctx context.Context, qr *QueryRunner, opts ...query.Option) (*results.Result, time.Duration, error) {
+		a := query.NewArgs("sip", "eth1",
+			append([]query.Option{query.WithFirst("-1d"), query.WithNumResults(query.MaxResults), query.WithFormat("json")}, opts...)...,
+		)
+		t0 := time.Now()
+		res, err := qr.Run(ctx, a)
+		return res, time.Since(t0), err
+	}
+
+	// the first block is read immediately, the remaining ones at the limited rate
+	res, elapsed, err := run(context.Background(), NewQueryRunner(tempDir), query.WithMaxBlocksPerSec(10))
+	require.Nil(t, err)
+	require.Equal(t, uint64(3), res.Summary.Totals.PacketsRcvd)
+	require.GreaterOrEqual(t, elapsed, 150*time.Millisecond)
+
+	// the default of the runner applies to queries without a limit of their own
+	res, elapsed, err = run(context.Background(), NewQueryRunner(tempDir, WithMaxBlocksPerSec(10)))
+	require.Nil(t, err)
+	require.Equal(t, uint64(3), res.Summary.Totals.PacketsRcvd)
+	require.GreaterOrEqual(t, elapsed, 150*time.Millisecond)
+
+	// and caps the limit of queries exceeding it
+	res, elapsed, err = run(context.Background(), NewQueryRunner(tempDir, WithMaxBlocksPerSec(10)), query.WithMaxBlocksPerSec(1000))
+	require.Nil(t, err)
+	require.Equal(t, uint64(3), res.Summary.Totals.PacketsRcvd)
+	require.GreaterOrEqual(t, elapsed, 150*time.Millisecond)
+
+	// waiting for the next read is aborted once the query times out
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, elapsed, err = run(ctx, NewQueryRunner(tempDir, WithMaxBlocksPerSec(1)))
+	require.ErrorIs(t, err, query.ErrQueryTimeout)
+	require.Less(t, elapsed, time.Second)
+
+	_, err = query.NewArgs("sip", "eth1", query.WithMaxBlocksPerSec(-1)).Prepare()
+	require.ErrorIs(t, err, query.ErrInvalidArgs)
+}
+
+func TestCoverageGapsInSummary(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// write blocks for the last 30 minutes, with the capture being down for 15 minutes in between (and
+	// a second interface only having written its first block)
+	flows := hashmap.NewAggFlowMap()
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6), hashmap.Val{PacketsRcvd: 1})
+	tNow := time.Now().Unix()
+	for _, ts := range []int64{tNow - 1800, tNow - 1500, tNow - 300, tNow} {
+		require.Nil(t, goDB.NewDBWriter(tempDir, "eth1", encoders.EncoderTypeNull).Write(flows, capturetypes.CaptureStats{}, ts))
+	}
+	require.Nil(t, goDB.NewDBWriter(tempDir, "eth2", encoders.EncoderTypeNull).Write(flows, capturetypes.CaptureStats{}, tNow))
+
+	a := query.NewArgs("sip", "eth1,eth2",
+		query.WithFirst(time.Unix(tNow-1800, 0).Format(time.RFC3339)), query.WithNumResults(query.MaxResults), query.WithFormat("json"),
+	)
+	res, err := NewQueryRunner(tempDir).Run(context.Background(), a)
+	require.Nil(t, err)
+
+	hostname := res.Rows[0].Labels.Hostname
+	require.Equal(t, []results.CoverageGap{
+		{Iface: "eth1", Hostname: hostname, TimeRange: results.TimeRange{First: time.Unix(tNow-1500, 0), Last: time.Unix(tNow-600, 0)}},
+	}, res.Summary.Gaps)
+}
+
+func TestLinkDownInSummary(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// write blocks for the last 30 minutes, with the link being down for 10 minutes in between (during
+	// which empty blocks are written)
+	flows := hashmap.NewAggFlowMap()
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6), hashmap.Val{PacketsRcvd: 1})
+	tNow := time.Now().Unix()
+	for ts := tNow - 1800; ts <= tNow; ts += 300 {
+		if ts == tNow-1200 || ts == tNow-900 {
+			require.Nil(t, goDB.NewDBWriter(tempDir, "eth1", encoders.EncoderTypeNull).Write(hashmap.NewAggFlowMap(), capturetypes.CaptureStats{LinkState: capturetypes.LinkStateDown}, ts))
+			continue
+		}
+		require.Nil(t, goDB.NewDBWriter(tempDir, "eth1", encoders.EncoderTypeNull).Write(flows, capturetypes.CaptureStats{}, ts))
+	}
+
+	a := query.NewArgs("sip", "eth1",
+		query.WithFirst(time.Unix(tNow-1800, 0).Format(time.RFC3339)), query.WithNumResults(query.MaxResults), query.WithFormat("json"),
+	)
+	res, err := NewQueryRunner(tempDir).Run(context.Background(), a)
+	require.Nil(t, err)
+
+	// the interval is reported as a gap due to the link being down (rather than the capture)
+	hostname := res.Rows[0].Labels.Hostname
+	require.Equal(t, []results.CoverageGap{
+		{Iface: "eth1", Hostname: hostname, LinkDown: true, TimeRange: results.TimeRange{First: time.Unix(tNow-1500, 0), Last: time.Unix(tNow-900, 0)}},
+	}, res.Summary.Gaps)
+}
+
+func TestVerifyQuery(t *testing.T) {
+	tempDir := t.TempDir()
+
+	flows := hashmap.NewAggFlowMap()
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6), hashmap.Val{PacketsRcvd: 1})
+	tNow := time.Now().Unix()
+	require.Nil(t, goDB.NewDBWriter(tempDir, "eth1", encoders.EncoderTypeNull).Write(flows, capturetypes.CaptureStats{}, tNow-300))
+
+	// write a block of two flows whose dport column only holds a single entry (as if it was written partially)
+	dir := gpfile.NewDir(filepath.Join(tempDir, "eth1"), tNow, gpfile.ModeWrite, gpfile.WithEncoderTypeLevel(encoders.EncoderTypeNull, 0))
+	require.Nil(t, dir.Open())
+	counters := bitpack.Pack([]uint64{1, 1})
+	require.Nil(t, dir.WriteBlocks(tNow, gpfile.TrafficMetadata{NumV4Entries: 2}, types.Counters{PacketsRcvd: 2}, [types.ColIdxCount][]byte{
+		{10, 0, 0, 3, 10, 0, 0, 4}, {10, 0, 0, 2, 10, 0, 0, 2}, {6, 6}, {0, 80},
+		counters, counters, counters, counters,
+	}))
+	require.Nil(t, dir.Close())
+
+	run := func(opts ...query.Option) *results.Result {
+		a := query.NewArgs("sip", "eth1",
+			append([]query.Option{query.WithFirst(time.Unix(tNow-300, 0).Format(time.RFC3339)), query.WithNumResults(query.MaxResults), query.WithFormat("json")}, opts...)...,
+		)
+		res, err := NewQueryRunner(tempDir).Run(context.Background(), a)
+		require.Nil(t, err)
+		return res
+	}
+
+	// the dport column isn't read unless the blocks are verified
+	res := run()
+	require.Equal(t, uint64(3), res.Summary.Totals.PacketsRcvd)
+	require.Empty(t, res.Summary.Corruptions)
+
+	// the inconsistent block is skipped and reported
+	res = run(query.WithVerify())
+	require.Equal(t, uint64(1), res.Summary.Totals.PacketsRcvd)
+	require.Equal(t, []results.Corruption{
+		{Iface: "eth1", Hostname: res.Rows[0].Labels.Hostname, Timestamp: time.Unix(tNow, 0), Column: types.DportName, Expected: 2, Found: 1},
+	}, res.Summary.Corruptions)
+}
+
+func TestAnomaliesInSummary(t *testing.T) {
+	tempDir := t.TempDir()
+
+	write := func(iface string, bytes uint64, ts int64) {
+		flows := hashmap.NewAggFlowMap()
+		flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6), hashmap.Val{BytesRcvd: bytes, PacketsRcvd: 1})
+		require.Nil(t, goDB.NewDBWriter(tempDir, iface, encoders.EncoderTypeNull).Write(flows, capturetypes.CaptureStats{}, ts))
+	}
+
+	// eth1 carries ten times its usual traffic (with one of the preceding days lacking data), eth2 its usual
+	// traffic and eth3 has no baseline
+	tNow := time.Now().Unix()
+	for day := int64(7); day > 0; day-- {
+		if day != 3 {
+			write("eth1", 100, tNow-day*gpfile.EpochDay)
+		}
+	}
+	write("eth1", 1000, tNow)
+	write("eth2", 100, tNow-gpfile.EpochDay)
+	write("eth2", 100, tNow)
+	write("eth3", 100, tNow)
+
+	run := func(opts ...query.Option) *results.Result {
+		a := query.NewArgs("sip", "eth1,eth2,eth3",
+			append([]query.Option{query.WithFirst(time.Unix(tNow-300, 0).Format(time.RFC3339)), query.WithNumResults(query.MaxResults), query.WithFormat("json")}, opts...)...,
+		)
+		res, err := NewQueryRunner(tempDir).Run(context.Background(), a)
+		require.Nil(t, err)
+		return res
+	}
+	require.Empty(t, run().Summary.Anomalies)
+
+	res := run(query.WithAnomalies())
+	require.Len(t, res.Summary.Anomalies, 2)
+	for _, anomaly := range res.Summary.Anomalies {
+		require.Equal(t, time.Unix(tNow-300, 0), anomaly.First)
+	}
+
+	eth1, eth2 := res.Summary.Anomalies[0], res.Summary.Anomalies[1]
+	require.Equal(t, "eth1", eth1.Iface)
+	require.Equal(t, types.Counters{BytesRcvd: 1000, PacketsRcvd: 1}, eth1.Totals)
+	require.Equal(t, types.Counters{BytesRcvd: 100, PacketsRcvd: 1}, eth1.Baseline)
+	require.Equal(t, 6, eth1.BaselineDays)
+	require.Equal(t, 10.0, eth1.Ratio)
+	require.True(t, eth1.Unusual)
+
+	require.Equal(t, "eth2", eth2.Iface)
+	require.Equal(t, 1, eth2.BaselineDays)
+	require.Equal(t, 1.0, eth2.Ratio)
+	require.False(t, eth2.Unusual)
+}
+
+func TestTimeWindowsQuery(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// write two blocks per day (at 10:00 and 20:00) for three days, each with a distinct source IP
+	tDay := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC).Unix()
+	for day := int64(0); day < 3; day++ {
+		for i, hour := range []int64{10, 20} {
+			flows := hashmap.NewAggFlowMap()
+			flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, byte(day), byte(i + 1)}, [4]byte{10, 0, 0, 254}, []byte{0, 80}, 6), hashmap.Val{PacketsRcvd: 1})
+			require.Nil(t, goDB.NewDBWriter(tempDir, "eth1", encoders.EncoderTypeNull).Write(flows, capturetypes.CaptureStats{}, tDay+day*86400+hour*3600))
+		}
+	}
+
+	// the day directory in between the windows isn't read at all
+	files, err := filepath.Glob(filepath.Join(tempDir, "eth1", "*", "*", strconv.FormatInt(tDay+86400, 10), types.SIPName+".gpf"))
+	require.Nil(t, err)
+	require.Len(t, files, 1)
+	require.Nil(t, os.Remove(files[0]))
+
+	window := func(day int64) string {
+		return fmt.Sprintf("%d..%d", tDay+day*86400+8*3600, tDay+day*86400+18*3600)
+	}
+	a := query.NewArgs("sip", "eth1", query.WithWindows(window(2), window(0)), query.WithNumResults(query.MaxResults), query.WithFormat("json"))
+	res, err := NewQueryRunner(tempDir).Run(context.Background(), a)
+	require.Nil(t, err)
+
+	var sips []string
+	for _, row := range res.Rows {
+		sips = append(sips, row.Attributes.SrcIP.String())
+	}
+	sort.Strings(sips)
+	require.Equal(t, []string{"10.0.0.1", "10.0.2.1"}, sips)
+	require.Equal(t, uint64(2), res.Summary.Resources.BlocksScanned)
+
+	// coverage gaps are only reported within the windows
+	for _, gap := range res.Summary.Gaps {
+		first, last := gap.TimeRange.First.Unix(), gap.TimeRange.Last.Unix()
+		require.True(t, (first >= tDay && last <= tDay+18*3600) || (first >= tDay+2*86400 && last <= tDay+2*86400+18*3600), "gap %v", gap)
+	}
+
+	// live queries can't be restricted to time windows
+	a = query.NewArgs("sip", "eth1", query.WithWindows(window(0)))
+	a.Live = true
+	_, err = a.Prepare()
+	require.ErrorIs(t, err, query.ErrInvalidArgs)
+}
+
+func TestApproxQuery(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestFlows(t, tempDir, "eth1")
+
+	exact, err := NewQueryRunner(tempDir).Run(context.Background(), query.NewArgs("sip,dip", "eth1", query.WithFormat("json"), query.WithNumResults(10)))
+	require.Nil(t, err)
+	require.Nil(t, exact.Summary.Approximation)
+
+	approx, err := NewQueryRunner(tempDir).Run(context.Background(), query.NewArgs("sip,dip", "eth1", query.WithFormat("json"), query.WithNumResults(10), query.WithApprox()))
+	require.Nil(t, err)
+	require.Equal(t, &results.Approximation{Capacity: minApproxCapacity}, approx.Summary.Approximation)
+	require.Equal(t, exact.Rows, approx.Rows)
+	require.Equal(t, exact.Summary.Totals, approx.Summary.Totals)
+
+	// the top flows cannot be approximated for ascending or time based sorting
+	for _, a := range []*query.Args{
+		query.NewArgs("sip,dip", "eth1", query.WithApprox(), query.WithSortAscending()),
+		query.NewArgs("time,sip", "eth1", query.WithApprox()),
+	} {
+		_, err = NewQueryRunner(tempDir).Run(context.Background(), a)
+		require.ErrorIs(t, err, query.ErrInvalidArgs)
+	}
+}
+
+func TestDistinctQuery(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestFlows(t, tempDir, "eth1")
+
+	res, err := NewQueryRunner(tempDir).Run(context.Background(), query.NewArgs("proto", "eth1", query.WithFormat("json"), query.WithSortBy("packets"), query.WithDistinct("dport")))
+	require.Nil(t, err)
+	require.Equal(t, []string{types.ProtoName}, res.Query.Attributes)
+	require.Equal(t, types.DportName, res.Query.Distinct)
+	require.Equal(t, 2, res.Summary.Hits.Total)
+
+	distinct := make(map[uint8]uint64)
+	for _, row := range res.Rows {
+		require.NotNil(t, row.Distinct)
+		distinct[row.Attributes.IPProto] = row.Distinct.Count()
+	}
+	require.Equal(t, map[uint8]uint64{6: 2, 17: 1}, distinct)
+	require.Equal(t, uint64(4), res.Rows[0].Counters.PacketsRcvd)
+
+	// the distinct attribute must be known and cannot be part of the query attributes
+	for _, a := range []*query.Args{
+		query.NewArgs("sip", "eth1", query.WithDistinct("unknown")),
+		query.NewArgs("sip,dip", "eth1", query.WithDistinct("dip")),
+		query.NewArgs("sip", "eth1", query.WithDistinct("dip"), query.WithApprox()),
+	} {
+		_, err = NewQueryRunner(tempDir).Run(context.Background(), a)
+		require.ErrorIs(t, err, query.ErrInvalidArgs)
+	}
+}
+
+func TestDistributionQuery(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// write one block per hour for eight hours: one source IP sends constantly, the other one
+	// only during the fourth hour
+	tFirst := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC).Unix()
+	for hour := int64(0); hour < results.DistributionBuckets; hour++ {
+		flows := hashmap.NewAggFlowMap()
+		flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 254}, []byte{0, 80}, 6), hashmap.Val{BytesRcvd: 100, BytesSent: 100})
+		if hour == 3 {
+			flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 2}, [4]byte{10, 0, 0, 254}, []byte{0, 80}, 6), hashmap.Val{BytesRcvd: 5000})
+		}
+		require.Nil(t, goDB.NewDBWriter(tempDir, "eth1", encoders.EncoderTypeNull).Write(flows, capturetypes.CaptureStats{}, tFirst+hour*3600+1800))
+	}
+
+	res, err := NewQueryRunner(tempDir).Run(context.Background(), query.NewArgs("sip", "eth1",
+		query.WithFirst(strconv.FormatInt(tFirst, 10)),
+		query.WithLast(strconv.FormatInt(tFirst+results.DistributionBuckets*3600-1, 10)),
+		query.WithFormat("json"), query.WithDistribution(),
+	))
+	require.Nil(t, err)
+	require.Equal(t, 2, res.Summary.Hits.Total)
+
+	distributions := make(map[string]results.Distribution)
+	for _, row := range res.Rows {
+		require.True(t, row.Labels.Timestamp.IsZero())
+		require.NotNil(t, row.Distribution)
+		distributions[row.Attributes.SrcIP.String()] = *row.Distribution
+	}
+	require.Equal(t, map[string]results.Distribution{
+		"10.0.0.1": {200, 200, 200, 200, 200, 200, 200, 200},
+		"10.0.0.2": {3: 5000},
+	}, distributions)
+
+	// the distribution can't be computed for time based or approximate queries
+	for _, a := range []*query.Args{
+		query.NewArgs("time,sip", "eth1", query.WithDistribution()),
+		query.NewArgs("sip", "eth1", query.WithDistribution(), query.WithApprox()),
+	} {
+		_, err = NewQueryRunner(tempDir).Run(context.Background(), a)
+		require.ErrorIs(t, err, query.ErrInvalidArgs)
+	}
+}
+
+func TestQueryWorkers(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestFlows(t, tempDir, "eth1")
+
+	defer func(n int) { numProcessingUnits = n }(numProcessingUnits)
+	numProcessingUnits = 8
+
+	var tests = []struct {
+		name      string
+		requested int
+		opts      []QueryRunnerOption
+		expected  int
+	}{
+		{"default", 0, nil, 8},
+		{"requested", 2, nil, 2},
+		{"more than CPUs", 16, nil, 8},
+		{"runner default", 0, []QueryRunnerOption{WithWorkers(2, 0)}, 2},
+		{"capped", 6, []QueryRunnerOption{WithWorkers(2, 4)}, 4},
+		{"capped default", 0, []QueryRunnerOption{WithWorkers(0, 4)}, 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := NewQueryRunner(tempDir, test.opts...).Run(context.Background(), query.NewArgs("sip", "eth1",
+				query.WithFirst("-1d"), query.WithFormat("json"), query.WithExplain(), query.WithWorkers(test.requested),
+			))
+			require.Nil(t, err)
+			require.Equal(t, test.expected, res.Query.Plan.Workers)
+		})
+	}
+
+	// the number of workers determines the parallelism only, not the result
+	sequential, err := NewQueryRunner(tempDir).Run(context.Background(), query.NewArgs("sip,dip", "eth1", query.WithFirst("-1d"), query.WithFormat("json"), query.WithWorkers(1)))
+	require.Nil(t, err)
+	parallel, err := NewQueryRunner(tempDir).Run(context.Background(), query.NewArgs("sip,dip", "eth1", query.WithFirst("-1d"), query.WithFormat("json"), query.WithWorkers(8)))
+	require.Nil(t, err)
+	require.Equal(t, sequential.Rows, parallel.Rows)
+
+	_, err = query.NewArgs("sip", "eth1", query.WithWorkers(-1)).Prepare()
+	require.ErrorIs(t, err, query.ErrInvalidArgs)
+}
+
+func TestQueryWhileWriting(t *testing.T) {
+	tempDir := t.TempDir()
+
+	const nBlocks = 50
+	var (
+		dayTimestamp = time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC).Unix()
+		blockFlows   = hashmap.NewAggFlowMap()
+	)
+	blockFlows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6), hashmap.Val{BytesRcvd: 100, PacketsRcvd: 1})
+	blockFlows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 3}, [4]byte{10, 0, 0, 2}, []byte{0, 53}, 17), hashmap.Val{BytesRcvd: 200, PacketsRcvd: 2})
+
+	// write blocks to the day in the background, mimicking the periodic writeouts of goProbe
+	writeErr := make(chan error, 1)
+	go func() {
+		defer close(writeErr)
+		for i := 1; i <= nBlocks; i++ {
+			if err := goDB.NewDBWriter(tempDir, "eth1", encoders.EncoderTypeNull).Write(blockFlows, capturetypes.CaptureStats{}, dayTimestamp+int64(i)*goDB.DBWriteInterval); err != nil {
+				writeErr <- err
+				return
+			}
+		}
+	}()
+
+	args := query.NewArgs("sip", "eth1",
+		query.WithFirst(strconv.FormatInt(dayTimestamp, 10)), query.WithLast(strconv.FormatInt(dayTimestamp+gpfile.EpochDay, 10)), query.WithFormat("json"),
+	)
+
+	// all queries have to succeed and each of them has to see a consistent set of fully written blocks
+	var lastBytes uint64
+	for done := false; !done; {
+		select {
+		case err := <-writeErr:
+			require.Nil(t, err)
+			done = true
+		default:
+		}
+
+		res, err := NewQueryRunner(tempDir).Run(context.Background(), args)
+		require.Nil(t, err)
+		if res.Status.Code == types.StatusEmpty {
+			require.Zero(t, lastBytes)
+			continue
+		}
+		require.Equal(t, types.StatusOK, res.Status.Code, res.Status.Message)
+
+		bytes := res.Summary.Totals.BytesRcvd
+		require.Zero(t, bytes%300, "partially written block visible (%d bytes)", bytes)
+		require.GreaterOrEqual(t, bytes, lastBytes)
+		lastBytes = bytes
+	}
+	require.Equal(t, uint64(nBlocks*300), lastBytes)
+}
+
+func TestQueryDayWithoutBlocks(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestFlows(t, tempDir, "eth1")
+
+	run := func() {
+		res, err := NewQueryRunner(tempDir).Run(context.Background(), query.NewArgs("sip", "eth1", query.WithFirst("-2d"), query.WithFormat("json")))
+		require.Nil(t, err)
+		require.Len(t, res.Rows, 3)
+	}
+
+	// a day directory which was created, but whose first block hasn't been written (yet)
+	dir := gpfile.NewDir(filepath.Join(tempDir, "eth1"), time.Now().Unix()-gpfile.EpochDay, gpfile.ModeWrite)
+	require.Nil(t, os.MkdirAll(dir.Path(), 0755))
+	run()
+
+	// a day directory holding metadata without any blocks
+	require.Nil(t, dir.Open())
+	require.Nil(t, dir.Close())
+	run()
+}
+
+func TestAnomaliesWindowEndingNow(t *testing.T) {
+	tempDir := t.TempDir()
+
+	write := func(bytes uint64, ts int64) {
+		flows := hashmap.NewAggFlowMap()
+		flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6), hashmap.Val{BytesRcvd: bytes, PacketsRcvd: 1})
+		require.Nil(t, goDB.NewDBWriter(tempDir, "eth1", encoders.EncoderTypeNull).Write(flows, capturetypes.CaptureStats{}, ts))
+	}
+
+	// the current block of today is still in progress, hence the traffic of the same period on the
+	// preceding days must not be part of the baseline
+	tNow := time.Now().Unix()
+	tLastWriteout := tNow - 150
+	for day := int64(7); day > 0; day-- {
+		write(100, tLastWriteout-day*gpfile.EpochDay)
+		write(100, tNow-10-day*gpfile.EpochDay)
+	}
+	write(100, tLastWriteout)
+
+	a := query.NewArgs("sip", "eth1",
+		query.WithFirst(time.Unix(tNow-600, 0).Format(time.RFC3339)), query.WithNumResults(query.MaxResults), query.WithFormat("json"), query.WithAnomalies(),
+	)
+	res, err := NewQueryRunner(tempDir).Run(context.Background(), a)
+	require.Nil(t, err)
+	require.Len(t, res.Summary.Anomalies, 1)
+
+	anomaly := res.Summary.Anomalies[0]
+	require.Equal(t, time.Unix(tLastWriteout, 0), anomaly.Last)
+	require.Equal(t, types.Counters{BytesRcvd: 100, PacketsRcvd: 1}, anomaly.Baseline)
+	require.Equal(t, 7, anomaly.BaselineDays)
+	require.Equal(t, 1.0, anomaly.Ratio)
+	require.False(t, anomaly.Unusual)
+}
@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"container/heap"
+
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/goProbe/pkg/types/hashmap"
+)
+
+const (
+	// approxCapacityFactor denotes the number of flows tracked by an approximate aggregation per
+	// requested result row. The more flows are tracked, the smaller the error of the top rows
+	approxCapacityFactor = 10
+
+	minApproxCapacity = 1024
+	maxApproxCapacity = 1 << 20
+)
+
+// topKEntry denotes a flow tracked by a topKSketch
+type topKEntry struct {
+	iface  string
+	key    []byte
+	isIPv4 bool
+
+	val    types.Counters // counters accumulated since the flow is tracked
+	weight uint64         // estimate of the sort metric (including the error inherited upon insertion)
+	index  int            // position in the heap
+}
+
+// topKSketch approximates the top flows of an aggregation (by a sort metric) using the space-saving
+// algorithm: at most capacity flows are tracked. Once the sketch is full, an untracked flow replaces
+// the tracked flow with the smallest (estimated) metric, inheriting its estimate as error. Hence, the
+// counters of each tracked flow underestimate its actual counters by at most the smallest estimate,
+// which is also an upper bound for the metric of any untracked flow
+type topKSketch struct {
+	capacity int
+	metric   func(types.Counters) uint64
+
+	entries map[string]*topKEntry
+	heap    topKHeap
+}
+
+func newTopKSketch(numResults uint64, sortBy results.SortOrder, direction types.Direction) *topKSketch {
+	capacity := uint64(maxApproxCapacity)
+	if numResults < maxApproxCapacity/approxCapacityFactor {
+		capacity = max(approxCapacityFactor*numResults, minApproxCapacity)
+	}
+
+	return &topKSketch{
+		capacity: int(capacity),
+		metric:   sortMetric(sortBy, direction),
+		entries:  make(map[string]*topKEntry, capacity),
+		heap:     make(topKHeap, 0, capacity),
+	}
+}
+
+// sortMetric returns the counter the rows are sorted by
+func sortMetric(sortBy results.SortOrder, direction types.Direction) func(types.Counters) uint64 {
+	if sortBy == results.SortPackets {
+		switch direction {
+		case types.DirectionIn:
+			return func(c types.Counters) uint64 { return c.PacketsRcvd }
+		case types.DirectionOut:
+			return func(c types.Counters) uint64 { return c.PacketsSent }
+		}
+		return func(c types.Counters) uint64 { return c.SumPackets() }
+	}
+
+	switch direction {
+	case types.DirectionIn:
+		return func(c types.Counters) uint64 { return c.BytesRcvd }
+	case types.DirectionOut:
+		return func(c types.Counters) uint64 { return c.BytesSent }
+	}
+	return func(c types.Counters) uint64 { return c.SumBytes() }
+}
+
+// addMap adds all flows of the map to the sketch (and their counters to totals)
+func (s *topKSketch) addMap(item hashmap.AggFlowMapWithMetadata, totals *types.Counters) {
+	for _, m := range []struct {
+		flows  *hashmap.Map
+		isIPv4 bool
+	}{
+		{item.PrimaryMap, true},
+		{item.SecondaryMap, false},
+	} {
+		for i := m.flows.Iter(); i.Next(); {
+			*totals = totals.Add(i.Val())
+			s.add(item.Interface, i.Key(), m.isIPv4, i.Val())
+		}
+	}
+}
+
+func (s *topKSketch) add(iface string, key []byte, isIPv4 bool, val types.Counters) {
+	id := iface + "\x00" + string(key)
+	if entry, exists := s.entries[id]; exists {
+		entry.val = entry.val.Add(val)
+		entry.weight += s.metric(val)
+		heap.Fix(&s.heap, entry.index)
+		return
+	}
+
+	if len(s.heap) < s.capacity {
+		entry := &topKEntry{
+			iface:  iface,
+			key:    append([]byte(nil), key...),
+			isIPv4: isIPv4,
+			val:    val,
+			weight: s.metric(val),
+		}
+		s.entries[id] = entry
+		heap.Push(&s.heap, entry)
+		return
+	}
+
+	// replace the flow with the smallest estimate
+	entry := s.heap[0]
+	delete(s.entries, entry.iface+"\x00"+string(entry.key))
+	entry.iface, entry.key, entry.isIPv4 = iface, append(entry.key[:0], key...), isIPv4
+	entry.val = val
+	entry.weight += s.metric(val)
+	s.entries[id] = entry
+	heap.Fix(&s.heap, 0)
+}
+
+// maxError returns the maximum amount by which the sort metric of any flow is underestimated
+func (s *topKSketch) maxError() uint64 {
+	if len(s.heap) < s.capacity {
+		return 0
+	}
+	return s.heap[0].weight
+}
+
+// flowMaps returns all tracked flows, by interface
+func (s *topKSketch) flowMaps(ifaces []string) hashmap.NamedAggFlowMapWithMetadata {
+	flowMaps := hashmap.NewNamedAggFlowMapWithMetadata(ifaces)
+	for _, entry := range s.heap {
+		flowMap := flowMaps[entry.iface]
+		if entry.isIPv4 {
+			flowMap.PrimaryMap.Set(entry.key, entry.val)
+		} else {
+			flowMap.SecondaryMap.Set(entry.key, entry.val)
+		}
+	}
+	return flowMaps
+}
+
+// topKHeap implements a min-heap of the tracked flows (by their estimate)
+type topKHeap []*topKEntry
+
+func (h topKHeap) Len() int           { return len(h) }
+func (h topKHeap) Less(i, j int) bool { return h[i].weight < h[j].weight }
+func (h topKHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+
+func (h *topKHeap) Push(x any) {
+	entry := x.(*topKEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *topKHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/goProbe/pkg/types/hashmap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopKSketch(t *testing.T) {
+	sketch := newTopKSketch(10, results.SortTraffic, types.DirectionSum)
+	require.Equal(t, minApproxCapacity, sketch.capacity)
+	sketch.capacity = 16
+
+	key := func(i int) []byte {
+		var ip [4]byte
+		binary.BigEndian.PutUint32(ip[:], uint32(i))
+		return types.NewV4KeyStatic(ip, [4]byte{10, 0, 0, 1}, []byte{0, 80}, 6)
+	}
+
+	// a few heavy flows hidden among many small ones (e.g. a scan), spread across several maps
+	var totals types.Counters
+	for n := 0; n < 4; n++ {
+		item := hashmap.NewAggFlowMapWithMetadata()
+		item.Interface = "eth0"
+		for i := 0; i < 1000; i++ {
+			item.PrimaryMap.Set(key(100+n*1000+i), types.Counters{BytesRcvd: 10, PacketsRcvd: 1})
+		}
+		for i := 0; i < 4; i++ {
+			item.PrimaryMap.Set(key(i), types.Counters{BytesRcvd: uint64(100000 * (i + 1)), PacketsRcvd: 100})
+		}
+		sketch.addMap(item, &totals)
+	}
+	require.Equal(t, uint64(4*(1000*10+100000*(1+2+3+4))), totals.BytesRcvd)
+
+	maxError := sketch.maxError()
+	require.NotZero(t, maxError)
+	require.Less(t, maxError, uint64(100000))
+
+	flowMaps := sketch.flowMaps([]string{"eth0"})
+	require.Equal(t, 16, flowMaps.Len())
+	for i := 0; i < 4; i++ {
+		val, exists := flowMaps["eth0"].PrimaryMap.Get(key(i))
+		require.True(t, exists, "heavy flow %d not tracked", i)
+
+		actual := uint64(4 * 100000 * (i + 1))
+		require.LessOrEqual(t, val.BytesRcvd, actual)
+		require.GreaterOrEqual(t, val.BytesRcvd+maxError, actual)
+	}
+}
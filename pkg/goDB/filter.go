@@ -1,6 +1,7 @@
 package goDB
 
 import (
+	"github.com/els0r/goProbe/pkg/types"
 	"github.com/els0r/goProbe/pkg/types/hashmap"
 )
 
@@ -12,16 +13,19 @@ type FilterFn func(*hashmap.AggFlowMap) *hashmap.AggFlowMap
 func QueryFilter(query *Query) FilterFn {
 	return func(input *hashmap.AggFlowMap) (result *hashmap.AggFlowMap) {
 
-		// If there is no condition, return the input map as is
-		if query.Conditional == nil {
+		// If there is no condition or IP version restriction, return the input map as is
+		if query.Conditional == nil && !query.ipVersionFilter.IsLimited() {
 			return input
 		}
 
 		result = hashmap.NewAggFlowMap()
+		satisfied := func(key types.Key, val types.Counters) bool {
+			return query.Conditional == nil || query.Conditional.Evaluate(key, query.flowDirection(val.PacketsRcvd, val.PacketsSent))
+		}
 
 		// Loop over primary (IPv4) entries
-		for it := input.PrimaryMap.Iter(); it.Next(); {
-			if conditionalSatisfied := query.Conditional.Evaluate(it.Key()); conditionalSatisfied {
+		for it := input.PrimaryMap.Iter(); query.ipVersionFilter != types.IPVersionV6 && it.Next(); {
+			if conditionalSatisfied := satisfied(it.Key(), it.Val()); conditionalSatisfied {
 				result.PrimaryMap.SetOrUpdate(it.Key(),
 					it.Val().BytesRcvd,
 					it.Val().BytesSent,
@@ -32,8 +36,8 @@ func QueryFilter(query *Query) FilterFn {
 		}
 
 		// Loop over primary (IPv6) entries
-		for it := input.SecondaryMap.Iter(); it.Next(); {
-			if conditionalSatisfied := query.Conditional.Evaluate(it.Key()); conditionalSatisfied {
+		for it := input.SecondaryMap.Iter(); query.ipVersionFilter != types.IPVersionV4 && it.Next(); {
+			if conditionalSatisfied := satisfied(it.Key(), it.Val()); conditionalSatisfied {
 				result.SecondaryMap.SetOrUpdate(it.Key(),
 					it.Val().BytesRcvd,
 					it.Val().BytesSent,
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/els0r/goProbe/pkg/goDB/storage/gpfile"
+	"github.com/els0r/goProbe/pkg/results"
 	"github.com/els0r/goProbe/pkg/types"
 	"github.com/els0r/goProbe/pkg/types/hashmap"
 	"github.com/stretchr/testify/require"
@@ -138,7 +139,7 @@ func populateTestDir(t *testing.T, basePath, iface string, timestamp time.Time)
 	f := gpfile.NewDir(testPath, timestamp.Unix(), gpfile.ModeWrite)
 	require.Nil(t, f.Open())
 
-	data, update := dbData(generateFlows())
+	data, _, update := dbData(generateFlows(), nil)
 	require.Nil(t, f.WriteBlocks(timestamp.Unix()+300, gpfile.TrafficMetadata{
 		NumV4Entries: update.Traffic.NumV4Entries,
 		NumV6Entries: update.Traffic.NumV6Entries,
@@ -224,3 +225,87 @@ func testWorkload(t *testing.T, c testCase, dryRun bool) {
 		}
 	}
 }
+
+func TestCoverageGaps(t *testing.T) {
+	gap := func(first, last int64) results.TimeRange {
+		return results.TimeRange{First: time.Unix(first, 0), Last: time.Unix(last, 0)}
+	}
+
+	var tests = []struct {
+		name         string
+		timestamps   []int64
+		tfirst       int64
+		tlast        int64
+		expectedGaps []results.TimeRange
+	}{
+		{"no data", nil, 0, 900, []results.TimeRange{gap(0, 900)}},
+		{"full coverage", []int64{300, 600, 900}, 0, 900, nil},
+		{"jitter", []int64{310, 620, 900}, 0, 1000, nil},
+		{"missed writeout", []int64{300, 900, 1200}, 0, 1200, []results.TimeRange{gap(300, 600)}},
+		{"downtime", []int64{300, 3600}, 0, 3600, []results.TimeRange{gap(300, 3300)}},
+		{"leading", []int64{1200, 1500}, 0, 1500, nil},
+		{"trailing", []int64{300, 600}, 0, 1800, []results.TimeRange{gap(600, 1800)}},
+		{"multiple", []int64{600, 1800}, 0, 3000, []results.TimeRange{gap(600, 1500), gap(1800, 3000)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expectedGaps, coverageGaps(test.timestamps, test.tfirst, test.tlast))
+		})
+	}
+}
+
+func TestCaptureQuality(t *testing.T) {
+
+	testPath := t.TempDir()
+	timestamp := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC).Unix()
+
+	// write blocks with a low, unknown (no received packets recorded) and high drop rate
+	f := gpfile.NewDir(filepath.Join(testPath, "eth0"), timestamp, gpfile.ModeWrite)
+	require.Nil(t, f.Open())
+	for i, traffic := range []gpfile.TrafficMetadata{
+		{NumDrops: 5, NumPackets: 1000, NumTruncated: 1},
+		{NumDrops: 50},
+		{NumDrops: 50, NumPackets: 1000, NumOverruns: 2},
+	} {
+		data, _, update := dbData(generateFlows(), nil)
+		traffic.NumV4Entries, traffic.NumV6Entries = update.Traffic.NumV4Entries, update.Traffic.NumV6Entries
+		require.Nil(t, f.WriteBlocks(timestamp+int64(i+1)*DBWriteInterval, traffic, update.Counts, data))
+	}
+	require.Nil(t, f.Close())
+
+	for _, maxDropRate := range []float64{0, 0.01} {
+		t.Run(fmt.Sprintf("max_drop_rate_%g", maxDropRate), func(t *testing.T) {
+			workMgr, err := NewDBWorkManager(NewQuery([]types.Attribute{types.SIPAttribute{}}, nil, types.LabelSelector{Timestamp: true}).MaxDropRate(maxDropRate),
+				testPath, "eth0", 1)
+			require.Nil(t, err)
+			nonempty, err := workMgr.CreateWorkerJobs(timestamp, timestamp+gpfile.EpochDay)
+			require.Nil(t, err)
+			require.True(t, nonempty)
+
+			mapChan := make(chan hashmap.AggFlowMapWithMetadata, 1024)
+			workMgr.ExecuteWorkerReadJobs(context.Background(), mapChan)
+			close(mapChan)
+
+			var nFlows int
+			for aggMap := range mapChan {
+				nFlows += aggMap.Len()
+			}
+
+			quality := workMgr.GetCaptureQuality()
+			if maxDropRate == 0 {
+				require.Zero(t, workMgr.GetNumBlocksExcluded())
+				require.Equal(t, 3*(testNv4+testNv6), nFlows)
+				require.Equal(t, uint64(105), quality.NumDrops)
+				require.Equal(t, uint64(2), quality.NumOverruns)
+			} else {
+				require.Equal(t, uint64(1), workMgr.GetNumBlocksExcluded())
+				require.Equal(t, 2*(testNv4+testNv6), nFlows)
+				require.Equal(t, uint64(55), quality.NumDrops)
+				require.Zero(t, quality.NumOverruns)
+			}
+			require.Equal(t, uint64(1), quality.NumTruncated)
+			require.Empty(t, workMgr.GetCoverageGaps(timestamp+DBWriteInterval, timestamp+3*DBWriteInterval))
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package info
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const aliasesFileName = "iface.aliases"
+
+// ErrInvalidAlias is returned for interface aliases that can't be used in place of an interface name
+var ErrInvalidAlias = errors.New("invalid interface alias")
+
+// aliases are accepted wherever interface names are, hence the same constraints apply
+var aliasRegexp = regexp.MustCompile(`^[a-zA-Z0-9\.:_-]{1,15}$`)
+
+// Aliases maps human readable aliases (e.g. "wan") to the (physical) interfaces they denote
+type Aliases map[string]string
+
+// ValidateAlias checks if the alias is a valid interface alias
+func ValidateAlias(alias string) error {
+	if !aliasRegexp.MatchString(alias) {
+		return fmt.Errorf("%w: `%s`", ErrInvalidAlias, alias)
+	}
+	return nil
+}
+
+// Resolve returns the interface denoted by name. If name isn't an alias, it is returned as is
+func (a Aliases) Resolve(name string) string {
+	if iface, isAlias := a[name]; isAlias {
+		return iface
+	}
+	return name
+}
+
+// Alias returns the alias of the interface. If no alias is assigned, the interface itself is returned
+func (a Aliases) Alias(iface string) string {
+	for alias, aliased := range a {
+		if aliased == iface {
+			return alias
+		}
+	}
+	return iface
+}
+
+// ReadAliases reads the interface aliases stored in the DB at dbPath by all goProbe instances (see
+// InstanceFileName()). If none were stored, no aliases are returned
+func ReadAliases(dbPath string) (Aliases, error) {
+	paths, err := instanceFilePaths(dbPath, aliasesFileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read interface aliases: %w", err)
+	}
+
+	var aliases Aliases
+	for _, path := range paths {
+		data, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read interface aliases: %w", err)
+		}
+
+		// the aliases of all instances are merged (in case of conflicts, the last one prevails)
+		if err = jsoniter.Unmarshal(data, &aliases); err != nil {
+			return nil, fmt.Errorf("failed to parse interface aliases: %w", err)
+		}
+	}
+	return aliases, nil
+}
+
+// WriteAliases stores the interface aliases of a goProbe instance in the DB at dbPath, so that queries
+// run against the DB accept and report them in place of the interface names. Storing no aliases removes
+// the ones stored previously by the instance
+func WriteAliases(dbPath, instance string, aliases Aliases) error {
+	if err := CheckDBExists(dbPath); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dbPath, InstanceFileName(aliasesFileName, instance))
+	if len(aliases) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove interface aliases: %w", err)
+		}
+		return nil
+	}
+
+	data, err := jsoniter.Marshal(aliases)
+	if err != nil {
+		return fmt.Errorf("failed to serialize interface aliases: %w", err)
+	}
+	if err = writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to store interface aliases: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package info
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const groupsFileName = "iface.groups"
+
+// ErrInvalidGroup is returned for interface groups that can't be used in place of an interface name
+var ErrInvalidGroup = errors.New("invalid interface group")
+
+// Groups maps the names of interface groups (e.g. "uplinks") to their member interfaces (or
+// their aliases). Queries selecting a group aggregate the flows of its members and report them
+// under the name of the group
+type Groups map[string][]string
+
+// ValidateGroupName checks if the name is a valid interface group name. Since groups are accepted
+// wherever interface names are, the same constraints apply as for aliases
+func ValidateGroupName(group string) error {
+	if !aliasRegexp.MatchString(group) {
+		return fmt.Errorf("%w: `%s`", ErrInvalidGroup, group)
+	}
+	return nil
+}
+
+// ValidateGroup checks if the group and its members are valid
+func ValidateGroup(group string, members []string) error {
+	if err := ValidateGroupName(group); err != nil {
+		return err
+	}
+	if len(members) == 0 {
+		return fmt.Errorf("%w: `%s` has no members", ErrInvalidGroup, group)
+	}
+	for _, member := range members {
+		if !aliasRegexp.MatchString(member) {
+			return fmt.Errorf("%w: `%s` has invalid member `%s`", ErrInvalidGroup, group, member)
+		}
+	}
+	return nil
+}
+
+// Validate checks if all groups are valid
+func (g Groups) Validate() error {
+	for group, members := range g {
+		if err := ValidateGroup(group, members); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// With returns the union of the groups, where the groups of other take precedence over the ones
+// of the same name in g
+func (g Groups) With(other Groups) Groups {
+	if len(other) == 0 {
+		return g
+	}
+	groups := make(Groups, len(g)+len(other))
+	for group, members := range g {
+		groups[group] = members
+	}
+	for group, members := range other {
+		groups[group] = members
+	}
+	return groups
+}
+
+// ReadGroups reads the interface groups stored in the DB at dbPath by all goProbe instances (see
+// InstanceFileName()). Groups of the same name stored by multiple instances comprise the members
+// of all of them. If none were stored, no groups are returned
+func ReadGroups(dbPath string) (Groups, error) {
+	paths, err := instanceFilePaths(dbPath, groupsFileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read interface groups: %w", err)
+	}
+
+	var groups Groups
+	for _, path := range paths {
+		data, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read interface groups: %w", err)
+		}
+
+		var instanceGroups Groups
+		if err = jsoniter.Unmarshal(data, &instanceGroups); err != nil {
+			return nil, fmt.Errorf("failed to parse interface groups: %w", err)
+		}
+		if groups == nil {
+			groups = instanceGroups
+			continue
+		}
+		for group, members := range instanceGroups {
+			members = append(groups[group], members...)
+			slices.Sort(members)
+			groups[group] = slices.Compact(members)
+		}
+	}
+	return groups, nil
+}
+
+// WriteGroups stores the interface groups of a goProbe instance in the DB at dbPath, so that queries
+// run against the DB accept them in place of interface names. Storing no groups removes the ones stored
+// previously by the instance
+func WriteGroups(dbPath, instance string, groups Groups) error {
+	if err := CheckDBExists(dbPath); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dbPath, InstanceFileName(groupsFileName, instance))
+	if len(groups) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove interface groups: %w", err)
+		}
+		return nil
+	}
+
+	// members are stored in order so that the file only changes if the groups do
+	sorted := make(Groups, len(groups))
+	for group, members := range groups {
+		sorted[group] = slices.Clone(members)
+		slices.Sort(sorted[group])
+	}
+
+	data, err := jsoniter.Marshal(sorted)
+	if err != nil {
+		return fmt.Errorf("failed to serialize interface groups: %w", err)
+	}
+	if err = writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to store interface groups: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package info
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const identityFileName = "host.identity"
+
+// Identity denotes the identity of the host that captured the flows stored in a goDB
+type Identity struct {
+	Hostname string `json:"hostname"` // Hostname: the hostname of the host. Example: probe-01
+	HostID   string `json:"host_id"`  // HostID: the unique identifier of the host. Example: 2ef5e6b2b5c14f3d9f0b7a8d7c3f0a1e
+}
+
+// IsComplete returns true if both the hostname and the host ID are set
+func (i Identity) IsComplete() bool {
+	return i.Hostname != "" && i.HostID != ""
+}
+
+// IdentityPath returns the path of the identity stored in the DB at dbPath (see WriteIdentity())
+func IdentityPath(dbPath string) string {
+	return filepath.Join(dbPath, identityFileName)
+}
+
+// DetectIdentity determines the identity of the current host. Fields which are already
+// set are retained, the remaining ones are detected from the system (using the DB at dbPath
+// as fallback location for the host ID, see GetHostID())
+func DetectIdentity(dbPath string, identity Identity) (Identity, error) {
+	if identity.Hostname == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return identity, fmt.Errorf("failed to get system hostname: %w", err)
+		}
+		identity.Hostname = hostname
+	}
+	if identity.HostID == "" {
+		identity.HostID = GetHostID(dbPath)
+	}
+	return identity, nil
+}
+
+// ReadIdentity reads the identity stored in the DB at dbPath. If none was stored, an
+// empty identity is returned
+func ReadIdentity(dbPath string) (Identity, error) {
+	var identity Identity
+
+	data, err := os.ReadFile(filepath.Clean(IdentityPath(dbPath)))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return identity, nil
+		}
+		return identity, fmt.Errorf("failed to read host identity: %w", err)
+	}
+	if err = jsoniter.Unmarshal(data, &identity); err != nil {
+		return identity, fmt.Errorf("failed to parse host identity: %w", err)
+	}
+	return identity, nil
+}
+
+// WriteIdentity stores the identity in the DB at dbPath, so that queries run against the
+// DB attribute its flows to the capturing host (even if run elsewhere, e.g. on a copy of the DB)
+func WriteIdentity(dbPath string, identity Identity) error {
+	if err := CheckDBExists(dbPath); err != nil {
+		return err
+	}
+
+	data, err := jsoniter.Marshal(identity)
+	if err != nil {
+		return fmt.Errorf("failed to serialize host identity: %w", err)
+	}
+
+	if err = writeFileAtomic(IdentityPath(dbPath), data); err != nil {
+		return fmt.Errorf("failed to store host identity: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes to a temporary file first to avoid concurrent queries reading partial
+// data. The file has to be readable by anyone with read access to the DB
+func writeFileAtomic(path string, data []byte) error {
+	// #nosec G306
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -108,3 +109,204 @@ func TestDBExists(t *testing.T) {
 	require.ErrorIs(t, CheckDBExists("/hjgfkjagdjhkad/kjagsduasgdjasg"), fs.ErrNotExist)
 	require.EqualError(t, CheckDBExists("/hjgfkjagdjhkad/kjagsduasgdjasg"), "database directory does not exist: stat /hjgfkjagdjhkad/kjagsduasgdjasg: no such file or directory")
 }
+
+func TestIdentity(t *testing.T) {
+	testPath := t.TempDir()
+
+	// nothing stored yet
+	identity, err := ReadIdentity(testPath)
+	require.Nil(t, err)
+	require.False(t, identity.IsComplete())
+
+	// configured fields take precedence over detected ones
+	identity, err = DetectIdentity(testPath, Identity{Hostname: "probe-01"})
+	require.Nil(t, err)
+	require.True(t, identity.IsComplete())
+	require.Equal(t, "probe-01", identity.Hostname)
+	require.Equal(t, GetHostID(testPath), identity.HostID)
+
+	require.Nil(t, WriteIdentity(testPath, identity))
+	stored, err := ReadIdentity(testPath)
+	require.Nil(t, err)
+	require.Equal(t, identity, stored)
+
+	require.NotNil(t, WriteIdentity(filepath.Join(testPath, "missing"), identity))
+
+	require.Nil(t, os.WriteFile(filepath.Join(testPath, identityFileName), []byte("{"), 0600))
+	_, err = ReadIdentity(testPath)
+	require.NotNil(t, err)
+}
+
+func TestTenants(t *testing.T) {
+	testPath := t.TempDir()
+
+	require.Nil(t, ValidateTenant(""))
+	require.Nil(t, ValidateTenant("acme_corp-1"))
+	require.NotNil(t, ValidateTenant("../acme"))
+	require.NotNil(t, ValidateTenant("acme corp"))
+
+	require.Equal(t, testPath, TenantPath(testPath, ""))
+	require.Equal(t, filepath.Join(testPath, "@acme"), TenantPath(testPath, "acme"))
+
+	for _, dir := range []string{"eth0", TenantPath(testPath, "acme"), TenantPath(testPath, "beta")} {
+		require.Nil(t, os.MkdirAll(filepath.Join(testPath, filepath.Base(dir), "eth1"), 0755))
+	}
+
+	tenants, err := GetTenants(testPath)
+	require.Nil(t, err)
+	require.Equal(t, []string{"acme", "beta"}, tenants)
+
+	// tenant subtrees aren't interfaces
+	ifaces, err := GetInterfaces(testPath)
+	require.Nil(t, err)
+	require.Equal(t, []string{"eth0"}, ifaces)
+
+	ifaces, err = GetInterfaces(TenantPath(testPath, "acme"))
+	require.Nil(t, err)
+	require.Equal(t, []string{"eth1"}, ifaces)
+}
+
+func TestAliases(t *testing.T) {
+	testPath := t.TempDir()
+
+	require.Nil(t, ValidateAlias("wan"))
+	require.ErrorIs(t, ValidateAlias(""), ErrInvalidAlias)
+	require.ErrorIs(t, ValidateAlias("../wan"), ErrInvalidAlias)
+
+	// nothing stored yet
+	aliases, err := ReadAliases(testPath)
+	require.Nil(t, err)
+	require.Empty(t, aliases)
+	require.Equal(t, "eth0", aliases.Resolve("eth0"))
+	require.Equal(t, "eth0", aliases.Alias("eth0"))
+
+	require.Nil(t, WriteAliases(testPath, "", Aliases{"wan": "eth0", "dmz": "eth1"}))
+	aliases, err = ReadAliases(testPath)
+	require.Nil(t, err)
+	require.Equal(t, Aliases{"wan": "eth0", "dmz": "eth1"}, aliases)
+	require.Equal(t, "eth0", aliases.Resolve("wan"))
+	require.Equal(t, "eth2", aliases.Resolve("eth2"))
+	require.Equal(t, "dmz", aliases.Alias("eth1"))
+	require.Equal(t, "eth2", aliases.Alias("eth2"))
+
+	// the aliases file isn't an interface
+	ifaces, err := GetInterfaces(testPath)
+	require.Nil(t, err)
+	require.Empty(t, ifaces)
+
+	// storing no aliases removes the existing ones
+	require.Nil(t, WriteAliases(testPath, "", nil))
+	aliases, err = ReadAliases(testPath)
+	require.Nil(t, err)
+	require.Empty(t, aliases)
+	require.Nil(t, WriteAliases(testPath, "", nil))
+
+	require.NotNil(t, WriteAliases(filepath.Join(testPath, "missing"), "", Aliases{"wan": "eth0"}))
+}
+
+func TestGroups(t *testing.T) {
+	testPath := t.TempDir()
+
+	require.Nil(t, ValidateGroup("uplinks", []string{"eth0", "wan"}))
+	require.ErrorIs(t, ValidateGroup("", []string{"eth0"}), ErrInvalidGroup)
+	require.ErrorIs(t, ValidateGroup("uplinks", nil), ErrInvalidGroup)
+	require.ErrorIs(t, ValidateGroup("uplinks", []string{"eth*"}), ErrInvalidGroup)
+
+	// nothing stored yet
+	groups, err := ReadGroups(testPath)
+	require.Nil(t, err)
+	require.Empty(t, groups)
+
+	require.Nil(t, WriteGroups(testPath, "", Groups{"uplinks": {"eth2", "eth0"}, "lan": {"eth1"}}))
+	groups, err = ReadGroups(testPath)
+	require.Nil(t, err)
+	require.Equal(t, Groups{"uplinks": {"eth0", "eth2"}, "lan": {"eth1"}}, groups)
+
+	// groups of the same name are replaced
+	require.Equal(t, Groups{"uplinks": {"eth3"}, "lan": {"eth1"}, "dmz": {"eth4"}},
+		groups.With(Groups{"uplinks": {"eth3"}, "dmz": {"eth4"}}))
+
+	// the groups file isn't an interface
+	ifaces, err := GetInterfaces(testPath)
+	require.Nil(t, err)
+	require.Empty(t, ifaces)
+
+	// storing no groups removes the existing ones
+	require.Nil(t, WriteGroups(testPath, "", nil))
+	groups, err = ReadGroups(testPath)
+	require.Nil(t, err)
+	require.Empty(t, groups)
+}
+
+func TestCaptureState(t *testing.T) {
+	testPath := t.TempDir()
+
+	// nothing stored yet
+	state, err := ReadCaptureState(testPath, "")
+	require.Nil(t, err)
+	require.Equal(t, CaptureState{}, state)
+
+	stored := CaptureState{
+		LastWriteout: time.Unix(1700000000, 0).UTC(),
+		Ifaces: map[string]IfaceCaptureState{
+			"eth0": {ReceivedTotal: 100, ProcessedTotal: 98, DroppedTotal: 2},
+		},
+	}
+	require.Nil(t, WriteCaptureState(testPath, "", stored))
+	state, err = ReadCaptureState(testPath, "")
+	require.Nil(t, err)
+	require.Equal(t, stored, state)
+
+	// the state file isn't an interface
+	ifaces, err := GetInterfaces(testPath)
+	require.Nil(t, err)
+	require.Empty(t, ifaces)
+
+	require.NotNil(t, WriteCaptureState(filepath.Join(testPath, "missing"), "", stored))
+}
+
+func TestInstances(t *testing.T) {
+	testPath := t.TempDir()
+
+	require.Nil(t, ValidateInstance("uplinks_1"))
+	require.ErrorIs(t, ValidateInstance(""), ErrInvalidInstance)
+	require.ErrorIs(t, ValidateInstance("up.links"), ErrInvalidInstance)
+	require.ErrorIs(t, ValidateInstance("../uplinks"), ErrInvalidInstance)
+
+	require.Equal(t, "iface.aliases", InstanceFileName("iface.aliases", ""))
+	require.Equal(t, "iface.uplinks.aliases", InstanceFileName("iface.aliases", "uplinks"))
+
+	// the aliases / groups of all instances are merged
+	require.Nil(t, WriteAliases(testPath, "", Aliases{"wan": "eth0"}))
+	require.Nil(t, WriteAliases(testPath, "b", Aliases{"dmz": "eth2"}))
+	require.Nil(t, WriteAliases(testPath, "a", Aliases{"lan": "eth1"}))
+	aliases, err := ReadAliases(testPath)
+	require.Nil(t, err)
+	require.Equal(t, Aliases{"wan": "eth0", "lan": "eth1", "dmz": "eth2"}, aliases)
+
+	require.Nil(t, WriteGroups(testPath, "a", Groups{"uplinks": {"eth1"}, "lan": {"eth3"}}))
+	require.Nil(t, WriteGroups(testPath, "b", Groups{"uplinks": {"eth2", "eth1"}}))
+	groups, err := ReadGroups(testPath)
+	require.Nil(t, err)
+	require.Equal(t, Groups{"uplinks": {"eth1", "eth2"}, "lan": {"eth3"}}, groups)
+
+	// removing the aliases of one instance retains the ones of the others
+	require.Nil(t, WriteAliases(testPath, "b", nil))
+	aliases, err = ReadAliases(testPath)
+	require.Nil(t, err)
+	require.Equal(t, Aliases{"wan": "eth0", "lan": "eth1"}, aliases)
+
+	// each instance keeps its own capture state
+	require.Nil(t, WriteCaptureState(testPath, "a", CaptureState{Ifaces: map[string]IfaceCaptureState{"eth1": {ReceivedTotal: 1}}}))
+	state, err := ReadCaptureState(testPath, "")
+	require.Nil(t, err)
+	require.Empty(t, state.Ifaces)
+	state, err = ReadCaptureState(testPath, "a")
+	require.Nil(t, err)
+	require.Equal(t, map[string]IfaceCaptureState{"eth1": {ReceivedTotal: 1}}, state.Ifaces)
+
+	// none of the files is an interface
+	ifaces, err := GetInterfaces(testPath)
+	require.Nil(t, err)
+	require.Empty(t, ifaces)
+}
@@ -0,0 +1,51 @@
+package info
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidInstance is returned for goProbe instance names that can't be used to tell apart the
+// files of multiple instances writing to the same DB
+var ErrInvalidInstance = errors.New("invalid instance name")
+
+// instance names are part of file names, hence they must not contain dots (separating the extension)
+var instanceRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,32}$`)
+
+// ValidateInstance checks if the name is a valid goProbe instance name
+func ValidateInstance(instance string) error {
+	if !instanceRegexp.MatchString(instance) {
+		return fmt.Errorf("%w: `%s`", ErrInvalidInstance, instance)
+	}
+	return nil
+}
+
+// InstanceFileName returns the name of a file stored at the root of the DB by a goProbe instance. If
+// multiple instances write to the same DB (each capturing a distinct set of interfaces), their files
+// are told apart by the instance name, which is inserted before the extension (e.g. iface.aliases ->
+// iface.uplinks.aliases). The default (unnamed) instance uses the name as is
+func InstanceFileName(name, instance string) string {
+	if instance == "" {
+		return name
+	}
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + instance + ext
+}
+
+// instanceFilePaths returns the paths of the files of all instances of the given name stored in the
+// DB at dbPath (whether they exist or not for the default instance). The file of the default instance
+// comes first, followed by the ones of the named instances (ordered by name)
+func instanceFilePaths(dbPath, name string) ([]string, error) {
+	ext := filepath.Ext(name)
+	paths, err := filepath.Glob(filepath.Join(dbPath, strings.TrimSuffix(name, ext)+".*"+ext))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	return append([]string{filepath.Join(dbPath, name)}, paths...), nil
+}
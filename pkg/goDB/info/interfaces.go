@@ -3,9 +3,11 @@ package info
 import (
 	"os"
 	"sort"
+	"strings"
 )
 
-// GetInterfaces returns a list of interfaces covered by this goDB
+// GetInterfaces returns a list of interfaces covered by this goDB (excluding the interfaces
+// of tenants, see TenantPath())
 func GetInterfaces(dbPath string) ([]string, error) {
 	dirents, err := os.ReadDir(dbPath)
 	if err != nil {
@@ -14,7 +16,7 @@ func GetInterfaces(dbPath string) ([]string, error) {
 
 	var ifaces []string
 	for _, dirent := range dirents {
-		if dirent.IsDir() {
+		if dirent.IsDir() && !strings.HasPrefix(dirent.Name(), TenantDirPrefix) {
 			ifaces = append(ifaces, dirent.Name())
 		}
 	}
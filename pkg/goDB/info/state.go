@@ -0,0 +1,64 @@
+package info
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const captureStateFileName = "capture.state"
+
+// CaptureState denotes the runtime state of the capture which is persisted across restarts of
+// goProbe, so that its status reports continuous counters (instead of resets)
+type CaptureState struct {
+	LastWriteout time.Time                    `json:"last_writeout"`    // LastWriteout: the time of the last writeout before shutdown
+	Ifaces       map[string]IfaceCaptureState `json:"ifaces,omitempty"` // Ifaces: the runtime stats per interface
+}
+
+// IfaceCaptureState denotes the runtime stats of the capture on an interface
+type IfaceCaptureState struct {
+	ReceivedTotal  uint64 `json:"received_total"`
+	ProcessedTotal uint64 `json:"processed_total"`
+	DroppedTotal   uint64 `json:"dropped_total"`
+}
+
+// ReadCaptureState reads the capture state of a goProbe instance stored in the DB at dbPath. If none
+// was stored, an empty state is returned
+func ReadCaptureState(dbPath, instance string) (CaptureState, error) {
+	var state CaptureState
+
+	data, err := os.ReadFile(filepath.Clean(filepath.Join(dbPath, InstanceFileName(captureStateFileName, instance))))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return state, nil
+		}
+		return state, fmt.Errorf("failed to read capture state: %w", err)
+	}
+
+	if err = jsoniter.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("failed to parse capture state: %w", err)
+	}
+	return state, nil
+}
+
+// WriteCaptureState stores the capture state of a goProbe instance in the DB at dbPath (replacing any
+// state stored previously by the instance)
+func WriteCaptureState(dbPath, instance string, state CaptureState) error {
+	if err := CheckDBExists(dbPath); err != nil {
+		return err
+	}
+
+	data, err := jsoniter.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to serialize capture state: %w", err)
+	}
+	if err = writeFileAtomic(filepath.Join(dbPath, InstanceFileName(captureStateFileName, instance)), data); err != nil {
+		return fmt.Errorf("failed to store capture state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package info
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// TenantDirPrefix denotes the prefix of the per-tenant subtrees of a goDB. Since it isn't
+// permitted in interface names, tenant subtrees can't be confused with interface directories
+const TenantDirPrefix = "@"
+
+// ErrInvalidTenant is returned for tenant names that can't be used as (part of) a DB path
+var ErrInvalidTenant = errors.New("invalid tenant name")
+
+var tenantNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// ValidateTenant checks if the tenant name is valid (an empty name denotes "no tenant")
+func ValidateTenant(tenant string) error {
+	if tenant != "" && !tenantNameRegexp.MatchString(tenant) {
+		return fmt.Errorf("%w: `%s`", ErrInvalidTenant, tenant)
+	}
+	return nil
+}
+
+// TenantPath returns the path of the subtree of the goDB at dbPath holding the flows of
+// the tenant. If no tenant is provided, dbPath itself is returned
+func TenantPath(dbPath, tenant string) string {
+	if tenant == "" {
+		return dbPath
+	}
+	return filepath.Join(dbPath, TenantDirPrefix+tenant)
+}
+
+// GetTenants returns the list of tenants that have a subtree in the goDB at dbPath
+func GetTenants(dbPath string) ([]string, error) {
+	dirents, err := os.ReadDir(dbPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var tenants []string
+	for _, dirent := range dirents {
+		if dirent.IsDir() && strings.HasPrefix(dirent.Name(), TenantDirPrefix) {
+			tenants = append(tenants, strings.TrimPrefix(dirent.Name(), TenantDirPrefix))
+		}
+	}
+	sort.Strings(tenants)
+
+	return tenants, nil
+}
@@ -0,0 +1,167 @@
+package goDB
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LockFileSuffix denotes the suffix of the lock files of the interface subtrees of the DB
+const LockFileSuffix = ".lock"
+
+// WriteoutLockFile denotes the lock file in the root of the DB coordinating the writeouts of all
+// goProbe instances sharing the DB with snapshots of it (see LockWriteout())
+const WriteoutLockFile = ".writeout" + LockFileSuffix
+
+// ErrIfaceLocked is returned if the subtree of an interface is locked by another goProbe instance
+var ErrIfaceLocked = errors.New("interface is captured by another goProbe instance")
+
+// IfaceLock denotes an exclusive lock on the subtree of an interface in the DB, ensuring that only a
+// single goProbe instance writes to it if multiple instances share the same DB. The lock is held until
+// released via Unlock() (or the process exits)
+type IfaceLock struct {
+	path string
+	file *os.File
+}
+
+type lockConfig struct {
+	permissions    fs.FileMode
+	dirPermissions fs.FileMode
+	uid, gid       int
+}
+
+// LockOption configures the creation of lock files
+type LockOption func(*lockConfig)
+
+// WithLockPermissions sets the permissions of the lock files (and the DB directories created for them)
+// to the ones of the DB. If dirPermissions is zero, they are derived from permissions
+func WithLockPermissions(permissions, dirPermissions fs.FileMode) LockOption {
+	return func(cfg *lockConfig) {
+		cfg.permissions, cfg.dirPermissions = permissions, dirPermissions
+	}
+}
+
+// WithLockOwner assigns the lock files (and the DB directories created for them) to the owner of the
+// DB. A negative UID or GID retains the one of the process
+func WithLockOwner(uid, gid int) LockOption {
+	return func(cfg *lockConfig) {
+		cfg.uid, cfg.gid = uid, gid
+	}
+}
+
+// openLockFile opens (or creates) the lock file at path, creating its directory if required
+func openLockFile(path string, opts ...LockOption) (*os.File, error) {
+	cfg := lockConfig{
+		permissions: DefaultPermissions,
+		uid:         -1,
+		gid:         -1,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.dirPermissions == 0 {
+		// add the execute bit wherever reading is permitted
+		cfg.dirPermissions = cfg.permissions | (cfg.permissions&0444)>>2
+	}
+	chown := func(path string) error {
+		if cfg.uid < 0 && cfg.gid < 0 {
+			return nil
+		}
+		return os.Chown(path, cfg.uid, cfg.gid)
+	}
+
+	dir := filepath.Dir(path)
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		if err := os.MkdirAll(dir, cfg.dirPermissions); err != nil {
+			return nil, fmt.Errorf("failed to create DB directory: %w", err)
+		}
+		if err := chown(dir); err != nil {
+			return nil, fmt.Errorf("failed to set owner of DB directory: %w", err)
+		}
+	}
+
+	_, statErr := os.Stat(path)
+	f, err := os.OpenFile(filepath.Clean(path), os.O_RDWR|os.O_CREATE, cfg.permissions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if errors.Is(statErr, fs.ErrNotExist) {
+		if err := chown(path); err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("failed to set owner of lock file: %w", err)
+		}
+	}
+	return f, nil
+}
+
+// LockIface acquires the lock of the interface subtree of the DB at dbPath (which may be the subtree of
+// a tenant, see info.TenantPath()). The lock file is stored alongside the subtree. If the lock is held
+// by another instance, ErrIfaceLocked is returned (along with the description of the holder)
+func LockIface(dbPath, iface, holder string, opts ...LockOption) (*IfaceLock, error) {
+	path := filepath.Join(dbPath, iface+LockFileSuffix)
+	f, err := openLockFile(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = lockFile(f); err != nil {
+		data, _ := os.ReadFile(filepath.Clean(path))
+		_ = f.Close()
+		if errors.Is(err, errLockHeld) {
+			return nil, fmt.Errorf("%w: %s (held by %s)", ErrIfaceLocked, iface, strings.TrimSpace(string(data)))
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	// record the holder to support troubleshooting
+	if err = f.Truncate(0); err == nil {
+		_, err = f.WriteAt([]byte(holder+"\n"), 0)
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("failed to write lock file: %w", err)
+	}
+
+	return &IfaceLock{path: path, file: f}, nil
+}
+
+// Path returns the path of the lock file
+func (l *IfaceLock) Path() string {
+	return l.path
+}
+
+// Unlock releases the lock. The lock file is retained, since removing it could race with another
+// instance acquiring the lock
+func (l *IfaceLock) Unlock() error {
+	return l.file.Close()
+}
+
+// WriteoutLock denotes a lock on the DB as a whole, coordinating the writeouts of all goProbe instances
+// sharing the DB with snapshots of it
+type WriteoutLock struct {
+	file *os.File
+}
+
+// LockWriteout acquires the writeout lock of the DB at dbPath, waiting for it to become available.
+// Writeouts share the lock, whereas snapshots acquire it exclusively, hence a snapshot waits for the
+// writeouts in progress (of any instance) to complete and suspends further ones until it's taken
+func LockWriteout(dbPath string, exclusive bool, opts ...LockOption) (*WriteoutLock, error) {
+	path := filepath.Join(dbPath, WriteoutLockFile)
+	f, err := openLockFile(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err = waitLockFile(f, exclusive); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	return &WriteoutLock{file: f}, nil
+}
+
+// Unlock releases the lock
+func (l *WriteoutLock) Unlock() error {
+	return l.file.Close()
+}
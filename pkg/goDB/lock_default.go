@@ -0,0 +1,21 @@
+//go:build !linux
+// +build !linux
+
+package goDB
+
+import (
+	"errors"
+	"os"
+)
+
+var errLockHeld = errors.New("lock held")
+
+// lockFile is a no-op on platforms not supporting the capture, hence no other instance can hold the lock
+func lockFile(_ *os.File) error {
+	return nil
+}
+
+// waitLockFile is a no-op on platforms not supporting the capture, hence no other instance can hold the lock
+func waitLockFile(_ *os.File, _ bool) error {
+	return nil
+}
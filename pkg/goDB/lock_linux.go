@@ -0,0 +1,38 @@
+//go:build linux
+// +build linux
+
+package goDB
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+var errLockHeld = errors.New("lock held")
+
+// lockFile places an exclusive (advisory) lock on the file without blocking. The lock is released once
+// the file is closed
+func lockFile(f *os.File) error {
+	err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if errors.Is(err, unix.EWOULDBLOCK) {
+		return errLockHeld
+	}
+	return err
+}
+
+// waitLockFile places an exclusive or shared (advisory) lock on the file, blocking until it is granted.
+// The lock is released once the file is closed
+func waitLockFile(f *os.File, exclusive bool) error {
+	how := unix.LOCK_SH
+	if exclusive {
+		how = unix.LOCK_EX
+	}
+	for {
+		err := unix.Flock(int(f.Fd()), how)
+		if !errors.Is(err, unix.EINTR) {
+			return err
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package goDB
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/goDB/storage/gpfile"
+)
+
+// DayUsage denotes the disk usage of a single day directory of an interface
+type DayUsage struct {
+	Iface     string `json:"iface"`            // Iface: interface the day directory belongs to. Example: "eth0"
+	Tenant    string `json:"tenant,omitempty"` // Tenant: tenant the interface belongs to (if any). Example: "acme"
+	Timestamp int64  `json:"timestamp"`        // Timestamp: start of the day (UNIX timestamp). Example: 1672531200
+	Path      string `json:"path,omitempty"`   // Path: location of the day directory. Example: "/usr/local/goProbe/db/eth0/2023/01/1672531200"
+	Size      int64  `json:"size"`             // Size: accumulated size of all files in the directory (in bytes). Example: 1048576
+	NumFiles  int    `json:"num_files"`        // NumFiles: number of files in the directory. Example: 9
+}
+
+// DayUsages denotes a list of day directory disk usages
+type DayUsages []DayUsage
+
+// Size returns the accumulated size of all day directories
+func (d DayUsages) Size() (size int64) {
+	for _, usage := range d {
+		size += usage.Size
+	}
+	return
+}
+
+// DiskUsage walks the DB tree and reports the disk usage of every day directory, ordered by
+// tenant, interface and time. If no interfaces are provided, all interfaces found in the DB (including
+// the subtrees of all tenants) are covered. It only relies on the directory structure and can hence be used without a running goProbe
+func DiskUsage(dbPath string, ifaces ...string) (DayUsages, error) {
+	var usages DayUsages
+	err := walkDayDirs(dbPath, ifaces, func(usage DayUsage) error {
+		usages = append(usages, usage)
+		return nil
+	})
+	return usages, err
+}
+
+// Expire removes all day directories which exclusively contain data older than cutoff (UNIX
+// timestamp), including those of the subtrees of all tenants. Directories for the year / month which
+// are empty after removal are cleaned up as well. If dryRun is set, nothing is deleted. The (to be) removed directories are returned
+func Expire(dbPath string, cutoff int64, dryRun bool, ifaces ...string) (DayUsages, error) {
+	var expired DayUsages
+	err := walkDayDirs(dbPath, ifaces, func(usage DayUsage) error {
+		if usage.Timestamp+gpfile.EpochDay > cutoff {
+			return nil
+		}
+		expired = append(expired, usage)
+		return nil
+	})
+	if err != nil || dryRun {
+		return expired, err
+	}
+
+	for _, usage := range expired {
+		if err := os.RemoveAll(usage.Path); err != nil {
+			return expired, fmt.Errorf("failed to remove expired directory %s: %w", usage.Path, err)
+		}
+
+		// clean up the month and year directories if they don't hold any more data
+		monthDir := filepath.Dir(usage.Path)
+		for _, dir := range []string{monthDir, filepath.Dir(monthDir)} {
+			if err := removeIfEmpty(dir); err != nil {
+				return expired, err
+			}
+		}
+	}
+	return expired, nil
+}
+
+type dayDirFunc func(usage DayUsage) error
+
+// walkDayDirs walks the day directories of the interfaces of the DB, covering the subtrees of all
+// tenants after those of the interfaces without tenant
+func walkDayDirs(dbPath string, ifaces []string, fn dayDirFunc) error {
+	if err := info.CheckDBExists(dbPath); err != nil {
+		return err
+	}
+
+	tenants, err := info.GetTenants(dbPath)
+	if err != nil {
+		return err
+	}
+
+	var covered []string
+	for _, tenant := range append([]string{""}, tenants...) {
+		tenantIfaces, err := walkTenantDayDirs(dbPath, tenant, ifaces, fn)
+		if err != nil {
+			return err
+		}
+		covered = append(covered, tenantIfaces...)
+	}
+	return checkIfacesCovered(ifaces, covered)
+}
+
+// walkSubtreeDayDirs walks the day directories of the interfaces within a single subtree of the DB
+// (i.e. without descending into the subtrees of tenants)
+func walkSubtreeDayDirs(dbPath string, ifaces []string, fn dayDirFunc) error {
+	if err := info.CheckDBExists(dbPath); err != nil {
+		return err
+	}
+
+	covered, err := walkTenantDayDirs(dbPath, "", ifaces, fn)
+	if err != nil {
+		return err
+	}
+	return checkIfacesCovered(ifaces, covered)
+}
+
+// checkIfacesCovered ensures that all requested interfaces were found in the DB
+func checkIfacesCovered(ifaces, covered []string) error {
+	for _, iface := range ifaces {
+		if !slices.Contains(covered, iface) {
+			return fmt.Errorf("interface %s not found in DB: %w", iface, fs.ErrNotExist)
+		}
+	}
+	return nil
+}
+
+// walkTenantDayDirs walks the day directories of the interfaces in the subtree of a tenant (the DB
+// itself if no tenant is provided). If no interfaces are provided, all interfaces of the subtree are
+// covered, otherwise those not present in the subtree are skipped. The interfaces covered are returned
+func walkTenantDayDirs(dbPath, tenant string, ifaces []string, fn dayDirFunc) ([]string, error) {
+	tenantPath := info.TenantPath(dbPath, tenant)
+	tenantIfaces, err := info.GetInterfaces(tenantPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(ifaces) > 0 {
+		tenantIfaces = slices.DeleteFunc(tenantIfaces, func(iface string) bool {
+			return !slices.Contains(ifaces, iface)
+		})
+	}
+	sort.Strings(tenantIfaces)
+
+	for _, iface := range tenantIfaces {
+		if err := walkIfaceDayDirs(tenantPath, tenant, iface, fn); err != nil {
+			return nil, err
+		}
+	}
+	return tenantIfaces, nil
+}
+
+func walkIfaceDayDirs(tenantPath, tenant, iface string, fn dayDirFunc) error {
+	ifaceDir := filepath.Join(tenantPath, iface)
+
+	// Get list of years in interface directory (ordered by directory name, i.e. time)
+	yearList, err := os.ReadDir(ifaceDir)
+	if err != nil {
+		return err
+	}
+	for _, year := range yearList {
+		if skipNonMatching(year.IsDir()) {
+			continue
+		}
+		if _, err := strconv.Atoi(year.Name()); err != nil {
+			return fmt.Errorf("failed to parse year from directory `%s`: %w", year.Name(), err)
+		}
+
+		monthList, err := os.ReadDir(filepath.Join(ifaceDir, year.Name()))
+		if err != nil {
+			return err
+		}
+		for _, month := range monthList {
+			if skipNonMatching(month.IsDir()) {
+				continue
+			}
+			if _, err := strconv.Atoi(month.Name()); err != nil {
+				return fmt.Errorf("failed to parse month from directory `%s`: %w", month.Name(), err)
+			}
+
+			monthDir := filepath.Join(ifaceDir, year.Name(), month.Name())
+			dirList, err := os.ReadDir(monthDir)
+			if err != nil {
+				return err
+			}
+			for _, day := range dirList {
+				if skipNonMatching(day.IsDir()) {
+					continue
+				}
+				dayTimestamp, err := strconv.ParseInt(day.Name(), 10, 64)
+				if err != nil {
+					return fmt.Errorf("failed to parse epoch timestamp from directory `%s`: %w", day.Name(), err)
+				}
+
+				usage := DayUsage{
+					Iface:     iface,
+					Tenant:    tenant,
+					Timestamp: dayTimestamp,
+					Path:      filepath.Join(monthDir, day.Name()),
+				}
+				if usage.Size, usage.NumFiles, err = dirSize(usage.Path); err != nil {
+					return err
+				}
+				if err := fn(usage); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func dirSize(path string) (size int64, numFiles int, err error) {
+	err = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		fileInfo, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += fileInfo.Size()
+		numFiles++
+		return nil
+	})
+	return
+}
+
+func removeIfEmpty(path string) error {
+	dirents, err := os.ReadDir(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if len(dirents) > 0 {
+		return nil
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,246 @@
+package goDB
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/goDB/storage/gpfile"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskUsageAndExpire(t *testing.T) {
+
+	// Setup a temporary directory for the test DB
+	tempDir, err := os.MkdirTemp(os.TempDir(), "maintenance_test")
+	require.Nil(t, err)
+	defer func() {
+		require.Nil(t, os.RemoveAll(tempDir))
+	}()
+
+	// Write one block per day for the last three days (and the month before) on two interfaces
+	now := gpfile.DirTimestamp(time.Now().Unix())
+	timestamps := []int64{
+		time.Unix(now, 0).AddDate(0, -1, 0).Unix(),
+		now - 2*gpfile.EpochDay,
+		now - gpfile.EpochDay,
+		now,
+	}
+	for _, iface := range []string{"eth0", "eth1"} {
+		w := NewDBWriter(tempDir, iface, encoders.EncoderTypeNull)
+		for _, ts := range timestamps {
+			require.Nil(t, w.Write(generateFlows(), capturetypes.CaptureStats{}, ts+DBWriteInterval))
+		}
+	}
+
+	t.Run("DiskUsage", func(t *testing.T) {
+		usages, err := DiskUsage(tempDir)
+		require.Nil(t, err)
+		require.Len(t, usages, 2*len(timestamps))
+		for _, usage := range usages {
+			require.Greater(t, usage.Size, int64(0))
+			require.Greater(t, usage.NumFiles, 0)
+		}
+		require.Equal(t, "eth0", usages[0].Iface)
+		require.Equal(t, "eth1", usages[len(usages)-1].Iface)
+
+		usages, err = DiskUsage(tempDir, "eth1")
+		require.Nil(t, err)
+		require.Len(t, usages, len(timestamps))
+
+		_, err = DiskUsage(filepath.Join(tempDir, "nonexistent"))
+		require.NotNil(t, err)
+	})
+
+	t.Run("ReadMetadata", func(t *testing.T) {
+		usages, err := DiskUsage(tempDir, "eth0")
+		require.Nil(t, err)
+		var totalSize int64
+		for _, usage := range usages {
+			totalSize += usage.Size
+		}
+
+		wm, err := NewDBWorkManager(NewMetadataQuery(), tempDir, "eth0", 1)
+		require.Nil(t, err)
+		metadata, err := wm.ReadMetadata(0, time.Now().Unix()+DBWriteInterval)
+		require.Nil(t, err)
+		require.Equal(t, len(timestamps), metadata.Days)
+
+		// the data size excludes the metadata files
+		require.Greater(t, metadata.DiskSize, uint64(0))
+		require.Less(t, metadata.DiskSize, uint64(totalSize))
+	})
+
+	t.Run("ReadDayMetadata", func(t *testing.T) {
+		days, err := ReadDayMetadata(tempDir, now-gpfile.EpochDay, time.Now().Unix()+DBWriteInterval, "eth1")
+		require.Nil(t, err)
+		require.Len(t, days, 2)
+		for i, day := range days {
+			require.Equal(t, "eth1", day.Iface)
+			require.Equal(t, timestamps[i+2], day.Timestamp)
+			require.Empty(t, day.Path)
+			require.Greater(t, day.Size, int64(0))
+			require.Greater(t, day.Counts.SumBytes(), uint64(0))
+
+			require.Len(t, day.Blocks, 1)
+			require.Equal(t, time.Unix(timestamps[i+2]+DBWriteInterval, 0), day.Blocks[0].Timestamp)
+			require.Equal(t, day.Traffic, day.Blocks[0].TrafficMetadata)
+		}
+
+		// days without blocks in the time range are omitted
+		days, err = ReadDayMetadata(tempDir, now+2*DBWriteInterval, time.Now().Unix()+DBWriteInterval)
+		require.Nil(t, err)
+		require.Empty(t, days)
+	})
+
+	t.Run("ExpireDryRun", func(t *testing.T) {
+		expired, err := Expire(tempDir, now-gpfile.EpochDay, true)
+		require.Nil(t, err)
+		require.Len(t, expired, 4)
+
+		usages, err := DiskUsage(tempDir)
+		require.Nil(t, err)
+		require.Len(t, usages, 2*len(timestamps))
+	})
+
+	t.Run("ExpireIface", func(t *testing.T) {
+		expired, err := Expire(tempDir, now-gpfile.EpochDay, false, "eth0")
+		require.Nil(t, err)
+		require.Len(t, expired, 2)
+		for _, usage := range expired {
+			require.Equal(t, "eth0", usage.Iface)
+			_, err := os.Stat(usage.Path)
+			require.ErrorIs(t, err, os.ErrNotExist)
+		}
+
+		// the month directory of the oldest entry must have been removed alongside it
+		// unless it contains data from another day
+		usages, err := DiskUsage(tempDir, "eth0")
+		require.Nil(t, err)
+		require.Len(t, usages, 2)
+		for _, usage := range usages {
+			require.GreaterOrEqual(t, usage.Timestamp, now-gpfile.EpochDay)
+		}
+
+		usages, err = DiskUsage(tempDir, "eth1")
+		require.Nil(t, err)
+		require.Len(t, usages, len(timestamps))
+	})
+}
+
+func TestDiskUsageAndExpireTenants(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Write one block yesterday and today on the same interface, with and without tenant
+	now := gpfile.DirTimestamp(time.Now().Unix())
+	for _, tenant := range []string{"", "acme"} {
+		w := NewDBWriter(info.TenantPath(tempDir, tenant), "eth0", encoders.EncoderTypeNull)
+		for _, ts := range []int64{now - gpfile.EpochDay, now} {
+			require.Nil(t, w.Write(generateFlows(), capturetypes.CaptureStats{}, ts+DBWriteInterval))
+		}
+	}
+
+	// the subtrees of tenants are covered after the interfaces without tenant
+	usages, err := DiskUsage(tempDir, "eth0")
+	require.Nil(t, err)
+	require.Len(t, usages, 4)
+	for _, usage := range usages {
+		require.Equal(t, "eth0", usage.Iface)
+	}
+	require.Empty(t, usages[1].Tenant)
+	require.Equal(t, "acme", usages[2].Tenant)
+
+	_, err = DiskUsage(tempDir, "eth1")
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	allStats, err := ComputeColumnStats(tempDir, 0, 1)
+	require.Nil(t, err)
+	require.Len(t, allStats, 2)
+	require.Equal(t, "acme", allStats[1].Tenant)
+	require.Equal(t, allStats[0].Traffic, allStats[1].Traffic)
+
+	// the metadata of a subtree excludes the data of the tenants
+	days, err := ReadDayMetadata(tempDir, 0, time.Now().Unix()+DBWriteInterval)
+	require.Nil(t, err)
+	require.Len(t, days, 2)
+
+	expired, err := Expire(tempDir, now, false)
+	require.Nil(t, err)
+	require.Len(t, expired, 2)
+	require.Equal(t, "acme", expired[1].Tenant)
+
+	usages, err = DiskUsage(tempDir)
+	require.Nil(t, err)
+	require.Len(t, usages, 2)
+	for _, usage := range usages {
+		require.Equal(t, now, usage.Timestamp)
+	}
+}
+
+func TestColumnStats(t *testing.T) {
+
+	// Setup a temporary directory for the test DB
+	tempDir, err := os.MkdirTemp(os.TempDir(), "column_stats_test")
+	require.Nil(t, err)
+	defer func() {
+		require.Nil(t, os.RemoveAll(tempDir))
+	}()
+
+	// Write two blocks yesterday and one today
+	now := gpfile.DirTimestamp(time.Now().Unix())
+	timestamps := []int64{
+		now - gpfile.EpochDay + DBWriteInterval,
+		now - gpfile.EpochDay + 2*DBWriteInterval,
+		now + DBWriteInterval,
+	}
+	w := NewDBWriter(tempDir, "eth0", encoders.EncoderTypeLZ4)
+	for _, ts := range timestamps {
+		require.Nil(t, w.Write(generateFlows(), capturetypes.CaptureStats{}, ts))
+	}
+
+	allStats, err := ComputeColumnStats(tempDir, 0, 2)
+	require.Nil(t, err)
+	require.Len(t, allStats, 1)
+
+	stats := allStats[0]
+	require.Equal(t, "eth0", stats.Iface)
+	require.Equal(t, timestamps[0], stats.First)
+	require.Equal(t, timestamps[2], stats.Last)
+	require.Equal(t, 2, stats.NumDays)
+	require.Equal(t, 3, stats.NumBlocks)
+	require.Equal(t, 2, stats.NumBlocksSampled)
+	require.Equal(t, uint64(3*testNv4), stats.Traffic.NumV4Entries)
+	require.Equal(t, uint64(3*testNv6), stats.Traffic.NumV6Entries)
+	require.Equal(t, float64(testNv4+testNv6), stats.AvgFlowsPerBlock())
+
+	// the sampled blocks hold identical flows, hence the cardinalities match those of a single block
+	require.InEpsilon(t, testNv4+testNv6, stats.Distinct.SIP, 0.1)
+	require.InEpsilon(t, testNv4+testNv6, stats.Distinct.DIP, 0.1)
+	require.InEpsilon(t, testNv4, stats.Distinct.Dport, 0.1)
+	require.Equal(t, uint64(testNv4), stats.Distinct.Proto)
+	require.Len(t, stats.Protocols, testNv4)
+	require.Equal(t, uint64(4), stats.Protocols[0].Flows)
+
+	require.Len(t, stats.Columns, int(types.ColIdxCount))
+	for _, col := range stats.Columns {
+		require.Greater(t, col.AvgSize, float64(0))
+		require.Greater(t, col.AvgRawSize, float64(0))
+	}
+
+	// only the blocks of today are covered
+	allStats, err = ComputeColumnStats(tempDir, now, 1, "eth0")
+	require.Nil(t, err)
+	require.Len(t, allStats, 1)
+	require.Equal(t, 1, allStats[0].NumDays)
+	require.Equal(t, 1, allStats[0].NumBlocks)
+	require.Equal(t, 1, allStats[0].NumBlocksSampled)
+
+	allStats, err = ComputeColumnStats(tempDir, now+gpfile.EpochDay, 1)
+	require.Nil(t, err)
+	require.Empty(t, allStats)
+}
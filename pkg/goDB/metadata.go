@@ -1,6 +1,11 @@
 package goDB
 
 import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
 	"github.com/els0r/goProbe/pkg/formatting"
 	"github.com/els0r/goProbe/pkg/goDB/storage/gpfile"
 	"github.com/els0r/goProbe/pkg/results"
@@ -13,6 +18,11 @@ type InterfaceMetadata struct {
 	Iface string `json:"iface"`
 	results.TimeRange
 
+	// Days: number of (daily) directories holding data in the time range
+	Days int `json:"days"`
+	// DiskSize: on-disk size of these directories' data in bytes
+	DiskSize uint64 `json:"disk_size"`
+
 	gpfile.Stats
 }
 
@@ -22,7 +32,7 @@ func (i *InterfaceMetadata) TableHeader(detailed bool) (headerRows [][]string) {
 	fromTo := []string{"from", "to"}
 
 	if detailed {
-		r0 := []string{"", "packets", "packets", "bytes", "bytes", "# of", "# of", "", "", ""}
+		r0 := []string{"", "packets", "packets", "bytes", "bytes", "# of", "# of", "", "", "", "", ""}
 		r1 = append(r1, "in", "out", "in", "out", "IPv4 flows", "IPv6 flows", "drops")
 
 		headerRows = append(headerRows, r0)
@@ -31,6 +41,7 @@ func (i *InterfaceMetadata) TableHeader(detailed bool) (headerRows [][]string) {
 	}
 
 	r1 = append(r1, fromTo...)
+	r1 = append(r1, "days", "on disk")
 
 	headerRows = append(headerRows, r1)
 	return headerRows
@@ -58,5 +69,67 @@ func (i *InterfaceMetadata) TableRow(detailed bool) []string {
 
 	}
 	str = append(str, fromTo...)
+	str = append(str, strconv.Itoa(i.Days), formatting.Size(i.DiskSize))
 	return str
 }
+
+// DayMetadata denotes the metadata of the blocks an interface wrote on a single day. It is read from the
+// metadata of the day directory only (i.e. without reading any flow data), hence it allows to cheaply
+// check the freshness and volume of the data
+type DayMetadata struct {
+	DayUsage
+
+	// Stats: traffic, number of flows and drops of all blocks of the day
+	gpfile.Stats
+
+	// Blocks: the blocks of the day within the requested time range
+	Blocks []BlockMetadata `json:"blocks"`
+}
+
+// BlockMetadata denotes the metadata of a single block
+type BlockMetadata struct {
+	Timestamp time.Time `json:"timestamp"` // Timestamp: the time the block was written. Example: 2024-03-01T12:05:00Z
+
+	gpfile.TrafficMetadata
+}
+
+// ReadDayMetadata walks the DB tree and reads the metadata of all day directories holding blocks within
+// [tfirst, tlast], ordered by interface and time. If no interfaces are provided, all interfaces found in
+// the DB are covered
+func ReadDayMetadata(dbPath string, tfirst, tlast int64, ifaces ...string) ([]DayMetadata, error) {
+	var days []DayMetadata
+	err := walkSubtreeDayDirs(dbPath, ifaces, func(usage DayUsage) error {
+		if usage.Timestamp+gpfile.EpochDay <= tfirst || usage.Timestamp > tlast {
+			return nil
+		}
+
+		dir := gpfile.NewDir(filepath.Join(dbPath, usage.Iface), usage.Timestamp, gpfile.ModeRead)
+		if err := dir.Open(); err != nil {
+			return fmt.Errorf("failed to open GPDir %s: %w", dir.Path(), err)
+		}
+		day := DayMetadata{
+			DayUsage: usage,
+			Stats:    dir.Stats,
+		}
+		for b, block := range dir.BlockMetadata[0].Blocks() {
+			if block.Timestamp < tfirst || block.Timestamp > tlast {
+				continue
+			}
+			day.Blocks = append(day.Blocks, BlockMetadata{
+				Timestamp:       time.Unix(block.Timestamp, 0),
+				TrafficMetadata: dir.TrafficAtIndex(b),
+			})
+		}
+		if err := dir.Close(); err != nil {
+			return fmt.Errorf("failed to close GPDir %s: %w", dir.Path(), err)
+		}
+
+		// the location of the directory is an implementation detail of the DB
+		day.Path = ""
+		if len(day.Blocks) > 0 {
+			days = append(days, day)
+		}
+		return nil
+	})
+	return days, err
+}
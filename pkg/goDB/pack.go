@@ -0,0 +1,750 @@
+package goDB
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/els0r/goProbe/pkg/goDB/encoder"
+	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/goDB/storage/gpfile"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/fako1024/gotools/bitpack"
+)
+
+// A pack is a single-file archive of the blocks of a time range of the DB, allowing to ship them
+// between sites (as opposed to copying the directory trees). It has the following format:
+//
+//	magic ("GOPACK") | format version (1 byte) | encoder type (1 byte)
+//	block record: uvarint length of the payload | payload | CRC-32C of the payload (4 bytes)
+//	...
+//	end of blocks: uvarint 0 | number of blocks (8 bytes) | CRC-32C of all preceding bytes (4 bytes)
+//
+// The payload of a block record holds the interface, the tenant it belongs to (empty if none, as of
+// format version 2), the timestamp and the traffic metadata of the block (as varints), followed by all
+// of its columns and its labels (resolved from the dictionaries of its directory). Strings are prefixed
+// by their uvarint length. Columns and labels are stored as uvarint raw length | uvarint length | data,
+// the data being compressed with the encoder of the pack. All multi-byte integers are big-endian
+const (
+	packMagic   = "GOPACK"
+	packVersion = 2
+
+	// packVersionNoTenants denotes the format version preceding the support of tenants
+	packVersionNoTenants = 1
+
+	// maxPackRecordSize limits the size of a block record, so that a corrupt length doesn't cause
+	// an excessive allocation
+	maxPackRecordSize = 1 << 30
+)
+
+var (
+	// ErrInvalidPack denotes that a pack is malformed or corrupt (e.g. due to a checksum mismatch)
+	ErrInvalidPack = errors.New("invalid pack")
+
+	// ErrPackConflict denotes that a block of a pack can't be added to the DB, since its directory
+	// already holds later blocks
+	ErrPackConflict = errors.New("block of pack conflicts with DB")
+)
+
+var packCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+// PackInfo summarizes a pack of blocks of the DB
+type PackInfo struct {
+	Ifaces     []string `json:"ifaces"`                // Ifaces: interfaces covered by the pack. Example: ["eth0", "eth1"]
+	Tenants    []string `json:"tenants,omitempty"`     // Tenants: tenants whose interfaces are covered by the pack. Example: ["acme"]
+	First      int64    `json:"first,omitempty"`       // First: timestamp of the earliest block (UNIX timestamp). Example: 1672531200
+	Last       int64    `json:"last,omitempty"`        // Last: timestamp of the latest block (UNIX timestamp). Example: 1672617300
+	NumBlocks  int      `json:"num_blocks"`            // NumBlocks: number of blocks in the pack. Example: 576
+	NumSkipped int      `json:"num_skipped,omitempty"` // NumSkipped: number of blocks not unpacked since they were already present in the DB. Example: 0
+	Size       int64    `json:"size"`                  // Size: size of the pack (in bytes). Example: 1048576
+}
+
+func (p *PackInfo) add(iface, tenant string, timestamp int64) {
+	if !slices.Contains(p.Ifaces, iface) {
+		p.Ifaces = append(p.Ifaces, iface)
+	}
+	if tenant != "" && !slices.Contains(p.Tenants, tenant) {
+		p.Tenants = append(p.Tenants, tenant)
+	}
+	if p.First == 0 || timestamp < p.First {
+		p.First = timestamp
+	}
+	p.Last = max(p.Last, timestamp)
+	p.NumBlocks++
+}
+
+// packBlock denotes a block of a pack
+type packBlock struct {
+	iface     string
+	tenant    string
+	timestamp int64
+	traffic   gpfile.TrafficMetadata
+	columns   [types.ColIdxCount][]byte
+	labels    map[string][]string
+}
+
+// Pack writes all blocks of the DB at dbPath within [tfirst, tlast] (UNIX timestamps) to w, compressing
+// them with the given encoder. If no interfaces are provided, all interfaces found in the DB (including
+// the subtrees of all tenants) are covered. The pack can be added to another DB via Unpacker.Unpack()
+func Pack(dbPath string, w io.Writer, tfirst, tlast int64, encoderType encoders.Type, ifaces ...string) (PackInfo, error) {
+	var summary PackInfo
+
+	enc, err := encoder.New(encoderType)
+	if err != nil {
+		return summary, err
+	}
+	defer enc.Close()
+
+	pw := &packWriter{
+		w:   bufio.NewWriter(w),
+		crc: crc32.New(packCRCTable),
+		enc: enc,
+	}
+	if err := pw.write(append([]byte(packMagic), packVersion, byte(encoderType))); err != nil {
+		return summary, err
+	}
+
+	err = walkDayDirs(dbPath, ifaces, func(usage DayUsage) error {
+		if usage.Timestamp > tlast || usage.Timestamp+gpfile.EpochDay+DBWriteInterval < tfirst {
+			return nil
+		}
+		return packDir(dbPath, usage, tfirst, tlast, func(block *packBlock) error {
+			summary.add(block.iface, block.tenant, block.timestamp)
+			return pw.writeBlock(block)
+		})
+	})
+	if err != nil {
+		return summary, err
+	}
+
+	if err := pw.writeTrailer(summary.NumBlocks); err != nil {
+		return summary, err
+	}
+	summary.Size = pw.size
+	return summary, pw.w.Flush()
+}
+
+// packDir reads all blocks of a day directory within [tfirst, tlast] and passes them to fn
+func packDir(dbPath string, usage DayUsage, tfirst, tlast int64, fn func(block *packBlock) error) (err error) {
+	ifaceDir := filepath.Join(info.TenantPath(dbPath, usage.Tenant), usage.Iface)
+	dir := gpfile.NewDir(ifaceDir, usage.Timestamp, gpfile.ModeRead)
+	if err := dir.Open(); err != nil {
+		return fmt.Errorf("failed to open GPDir %s: %w", dir.Path(), err)
+	}
+	defer func() {
+		if cerr := dir.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	for b, block := range dir.BlockMetadata[0].Blocks() {
+		if block.Timestamp < tfirst || block.Timestamp > tlast {
+			continue
+		}
+
+		pb := &packBlock{
+			iface:     usage.Iface,
+			tenant:    usage.Tenant,
+			timestamp: block.Timestamp,
+			traffic:   dir.TrafficAtIndex(b),
+		}
+		for colIdx := types.ColumnIndex(0); colIdx < types.ColIdxCount; colIdx++ {
+			if pb.columns[colIdx], err = dir.ReadBlockAtIndex(colIdx, b); err != nil {
+				return fmt.Errorf("failed to read column %s of block %d in %s: %w", types.ColumnFileNames[colIdx], block.Timestamp, dir.Path(), err)
+			}
+		}
+		for _, name := range dir.LabelColumns() {
+			data, err := dir.ReadLabelBlockAtIndex(name, b)
+			if err != nil {
+				return fmt.Errorf("failed to read label column %s of block %d in %s: %w", name, block.Timestamp, dir.Path(), err)
+			}
+			if len(data) == 0 {
+				continue
+			}
+			dict, err := dir.Dictionary(name)
+			if err != nil {
+				return err
+			}
+			if pb.labels == nil {
+				pb.labels = make(map[string][]string)
+			}
+			if pb.labels[name], err = dict.Resolve(data); err != nil {
+				return err
+			}
+		}
+
+		if err := fn(pb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unpacker adds the blocks of packs (see Pack()) to a DB
+type Unpacker struct {
+	dbPath         string
+	encoderType    encoders.Type
+	permissions    fs.FileMode
+	dirPermissions fs.FileMode
+	uid, gid       int
+	dryRun         bool
+}
+
+// NewUnpacker instantiates a new Unpacker adding the blocks to the DB at dbPath, writing them with
+// the given encoder
+func NewUnpacker(dbPath string, encoderType encoders.Type) *Unpacker {
+	return &Unpacker{
+		dbPath:      dbPath,
+		encoderType: encoderType,
+		permissions: DefaultPermissions,
+		uid:         -1,
+		gid:         -1,
+	}
+}
+
+// Permissions overrides the default permissions for files / directories in the DB
+func (u *Unpacker) Permissions(permissions fs.FileMode) *Unpacker {
+	u.permissions = permissions
+	return u
+}
+
+// DirPermissions overrides the permissions of directories created in the DB (by default, they are
+// derived from the file permissions)
+func (u *Unpacker) DirPermissions(permissions fs.FileMode) *Unpacker {
+	u.dirPermissions = permissions
+	return u
+}
+
+// Owner assigns files / directories created in the DB to the given user / group. A negative UID or
+// GID retains the one of the process
+func (u *Unpacker) Owner(uid, gid int) *Unpacker {
+	u.uid, u.gid = uid, gid
+	return u
+}
+
+// DryRun only verifies packs instead of adding their blocks to the DB
+func (u *Unpacker) DryRun(enabled bool) *Unpacker {
+	u.dryRun = enabled
+	return u
+}
+
+// Unpack adds all blocks of a pack read from r to the DB. The pack is verified as a whole (including
+// all checksums) before anything is written, hence a corrupt or truncated pack leaves the DB untouched.
+// Unless r can be rewound, the pack is buffered in a temporary file in the meantime. Blocks already
+// present in the DB are skipped (hence a pack can be unpacked repeatedly). The interfaces of the pack
+// are locked while their blocks are written (see LockIface()), failing if they are captured by a
+// goProbe instance
+func (u *Unpacker) Unpack(r io.Reader) (summary PackInfo, err error) {
+	var (
+		src   io.ReadSeeker
+		start int64
+	)
+	if rs, ok := r.(io.ReadSeeker); ok {
+		if start, err = rs.Seek(0, io.SeekCurrent); err == nil {
+			src = rs
+		}
+	}
+	if src == nil && !u.dryRun {
+		tmp, err := os.CreateTemp("", "goprobe-unpack-")
+		if err != nil {
+			return summary, fmt.Errorf("failed to buffer pack: %w", err)
+		}
+		defer func() {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}()
+		r, src, start = io.TeeReader(r, tmp), tmp, 0
+	}
+
+	ifaces := make(map[packIface]struct{})
+	if summary, err = u.unpack(r, func(block *packBlock, _ types.Counters) error {
+		ifaces[packIface{block.tenant, block.iface}] = struct{}{}
+		return nil
+	}); err != nil || u.dryRun {
+		return summary, err
+	}
+	if _, err := src.Seek(start, io.SeekStart); err != nil {
+		return summary, fmt.Errorf("failed to rewind pack: %w", err)
+	}
+
+	var locks []interface{ Unlock() error }
+	defer func() {
+		for _, lock := range locks {
+			if uerr := lock.Unlock(); uerr != nil && err == nil {
+				err = uerr
+			}
+		}
+	}()
+	lockOpts := []LockOption{WithLockPermissions(u.permissions, u.dirPermissions), WithLockOwner(u.uid, u.gid)}
+	holder := fmt.Sprintf("godb unpack, pid %d", os.Getpid())
+	for iface := range ifaces {
+		lock, err := LockIface(info.TenantPath(u.dbPath, iface.tenant), iface.iface, holder, lockOpts...)
+		if err != nil {
+			return summary, err
+		}
+		locks = append(locks, lock)
+	}
+
+	// the blocks are written like a writeout, hence snapshots of the DB are suspended meanwhile
+	writeoutLock, err := LockWriteout(u.dbPath, false, lockOpts...)
+	if err != nil {
+		return summary, err
+	}
+	locks = append(locks, writeoutLock)
+
+	// Consecutive blocks of the same directory are written without reopening it
+	var (
+		dir      *gpfile.GPDir
+		dirIface packIface
+		dirDay   int64
+		skipped  int
+	)
+	defer func() {
+		if dir != nil {
+			if cerr := dir.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+	}()
+
+	summary, err = u.unpack(src, func(block *packBlock, counters types.Counters) error {
+		iface := packIface{block.tenant, block.iface}
+		if dir == nil || dirIface != iface || dirDay != gpfile.DirTimestamp(block.timestamp) {
+			if dir != nil {
+				if err := dir.Close(); err != nil {
+					dir = nil
+					return err
+				}
+			}
+			dirIface, dirDay = iface, gpfile.DirTimestamp(block.timestamp)
+			dir = gpfile.NewDir(filepath.Join(info.TenantPath(u.dbPath, block.tenant), block.iface), block.timestamp, gpfile.ModeWrite,
+				gpfile.WithPermissions(u.permissions),
+				gpfile.WithDirPermissions(u.dirPermissions),
+				gpfile.WithOwner(u.uid, u.gid),
+				gpfile.WithEncoderTypeLevel(u.encoderType, 0),
+			)
+			if err := dir.Open(); err != nil {
+				dir = nil
+				return fmt.Errorf("failed to create / open daily directory: %w", err)
+			}
+		}
+
+		written, err := unpackBlock(dir, block, counters)
+		if err != nil {
+			return err
+		}
+		if !written {
+			skipped++
+		}
+		return nil
+	})
+	summary.NumSkipped = skipped
+	return summary, err
+}
+
+// packIface identifies the interface (within the subtree of its tenant) a block of a pack belongs to
+type packIface struct {
+	tenant, iface string
+}
+
+// unpack reads all blocks of a pack from r, passing each verified block to fn
+func (u *Unpacker) unpack(r io.Reader, fn func(block *packBlock, counters types.Counters) error) (summary PackInfo, err error) {
+	pr := &packReader{
+		r:   bufio.NewReader(r),
+		crc: crc32.New(packCRCTable),
+	}
+
+	header := make([]byte, len(packMagic)+2)
+	if err := pr.read(header); err != nil {
+		return summary, fmt.Errorf("%w: failed to read header: %w", ErrInvalidPack, err)
+	}
+	if string(header[:len(packMagic)]) != packMagic {
+		return summary, fmt.Errorf("%w: not a pack", ErrInvalidPack)
+	}
+	if pr.version = header[len(packMagic)]; pr.version != packVersion && pr.version != packVersionNoTenants {
+		return summary, fmt.Errorf("%w: unsupported format version %d", ErrInvalidPack, pr.version)
+	}
+	if pr.dec, err = encoder.New(encoders.Type(header[len(packMagic)+1])); err != nil {
+		return summary, fmt.Errorf("%w: %w", ErrInvalidPack, err)
+	}
+	defer pr.dec.Close()
+
+	for {
+		block, err := pr.readBlock()
+		if err != nil {
+			return summary, err
+		}
+		if block == nil {
+			break
+		}
+		summary.add(block.iface, block.tenant, block.timestamp)
+		counters, err := block.counters()
+		if err != nil {
+			return summary, err
+		}
+		if err := fn(block, counters); err != nil {
+			return summary, err
+		}
+	}
+
+	if err := pr.readTrailer(summary.NumBlocks); err != nil {
+		return summary, err
+	}
+	summary.Size = pr.size
+	return summary, nil
+}
+
+// counters checks that all columns of the block are consistent with its number of entries and returns
+// the sum of its counters
+func (block *packBlock) counters() (types.Counters, error) {
+	var counters [types.ColIdxCount]uint64
+	for colIdx := types.ColumnIndex(0); colIdx < types.ColIdxCount; colIdx++ {
+		found, consistent := columnEntries(colIdx, block.columns[colIdx], int(block.traffic.NumV4Entries), int(block.traffic.NumV6Entries))
+		if !consistent {
+			return types.Counters{}, fmt.Errorf("%w: column %s of block %d of %s holds %d entries, expected %d", ErrInvalidPack,
+				types.ColumnFileNames[colIdx], block.timestamp, block.iface, found, block.traffic.NumV4Entries+block.traffic.NumV6Entries)
+		}
+		if colIdx.IsCounterCol() && found > 0 {
+			for _, v := range bitpack.Unpack(block.columns[colIdx]) {
+				counters[colIdx] += v
+			}
+		}
+	}
+	return types.Counters{
+		BytesRcvd:   counters[types.BytesRcvdColIdx],
+		BytesSent:   counters[types.BytesSentColIdx],
+		PacketsRcvd: counters[types.PacketsRcvdColIdx],
+		PacketsSent: counters[types.PacketsSentColIdx],
+	}, nil
+}
+
+// unpackBlock writes a block of a pack to its directory. Returns false if it was already present
+func unpackBlock(dir *gpfile.GPDir, block *packBlock, counters types.Counters) (bool, error) {
+	blocks := dir.BlockMetadata[0].Blocks()
+	if _, exists := dir.BlockMetadata[0].BlockAtTime(block.timestamp); exists {
+		return false, nil
+	}
+	if n := len(blocks); n > 0 && blocks[n-1].Timestamp > block.timestamp {
+		return false, fmt.Errorf("%w: block %d of %s precedes the latest block %d of %s", ErrPackConflict,
+			block.timestamp, block.iface, blocks[n-1].Timestamp, dir.Path())
+	}
+
+	if err := dir.WriteBlocksWithLabels(block.timestamp, block.traffic, counters, block.columns, block.labels); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// packWriter writes the records of a pack, keeping track of its checksum
+type packWriter struct {
+	w    *bufio.Writer
+	crc  hash.Hash32
+	enc  encoder.Encoder
+	size int64
+
+	payload, buf bytes.Buffer
+}
+
+func (pw *packWriter) write(data []byte) error {
+	n, err := pw.w.Write(data)
+	pw.size += int64(n)
+	_, _ = pw.crc.Write(data[:n])
+	return err
+}
+
+func (pw *packWriter) writeBlock(block *packBlock) error {
+	pw.payload.Reset()
+	for _, s := range []string{block.iface, block.tenant} {
+		pw.payload.Write(binary.AppendUvarint(nil, uint64(len(s))))
+		pw.payload.WriteString(s)
+	}
+	pw.payload.Write(binary.AppendVarint(nil, block.timestamp))
+	for _, v := range trafficFields(&block.traffic) {
+		pw.payload.Write(binary.AppendUvarint(nil, *v))
+	}
+	for _, column := range block.columns {
+		if err := pw.writeSection(column); err != nil {
+			return err
+		}
+	}
+	if err := pw.writeSection(encodePackLabels(block.labels)); err != nil {
+		return err
+	}
+
+	record := binary.AppendUvarint(nil, uint64(pw.payload.Len()))
+	record = append(record, pw.payload.Bytes()...)
+	record = binary.BigEndian.AppendUint32(record, crc32.Checksum(pw.payload.Bytes(), packCRCTable))
+	return pw.write(record)
+}
+
+// writeSection appends the compressed data to the payload of the current block record
+func (pw *packWriter) writeSection(data []byte) error {
+	pw.payload.Write(binary.AppendUvarint(nil, uint64(len(data))))
+	if len(data) == 0 {
+		pw.payload.Write(binary.AppendUvarint(nil, 0))
+		return nil
+	}
+
+	pw.buf.Reset()
+	n, err := pw.enc.Compress(data, nil, &pw.buf)
+	if err != nil {
+		return err
+	}
+	pw.payload.Write(binary.AppendUvarint(nil, uint64(n)))
+	pw.payload.Write(pw.buf.Bytes()[:n])
+	return nil
+}
+
+func (pw *packWriter) writeTrailer(numBlocks int) error {
+	trailer := binary.AppendUvarint(nil, 0)
+	trailer = binary.BigEndian.AppendUint64(trailer, uint64(numBlocks))
+	if err := pw.write(trailer); err != nil {
+		return err
+	}
+
+	// The final checksum covers everything but itself
+	n, err := pw.w.Write(binary.BigEndian.AppendUint32(nil, pw.crc.Sum32()))
+	pw.size += int64(n)
+	return err
+}
+
+// packReader reads the records of a pack, verifying their checksums
+type packReader struct {
+	r       *bufio.Reader
+	crc     hash.Hash32
+	dec     encoder.Encoder
+	size    int64
+	version byte
+}
+
+func (pr *packReader) read(data []byte) error {
+	n, err := io.ReadFull(pr.r, data)
+	pr.size += int64(n)
+	_, _ = pr.crc.Write(data[:n])
+	return err
+}
+
+func (pr *packReader) readUvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(pr.r)
+	if err != nil {
+		return 0, err
+	}
+	encoded := binary.AppendUvarint(nil, v)
+	pr.size += int64(len(encoded))
+	_, _ = pr.crc.Write(encoded)
+	return v, nil
+}
+
+// readBlock reads the next block record of the pack. Returns nil once all blocks were read
+func (pr *packReader) readBlock() (*packBlock, error) {
+	size, err := pr.readUvarint()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read block record: %w", ErrInvalidPack, err)
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	if size > maxPackRecordSize {
+		return nil, fmt.Errorf("%w: block record of %d bytes exceeds maximum size", ErrInvalidPack, size)
+	}
+
+	record := make([]byte, size+4)
+	if err := pr.read(record); err != nil {
+		return nil, fmt.Errorf("%w: failed to read block record: %w", ErrInvalidPack, err)
+	}
+	payload := record[:size]
+	if checksum := binary.BigEndian.Uint32(record[size:]); checksum != crc32.Checksum(payload, packCRCTable) {
+		return nil, fmt.Errorf("%w: checksum mismatch of block record at offset %d", ErrInvalidPack, pr.size-int64(len(record)))
+	}
+
+	block, err := pr.decodeBlock(bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decode block record: %w", ErrInvalidPack, err)
+	}
+	return block, nil
+}
+
+func (pr *packReader) decodeBlock(r *bytes.Reader) (*packBlock, error) {
+	block := new(packBlock)
+
+	var err error
+	if block.iface, err = readPackString(r); err != nil {
+		return nil, err
+	}
+	if block.iface == "" || block.iface == "." || block.iface == ".." || filepath.Base(block.iface) != block.iface ||
+		strings.HasPrefix(block.iface, info.TenantDirPrefix) {
+		return nil, fmt.Errorf("invalid interface `%s`", block.iface)
+	}
+	if pr.version > packVersionNoTenants {
+		if block.tenant, err = readPackString(r); err != nil {
+			return nil, err
+		}
+		if err := info.ValidateTenant(block.tenant); err != nil {
+			return nil, err
+		}
+	}
+
+	if block.timestamp, err = binary.ReadVarint(r); err != nil {
+		return nil, err
+	}
+	for _, v := range trafficFields(&block.traffic) {
+		if *v, err = binary.ReadUvarint(r); err != nil {
+			return nil, err
+		}
+	}
+	for colIdx := range block.columns {
+		if block.columns[colIdx], err = pr.readSection(r); err != nil {
+			return nil, err
+		}
+	}
+	labels, err := pr.readSection(r)
+	if err != nil {
+		return nil, err
+	}
+	if block.labels, err = decodePackLabels(labels); err != nil {
+		return nil, err
+	}
+	if r.Len() > 0 {
+		return nil, fmt.Errorf("%d trailing bytes", r.Len())
+	}
+	return block, nil
+}
+
+// readPackString reads a string prefixed by its uvarint length
+func readPackString(r *bytes.Reader) (string, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	if n > uint64(r.Len()) {
+		return "", io.ErrUnexpectedEOF
+	}
+	s := make([]byte, n)
+	_, err = io.ReadFull(r, s)
+	return string(s), err
+}
+
+// readSection decompresses the next column (or labels) of a block record
+func (pr *packReader) readSection(r *bytes.Reader) ([]byte, error) {
+	rawLen, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(r.Len()) || rawLen > maxPackRecordSize {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if rawLen == 0 {
+		return []byte{}, nil
+	}
+
+	data := make([]byte, rawLen)
+	nRead, err := pr.dec.Decompress(make([]byte, n), data, r)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(nRead) != rawLen {
+		return nil, fmt.Errorf("unexpected amount of bytes after decompression, want %d, have %d", rawLen, nRead)
+	}
+	return data, nil
+}
+
+func (pr *packReader) readTrailer(numBlocks int) error {
+	trailer := make([]byte, 8)
+	if err := pr.read(trailer); err != nil {
+		return fmt.Errorf("%w: failed to read trailer: %w", ErrInvalidPack, err)
+	}
+	if n := binary.BigEndian.Uint64(trailer); n != uint64(numBlocks) {
+		return fmt.Errorf("%w: pack holds %d blocks, expected %d", ErrInvalidPack, numBlocks, n)
+	}
+
+	checksum := pr.crc.Sum32()
+	if _, err := io.ReadFull(pr.r, trailer[:4]); err != nil {
+		return fmt.Errorf("%w: failed to read trailer: %w", ErrInvalidPack, err)
+	}
+	pr.size += 4
+	if binary.BigEndian.Uint32(trailer[:4]) != checksum {
+		return fmt.Errorf("%w: checksum mismatch", ErrInvalidPack)
+	}
+	return nil
+}
+
+// trafficFields returns the fields of the traffic metadata in the order they are stored in a pack
+func trafficFields(t *gpfile.TrafficMetadata) []*uint64 {
+	return []*uint64{&t.NumV4Entries, &t.NumV6Entries, &t.NumDrops, &t.NumPackets, &t.NumOverruns, &t.NumTruncated, &t.NumLinkDown}
+}
+
+// encodePackLabels serializes the labels of a block (ordered by name) as uvarint number of label
+// columns | { name | uvarint number of values | values }, each string being prefixed by its uvarint length
+func encodePackLabels(labels map[string][]string) []byte {
+	if len(labels) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	data := binary.AppendUvarint(nil, uint64(len(names)))
+	appendString := func(s string) {
+		data = binary.AppendUvarint(data, uint64(len(s)))
+		data = append(data, s...)
+	}
+	for _, name := range names {
+		appendString(name)
+		data = binary.AppendUvarint(data, uint64(len(labels[name])))
+		for _, value := range labels[name] {
+			appendString(value)
+		}
+	}
+	return data
+}
+
+func decodePackLabels(data []byte) (map[string][]string, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	r := bytes.NewReader(data)
+
+	numLabels, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	labels := make(map[string][]string)
+	for i := uint64(0); i < numLabels; i++ {
+		name, err := readPackString(r)
+		if err != nil {
+			return nil, err
+		}
+		numValues, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if numValues > uint64(r.Len()) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		values := make([]string, numValues)
+		for j := range values {
+			if values[j], err = readPackString(r); err != nil {
+				return nil, err
+			}
+		}
+		labels[name] = values
+	}
+	return labels, nil
+}
@@ -0,0 +1,159 @@
+package goDB
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/goDB/storage/gpfile"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackUnpack(t *testing.T) {
+	srcPath, destPath := filepath.Join(t.TempDir(), "src"), filepath.Join(t.TempDir(), "dest")
+
+	// write blocks on two days for two interfaces, some of them labeled
+	day := gpfile.DirTimestamp(1704067200)
+	timestamps := []int64{day + DBWriteInterval, day + 2*DBWriteInterval, day + gpfile.EpochDay + DBWriteInterval}
+	for _, iface := range []string{"eth0", "eth1"} {
+		w := NewDBWriter(srcPath, iface, encoders.EncoderTypeLZ4)
+		for i, ts := range timestamps {
+			var labelers map[string]capturetypes.FlowLabeler
+			if i == 1 {
+				labelers = map[string]capturetypes.FlowLabeler{
+					types.ProcessName: func(key types.Key) string {
+						if key.IsIPv4() {
+							return "nginx.service"
+						}
+						return ""
+					},
+				}
+			}
+			require.Nil(t, w.WriteWithLabels(generateFlows(), labelers, capturetypes.CaptureStats{Received: 100, Dropped: 1}, ts))
+		}
+	}
+
+	pack := func(dbPath string, tfirst, tlast int64, ifaces ...string) ([]byte, PackInfo) {
+		buf := new(bytes.Buffer)
+		info, err := Pack(dbPath, buf, tfirst, tlast, encoders.EncoderTypeZSTD, ifaces...)
+		require.Nil(t, err)
+		require.Equal(t, int64(buf.Len()), info.Size)
+		return buf.Bytes(), info
+	}
+
+	// only the blocks within the time range are packed
+	data, info := pack(srcPath, timestamps[1], timestamps[2], "eth1")
+	require.Equal(t, PackInfo{Ifaces: []string{"eth1"}, First: timestamps[1], Last: timestamps[2], NumBlocks: 2, Size: info.Size}, info)
+
+	data, info = pack(srcPath, 0, timestamps[2])
+	require.Equal(t, 6, info.NumBlocks)
+
+	// verifying the pack doesn't write anything
+	verified, err := NewUnpacker(destPath, encoders.EncoderTypeLZ4).DryRun(true).Unpack(bytes.NewReader(data))
+	require.Nil(t, err)
+	require.Equal(t, info, verified)
+	_, err = DiskUsage(destPath)
+	require.NotNil(t, err)
+
+	// the unpacked DB holds the same blocks as the original one, blocks which are already present
+	// are skipped upon unpacking the pack again
+	unpacked, err := NewUnpacker(destPath, encoders.EncoderTypeLZ4).Unpack(bytes.NewReader(data))
+	require.Nil(t, err)
+	require.Equal(t, info, unpacked)
+	repacked, _ := pack(destPath, 0, timestamps[2])
+	require.Equal(t, data, repacked)
+
+	unpacked, err = NewUnpacker(destPath, encoders.EncoderTypeLZ4).Unpack(bytes.NewReader(data))
+	require.Nil(t, err)
+	require.Equal(t, 6, unpacked.NumSkipped)
+
+	for _, iface := range []string{"eth0", "eth1"} {
+		src := gpfile.NewDir(filepath.Join(srcPath, iface), day, gpfile.ModeRead)
+		dest := gpfile.NewDir(filepath.Join(destPath, iface), day, gpfile.ModeRead)
+		require.Nil(t, src.Open())
+		require.Nil(t, dest.Open())
+		require.Equal(t, src.Stats, dest.Stats)
+		require.Equal(t, src.BlockTraffic, dest.BlockTraffic)
+		require.Equal(t, src.LabelColumns(), dest.LabelColumns())
+		require.Nil(t, src.Close())
+		require.Nil(t, dest.Close())
+	}
+
+	// corrupt or truncated packs are rejected before anything is written (also if they can't be rewound)
+	for _, corrupt := range [][]byte{
+		append(append([]byte{}, data[:100]...), append([]byte{data[100] ^ 0xff}, data[101:]...)...),
+		data[:len(data)-1],
+		data[:len(data)/2],
+	} {
+		for _, r := range []io.Reader{bytes.NewReader(corrupt), bufio.NewReader(bytes.NewReader(corrupt))} {
+			corruptPath := t.TempDir()
+			_, err := NewUnpacker(corruptPath, encoders.EncoderTypeLZ4).Unpack(r)
+			require.ErrorIs(t, err, ErrInvalidPack)
+			entries, err := os.ReadDir(corruptPath)
+			require.Nil(t, err)
+			require.Empty(t, entries)
+		}
+	}
+
+	// blocks preceding the ones present in the DB can't be unpacked
+	earlier, _ := pack(srcPath, 0, timestamps[0], "eth0")
+	later, _ := pack(srcPath, timestamps[1], timestamps[1], "eth0")
+	conflictPath := t.TempDir()
+	_, err = NewUnpacker(conflictPath, encoders.EncoderTypeLZ4).Unpack(bytes.NewReader(later))
+	require.Nil(t, err)
+	_, err = NewUnpacker(conflictPath, encoders.EncoderTypeLZ4).Unpack(bytes.NewReader(earlier))
+	require.ErrorIs(t, err, ErrPackConflict)
+}
+
+func TestPackUnpackTenants(t *testing.T) {
+	srcPath, destPath := filepath.Join(t.TempDir(), "src"), filepath.Join(t.TempDir(), "dest")
+
+	timestamp := gpfile.DirTimestamp(1704067200) + DBWriteInterval
+	for _, tenant := range []string{"", "acme"} {
+		w := NewDBWriter(info.TenantPath(srcPath, tenant), "eth0", encoders.EncoderTypeLZ4)
+		require.Nil(t, w.Write(generateFlows(), capturetypes.CaptureStats{Received: 100}, timestamp))
+	}
+
+	buf := new(bytes.Buffer)
+	packed, err := Pack(srcPath, buf, 0, timestamp, encoders.EncoderTypeZSTD)
+	require.Nil(t, err)
+	require.Equal(t, PackInfo{Ifaces: []string{"eth0"}, Tenants: []string{"acme"}, First: timestamp, Last: timestamp, NumBlocks: 2, Size: packed.Size}, packed)
+
+	// the blocks of each tenant are unpacked into its subtree, applying the permissions of the DB. Packs
+	// read from a stream are buffered before being written
+	unpacked, err := NewUnpacker(destPath, encoders.EncoderTypeLZ4).Permissions(0600).DirPermissions(0700).
+		Unpack(bufio.NewReader(bytes.NewReader(buf.Bytes())))
+	require.Nil(t, err)
+	require.Equal(t, packed, unpacked)
+	for _, tenant := range []string{"", "acme"} {
+		dayPath := gpfile.NewDir(filepath.Join(info.TenantPath(destPath, tenant), "eth0"), timestamp, gpfile.ModeRead).Path()
+		stat, err := os.Stat(dayPath)
+		require.Nil(t, err)
+		require.Equal(t, fs.FileMode(0700), stat.Mode().Perm())
+	}
+	usages, err := DiskUsage(destPath)
+	require.Nil(t, err)
+	require.Len(t, usages, 2)
+	require.Equal(t, "acme", usages[1].Tenant)
+
+	// interfaces captured by a goProbe instance can't be unpacked
+	if runtime.GOOS != "linux" {
+		return
+	}
+	lock, err := LockIface(info.TenantPath(destPath, "acme"), "eth0", "test")
+	require.Nil(t, err)
+	defer func() {
+		require.Nil(t, lock.Unlock())
+	}()
+	_, err = NewUnpacker(destPath, encoders.EncoderTypeLZ4).Unpack(bytes.NewReader(buf.Bytes()))
+	require.ErrorIs(t, err, ErrIfaceLocked)
+}
@@ -1,11 +1,61 @@
 /*
-Package protocols provides lookup functionality for IP protocol IDs and their names (which are
-in some cases OS specific)
+Package protocols provides lookup functionality for IP protocol IDs and their names. The names
+are taken from the IANA protocol number registry (embedded at build time) and can be overridden
+at runtime, e.g. to name protocol numbers used for experimentation or site-specific purposes
 */
 package protocols
 
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
 //go:generate go run protocols_generator.go
 
+// DefaultOverridesFile is the location the protocol name overrides are read from by default
+const DefaultOverridesFile = "/etc/goprobe/protocols.json"
+
+//go:embed iana_protocols.csv
+var ianaProtocols []byte
+
+// IPProtocols stores the IP protocol mappings to friendly name
+var IPProtocols = make(map[int]string)
+
+// IPProtocolIDs is the reverse mapping from (lowercase) friendly name to protocol number
+var IPProtocolIDs = make(map[string]int)
+
+var (
+	errorEmptyProtocolName     = errors.New("empty protocol name")
+	errorInvalidProtocolName   = errors.New("protocol name must not contain whitespace")
+	errorDuplicateProtocolName = errors.New("protocol name is already assigned to another protocol number")
+)
+
+func init() {
+	records, err := csv.NewReader(bytes.NewReader(ianaProtocols)).ReadAll()
+	if err != nil {
+		panic(fmt.Sprintf("failed to read embedded IP protocol table: %v", err))
+	}
+
+	// the first record is the header
+	for _, record := range records[1:] {
+		id, err := strconv.ParseUint(record[0], 10, 8)
+		if err != nil {
+			panic(fmt.Sprintf("invalid protocol number in embedded IP protocol table: %v", err))
+		}
+		IPProtocols[int(id)] = record[1]
+		IPProtocolIDs[strings.ToLower(record[1])] = int(id)
+	}
+}
+
 // GetIPProto returns the friendly name for a given protocol id
 func GetIPProto(id int) string {
 	return IPProtocols[id]
@@ -16,3 +66,59 @@ func GetIPProtoID(name string) (uint64, bool) {
 	ret, ok := IPProtocolIDs[name]
 	return uint64(ret), ok
 }
+
+// LoadOverrides reads protocol name overrides from a JSON file mapping protocol numbers to names,
+// e.g.
+//
+//	{"253": "EXP1", "254": "EXP2"}
+//
+// and applies them (see Override()). The file is optional, i.e. if it doesn't exist, the IANA
+// names remain in place
+func LoadOverrides(path string) error {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read protocol overrides: %w", err)
+	}
+
+	var overrides map[uint8]string
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return fmt.Errorf("failed to parse protocol overrides from %s: %w", path, err)
+	}
+	if err := Override(overrides); err != nil {
+		return fmt.Errorf("invalid protocol overrides in %s: %w", path, err)
+	}
+	return nil
+}
+
+// Override assigns names to protocol numbers, replacing the IANA names where present. The names
+// being replaced remain valid for lookups of the protocol number (e.g. in query conditions).
+// Overrides are not safe for concurrent use with lookups and hence must be applied upon startup
+func Override(overrides map[uint8]string) error {
+	for id, name := range overrides {
+		if name == "" {
+			return fmt.Errorf("%w (protocol %d)", errorEmptyProtocolName, id)
+		}
+		if strings.ContainsFunc(name, unicode.IsSpace) {
+			return fmt.Errorf("%w: `%s`", errorInvalidProtocolName, name)
+		}
+		for otherID, otherName := range overrides {
+			if otherID != id && strings.EqualFold(name, otherName) {
+				return fmt.Errorf("%w: `%s`", errorDuplicateProtocolName, name)
+			}
+		}
+		if existingID, exists := IPProtocolIDs[strings.ToLower(name)]; exists && existingID != int(id) {
+			if _, overridden := overrides[uint8(existingID)]; !overridden {
+				return fmt.Errorf("%w: `%s`", errorDuplicateProtocolName, name)
+			}
+		}
+	}
+
+	for id, name := range overrides {
+		IPProtocols[int(id)] = name
+		IPProtocolIDs[strings.ToLower(name)] = int(id)
+	}
+	return nil
+}
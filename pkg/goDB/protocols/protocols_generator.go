@@ -1,23 +1,24 @@
 //go:build ignore
 // +build ignore
 
-// The make_protocols program is run by go generate to compile a OS specific
-// list of IP protocols for forward and reverse lookup
+// The make_protocols program is run by go generate to compile the list of IP protocols
+// assigned by IANA for forward and reverse lookup
 package main
 
 import (
-	"bufio"
 	"bytes"
+	"encoding/csv"
 	"fmt"
-	"go/format"
+	"io"
+	"net/http"
 	"os"
-	"runtime"
 	"strconv"
 	"strings"
 )
 
 const (
-	protocolsFile = "/etc/protocols"
+	protocolsURL = "https://www.iana.org/assignments/protocol-numbers/protocol-numbers-1.csv"
+	outputFile   = "iana_protocols.csv"
 )
 
 func main() {
@@ -29,44 +30,54 @@ func main() {
 	}
 
 	if err := generateOutput(protocols); err != nil {
-		fmt.Printf("failed to generate code: %s\n", err)
+		fmt.Printf("failed to generate protocol table: %s\n", err)
 		os.Exit(1)
 	}
 }
 
-// readProtocols loads contents of /etc/protocols into protocols map
-// for quick access.
+// readProtocols downloads the IANA protocol number registry and extracts the keyword
+// of each assigned protocol number
 func readProtocols() (protoList []string, err error) {
-	file, err := os.Open(protocolsFile)
+	resp, err := http.Get(protocolsURL)
 	if err != nil {
 		return nil, err
 	}
 	defer func() {
-		if cerr := file.Close(); err == nil && cerr != nil {
+		if cerr := resp.Body.Close(); err == nil && cerr != nil {
 			err = cerr
 		}
 	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
 
 	protoList = make([]string, 256)
-	fileScanner := bufio.NewScanner(file)
-	for fileScanner.Scan() {
-
-		line := fileScanner.Text()
-		if strings.HasPrefix(line, "#") {
-			continue
+	reader := csv.NewReader(resp.Body)
+	reader.FieldsPerRecord = -1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
 		}
-
-		fields := strings.Fields(line)
-		if len(fields) < 3 {
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 2 {
 			continue
 		}
 
-		protoID, err := strconv.ParseInt(fields[1], 10, 64)
+		// skips the header as well as ranges of unassigned protocol numbers
+		protoID, err := strconv.ParseUint(record[0], 10, 8)
 		if err != nil {
 			continue
 		}
 
-		protoList[protoID] = fields[2]
+		// some keywords carry annotations (e.g. "(deprecated)")
+		keyword := strings.Fields(record[1])
+		if len(keyword) == 0 {
+			continue
+		}
+		protoList[protoID] = keyword[0]
 	}
 
 	protoList[255] = "UNKNOWN"
@@ -77,42 +88,22 @@ func readProtocols() (protoList []string, err error) {
 func generateOutput(protoList []string) error {
 
 	buffer := bytes.NewBuffer(nil)
+	writer := csv.NewWriter(buffer)
 
-	fmt.Fprintf(buffer, `//go:build %s
-// +build %s
-
-// Code generated by protocols_generator.go - DO NOT EDIT.
-`, runtime.GOOS, runtime.GOOS)
-
-	fmt.Fprintln(buffer, `package protocols
-
-// IPProtocols stores the IP protocol mappings to friendly name
-var IPProtocols = map[int]string{`)
-
-	for protoID, protoName := range protoList {
-		if protoName != "" {
-			fmt.Fprintf(buffer, "\t%d:\t\t\"%s\",\n", protoID, protoName)
-		}
+	if err := writer.Write([]string{"Decimal", "Keyword"}); err != nil {
+		return err
 	}
-
-	fmt.Fprintln(buffer, `}
-
-// IPProtocolIDs is the reverse mapping from friendly name to protocol number
-var IPProtocolIDs = map[string]int{`)
-
 	for protoID, protoName := range protoList {
 		if protoName != "" {
-			fmt.Fprintf(buffer, "\t\"%s\":\t\t%d,\n", strings.ToLower(protoName), protoID)
+			if err := writer.Write([]string{strconv.Itoa(protoID), protoName}); err != nil {
+				return err
+			}
 		}
 	}
-
-	fmt.Fprintln(buffer, `}`)
-
-	// Format the code
-	fmtContent, err := format.Source(buffer.Bytes())
-	if err != nil {
+	writer.Flush()
+	if err := writer.Error(); err != nil {
 		return err
 	}
 
-	return os.WriteFile("protocols_"+runtime.GOOS+".go", fmtContent, 0600)
+	return os.WriteFile(outputFile, buffer.Bytes(), 0600)
 }
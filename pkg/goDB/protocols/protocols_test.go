@@ -0,0 +1,60 @@
+package protocols
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIANAProtocols(t *testing.T) {
+	require.Equal(t, "TCP", GetIPProto(6))
+	require.Equal(t, "IPv6-ICMP", GetIPProto(58))
+	require.Equal(t, "UNKNOWN", GetIPProto(255))
+
+	id, exists := GetIPProtoID("udp")
+	require.True(t, exists)
+	require.Equal(t, uint64(17), id)
+
+	for name, id := range IPProtocolIDs {
+		require.Equal(t, name, strings.ToLower(IPProtocols[id]))
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	defer restore()()
+
+	// a missing overrides file is not an error
+	require.Nil(t, LoadOverrides(filepath.Join(t.TempDir(), "protocols.json")))
+
+	path := filepath.Join(t.TempDir(), "protocols.json")
+	require.Nil(t, os.WriteFile(path, []byte(`{"253": "EXP1", "6": "tcp-site"}`), 0600))
+	require.Nil(t, LoadOverrides(path))
+
+	require.Equal(t, "EXP1", GetIPProto(253))
+	require.Equal(t, "tcp-site", GetIPProto(6))
+	for name, expected := range map[string]uint64{"exp1": 253, "tcp-site": 6, "tcp": 6} {
+		id, exists := GetIPProtoID(name)
+		require.True(t, exists, name)
+		require.Equal(t, expected, id, name)
+	}
+
+	require.Nil(t, os.WriteFile(path, []byte(`{"256": "EXP1"}`), 0600))
+	require.NotNil(t, LoadOverrides(path))
+
+	require.ErrorIs(t, Override(map[uint8]string{253: ""}), errorEmptyProtocolName)
+	require.ErrorIs(t, Override(map[uint8]string{253: "EXP 1"}), errorInvalidProtocolName)
+	require.ErrorIs(t, Override(map[uint8]string{253: "UDP"}), errorDuplicateProtocolName)
+	require.ErrorIs(t, Override(map[uint8]string{253: "EXP", 254: "exp"}), errorDuplicateProtocolName)
+}
+
+// restore resets the protocol tables once a test is done
+func restore() func() {
+	protocols, protocolIDs := maps.Clone(IPProtocols), maps.Clone(IPProtocolIDs)
+	return func() {
+		IPProtocols, IPProtocolIDs = protocols, protocolIDs
+	}
+}
@@ -0,0 +1,110 @@
+package goDB
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/goDB/info"
+)
+
+var (
+	// ErrSnapshotExists denotes that the destination of a snapshot already exists
+	ErrSnapshotExists = errors.New("snapshot already exists")
+
+	// ErrSnapshotInDB denotes that the destination of a snapshot is located within the DB itself
+	ErrSnapshotInDB = errors.New("snapshot must not be located within the database")
+)
+
+// SnapshotInfo summarizes a snapshot of the DB
+type SnapshotInfo struct {
+	Path      string    `json:"path"`       // Path: location of the snapshot. Example: "/usr/local/goprobe/db.snapshots/20240101T000000Z"
+	NumFiles  int       `json:"num_files"`  // NumFiles: number of files in the snapshot. Example: 1024
+	CreatedAt time.Time `json:"created_at"` // CreatedAt: time the snapshot was created. Example: "2024-01-01T00:00:00Z"
+}
+
+// DefaultSnapshotPath returns the directory snapshots of the DB at dbPath are created in by default
+// (next to the DB, so that both reside on the same file system)
+func DefaultSnapshotPath(dbPath string) string {
+	return filepath.Clean(dbPath) + ".snapshots"
+}
+
+// Snapshot creates a consistent view of the DB at dest by hard-linking all of its files, which
+// requires dest to reside on the same file system as the DB. Since blocks are only ever appended
+// and metadata files are replaced atomically, the snapshot is not affected by subsequent writes
+// to the DB. However, no writeout must be in progress while it is taken
+func Snapshot(dbPath, dest string) (SnapshotInfo, error) {
+	snapshot := SnapshotInfo{
+		Path:      filepath.Clean(dest),
+		CreatedAt: time.Now(),
+	}
+
+	if err := info.CheckDBExists(dbPath); err != nil {
+		return snapshot, err
+	}
+	dbInfo, err := os.Stat(dbPath)
+	if err != nil {
+		return snapshot, err
+	}
+	dbPath = filepath.Clean(dbPath)
+	if rel, err := filepath.Rel(dbPath, snapshot.Path); err == nil && !strings.HasPrefix(rel, "..") {
+		return snapshot, fmt.Errorf("%w: %s", ErrSnapshotInDB, snapshot.Path)
+	}
+
+	// the snapshot directories inherit the permissions of the DB
+	if err := os.MkdirAll(filepath.Dir(snapshot.Path), dbInfo.Mode().Perm()); err != nil {
+		return snapshot, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	if err := os.Mkdir(snapshot.Path, dbInfo.Mode().Perm()); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return snapshot, fmt.Errorf("%w: %s", ErrSnapshotExists, snapshot.Path)
+		}
+		return snapshot, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	err = filepath.WalkDir(dbPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dbPath, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		// skip any temporary files of writes in progress (e.g. of the block metadata) and the locks
+		// of the interface subtrees (which only concern the running goProbe instances)
+		if strings.HasPrefix(d.Name(), ".tmp") || strings.HasSuffix(d.Name(), ".tmp") ||
+			(!d.IsDir() && strings.HasSuffix(d.Name(), LockFileSuffix)) {
+			return nil
+		}
+
+		target := filepath.Join(snapshot.Path, rel)
+		if d.IsDir() {
+			fileInfo, err := d.Info()
+			if err != nil {
+				return err
+			}
+			return os.Mkdir(target, fileInfo.Mode().Perm())
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if err := os.Link(path, target); err != nil {
+			return err
+		}
+		snapshot.NumFiles++
+		return nil
+	})
+	if err != nil {
+		if rerr := os.RemoveAll(snapshot.Path); rerr != nil {
+			err = errors.Join(err, rerr)
+		}
+		return snapshot, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	return snapshot, nil
+}
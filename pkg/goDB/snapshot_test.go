@@ -0,0 +1,85 @@
+package goDB
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/els0r/goProbe/pkg/goDB/storage/gpfile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "db")
+
+	ts := gpfile.DirTimestamp(time.Now().Unix()) + DBWriteInterval
+	w := NewDBWriter(dbPath, "eth0", encoders.EncoderTypeNull)
+	require.Nil(t, w.Write(generateFlows(), capturetypes.CaptureStats{}, ts))
+
+	// leftovers of an interrupted metadata write are not part of the snapshot
+	usages, err := DiskUsage(dbPath)
+	require.Nil(t, err)
+	require.Len(t, usages, 1)
+	require.Nil(t, os.WriteFile(filepath.Join(usages[0].Path, ".tmp-metadata-123"), nil, 0600))
+
+	dest := filepath.Join(DefaultSnapshotPath(dbPath), "snap")
+	snapshot, err := Snapshot(dbPath, dest)
+	require.Nil(t, err)
+	require.Equal(t, dest, snapshot.Path)
+	require.Equal(t, usages[0].NumFiles, snapshot.NumFiles)
+
+	// subsequent writes to the DB don't affect the snapshot
+	require.Nil(t, w.Write(generateFlows(), capturetypes.CaptureStats{}, ts+DBWriteInterval))
+	for path, nBlocks := range map[string]int{dbPath: 2, dest: 1} {
+		dir := gpfile.NewDir(filepath.Join(path, "eth0"), ts, gpfile.ModeRead)
+		require.Nil(t, dir.Open())
+		require.Equal(t, nBlocks, dir.NBlocks(), path)
+		require.Nil(t, dir.Close())
+	}
+
+	_, err = Snapshot(dbPath, dest)
+	require.ErrorIs(t, err, ErrSnapshotExists)
+	_, err = Snapshot(dbPath, filepath.Join(dbPath, "snap"))
+	require.ErrorIs(t, err, ErrSnapshotInDB)
+}
+
+func TestLockWriteout(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "db")
+
+	// the lock file is created with the permissions of the DB
+	shared, err := LockWriteout(dbPath, false, WithLockPermissions(0640, 0750))
+	require.Nil(t, err)
+	for path, perm := range map[string]fs.FileMode{dbPath: 0750, filepath.Join(dbPath, WriteoutLockFile): 0640} {
+		stat, err := os.Stat(path)
+		require.Nil(t, err)
+		require.Equal(t, perm, stat.Mode().Perm(), path)
+	}
+	if runtime.GOOS != "linux" {
+		require.Nil(t, shared.Unlock())
+		return
+	}
+
+	// writeouts share the lock, whereas a snapshot waits for all of them to complete
+	other, err := LockWriteout(dbPath, false)
+	require.Nil(t, err)
+	acquired := make(chan *WriteoutLock)
+	go func() {
+		exclusive, err := LockWriteout(dbPath, true)
+		require.Nil(t, err)
+		acquired <- exclusive
+	}()
+
+	require.Nil(t, shared.Unlock())
+	select {
+	case <-acquired:
+		t.Fatal("exclusive lock acquired while shared lock is held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	require.Nil(t, other.Unlock())
+	require.Nil(t, (<-acquired).Unlock())
+}
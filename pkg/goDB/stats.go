@@ -0,0 +1,319 @@
+package goDB
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/goDB/protocols"
+	"github.com/els0r/goProbe/pkg/goDB/storage/gpfile"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/goProbe/pkg/types/hll"
+	"github.com/fako1024/gotools/bitpack"
+)
+
+// ColumnStats denotes the column-level statistics of the data of an interface. Flow counts and
+// block sizes are determined from the block headers (i.e. they cover all blocks), while the
+// attribute cardinalities and protocol breakdown are estimated from a sample of the blocks
+type ColumnStats struct {
+	Iface  string `json:"iface"`            // Iface: interface the statistics were computed for. Example: "eth0"
+	Tenant string `json:"tenant,omitempty"` // Tenant: tenant the interface belongs to (if any). Example: "acme"
+	First  int64  `json:"first"`            // First: timestamp of the first block covered (UNIX timestamp). Example: 1672531500
+	Last   int64  `json:"last"`             // Last: timestamp of the last block covered (UNIX timestamp). Example: 1673136000
+
+	NumDays          int `json:"num_days"`           // NumDays: number of day directories covered. Example: 7
+	NumBlocks        int `json:"num_blocks"`         // NumBlocks: number of blocks covered. Example: 2016
+	NumBlocksSampled int `json:"num_blocks_sampled"` // NumBlocksSampled: number of blocks read to estimate cardinalities. Example: 202
+
+	Traffic gpfile.TrafficMetadata `json:"traffic"` // Traffic: number of flows (and drops) across all blocks covered
+
+	Distinct  Cardinalities `json:"distinct"`  // Distinct: estimated number of distinct values per attribute (in the sampled blocks)
+	Protocols []ProtoStats  `json:"protocols"` // Protocols: flows / bytes per IP protocol (in the sampled blocks), ordered by number of flows
+	Columns   []ColumnSize  `json:"columns"`   // Columns: average block size per column
+}
+
+// Cardinalities denotes the (estimated) number of distinct values of each attribute
+type Cardinalities struct {
+	SIP   uint64 `json:"sip"`   // SIP: distinct source IPs. Example: 1024
+	DIP   uint64 `json:"dip"`   // DIP: distinct destination IPs. Example: 4096
+	Dport uint64 `json:"dport"` // Dport: distinct destination ports. Example: 312
+	Proto uint64 `json:"proto"` // Proto: distinct IP protocols. Example: 4
+}
+
+// ProtoStats denotes the number of flows and bytes of an IP protocol
+type ProtoStats struct {
+	Proto string `json:"proto"` // Proto: name of the IP protocol. Example: "TCP"
+	Flows uint64 `json:"flows"` // Flows: number of flows. Example: 10234
+	Bytes uint64 `json:"bytes"` // Bytes: number of bytes (received and sent). Example: 1048576
+}
+
+// ColumnSize denotes the average size of the blocks of a column (on disk and decompressed)
+type ColumnSize struct {
+	Column     string  `json:"column"`       // Column: name of the column. Example: "sip"
+	AvgSize    float64 `json:"avg_size"`     // AvgSize: average size of a block on disk (in bytes). Example: 2048.5
+	AvgRawSize float64 `json:"avg_raw_size"` // AvgRawSize: average decompressed size of a block (in bytes). Example: 8192
+}
+
+// AvgFlowsPerBlock returns the average number of flows stored in a block
+func (s *ColumnStats) AvgFlowsPerBlock() float64 {
+	if s.NumBlocks == 0 {
+		return 0
+	}
+	return float64(s.Traffic.NumFlows()) / float64(s.NumBlocks)
+}
+
+// columnStatsCollector accumulates the statistics of an interface while walking its day directories
+type columnStatsCollector struct {
+	stats *ColumnStats
+
+	sips, dips, dports *hll.Sketch
+	protos             map[byte]*ProtoStats
+	blockSizes         [types.ColIdxCount]uint64
+	rawBlockSizes      [types.ColIdxCount]uint64
+
+	// blocks are sampled across directory boundaries, i.e. every sampleEvery-th block is read
+	sampleEvery int
+	numSeen     int
+}
+
+// ComputeColumnStats walks the DB tree and computes the column-level statistics of all blocks not
+// older than since (UNIX timestamp, 0 covers all data) per interface. Every sampleEvery-th block is
+// read in order to estimate the attribute cardinalities, all others only contribute their header
+// information. If no interfaces are provided, all interfaces found in the DB (including the subtrees of
+// all tenants) are covered
+func ComputeColumnStats(dbPath string, since int64, sampleEvery int, ifaces ...string) ([]*ColumnStats, error) {
+	if sampleEvery < 1 {
+		sampleEvery = 1
+	}
+
+	var (
+		allStats  []*ColumnStats
+		collector *columnStatsCollector
+	)
+	err := walkDayDirs(dbPath, ifaces, func(usage DayUsage) error {
+		if usage.Timestamp+gpfile.EpochDay <= since {
+			return nil
+		}
+		if collector == nil || collector.stats.Iface != usage.Iface || collector.stats.Tenant != usage.Tenant {
+			if collector != nil {
+				allStats = append(allStats, collector.finalize())
+			}
+			collector = newColumnStatsCollector(usage.Iface, usage.Tenant, sampleEvery)
+		}
+		return collector.addDir(filepath.Join(info.TenantPath(dbPath, usage.Tenant), usage.Iface), usage.Timestamp, since)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if collector != nil {
+		allStats = append(allStats, collector.finalize())
+	}
+	return allStats, nil
+}
+
+func newColumnStatsCollector(iface, tenant string, sampleEvery int) *columnStatsCollector {
+	return &columnStatsCollector{
+		stats:       &ColumnStats{Iface: iface, Tenant: tenant},
+		sips:        hll.New(),
+		dips:        hll.New(),
+		dports:      hll.New(),
+		protos:      make(map[byte]*ProtoStats),
+		sampleEvery: sampleEvery,
+	}
+}
+
+func (c *columnStatsCollector) addDir(ifaceDir string, timestamp, since int64) (err error) {
+	dir := gpfile.NewDir(ifaceDir, timestamp, gpfile.ModeRead)
+	if err := dir.Open(); err != nil {
+		return fmt.Errorf("failed to open GPDir %s: %w", dir.Path(), err)
+	}
+	defer func() {
+		if cerr := dir.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	dirCovered := false
+	for b, block := range dir.BlockMetadata[0].Blocks() {
+		if block.Timestamp < since {
+			continue
+		}
+		dirCovered = true
+
+		if c.stats.First == 0 || block.Timestamp < c.stats.First {
+			c.stats.First = block.Timestamp
+		}
+		if block.Timestamp > c.stats.Last {
+			c.stats.Last = block.Timestamp
+		}
+		c.stats.NumBlocks++
+		c.stats.Traffic = c.stats.Traffic.Add(dir.BlockTraffic[b])
+		for colIdx := types.ColumnIndex(0); colIdx < types.ColIdxCount; colIdx++ {
+			colBlock := dir.BlockMetadata[colIdx].BlockList[b].Block
+			c.blockSizes[colIdx] += uint64(colBlock.Len)
+			c.rawBlockSizes[colIdx] += uint64(colBlock.RawLen)
+		}
+
+		c.numSeen++
+		if (c.numSeen-1)%c.sampleEvery == 0 {
+			c.sampleBlock(dir, b)
+		}
+	}
+	if dirCovered {
+		c.stats.NumDays++
+	}
+	return nil
+}
+
+var sampledColumns = []types.ColumnIndex{
+	types.SIPColIdx, types.DIPColIdx, types.DportColIdx, types.ProtoColIdx,
+	types.BytesRcvdColIdx, types.BytesSentColIdx,
+}
+
+// sampleBlock reads the attribute columns of a block and adds its values to the sketches. Analogous
+// to queries, blocks which can't be read or whose columns are inconsistent with the header are skipped
+// (and not counted as sampled)
+func (c *columnStatsCollector) sampleBlock(dir *gpfile.GPDir, blockIdx int) {
+	var (
+		blocks [types.ColIdxCount][]byte
+		err    error
+	)
+	for _, colIdx := range sampledColumns {
+		if blocks[colIdx], err = dir.ReadBlockAtIndex(colIdx, blockIdx); err != nil {
+			return
+		}
+	}
+
+	numV4Entries := int(dir.NumIPv4EntriesAtIndex(blockIdx))
+	numEntries := numV4Entries + int(dir.NumIPv6EntriesAtIndex(blockIdx))
+	ipLen := numV4Entries*types.IPv4Width + (numEntries-numV4Entries)*types.IPv6Width
+	if len(blocks[types.SIPColIdx]) != ipLen ||
+		len(blocks[types.DIPColIdx]) != ipLen ||
+		len(blocks[types.DportColIdx]) != numEntries*types.DportSizeof ||
+		len(blocks[types.ProtoColIdx]) != numEntries*types.ProtoSizeof ||
+		bitpack.Len(blocks[types.BytesRcvdColIdx]) != numEntries ||
+		bitpack.Len(blocks[types.BytesSentColIdx]) != numEntries {
+		return
+	}
+	c.stats.NumBlocksSampled++
+
+	bytesRcvd := bitpack.UnpackInto(blocks[types.BytesRcvdColIdx], nil)
+	bytesSent := bitpack.UnpackInto(blocks[types.BytesSentColIdx], nil)
+
+	offset, width := 0, types.IPv4Width
+	for i := 0; i < numEntries; i++ {
+		if i == numV4Entries {
+			width = types.IPv6Width
+		}
+		c.sips.Add(blocks[types.SIPColIdx][offset : offset+width])
+		c.dips.Add(blocks[types.DIPColIdx][offset : offset+width])
+		offset += width
+
+		c.dports.Add(blocks[types.DportColIdx][i*types.DportSizeof : (i+1)*types.DportSizeof])
+
+		proto := blocks[types.ProtoColIdx][i]
+		protoStats, exists := c.protos[proto]
+		if !exists {
+			protoStats = &ProtoStats{Proto: protocols.GetIPProto(int(proto))}
+			c.protos[proto] = protoStats
+		}
+		protoStats.Flows++
+		protoStats.Bytes += bytesRcvd[i] + bytesSent[i]
+	}
+}
+
+func (c *columnStatsCollector) finalize() *ColumnStats {
+	c.stats.Distinct = Cardinalities{
+		SIP:   c.sips.Count(),
+		DIP:   c.dips.Count(),
+		Dport: c.dports.Count(),
+		Proto: uint64(len(c.protos)),
+	}
+
+	c.stats.Protocols = make([]ProtoStats, 0, len(c.protos))
+	for _, protoStats := range c.protos {
+		c.stats.Protocols = append(c.stats.Protocols, *protoStats)
+	}
+	sort.Slice(c.stats.Protocols, func(i, j int) bool {
+		if c.stats.Protocols[i].Flows == c.stats.Protocols[j].Flows {
+			return c.stats.Protocols[i].Proto < c.stats.Protocols[j].Proto
+		}
+		return c.stats.Protocols[i].Flows > c.stats.Protocols[j].Flows
+	})
+
+	c.stats.Columns = make([]ColumnSize, 0, types.ColIdxCount)
+	for colIdx := types.ColumnIndex(0); colIdx < types.ColIdxCount; colIdx++ {
+		size := ColumnSize{Column: types.ColumnFileNames[colIdx]}
+		if c.stats.NumBlocks > 0 {
+			size.AvgSize = float64(c.blockSizes[colIdx]) / float64(c.stats.NumBlocks)
+			size.AvgRawSize = float64(c.rawBlockSizes[colIdx]) / float64(c.stats.NumBlocks)
+		}
+		c.stats.Columns = append(c.stats.Columns, size)
+	}
+	return c.stats
+}
+
+// IfaceTraffic sums the counters of all blocks of an interface with a timestamp within [tfirst, tlast]
+// and returns them along with the number of blocks covered and the timestamp of the latest one. Only the counter columns are read, blocks
+// whose counter columns are inconsistent with the header are skipped (analogous to queries)
+func IfaceTraffic(dbPath, iface string, tfirst, tlast int64) (counters types.Counters, numBlocks int, lastBlock int64, err error) {
+	ifaceDir := filepath.Join(dbPath, iface)
+	for day := gpfile.DirTimestamp(tfirst); day <= tlast; day += gpfile.EpochDay {
+		dir := gpfile.NewDir(ifaceDir, day, gpfile.ModeRead)
+		if err := dir.Open(); err != nil {
+			if errors.Is(err, gpfile.ErrMetadataMissing) {
+				continue
+			}
+			return counters, numBlocks, lastBlock, fmt.Errorf("failed to open GPDir %s: %w", dir.Path(), err)
+		}
+
+		dirCounters, dirBlocks, dirLastBlock := dirTraffic(dir, tfirst, tlast)
+		counters = counters.Add(dirCounters)
+		numBlocks += dirBlocks
+		lastBlock = max(lastBlock, dirLastBlock)
+
+		if err := dir.Close(); err != nil {
+			return counters, numBlocks, lastBlock, fmt.Errorf("failed to close GPDir %s: %w", dir.Path(), err)
+		}
+	}
+	return counters, numBlocks, lastBlock, nil
+}
+
+func dirTraffic(dir *gpfile.GPDir, tfirst, tlast int64) (counters types.Counters, numBlocks int, lastBlock int64) {
+blocks:
+	for b, block := range dir.BlockMetadata[0].Blocks() {
+		if block.Timestamp < tfirst || block.Timestamp > tlast {
+			continue
+		}
+
+		var sums [types.ColIdxCount]uint64
+		numV4Entries, numV6Entries := int(dir.NumIPv4EntriesAtIndex(b)), int(dir.NumIPv6EntriesAtIndex(b))
+		for colIdx := types.ColIdxAttributeCount; colIdx.IsCounterCol(); colIdx++ {
+			colBlock, err := dir.ReadBlockAtIndex(colIdx, b)
+			if err != nil {
+				continue blocks
+			}
+			found, consistent := columnEntries(colIdx, colBlock, numV4Entries, numV6Entries)
+			if !consistent {
+				continue blocks
+			}
+			if found == 0 {
+				continue
+			}
+			for _, v := range bitpack.UnpackInto(colBlock, nil) {
+				sums[colIdx] += v
+			}
+		}
+		counters = counters.Add(types.Counters{
+			BytesRcvd:   sums[types.BytesRcvdColIdx],
+			BytesSent:   sums[types.BytesSentColIdx],
+			PacketsRcvd: sums[types.PacketsRcvdColIdx],
+			PacketsSent: sums[types.PacketsSentColIdx],
+		})
+		numBlocks++
+		lastBlock = max(lastBlock, block.Timestamp)
+	}
+	return counters, numBlocks, lastBlock
+}
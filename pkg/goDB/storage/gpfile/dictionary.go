@@ -0,0 +1,175 @@
+package gpfile
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+
+	// DictionaryIDWidth denotes the width of a dictionary ID as stored in a block (big endian)
+	DictionaryIDWidth = 4
+
+	dictionaryFileSuffix = ".dict"
+	dictionaryVersion    = byte(1)
+)
+
+var (
+	// ErrUnknownDictionaryID denotes that a block references a value missing from its dictionary
+	ErrUnknownDictionaryID = errors.New("unknown dictionary ID")
+
+	// ErrInvalidDictionaryIDs denotes that the size of a list of encoded IDs is invalid
+	ErrInvalidDictionaryIDs = errors.New("invalid size of dictionary IDs")
+)
+
+// Dictionary stores the (string) values of an attribute once per GPDir, allowing blocks to reference
+// them by ID. IDs are assigned in order of insertion and the underlying file is append-only, so that
+// existing IDs remain valid (also for snapshots of the DB)
+type Dictionary struct {
+	path string
+
+	ids          map[string]uint32
+	values       []string
+	numPersisted int // number of values already persisted to disk
+	persistedLen int64
+}
+
+// dictionaryPath returns the path of the dictionary of the attribute within the GPDir
+func dictionaryPath(dirPath, name string) string {
+	return filepath.Join(dirPath, name+dictionaryFileSuffix)
+}
+
+// loadDictionary reads the dictionary at path (if it exists). A truncated trailing value (e.g.
+// after an interrupted write) is ignored
+func loadDictionary(path string) (*Dictionary, error) {
+	d := &Dictionary{
+		path: path,
+		ids:  make(map[string]uint32),
+	}
+
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return d, nil
+		}
+		return nil, fmt.Errorf("failed to read dictionary `%s`: %w", path, err)
+	}
+	if len(data) == 0 {
+		return d, nil
+	}
+	if data[0] != dictionaryVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d of dictionary `%s`", ErrCorruptBlock, data[0], path)
+	}
+
+	pos := 1
+	for pos < len(data) {
+		size, n := binary.Uvarint(data[pos:])
+		if n <= 0 || uint64(len(data)-pos-n) < size {
+			break
+		}
+		d.add(string(data[pos+n : pos+n+int(size)]))
+		pos += n + int(size)
+	}
+	d.numPersisted, d.persistedLen = len(d.values), int64(pos)
+
+	return d, nil
+}
+
+// ID returns the ID of value, adding it to the dictionary if required
+func (d *Dictionary) ID(value string) uint32 {
+	if id, exists := d.ids[value]; exists {
+		return id
+	}
+	return d.add(value)
+}
+
+// Lookup returns the value with the given ID
+func (d *Dictionary) Lookup(id uint32) (string, bool) {
+	if int(id) >= len(d.values) {
+		return "", false
+	}
+	return d.values[id], true
+}
+
+// Len returns the number of values in the dictionary
+func (d *Dictionary) Len() int {
+	return len(d.values)
+}
+
+// Encode returns the IDs of all values (adding them to the dictionary if required), serialized for
+// storage in a block
+func (d *Dictionary) Encode(values []string) []byte {
+	data := make([]byte, 0, len(values)*DictionaryIDWidth)
+	for _, value := range values {
+		data = binary.BigEndian.AppendUint32(data, d.ID(value))
+	}
+	return data
+}
+
+// Resolve returns the values referenced by the serialized IDs of a block (see Encode())
+func (d *Dictionary) Resolve(data []byte) ([]string, error) {
+	if len(data)%DictionaryIDWidth != 0 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidDictionaryIDs, len(data))
+	}
+
+	values := make([]string, 0, len(data)/DictionaryIDWidth)
+	for i := 0; i < len(data); i += DictionaryIDWidth {
+		id := binary.BigEndian.Uint32(data[i : i+DictionaryIDWidth])
+		value, exists := d.Lookup(id)
+		if !exists {
+			return nil, fmt.Errorf("%w: %d in `%s`", ErrUnknownDictionaryID, id, d.path)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func (d *Dictionary) add(value string) uint32 {
+	id := uint32(len(d.values))
+	d.ids[value] = id
+	d.values = append(d.values, value)
+	return id
+}
+
+// persist appends all values added since the last call to the underlying file (discarding any
+// truncated trailing value written previously)
+func (d *Dictionary) persist(permissions fs.FileMode, owner owner) (err error) {
+	if d.numPersisted == len(d.values) {
+		return nil
+	}
+
+	file, err := os.OpenFile(d.path, os.O_CREATE|os.O_WRONLY, permissions)
+	if err != nil {
+		return fmt.Errorf("failed to open dictionary `%s`: %w", d.path, err)
+	}
+	defer func() {
+		if cerr := file.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	var data []byte
+	if d.persistedLen == 0 {
+		data = append(data, dictionaryVersion)
+		if err = owner.chown(d.path); err != nil {
+			return fmt.Errorf("failed to set owner of dictionary `%s`: %w", d.path, err)
+		}
+	}
+	for _, value := range d.values[d.numPersisted:] {
+		data = binary.AppendUvarint(data, uint64(len(value)))
+		data = append(data, value...)
+	}
+	if _, err = file.WriteAt(data, d.persistedLen); err != nil {
+		return fmt.Errorf("failed to write dictionary `%s`: %w", d.path, err)
+	}
+	if err = file.Truncate(d.persistedLen + int64(len(data))); err != nil {
+		return fmt.Errorf("failed to write dictionary `%s`: %w", d.path, err)
+	}
+
+	d.numPersisted, d.persistedLen = len(d.values), d.persistedLen+int64(len(data))
+	return nil
+}
@@ -0,0 +1,62 @@
+package gpfile
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDictionary(t *testing.T) {
+	basePath := t.TempDir()
+
+	writeValues := func(values ...string) []byte {
+		dir := NewDir(basePath, 1000, ModeWrite)
+		require.Nil(t, dir.Open())
+		dict, err := dir.Dictionary("sni")
+		require.Nil(t, err)
+		ids := dict.Encode(values)
+		require.Nil(t, dir.Close())
+		return ids
+	}
+
+	ids := writeValues("example.com", "example.org", "example.com")
+	require.Len(t, ids, 3*DictionaryIDWidth)
+	require.Equal(t, ids[:DictionaryIDWidth], ids[2*DictionaryIDWidth:])
+
+	// a truncated trailing value (e.g. of an interrupted write) is discarded upon the next write
+	path := dictionaryPath(NewDir(basePath, 1000, ModeRead).Path(), "sni")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.Nil(t, err)
+	_, err = f.Write([]byte{20, 'e', 'x'})
+	require.Nil(t, err)
+	require.Nil(t, f.Close())
+
+	// values are stored once, existing IDs remain valid
+	moreIDs := writeValues("example.org", "example.net")
+	require.Equal(t, ids[DictionaryIDWidth:2*DictionaryIDWidth], moreIDs[:DictionaryIDWidth])
+
+	dir := NewDir(basePath, 1000, ModeRead)
+	require.Nil(t, dir.Open())
+	dict, err := dir.Dictionary("sni")
+	require.Nil(t, err)
+	require.Equal(t, 3, dict.Len())
+
+	values, err := dict.Resolve(append(ids, moreIDs...))
+	require.Nil(t, err)
+	require.Equal(t, []string{"example.com", "example.org", "example.com", "example.org", "example.net"}, values)
+
+	_, err = dict.Resolve([]byte{0, 0, 0, 3})
+	require.ErrorIs(t, err, ErrUnknownDictionaryID)
+	_, err = dict.Resolve([]byte{0, 0})
+	require.ErrorIs(t, err, ErrInvalidDictionaryIDs)
+	require.Nil(t, dir.Close())
+
+	// dictionaries of other attributes are independent
+	dir = NewDir(basePath, 1000, ModeRead)
+	require.Nil(t, dir.Open())
+	dict, err = dir.Dictionary("app")
+	require.Nil(t, err)
+	require.Zero(t, dict.Len())
+	require.Nil(t, dir.Close())
+}
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
@@ -24,6 +25,18 @@ const (
 
 	metadataFileName = ".blockmeta"
 	maxUint32        = 1<<32 - 1 // 4294967295
+
+	// blockRangeSize denotes the serialized size of the attribute value ranges of a block
+	// (flag, proto, dport, IPv4 & IPv6 source / destination IPs)
+	blockRangeSize = 1 + 2*types.ProtoWidth + 2*types.DPortWidth + 4*types.IPv4Width + 4*types.IPv6Width
+
+	// captureQualitySize denotes the serialized size of the capture quality indicators of a block
+	// (received packets, overruns, truncated packets)
+	captureQualitySize = 3 * 4
+
+	// linkDownFlag flags a block written while the link of the interface was down (the remaining
+	// bits of the per-block flags are reserved)
+	linkDownFlag = 1 << 0
 )
 
 var (
@@ -40,6 +53,9 @@ var (
 
 	// ErrDirNotOpen denotes that a GPDir is not (yet) open or has been closed
 	ErrDirNotOpen = errors.New("GPDir not open, call Open() first")
+
+	// ErrMetadataMissing denotes that the metadata of a GPDir is not available
+	ErrMetadataMissing = errors.New("metadata file missing")
 )
 
 // TrafficMetadata denotes a serializable set of metadata information about traffic stats
@@ -47,6 +63,15 @@ type TrafficMetadata struct {
 	NumV4Entries uint64 `json:"num_v4_entries"`
 	NumV6Entries uint64 `json:"num_v6_entries"`
 	NumDrops     uint64 `json:"num_drops"`
+
+	// capture quality indicators (zero for blocks written prior to their introduction)
+	NumPackets   uint64 `json:"num_packets,omitempty"`   // packets received by the capture (including dropped ones)
+	NumOverruns  uint64 `json:"num_overruns,omitempty"`  // ring buffer overruns (queue freezes)
+	NumTruncated uint64 `json:"num_truncated,omitempty"` // packets too short to be parsed
+
+	// NumLinkDown denotes the number of blocks written while the link of the interface was down
+	// (i.e. one for a single block), telling apart "link down" from "no traffic"
+	NumLinkDown uint64 `json:"num_link_down,omitempty"`
 }
 
 // Stats denotes statistics for a GPDir instance
@@ -60,11 +85,25 @@ func (t TrafficMetadata) NumFlows() uint64 {
 	return t.NumV4Entries + t.NumV6Entries
 }
 
+// DropRate returns the fraction of packets dropped by the capture. If the number of received packets
+// is unknown (e.g. for blocks written prior to the introduction of the capture quality indicators),
+// the drop rate is unknown as well (and false is returned)
+func (t TrafficMetadata) DropRate() (float64, bool) {
+	if t.NumPackets == 0 {
+		return 0, t.NumDrops == 0
+	}
+	return float64(t.NumDrops) / float64(max(t.NumPackets, t.NumDrops)), true
+}
+
 // Add computes the sum of two sets of TrafficMetadata
 func (t TrafficMetadata) Add(t2 TrafficMetadata) TrafficMetadata {
 	t.NumDrops += t2.NumDrops
 	t.NumV4Entries += t2.NumV4Entries
 	t.NumV6Entries += t2.NumV6Entries
+	t.NumPackets += t2.NumPackets
+	t.NumOverruns += t2.NumOverruns
+	t.NumTruncated += t2.NumTruncated
+	t.NumLinkDown += t2.NumLinkDown
 	return t
 }
 
@@ -73,6 +112,10 @@ func (t TrafficMetadata) Sub(t2 TrafficMetadata) TrafficMetadata {
 	t.NumDrops -= t2.NumDrops
 	t.NumV4Entries -= t2.NumV4Entries
 	t.NumV6Entries -= t2.NumV6Entries
+	t.NumPackets -= t2.NumPackets
+	t.NumOverruns -= t2.NumOverruns
+	t.NumTruncated -= t2.NumTruncated
+	t.NumLinkDown -= t2.NumLinkDown
 	return t
 }
 
@@ -94,6 +137,8 @@ func (s Stats) Sub(s2 Stats) Stats {
 type Metadata struct {
 	BlockMetadata [types.ColIdxCount]*storage.BlockHeader
 	BlockTraffic  []TrafficMetadata
+	BlockRanges   []*types.BlockRange             // BlockRanges: attribute value ranges per block (nil if unknown)
+	LabelMetadata map[string]*storage.BlockHeader // LabelMetadata: block information of the label columns (by name)
 
 	Stats
 	Version uint64
@@ -102,8 +147,10 @@ type Metadata struct {
 // newMetadata initializes a new Metadata set (internal / serialization use only)
 func newMetadata() *Metadata {
 	m := Metadata{
-		BlockTraffic: make([]TrafficMetadata, 0),
-		Version:      headerVersion,
+		BlockTraffic:  make([]TrafficMetadata, 0),
+		BlockRanges:   make([]*types.BlockRange, 0),
+		LabelMetadata: make(map[string]*storage.BlockHeader),
+		Version:       headerVersion,
 	}
 	for i := 0; i < int(types.ColIdxCount); i++ {
 		m.BlockMetadata[i] = &storage.BlockHeader{
@@ -116,14 +163,25 @@ func newMetadata() *Metadata {
 
 // GPDir denotes a timestamped goDB directory (usually a daily set of blocks)
 type GPDir struct {
-	gpFiles [types.ColIdxCount]*GPFile // Set of GPFile (lazy-load)
-
-	options     []Option    // Options (forwarded to all GPFiles)
-	basePath    string      // goDB base path (up to interface)
-	dirPath     string      // GPDir path (up to GPDir timestanp)
-	metaPath    string      // Full path to GPDir metadata
-	accessMode  int         // Access mode (also forwarded to all GPFiles)
-	permissions os.FileMode // Permissions (also forwarded to all GPFiles)
+	gpFiles      [types.ColIdxCount]*GPFile // Set of GPFile (lazy-load)
+	labelFiles   map[string]*GPFile         // GPFiles of the label columns, by name (lazy-load)
+	dictionaries map[string]*Dictionary     // Dictionaries of string attributes, by name (lazy-load)
+
+	options      []Option    // Options (forwarded to all GPFiles)
+	basePath     string      // goDB base path (up to interface)
+	dirPath      string      // GPDir path (up to GPDir timestanp)
+	dayTimestamp int64       // Timestamp of the day covered by the GPDir
+	metaPath     string      // Full path to GPDir metadata
+	accessMode   int         // Access mode (also forwarded to all GPFiles)
+	permissions  os.FileMode // Permissions (also forwarded to all GPFiles)
+	dirPerms     os.FileMode // Permissions of created directories (derived from permissions if unset)
+	owner        owner       // Owner of created files / directories (also forwarded to all GPFiles)
+
+	writeConcurrency int  // Maximum number of columns written in parallel
+	sharedEncoder    bool // All GPFiles use the same encoder (which prevents parallel writes)
+
+	preallocate   bool                      // Preallocate the column files based on the preceding days
+	preallocSizes [types.ColIdxCount]uint64 // Estimated sizes of the column files (if preallocated)
 
 	isOpen bool
 	*Metadata
@@ -135,12 +193,15 @@ func NewDir(basePath string, timestamp int64, accessMode int, options ...Option)
 		basePath:    filepath.Clean(strings.TrimSuffix(basePath, "/")),
 		accessMode:  accessMode,
 		permissions: defaultPermissions,
+		owner:       defaultOwner,
 		options:     options,
 	}
 
 	dayTimestamp := DirTimestamp(timestamp)
 	dayUnix := time.Unix(dayTimestamp, 0)
 
+	obj.dayTimestamp = dayTimestamp
+
 	obj.dirPath = filepath.Join(basePath, strconv.Itoa(dayUnix.Year()), fmt.Sprintf("%02d", dayUnix.Month()), strconv.FormatInt(dayTimestamp, 10))
 	obj.metaPath = filepath.Join(obj.dirPath, metadataFileName)
 	return &obj
@@ -172,7 +233,7 @@ func (d *GPDir) Open(options ...Option) error {
 			// In read mode the metadata file has to be present, otherwise we instantiate
 			// an empty one
 			if d.accessMode == ModeRead {
-				return fmt.Errorf("metadata file `%s` missing", d.MetadataPath())
+				return fmt.Errorf("%w: `%s`", ErrMetadataMissing, d.MetadataPath())
 			}
 			d.Metadata = newMetadata()
 		} else {
@@ -187,10 +248,15 @@ func (d *GPDir) Open(options ...Option) error {
 			}
 		}()
 		if err := d.Unmarshal(metadataFile); err != nil {
-			return fmt.Errorf("error decoding metadata file `%s`: %w", d.MetadataPath(), err)
+			return fmt.Errorf("%w: error decoding metadata file `%s`: %w", ErrCorruptBlock, d.MetadataPath(), err)
 		}
 	}
 
+	// Upon the first write to the GPDir, the space required by its column files is reserved
+	if d.accessMode == ModeWrite && d.preallocate && d.NBlocks() == 0 {
+		d.preallocSizes = d.preallocationSizes()
+	}
+
 	d.isOpen = true
 	return nil
 }
@@ -205,6 +271,21 @@ func (d *GPDir) NumIPv6EntriesAtIndex(blockIdx int) uint64 {
 	return d.BlockTraffic[blockIdx].NumV6Entries
 }
 
+// TrafficAtIndex returns the traffic metadata (including the capture quality indicators) for a given
+// block index
+func (d *GPDir) TrafficAtIndex(blockIdx int) TrafficMetadata {
+	return d.BlockTraffic[blockIdx]
+}
+
+// BlockRangeAtIndex returns the attribute value ranges for a given block index (or nil if they
+// are unknown, e.g. for blocks written prior to the introduction of block ranges)
+func (d *GPDir) BlockRangeAtIndex(blockIdx int) *types.BlockRange {
+	if blockIdx >= len(d.BlockRanges) {
+		return nil
+	}
+	return d.BlockRanges[blockIdx]
+}
+
 // ReadBlockAtIndex returns the block for a specified block index from the underlying GPFile
 func (d *GPDir) ReadBlockAtIndex(colIdx types.ColumnIndex, blockIdx int) ([]byte, error) {
 
@@ -224,21 +305,39 @@ func (d *GPDir) ReadBlockAtIndex(colIdx types.ColumnIndex, blockIdx int) ([]byte
 
 // WriteBlocks writes a set of blocks to the underlying GPFiles and updates the metadata
 func (d *GPDir) WriteBlocks(timestamp int64, blockTraffic TrafficMetadata, counters types.Counters, dbData [types.ColIdxCount][]byte) error {
-	for colIdx := types.ColumnIndex(0); colIdx < types.ColIdxCount; colIdx++ {
+	return d.WriteBlocksWithLabels(timestamp, blockTraffic, counters, dbData, nil)
+}
+
+// WriteBlocksWithLabels writes a set of blocks to the underlying GPFiles (see WriteBlocks()), along with
+// the labels of the flows (see writeLabelBlocks())
+func (d *GPDir) WriteBlocksWithLabels(timestamp int64, blockTraffic TrafficMetadata, counters types.Counters, dbData [types.ColIdxCount][]byte, labels map[string][]string) error {
+	if err := d.writeLabelBlocks(timestamp, int(blockTraffic.NumV4Entries+blockTraffic.NumV6Entries), labels); err != nil {
+		return err
+	}
+	if err := d.forEachColumn(func(colIdx types.ColumnIndex) error {
 
 		// Load column if required
-		_, err := d.Column(colIdx)
+		column, err := d.Column(colIdx)
 		if err != nil {
 			return err
 		}
 
 		// Write data to column file
-		if err := d.gpFiles[colIdx].writeBlock(timestamp, dbData[colIdx]); err != nil {
-			return err
-		}
+		return column.writeBlock(timestamp, dbData[colIdx])
+	}); err != nil {
+		return err
 	}
 
 	// Update global block info / counters
+	for len(d.Metadata.BlockRanges) < len(d.Metadata.BlockTraffic) {
+		d.Metadata.BlockRanges = append(d.Metadata.BlockRanges, nil)
+	}
+	if blockRange, ok := types.NewBlockRange(int(blockTraffic.NumV4Entries),
+		dbData[types.SIPColIdx], dbData[types.DIPColIdx], dbData[types.DportColIdx], dbData[types.ProtoColIdx]); ok {
+		d.Metadata.BlockRanges = append(d.Metadata.BlockRanges, &blockRange)
+	} else {
+		d.Metadata.BlockRanges = append(d.Metadata.BlockRanges, nil)
+	}
 	d.Metadata.BlockTraffic = append(d.Metadata.BlockTraffic, blockTraffic)
 	d.Metadata.Traffic = d.Metadata.Traffic.Add(blockTraffic)
 	d.Metadata.Counts = d.Metadata.Counts.Add(counters)
@@ -246,6 +345,54 @@ func (d *GPDir) WriteBlocks(timestamp int64, blockTraffic TrafficMetadata, count
 	return nil
 }
 
+// forEachColumn runs fn for all columns. Since each column is compressed and written to its own
+// GPFile, the columns are processed in parallel (using up to writeConcurrency workers) unless the
+// GPFiles share a single encoder. The errors of all failing columns are returned
+func (d *GPDir) forEachColumn(fn func(colIdx types.ColumnIndex) error) error {
+	if d.writeConcurrency <= 1 || d.sharedEncoder {
+		for colIdx := types.ColumnIndex(0); colIdx < types.ColIdxCount; colIdx++ {
+			if err := fn(colIdx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var (
+		errs [types.ColIdxCount]error
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, d.writeConcurrency)
+	)
+	for colIdx := types.ColumnIndex(0); colIdx < types.ColIdxCount; colIdx++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(colIdx types.ColumnIndex) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[colIdx] = fn(colIdx)
+		}(colIdx)
+	}
+	wg.Wait()
+
+	return errors.Join(errs[:]...)
+}
+
+// Prefetch asynchronously reads the files of the given columns into the page cache (e.g. while the
+// previous GPDir of a query is being processed), so that opening them later on doesn't block on disk
+// I/O. Prefetching is best-effort and doesn't require the GPDir to be open
+func (d *GPDir) Prefetch(colIndices []types.ColumnIndex) {
+	for _, colIdx := range colIndices {
+		f, err := os.Open(filepath.Join(d.dirPath, types.ColumnFileNames[colIdx]+FileSuffix))
+		if err != nil {
+			continue
+		}
+		_ = prefetch(f, 0, 0)
+		_ = f.Close()
+	}
+}
+
 // SetMemPool sets a memory pool (used to access the underlying GPFiles in full-read mode)
 func (d *GPDir) SetMemPool(pool concurrency.MemPoolGCable) {
 	d.options = append(d.options, WithReadAll(pool))
@@ -322,6 +469,66 @@ func (d *GPDir) Unmarshal(r concurrency.ReadWriteSeekCloser) error {
 		pos += 16
 	}
 
+	// Get Metadata.BlockRanges (if present in this header version)
+	d.BlockRanges = make([]*types.BlockRange, nBlocks)
+	if d.Metadata.Version < headerVersionBlockRanges || nBlocks == 0 {
+		return nil
+	}
+	if len(data) < pos+nBlocks*blockRangeSize {
+		return fmt.Errorf("%w (len: %d)", ErrInputSizeTooSmall, len(data))
+	}
+	blockRanges := make([]types.BlockRange, nBlocks)
+	for i := 0; i < nBlocks; i++ {
+		if data[pos] != 0 {
+			unmarshalBlockRange(&blockRanges[i], data[pos+1:pos+blockRangeSize])
+			blockRanges[i].HasV4 = d.BlockTraffic[i].NumV4Entries > 0
+			blockRanges[i].HasV6 = d.BlockTraffic[i].NumV6Entries > 0
+			d.BlockRanges[i] = &blockRanges[i]
+		}
+		pos += blockRangeSize
+	}
+
+	// Get Metadata.Traffic capture quality indicators (if present in this header version)
+	if d.Metadata.Version < headerVersionCaptureQuality {
+		return nil
+	}
+	if len(data) < pos+3*8+nBlocks*captureQualitySize {
+		return fmt.Errorf("%w (len: %d)", ErrInputSizeTooSmall, len(data))
+	}
+	d.Metadata.Traffic.NumPackets = binary.BigEndian.Uint64(data[pos : pos+8])
+	d.Metadata.Traffic.NumOverruns = binary.BigEndian.Uint64(data[pos+8 : pos+16])
+	d.Metadata.Traffic.NumTruncated = binary.BigEndian.Uint64(data[pos+16 : pos+24])
+	pos += 24
+	for i := 0; i < nBlocks; i++ {
+		d.BlockTraffic[i].NumPackets = uint64(binary.BigEndian.Uint32(data[pos : pos+4]))
+		d.BlockTraffic[i].NumOverruns = uint64(binary.BigEndian.Uint32(data[pos+4 : pos+8]))
+		d.BlockTraffic[i].NumTruncated = uint64(binary.BigEndian.Uint32(data[pos+8 : pos+12]))
+		pos += captureQualitySize
+	}
+
+	// Get Metadata.LabelMetadata (if present in this header version)
+	if d.Metadata.Version < headerVersionLabelColumns {
+		return nil
+	}
+	if err := unmarshalLabelMetadata(d.Metadata, data[pos:], d.BlockMetadata[0].BlockList); err != nil {
+		return err
+	}
+	pos += labelMetadataSize(d.LabelMetadata, nBlocks)
+
+	// Get Metadata.Traffic link state flags (if present in this header version)
+	if d.Metadata.Version < headerVersionLinkState {
+		return nil
+	}
+	if len(data) < pos+nBlocks {
+		return fmt.Errorf("%w (len: %d)", ErrInputSizeTooSmall, len(data))
+	}
+	for i := 0; i < nBlocks; i++ {
+		if data[pos+i]&linkDownFlag != 0 {
+			d.BlockTraffic[i].NumLinkDown = 1
+			d.Metadata.Traffic.NumLinkDown++
+		}
+	}
+
 	return nil
 }
 
@@ -340,10 +547,15 @@ func (d *GPDir) Marshal(w concurrency.ReadWriteSeekCloser) error {
 		nBlocks*4 + // Metadata.GlobalBlockMetadata.NumV6Entries
 		nBlocks*4 + // Metadata.GlobalBlockMetadata.NumDrops
 		nBlocks*4 + // Metadata.BlockMetadata.BlockList.Timestamp (Delta)
+		nBlocks*blockRangeSize + // Metadata.BlockRanges
+		3*8 + // Metadata.NumPackets / NumOverruns / NumTruncated
+		nBlocks*captureQualitySize + // Metadata.GlobalBlockMetadata.NumPackets / NumOverruns / NumTruncated
 		int(types.ColIdxCount)*8 + // Metadata.BlockMetadata.CurrentOffset
 		nBlocks*int(types.ColIdxCount)*4 + // Metadata.BlockMetadata.BlockList.Len
 		nBlocks*int(types.ColIdxCount)*4 + // Metadata.BlockMetadata.BlockList.RawLen
-		nBlocks*int(types.ColIdxCount) // Metadata.BlockMetadata.BlockList.Block.EncoderType
+		nBlocks*int(types.ColIdxCount) + // Metadata.BlockMetadata.BlockList.Block.EncoderType
+		labelMetadataSize(d.LabelMetadata, nBlocks) + // Metadata.LabelMetadata
+		nBlocks // Metadata.GlobalBlockMetadata.NumLinkDown (flags)
 
 	// Note: Lengths and timestamp deltas are encoded as uint32s, allowing for a maximum block (!) size of
 	// 4 GiB (uncompressed / compressed).
@@ -354,7 +566,7 @@ func (d *GPDir) Marshal(w concurrency.ReadWriteSeekCloser) error {
 	data := metaDataMemPool.Get(size)
 	defer metaDataMemPool.Put(data)
 
-	binary.BigEndian.PutUint64(data[0:8], d.Metadata.Version)                // Store header version
+	binary.BigEndian.PutUint64(data[0:8], headerVersion)                     // Store header version
 	binary.BigEndian.PutUint64(data[8:16], uint64(nBlocks))                  // Store flat nummber of blocks
 	binary.BigEndian.PutUint64(data[16:24], d.Metadata.Traffic.NumV4Entries) // Store global number of IPv4 flows
 	binary.BigEndian.PutUint64(data[24:32], d.Metadata.Traffic.NumV6Entries) // Store global number of IPv6 flows
@@ -406,6 +618,44 @@ func (d *GPDir) Marshal(w concurrency.ReadWriteSeekCloser) error {
 			lastTimestamp = d.BlockMetadata[0].BlockList[i].Timestamp
 			pos += 16
 		}
+
+		// Store Metadata.BlockRanges (flagging unknown ranges)
+		for i := 0; i < nBlocks; i++ {
+			if i < len(d.BlockRanges) && d.BlockRanges[i] != nil {
+				data[pos] = 1
+				marshalBlockRange(d.BlockRanges[i], data[pos+1:pos+blockRangeSize])
+			} else {
+				clear(data[pos : pos+blockRangeSize])
+			}
+			pos += blockRangeSize
+		}
+
+		// Store Metadata.Traffic capture quality indicators. Since they are indicators only, the ones
+		// of a block are capped at the encoding width (instead of failing the write)
+		binary.BigEndian.PutUint64(data[pos:pos+8], d.Metadata.Traffic.NumPackets)
+		binary.BigEndian.PutUint64(data[pos+8:pos+16], d.Metadata.Traffic.NumOverruns)
+		binary.BigEndian.PutUint64(data[pos+16:pos+24], d.Metadata.Traffic.NumTruncated)
+		pos += 24
+		for i := 0; i < nBlocks; i++ {
+			binary.BigEndian.PutUint32(data[pos:pos+4], uint32(min(d.BlockTraffic[i].NumPackets, maxUint32)))
+			binary.BigEndian.PutUint32(data[pos+4:pos+8], uint32(min(d.BlockTraffic[i].NumOverruns, maxUint32)))
+			binary.BigEndian.PutUint32(data[pos+8:pos+12], uint32(min(d.BlockTraffic[i].NumTruncated, maxUint32)))
+			pos += captureQualitySize
+		}
+
+		// Store Metadata.LabelMetadata
+		if err := marshalLabelMetadata(d.LabelMetadata, data[pos:]); err != nil {
+			return err
+		}
+		pos += labelMetadataSize(d.LabelMetadata, nBlocks)
+
+		// Store Metadata.Traffic link state flags
+		for i := 0; i < nBlocks; i++ {
+			data[pos+i] = 0
+			if d.BlockTraffic[i].NumLinkDown > 0 {
+				data[pos+i] = linkDownFlag
+			}
+		}
 	}
 
 	n, err := w.Write(data)
@@ -420,6 +670,34 @@ func (d *GPDir) Marshal(w concurrency.ReadWriteSeekCloser) error {
 	return nil
 }
 
+func marshalBlockRange(r *types.BlockRange, data []byte) {
+	data[0], data[1] = r.ProtoMin, r.ProtoMax
+	pos := 2
+	for _, field := range [][]byte{
+		r.DportMin[:], r.DportMax[:],
+		r.SIPv4.Min[:types.IPv4Width], r.SIPv4.Max[:types.IPv4Width],
+		r.DIPv4.Min[:types.IPv4Width], r.DIPv4.Max[:types.IPv4Width],
+		r.SIPv6.Min[:], r.SIPv6.Max[:],
+		r.DIPv6.Min[:], r.DIPv6.Max[:],
+	} {
+		pos += copy(data[pos:], field)
+	}
+}
+
+func unmarshalBlockRange(r *types.BlockRange, data []byte) {
+	r.ProtoMin, r.ProtoMax = data[0], data[1]
+	pos := 2
+	for _, field := range [][]byte{
+		r.DportMin[:], r.DportMax[:],
+		r.SIPv4.Min[:types.IPv4Width], r.SIPv4.Max[:types.IPv4Width],
+		r.DIPv4.Min[:types.IPv4Width], r.DIPv4.Max[:types.IPv4Width],
+		r.SIPv6.Min[:], r.SIPv6.Max[:],
+		r.DIPv6.Min[:], r.DIPv6.Max[:],
+	} {
+		pos += copy(field, data[pos:pos+len(field)])
+	}
+}
+
 // Path returns the path of the GPDir (up to the timestamp)
 func (d *GPDir) Path() string {
 	return d.dirPath
@@ -430,11 +708,30 @@ func (d *GPDir) MetadataPath() string {
 	return d.metaPath
 }
 
+// HasMetadata returns whether the metadata of the GPDir has been written to disk. Since the metadata
+// is only ever replaced atomically, a GPDir without it has no (fully written) blocks yet, e.g. because
+// its first write is still in progress
+func (d *GPDir) HasMetadata() bool {
+	return exists(d.metaPath)
+}
+
 // NBlocks returns the number of blocks in this GPDir
 func (d *GPDir) NBlocks() int {
 	return d.BlockMetadata[0].NBlocks()
 }
 
+// DataSize returns the size of all column files of this GPDir on disk (as recorded in their headers,
+// i.e. without accessing the files themselves)
+func (d *GPDir) DataSize() (size uint64) {
+	for i := 0; i < int(types.ColIdxCount); i++ {
+		size += d.BlockMetadata[i].CurrentOffset
+	}
+	for _, header := range d.LabelMetadata {
+		size += header.CurrentOffset
+	}
+	return
+}
+
 // Close closes all underlying open GPFiles and cleans up resources
 func (d *GPDir) Close() error {
 
@@ -451,10 +748,28 @@ func (d *GPDir) Close() error {
 			}
 		}
 	}
+	for _, labelFile := range d.labelFiles {
+		if err := labelFile.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	d.labelFiles = nil
+
+	// Persist all values added to the dictionaries (before the metadata referencing them)
+	if d.accessMode == ModeWrite {
+		for _, dict := range d.dictionaries {
+			if err := dict.persist(d.permissions, d.owner); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	d.dictionaries = nil
 
 	// Ensure resources are marked for cleanup
 	defer func() {
 		d.Metadata.BlockTraffic = nil
+		d.Metadata.BlockRanges = nil
+		d.Metadata.LabelMetadata = nil
 		for i := 0; i < int(types.ColIdxCount); i++ {
 			d.Metadata.BlockMetadata[i].BlockList = nil
 			d.Metadata.BlockMetadata[i] = nil
@@ -488,14 +803,62 @@ func (d *GPDir) Column(colIdx types.ColumnIndex) (*GPFile, error) {
 		if d.gpFiles[colIdx], err = New(filepath.Join(d.Path(), types.ColumnFileNames[colIdx]+FileSuffix), d.BlockMetadata[colIdx], d.accessMode, d.options...); err != nil {
 			return nil, err
 		}
+		d.gpFiles[colIdx].preallocSize = d.preallocSizes[colIdx]
 	}
 
 	return d.gpFiles[colIdx], nil
 }
 
+// Dictionary returns the dictionary of the (string) attribute with the given name (lazy-access).
+// In write mode, all values added to it are persisted upon Close()
+func (d *GPDir) Dictionary(name string) (*Dictionary, error) {
+
+	if !d.isOpen {
+		return nil, ErrDirNotOpen
+	}
+
+	if dict, exists := d.dictionaries[name]; exists {
+		return dict, nil
+	}
+	dict, err := loadDictionary(dictionaryPath(d.dirPath, name))
+	if err != nil {
+		return nil, err
+	}
+	if d.dictionaries == nil {
+		d.dictionaries = make(map[string]*Dictionary)
+	}
+	d.dictionaries[name] = dict
+
+	return dict, nil
+}
+
 // createIfRequired created the underlying path structure (if missing)
 func (d *GPDir) createIfRequired() error {
-	return os.MkdirAll(d.dirPath, calculateDirPerm(d.permissions))
+	perm := d.dirPerms
+	if perm == 0 {
+		perm = calculateDirPerm(d.permissions)
+	}
+	if !d.owner.isSet() {
+		return os.MkdirAll(d.dirPath, perm)
+	}
+
+	// determine the directories to be created in order to assign them to the owner
+	var missing []string
+	for path := d.dirPath; !exists(path); path = filepath.Dir(path) {
+		missing = append(missing, path)
+		if filepath.Dir(path) == path {
+			break
+		}
+	}
+	if err := os.MkdirAll(d.dirPath, perm); err != nil {
+		return err
+	}
+	for _, path := range missing {
+		if err := d.owner.chown(path); err != nil {
+			return fmt.Errorf("failed to set owner of directory %s: %w", path, err)
+		}
+	}
+	return nil
 }
 
 func (d *GPDir) writeMetadataAtomic() error {
@@ -519,19 +882,42 @@ func (d *GPDir) writeMetadataAtomic() error {
 		return err
 	}
 
-	// Set permissions / file mode
+	// Set permissions / file mode and owner
 	if err = os.Chmod(tempFile.Name(), d.permissions); err != nil {
 		return err
 	}
+	if err = d.owner.chown(tempFile.Name()); err != nil {
+		return err
+	}
 
 	// Move the temporary file
 	return os.Rename(tempFile.Name(), d.MetadataPath())
 }
 
+func (d *GPDir) setWriteConcurrency(n int) {
+	d.writeConcurrency = n
+}
+
+func (d *GPDir) setSharedEncoder() {
+	d.sharedEncoder = true
+}
+
 func (d *GPDir) setPermissions(permissions fs.FileMode) {
 	d.permissions = permissions
 }
 
+func (d *GPDir) setDirPermissions(permissions fs.FileMode) {
+	d.dirPerms = permissions
+}
+
+func (d *GPDir) setOwner(uid, gid int) {
+	d.owner = owner{uid: uid, gid: gid}
+}
+
+func (d *GPDir) setPreallocation(enabled bool) {
+	d.preallocate = enabled
+}
+
 // DirTimestamp returns timestamp rounded down to the nearest directory time frame (usually a day)
 func DirTimestamp(timestamp int64) int64 {
 	return (timestamp / EpochDay) * EpochDay
@@ -2,7 +2,9 @@ package gpfile
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -17,6 +19,22 @@ import (
 // Global pool for reusable memory buffers
 var bufPool = concurrency.NewMemPoolNoLimit()
 
+var (
+
+	// ErrBlockNotFound denotes that no block exists for a requested timestamp
+	ErrBlockNotFound = errors.New("block not found")
+
+	// ErrBlockExists denotes that a block for a given timestamp has already been written
+	ErrBlockExists = errors.New("block already present")
+
+	// ErrCorruptBlock denotes that a block (or its header information) could not be decoded
+	ErrCorruptBlock = errors.New("corrupt block")
+
+	// ErrInvalidAccessMode denotes that an operation is not permitted in the access mode the
+	// GPFile / GPDir has been opened with
+	ErrInvalidAccessMode = errors.New("invalid access mode")
+)
+
 const (
 	// FileSuffix denotes the suffix used for the raw data stored
 	FileSuffix = ".gpf"
@@ -32,7 +50,23 @@ const (
 	bufferPreallocSize = 8192
 
 	// headerVersion denotes the current header version
-	headerVersion = 1
+	headerVersion = 5
+
+	// headerVersionBlockRanges denotes the first header version containing the attribute
+	// value ranges of each block
+	headerVersionBlockRanges = 2
+
+	// headerVersionCaptureQuality denotes the first header version containing the capture
+	// quality indicators (received packets, ring buffer overruns, truncated packets) of each block
+	headerVersionCaptureQuality = 3
+
+	// headerVersionLabelColumns denotes the first header version containing the block information
+	// of the (optional) label columns
+	headerVersionLabelColumns = 4
+
+	// headerVersionLinkState denotes the first header version flagging the blocks written while
+	// the link of the interface was down
+	headerVersionLinkState = 5
 
 	// ModeRead denotes read access
 	ModeRead = os.O_RDONLY
@@ -58,6 +92,11 @@ type GPFile struct {
 	// sequential read
 	lastSeekPos int64
 
+	// readAhead denotes the number of blocks following the one currently read which are
+	// prefetched (up to prefetchedUntil) while it is decompressed
+	readAhead       int
+	prefetchedUntil int64
+
 	// defaultEncoderType governs how data blocks are (de-)compressed by default
 	defaultEncoderType  encoders.Type
 	defaultEncoderLevel int
@@ -69,6 +108,11 @@ type GPFile struct {
 	// permissions defines the permissions / mode to use for this GPFile
 	accessMode  int
 	permissions fs.FileMode
+	owner       owner
+
+	// preallocSize denotes the size the file is preallocated to upon opening it for writing (if
+	// larger than its current size)
+	preallocSize uint64
 
 	// Reusable buffers for compression / decompression
 	uncompData, blockData []byte
@@ -84,12 +128,13 @@ func New(filename string, header *storage.BlockHeader, accessMode int, options .
 		header:             header,
 		accessMode:         accessMode,
 		permissions:        defaultPermissions,
+		owner:              defaultOwner,
 		defaultEncoderType: defaultEncoderType,
 		freeEncoder:        true,
 	}
 
 	if header == nil {
-		return nil, fmt.Errorf("header information missing when trying to access `%s`", filename)
+		return nil, fmt.Errorf("%w: header information missing when trying to access `%s`", ErrCorruptBlock, filename)
 	}
 
 	// apply functional options
@@ -126,7 +171,7 @@ func (g *GPFile) ReadBlock(timestamp int64) ([]byte, error) {
 	// Check if the requested block exists
 	blockIdx, found := g.header.BlockIndex(timestamp)
 	if !found {
-		return nil, fmt.Errorf("block for timestamp %v not found", timestamp)
+		return nil, fmt.Errorf("%w: no block for timestamp %v", ErrBlockNotFound, timestamp)
 	}
 
 	return g.ReadBlockAtIndex(blockIdx)
@@ -137,7 +182,7 @@ func (g *GPFile) ReadBlockAtIndex(idx int) ([]byte, error) {
 
 	// Check that the file has been opened in the correct mode
 	if g.accessMode != ModeRead {
-		return nil, fmt.Errorf("cannot read from GPFile in write mode")
+		return nil, fmt.Errorf("%w: cannot read from GPFile in write mode", ErrInvalidAccessMode)
 	}
 	block := g.header.BlockList[idx]
 
@@ -163,9 +208,14 @@ func (g *GPFile) ReadBlockAtIndex(idx int) ([]byte, error) {
 			return nil, err
 		}
 	}
+	g.prefetchAhead(idx)
 
-	// Perform decompression of data and store in output slice
-	var nRead int
+	// Perform decompression of data and store in output slice. Failures to read the data from the
+	// file are told apart from failures to decode it, only the latter denote a corrupt block
+	var (
+		nRead int
+		src   = &blockReader{r: g.file}
+	)
 	if uint32(cap(g.uncompData)) < block.RawLen {
 		g.uncompData = make([]byte, 0, 2*block.RawLen)
 	}
@@ -176,7 +226,7 @@ func (g *GPFile) ReadBlockAtIndex(idx int) ([]byte, error) {
 		if block.EncoderType != g.defaultEncoder.Type() {
 			decoder, err := encoder.New(block.EncoderType)
 			if err != nil {
-				return nil, fmt.Errorf("failed to decode block %d based on detected encoder type %v: %w", block, block.EncoderType, err)
+				return nil, fmt.Errorf("%w: failed to decode block %d based on detected encoder type %v: %w", ErrCorruptBlock, idx, block.EncoderType, err)
 			}
 			g.defaultEncoder = decoder
 		}
@@ -185,34 +235,92 @@ func (g *GPFile) ReadBlockAtIndex(idx int) ([]byte, error) {
 			g.blockData = make([]byte, 0, 2*block.Len)
 		}
 		g.blockData = g.blockData[:block.Len]
-		nRead, err = g.defaultEncoder.Decompress(g.blockData, g.uncompData, g.file)
+		nRead, err = g.defaultEncoder.Decompress(g.blockData, g.uncompData, src)
 	} else {
 		// micro-optimization that saves the allocation of blockData for decompression
 		// in the Null decompression case, since it is essentially just a byte read
 		// and the src bytes aren't used
-		nRead, err = null.DefaultEncoder.Decompress(nil, g.uncompData, g.file)
+		nRead, err = null.DefaultEncoder.Decompress(nil, g.uncompData, src)
 	}
 	if err != nil {
-		return nil, err
+		// the position in the file is unknown after a failed read
+		g.lastSeekPos = -1
+		if readErr := src.readErr(block); readErr != nil {
+			return nil, fmt.Errorf("failed to read block %d of file %s: %w", idx, g.filename, readErr)
+		}
+		return nil, fmt.Errorf("%w: %w", ErrCorruptBlock, err)
 	}
 	if uint32(nRead) != block.RawLen {
-		return nil, fmt.Errorf("unexpected amount of bytes after decompression, want %d, have %d", block.RawLen, nRead)
+		return nil, fmt.Errorf("%w: unexpected amount of bytes after decompression, want %d, have %d", ErrCorruptBlock, block.RawLen, nRead)
 	}
 	g.lastSeekPos += int64(block.Len)
 
 	return g.uncompData, nil
 }
 
+// prefetchAhead issues asynchronous reads of the blocks following the indexed one (up to readAhead
+// blocks), overlapping the disk I/O for them with the decompression of the current block. It only
+// applies to files read block by block from disk (as opposed to files read into memory at once)
+// blockReader keeps track of the data read for a block and the error encountered doing so (if any)
+type blockReader struct {
+	r   io.Reader
+	n   int
+	err error
+}
+
+// Read implements io.Reader
+func (b *blockReader) Read(p []byte) (n int, err error) {
+	n, err = b.r.Read(p)
+	b.n += n
+	if err != nil && b.err == nil {
+		b.err = err
+	}
+	return n, err
+}
+
+// readErr returns the error encountered reading the (stored) data of block from the file, where
+// reading less data than expected is an error as well
+func (b *blockReader) readErr(block storage.BlockAtTime) error {
+	expected := int(block.Len)
+	if block.EncoderType == encoders.EncoderTypeNull {
+		expected = int(block.RawLen)
+	}
+	switch {
+	case b.err != nil && !errors.Is(b.err, io.EOF):
+		return b.err
+	case b.n < expected:
+		return fmt.Errorf("%w: want %d bytes, have %d", io.ErrUnexpectedEOF, expected, b.n)
+	}
+	return nil
+}
+
+func (g *GPFile) prefetchAhead(idx int) {
+	f, isFile := g.file.(*os.File)
+	if !isFile || g.readAhead <= 0 {
+		return
+	}
+
+	last := g.header.BlockList[min(idx+g.readAhead, len(g.header.BlockList)-1)]
+	end := int64(last.Offset) + int64(last.Len)
+	if end <= g.prefetchedUntil {
+		return
+	}
+	start := max(g.prefetchedUntil, int64(g.header.BlockList[idx].Offset))
+	if err := prefetch(f, start, end-start); err == nil {
+		g.prefetchedUntil = end
+	}
+}
+
 // writeBlock writes data for a given timestamp to the file (not exposed to ensure handling by GPDir)
 func (g *GPFile) writeBlock(timestamp int64, blockData []byte) error {
 	blockIdx, exists := g.header.BlockIndex(timestamp)
 	if exists {
-		return fmt.Errorf("timestamp %d already present: offset=%d", timestamp, g.header.BlockList[int64(blockIdx)].Offset)
+		return fmt.Errorf("%w: timestamp %d already present: offset=%d", ErrBlockExists, timestamp, g.header.BlockList[int64(blockIdx)].Offset)
 	}
 
 	// Check that the file has been opened in the correct mode
 	if g.accessMode != ModeWrite {
-		return fmt.Errorf("cannot write to GPFile in read mode")
+		return fmt.Errorf("%w: cannot write to GPFile in read mode", ErrInvalidAccessMode)
 	}
 
 	// If block data is empty, do nothing except updating the header
@@ -262,6 +370,10 @@ func (g *GPFile) writeBlock(timestamp int64, blockData []byte) error {
 	})
 	g.header.CurrentOffset += uint64(nWritten)
 
+	blocksWritten.Inc()
+	bytesWritten.Add(float64(nWritten))
+	rawBytesWritten.Add(float64(len(blockData)))
+
 	return nil
 }
 
@@ -309,9 +421,22 @@ func (g *GPFile) open() (err error) {
 	}
 
 	// Open file for append, create if not exists
+	isNew := g.accessMode == ModeWrite && g.owner.isSet() && !exists(g.filename)
 	if g.file, err = os.OpenFile(g.filename, g.accessMode, g.permissions); err != nil {
 		return fmt.Errorf("failed to open file %s: %w", g.filename, err)
 	}
+	if isNew {
+		if err = g.owner.chown(g.filename); err != nil {
+			return fmt.Errorf("failed to set owner of file %s: %w", g.filename, err)
+		}
+	}
+
+	// Preallocation is best-effort, the data is written regardless (possibly fragmented)
+	if g.accessMode == ModeWrite && g.preallocSize > g.header.CurrentOffset {
+		if err = preallocate(g.file.(*os.File), int64(g.preallocSize)); err != nil {
+			preallocationErrors.Inc()
+		}
+	}
 	if g.accessMode == ModeWrite {
 
 		// Ensure that the file is loaded at the position of the last known successful write
@@ -334,6 +459,14 @@ func (g *GPFile) setPermissions(permissions fs.FileMode) {
 	g.permissions = permissions
 }
 
+func (g *GPFile) setOwner(uid, gid int) {
+	g.owner = owner{uid: uid, gid: gid}
+}
+
+func (g *GPFile) setReadAhead(n int) {
+	g.readAhead = n
+}
+
 func (g *GPFile) setMemPool(pool concurrency.MemPoolGCable) {
 	g.memPool = pool
 }
@@ -2,11 +2,15 @@ package gpfile
 
 import (
 	"bytes"
+	"crypto/rand"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
+	"syscall"
 	"testing"
 	"time"
 
@@ -82,6 +86,122 @@ func TestDirPermissions(t *testing.T) {
 	}
 }
 
+func TestDirPermissionsAndOwner(t *testing.T) {
+	uid, gid := os.Getuid(), os.Getgid()
+	if os.Geteuid() == 0 {
+		uid, gid = 12345, 23456
+	}
+
+	basePath := filepath.Join(t.TempDir(), "eth0")
+	testDir := NewDir(basePath, 1000, ModeWrite, WithPermissions(0640), WithDirPermissions(0750), WithOwner(uid, gid))
+	require.Nil(t, testDir.Open())
+	require.Nil(t, writeDummyBlock(1000, testDir, 1))
+	require.Nil(t, testDir.Close())
+
+	// all directories / files created are assigned to the owner
+	for _, path := range []string{basePath, filepath.Join(basePath, "1970"), testDir.Path(), testDir.MetadataPath(),
+		filepath.Join(testDir.Path(), types.ColumnFileNames[types.BytesRcvdColIdx]+FileSuffix)} {
+		stat, err := os.Stat(path)
+		require.Nil(t, err)
+		if stat.IsDir() {
+			require.Equal(t, fs.FileMode(0750), stat.Mode().Perm(), path)
+		} else {
+			require.Equal(t, fs.FileMode(0640), stat.Mode().Perm(), path)
+		}
+		sys := stat.Sys().(*syscall.Stat_t)
+		require.Equal(t, uint32(uid), sys.Uid, path)
+		require.Equal(t, uint32(gid), sys.Gid, path)
+	}
+}
+
+func TestPreallocation(t *testing.T) {
+	basePath := filepath.Join(t.TempDir(), "eth0")
+	colPath := func(dir *GPDir) string {
+		return filepath.Join(dir.Path(), types.ColumnFileNames[types.BytesRcvdColIdx]+FileSuffix)
+	}
+	allocated := func(path string) (int64, int64) {
+		stat, err := os.Stat(path)
+		require.Nil(t, err)
+		return stat.Size(), stat.Sys().(*syscall.Stat_t).Blocks * 512
+	}
+
+	// the first day holds (incompressible) data of 1 MiB per column
+	data := make([]byte, 1024*1024)
+	_, err := rand.Read(data)
+	require.Nil(t, err)
+	day1 := NewDir(basePath, EpochDay, ModeWrite)
+	require.Nil(t, day1.Open())
+	require.Nil(t, day1.WriteBlocks(EpochDay, TrafficMetadata{NumV4Entries: 1}, types.Counters{},
+		[types.ColIdxCount][]byte{data, data, data, data, data, data, data, data}))
+	require.Nil(t, day1.Close())
+
+	// the files of the following day are preallocated accordingly
+	day2 := NewDir(basePath, 2*EpochDay, ModeWrite, WithPreallocation(true))
+	require.Nil(t, day2.Open())
+	require.Nil(t, writeDummyBlock(2*EpochDay, day2, 1))
+	require.Nil(t, day2.Close())
+
+	size, reserved := allocated(colPath(day2))
+	require.Equal(t, int64(1), size)
+	if reserved < int64(len(data)) {
+		t.Skip("preallocation not supported by the file system of", basePath)
+	}
+
+	// appending to the file retains the reservation
+	day2 = NewDir(basePath, 2*EpochDay+300, ModeWrite, WithPreallocation(true))
+	require.Nil(t, day2.Open())
+	require.Nil(t, writeDummyBlock(2*EpochDay+300, day2, 2))
+	require.Nil(t, day2.Close())
+	size, reserved = allocated(colPath(day2))
+	require.Equal(t, int64(2), size)
+	require.GreaterOrEqual(t, reserved, int64(len(data)))
+
+	// the space reserved for the previous day is released once the next day is preallocated
+	day3 := NewDir(basePath, 3*EpochDay, ModeWrite, WithPreallocation(true))
+	require.Nil(t, day3.Open())
+	require.Nil(t, writeDummyBlock(3*EpochDay, day3, 1))
+	require.Nil(t, day3.Close())
+	_, reserved = allocated(colPath(day2))
+	require.Less(t, reserved, int64(len(data)))
+	_, reserved = allocated(colPath(day3))
+	require.GreaterOrEqual(t, reserved, int64(len(data)))
+
+	// the data of the preallocated files is read back as usual
+	day2 = NewDir(basePath, 2*EpochDay, ModeRead)
+	require.Nil(t, day2.Open())
+	block, err := day2.ReadBlockAtIndex(types.BytesRcvdColIdx, 1)
+	require.Nil(t, err)
+	require.Equal(t, []byte{2}, block)
+	require.Nil(t, day2.Close())
+}
+
+func TestReadAhead(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "test.gpf")
+	header := newMetadata().BlockMetadata[0]
+
+	gpf, err := New(testPath, header, ModeWrite)
+	require.Nil(t, err)
+	for i := 0; i < 10; i++ {
+		require.Nil(t, gpf.writeBlock(int64(i), bytes.Repeat([]byte{byte(i)}, 1024)))
+	}
+	require.Nil(t, gpf.Close())
+
+	gpf, err = New(testPath, header, ModeRead, WithReadAhead(2))
+	require.Nil(t, err)
+	for i := 0; i < 10; i++ {
+		block, err := gpf.ReadBlockAtIndex(i)
+		require.Nil(t, err)
+		require.Equal(t, bytes.Repeat([]byte{byte(i)}, 1024), block)
+
+		// the following blocks (within the file) have been prefetched
+		if runtime.GOOS == "linux" {
+			last := header.BlockList[min(i+2, 9)]
+			require.Equal(t, int64(last.Offset)+int64(last.Len), gpf.prefetchedUntil)
+		}
+	}
+	require.Nil(t, gpf.Close())
+}
+
 func TestFilePermissions(t *testing.T) {
 	require.Nil(t, os.RemoveAll(testFilePath))
 	for _, perm := range []fs.FileMode{
@@ -115,6 +235,46 @@ func TestFailedRead(t *testing.T) {
 	require.Error(t, err, "expected an error trying to open a non-existing GPFile for reading, got none")
 }
 
+func TestReadBlockErrors(t *testing.T) {
+	testPath := filepath.Join(t.TempDir(), "test.gpf")
+	header := newMetadata().BlockMetadata[0]
+
+	gpf, err := New(testPath, header, ModeWrite)
+	require.Nil(t, err)
+	for i := 0; i < 3; i++ {
+		require.Nil(t, gpf.writeBlock(int64(i), bytes.Repeat([]byte{byte(i)}, 1024)))
+	}
+	require.Nil(t, gpf.Close())
+
+	// failing to read from the file doesn't denote a corrupt block
+	gpf, err = New(testPath, header, ModeRead)
+	require.Nil(t, err)
+	_, err = gpf.ReadBlockAtIndex(0)
+	require.Nil(t, err)
+	require.Nil(t, gpf.file.Close())
+	_, err = gpf.ReadBlockAtIndex(1)
+	require.ErrorIs(t, err, os.ErrClosed)
+	require.NotErrorIs(t, err, ErrCorruptBlock)
+
+	// neither does a short read
+	require.Nil(t, os.Truncate(testPath, int64(header.BlockList[2].Offset)+1))
+	gpf, err = New(testPath, header, ModeRead)
+	require.Nil(t, err)
+	_, err = gpf.ReadBlockAtIndex(2)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.NotErrorIs(t, err, ErrCorruptBlock)
+
+	// data which can't be decoded does
+	f, err := os.OpenFile(testPath, os.O_WRONLY, 0)
+	require.Nil(t, err)
+	_, err = f.WriteAt(bytes.Repeat([]byte{0xff}, int(header.BlockList[1].Len)), int64(header.BlockList[1].Offset))
+	require.Nil(t, err)
+	require.Nil(t, f.Close())
+	_, err = gpf.ReadBlockAtIndex(1)
+	require.ErrorIs(t, err, ErrCorruptBlock)
+	require.Nil(t, gpf.Close())
+}
+
 func TestCreateFile(t *testing.T) {
 	gpf, err := New(testFilePath, newMetadata().BlockMetadata[0], ModeWrite)
 	require.Nil(t, err, "failed to create new GPFile")
@@ -133,6 +293,9 @@ func TestWriteFile(t *testing.T) {
 	timestamp := time.Now()
 	require.Nil(t, gpf.writeBlock(timestamp.Unix(), []byte{1, 2, 3, 4}), "failed to write block")
 	require.Nil(t, gpf.validateBlocks(1), "failed to validate block")
+	require.ErrorIs(t, gpf.writeBlock(timestamp.Unix(), []byte{1, 2, 3, 4}), ErrBlockExists)
+	_, err = gpf.ReadBlockAtIndex(0)
+	require.ErrorIs(t, err, ErrInvalidAccessMode)
 	require.Nil(t, gpf.Close(), "failed to close test file")
 }
 
@@ -213,6 +376,10 @@ func testRoundtrip(t *testing.T, encType encoders.Type) {
 		require.Equalf(t, blockData, expectedData, "unexpected data at block timetamp %v", blockItem.Timestamp)
 	}
 
+	_, err = gpf.ReadBlock(1001)
+	require.ErrorIs(t, err, ErrBlockNotFound)
+	require.ErrorIs(t, gpf.writeBlock(1001, []byte{1}), ErrInvalidAccessMode)
+
 	require.Error(t, gpf.open(), "expected error trying to re-open already open file, got none")
 	require.Nil(t, gpf.Close(), "failed to close test file")
 }
@@ -225,6 +392,10 @@ func TestInvalidMetadata(t *testing.T) {
 
 	testDir := NewDir("/tmp/test_db", 1000, ModeRead)
 	require.ErrorIs(t, testDir.Open(), ErrInputSizeTooSmall)
+	require.ErrorIs(t, testDir.Open(), ErrCorruptBlock)
+
+	require.Nil(t, os.Remove("/tmp/test_db/1970/01/0/.blockmeta"))
+	require.ErrorIs(t, NewDir("/tmp/test_db", 1000, ModeRead).Open(), ErrMetadataMissing)
 }
 
 func TestEmptyMetadata(t *testing.T) {
@@ -276,16 +447,22 @@ func TestMetadataRoundTrip(t *testing.T) {
 		NumV4Entries: 10,
 		NumV6Entries: 5,
 		NumDrops:     0,
+		NumPackets:   100,
 	})
 	testDir.BlockTraffic = append(testDir.BlockTraffic, TrafficMetadata{
 		NumV4Entries: 0,
 		NumV6Entries: 30,
 		NumDrops:     1,
+		NumPackets:   1000,
+		NumTruncated: 2,
+		NumLinkDown:  1,
 	})
 	testDir.BlockTraffic = append(testDir.BlockTraffic, TrafficMetadata{
 		NumV4Entries: 3,
 		NumV6Entries: 3,
 		NumDrops:     10000,
+		NumPackets:   40000,
+		NumOverruns:  3,
 	})
 	for _, blockTraffic := range testDir.BlockTraffic {
 		testDir.Metadata.Traffic = testDir.Metadata.Traffic.Add(blockTraffic)
@@ -317,6 +494,107 @@ func TestMetadataRoundTrip(t *testing.T) {
 	require.Equal(t, sumNumV4Entries, int(testDir.Metadata.Traffic.NumV4Entries), "mismatched number of total IPv4 entries vs. computed")
 	require.Equal(t, sumNumV6Entries, int(testDir.Metadata.Traffic.NumV6Entries), "mismatched number of total IPv6 entries vs. computed")
 	require.Equal(t, sumDrops, int(testDir.Metadata.Traffic.NumDrops), "mismatched number of total packet drops vs. computed")
+	require.Equal(t, refMetadata.Traffic, testDir.Metadata.Traffic, "mismatched global traffic metadata")
+
+	dropRate, known := testDir.TrafficAtIndex(2).DropRate()
+	require.True(t, known)
+	require.Equal(t, 0.25, dropRate)
+	_, known = TrafficMetadata{NumDrops: 1}.DropRate()
+	require.False(t, known, "drop rate without received packets unexpectedly known")
+}
+
+func TestBlockRangeRoundTrip(t *testing.T) {
+
+	require.Nil(t, os.RemoveAll("/tmp/test_db"))
+
+	testDir := NewDir("/tmp/test_db", 1000, ModeWrite)
+	require.Nil(t, testDir.Open(), "error opening test dir for writing")
+
+	// Write one consistent block (one IPv4 and one IPv6 flow) and one block with broken columns
+	require.Nil(t, testDir.WriteBlocks(1000, TrafficMetadata{
+		NumV4Entries: 1,
+		NumV6Entries: 1,
+	}, types.Counters{}, [types.ColIdxCount][]byte{
+		types.SIPColIdx:   {10, 0, 0, 1, 0xfe, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+		types.DIPColIdx:   {10, 0, 0, 2, 0xfe, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2},
+		types.ProtoColIdx: {6, 17},
+		types.DportColIdx: {0, 80, 1, 187},
+	}), "failed to write block")
+	require.Nil(t, writeDummyBlock(1300, testDir, 1), "failed to write block")
+
+	require.NotNil(t, testDir.BlockRangeAtIndex(0))
+	refRange := *testDir.BlockRangeAtIndex(0)
+	require.Equal(t, types.BlockRange{
+		HasV4:    true,
+		HasV6:    true,
+		SIPv4:    types.IPRange{Min: [16]byte{10, 0, 0, 1}, Max: [16]byte{10, 0, 0, 1}},
+		DIPv4:    types.IPRange{Min: [16]byte{10, 0, 0, 2}, Max: [16]byte{10, 0, 0, 2}},
+		SIPv6:    types.IPRange{Min: [16]byte{0xfe, 0x80, 15: 1}, Max: [16]byte{0xfe, 0x80, 15: 1}},
+		DIPv6:    types.IPRange{Min: [16]byte{0xfe, 0x80, 15: 2}, Max: [16]byte{0xfe, 0x80, 15: 2}},
+		DportMin: [2]byte{0, 80},
+		DportMax: [2]byte{1, 187},
+		ProtoMin: 6,
+		ProtoMax: 17,
+	}, refRange)
+	require.Nil(t, testDir.BlockRangeAtIndex(1), "broken block unexpectedly has a range")
+	require.Nil(t, testDir.Close(), "error writing test dir")
+
+	testDir = NewDir("/tmp/test_db", 1000, ModeRead)
+	require.Nil(t, testDir.Open(), "error opening test dir for reading")
+	require.Equal(t, 2, testDir.NBlocks())
+	require.NotNil(t, testDir.BlockRangeAtIndex(0))
+	require.Equal(t, refRange, *testDir.BlockRangeAtIndex(0), "mismatched block range")
+	require.Nil(t, testDir.BlockRangeAtIndex(1), "broken block unexpectedly has a range")
+	require.Nil(t, testDir.BlockRangeAtIndex(2))
+	require.Nil(t, testDir.Close())
+}
+
+func TestLabelColumnRoundTrip(t *testing.T) {
+
+	require.Nil(t, os.RemoveAll("/tmp/test_db"))
+
+	testDir := NewDir("/tmp/test_db", 1000, ModeWrite)
+	require.Nil(t, testDir.Open(), "error opening test dir for writing")
+
+	// The first block is written without labels, the label column is backfilled upon the second one
+	require.Nil(t, writeDummyBlock(1000, testDir, 1), "failed to write block")
+	require.Nil(t, testDir.WriteBlocksWithLabels(1300, TrafficMetadata{
+		NumV4Entries: 2,
+		NumV6Entries: 1,
+	}, types.Counters{}, [types.ColIdxCount][]byte{}, map[string][]string{
+		"process": {"nginx.service", "", "nginx.service"},
+	}), "failed to write block")
+	require.Nil(t, writeDummyBlock(1600, testDir, 1), "failed to write block")
+
+	require.ErrorIs(t, testDir.WriteBlocksWithLabels(1900, TrafficMetadata{NumV4Entries: 1}, types.Counters{}, [types.ColIdxCount][]byte{},
+		map[string][]string{"process": {"a", "b"}}), ErrInvalidLabelColumn)
+	require.ErrorIs(t, testDir.WriteBlocksWithLabels(1900, TrafficMetadata{NumV4Entries: 1}, types.Counters{}, [types.ColIdxCount][]byte{},
+		map[string][]string{"sip": {"a"}}), ErrInvalidLabelColumn)
+	require.Nil(t, testDir.Close(), "error writing test dir")
+
+	testDir = NewDir("/tmp/test_db", 1000, ModeRead)
+	require.Nil(t, testDir.Open(), "error opening test dir for reading")
+	require.Equal(t, 3, testDir.NBlocks())
+	require.Equal(t, []string{"process"}, testDir.LabelColumns())
+
+	dict, err := testDir.Dictionary("process")
+	require.Nil(t, err)
+	for i, expected := range [][]string{nil, {"nginx.service", "", "nginx.service"}, nil} {
+		block, err := testDir.ReadLabelBlockAtIndex("process", i)
+		require.Nil(t, err)
+		if expected == nil {
+			require.Empty(t, block)
+			continue
+		}
+		values, err := dict.Resolve(block)
+		require.Nil(t, err)
+		require.Equal(t, expected, values)
+	}
+
+	block, err := testDir.ReadLabelBlockAtIndex("unit", 0)
+	require.Nil(t, err)
+	require.Empty(t, block)
+	require.Nil(t, testDir.Close())
 }
 
 func TestBrokenAccess(t *testing.T) {
@@ -434,3 +712,39 @@ func writeDummyBlock(timestamp int64, dir *GPDir, dummyByte byte) error {
 		PacketsSent: uint64(dummyByte),
 	}, [types.ColIdxCount][]byte{{dummyByte}, {dummyByte}, {dummyByte}, {dummyByte}, {dummyByte}, {dummyByte}, {dummyByte}, {dummyByte}})
 }
+
+func TestParallelWriteBlocks(t *testing.T) {
+
+	// writeDir writes a few blocks of (compressible) data to all columns of a new GPDir
+	writeDir := func(path string, opts ...Option) {
+		dir := NewDir(path, 1000, ModeWrite, append([]Option{WithEncoderTypeLevel(encoders.EncoderTypeLZ4, 0)}, opts...)...)
+		require.Nil(t, dir.Open())
+		for ts := int64(1000); ts < 1005; ts++ {
+			var dbData [types.ColIdxCount][]byte
+			for colIdx := range dbData {
+				dbData[colIdx] = bytes.Repeat([]byte{byte(colIdx), byte(ts)}, 1000*(colIdx+1))
+			}
+			require.Nil(t, dir.WriteBlocks(ts, TrafficMetadata{NumV4Entries: uint64(ts)}, types.Counters{PacketsRcvd: 1}, dbData))
+		}
+		require.Nil(t, dir.Close())
+	}
+
+	seqPath, parPath := t.TempDir(), t.TempDir()
+	writeDir(seqPath)
+	writeDir(parPath, WithWriteConcurrency(3))
+
+	seqDir, parDir := NewDir(seqPath, 1000, ModeRead), NewDir(parPath, 1000, ModeRead)
+	require.Nil(t, seqDir.Open())
+	require.Nil(t, parDir.Open())
+	require.Equal(t, seqDir.Metadata, parDir.Metadata)
+
+	for colIdx := types.ColumnIndex(0); colIdx < types.ColIdxCount; colIdx++ {
+		seqData, err := os.ReadFile(filepath.Join(seqDir.Path(), types.ColumnFileNames[colIdx]+FileSuffix))
+		require.Nil(t, err)
+		parData, err := os.ReadFile(filepath.Join(parDir.Path(), types.ColumnFileNames[colIdx]+FileSuffix))
+		require.Nil(t, err)
+		require.Equal(t, seqData, parData)
+	}
+	require.Nil(t, seqDir.Close())
+	require.Nil(t, parDir.Close())
+}
@@ -0,0 +1,245 @@
+package gpfile
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/els0r/goProbe/pkg/goDB/storage"
+	"github.com/els0r/goProbe/pkg/types"
+)
+
+// maxLabelColumns denotes the maximum number of label columns per GPDir (encoded as uint8)
+const maxLabelColumns = 255
+
+// ErrInvalidLabelColumn denotes that a label column can't be written to a GPDir
+var ErrInvalidLabelColumn = errors.New("invalid label column")
+
+// label column names are part of file names and must not clash with the names of the attribute columns
+var labelColumnRegexp = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+// validateLabelColumn checks if name can be used as the name of a label column
+func validateLabelColumn(name string) error {
+	if !labelColumnRegexp.MatchString(name) {
+		return fmt.Errorf("%w: `%s`", ErrInvalidLabelColumn, name)
+	}
+	for _, colName := range types.ColumnFileNames {
+		if name == colName {
+			return fmt.Errorf("%w: `%s` is an attribute column", ErrInvalidLabelColumn, name)
+		}
+	}
+	return nil
+}
+
+// LabelColumns returns the names of all label columns stored in the GPDir (ordered by name)
+func (d *GPDir) LabelColumns() []string {
+	names := make([]string, 0, len(d.LabelMetadata))
+	for name := range d.LabelMetadata {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LabelColumn returns the underlying GPFile for a specified label column (lazy-access)
+func (d *GPDir) LabelColumn(name string) (*GPFile, error) {
+
+	if !d.isOpen {
+		return nil, ErrDirNotOpen
+	}
+
+	if labelFile, exists := d.labelFiles[name]; exists {
+		return labelFile, nil
+	}
+	header, exists := d.LabelMetadata[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: `%s` not present", ErrInvalidLabelColumn, name)
+	}
+	labelFile, err := New(filepath.Join(d.Path(), name+FileSuffix), header, d.accessMode, d.options...)
+	if err != nil {
+		return nil, err
+	}
+	if d.labelFiles == nil {
+		d.labelFiles = make(map[string]*GPFile)
+	}
+	d.labelFiles[name] = labelFile
+
+	return labelFile, nil
+}
+
+// ReadLabelBlockAtIndex returns the block for a specified block index from the GPFile of a label
+// column. The block holds one dictionary ID per flow of the block (see Dictionary()), ordered like
+// the flows of the attribute columns. If the column isn't present or no labels were written along
+// with the block, an empty block is returned
+func (d *GPDir) ReadLabelBlockAtIndex(name string, blockIdx int) ([]byte, error) {
+
+	if !d.isOpen {
+		return nil, ErrDirNotOpen
+	}
+	if _, exists := d.LabelMetadata[name]; !exists {
+		return nil, nil
+	}
+
+	labelFile, err := d.LabelColumn(name)
+	if err != nil {
+		return nil, err
+	}
+	return labelFile.ReadBlockAtIndex(blockIdx)
+}
+
+// writeLabelBlocks writes the labels of the flows of a block to their label columns, the values of
+// each column being aligned with the flows of the attribute columns. Label columns created by this
+// write are backfilled with empty blocks, existing label columns without labels receive an empty block
+// (so that all columns of the GPDir share the same blocks)
+func (d *GPDir) writeLabelBlocks(timestamp int64, nEntries int, labels map[string][]string) error {
+	if len(labels) == 0 && len(d.LabelMetadata) == 0 {
+		return nil
+	}
+
+	for name, values := range labels {
+		if err := validateLabelColumn(name); err != nil {
+			return err
+		}
+		if len(values) != nEntries {
+			return fmt.Errorf("%w: `%s` has %d values for %d flows", ErrInvalidLabelColumn, name, len(values), nEntries)
+		}
+		if _, exists := d.LabelMetadata[name]; exists {
+			continue
+		}
+		if len(d.LabelMetadata) >= maxLabelColumns {
+			return fmt.Errorf("%w: `%s` exceeds the maximum number of label columns (%d)", ErrInvalidLabelColumn, name, maxLabelColumns)
+		}
+
+		header := &storage.BlockHeader{
+			BlockList: make([]storage.BlockAtTime, 0, d.NBlocks()+1),
+		}
+		for _, block := range d.BlockMetadata[0].BlockList {
+			header.AddBlock(block.Timestamp, storage.Block{
+				EncoderType: encoders.EncoderTypeNull,
+			})
+		}
+		d.LabelMetadata[name] = header
+	}
+
+	for _, name := range d.LabelColumns() {
+		labelFile, err := d.LabelColumn(name)
+		if err != nil {
+			return err
+		}
+
+		var blockData []byte
+		if values := labels[name]; hasLabels(values) {
+			dict, err := d.Dictionary(name)
+			if err != nil {
+				return err
+			}
+			blockData = dict.Encode(values)
+		}
+		if err := labelFile.writeBlock(timestamp, blockData); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hasLabels checks if any of the values is set
+func hasLabels(values []string) bool {
+	for _, value := range values {
+		if value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// labelMetadataSize returns the serialized size of the block information of the label columns
+func labelMetadataSize(labelMetadata map[string]*storage.BlockHeader, nBlocks int) (size int) {
+	if nBlocks == 0 {
+		return 0
+	}
+	size = 1 // Number of label columns
+	for name := range labelMetadata {
+		size += 1 + len(name) + // Name of the label column
+			8 + // CurrentOffset
+			nBlocks*9 // BlockList.Len / RawLen / EncoderType
+	}
+	return
+}
+
+func marshalLabelMetadata(labelMetadata map[string]*storage.BlockHeader, data []byte) error {
+	if len(labelMetadata) > maxLabelColumns {
+		return ErrExceedsEncodingSize
+	}
+
+	names := make([]string, 0, len(labelMetadata))
+	for name := range labelMetadata {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	data[0] = uint8(len(names))
+	pos := 1
+	for _, name := range names {
+		data[pos] = uint8(len(name))
+		pos += 1 + copy(data[pos+1:], name)
+
+		binary.BigEndian.PutUint64(data[pos:pos+8], labelMetadata[name].CurrentOffset)
+		pos += 8
+		for _, block := range labelMetadata[name].BlockList {
+			binary.BigEndian.PutUint32(data[pos:pos+4], block.Len)
+			binary.BigEndian.PutUint32(data[pos+4:pos+8], block.RawLen)
+			data[pos+8] = byte(block.EncoderType)
+			pos += 9
+		}
+	}
+
+	return nil
+}
+
+func unmarshalLabelMetadata(m *Metadata, data []byte, blocks []storage.BlockAtTime) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+	if len(data) < 1 {
+		return fmt.Errorf("%w (len: %d)", ErrInputSizeTooSmall, len(data))
+	}
+
+	nColumns := int(data[0])
+	pos := 1
+	for i := 0; i < nColumns; i++ {
+		if len(data) < pos+1 {
+			return fmt.Errorf("%w (len: %d)", ErrInputSizeTooSmall, len(data))
+		}
+		nameLen := int(data[pos])
+		if len(data) < pos+1+nameLen+8+len(blocks)*9 {
+			return fmt.Errorf("%w (len: %d)", ErrInputSizeTooSmall, len(data))
+		}
+		name := string(data[pos+1 : pos+1+nameLen])
+		pos += 1 + nameLen
+
+		header := &storage.BlockHeader{
+			CurrentOffset: binary.BigEndian.Uint64(data[pos : pos+8]),
+			BlockList:     make([]storage.BlockAtTime, len(blocks)),
+		}
+		pos += 8
+		curOffset := uint64(0)
+		for j := range blocks {
+			header.BlockList[j].Timestamp = blocks[j].Timestamp
+			header.BlockList[j].Offset = curOffset
+			header.BlockList[j].Len = binary.BigEndian.Uint32(data[pos : pos+4])
+			header.BlockList[j].RawLen = binary.BigEndian.Uint32(data[pos+4 : pos+8])
+			header.BlockList[j].EncoderType = encoders.Type(data[pos+8])
+			pos += 9
+
+			curOffset += uint64(header.BlockList[j].Len)
+		}
+		m.LabelMetadata[name] = header
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package gpfile
+
+import (
+	"github.com/els0r/goProbe/pkg/defaults"
+	"github.com/els0r/goProbe/pkg/metrics"
+)
+
+const (
+	storageSubsystem = "godb_storage"
+)
+
+var blocksWritten = metrics.NewCounter(metrics.Opts{
+	Namespace: defaults.ServiceName,
+	Subsystem: storageSubsystem,
+	Name:      "blocks_written_total",
+	Help:      "Number of (column) blocks written to the DB",
+})
+var bytesWritten = metrics.NewCounter(metrics.Opts{
+	Namespace: defaults.ServiceName,
+	Subsystem: storageSubsystem,
+	Name:      "bytes_written_total",
+	Help:      "Number of (encoded) bytes written to the DB",
+})
+var preallocationErrors = metrics.NewCounter(metrics.Opts{
+	Namespace: defaults.ServiceName,
+	Subsystem: storageSubsystem,
+	Name:      "preallocation_errors_total",
+	Help:      "Number of failures to preallocate (or release preallocated) disk space for DB files",
+})
+var rawBytesWritten = metrics.NewCounter(metrics.Opts{
+	Namespace: defaults.ServiceName,
+	Subsystem: storageSubsystem,
+	Name:      "raw_bytes_written_total",
+	Help:      "Number of bytes written to the DB prior to encoding",
+})
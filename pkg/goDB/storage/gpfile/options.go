@@ -14,21 +14,46 @@ type Option func(any)
 // optionSetterCommon denotes options that apply to both GPDir and GPFile
 type optionSetterCommon interface {
 	setPermissions(fs.FileMode)
+	setOwner(uid, gid int)
 }
 
 // optionSetterFile denotes options that apply to GPFile only
 type optionSetterFile interface {
 	optionSetterCommon
 	setMemPool(concurrency.MemPoolGCable)
+	setReadAhead(int)
 	setEncoder(encoder.Encoder)
 	setEncoderTypeLevel(encoders.Type, int)
 }
 
-// WithEncoder allows to set the compression implementation
+// optionSetterDir denotes options that apply to GPDir only
+type optionSetterDir interface {
+	optionSetterCommon
+	setWriteConcurrency(int)
+	setSharedEncoder()
+	setDirPermissions(fs.FileMode)
+	setPreallocation(bool)
+}
+
+// WithEncoder allows to set the compression implementation. Since the encoder is shared by
+// all GPFiles of a GPDir, its columns are written sequentially
 func WithEncoder(e encoder.Encoder) Option {
 	return func(o any) {
-		if obj, ok := o.(optionSetterFile); ok {
+		switch obj := o.(type) {
+		case optionSetterFile:
 			obj.setEncoder(e)
+		case optionSetterDir:
+			obj.setSharedEncoder()
+		}
+	}
+}
+
+// WithWriteConcurrency sets the maximum number of columns of a GPDir that are compressed and
+// written in parallel (by default, all columns are written sequentially)
+func WithWriteConcurrency(n int) Option {
+	return func(o any) {
+		if obj, ok := o.(optionSetterDir); ok {
+			obj.setWriteConcurrency(n)
 		}
 	}
 }
@@ -54,6 +79,17 @@ func WithReadAll(pool concurrency.MemPoolGCable) Option {
 	}
 }
 
+// WithReadAhead prefetches the next n blocks while a block is decompressed when reading a file
+// sequentially (if not read into memory at once via WithReadAll()), overlapping disk I/O and
+// decompression. It is most effective on spinning disks (Linux only)
+func WithReadAhead(n int) Option {
+	return func(o any) {
+		if obj, ok := o.(optionSetterFile); ok {
+			obj.setReadAhead(n)
+		}
+	}
+}
+
 // WithPermissions sets a non-default set of permissions / file mode for
 // the file
 func WithPermissions(permissions fs.FileMode) Option {
@@ -63,3 +99,35 @@ func WithPermissions(permissions fs.FileMode) Option {
 		}
 	}
 }
+
+// WithDirPermissions sets the permissions of directories created for a GPDir (by default,
+// they are derived from the file permissions)
+func WithDirPermissions(permissions fs.FileMode) Option {
+	return func(o any) {
+		if obj, ok := o.(optionSetterDir); ok {
+			obj.setDirPermissions(permissions)
+		}
+	}
+}
+
+// WithOwner assigns all files and directories created to the given user / group. A negative
+// UID or GID retains the one of the writing process
+func WithOwner(uid, gid int) Option {
+	return func(o any) {
+		if obj, ok := o.(optionSetterCommon); ok {
+			obj.setOwner(uid, gid)
+		}
+	}
+}
+
+// WithPreallocation reserves the disk space of the column files of a GPDir upon its first write,
+// estimating their final sizes from the preceding days. Subsequent writes are appended to the
+// reserved space, reducing fragmentation and the risk of running out of space mid-writeout. The
+// space not used up is released once the GPDir of the following day is preallocated (Linux only)
+func WithPreallocation(enabled bool) Option {
+	return func(o any) {
+		if obj, ok := o.(optionSetterDir); ok {
+			obj.setPreallocation(enabled)
+		}
+	}
+}
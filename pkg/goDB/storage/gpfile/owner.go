@@ -0,0 +1,33 @@
+package gpfile
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// owner denotes the user / group files and directories are assigned to upon creation
+type owner struct {
+	uid, gid int
+}
+
+// defaultOwner retains the user / group of the writing process
+var defaultOwner = owner{uid: -1, gid: -1}
+
+func (o owner) isSet() bool {
+	return o.uid >= 0 || o.gid >= 0
+}
+
+// chown assigns path to the owner (if set)
+func (o owner) chown(path string) error {
+	if !o.isSet() {
+		return nil
+	}
+	return os.Chown(path, o.uid, o.gid)
+}
+
+// exists determines if anything exists at path
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil || !errors.Is(err, fs.ErrNotExist)
+}
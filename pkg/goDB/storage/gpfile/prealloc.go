@@ -0,0 +1,36 @@
+package gpfile
+
+import (
+	"path/filepath"
+
+	"github.com/els0r/goProbe/pkg/types"
+)
+
+// preallocLookbackDays denotes the number of days preceding a GPDir whose sizes are taken into
+// account when preallocating its column files
+const preallocLookbackDays = 7
+
+// preallocationSizes estimates the final size of each column file of the GPDir from the largest
+// size of the column over the preceding days. The space reserved beyond the end of the column files
+// of these days (i.e. not used up by their writeouts) is released along the way
+func (d *GPDir) preallocationSizes() (sizes [types.ColIdxCount]uint64) {
+	for i := int64(1); i <= preallocLookbackDays; i++ {
+		prev := NewDir(d.basePath, d.dayTimestamp-i*EpochDay, ModeRead)
+		if !exists(prev.MetadataPath()) {
+			continue
+		}
+		if err := prev.Open(); err != nil {
+			continue
+		}
+		for colIdx := types.ColumnIndex(0); colIdx < types.ColIdxCount; colIdx++ {
+			sizes[colIdx] = max(sizes[colIdx], prev.BlockMetadata[colIdx].CurrentOffset)
+
+			// releasing is best-effort, the space is reclaimed upon deletion of the GPDir at the latest
+			if err := releasePreallocated(filepath.Join(prev.Path(), types.ColumnFileNames[colIdx]+FileSuffix)); err != nil {
+				preallocationErrors.Inc()
+			}
+		}
+		_ = prev.Close()
+	}
+	return
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+// +build !linux
+
+package gpfile
+
+import (
+	"errors"
+	"os"
+)
+
+var errPreallocationNotSupported = errors.New("preallocation not supported on this platform")
+
+func preallocate(_ *os.File, _ int64) error {
+	return errPreallocationNotSupported
+}
+
+func releasePreallocated(_ string) error {
+	return nil
+}
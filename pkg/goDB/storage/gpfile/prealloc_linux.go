@@ -0,0 +1,38 @@
+//go:build linux
+// +build linux
+
+package gpfile
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// preallocate reserves disk space for the file up to size bytes without changing its size, so
+// that subsequent appends are written to the reserved space
+func preallocate(f *os.File, size int64) error {
+	return unix.Fallocate(int(f.Fd()), unix.FALLOC_FL_KEEP_SIZE, 0, size)
+}
+
+// releasePreallocated releases the disk space reserved beyond the end of the file (if any). Since
+// the file is truncated to its current size, it must not be written to concurrently
+func releasePreallocated(path string) error {
+	var stat unix.Stat_t
+	if err := unix.Stat(path, &stat); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	// st_blocks is always in units of 512 bytes, hence a file without reserved space occupies
+	// less than one additional file system block
+	if int64(stat.Blocks)*512 < stat.Size+int64(stat.Blksize) {
+		return nil
+	}
+
+	return unix.Truncate(path, stat.Size)
+}
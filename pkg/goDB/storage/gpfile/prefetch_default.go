@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package gpfile
+
+import (
+	"errors"
+	"os"
+)
+
+var errPrefetchNotSupported = errors.New("prefetching not supported on this platform")
+
+func prefetch(_ *os.File, _, _ int64) error {
+	return errPrefetchNotSupported
+}
@@ -0,0 +1,16 @@
+//go:build linux
+// +build linux
+
+package gpfile
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// prefetch asynchronously reads the given range of the file into the page cache, so that it can
+// be read without blocking on disk I/O later on
+func prefetch(f *os.File, offset, length int64) error {
+	return unix.Fadvise(int(f.Fd()), offset, length, unix.FADV_WILLNEED)
+}
@@ -2,14 +2,19 @@ package writeout
 
 import (
 	"context"
+	"errors"
 	"io/fs"
 	"log/slog"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/els0r/goProbe/pkg/capture/capturetypes"
 	"github.com/els0r/goProbe/pkg/goDB"
 	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/types"
 	"github.com/els0r/telemetry/logging"
 )
 
@@ -17,22 +22,50 @@ import (
 type GoDBHandler struct {
 	encoderType encoders.Type
 	permissions fs.FileMode
+	dirPerms    fs.FileMode
+	uid, gid    int
+	preallocate bool
 
 	path        string
-	dbWriters   map[string]*goDB.DBWriter
+	dbWriters   map[string]*dbWriter // keyed by the interface directory (see writerKey())
 	logToSyslog bool
+	streamSink  *StreamSink
+	sampleSink  *SampleSink
+	hooks       *hookRunner
+	mirror      *mirror
+	retries     *retryQueue
+	workers     int
+
+	sync.Mutex
+}
+
+// dbWriter tracks the encoder a DBWriter was created with, so that it can be replaced once the
+// encoder of its interface changes. Writes are serialized per interface, since the interface may
+// be written by a writeout and a retry (or the mirror) at the same time
+type dbWriter struct {
+	*goDB.DBWriter
+	encoder capturetypes.Encoder
+
+	// lastWrite denotes the timestamp of the last writeout of the interface (guarded by the lock
+	// of the handler)
+	lastWrite int64
 
 	sync.Mutex
 }
 
 // NewGoDBHandler instantiates a new GoDB handler
 func NewGoDBHandler(path string, encoderType encoders.Type) *GoDBHandler {
-	return &GoDBHandler{
+	h := &GoDBHandler{
 		path:        path,
-		dbWriters:   make(map[string]*goDB.DBWriter),
+		dbWriters:   make(map[string]*dbWriter),
 		encoderType: encoderType,
 		permissions: goDB.DefaultPermissions,
+		uid:         -1,
+		gid:         -1,
+		workers:     DefaultWorkers,
 	}
+	h.retries = newRetryQueue(h, DefaultMaxPendingWriteouts)
+	return h
 }
 
 // WithSyslogWriting enables / disables explicit writing to Syslog facilities
@@ -41,12 +74,117 @@ func (h *GoDBHandler) WithSyslogWriting(b bool) *GoDBHandler {
 	return h
 }
 
+// WithStreamSink publishes the flows of each interface writeout to a message bus (in addition
+// to writing them to the GoDB)
+func (h *GoDBHandler) WithStreamSink(sink *StreamSink) *GoDBHandler {
+	h.streamSink = sink
+	return h
+}
+
+// WithSampleSink writes the sampled individual flows of each interface writeout (if any) to
+// sample files (in addition to writing the aggregated flows to the GoDB)
+func (h *GoDBHandler) WithSampleSink(sink *SampleSink) *GoDBHandler {
+	h.sampleSink = sink
+	return h
+}
+
+// WithHooks invokes the hooks after each writeout with a summary of the blocks written to the GoDB.
+// Blocks which failed to be written (including the ones written by retries later on) aren't reported.
+// The hooks are invoked asynchronously once the writeout has completed
+func (h *GoDBHandler) WithHooks(hooks ...Hook) *GoDBHandler {
+	if len(hooks) > 0 {
+		h.hooks = newHookRunner(hooks)
+	}
+	return h
+}
+
+// WithMirror mirrors all writeouts to a secondary GoDB at path (e.g. on a network share or a slow
+// disk for archival). Writes to the mirror are performed asynchronously, so that a slow or failing
+// mirror doesn't affect the writeouts to the primary GoDB
+func (h *GoDBHandler) WithMirror(path string) *GoDBHandler {
+	h.mirror = newMirror(NewGoDBHandler(path, h.encoderType).WithPermissions(h.permissions).
+		WithDirPermissions(h.dirPerms).WithOwner(h.uid, h.gid).WithPreallocation(h.preallocate))
+	return h
+}
+
+// WithWriteoutRetries sets the number of failed writeouts per interface that are buffered in memory
+// and retried with backoff (instead of dropping their flows) until the DB becomes writable again. A
+// value <= 0 disables retries
+func (h *GoDBHandler) WithWriteoutRetries(maxPending int) *GoDBHandler {
+	h.retries = nil
+	if maxPending > 0 {
+		h.retries = newRetryQueue(h, maxPending)
+	}
+	return h
+}
+
+// WithWorkers sets the number of interfaces written out concurrently. Increasing it helps if the
+// writeouts of many interfaces lag behind (e.g. on storage with high latency). A value <= 0 resets
+// the number of workers to DefaultWorkers
+func (h *GoDBHandler) WithWorkers(n int) *GoDBHandler {
+	h.workers = DefaultWorkers
+	if n > 0 {
+		h.workers = n
+	}
+	return h
+}
+
 // WithPermissions sets explicit permissions for the underlying GoDB
 func (h *GoDBHandler) WithPermissions(permissions fs.FileMode) *GoDBHandler {
 	h.permissions = permissions
+	if h.mirror != nil {
+		h.mirror.handler.WithPermissions(permissions)
+	}
+	return h
+}
+
+// WithDirPermissions sets explicit permissions for the directories of the underlying GoDB (by
+// default, they are derived from the file permissions)
+func (h *GoDBHandler) WithDirPermissions(permissions fs.FileMode) *GoDBHandler {
+	h.dirPerms = permissions
+	if h.mirror != nil {
+		h.mirror.handler.WithDirPermissions(permissions)
+	}
+	return h
+}
+
+// WithOwner assigns the files / directories created in the underlying GoDB to the given user / group.
+// A negative UID or GID retains the one of the process
+func (h *GoDBHandler) WithOwner(uid, gid int) *GoDBHandler {
+	h.uid, h.gid = uid, gid
+	if h.mirror != nil {
+		h.mirror.handler.WithOwner(uid, gid)
+	}
 	return h
 }
 
+// WithPreallocation reserves the disk space of the files of each daily directory of the underlying GoDB
+// upon its first write, based on the sizes of the preceding days
+func (h *GoDBHandler) WithPreallocation(enabled bool) *GoDBHandler {
+	h.preallocate = enabled
+	if h.mirror != nil {
+		h.mirror.handler.WithPreallocation(enabled)
+	}
+	return h
+}
+
+// Close releases the resources held by the handler, attempting the writeouts pending for retry a final
+// time and publishing the flows pending for the stream sink (if any). Writeout hooks still running are
+// aborted
+func (h *GoDBHandler) Close() error {
+	var err error
+	if h.retries != nil {
+		err = h.retries.close(context.Background())
+	}
+	if h.hooks != nil {
+		h.hooks.close()
+	}
+	if h.streamSink != nil {
+		err = errors.Join(err, h.streamSink.Close())
+	}
+	return err
+}
+
 // HandleWriteout provides access to writeouts to a GoDB via a channel
 func (h *GoDBHandler) HandleWriteout(ctx context.Context, timestamp time.Time, writeoutChan <-chan capturetypes.TaggedAggFlowMap) <-chan struct{} {
 
@@ -66,65 +204,181 @@ func (h *GoDBHandler) HandleWriteout(ctx context.Context, timestamp time.Time, w
 			}
 		}
 
-		seenIfaces := make(map[string]struct{})
-		for taggedMap := range writeoutChan {
-			seenIfaces[taggedMap.Iface] = struct{}{}
-			h.handleIfaceWriteout(ctx, timestamp, taggedMap, syslogWriter)
+		var (
+			taggedMaps []capturetypes.TaggedAggFlowMap
+			blocks     []BlockSummary
+			mapsLock   sync.Mutex
+			wg         sync.WaitGroup
+		)
+		wg.Add(h.workers)
+		for i := 0; i < h.workers; i++ {
+			go func() {
+				defer wg.Done()
+				for taggedMap := range writeoutChan {
+					writeoutQueueDepth.Set(float64(len(writeoutChan)))
+
+					tIface := time.Now()
+					written := h.handleIfaceWriteout(ctx, timestamp, taggedMap, syslogWriter)
+					ifaceWriteoutDuration.ObserveDuration(time.Since(tIface))
+
+					mapsLock.Lock()
+					taggedMaps = append(taggedMaps, taggedMap)
+					if written && h.hooks != nil {
+						blocks = append(blocks, NewBlockSummary(taggedMap))
+					}
+					mapsLock.Unlock()
+				}
+			}()
 		}
+		wg.Wait()
+		writeoutQueueDepth.Set(0)
 
-		// Clean up dead writers. We say that a writer is dead
-		// if it hasn't been used in the last few writeouts.
-		h.Lock()
-		for iface := range h.dbWriters {
-			if _, exists := seenIfaces[iface]; !exists {
-				delete(h.dbWriters, iface)
-			}
+		h.pruneWriters(timestamp)
+
+		if h.mirror != nil {
+			h.mirror.enqueue(ctx, timestamp, taggedMaps)
 		}
-		h.Unlock()
 
 		elapsed := time.Since(t0)
-		writeoutDuration.Observe(float64(elapsed) / float64(time.Second))
+		writeoutDuration.ObserveDuration(elapsed)
 
 		logger.With("elapsed", elapsed.Round(time.Millisecond).String()).Debug("completed writeout")
 		doneChan <- struct{}{}
+
+		// the hooks are only invoked once all blocks were written (and don't delay the writeout)
+		if len(blocks) > 0 {
+			sort.Slice(blocks, func(i, j int) bool {
+				return blocks[i].Iface < blocks[j].Iface
+			})
+			h.hooks.enqueue(ctx, WriteoutSummary{
+				Timestamp: timestamp.Unix(),
+				DBPath:    h.path,
+				Blocks:    blocks,
+			})
+		}
 	}()
 
 	return doneChan
 }
 
-func (h *GoDBHandler) handleIfaceWriteout(ctx context.Context, timestamp time.Time, taggedMap capturetypes.TaggedAggFlowMap, syslogWriter *goDB.SyslogDBWriter) {
-	ctx = logging.WithFields(ctx, slog.String("iface", taggedMap.Iface))
-	logger := logging.FromContext(ctx)
+// writerKey returns the key of the DBWriter of an interface, i.e. the path of the interface
+// directory (which changes if the interface is assigned to a different tenant)
+func (h *GoDBHandler) writerKey(taggedMap capturetypes.TaggedAggFlowMap) string {
+	return filepath.Join(info.TenantPath(h.path, taggedMap.Tenant), taggedMap.Iface)
+}
 
-	// Ensure that there is a DBWriter for the given interface
+// pruneWriters cleans up dead writers. We say that a writer is dead if it hasn't been used
+// for more than a writeout interval before the timestamp. The age is used rather than the presence
+// in the last writeout, since interfaces may be written out separately (e.g. if the writeout is
+// staggered)
+func (h *GoDBHandler) pruneWriters(timestamp time.Time) {
 	h.Lock()
-	if _, exists := h.dbWriters[taggedMap.Iface]; !exists {
-		w := goDB.NewDBWriter(h.path,
-			taggedMap.Iface,
-			h.encoderType,
-		).Permissions(h.permissions)
-		h.dbWriters[taggedMap.Iface] = w
+	for key, w := range h.dbWriters {
+		if timestamp.Unix()-w.lastWrite > goDB.DBWriteInterval {
+			delete(h.dbWriters, key)
+		}
 	}
+	h.Unlock()
+}
 
-	// Write to database, update summary
-	err := h.dbWriters[taggedMap.Iface].Write(taggedMap.Map, taggedMap.Stats, timestamp.Unix())
-	if err != nil {
+// handleIfaceWriteout writes out the flows of an interface and returns whether they were written to the DB
+func (h *GoDBHandler) handleIfaceWriteout(ctx context.Context, timestamp time.Time, taggedMap capturetypes.TaggedAggFlowMap, syslogWriter *goDB.SyslogDBWriter) (written bool) {
+	ctx = logging.WithFields(ctx, slog.String("iface", taggedMap.Iface))
+	logger := logging.FromContext(ctx)
+
+	// Write to database, update summary. If previous writeouts of the interface are still pending
+	// for retry, the writeout is queued behind them in order to preserve the order of the blocks
+	var err error
+	if key := h.writerKey(taggedMap); h.retries != nil && h.retries.hasPending(key) {
+		logger.Warn("previous writeouts are pending, buffering writeout for retry")
+		h.retries.enqueue(ctx, key, timestamp, taggedMap)
+	} else if err = h.writeIface(timestamp, taggedMap); err != nil {
 		logger.Errorf("failed to perform writeout: %s", err)
+		writeoutErrors.Inc()
+		if h.retries != nil {
+			h.retries.enqueue(ctx, key, timestamp, taggedMap)
+		}
+	} else {
+		written = true
 	}
-	h.Unlock()
 
-	// write out flows to syslog if necessary
-	if h.logToSyslog {
+	// publish flows to the message bus if necessary
+	if h.streamSink != nil {
+		if err := h.streamSink.Publish(ctx, timestamp, taggedMap); err != nil {
+			logger.Errorf("failed to publish flows: %s", err)
+			streamErrors.Inc()
+		}
+	}
+
+	// write flow samples if necessary
+	if h.sampleSink != nil {
+		if err := h.sampleSink.Write(timestamp, taggedMap); err != nil {
+			logger.Errorf("failed to write flow samples: %s", err)
+			sampleErrors.Inc()
+		}
+	}
+
+	// write out flows to syslog if necessary (there are none for interfaces whose link is down)
+	if h.logToSyslog && taggedMap.Map != nil {
 		if syslogWriter == nil {
 			logger.Error("cannot write flows to <nil> syslog writer. Attempting reinitialization")
 
 			// try to reinitialize the writer
 			if syslogWriter, err = goDB.NewSyslogDBWriter(); err != nil {
 				logger.Errorf("failed to reinitialize syslog writer: %v", err)
-				return
+				return written
 			}
 		}
 
 		syslogWriter.Write(taggedMap.Map, taggedMap.Iface, timestamp.Unix())
 	}
+	return written
+}
+
+// WriteIface writes the flows of an interface to the DB right away, bypassing the sinks, hooks and
+// retries of a writeout
+func (h *GoDBHandler) WriteIface(timestamp time.Time, taggedMap capturetypes.TaggedAggFlowMap) error {
+	return h.writeIface(timestamp, taggedMap)
+}
+
+// writeIface writes the flows of an interface to the DB
+func (h *GoDBHandler) writeIface(timestamp time.Time, taggedMap capturetypes.TaggedAggFlowMap) error {
+
+	// the interface may override the encoder of the DB
+	encoder := capturetypes.Encoder{Type: h.encoderType}
+	if taggedMap.Encoder != nil {
+		encoder = *taggedMap.Encoder
+	}
+
+	// Ensure that there is a DBWriter for the given interface (within the subtree of its tenant)
+	// using its current encoder
+	key := h.writerKey(taggedMap)
+	h.Lock()
+	w, exists := h.dbWriters[key]
+	if !exists || w.encoder != encoder {
+		w = &dbWriter{
+			DBWriter: goDB.NewDBWriter(info.TenantPath(h.path, taggedMap.Tenant),
+				taggedMap.Iface,
+				encoder.Type,
+			).Permissions(h.permissions).DirPermissions(h.dirPerms).Owner(h.uid, h.gid).
+				EncoderLevel(encoder.Level).Preallocate(h.preallocate),
+			encoder: encoder,
+		}
+		h.dbWriters[key] = w
+	}
+	w.lastWrite = max(w.lastWrite, timestamp.Unix())
+	h.Unlock()
+
+	// the lock of the handler isn't held during the write, so that interfaces can be written
+	// concurrently
+	w.Lock()
+	defer w.Unlock()
+
+	// the flows attributed to local processes are stored along with their process label
+	if taggedMap.Processes != nil {
+		return w.WriteWithLabels(taggedMap.Map, map[string]capturetypes.FlowLabeler{
+			types.ProcessName: taggedMap.Processes,
+		}, taggedMap.Stats, timestamp.Unix())
+	}
+	return w.Write(taggedMap.Map, taggedMap.Stats, timestamp.Unix())
 }
@@ -0,0 +1,205 @@
+package writeout
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/goProbe/pkg/types/hashmap"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+// dbFiles lists the files of the DB at path (relative to it)
+func dbFiles(t *testing.T, path string) []string {
+	var files []string
+	require.Nil(t, filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(path, p)
+		files = append(files, rel)
+		return err
+	}))
+	sort.Strings(files)
+	return files
+}
+
+func TestMirror(t *testing.T) {
+	primary, secondary := t.TempDir(), t.TempDir()
+
+	flows := hashmap.NewAggFlowMap()
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6), hashmap.Val{PacketsRcvd: 1})
+
+	handler := NewGoDBHandler(primary, encoders.EncoderTypeNull).WithMirror(secondary)
+
+	writeoutChan := make(chan capturetypes.TaggedAggFlowMap, 2)
+	writeoutChan <- capturetypes.TaggedAggFlowMap{Map: flows, Iface: "eth0"}
+	writeoutChan <- capturetypes.TaggedAggFlowMap{Map: flows, Iface: "eth1", Tenant: "acme"}
+	close(writeoutChan)
+	<-handler.HandleWriteout(context.Background(), time.Now(), writeoutChan)
+
+	expected := dbFiles(t, primary)
+	require.NotEmpty(t, expected)
+	require.Eventually(t, func() bool {
+		return len(dbFiles(t, secondary)) == len(expected)
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, expected, dbFiles(t, secondary))
+}
+
+func TestSampleSink(t *testing.T) {
+	dbPath := t.TempDir()
+	samplesPath := DefaultSamplesPath(dbPath)
+
+	flows := hashmap.NewAggFlowMap()
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6), hashmap.Val{PacketsRcvd: 1})
+	sample := results.ExtendedRow{
+		Attributes: results.ExtendedAttributes{
+			SrcPort: 55555,
+			Attributes: results.Attributes{
+				SrcIP:   netip.MustParseAddr("10.0.0.1"),
+				DstIP:   netip.MustParseAddr("10.0.0.2"),
+				DstPort: 80,
+				IPProto: 6,
+			},
+		},
+		Counters: types.Counters{PacketsRcvd: 1},
+	}
+
+	handler := NewGoDBHandler(dbPath, encoders.EncoderTypeNull).WithSampleSink(NewSampleSink(samplesPath))
+
+	// samples of consecutive writeouts are appended to the same file
+	timestamp := time.Unix(1700000000, 0)
+	for i := 0; i < 2; i++ {
+		writeoutChan := make(chan capturetypes.TaggedAggFlowMap, 2)
+		writeoutChan <- capturetypes.TaggedAggFlowMap{Map: flows, Iface: "eth0", Samples: []results.ExtendedRow{sample}}
+		writeoutChan <- capturetypes.TaggedAggFlowMap{Map: flows, Iface: "eth1"}
+		close(writeoutChan)
+		<-handler.HandleWriteout(context.Background(), timestamp.Add(time.Duration(i)*5*time.Minute), writeoutChan)
+	}
+
+	require.Equal(t, []string{filepath.Join("eth0", "2023-11-14.jsonl")}, dbFiles(t, samplesPath))
+
+	data, err := os.ReadFile(filepath.Join(samplesPath, "eth0", "2023-11-14.jsonl"))
+	require.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	for i, line := range lines {
+		var row results.ExtendedRow
+		require.Nil(t, jsoniter.UnmarshalFromString(line, &row))
+		require.Equal(t, "eth0", row.Labels.Iface)
+		require.Equal(t, timestamp.Add(time.Duration(i)*5*time.Minute).Unix(), row.Labels.Timestamp.Unix())
+		require.Equal(t, sample.Attributes, row.Attributes)
+		require.Equal(t, sample.Counters, row.Counters)
+	}
+}
+
+func TestWriteoutRetries(t *testing.T) {
+
+	// a regular file at the location of the DB renders the DB path unwritable
+	path := filepath.Join(t.TempDir(), "db")
+	require.Nil(t, os.WriteFile(path, nil, 0600))
+
+	flows := hashmap.NewAggFlowMap()
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6), hashmap.Val{PacketsRcvd: 1})
+
+	handler := NewGoDBHandler(path, encoders.EncoderTypeNull).WithWriteoutRetries(2)
+	handler.retries.initialBackoff, handler.retries.maxBackoff = 10*time.Millisecond, 20*time.Millisecond
+
+	writeout := func(timestamp time.Time) {
+		writeoutChan := make(chan capturetypes.TaggedAggFlowMap, 1)
+		writeoutChan <- capturetypes.TaggedAggFlowMap{Map: flows, Iface: "eth0"}
+		close(writeoutChan)
+		<-handler.HandleWriteout(context.Background(), timestamp, writeoutChan)
+	}
+
+	// the oldest writeout is dropped once the buffer is full
+	dropped := retryDropped.Value()
+	ts := time.Now().Add(-time.Hour)
+	for i := 0; i < 3; i++ {
+		writeout(ts.Add(time.Duration(i) * 5 * time.Minute))
+	}
+	key := filepath.Join(path, "eth0")
+	require.True(t, handler.retries.hasPending(key))
+	require.Equal(t, dropped+1, retryDropped.Value())
+	require.Equal(t, float64(2), retryBufferedWriteouts.Value())
+
+	// once the DB is writable again, the buffered writeouts are written (in order)
+	require.Nil(t, os.Remove(path))
+	require.Eventually(t, func() bool {
+		return !handler.retries.hasPending(key)
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Zero(t, retryBufferedWriteouts.Value())
+	require.NotEmpty(t, dbFiles(t, path))
+
+	writeout(ts.Add(15 * time.Minute))
+	require.False(t, handler.retries.hasPending(key))
+}
+
+func TestWriteoutRetriesClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	require.Nil(t, os.WriteFile(path, nil, 0600))
+
+	flows := hashmap.NewAggFlowMap()
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6), hashmap.Val{PacketsRcvd: 1})
+
+	newHandler := func() *GoDBHandler {
+		handler := NewGoDBHandler(path, encoders.EncoderTypeNull).WithWriteoutRetries(2)
+		handler.retries.initialBackoff = time.Hour
+
+		writeoutChan := make(chan capturetypes.TaggedAggFlowMap, 1)
+		writeoutChan <- capturetypes.TaggedAggFlowMap{Map: flows, Iface: "eth0"}
+		close(writeoutChan)
+		<-handler.HandleWriteout(context.Background(), time.Now().Add(-time.Hour), writeoutChan)
+		require.True(t, handler.retries.hasPending(filepath.Join(path, "eth0")))
+		return handler
+	}
+
+	// writeouts which still fail upon close are reported
+	require.ErrorIs(t, newHandler().Close(), errorPendingWriteouts)
+
+	// pending writeouts are written upon close (without waiting for the next retry)
+	handler := newHandler()
+	require.Nil(t, os.Remove(path))
+	require.Nil(t, handler.Close())
+	require.NotEmpty(t, dbFiles(t, path))
+}
+
+func TestWriteoutWorkers(t *testing.T) {
+	sequential, concurrent := t.TempDir(), t.TempDir()
+
+	flows := hashmap.NewAggFlowMap()
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6), hashmap.Val{PacketsRcvd: 1})
+
+	timestamp := time.Now()
+	writeout := func(handler *GoDBHandler) {
+		writeoutChan := make(chan capturetypes.TaggedAggFlowMap, 8)
+		for i := 0; i < 8; i++ {
+			writeoutChan <- capturetypes.TaggedAggFlowMap{Map: flows, Iface: fmt.Sprintf("eth%d", i)}
+		}
+		close(writeoutChan)
+		<-handler.HandleWriteout(context.Background(), timestamp, writeoutChan)
+	}
+
+	writeout(NewGoDBHandler(sequential, encoders.EncoderTypeNull))
+	concurrentHandler := NewGoDBHandler(concurrent, encoders.EncoderTypeNull).WithWorkers(4)
+	writeout(concurrentHandler)
+
+	// all interfaces are written, regardless of the number of workers
+	expected := dbFiles(t, sequential)
+	require.NotEmpty(t, expected)
+	require.Equal(t, expected, dbFiles(t, concurrent))
+	require.Len(t, concurrentHandler.dbWriters, 8)
+	require.Zero(t, writeoutQueueDepth.Value())
+}
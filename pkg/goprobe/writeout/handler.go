@@ -7,8 +7,13 @@ import (
 	"github.com/els0r/goProbe/pkg/capture/capturetypes"
 )
 
-// WriteoutsChanDepth defines a default depth for sending writeouts over the writeout channel
-const WriteoutsChanDepth = 100
+const (
+	// WriteoutsChanDepth defines a default depth for sending writeouts over the writeout channel
+	WriteoutsChanDepth = 100
+
+	// DefaultWorkers denotes the default number of interfaces written out concurrently
+	DefaultWorkers = 1
+)
 
 // Handler defines a generic interface for handling writeouts
 type Handler interface {
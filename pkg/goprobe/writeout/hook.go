@@ -0,0 +1,281 @@
+package writeout
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/telemetry/logging"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// DefaultHookTimeout denotes the time after which a writeout hook is aborted (if not set explicitly)
+const DefaultHookTimeout = 30 * time.Second
+
+// Hook defines an action invoked after each writeout with the summary of the blocks written, e.g. in
+// order to process them further downstream
+type Hook interface {
+
+	// Run invokes the hook with the JSON serialized summary
+	Run(ctx context.Context, summary []byte) error
+
+	// String returns a human readable description of the hook (used for logging)
+	String() string
+}
+
+// WriteoutSummary denotes the blocks written to the DB upon a writeout. It is passed to the writeout
+// hooks as JSON
+type WriteoutSummary struct {
+	Timestamp int64          `json:"timestamp"` // Timestamp: unix timestamp of the writeout. Example: 1700000000
+	DBPath    string         `json:"db_path"`   // DBPath: path of the DB the blocks were written to. Example: /usr/local/goprobe/db
+	Blocks    []BlockSummary `json:"blocks"`    // Blocks: the blocks written (one per interface)
+}
+
+// BlockSummary denotes the block written for an interface
+type BlockSummary struct {
+	Iface    string         `json:"iface"`            // Iface: interface the flows were captured on. Example: eth0
+	Tenant   string         `json:"tenant,omitempty"` // Tenant: tenant the interface is assigned to (if any). Example: acme
+	Flows    int            `json:"flows"`            // Flows: number of flows in the block. Example: 1024
+	Counters types.Counters `json:"counters"`         // Counters: the total counters of the flows in the block
+	Dropped  uint64         `json:"dropped"`          // Dropped: number of packets dropped during capture. Example: 3
+}
+
+// NewBlockSummary summarizes the block written for an interface
+func NewBlockSummary(taggedMap capturetypes.TaggedAggFlowMap) BlockSummary {
+	block := BlockSummary{
+		Iface:   taggedMap.Iface,
+		Tenant:  taggedMap.Tenant,
+		Dropped: taggedMap.Stats.Dropped,
+	}
+	if taggedMap.Map == nil {
+		return block
+	}
+
+	block.Flows = taggedMap.Map.Len()
+	for i := taggedMap.Map.Iter(); i.Next(); {
+		block.Counters = block.Counters.Add(i.Val())
+	}
+	return block
+}
+
+// ExecHook executes a command with the summary on its standard input
+type ExecHook struct {
+	command []string
+	timeout time.Duration
+}
+
+// NewExecHook instantiates a new hook executing the command (followed by its arguments)
+func NewExecHook(command ...string) *ExecHook {
+	return &ExecHook{
+		command: command,
+		timeout: DefaultHookTimeout,
+	}
+}
+
+// WithTimeout sets the time after which the command is killed
+func (h *ExecHook) WithTimeout(timeout time.Duration) *ExecHook {
+	h.timeout = timeout
+	return h
+}
+
+// Run executes the command
+func (h *ExecHook) Run(ctx context.Context, summary []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, h.command[0], h.command[1:]...) // #nosec G204
+	cmd.Stdin = bytes.NewReader(summary)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// String returns the command of the hook
+func (h *ExecHook) String() string {
+	return "exec " + h.command[0]
+}
+
+// WebhookHook POSTs the summary to a URL
+type WebhookHook struct {
+	url     string
+	headers map[string]string
+	timeout time.Duration
+	client  *http.Client
+}
+
+// NewWebhookHook instantiates a new hook POSTing the summary to the URL
+func NewWebhookHook(webhookURL string) *WebhookHook {
+	return &WebhookHook{
+		url:     webhookURL,
+		timeout: DefaultHookTimeout,
+		client:  http.DefaultClient,
+	}
+}
+
+// WithHeaders sets additional headers sent with the request (e.g. for authentication)
+func (h *WebhookHook) WithHeaders(headers map[string]string) *WebhookHook {
+	h.headers = headers
+	return h
+}
+
+// WithTimeout sets the time after which the request is aborted
+func (h *WebhookHook) WithTimeout(timeout time.Duration) *WebhookHook {
+	h.timeout = timeout
+	return h
+}
+
+// Run POSTs the summary, treating any non-2xx response as failure
+func (h *WebhookHook) Run(ctx context.Context, summary []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(summary))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range h.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// String returns the (redacted) URL of the hook
+func (h *WebhookHook) String() string {
+	if u, err := url.Parse(h.url); err == nil {
+		return "webhook " + u.Redacted()
+	}
+	return "webhook"
+}
+
+// runHooks invokes all hooks (concurrently) with the summary of the writeout
+func runHooks(ctx context.Context, hooks []Hook, summary WriteoutSummary) []error {
+	msg, err := jsoniter.Marshal(summary)
+	if err != nil {
+		return []error{fmt.Errorf("failed to serialize writeout summary: %w", err)}
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(hooks))
+	wg.Add(len(hooks))
+	for i, hook := range hooks {
+		go func(i int, hook Hook) {
+			defer wg.Done()
+			if err := hook.Run(ctx, msg); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", hook, err)
+			}
+		}(i, hook)
+	}
+	wg.Wait()
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	return failed
+}
+
+// hookQueueDepth denotes the number of writeouts whose hooks may be pending. The hooks of further
+// writeouts are skipped until the pending ones have completed
+const hookQueueDepth = 4
+
+type hookInvocation struct {
+	ctx     context.Context
+	summary WriteoutSummary
+}
+
+// hookRunner asynchronously invokes the hooks after each writeout, so that slow hooks don't delay
+// the completion of the writeout. Hooks still running upon shutdown are aborted
+type hookRunner struct {
+	hooks []Hook
+	queue chan hookInvocation
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newHookRunner(hooks []Hook) *hookRunner {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &hookRunner{
+		hooks:  hooks,
+		queue:  make(chan hookInvocation, hookQueueDepth),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go r.run()
+
+	return r
+}
+
+// enqueue schedules the invocation of the hooks without blocking
+func (r *hookRunner) enqueue(ctx context.Context, summary WriteoutSummary) {
+	select {
+	case <-r.ctx.Done():
+		return
+	default:
+	}
+
+	select {
+	case r.queue <- hookInvocation{ctx: context.WithoutCancel(ctx), summary: summary}:
+	default:
+		logging.FromContext(ctx).Error("writeout hooks are lagging behind, skipping writeout")
+		hooksSkipped.Inc()
+	}
+}
+
+func (r *hookRunner) run() {
+	defer close(r.done)
+	for {
+		select {
+		case inv := <-r.queue:
+			for _, err := range runHooks(r.ctx, r.hooks, inv.summary) {
+				logging.FromContext(inv.ctx).Errorf("failed to run writeout hook: %s", err)
+				hookErrors.Inc()
+			}
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+// close aborts the running hooks and skips the pending ones
+func (r *hookRunner) close() {
+	r.cancel()
+	<-r.done
+
+	if n := len(r.queue); n > 0 {
+		logging.Logger().Warnf("skipped writeout hooks of %d pending writeout(s) upon shutdown", n)
+		hooksSkipped.Add(float64(n))
+	}
+}
@@ -0,0 +1,122 @@
+package writeout
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/goProbe/pkg/types/hashmap"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteoutHooks(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	execOut := filepath.Join(t.TempDir(), "summary.json")
+
+	flows := hashmap.NewAggFlowMap()
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6), hashmap.Val{PacketsRcvd: 1, BytesRcvd: 100})
+	flows.SecondaryMap.Set(types.NewV6KeyStatic([16]byte{0xfe, 0x80, 15: 1}, [16]byte{0xfe, 0x80, 15: 2}, []byte{1, 187}, 6), hashmap.Val{PacketsSent: 2, BytesSent: 200})
+
+	dbPath := t.TempDir()
+	handler := NewGoDBHandler(dbPath, encoders.EncoderTypeNull).WithHooks(
+		NewWebhookHook(srv.URL).WithHeaders(map[string]string{"Authorization": "Bearer token"}),
+		NewExecHook("sh", "-c", "cat > "+execOut),
+		NewExecHook("sh", "-c", "echo failure >&2; exit 1"),
+	)
+
+	timestamp := time.Unix(1700000000, 0)
+	writeoutChan := make(chan capturetypes.TaggedAggFlowMap, 2)
+	writeoutChan <- capturetypes.TaggedAggFlowMap{Map: flows, Iface: "eth1", Tenant: "acme", Stats: capturetypes.CaptureStats{Dropped: 3}}
+	writeoutChan <- capturetypes.TaggedAggFlowMap{Map: flows, Iface: "eth0"}
+	close(writeoutChan)
+	<-handler.HandleWriteout(context.Background(), timestamp, writeoutChan)
+	defer handler.Close()
+
+	expected := WriteoutSummary{
+		Timestamp: timestamp.Unix(),
+		DBPath:    dbPath,
+		Blocks: []BlockSummary{
+			{Iface: "eth0", Flows: 2, Counters: types.Counters{PacketsRcvd: 1, BytesRcvd: 100, PacketsSent: 2, BytesSent: 200}},
+			{Iface: "eth1", Tenant: "acme", Flows: 2, Counters: types.Counters{PacketsRcvd: 1, BytesRcvd: 100, PacketsSent: 2, BytesSent: 200}, Dropped: 3},
+		},
+	}
+
+	// all (successful) hooks received the summary (after the writeout completed), regardless of the
+	// failing one
+	var (
+		summary WriteoutSummary
+		req     *http.Request
+	)
+	select {
+	case req = <-requests:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for webhook")
+	}
+	require.Nil(t, jsoniter.Unmarshal(<-bodies, &summary))
+	require.Equal(t, expected, summary)
+	require.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	require.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+
+	require.Eventually(t, func() bool {
+		data, err := os.ReadFile(execOut)
+		return err == nil && jsoniter.Unmarshal(data, &summary) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, expected, summary)
+}
+
+func TestWriteoutHooksAsync(t *testing.T) {
+	handler := NewGoDBHandler(t.TempDir(), encoders.EncoderTypeNull).WithHooks(
+		NewExecHook("sleep", "10").WithTimeout(time.Minute),
+	)
+
+	flows := hashmap.NewAggFlowMap()
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6), hashmap.Val{PacketsRcvd: 1, BytesRcvd: 100})
+
+	// slow hooks don't delay the writeouts, the hooks of writeouts exceeding the queue are skipped
+	t0 := time.Now()
+	for i := 0; i < hookQueueDepth+2; i++ {
+		writeoutChan := make(chan capturetypes.TaggedAggFlowMap, 1)
+		writeoutChan <- capturetypes.TaggedAggFlowMap{Map: flows, Iface: "eth0"}
+		close(writeoutChan)
+		<-handler.HandleWriteout(context.Background(), time.Unix(1700000000+int64(i)*300, 0), writeoutChan)
+	}
+
+	// running hooks are aborted upon shutdown
+	require.Nil(t, handler.Close())
+	require.Less(t, time.Since(t0), 5*time.Second)
+}
+
+func TestHookErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	errs := runHooks(context.Background(), []Hook{
+		NewWebhookHook(srv.URL),
+		NewExecHook("sh", "-c", "echo failure >&2; exit 1"),
+		NewExecHook("sleep", "5").WithTimeout(10 * time.Millisecond),
+		NewExecHook("true"),
+	}, WriteoutSummary{})
+	require.Len(t, errs, 3)
+	require.ErrorContains(t, errs[0], "503")
+	require.ErrorContains(t, errs[1], "failure")
+	require.ErrorContains(t, errs[2], "killed")
+}
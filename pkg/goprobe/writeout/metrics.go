@@ -1,25 +1,115 @@
 package writeout
 
 import (
-	"github.com/els0r/goProbe/cmd/goProbe/config"
-	"github.com/prometheus/client_golang/prometheus"
+	"github.com/els0r/goProbe/pkg/defaults"
+	"github.com/els0r/goProbe/pkg/metrics"
 )
 
 const (
 	writeoutSubsystem = "godb_handler"
 )
 
-var writeoutDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
-	Namespace: config.ServiceName,
-	Subsystem: writeoutSubsystem,
-	Name:      "writeout_duration_seconds",
-	Help:      "Total flow data writeout time, aggregated across all interfaces written to DB",
+var writeoutDuration = metrics.NewHistogram(metrics.HistogramOpts{
+	Opts: metrics.Opts{
+		Namespace: defaults.ServiceName,
+		Subsystem: writeoutSubsystem,
+		Name:      "writeout_duration_seconds",
+		Help:      "Total flow data writeout time, aggregated across all interfaces written to DB",
+	},
 	// these buckets should capture disks of various speed and setups with many interfaces
 	Buckets: []float64{0.025, 0.05, 0.1, 0.25, 0.5, 1, 5, 10, 30, 60},
 })
 
-func init() {
-	prometheus.MustRegister(
-		writeoutDuration,
-	)
-}
+var ifaceWriteoutDuration = metrics.NewHistogram(metrics.HistogramOpts{
+	Opts: metrics.Opts{
+		Namespace: defaults.ServiceName,
+		Subsystem: writeoutSubsystem,
+		Name:      "iface_writeout_duration_seconds",
+		Help:      "Flow data writeout time of individual interfaces",
+	},
+	Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 5, 10},
+})
+
+var writeoutQueueDepth = metrics.NewGauge(metrics.Opts{
+	Namespace: defaults.ServiceName,
+	Subsystem: writeoutSubsystem,
+	Name:      "writeout_queue_depth",
+	Help:      "Number of rotated interfaces waiting to be written out by the handler",
+})
+
+var writeoutErrors = metrics.NewCounter(metrics.Opts{
+	Namespace: defaults.ServiceName,
+	Subsystem: writeoutSubsystem,
+	Name:      "writeout_errors_total",
+	Help:      "Number of failed interface writeouts to the DB",
+})
+
+var streamErrors = metrics.NewCounter(metrics.Opts{
+	Namespace: defaults.ServiceName,
+	Subsystem: writeoutSubsystem,
+	Name:      "stream_errors_total",
+	Help:      "Number of interface writeouts which failed to be published to the message bus",
+})
+
+var hookErrors = metrics.NewCounter(metrics.Opts{
+	Namespace: defaults.ServiceName,
+	Subsystem: writeoutSubsystem,
+	Name:      "hook_errors_total",
+	Help:      "Number of writeout hook invocations which failed",
+})
+
+var hooksSkipped = metrics.NewCounter(metrics.Opts{
+	Namespace: defaults.ServiceName,
+	Subsystem: writeoutSubsystem,
+	Name:      "hooks_skipped_total",
+	Help:      "Number of writeouts whose hooks were skipped since the hooks were lagging behind (or upon shutdown)",
+})
+
+var sampleErrors = metrics.NewCounter(metrics.Opts{
+	Namespace: defaults.ServiceName,
+	Subsystem: writeoutSubsystem,
+	Name:      "sample_errors_total",
+	Help:      "Number of interface writeouts whose flow samples failed to be written",
+})
+
+var mirrorErrors = metrics.NewCounter(metrics.Opts{
+	Namespace: defaults.ServiceName,
+	Subsystem: writeoutSubsystem,
+	Name:      "mirror_writeout_errors_total",
+	Help:      "Number of failed interface writeouts to the mirror DB",
+})
+
+var mirrorDropped = metrics.NewCounter(metrics.Opts{
+	Namespace: defaults.ServiceName,
+	Subsystem: writeoutSubsystem,
+	Name:      "mirror_writeouts_dropped_total",
+	Help:      "Number of writeouts not mirrored since the mirror DB was lagging behind",
+})
+
+var retryBufferedWriteouts = metrics.NewGauge(metrics.Opts{
+	Namespace: defaults.ServiceName,
+	Subsystem: writeoutSubsystem,
+	Name:      "retry_buffered_writeouts",
+	Help:      "Number of failed interface writeouts currently buffered for retry",
+})
+
+var retryBufferedFlows = metrics.NewGauge(metrics.Opts{
+	Namespace: defaults.ServiceName,
+	Subsystem: writeoutSubsystem,
+	Name:      "retry_buffered_flows",
+	Help:      "Number of flows contained in the interface writeouts currently buffered for retry",
+})
+
+var retriedWriteouts = metrics.NewCounter(metrics.Opts{
+	Namespace: defaults.ServiceName,
+	Subsystem: writeoutSubsystem,
+	Name:      "retried_writeouts_total",
+	Help:      "Number of buffered interface writeouts which were successfully written to the DB upon retry",
+})
+
+var retryDropped = metrics.NewCounter(metrics.Opts{
+	Namespace: defaults.ServiceName,
+	Subsystem: writeoutSubsystem,
+	Name:      "retry_writeouts_dropped_total",
+	Help:      "Number of failed interface writeouts dropped since the retry buffer was full",
+})
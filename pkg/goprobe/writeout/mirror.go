@@ -0,0 +1,65 @@
+package writeout
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/telemetry/logging"
+)
+
+// mirrorQueueDepth denotes the number of writeouts that may be pending for the mirror. Further
+// writeouts are dropped (for the mirror only) until it has caught up
+const mirrorQueueDepth = 16
+
+type mirrorWriteout struct {
+	ctx        context.Context
+	timestamp  time.Time
+	taggedMaps []capturetypes.TaggedAggFlowMap
+}
+
+// mirror asynchronously replicates the writeouts of a GoDBHandler to a secondary GoDB
+type mirror struct {
+	handler  *GoDBHandler
+	writeout chan mirrorWriteout
+}
+
+func newMirror(handler *GoDBHandler) *mirror {
+	m := &mirror{
+		handler:  handler,
+		writeout: make(chan mirrorWriteout, mirrorQueueDepth),
+	}
+	go m.run()
+
+	return m
+}
+
+// enqueue schedules the writeout for the mirror without blocking
+func (m *mirror) enqueue(ctx context.Context, timestamp time.Time, taggedMaps []capturetypes.TaggedAggFlowMap) {
+	select {
+	case m.writeout <- mirrorWriteout{
+		ctx:        context.WithoutCancel(ctx),
+		timestamp:  timestamp,
+		taggedMaps: taggedMaps,
+	}:
+	default:
+		logging.FromContext(ctx).With("path", m.handler.path).Error("mirror is lagging behind, dropping writeout")
+		mirrorDropped.Inc()
+	}
+}
+
+func (m *mirror) run() {
+	for w := range m.writeout {
+		for _, taggedMap := range w.taggedMaps {
+			if err := m.handler.writeIface(w.timestamp, taggedMap); err != nil {
+				logging.FromContext(w.ctx).With(
+					"path", m.handler.path,
+					slog.String("iface", taggedMap.Iface),
+				).Errorf("failed to perform mirror writeout: %s", err)
+				mirrorErrors.Inc()
+			}
+		}
+		m.handler.pruneWriters(w.timestamp)
+	}
+}
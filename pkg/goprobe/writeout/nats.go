@@ -0,0 +1,267 @@
+package writeout
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/els0r/telemetry/logging"
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	defaultNATSPort  = "4222"
+	natsWriteTimeout = 5 * time.Second
+
+	// natsQueueDepth denotes the number of messages that may be pending for publication. Further
+	// messages are dropped until the publisher has caught up
+	natsQueueDepth = 64
+
+	// defaultNATSMaxPayload denotes the default message size limit of NATS servers, which is assumed
+	// until the server announces its limit
+	defaultNATSMaxPayload = 1 << 20
+
+	// the client doesn't request acknowledgements, hence the server only sends INFO, PING and -ERR messages
+	natsConnect = `CONNECT {"verbose":false,"pedantic":false,"name":"goprobe","lang":"go"}` + "\r\n"
+)
+
+var (
+	errorNATSHandshake    = errors.New("unexpected handshake from NATS server")
+	errorNATSQueueFull    = errors.New("NATS publisher is lagging behind, dropping message")
+	errorNATSClosed       = errors.New("NATS publisher is closed")
+	errorNATSMaxPayload   = errors.New("message exceeds maximum payload of NATS server")
+	errorNATSServerReport = errors.New("NATS server reported error")
+)
+
+type natsMessage struct {
+	ctx     context.Context
+	subject string
+	payload []byte
+}
+
+// NATSPublisher publishes messages to a NATS server using the core NATS protocol (i.e. with at
+// most once delivery). Messages are published asynchronously, so that a slow or unreachable server
+// doesn't stall the writeout. The connection is established lazily and re-established on failure
+type NATSPublisher struct {
+	addr string
+
+	queue      chan natsMessage
+	stop, done chan struct{}
+	closeOnce  sync.Once
+	maxPayload atomic.Int64
+
+	conn net.Conn
+	w    *bufio.Writer
+
+	sync.Mutex
+}
+
+// NewNATSPublisher instantiates a new publisher for the NATS server at addr (host:port or
+// nats://host:port, using the default port 4222 if none is provided)
+func NewNATSPublisher(addr string) *NATSPublisher {
+	addr = strings.TrimPrefix(addr, "nats://")
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, defaultNATSPort)
+	}
+	p := &NATSPublisher{
+		addr:  addr,
+		queue: make(chan natsMessage, natsQueueDepth),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	p.maxPayload.Store(defaultNATSMaxPayload)
+	go p.run()
+
+	return p
+}
+
+// MaxPayload returns the maximum size of a message accepted by the NATS server
+func (p *NATSPublisher) MaxPayload() int {
+	return int(p.maxPayload.Load())
+}
+
+// Publish queues the message for publication on the subject without blocking. Errors occurring
+// during its publication are logged
+func (p *NATSPublisher) Publish(ctx context.Context, subject string, msg []byte) error {
+	select {
+	case <-p.stop:
+		return errorNATSClosed
+	default:
+	}
+
+	select {
+	case p.queue <- natsMessage{ctx: context.WithoutCancel(ctx), subject: subject, payload: msg}:
+		return nil
+	default:
+		return fmt.Errorf("%w (%s)", errorNATSQueueFull, p.addr)
+	}
+}
+
+// Close publishes all pending messages (as far as possible within a few seconds) and terminates
+// the connection to the NATS server
+func (p *NATSPublisher) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.stop)
+	})
+	<-p.done
+
+	p.Lock()
+	defer p.Unlock()
+
+	if p.conn == nil {
+		return nil
+	}
+	_ = p.conn.SetWriteDeadline(time.Now().Add(natsWriteTimeout))
+	err := p.w.Flush()
+	p.closeConn()
+	return err
+}
+
+// run publishes the queued messages until the publisher is closed
+func (p *NATSPublisher) run() {
+	defer close(p.done)
+
+	for {
+		select {
+		case msg := <-p.queue:
+			p.publish(msg)
+		case <-p.stop:
+
+			// pending messages are published unless the server is unreachable
+			deadline := time.Now().Add(natsWriteTimeout)
+			for {
+				select {
+				case msg := <-p.queue:
+					if time.Now().Before(deadline) {
+						p.publish(msg)
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (p *NATSPublisher) publish(msg natsMessage) {
+	if err := p.send(msg); err != nil {
+		logging.FromContext(msg.ctx).With("subject", msg.subject).Errorf("failed to publish flows: %s", err)
+		streamErrors.Inc()
+	}
+}
+
+// send publishes a message, connecting to the server if necessary
+func (p *NATSPublisher) send(msg natsMessage) error {
+	if maxPayload := p.MaxPayload(); len(msg.payload) > maxPayload {
+		return fmt.Errorf("%w (%d > %d bytes)", errorNATSMaxPayload, len(msg.payload), maxPayload)
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if p.conn == nil {
+		if err := p.connect(); err != nil {
+			return fmt.Errorf("failed to connect to NATS server %s: %w", p.addr, err)
+		}
+	}
+
+	_ = p.conn.SetWriteDeadline(time.Now().Add(natsWriteTimeout))
+	fmt.Fprintf(p.w, "PUB %s %d\r\n", msg.subject, len(msg.payload))
+	_, _ = p.w.Write(msg.payload)
+	_, _ = p.w.WriteString("\r\n")
+	if err := p.w.Flush(); err != nil {
+		p.closeConn()
+		return fmt.Errorf("failed to publish to NATS server %s: %w", p.addr, err)
+	}
+	return nil
+}
+
+func (p *NATSPublisher) connect() error {
+	deadline := time.Now().Add(natsWriteTimeout)
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.Dial("tcp", p.addr)
+	if err != nil {
+		return err
+	}
+
+	// the server greets with an INFO message announcing (among others) its message size limit
+	r := bufio.NewReader(conn)
+	_ = conn.SetDeadline(deadline)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		_ = conn.Close()
+		return fmt.Errorf("%w: %q", errorNATSHandshake, strings.TrimSpace(line))
+	}
+	p.updateInfo(line)
+	if _, err = conn.Write([]byte(natsConnect)); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	p.conn, p.w = conn, bufio.NewWriter(conn)
+	go p.readLoop(conn, r)
+
+	return nil
+}
+
+// updateInfo applies the message size limit announced by an INFO message of the server
+func (p *NATSPublisher) updateInfo(line string) {
+	var info struct {
+		MaxPayload int64 `json:"max_payload"`
+	}
+	if err := jsoniter.UnmarshalFromString(strings.TrimSpace(strings.TrimPrefix(line, "INFO ")), &info); err == nil && info.MaxPayload > 0 {
+		p.maxPayload.Store(info.MaxPayload)
+	}
+}
+
+// readLoop answers the keepalives of the server and reports its errors until the connection fails
+func (p *NATSPublisher) readLoop(conn net.Conn, r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			break
+		}
+
+		switch {
+		case strings.HasPrefix(line, "PING"):
+			p.Lock()
+			if p.conn == conn {
+				_ = conn.SetWriteDeadline(time.Now().Add(natsWriteTimeout))
+				_, _ = p.w.WriteString("PONG\r\n")
+				_ = p.w.Flush()
+			}
+			p.Unlock()
+		case strings.HasPrefix(line, "INFO "):
+			p.updateInfo(line)
+		case strings.HasPrefix(line, "-ERR"):
+
+			// most errors cause the server to close the connection, which is then re-established
+			// upon the next message
+			logging.Logger().With("addr", p.addr).Errorf("%s: %s", errorNATSServerReport,
+				strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'"))
+			streamErrors.Inc()
+		}
+	}
+
+	p.Lock()
+	if p.conn == conn {
+		p.closeConn()
+	}
+	p.Unlock()
+}
+
+func (p *NATSPublisher) closeConn() {
+	_ = p.conn.Close()
+	p.conn, p.w = nil, nil
+}
@@ -0,0 +1,204 @@
+package writeout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/telemetry/logging"
+)
+
+// DefaultMaxPendingWriteouts denotes the default number of failed writeouts per interface that are
+// buffered for retry (i.e. one hour of data at the default writeout interval)
+const DefaultMaxPendingWriteouts = 12
+
+const (
+	retryInitialBackoff = 5 * time.Second
+	retryMaxBackoff     = 5 * time.Minute
+)
+
+var errorPendingWriteouts = errors.New("failed to write buffered writeouts")
+
+type pendingWriteout struct {
+	timestamp time.Time
+	taggedMap capturetypes.TaggedAggFlowMap
+}
+
+// retryQueue buffers the writeouts of a GoDBHandler that failed (e.g. due to a full disk or an
+// unavailable network share) in memory and retries them with exponential backoff. Writeouts
+// of an interface are always written in order, hence any writeout of an interface with pending
+// writeouts is queued as well
+type retryQueue struct {
+	handler    *GoDBHandler
+	maxPending int
+
+	initialBackoff, maxBackoff time.Duration
+
+	pending map[string][]pendingWriteout // keyed by the interface directory (see writerKey())
+	running bool
+	closed  bool
+
+	// flushLock serializes the flushes of the background retries and the final one upon close
+	flushLock sync.Mutex
+
+	sync.Mutex
+}
+
+func newRetryQueue(handler *GoDBHandler, maxPending int) *retryQueue {
+	return &retryQueue{
+		handler:        handler,
+		maxPending:     maxPending,
+		initialBackoff: retryInitialBackoff,
+		maxBackoff:     retryMaxBackoff,
+		pending:        make(map[string][]pendingWriteout),
+	}
+}
+
+// hasPending determines if there are buffered writeouts for the interface directory
+func (q *retryQueue) hasPending(key string) bool {
+	q.Lock()
+	defer q.Unlock()
+
+	return len(q.pending[key]) > 0
+}
+
+// enqueue buffers the writeout for retry. If the buffer of the interface is full, its oldest
+// writeout is dropped
+func (q *retryQueue) enqueue(ctx context.Context, key string, timestamp time.Time, taggedMap capturetypes.TaggedAggFlowMap) {
+	q.Lock()
+	defer q.Unlock()
+
+	pending := append(q.pending[key], pendingWriteout{
+		timestamp: timestamp,
+		taggedMap: taggedMap,
+	})
+	if len(pending) > q.maxPending {
+		logging.FromContext(ctx).With("oldest", pending[0].timestamp).Error("writeout retry buffer is full, dropping oldest writeout")
+		retryDropped.Inc()
+		pending = pending[1:]
+	}
+	q.pending[key] = pending
+	q.updateOccupancy()
+
+	if !q.running && !q.closed {
+		q.running = true
+		go q.run(context.WithoutCancel(ctx))
+	}
+}
+
+// run retries all buffered writeouts until the buffer is empty (or the queue is closed)
+func (q *retryQueue) run(ctx context.Context) {
+	backoff := q.initialBackoff
+	for {
+		time.Sleep(backoff)
+
+		q.Lock()
+		closed := q.closed
+		q.Unlock()
+		if closed || q.flush(ctx) {
+			return
+		}
+		backoff = min(2*backoff, q.maxBackoff)
+	}
+}
+
+// close attempts to write all buffered writeouts a final time (e.g. upon shutdown), so that they
+// aren't lost if the DB has become writable again in the meantime. The writeouts which still fail
+// are dropped and reported
+func (q *retryQueue) close(ctx context.Context) error {
+	q.Lock()
+	q.closed = true
+	q.Unlock()
+
+	if q.flush(ctx) {
+		return nil
+	}
+
+	q.Lock()
+	defer q.Unlock()
+
+	var numWriteouts int
+	for _, pending := range q.pending {
+		numWriteouts += len(pending)
+	}
+	retryDropped.Add(float64(numWriteouts))
+	err := fmt.Errorf("%w: dropped %d writeouts of %d interfaces", errorPendingWriteouts, numWriteouts, len(q.pending))
+
+	clear(q.pending)
+	q.updateOccupancy()
+	return err
+}
+
+// flush attempts to write all buffered writeouts (in order per interface) and reports whether the
+// buffer is empty afterwards
+func (q *retryQueue) flush(ctx context.Context) bool {
+	q.flushLock.Lock()
+	defer q.flushLock.Unlock()
+
+	q.Lock()
+	keys := make([]string, 0, len(q.pending))
+	for key := range q.pending {
+		keys = append(keys, key)
+	}
+	q.Unlock()
+
+	for _, key := range keys {
+		for {
+			q.Lock()
+			if len(q.pending[key]) == 0 {
+				q.Unlock()
+				break
+			}
+			w := q.pending[key][0]
+			q.Unlock()
+
+			logger := logging.FromContext(ctx).With(slog.String("iface", w.taggedMap.Iface), "timestamp", w.timestamp)
+			if err := q.handler.writeIface(w.timestamp, w.taggedMap); err != nil {
+				logger.Warnf("failed to retry writeout: %s", err)
+				break
+			}
+			logger.Info("completed retried writeout")
+			retriedWriteouts.Inc()
+
+			// the writeout may have been dropped from a full buffer in the meantime
+			q.Lock()
+			if pending := q.pending[key]; len(pending) > 0 && pending[0].timestamp.Equal(w.timestamp) {
+				q.pending[key] = pending[1:]
+			}
+			q.updateOccupancy()
+			q.Unlock()
+		}
+	}
+
+	q.Lock()
+	defer q.Unlock()
+	for key, pending := range q.pending {
+		if len(pending) == 0 {
+			delete(q.pending, key)
+		}
+	}
+	if len(q.pending) == 0 {
+		q.running = false
+		return true
+	}
+	return false
+}
+
+// updateOccupancy exposes the number of buffered writeouts / flows. The caller must hold the lock
+func (q *retryQueue) updateOccupancy() {
+	var numWriteouts, numFlows int
+	for _, pending := range q.pending {
+		numWriteouts += len(pending)
+		for _, w := range pending {
+			if w.taggedMap.Map != nil && !w.taggedMap.Map.IsNil() {
+				numFlows += w.taggedMap.Map.Len()
+			}
+		}
+	}
+	retryBufferedWriteouts.Set(float64(numWriteouts))
+	retryBufferedFlows.Set(float64(numFlows))
+}
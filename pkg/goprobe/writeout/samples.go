@@ -0,0 +1,88 @@
+package writeout
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/goProbe/pkg/goDB"
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	samplesFileSuffix     = ".jsonl"
+	samplesFileDateFormat = "2006-01-02"
+)
+
+// DefaultSamplesPath returns the directory the flow samples of the DB at dbPath are written to by
+// default (next to the DB)
+func DefaultSamplesPath(dbPath string) string {
+	return filepath.Clean(dbPath) + ".samples"
+}
+
+// SampleSink appends the sampled individual flows of each writeout (per interface) to a daily file
+// in JSON lines format, located at <path>/[<tenant>/]<iface>/<YYYY-MM-DD>.jsonl. Each line holds a
+// single flow (including its source port), labeled with the writeout timestamp and interface
+type SampleSink struct {
+	path        string
+	permissions fs.FileMode
+}
+
+// NewSampleSink instantiates a new sink writing flow samples to files below path
+func NewSampleSink(path string) *SampleSink {
+	return &SampleSink{
+		path:        path,
+		permissions: goDB.DefaultPermissions,
+	}
+}
+
+// WithPermissions sets explicit permissions for the sample files (directories are created with the
+// corresponding execute permissions)
+func (s *SampleSink) WithPermissions(permissions fs.FileMode) *SampleSink {
+	s.permissions = permissions
+	return s
+}
+
+// Path returns the file the samples of an interface written out at timestamp are appended to
+func (s *SampleSink) Path(timestamp time.Time, iface, tenant string) string {
+	return filepath.Join(info.TenantPath(s.path, tenant), iface, timestamp.UTC().Format(samplesFileDateFormat)+samplesFileSuffix)
+}
+
+// Write appends the flow samples of the interface to its current sample file
+func (s *SampleSink) Write(timestamp time.Time, taggedMap capturetypes.TaggedAggFlowMap) (err error) {
+	if len(taggedMap.Samples) == 0 {
+		return nil
+	}
+
+	path := s.Path(timestamp, taggedMap.Iface, taggedMap.Tenant)
+	if err := os.MkdirAll(filepath.Dir(path), s.permissions|(s.permissions&0444)>>2); err != nil {
+		return fmt.Errorf("failed to create sample directory: %w", err)
+	}
+
+	// #nosec G304
+	f, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_APPEND|os.O_WRONLY, s.permissions)
+	if err != nil {
+		return fmt.Errorf("failed to open sample file: %w", err)
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	w := bufio.NewWriter(f)
+	enc := jsoniter.NewEncoder(w)
+	for _, row := range taggedMap.Samples {
+		row.Labels.Timestamp = timestamp
+		row.Labels.Iface = taggedMap.Iface
+		if err := enc.Encode(row); err != nil {
+			return fmt.Errorf("failed to serialize flow sample: %w", err)
+		}
+	}
+	return w.Flush()
+}
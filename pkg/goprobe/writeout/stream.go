@@ -0,0 +1,125 @@
+package writeout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/types"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Publisher defines a generic interface for publishing messages to a message bus (e.g. NATS or Kafka)
+type Publisher interface {
+
+	// Publish sends the message on the subject / topic
+	Publish(ctx context.Context, subject string, msg []byte) error
+
+	// Close terminates the connection to the message bus
+	Close() error
+}
+
+// payloadLimiter is implemented by publishers whose message bus limits the size of messages
+type payloadLimiter interface {
+
+	// MaxPayload returns the maximum size of a message (in bytes)
+	MaxPayload() int
+}
+
+// FlowBatch denotes the flows of an interface written out at a given time. It is the message
+// published to streams for each interface and writeout. If the message bus limits the size of
+// messages, the flows may be split across multiple batches (with the same timestamp)
+type FlowBatch struct {
+	Timestamp int64  `json:"timestamp"`        // Timestamp: unix timestamp of the writeout. Example: 1700000000
+	Iface     string `json:"iface"`            // Iface: interface the flows were captured on. Example: eth0
+	Tenant    string `json:"tenant,omitempty"` // Tenant: tenant the interface is assigned to (if any). Example: acme
+
+	Flows []results.Row `json:"flows"` // Flows: the flows observed since the previous writeout
+}
+
+// StreamSink publishes the flows of each writeout (per interface) to a message bus
+type StreamSink struct {
+	publisher Publisher
+	subject   string
+}
+
+// NewStreamSink instantiates a new sink publishing the flow batches via the publisher. The batch of
+// an interface is published on the subject "<subject>.<iface>"
+func NewStreamSink(publisher Publisher, subject string) *StreamSink {
+	return &StreamSink{
+		publisher: publisher,
+		subject:   subject,
+	}
+}
+
+// Publish serializes the flows of the interface and publishes them
+func (s *StreamSink) Publish(ctx context.Context, timestamp time.Time, taggedMap capturetypes.TaggedAggFlowMap) error {
+	return s.publish(ctx, s.subject+"."+taggedMap.Iface, NewFlowBatch(timestamp, taggedMap))
+}
+
+// publish serializes the batch and publishes it, splitting it into chunks if it exceeds the
+// message size limit of the publisher
+func (s *StreamSink) publish(ctx context.Context, subject string, batch FlowBatch) error {
+	msg, err := jsoniter.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to serialize flows: %w", err)
+	}
+
+	limiter, ok := s.publisher.(payloadLimiter)
+	if !ok || len(msg) <= limiter.MaxPayload() || len(batch.Flows) <= 1 {
+		return s.publisher.Publish(ctx, subject, msg)
+	}
+
+	// the flows are split evenly, chunks which still exceed the limit are split further
+	numChunks := len(msg)/limiter.MaxPayload() + 1
+	chunkSize := (len(batch.Flows) + numChunks - 1) / numChunks
+	flows := batch.Flows
+
+	var errs []error
+	for start := 0; start < len(flows); start += chunkSize {
+		batch.Flows = flows[start:min(start+chunkSize, len(flows))]
+		if err := s.publish(ctx, subject, batch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes the underlying publisher
+func (s *StreamSink) Close() error {
+	return s.publisher.Close()
+}
+
+// NewFlowBatch creates the batch of flows written out for an interface
+func NewFlowBatch(timestamp time.Time, taggedMap capturetypes.TaggedAggFlowMap) FlowBatch {
+	batch := FlowBatch{
+		Timestamp: timestamp.Unix(),
+		Iface:     taggedMap.Iface,
+		Tenant:    taggedMap.Tenant,
+		Flows:     make([]results.Row, 0),
+	}
+	if taggedMap.Map == nil {
+		return batch
+	}
+
+	batch.Flows = make([]results.Row, 0, taggedMap.Map.Len())
+	for i := taggedMap.Map.Iter(); i.Next(); {
+		key := types.Key(i.Key())
+		sip, _ := netip.AddrFromSlice(key.GetSIP())
+		dip, _ := netip.AddrFromSlice(key.GetDIP())
+		batch.Flows = append(batch.Flows, results.Row{
+			Attributes: results.Attributes{
+				SrcIP:   sip,
+				DstIP:   dip,
+				IPProto: key.GetProto(),
+				DstPort: types.PortToUint16(key.GetDport()),
+			},
+			Counters: i.Val(),
+		})
+	}
+	return batch
+}
@@ -0,0 +1,177 @@
+package writeout
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/goProbe/pkg/goDB/encoder/encoders"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/goProbe/pkg/types/hashmap"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+type natsMsg struct {
+	subject string
+	payload []byte
+}
+
+// fakeNATSServer accepts a single connection and forwards all published messages to the channel
+func fakeNATSServer(t *testing.T, maxPayload int) (string, <-chan natsMsg) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	msgs := make(chan natsMsg, 100)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		_, _ = fmt.Fprintf(conn, "INFO {\"server_id\":\"test\",\"max_payload\":%d}\r\nPING\r\n", maxPayload)
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			var (
+				subject string
+				size    int
+			)
+			if _, err := fmt.Sscanf(line, "PUB %s %d\r\n", &subject, &size); err != nil {
+				continue
+			}
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			if size > maxPayload {
+				_, _ = fmt.Fprint(conn, "-ERR 'Maximum Payload Violation'\r\n")
+				return
+			}
+			msgs <- natsMsg{subject: subject, payload: payload[:size]}
+		}
+	}()
+
+	return listener.Addr().String(), msgs
+}
+
+func TestStreamSink(t *testing.T) {
+	addr, msgs := fakeNATSServer(t, 1<<20)
+
+	flows := hashmap.NewAggFlowMap()
+	flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, []byte{0, 80}, 6), hashmap.Val{PacketsRcvd: 1, BytesRcvd: 100})
+	flows.SecondaryMap.Set(types.NewV6KeyStatic([16]byte{0xfe, 0x80, 15: 1}, [16]byte{0xfe, 0x80, 15: 2}, []byte{1, 187}, 17), hashmap.Val{PacketsSent: 2})
+
+	sink := NewStreamSink(NewNATSPublisher("nats://"+addr), "goprobe.flows")
+	handler := NewGoDBHandler(t.TempDir(), encoders.EncoderTypeNull).WithStreamSink(sink)
+
+	timestamp := time.Unix(1700000000, 0)
+	require.Nil(t, sink.Publish(context.Background(), timestamp, capturetypes.TaggedAggFlowMap{
+		Map:    flows,
+		Iface:  "eth0",
+		Tenant: "acme",
+	}))
+
+	// closing the handler publishes all pending messages
+	require.Nil(t, handler.Close())
+	require.ErrorIs(t, sink.Publish(context.Background(), timestamp, capturetypes.TaggedAggFlowMap{Iface: "eth0"}), errorNATSClosed)
+
+	var msg natsMsg
+	select {
+	case msg = <-msgs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for published message")
+	}
+	require.Equal(t, "goprobe.flows.eth0", msg.subject)
+
+	var batch FlowBatch
+	require.Nil(t, jsoniter.Unmarshal(msg.payload, &batch))
+	require.Equal(t, int64(1700000000), batch.Timestamp)
+	require.Equal(t, "eth0", batch.Iface)
+	require.Equal(t, "acme", batch.Tenant)
+	require.Len(t, batch.Flows, 2)
+
+	byProto := make(map[uint8]string)
+	for _, flow := range batch.Flows {
+		byProto[flow.Attributes.IPProto] = fmt.Sprintf("%s,%s,%d,%d,%d",
+			flow.Attributes.SrcIP, flow.Attributes.DstIP, flow.Attributes.DstPort,
+			flow.Counters.SumPackets(), flow.Counters.SumBytes())
+	}
+	require.Equal(t, map[uint8]string{
+		6:  "10.0.0.1,10.0.0.2,80,1,100",
+		17: "fe80::1,fe80::2,443,2,0",
+	}, byProto)
+}
+
+func TestStreamSinkChunking(t *testing.T) {
+	const maxPayload = 1024
+	addr, msgs := fakeNATSServer(t, maxPayload)
+
+	flows := hashmap.NewAggFlowMap()
+	for i := 0; i < 100; i++ {
+		flows.PrimaryMap.Set(types.NewV4KeyStatic([4]byte{10, 0, 0, byte(i)}, [4]byte{10, 0, 0, 255}, []byte{0, 80}, 6), hashmap.Val{PacketsRcvd: 1})
+	}
+
+	publisher := NewNATSPublisher(addr)
+	sink := NewStreamSink(publisher, "goprobe.flows")
+	defer sink.Close()
+
+	// the message size limit is learned upon connecting, hence the first message only serves to connect
+	require.Nil(t, publisher.send(natsMessage{ctx: context.Background(), subject: "goprobe.flows.eth0", payload: []byte("{}")}))
+	<-msgs
+	require.Equal(t, maxPayload, publisher.MaxPayload())
+
+	require.Nil(t, sink.Publish(context.Background(), time.Unix(1700000000, 0), capturetypes.TaggedAggFlowMap{Map: flows, Iface: "eth0"}))
+	require.Nil(t, sink.Close())
+
+	var numFlows, numMsgs int
+	for numFlows < 100 {
+		var msg natsMsg
+		select {
+		case msg = <-msgs:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for published messages")
+		}
+		require.LessOrEqual(t, len(msg.payload), maxPayload)
+
+		var batch FlowBatch
+		require.Nil(t, jsoniter.Unmarshal(msg.payload, &batch))
+		require.Equal(t, int64(1700000000), batch.Timestamp)
+		numFlows += len(batch.Flows)
+		numMsgs++
+	}
+	require.Equal(t, 100, numFlows)
+	require.Greater(t, numMsgs, 1)
+}
+
+func TestNATSPublisherUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	addr := listener.Addr().String()
+	require.Nil(t, listener.Close())
+
+	publisher := NewNATSPublisher(addr)
+	defer publisher.Close()
+
+	// messages are published asynchronously, failures are logged
+	require.Nil(t, publisher.Publish(context.Background(), "goprobe.flows.eth0", []byte("{}")))
+
+	err = publisher.send(natsMessage{ctx: context.Background(), subject: "goprobe.flows.eth0", payload: []byte("{}")})
+	require.NotNil(t, err)
+	require.True(t, strings.Contains(err.Error(), addr))
+
+	// messages exceeding the limit of the server aren't sent
+	err = publisher.send(natsMessage{ctx: context.Background(), subject: "goprobe.flows.eth0", payload: make([]byte, defaultNATSMaxPayload+1)})
+	require.ErrorIs(t, err, errorNATSMaxPayload)
+}
@@ -0,0 +1,347 @@
+package logging
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	backupTimeFormat = "20060102T150405.000"
+	compressedSuffix = ".gz"
+
+	logFilePermissions = 0644
+)
+
+var errorEmptyLogFilePath = errors.New("empty log file path provided")
+
+// RotatingFileWriter writes log lines to a file, rotating it once it exceeds a maximum size and / or
+// age. Rotated files are renamed to <path>.<timestamp> and can optionally be compressed. Only the
+// most recent backups are retained (if configured). It is safe for concurrent use
+type RotatingFileWriter struct {
+	sync.Mutex
+
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	compress   bool
+
+	file     *os.File
+	size     int64
+	openedAt time.Time
+
+	// compression and cleanup of rotated files happen in the background (one at a time)
+	postRotateLock sync.Mutex
+	wg             sync.WaitGroup
+
+	now func() time.Time
+}
+
+// RotationOption configures the rotation of a log file
+type RotationOption func(*RotatingFileWriter)
+
+// WithMaxSize rotates the file before it would exceed maxSize bytes (0 disables size based rotation)
+func WithMaxSize(maxSize int64) RotationOption {
+	return func(w *RotatingFileWriter) {
+		w.maxSize = maxSize
+	}
+}
+
+// WithMaxAge rotates the file once it has been written to for longer than maxAge (0 disables age
+// based rotation). The age is measured from the point in time the file was opened by the writer
+func WithMaxAge(maxAge time.Duration) RotationOption {
+	return func(w *RotatingFileWriter) {
+		w.maxAge = maxAge
+	}
+}
+
+// WithMaxBackups limits the number of rotated files that are retained (0 retains all of them)
+func WithMaxBackups(maxBackups int) RotationOption {
+	return func(w *RotatingFileWriter) {
+		w.maxBackups = maxBackups
+	}
+}
+
+// WithCompression enables gzip compression of rotated files
+func WithCompression(enabled bool) RotationOption {
+	return func(w *RotatingFileWriter) {
+		w.compress = enabled
+	}
+}
+
+// NewRotatingFileWriter opens (or creates) the log file at path for appending
+func NewRotatingFileWriter(path string, opts ...RotationOption) (*RotatingFileWriter, error) {
+	if path == "" {
+		return nil, errorEmptyLogFilePath
+	}
+
+	w := &RotatingFileWriter{
+		path: filepath.Clean(path),
+		now:  time.Now,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *RotatingFileWriter) open() error {
+	f, size, err := openLogFile(w.path)
+	if err != nil {
+		return err
+	}
+
+	w.file, w.size, w.openedAt = f, size, w.now()
+	return nil
+}
+
+func openLogFile(path string) (*os.File, int64, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, logFilePermissions) // #nosec G302
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open log file: %w", err)
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, 0, fmt.Errorf("failed to stat log file: %w", err)
+	}
+	return f, stat.Size(), nil
+}
+
+// Write implements the io.Writer interface. If writing p would exceed the maximum size (or the
+// maximum age of the file has been reached), the file is rotated first. If the rotation fails, p is
+// written to the current file nonetheless (and the failure is reported on stderr)
+func (w *RotatingFileWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	// a file is never rotated while empty, even if a single write exceeds the maximum size
+	if w.size > 0 &&
+		((w.maxSize > 0 && w.size+int64(len(p)) > w.maxSize) ||
+			(w.maxAge > 0 && w.now().Sub(w.openedAt) >= w.maxAge)) {
+		if err := w.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to rotate log file %s: %v\n", w.path, err)
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate forces a rotation of the log file (e.g. upon receiving SIGHUP)
+func (w *RotatingFileWriter) Rotate() error {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.file == nil {
+		return os.ErrClosed
+	}
+	return w.rotate()
+}
+
+// rotate moves the log file to a backup and opens a new one. The current file remains open until
+// the new one has been opened, hence it can still be written to if the rotation fails
+func (w *RotatingFileWriter) rotate() error {
+	backup := w.backupName()
+	if err := os.Rename(w.path, backup); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	f, size, err := openLogFile(w.path)
+	if err != nil {
+		// move the current file back in place so that it keeps being written to under its path
+		if rerr := os.Rename(backup, w.path); rerr != nil {
+			return errors.Join(err, fmt.Errorf("failed to restore log file: %w", rerr))
+		}
+		return err
+	}
+
+	if err := w.file.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to close rotated log file %s: %v\n", backup, err)
+	}
+	w.file, w.size, w.openedAt = f, size, w.now()
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.postRotate(backup)
+	}()
+
+	return nil
+}
+
+// backupName returns a unique name for the next backup file
+func (w *RotatingFileWriter) backupName() string {
+	name := w.path + "." + w.now().Format(backupTimeFormat)
+	candidate := name
+	for i := 1; ; i++ {
+		_, errPlain := os.Stat(candidate)
+		_, errCompressed := os.Stat(candidate + compressedSuffix)
+		if os.IsNotExist(errPlain) && os.IsNotExist(errCompressed) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%d", name, i)
+	}
+}
+
+// postRotate compresses the rotated file (if enabled) and removes excess backups. Errors are
+// reported on stderr since the log output itself is the one failing
+func (w *RotatingFileWriter) postRotate(backup string) {
+	w.postRotateLock.Lock()
+	defer w.postRotateLock.Unlock()
+
+	if w.compress {
+		if err := compressFile(backup); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to compress rotated log file %s: %v\n", backup, err)
+		}
+	}
+	if w.maxBackups > 0 {
+		if err := w.removeExcessBackups(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to remove rotated log files: %v\n", err)
+		}
+	}
+}
+
+// Backups returns the paths of all rotated files, oldest first
+func (w *RotatingFileWriter) Backups() ([]string, error) {
+	dir, base := filepath.Split(w.path)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), base+".") {
+			continue
+		}
+
+		// only consider files carrying a backup timestamp
+		ts := strings.TrimSuffix(strings.TrimPrefix(entry.Name(), base+"."), compressedSuffix)
+		if len(ts) < len(backupTimeFormat) {
+			continue
+		}
+		if _, err := time.Parse(backupTimeFormat, ts[:len(backupTimeFormat)]); err != nil {
+			continue
+		}
+		backups = append(backups, filepath.Join(dir, entry.Name()))
+	}
+
+	// the timestamp format sorts lexicographically, the compression suffix is irrelevant
+	sort.Slice(backups, func(i, j int) bool {
+		return strings.TrimSuffix(backups[i], compressedSuffix) < strings.TrimSuffix(backups[j], compressedSuffix)
+	})
+	return backups, nil
+}
+
+func (w *RotatingFileWriter) removeExcessBackups() error {
+	backups, err := w.Backups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > w.maxBackups {
+		if err := os.Remove(backups[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// compressFile replaces path with a gzip compressed copy (path.gz)
+func compressFile(path string) (err error) {
+	src, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := src.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	dstPath := path + compressedSuffix
+	dst, err := os.OpenFile(filepath.Clean(dstPath), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, logFilePermissions) // #nosec G302
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err == nil {
+		err = gz.Close()
+	}
+	if cerr := dst.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(dstPath)
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// Close closes the log file and waits for any pending compression / cleanup to finish
+func (w *RotatingFileWriter) Close() error {
+	w.Lock()
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.Unlock()
+
+	w.wg.Wait()
+	return err
+}
+
+// OpenDestination opens the writer for a log destination. Apart from a file path, the destination
+// can be one of stdout / stderr / devnull (case insensitive). The caller must close the writer once
+// done logging (which is a no-op for the standard streams)
+func OpenDestination(path string) (io.WriteCloser, error) {
+	switch strings.ToLower(path) {
+	case "stdout":
+		return nopCloser{os.Stdout}, nil
+	case "stderr":
+		return nopCloser{os.Stderr}, nil
+	case "devnull":
+		return nopCloser{io.Discard}, nil
+	case "":
+		return nil, errorEmptyLogFilePath
+	}
+
+	// #nosec G302
+	f, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_WRONLY|os.O_APPEND, logFilePermissions)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// nopCloser retains the underlying writer (e.g. stdout) upon closing
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,136 @@
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFileWriterSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goprobe.log")
+
+	w, err := NewRotatingFileWriter(path, WithMaxSize(10), WithMaxBackups(2))
+	require.Nil(t, err)
+
+	// advance the clock for every rotation in order to obtain distinct backup names
+	ts := time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC)
+	w.now = func() time.Time {
+		ts = ts.Add(time.Second)
+		return ts
+	}
+
+	for _, line := range []string{"line 1\n", "line 2\n", "line 3\n", "line 4\n", "a line exceeding the limit\n"} {
+		_, err := w.Write([]byte(line))
+		require.Nil(t, err)
+	}
+	require.Nil(t, w.Close())
+
+	_, err = w.Write([]byte("closed\n"))
+	require.ErrorIs(t, err, os.ErrClosed)
+
+	content, err := os.ReadFile(path)
+	require.Nil(t, err)
+	require.Equal(t, "a line exceeding the limit\n", string(content))
+
+	// only the two most recent backups are retained
+	backups, err := w.Backups()
+	require.Nil(t, err)
+	require.Len(t, backups, 2)
+	for i, expected := range []string{"line 3\n", "line 4\n"} {
+		content, err := os.ReadFile(backups[i])
+		require.Nil(t, err)
+		require.Equal(t, expected, string(content))
+	}
+}
+
+func TestRotatingFileWriterAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goprobe.log")
+
+	// pre-existing content is appended to
+	require.Nil(t, os.WriteFile(path, []byte("existing\n"), 0600))
+
+	ts := time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC)
+	w, err := NewRotatingFileWriter(path, WithMaxAge(time.Hour), WithCompression(true))
+	require.Nil(t, err)
+	w.now = func() time.Time { return ts }
+	w.openedAt = ts
+
+	_, err = w.Write([]byte("first\n"))
+	require.Nil(t, err)
+
+	ts = ts.Add(time.Hour)
+	_, err = w.Write([]byte("second\n"))
+	require.Nil(t, err)
+
+	// another rotation at the same point in time must not overwrite the previous backup
+	require.Nil(t, w.Rotate())
+	require.Nil(t, w.Close())
+
+	backups, err := w.Backups()
+	require.Nil(t, err)
+	require.Len(t, backups, 2)
+	require.Equal(t, path+".20230901T010000.000.gz", backups[0])
+	require.Equal(t, path+".20230901T010000.000-1.gz", backups[1])
+
+	for i, expected := range []string{"existing\nfirst\n", "second\n"} {
+		f, err := os.Open(backups[i])
+		require.Nil(t, err)
+		gz, err := gzip.NewReader(f)
+		require.Nil(t, err)
+		content, err := io.ReadAll(gz)
+		require.Nil(t, err)
+		require.Nil(t, f.Close())
+		require.Equal(t, expected, string(content))
+	}
+
+	content, err := os.ReadFile(path)
+	require.Nil(t, err)
+	require.Empty(t, strings.TrimSpace(string(content)))
+}
+
+func TestRotatingFileWriterRotationFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goprobe.log")
+
+	w, err := NewRotatingFileWriter(path)
+	require.Nil(t, err)
+	_, err = w.Write([]byte("first\n"))
+	require.Nil(t, err)
+
+	// the rotation fails since the file vanished, but the current file remains open
+	f := w.file
+	require.Nil(t, os.Remove(path))
+	require.NotNil(t, w.Rotate())
+	require.Equal(t, f, w.file)
+
+	_, err = w.Write([]byte("second\n"))
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+}
+
+func TestRotatingFileWriterEmptyPath(t *testing.T) {
+	_, err := NewRotatingFileWriter("")
+	require.ErrorIs(t, err, errorEmptyLogFilePath)
+}
+
+func TestRecentLines(t *testing.T) {
+	r := NewRecentLines(3)
+	require.Empty(t, r.Lines())
+
+	_, err := r.Write([]byte("a\n"))
+	require.Nil(t, err)
+	require.Equal(t, []string{"a"}, r.Lines())
+
+	_, err = r.Write([]byte("b\nc\nd\n"))
+	require.Nil(t, err)
+	require.Equal(t, []string{"b", "c", "d"}, r.Lines())
+
+	_, err = r.Write([]byte("e\n"))
+	require.Nil(t, err)
+	require.Equal(t, []string{"c", "d", "e"}, r.Lines())
+}
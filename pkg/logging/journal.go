@@ -0,0 +1,152 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultJournalSocket denotes the socket of the systemd journal (native protocol)
+	DefaultJournalSocket = "/run/systemd/journal/socket"
+
+	maxJournalFieldLen = 64
+)
+
+// JournalWriter sends JSON encoded log lines to the systemd journal using its native protocol,
+// mapping the structured fields of each log line onto (upper case) journal fields. It is safe for
+// concurrent use
+type JournalWriter struct {
+	sync.Mutex
+
+	conn       *net.UnixConn
+	addr       *net.UnixAddr
+	identifier string
+}
+
+// JournalOption configures the journal writer
+type JournalOption func(*JournalWriter)
+
+// WithJournalIdentifier sets the SYSLOG_IDENTIFIER of all entries (default: the name of the binary)
+func WithJournalIdentifier(identifier string) JournalOption {
+	return func(w *JournalWriter) {
+		w.identifier = identifier
+	}
+}
+
+// WithJournalSocket overrides the path of the journal socket
+func WithJournalSocket(path string) JournalOption {
+	return func(w *JournalWriter) {
+		w.addr = &net.UnixAddr{Name: path, Net: "unixgram"}
+	}
+}
+
+// NewJournalWriter creates a new writer sending log entries to the systemd journal
+func NewJournalWriter(opts ...JournalOption) (*JournalWriter, error) {
+	w := &JournalWriter{
+		addr:       &net.UnixAddr{Name: DefaultJournalSocket, Net: "unixgram"},
+		identifier: sanitizeHeader(os.Args[0]),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	// check that the journal is available upfront instead of failing on the first log line
+	if _, err := os.Stat(w.addr.Name); err != nil {
+		return nil, fmt.Errorf("systemd journal not available: %w", err)
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create journal socket: %w", err)
+	}
+	w.conn = conn
+
+	return w, nil
+}
+
+// Write implements the io.Writer interface. Each line in p is sent as a separate journal entry
+func (w *JournalWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	for _, line := range bytes.Split(p, []byte{'\n'}) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		rec, err := parseRecord(line)
+		if err != nil {
+			rec = &record{severity: SeverityNotice, msg: string(line)}
+		}
+		if _, err := w.conn.WriteToUnix(w.format(rec), w.addr); err != nil {
+			return 0, fmt.Errorf("failed to write to systemd journal: %w", err)
+		}
+	}
+	return len(p), nil
+}
+
+// format renders the record as a journal entry (native protocol)
+func (w *JournalWriter) format(rec *record) []byte {
+	var buf bytes.Buffer
+
+	writeJournalField(&buf, "MESSAGE", rec.msg)
+	writeJournalField(&buf, "PRIORITY", strconv.Itoa(int(rec.severity)))
+	writeJournalField(&buf, "SYSLOG_IDENTIFIER", w.identifier)
+	for _, f := range rec.fields {
+		writeJournalField(&buf, journalFieldName(f.key), f.value)
+	}
+	return buf.Bytes()
+}
+
+// writeJournalField appends a single field. Values containing newlines have to be serialized
+// using the binary (length prefixed) representation
+func writeJournalField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	if !strings.ContainsRune(value, '\n') {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+
+	buf.WriteByte('\n')
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journalFieldName converts a key into a valid journal field name, which may only consist of
+// upper case letters, digits and underscores, must not start with an underscore or digit and
+// is limited in length
+func journalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		}
+		return '_'
+	}, key)
+
+	if name == "" || name[0] == '_' || (name[0] >= '0' && name[0] <= '9') {
+		name = "F_" + name
+	}
+	if len(name) > maxJournalFieldLen {
+		name = name[:maxJournalFieldLen]
+	}
+	return name
+}
+
+// Close closes the journal socket
+func (w *JournalWriter) Close() error {
+	w.Lock()
+	defer w.Unlock()
+
+	return w.conn.Close()
+}
@@ -0,0 +1,132 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/els0r/telemetry/logging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRecord(t *testing.T) {
+	rec, err := parseRecord([]byte(`{"ts":"2023-09-01T12:00:00.123456Z","level":"warn","msg":"writeout failed","iface":"eth0","req":{"method":"GET","code":200},"ok":true}` + "\n"))
+	require.Nil(t, err)
+
+	require.Equal(t, time.Date(2023, 9, 1, 12, 0, 0, 123456000, time.UTC), rec.time)
+	require.Equal(t, SeverityWarning, rec.severity)
+	require.Equal(t, "writeout failed", rec.msg)
+	require.Equal(t, []field{
+		{"iface", "eth0"},
+		{"ok", "true"},
+		{"req.code", "200"},
+		{"req.method", "GET"},
+	}, rec.fields)
+
+	_, err = parseRecord([]byte(" \n"))
+	require.ErrorIs(t, err, errorEmptyRecord)
+	_, err = parseRecord([]byte("level=info msg=test"))
+	require.NotNil(t, err)
+}
+
+func TestParseFacility(t *testing.T) {
+	f, err := ParseFacility("LOCAL3")
+	require.Nil(t, err)
+	require.Equal(t, FacilityLocal3, f)
+
+	_, err = ParseFacility("kern")
+	require.ErrorIs(t, err, errorUnknownFacility)
+}
+
+// listen creates a datagram socket receiving the messages of the writers under test
+func listen(t *testing.T) (*net.UnixConn, string) {
+	// the path of a unix socket is limited in length, hence t.TempDir() isn't used
+	dir, err := os.MkdirTemp("", "gplog")
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.Nil(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn, path
+}
+
+func receive(t *testing.T, conn *net.UnixConn) []byte {
+	buf := make([]byte, 65536)
+	require.Nil(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, err := conn.Read(buf)
+	require.Nil(t, err)
+	return buf[:n]
+}
+
+func TestSyslogWriter(t *testing.T) {
+	conn, path := listen(t)
+
+	_, err := NewSyslogWriter(WithSyslogAddress("ftp", path))
+	require.ErrorIs(t, err, errorUnsupportedNetwork)
+
+	w, err := NewSyslogWriter(
+		WithSyslogAddress("unixgram", path),
+		WithSyslogFacility(FacilityLocal0),
+		WithSyslogAppName("goprobe"),
+	)
+	require.Nil(t, err)
+	defer w.Close()
+	w.hostname, w.procID = "host", "42"
+
+	_, err = w.Write([]byte(`{"ts":"2023-09-01T12:00:00Z","level":"error","msg":"failed","iface":"eth0","err":"quote \" and ] bracket"}` + "\n"))
+	require.Nil(t, err)
+	require.Equal(t,
+		`<131>1 2023-09-01T12:00:00.000000Z host goprobe 42 - [fields@32473 err="quote \" and \] bracket" iface="eth0"] failed`,
+		string(receive(t, conn)),
+	)
+
+	// lines without fields or which can't be decoded
+	_, err = w.Write([]byte(`{"level":"info","msg":"no fields"}` + "\n" + "plain line\n"))
+	require.Nil(t, err)
+	require.Regexp(t, `^<134>1 \S+ host goprobe 42 - - no fields$`, string(receive(t, conn)))
+	require.Equal(t, `<133>1 - host goprobe 42 - - plain line`, string(receive(t, conn)))
+}
+
+func TestJournalWriter(t *testing.T) {
+	conn, path := listen(t)
+
+	_, err := NewJournalWriter(WithJournalSocket(filepath.Join(filepath.Dir(path), "missing")))
+	require.NotNil(t, err)
+
+	w, err := NewJournalWriter(WithJournalSocket(path), WithJournalIdentifier("goprobe"))
+	require.Nil(t, err)
+	defer w.Close()
+
+	// use the actual logger in order to make sure that its output is mapped as expected
+	logger, err := logging.New(logging.LevelInfo, logging.EncodingJSON, logging.WithOutput(w))
+	require.Nil(t, err)
+
+	logger.With("iface", "eth0", "1st", 1).Info("multi\nline")
+
+	expected := bytes.NewBufferString("MESSAGE\n")
+	require.Nil(t, binary.Write(expected, binary.LittleEndian, uint64(len("multi\nline"))))
+	expected.WriteString("multi\nline\nPRIORITY=6\nSYSLOG_IDENTIFIER=goprobe\nF_1ST=1\nIFACE=eth0\n")
+	require.Equal(t, expected.String(), string(receive(t, conn)))
+}
+
+func TestJournalFieldName(t *testing.T) {
+	var tests = []struct {
+		in, expected string
+	}{
+		{"iface", "IFACE"},
+		{"req.method", "REQ_METHOD"},
+		{"_hidden", "F__HIDDEN"},
+		{"", "F_"},
+		{string(bytes.Repeat([]byte("a"), 100)), string(bytes.Repeat([]byte("A"), maxJournalFieldLen))},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.expected, journalFieldName(test.in))
+	}
+}
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"bytes"
+	"sync"
+)
+
+// DefaultRecentLines denotes the default number of log lines retained by a RecentLines buffer
+const DefaultRecentLines = 256
+
+// RecentLines retains the most recent log lines written to it in memory (e.g. to include the
+// latest errors in a support bundle). It is safe for concurrent use
+type RecentLines struct {
+	sync.Mutex
+
+	lines []string
+	next  int
+	full  bool
+}
+
+// NewRecentLines creates a buffer retaining the last n log lines. If n isn't positive,
+// DefaultRecentLines is used
+func NewRecentLines(n int) *RecentLines {
+	if n <= 0 {
+		n = DefaultRecentLines
+	}
+	return &RecentLines{
+		lines: make([]string, n),
+	}
+}
+
+// Write implements the io.Writer interface. Each write is expected to hold one or more
+// complete log lines (as written by the logger)
+func (r *RecentLines) Write(p []byte) (int, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+		r.lines[r.next] = string(line)
+		r.next = (r.next + 1) % len(r.lines)
+		if r.next == 0 {
+			r.full = true
+		}
+	}
+	return len(p), nil
+}
+
+// Lines returns the retained log lines, oldest first
+func (r *RecentLines) Lines() []string {
+	r.Lock()
+	defer r.Unlock()
+
+	if !r.full {
+		return append([]string(nil), r.lines[:r.next]...)
+	}
+	return append(append([]string(nil), r.lines[r.next:]...), r.lines[:r.next]...)
+}
@@ -0,0 +1,146 @@
+// Package logging provides additional log sinks (syslog, systemd journal, rotating files) for the
+// structured logger from github.com/els0r/telemetry/logging. Since the logger only supports plain
+// io.Writer outputs, the syslog and journal sinks consume JSON encoded log lines and map their
+// structured fields onto the respective native representation (RFC5424 structured data / journal
+// fields)
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Destinations (in addition to files / stdout / stderr) selectable for log output
+const (
+	DestinationSyslog  = "syslog"
+	DestinationJournal = "journald"
+)
+
+// keys emitted by the logger for the "built-in" fields of a log line
+const (
+	keyTime    = "ts"
+	keyLevel   = "level"
+	keyMessage = "msg"
+)
+
+var errorEmptyRecord = errors.New("empty log record")
+
+// Severity denotes a syslog / journal message severity (priority) as defined in RFC5424
+type Severity int
+
+// Severity levels as defined in RFC5424
+const (
+	SeverityEmergency Severity = iota
+	SeverityAlert
+	SeverityCritical
+	SeverityError
+	SeverityWarning
+	SeverityNotice
+	SeverityInformational
+	SeverityDebug
+)
+
+// severityFromLevel maps the level names of the logger onto a severity
+func severityFromLevel(level string) Severity {
+	switch strings.ToLower(level) {
+	case "debug":
+		return SeverityDebug
+	case "info":
+		return SeverityInformational
+	case "warn":
+		return SeverityWarning
+	case "error":
+		return SeverityError
+	case "fatal":
+		return SeverityCritical
+	case "panic":
+		return SeverityAlert
+	}
+	return SeverityNotice
+}
+
+type field struct {
+	key   string
+	value string
+}
+
+// record denotes a single (decoded) log line
+type record struct {
+	time     time.Time
+	severity Severity
+	msg      string
+	fields   []field // flattened structured fields, sorted by key
+}
+
+// parseRecord decodes a JSON encoded log line. Nested groups are flattened, joining their keys
+// with a dot (e.g. "req.method")
+func parseRecord(line []byte) (*record, error) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return nil, errorEmptyRecord
+	}
+
+	var attrs map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	if err := dec.Decode(&attrs); err != nil {
+		return nil, fmt.Errorf("failed to decode log record: %w", err)
+	}
+
+	rec := &record{
+		time:     time.Now(),
+		severity: SeverityNotice,
+	}
+	if ts, ok := attrs[keyTime].(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			rec.time = t
+		}
+		delete(attrs, keyTime)
+	}
+	if level, ok := attrs[keyLevel].(string); ok {
+		rec.severity = severityFromLevel(level)
+		delete(attrs, keyLevel)
+	}
+	if msg, ok := attrs[keyMessage].(string); ok {
+		rec.msg = msg
+		delete(attrs, keyMessage)
+	}
+
+	rec.fields = flatten("", attrs, rec.fields)
+	sort.Slice(rec.fields, func(i, j int) bool {
+		return rec.fields[i].key < rec.fields[j].key
+	})
+
+	return rec, nil
+}
+
+func flatten(prefix string, attrs map[string]interface{}, fields []field) []field {
+	for key, val := range attrs {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch v := val.(type) {
+		case map[string]interface{}:
+			fields = flatten(key, v, fields)
+		case string:
+			fields = append(fields, field{key, v})
+		case json.Number:
+			fields = append(fields, field{key, v.String()})
+		case nil:
+			fields = append(fields, field{key, ""})
+		default:
+			// booleans / arrays
+			b, err := json.Marshal(v)
+			if err != nil {
+				b = []byte(fmt.Sprint(v))
+			}
+			fields = append(fields, field{key, string(b)})
+		}
+	}
+	return fields
+}
@@ -0,0 +1,293 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Facility denotes a syslog facility as defined in RFC5424
+type Facility int
+
+// Facilities commonly used for daemons
+const (
+	FacilityUser   Facility = 1
+	FacilityDaemon Facility = 3
+	FacilityLocal0 Facility = 16
+	FacilityLocal1 Facility = 17
+	FacilityLocal2 Facility = 18
+	FacilityLocal3 Facility = 19
+	FacilityLocal4 Facility = 20
+	FacilityLocal5 Facility = 21
+	FacilityLocal6 Facility = 22
+	FacilityLocal7 Facility = 23
+)
+
+var facilities = map[string]Facility{
+	"user":   FacilityUser,
+	"daemon": FacilityDaemon,
+	"local0": FacilityLocal0,
+	"local1": FacilityLocal1,
+	"local2": FacilityLocal2,
+	"local3": FacilityLocal3,
+	"local4": FacilityLocal4,
+	"local5": FacilityLocal5,
+	"local6": FacilityLocal6,
+	"local7": FacilityLocal7,
+}
+
+var errorUnknownFacility = errors.New("unknown syslog facility")
+
+// ParseFacility returns the facility matching its (case insensitive) name, e.g. "daemon" or "local3"
+func ParseFacility(name string) (Facility, error) {
+	f, exists := facilities[strings.ToLower(name)]
+	if !exists {
+		return 0, fmt.Errorf("%w: %s", errorUnknownFacility, name)
+	}
+	return f, nil
+}
+
+const (
+	// DefaultSyslogSocket denotes the local syslog socket used if no address is provided
+	DefaultSyslogSocket = "/dev/log"
+
+	// DefaultSDID denotes the default ID of the structured data element holding the log fields.
+	// 32473 is the private enterprise number reserved for documentation purposes (RFC5612)
+	DefaultSDID = "fields@32473"
+
+	syslogVersion   = 1
+	syslogNilValue  = "-"
+	syslogTimestamp = "2006-01-02T15:04:05.000000Z07:00"
+
+	maxSDNameLen = 32
+)
+
+var errorUnsupportedNetwork = errors.New("unsupported syslog network")
+
+// SyslogWriter sends JSON encoded log lines as RFC5424 messages to a syslog daemon. The structured
+// fields of each log line are mapped onto the parameters of a single structured data element. It is
+// safe for concurrent use
+type SyslogWriter struct {
+	sync.Mutex
+
+	network, addr string
+	conn          net.Conn
+
+	facility Facility
+	hostname string
+	appName  string
+	procID   string
+	sdID     string
+}
+
+// SyslogOption configures the syslog writer
+type SyslogOption func(*SyslogWriter)
+
+// WithSyslogAddress sets the address of the syslog daemon. Supported networks are "udp", "tcp",
+// "unix" and "unixgram". By default, the local syslog socket is used
+func WithSyslogAddress(network, addr string) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.network, w.addr = network, addr
+	}
+}
+
+// WithSyslogFacility sets the facility of all messages (default: daemon)
+func WithSyslogFacility(facility Facility) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.facility = facility
+	}
+}
+
+// WithSyslogAppName sets the APP-NAME of all messages (default: the name of the binary)
+func WithSyslogAppName(appName string) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.appName = appName
+	}
+}
+
+// WithSyslogSDID sets the ID of the structured data element holding the log fields
+func WithSyslogSDID(sdID string) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.sdID = sdID
+	}
+}
+
+// NewSyslogWriter creates a new syslog writer and connects to the syslog daemon
+func NewSyslogWriter(opts ...SyslogOption) (*SyslogWriter, error) {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = syslogNilValue
+	}
+
+	w := &SyslogWriter{
+		network:  "unixgram",
+		addr:     DefaultSyslogSocket,
+		facility: FacilityDaemon,
+		hostname: hostname,
+		appName:  sanitizeHeader(os.Args[0]),
+		procID:   strconv.Itoa(os.Getpid()),
+		sdID:     DefaultSDID,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	switch w.network {
+	case "udp", "tcp", "unix", "unixgram":
+	default:
+		return nil, fmt.Errorf("%w: %s", errorUnsupportedNetwork, w.network)
+	}
+
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *SyslogWriter) connect() error {
+	if w.conn != nil {
+		_ = w.conn.Close()
+		w.conn = nil
+	}
+	conn, err := net.Dial(w.network, w.addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to syslog daemon at %s://%s: %w", w.network, w.addr, err)
+	}
+	w.conn = conn
+	return nil
+}
+
+// Write implements the io.Writer interface. Each line in p is sent as a separate message. Lines
+// which can't be decoded are sent verbatim (with notice severity)
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	for _, line := range bytes.Split(p, []byte{'\n'}) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		rec, err := parseRecord(line)
+		if err != nil {
+			rec = &record{severity: SeverityNotice, msg: string(line)}
+		}
+		if err := w.send(w.format(rec)); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// send writes a single message, reconnecting once if the connection was lost
+func (w *SyslogWriter) send(msg []byte) (err error) {
+	// stream based transports require framing (octet counting, RFC6587)
+	if w.network == "tcp" || w.network == "unix" {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	if w.conn != nil {
+		if _, err = w.conn.Write(msg); err == nil {
+			return nil
+		}
+	}
+	if err := w.connect(); err != nil {
+		return err
+	}
+	_, err = w.conn.Write(msg)
+	return err
+}
+
+// format renders the record as RFC5424 message:
+//
+//	<PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+func (w *SyslogWriter) format(rec *record) []byte {
+	var buf bytes.Buffer
+
+	ts := syslogNilValue
+	if !rec.time.IsZero() {
+		ts = rec.time.Format(syslogTimestamp)
+	}
+	fmt.Fprintf(&buf, "<%d>%d %s %s %s %s %s ",
+		int(w.facility)*8+int(rec.severity), syslogVersion, ts, w.hostname, w.appName, w.procID, syslogNilValue)
+
+	if len(rec.fields) == 0 {
+		buf.WriteString(syslogNilValue)
+	} else {
+		buf.WriteByte('[')
+		buf.WriteString(w.sdID)
+		for _, f := range rec.fields {
+			buf.WriteByte(' ')
+			buf.WriteString(sanitizeSDName(f.key))
+			buf.WriteString(`="`)
+			writeSDValue(&buf, f.value)
+			buf.WriteByte('"')
+		}
+		buf.WriteByte(']')
+	}
+
+	if rec.msg != "" {
+		buf.WriteByte(' ')
+		buf.WriteString(rec.msg)
+	}
+	return buf.Bytes()
+}
+
+// Close closes the connection to the syslog daemon
+func (w *SyslogWriter) Close() error {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// sanitizeHeader ensures that a header field only consists of printable US-ASCII characters
+func sanitizeHeader(s string) string {
+	if idx := strings.LastIndexByte(s, '/'); idx >= 0 {
+		s = s[idx+1:]
+	}
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" {
+		return syslogNilValue
+	}
+	return s
+}
+
+// sanitizeSDName ensures that a key conforms to the SD-NAME definition of RFC5424
+func sanitizeSDName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, s)
+	if len(s) > maxSDNameLen {
+		s = s[:maxSDNameLen]
+	}
+	return s
+}
+
+// writeSDValue escapes the characters '"', '\' and ']' as required by RFC5424
+func writeSDValue(buf *bytes.Buffer, s string) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\\', ']':
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(s[i])
+	}
+}
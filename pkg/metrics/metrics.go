@@ -0,0 +1,334 @@
+// Package metrics provides the internal metrics (counters, gauges and histograms) used across the
+// capture, writeout, storage and query modules. Metrics are defined once, are registered with a
+// Registry and can be exported from there to any supported backend (e.g. Prometheus or an OTLP
+// collector), independent of where they are updated
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Kind denotes the type of a metric
+type Kind uint8
+
+const (
+	// KindCounter denotes a monotonically increasing value
+	KindCounter Kind = iota
+	// KindGauge denotes a value that can go up and down
+	KindGauge
+	// KindHistogram denotes a distribution of observed values
+	KindHistogram
+)
+
+// String returns a string representation of the metric kind
+func (k Kind) String() string {
+	switch k {
+	case KindCounter:
+		return "counter"
+	case KindGauge:
+		return "gauge"
+	case KindHistogram:
+		return "histogram"
+	}
+	return "unknown"
+}
+
+// Opts describes a metric. The fully-qualified name of a metric is made up of its Namespace,
+// Subsystem and Name, joined by underscores (analogous to the Prometheus naming convention)
+type Opts struct {
+	Namespace string
+	Subsystem string
+	Name      string
+	Help      string
+}
+
+// FullName returns the fully-qualified name of the metric
+func (o Opts) FullName() string {
+	parts := make([]string, 0, 3)
+	for _, part := range []string{o.Namespace, o.Subsystem, o.Name} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+// HistogramOpts describes a histogram metric
+type HistogramOpts struct {
+	Opts
+
+	// Buckets denotes the upper (inclusive) bounds of the histogram buckets. An
+	// additional +Inf bucket is implied
+	Buckets []float64
+}
+
+// DefaultBuckets denotes the default histogram buckets (matching the Prometheus default buckets)
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Sample denotes a snapshot of a metric's state at a given point in time
+type Sample struct {
+	Opts
+	Kind Kind
+
+	// Value holds the current value of a counter or gauge
+	Value float64
+
+	// Histogram state: Buckets denotes the upper bounds of the buckets, BucketCounts the
+	// number of observations per bucket (non-cumulative, including the implied +Inf bucket)
+	Buckets      []float64
+	BucketCounts []uint64
+	Count        uint64
+	Sum          float64
+}
+
+// atomicFloat provides lock-free updates of a float64
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (f *atomicFloat) add(v float64) {
+	for {
+		old := f.bits.Load()
+		if f.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) set(v float64) {
+	f.bits.Store(math.Float64bits(v))
+}
+
+func (f *atomicFloat) load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
+
+// Counter denotes a monotonically increasing metric. All methods are safe for concurrent use
+type Counter struct {
+	opts  Opts
+	value atomicFloat
+}
+
+// Inc increments the counter by one
+func (c *Counter) Inc() {
+	c.value.add(1)
+}
+
+// Add increments the counter by v. Negative values are ignored
+func (c *Counter) Add(v float64) {
+	if v <= 0 {
+		return
+	}
+	c.value.add(v)
+}
+
+// Value returns the current value of the counter
+func (c *Counter) Value() float64 {
+	return c.value.load()
+}
+
+func (c *Counter) sample() Sample {
+	return Sample{Opts: c.opts, Kind: KindCounter, Value: c.Value()}
+}
+
+// Gauge denotes a metric that can arbitrarily go up and down. All methods are safe for concurrent use
+type Gauge struct {
+	opts  Opts
+	value atomicFloat
+}
+
+// Set sets the gauge to v
+func (g *Gauge) Set(v float64) {
+	g.value.set(v)
+}
+
+// Add adds v (which may be negative) to the gauge
+func (g *Gauge) Add(v float64) {
+	g.value.add(v)
+}
+
+// Value returns the current value of the gauge
+func (g *Gauge) Value() float64 {
+	return g.value.load()
+}
+
+func (g *Gauge) sample() Sample {
+	return Sample{Opts: g.opts, Kind: KindGauge, Value: g.Value()}
+}
+
+// Histogram tracks the distribution of observed values. All methods are safe for concurrent use
+type Histogram struct {
+	opts    Opts
+	buckets []float64
+	counts  []atomic.Uint64 // one per bucket, plus the +Inf bucket
+
+	count atomic.Uint64
+	sum   atomicFloat
+}
+
+// Observe adds a single observation to the histogram
+func (h *Histogram) Observe(v float64) {
+	h.counts[sort.SearchFloat64s(h.buckets, v)].Add(1)
+	h.sum.add(v)
+	h.count.Add(1)
+}
+
+// ObserveDuration adds a duration (in seconds) to the histogram
+func (h *Histogram) ObserveDuration(d time.Duration) {
+	h.Observe(d.Seconds())
+}
+
+func (h *Histogram) sample() Sample {
+	s := Sample{
+		Opts:         h.opts,
+		Kind:         KindHistogram,
+		Buckets:      h.buckets,
+		BucketCounts: make([]uint64, len(h.counts)),
+		Sum:          h.sum.load(),
+	}
+	for i := range h.counts {
+		s.BucketCounts[i] = h.counts[i].Load()
+		s.Count += s.BucketCounts[i]
+	}
+	return s
+}
+
+type metric interface {
+	sample() Sample
+}
+
+// Registry holds a set of uniquely named metrics
+type Registry struct {
+	sync.RWMutex
+
+	metrics map[string]metric
+	names   []string // names in order of registration
+	start   time.Time
+}
+
+// DefaultRegistry is the registry used by the package level constructors
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates a new, empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: make(map[string]metric),
+		start:   time.Now(),
+	}
+}
+
+// Start returns the time the registry was created at (i.e. the start time of all cumulative metrics)
+func (r *Registry) Start() time.Time {
+	return r.start
+}
+
+// register adds a metric to the registry. Since metrics are typically defined during package
+// initialization, a duplicate name is considered a programming error and causes a panic
+func (r *Registry) register(opts Opts, m metric) {
+	name := opts.FullName()
+	if name == "" {
+		panic("metrics: empty metric name")
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	if _, exists := r.metrics[name]; exists {
+		panic(fmt.Sprintf("metrics: metric %q is already registered", name))
+	}
+	r.metrics[name] = m
+	r.names = append(r.names, name)
+}
+
+// NewCounter creates a new counter and registers it
+func (r *Registry) NewCounter(opts Opts) *Counter {
+	c := &Counter{opts: opts}
+	r.register(opts, c)
+	return c
+}
+
+// NewGauge creates a new gauge and registers it
+func (r *Registry) NewGauge(opts Opts) *Gauge {
+	g := &Gauge{opts: opts}
+	r.register(opts, g)
+	return g
+}
+
+// NewHistogram creates a new histogram and registers it. If no buckets are provided, the
+// DefaultBuckets are used
+func (r *Registry) NewHistogram(opts HistogramOpts) *Histogram {
+	buckets := opts.Buckets
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	h := &Histogram{
+		opts:    opts.Opts,
+		buckets: buckets,
+		counts:  make([]atomic.Uint64, len(buckets)+1),
+	}
+	r.register(opts.Opts, h)
+	return h
+}
+
+// Samples returns a snapshot of all registered metrics, in order of registration
+func (r *Registry) Samples() []Sample {
+	r.RLock()
+	defer r.RUnlock()
+
+	samples := make([]Sample, 0, len(r.names))
+	for _, name := range r.names {
+		samples = append(samples, r.metrics[name].sample())
+	}
+	return samples
+}
+
+// NewCounter creates a new counter and registers it with the DefaultRegistry
+func NewCounter(opts Opts) *Counter {
+	return DefaultRegistry.NewCounter(opts)
+}
+
+// NewGauge creates a new gauge and registers it with the DefaultRegistry
+func NewGauge(opts Opts) *Gauge {
+	return DefaultRegistry.NewGauge(opts)
+}
+
+// NewHistogram creates a new histogram and registers it with the DefaultRegistry
+func NewHistogram(opts HistogramOpts) *Histogram {
+	return DefaultRegistry.NewHistogram(opts)
+}
+
+// Exporter pushes the state of all metrics of a registry to an external system
+type Exporter interface {
+	// Export sends the provided samples, start denotes the start time of all cumulative metrics
+	Export(ctx context.Context, start time.Time, samples []Sample) error
+
+	// Shutdown flushes any pending data and releases all resources held by the exporter
+	Shutdown(ctx context.Context) error
+}
+
+// Push periodically exports all metrics of the registry until ctx is done. Errors are passed
+// to errFn (if provided), but don't stop the export
+func (r *Registry) Push(ctx context.Context, exporter Exporter, interval time.Duration, errFn func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := exporter.Export(ctx, r.start, r.Samples()); err != nil && errFn != nil {
+				errFn(err)
+			}
+		}
+	}
+}
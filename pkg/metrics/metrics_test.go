@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+func TestFullName(t *testing.T) {
+	var tests = []struct {
+		opts     Opts
+		expected string
+	}{
+		{Opts{Name: "total"}, "total"},
+		{Opts{Subsystem: "capture", Name: "total"}, "capture_total"},
+		{Opts{Namespace: "goprobe", Subsystem: "capture", Name: "total"}, "goprobe_capture_total"},
+		{Opts{Namespace: "goprobe", Name: "total"}, "goprobe_total"},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.expected, test.opts.FullName())
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+
+	c := r.NewCounter(Opts{Subsystem: "test", Name: "counter_total"})
+	g := r.NewGauge(Opts{Subsystem: "test", Name: "gauge"})
+	h := r.NewHistogram(HistogramOpts{Opts: Opts{Subsystem: "test", Name: "histogram"}, Buckets: []float64{10, 1, 5}})
+
+	require.Panics(t, func() { r.NewGauge(Opts{Subsystem: "test", Name: "counter_total"}) })
+	require.Panics(t, func() { r.NewCounter(Opts{}) })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Inc()
+				g.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	c.Add(-5) // must be ignored
+	g.Add(-500)
+	for _, v := range []float64{0.5, 1, 2, 5, 7, 100} {
+		h.Observe(v)
+	}
+
+	samples := r.Samples()
+	require.Len(t, samples, 3)
+
+	require.Equal(t, KindCounter, samples[0].Kind)
+	require.Equal(t, 1000., samples[0].Value)
+	require.Equal(t, KindGauge, samples[1].Kind)
+	require.Equal(t, 500., samples[1].Value)
+
+	require.Equal(t, KindHistogram, samples[2].Kind)
+	require.Equal(t, []float64{1, 5, 10}, samples[2].Buckets)
+	require.Equal(t, []uint64{2, 2, 1, 1}, samples[2].BucketCounts)
+	require.Equal(t, uint64(6), samples[2].Count)
+	require.Equal(t, 115.5, samples[2].Sum)
+}
+
+func TestPrometheusCollector(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter(Opts{Namespace: "goprobe", Subsystem: "test", Name: "events_total", Help: "Number of events"})
+	h := r.NewHistogram(HistogramOpts{Opts: Opts{Namespace: "goprobe", Subsystem: "test", Name: "duration_seconds", Help: "Duration"}, Buckets: []float64{0.1, 1}})
+
+	c.Add(3)
+	h.ObserveDuration(50 * time.Millisecond)
+	h.ObserveDuration(2 * time.Second)
+
+	reg := prometheus.NewPedanticRegistry()
+	require.Nil(t, reg.Register(NewPrometheusCollector(r)))
+
+	expected := `
+# HELP goprobe_test_duration_seconds Duration
+# TYPE goprobe_test_duration_seconds histogram
+goprobe_test_duration_seconds_bucket{le="0.1"} 1
+goprobe_test_duration_seconds_bucket{le="1"} 1
+goprobe_test_duration_seconds_bucket{le="+Inf"} 2
+goprobe_test_duration_seconds_sum 2.05
+goprobe_test_duration_seconds_count 2
+# HELP goprobe_test_events_total Number of events
+# TYPE goprobe_test_events_total counter
+goprobe_test_events_total 3
+`
+	require.Nil(t, testutil.GatherAndCompare(reg, strings.NewReader(expected)))
+}
+
+func TestOTLPConversion(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter(Opts{Name: "counter_total"}).Add(2)
+	r.NewGauge(Opts{Name: "gauge"}).Set(-1)
+	r.NewHistogram(HistogramOpts{Opts: Opts{Name: "histogram"}, Buckets: []float64{1}}).Observe(0.5)
+
+	now := time.Now()
+	rm := toResourceMetrics(resource.Empty(), r.Start(), now, r.Samples())
+	require.Len(t, rm.ScopeMetrics, 1)
+
+	metrics := rm.ScopeMetrics[0].Metrics
+	require.Len(t, metrics, 3)
+
+	sum, ok := metrics[0].Data.(metricdata.Sum[float64])
+	require.True(t, ok)
+	require.True(t, sum.IsMonotonic)
+	require.Equal(t, metricdata.CumulativeTemporality, sum.Temporality)
+	require.Equal(t, 2., sum.DataPoints[0].Value)
+	require.Equal(t, r.Start(), sum.DataPoints[0].StartTime)
+
+	gauge, ok := metrics[1].Data.(metricdata.Gauge[float64])
+	require.True(t, ok)
+	require.Equal(t, -1., gauge.DataPoints[0].Value)
+
+	hist, ok := metrics[2].Data.(metricdata.Histogram[float64])
+	require.True(t, ok)
+	require.Equal(t, []float64{1}, hist.DataPoints[0].Bounds)
+	require.Equal(t, []uint64{1, 0}, hist.DataPoints[0].BucketCounts)
+	require.Equal(t, uint64(1), hist.DataPoints[0].Count)
+}
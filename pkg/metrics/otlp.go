@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/version"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+const scopeName = "github.com/els0r/goProbe"
+
+var errorEmptyServiceName = errors.New("no service name provided")
+
+// OTLPExporter pushes metrics to an OpenTelemetry collector via OTLP/HTTP
+type OTLPExporter struct {
+	exporter sdkmetric.Exporter
+	resource *resource.Resource
+}
+
+type otlpConfig struct {
+	endpoint string
+	insecure bool
+}
+
+// OTLPOption configures the OTLP exporter
+type OTLPOption func(*otlpConfig)
+
+// WithOTLPEndpoint sets the OTLP/HTTP collector endpoint (host:port). If unset, the endpoint is
+// derived from the standard OTEL_EXPORTER_OTLP_* environment variables
+func WithOTLPEndpoint(endpoint string) OTLPOption {
+	return func(c *otlpConfig) {
+		c.endpoint = endpoint
+	}
+}
+
+// WithOTLPInsecure disables TLS when connecting to the collector
+func WithOTLPInsecure(insecure bool) OTLPOption {
+	return func(c *otlpConfig) {
+		c.insecure = insecure
+	}
+}
+
+// NewOTLPExporter creates a new exporter pushing metrics to an OTLP collector
+func NewOTLPExporter(ctx context.Context, serviceName string, opts ...OTLPOption) (*OTLPExporter, error) {
+	if serviceName == "" {
+		return nil, errorEmptyServiceName
+	}
+
+	cfg := &otlpConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	var exporterOpts []otlpmetrichttp.Option
+	if cfg.endpoint != "" {
+		exporterOpts = append(exporterOpts, otlpmetrichttp.WithEndpoint(cfg.endpoint))
+	}
+	if cfg.insecure {
+		exporterOpts = append(exporterOpts, otlpmetrichttp.WithInsecure())
+	}
+	exporter, err := otlpmetrichttp.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP metrics exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(version.Short()),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics resource: %w", err)
+	}
+
+	return &OTLPExporter{
+		exporter: exporter,
+		resource: res,
+	}, nil
+}
+
+// Export implements the Exporter interface
+func (o *OTLPExporter) Export(ctx context.Context, start time.Time, samples []Sample) error {
+	return o.exporter.Export(ctx, toResourceMetrics(o.resource, start, time.Now(), samples))
+}
+
+// Shutdown implements the Exporter interface
+func (o *OTLPExporter) Shutdown(ctx context.Context) error {
+	return o.exporter.Shutdown(ctx)
+}
+
+// toResourceMetrics converts the samples to their (cumulative) OpenTelemetry representation
+func toResourceMetrics(res *resource.Resource, start, now time.Time, samples []Sample) *metricdata.ResourceMetrics {
+	metrics := make([]metricdata.Metrics, 0, len(samples))
+	for _, s := range samples {
+		m := metricdata.Metrics{
+			Name:        s.FullName(),
+			Description: s.Help,
+		}
+
+		switch s.Kind {
+		case KindCounter:
+			m.Data = metricdata.Sum[float64]{
+				DataPoints:  []metricdata.DataPoint[float64]{{StartTime: start, Time: now, Value: s.Value}},
+				Temporality: metricdata.CumulativeTemporality,
+				IsMonotonic: true,
+			}
+		case KindGauge:
+			m.Data = metricdata.Gauge[float64]{
+				DataPoints: []metricdata.DataPoint[float64]{{StartTime: start, Time: now, Value: s.Value}},
+			}
+		case KindHistogram:
+			m.Data = metricdata.Histogram[float64]{
+				DataPoints: []metricdata.HistogramDataPoint[float64]{{
+					StartTime:    start,
+					Time:         now,
+					Count:        s.Count,
+					Bounds:       s.Buckets,
+					BucketCounts: s.BucketCounts,
+					Sum:          s.Sum,
+				}},
+				Temporality: metricdata.CumulativeTemporality,
+			}
+		default:
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+
+	return &metricdata.ResourceMetrics{
+		Resource: res,
+		ScopeMetrics: []metricdata.ScopeMetrics{{
+			Scope:   instrumentation.Scope{Name: scopeName, Version: version.Short()},
+			Metrics: metrics,
+		}},
+	}
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// prometheusCollector exposes the metrics of a registry to Prometheus
+type prometheusCollector struct {
+	registry *Registry
+}
+
+// NewPrometheusCollector returns a prometheus.Collector exposing all metrics of the registry. Since
+// metrics may be registered at any point in time, the collector is unchecked (i.e. it doesn't
+// describe its metrics upfront)
+func NewPrometheusCollector(r *Registry) prometheus.Collector {
+	return &prometheusCollector{registry: r}
+}
+
+// Describe implements the prometheus.Collector interface
+func (p *prometheusCollector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements the prometheus.Collector interface
+func (p *prometheusCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range p.registry.Samples() {
+		desc := prometheus.NewDesc(s.FullName(), s.Help, nil, nil)
+
+		var (
+			m   prometheus.Metric
+			err error
+		)
+		switch s.Kind {
+		case KindCounter:
+			m, err = prometheus.NewConstMetric(desc, prometheus.CounterValue, s.Value)
+		case KindGauge:
+			m, err = prometheus.NewConstMetric(desc, prometheus.GaugeValue, s.Value)
+		case KindHistogram:
+			// Prometheus expects cumulative bucket counts (excluding the +Inf bucket)
+			buckets, cumulative := make(map[float64]uint64, len(s.Buckets)), uint64(0)
+			for i, upper := range s.Buckets {
+				cumulative += s.BucketCounts[i]
+				buckets[upper] = cumulative
+			}
+			m, err = prometheus.NewConstHistogram(desc, s.Count, s.Sum, buckets)
+		default:
+			continue
+		}
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(desc, err)
+			continue
+		}
+		ch <- m
+	}
+}
@@ -0,0 +1,164 @@
+// Package privileges provides the means to permanently drop the privileges of the running
+// process to an unprivileged user once all resources requiring them (e.g. capture sockets)
+// were acquired
+package privileges
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var (
+	// ErrEmptyUser is returned if no user is specified
+	ErrEmptyUser = errors.New("no user specified")
+
+	// ErrNotDropped is returned if root privileges could be regained after dropping them
+	ErrNotDropped = errors.New("privileges could not be dropped permanently")
+)
+
+// Credentials denotes the user / group(s) a process runs as
+type Credentials struct {
+	UID    int   // UID: the user ID
+	GID    int   // GID: the primary group ID
+	Groups []int // Groups: the supplementary group IDs
+}
+
+// Lookup resolves a specification of the form `user` or `user:group` (names or numeric IDs)
+// to the credentials to run as. If no group is provided, the primary group of the user is used.
+// Supplementary groups are only assigned for users known to the system
+func Lookup(spec string) (Credentials, error) {
+	userSpec, groupSpec, hasGroup := strings.Cut(spec, ":")
+	if userSpec == "" {
+		return Credentials{}, ErrEmptyUser
+	}
+
+	var creds Credentials
+	u, err := lookupUser(userSpec)
+	switch {
+	case err == nil:
+		if creds.UID, err = strconv.Atoi(u.Uid); err != nil {
+			return Credentials{}, fmt.Errorf("invalid UID %s of user %s: %w", u.Uid, userSpec, err)
+		}
+		if creds.GID, err = strconv.Atoi(u.Gid); err != nil {
+			return Credentials{}, fmt.Errorf("invalid GID %s of user %s: %w", u.Gid, userSpec, err)
+		}
+		if creds.Groups, err = supplementaryGroups(u); err != nil {
+			return Credentials{}, err
+		}
+	default:
+		// numeric IDs don't have to be known to the system (e.g. in containers), in which case
+		// the group has to be provided explicitly
+		uid, convErr := strconv.Atoi(userSpec)
+		if convErr != nil || !hasGroup {
+			return Credentials{}, fmt.Errorf("failed to look up user %s: %w", userSpec, err)
+		}
+		creds.UID = uid
+	}
+
+	if hasGroup {
+		if creds.GID, err = lookupGroup(groupSpec); err != nil {
+			return Credentials{}, err
+		}
+	}
+
+	return creds, nil
+}
+
+// Drop permanently changes the user / group(s) of the process (all of its threads) to the ones of
+// creds. Since the root privileges are lost, it must be called after all privileged resources were
+// acquired
+func Drop(creds Credentials) error {
+
+	// nothing to do if the process already runs as the user (e.g. if started unprivileged)
+	if os.Geteuid() == creds.UID && os.Getegid() == creds.GID && os.Getuid() == creds.UID {
+		return nil
+	}
+
+	groups := append([]int{creds.GID}, creds.Groups...)
+	if err := syscall.Setgroups(groups); err != nil {
+		return fmt.Errorf("failed to set supplementary groups: %w", err)
+	}
+	if err := syscall.Setgid(creds.GID); err != nil {
+		return fmt.Errorf("failed to set GID %d: %w", creds.GID, err)
+	}
+	if err := syscall.Setuid(creds.UID); err != nil {
+		return fmt.Errorf("failed to set UID %d: %w", creds.UID, err)
+	}
+
+	// make sure there is no way back
+	if creds.UID != 0 {
+		if err := syscall.Setuid(0); err == nil {
+			return ErrNotDropped
+		}
+	}
+	if os.Getuid() != creds.UID || os.Geteuid() != creds.UID || os.Getgid() != creds.GID || os.Getegid() != creds.GID {
+		return ErrNotDropped
+	}
+	return nil
+}
+
+// Chown assigns the files / directories at paths (e.g. the ones created at startup) to the user / group
+// of creds, so that they remain accessible once the privileges were dropped. Paths which don't exist are
+// skipped
+func Chown(creds Credentials, paths ...string) error {
+	var errs []error
+	for _, path := range paths {
+		if err := os.Lchown(path, creds.UID, creds.GID); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("failed to set owner of %s: %w", path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func lookupUser(spec string) (*user.User, error) {
+	u, err := user.Lookup(spec)
+	if err == nil {
+		return u, nil
+	}
+	if _, convErr := strconv.Atoi(spec); convErr == nil {
+		return user.LookupId(spec)
+	}
+	return nil, err
+}
+
+func lookupGroup(spec string) (int, error) {
+	if spec == "" {
+		return 0, errors.New("no group specified")
+	}
+	if gid, err := strconv.Atoi(spec); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(spec)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up group %s: %w", spec, err)
+	}
+	gid, err := strconv.Atoi(g.Gid)
+	if err != nil {
+		return 0, fmt.Errorf("invalid GID %s of group %s: %w", g.Gid, spec, err)
+	}
+	return gid, nil
+}
+
+func supplementaryGroups(u *user.User) ([]int, error) {
+	groupIDs, err := u.GroupIds()
+	if err != nil {
+		// not all systems provide the group membership of a user, in which case only the
+		// primary group is assigned
+		return nil, nil
+	}
+	groups := make([]int, 0, len(groupIDs))
+	for _, id := range groupIDs {
+		gid, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid supplementary GID %s of user %s: %w", id, u.Username, err)
+		}
+		groups = append(groups, gid)
+	}
+	return groups, nil
+}
@@ -0,0 +1,56 @@
+package privileges
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	current, err := user.Current()
+	require.Nil(t, err)
+	uid, err := strconv.Atoi(current.Uid)
+	require.Nil(t, err)
+	gid, err := strconv.Atoi(current.Gid)
+	require.Nil(t, err)
+
+	for _, spec := range []string{current.Username, current.Uid} {
+		creds, err := Lookup(spec)
+		require.Nil(t, err, spec)
+		require.Equal(t, uid, creds.UID)
+		require.Equal(t, gid, creds.GID)
+	}
+
+	creds, err := Lookup(current.Username + ":12345")
+	require.Nil(t, err)
+	require.Equal(t, uid, creds.UID)
+	require.Equal(t, 12345, creds.GID)
+
+	// unknown numeric IDs are accepted if the group is provided explicitly
+	creds, err = Lookup("54321:54321")
+	require.Nil(t, err)
+	require.Equal(t, Credentials{UID: 54321, GID: 54321}, creds)
+
+	for _, spec := range []string{"", ":root", "doesnotexist-goprobe", "54321", current.Username + ":", current.Username + ":doesnotexist-goprobe"} {
+		_, err := Lookup(spec)
+		require.NotNil(t, err, spec)
+	}
+}
+
+func TestChown(t *testing.T) {
+	current, err := user.Current()
+	require.Nil(t, err)
+	creds, err := Lookup(current.Uid)
+	require.Nil(t, err)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "goprobe.log")
+	require.Nil(t, os.WriteFile(path, nil, 0600))
+
+	// missing paths are skipped
+	require.Nil(t, Chown(creds, dir, path, filepath.Join(dir, "missing")))
+}
@@ -1,15 +1,16 @@
 package query
 
 import (
-	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/els0r/goProbe/pkg/goDB/conditions"
+	"github.com/els0r/goProbe/pkg/goDB/info"
 	"github.com/els0r/goProbe/pkg/query/dns"
 	"github.com/els0r/goProbe/pkg/results"
 	"github.com/els0r/goProbe/pkg/types"
@@ -55,15 +56,46 @@ func DefaultArgs() *Args {
 type Args struct {
 	// required
 	Query  string `json:"query" yaml:"query" form:"query"`    // Query: the query type. Example: sip,dip,dport,proto
-	Ifaces string `json:"ifaces" yaml:"ifaces" form:"ifaces"` // Ifaces: the interfaces to query. Example: eth0,eth1
+	Ifaces string `json:"ifaces" yaml:"ifaces" form:"ifaces"` // Ifaces: the interfaces to query. Supports wildcards and negations. Example: eth0,eth1
 
 	QueryHosts string `json:"query_hosts,omitempty" yaml:"query_hosts,omitempty" form:"query_hosts,omitempty"` // QueryHosts: the hosts for which data is queried (comma-separated list). Example: hostA,hostB,hostC
 
+	Tenant string `json:"tenant,omitempty" yaml:"tenant,omitempty" form:"tenant,omitempty"` // Tenant: the tenant whose flows are queried. If empty, the flows of interfaces without tenant are queried. Example: acme
+
+	// IfaceGroups: interface groups which can be selected in Ifaces in addition to the ones stored in the DB (taking precedence
+	// over the latter). The flows of the members of a selected group are aggregated and reported under the group's name. Example: {"uplinks": ["eth0", "eth2"]}
+	// Note: Nested structures are not supported for form data
+	IfaceGroups info.Groups `json:"iface_groups,omitempty" yaml:"iface_groups,omitempty" form:"-"`
+
 	Hostname string `json:"hostname,omitempty" yaml:"hostname,omitempty" form:"hostname,omitempty"` // Hostname: the hostname from which data is queried. Example: localhost
 	HostID   uint   `json:"host_id,omitempty" yaml:"host_id,omitempty" form:"host_id,omitempty"`    // HostID: the host id from which data is queried. Example: 123456
 
+	// Distinct: the attribute whose distinct values are counted (approximately) per row. Example: dip
+	Distinct string `json:"distinct,omitempty" yaml:"distinct,omitempty" form:"distinct,omitempty"`
+
+	// Distribution: compute the time distribution of the bytes of each row across the queried range (in 8 equally sized intervals),
+	// revealing whether the traffic was constant or occurred in bursts. Not supported for queries including time. Example: false
+	Distribution bool `json:"distribution,omitempty" yaml:"distribution,omitempty" form:"distribution,omitempty"`
+
+	// Dedup: count flows seen identically (same attributes, matching counters) on several of the queried interfaces only once,
+	// e.g. if both sides of a link are mirrored to different capture ports. The removed flows are reported in the summary. Example: false
+	Dedup bool `json:"dedup,omitempty" yaml:"dedup,omitempty" form:"dedup,omitempty"`
+
 	// data filtering
-	Condition string `json:"condition,omitempty" yaml:"condition,omitempty" form:"condition,omitempty"` // Condition: the condition to filter data by. Example: port=80 && proto=TCP
+	Condition string `json:"condition,omitempty" yaml:"condition,omitempty" form:"condition,omitempty"`    // Condition: the condition to filter data by. Example: port=80 && proto=TCP
+	IPVersion int    `json:"ip_version,omitempty" yaml:"ip_version,omitempty" form:"ip_version,omitempty"` // IPVersion: only query flows of one IP version (4 or 6). Example: 4
+
+	// MaxDropPct: exclude the blocks (5 minute intervals) of an interface whose capture dropped a higher percentage of packets.
+	// Blocks with an unknown drop rate (written by older versions) aren't excluded. Example: 1
+	MaxDropPct float64 `json:"max_drop_pct,omitempty" yaml:"max_drop_pct,omitempty" form:"max_drop_pct,omitempty"`
+
+	// Verify: check that all columns of each block hold the same number of entries. Inconsistent blocks (e.g. due to partial
+	// writes) are reported as corruptions in the summary and skipped. Slower, since all columns of all blocks are read. Example: false
+	Verify bool `json:"verify,omitempty" yaml:"verify,omitempty" form:"verify,omitempty"`
+
+	// Anomalies: compare the traffic of each interface over (the last day of) the queried range with the same period of the
+	// preceding seven days and report it in the summary, flagging unusual volumes. Example: false
+	Anomalies bool `json:"anomalies,omitempty" yaml:"anomalies,omitempty" form:"anomalies,omitempty"`
 
 	// counter addition
 	In  bool `json:"in,omitempty" yaml:"in,omitempty" form:"in,omitempty"`     // In: only show incoming packets/bytes. Example: false
@@ -74,15 +106,31 @@ type Args struct {
 	First string `json:"first,omitempty" yaml:"first,omitempty" form:"first,omitempty"` // First: the first timestamp to query. Example: 2020-08-12T09:47:00+0200
 	Last  string `json:"last,omitempty" yaml:"last,omitempty" form:"last,omitempty"`    // Last: the last timestamp to query. Example: -24h
 
+	// Windows: disjoint time windows of the form "<first>..<last>" to query in one pass instead of the range given
+	// by First / Last (which are ignored if set). Example: ["2024-01-08 08:00..2024-01-08 18:00", "2024-01-09 08:00..2024-01-09 18:00"]
+	Windows []string `json:"windows,omitempty" yaml:"windows,omitempty" form:"windows,omitempty"`
+
 	// formatting
 	Format        string `json:"format,omitempty" yaml:"format,omitempty" form:"format,omitempty"`                         // Format: the output format. Enum: [json, csv, table]. Example: json
 	SortBy        string `json:"sort_by,omitempty" yaml:"sort_by,omitempty" form:"sort_by,omitempty"`                      // SortBy: column to sort by. Enum: [packets, bytes]. Example: bytes
 	NumResults    uint64 `json:"num_results,omitempty" yaml:"num_results,omitempty" form:"num_results,omitempty"`          // NumResults: number of results to return/print. Example: 25
 	SortAscending bool   `json:"sort_ascending,omitempty" yaml:"sort_ascending,omitempty" form:"sort_ascending,omitempty"` // SortAscending: sort ascending instead of the default descending. Example: false
+	RawUnits      bool   `json:"raw_units,omitempty" yaml:"raw_units,omitempty" form:"raw_units,omitempty"`                // RawUnits: print sizes and counts as exact integers instead of in human-readable form (txt format only). Example: false
+	TotalRow      bool   `json:"total_row,omitempty" yaml:"total_row,omitempty" form:"total_row,omitempty"`                // TotalRow: append a row summing up the printed rows (txt format only). Example: false
+	Subtotals     bool   `json:"subtotals,omitempty" yaml:"subtotals,omitempty" form:"subtotals,omitempty"`                // Subtotals: group the printed rows by their first column and append subtotal rows (txt format only). Example: false
+
+	// Enrich: the enrichers run over the final rows before they are printed (in order). Example: ["service", "label"]
+	Enrich []string `json:"enrich,omitempty" yaml:"enrich,omitempty" form:"enrich,omitempty"`
+
+	// Filter: filters of the form "<field>=<pattern>" applied to the resolved hostnames (hostname, sip_hostname, dip_hostname) or
+	// enriched columns of the rows before they are limited. Patterns are globs or regular expressions enclosed in slashes. When filtering
+	// on hostnames, all rows are resolved (regardless of DNSResolution.MaxRows). Example: ["hostname=*.amazonaws.com"]
+	Filter []string `json:"filter,omitempty" yaml:"filter,omitempty" form:"filter,omitempty"`
 
 	// do-and-exit arguments
 	List    bool `json:"list,omitempty" yaml:"list,omitempty" form:"list,omitempty"`          // List: only list interfaces and return. Example: false
 	Version bool `json:"version,omitempty" yaml:"version,omitempty" form:"version,omitempty"` // Version: only print version and return. Example: false
+	Explain bool `json:"explain,omitempty" yaml:"explain,omitempty" form:"explain,omitempty"` // Explain: only show how the query would be executed (e.g. which columns are read) instead of running it. Example: false
 
 	// resolution
 	// Note: Nested structures are not supported for form data, see individual parameters in definition of DNSResolution
@@ -92,14 +140,30 @@ type Args struct {
 	MaxMemPct int  `json:"max_mem_pct,omitempty" yaml:"max_mem_pct,omitempty" form:"max_mem_pct,omitempty"` // MaxMemPct: maximum percentage of available host memory to use for query processing. Example: 80
 	LowMem    bool `json:"low_mem,omitempty" yaml:"low_mem,omitempty" form:"low_mem,omitempty"`             // LowMem: use less memory for query processing. Example: false
 
+	MaxBlocksPerSec int  `json:"max_blocks_per_sec,omitempty" yaml:"max_blocks_per_sec,omitempty" form:"max_blocks_per_sec,omitempty"` // MaxBlocksPerSec: maximum number of blocks read from disk per second (0: server default / unlimited), capped by the limit of the server. Example: 500
+	Workers         int  `json:"workers,omitempty" yaml:"workers,omitempty" form:"workers,omitempty"`                                  // Workers: number of workers reading the blocks of each interface concurrently (0: server default). Capped by the server. Example: 4
+	Approx          bool `json:"approx,omitempty" yaml:"approx,omitempty" form:"approx,omitempty"`                                     // Approx: approximate the top results (with bounded memory) instead of aggregating all flows exactly. Example: false
+
 	// Caller stores who produced these args (caller). Example: goQuery. Example: goQuery. Example: goQuery. Example: goQuery
 	Caller string `json:"caller,omitempty" yaml:"caller,omitempty" form:"caller,omitempty"`
 
 	// Live can be used to request live flow data (in addition to DB results). Example: false
 	Live bool `json:"live,omitempty" yaml:"live,omitempty" form:"live,omitempty"`
 
+	// Export: server-side destination the result is written to instead of being returned, either an S3 URI or a path relative
+	// to the export directory of the server. The response only references the exported result. Example: s3://results/top-talkers.json
+	Export string `json:"export,omitempty" yaml:"export,omitempty" form:"export,omitempty"`
+
+	// OutputFile: file the results are written to instead of the console. The file is only replaced
+	// once all results were written. Not exposed via the API, hence it can't be set in requests
+	OutputFile string `json:"-" yaml:"-" form:"-"`
+
 	// outputs is unexported
 	outputs []io.Writer
+
+	// conditionMacros are expanded in the condition upon preparation. Since the expanded condition
+	// is what's passed on, they don't have to be known to remote query runners
+	conditionMacros conditions.Macros
 }
 
 // DNSResolution contains DNS query / resolution related config arguments / parameters
@@ -107,6 +171,10 @@ type DNSResolution struct {
 	Enabled bool          `json:"enabled" yaml:"enabled" form:"dns_enabled"`                                  // Enabled: enable reverse DNS lookups. Example: false
 	Timeout time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty" form:"dns_timeout,omitempty"`    // Timeout: timeout for reverse DNS lookups. Example: 2s
 	MaxRows int           `json:"max_rows,omitempty" yaml:"max_rows,omitempty" form:"dns_max_rows,omitempty"` // MaxRows: maximum number of rows to resolve. Example: 100
+
+	Resolvers        []string      `json:"resolvers,omitempty" yaml:"resolvers,omitempty" form:"dns_resolvers,omitempty"`                            // Resolvers: DNS servers to use instead of the resolver of the host (plain, tls:// or https://). Example: ["1.1.1.1", "tls://9.9.9.9"]
+	MaxConcurrency   int           `json:"max_concurrency,omitempty" yaml:"max_concurrency,omitempty" form:"dns_max_concurrency,omitempty"`          // MaxConcurrency: maximum number of lookups performed in parallel (0: unlimited). Example: 16
+	NegativeCacheTTL time.Duration `json:"negative_cache_ttl,omitempty" yaml:"negative_cache_ttl,omitempty" form:"dns_negative_cache_ttl,omitempty"` // NegativeCacheTTL: duration for which IPs without RDNS entry aren't looked up again. Example: 5m
 }
 
 // AddOutputs allows more control over to which outputs the
@@ -116,6 +184,12 @@ func (a *Args) AddOutputs(outputs ...io.Writer) *Args {
 	return a
 }
 
+// SetConditionMacros sets the macros which can be referenced in the condition (via $name)
+func (a *Args) SetConditionMacros(macros conditions.Macros) *Args {
+	a.conditionMacros = macros
+	return a
+}
+
 // String formats aruguments in human-readable form
 func (a *Args) String() string {
 	str := fmt.Sprintf("{type: %s, ifaces: %s",
@@ -130,11 +204,20 @@ func (a *Args) String() string {
 		a.First,
 		a.Last,
 	)
+	if len(a.Windows) > 0 {
+		str += fmt.Sprintf(", windows: %s", a.Windows)
+	}
 	if a.DNSResolution.Enabled {
 		str += fmt.Sprintf(", dns-resolution: %t, dns-timeout: %s, dns-rows-resolved: %d",
 			a.DNSResolution.Enabled, a.DNSResolution.Timeout.Round(time.Second), a.DNSResolution.MaxRows,
 		)
 	}
+	if len(a.Enrich) > 0 {
+		str += fmt.Sprintf(", enrich: %s", a.Enrich)
+	}
+	if len(a.Filter) > 0 {
+		str += fmt.Sprintf(", filter: %s", a.Filter)
+	}
 	if a.Caller != "" {
 		str += fmt.Sprintf(", caller: %s", a.Caller)
 	}
@@ -164,24 +247,45 @@ func (a *Args) Prepare(writers ...io.Writer) (*Statement, error) {
 		DNSResolution: a.DNSResolution,
 		Condition:     a.Condition,
 		LowMem:        a.LowMem,
+		RawUnits:      a.RawUnits,
+		TotalRow:      a.TotalRow,
+		Subtotals:     a.Subtotals,
+		Explain:       a.Explain,
 		Caller:        a.Caller,
 		Live:          a.Live,
+		Tenant:        a.Tenant,
+		OutputFile:    a.OutputFile,
 		Output:        os.Stdout, // by default, we write results to the console
 	}
 
 	var err error
 
+	if err = info.ValidateTenant(a.Tenant); err != nil {
+		return s, fmt.Errorf("%w: %w", ErrInvalidArgs, err)
+	}
+	if err = a.IfaceGroups.Validate(); err != nil {
+		return s, fmt.Errorf("%w: %w", ErrInvalidArgs, err)
+	}
+
 	// verify config format
-	_, verifies := PermittedFormats[a.Format]
+	_, verifies := results.LookupFormatter(a.Format)
 	if !verifies {
-		return s, fmt.Errorf("unknown output format '%s'", a.Format)
+		return s, fmt.Errorf("%w: unknown output format '%s'", ErrInvalidArgs, a.Format)
 	}
 	s.Format = a.Format
 
+	// verify that the enrichers exist
+	for _, enricher := range a.Enrich {
+		if _, exists := results.LookupEnricher(enricher); !exists {
+			return s, fmt.Errorf("%w: unknown enricher '%s'", ErrInvalidArgs, enricher)
+		}
+	}
+	s.Enrich = a.Enrich
+
 	// assign sort order and direction
 	s.SortBy, verifies = PermittedSortBy[a.SortBy]
 	if !verifies {
-		return s, fmt.Errorf("unknown sorting parameter '%s' specified", a.SortBy)
+		return s, fmt.Errorf("%w: unknown sorting parameter '%s' specified", ErrInvalidArgs, a.SortBy)
 	}
 
 	// the query type is parsed here already in order to validate if the query contains
@@ -189,12 +293,12 @@ func (a *Args) Prepare(writers ...io.Writer) (*Statement, error) {
 	var selector types.LabelSelector
 	s.attributes, selector, err = types.ParseQueryType(a.Query)
 	if err != nil {
-		return s, fmt.Errorf("failed to parse query type: %w", err)
+		return s, fmt.Errorf("%w: %w", ErrInvalidQueryType, err)
 	}
 
-	// insert iface attribute here in case multiple interfaces where specified and the
-	// interface column was not added as an attribute
-	if (len(s.Ifaces) > 1 || strings.Contains(a.Ifaces, "any")) &&
+	// insert iface attribute here in case multiple interfaces where specified (or possibly
+	// selected by a wildcard / negation) and the interface column was not added as an attribute
+	if (len(s.Ifaces) > 1 || strings.Contains(a.Ifaces, "any") || strings.ContainsAny(a.Ifaces, "*?[!=")) &&
 		!strings.Contains(a.Query, "iface") {
 		selector.Iface = true
 	}
@@ -210,7 +314,16 @@ func (a *Args) Prepare(writers ...io.Writer) (*Statement, error) {
 	// parse time bound
 	s.First, s.Last, err = ParseTimeRange(a.First, a.Last)
 	if err != nil {
-		return s, err
+		return s, fmt.Errorf("%w: %w", ErrInvalidArgs, err)
+	}
+
+	// time windows replace the time bounds, which are set to the span of all windows
+	if len(a.Windows) > 0 {
+		s.Windows, err = ParseTimeWindows(a.Windows)
+		if err != nil {
+			return s, fmt.Errorf("%w: %w", ErrInvalidArgs, err)
+		}
+		s.First, s.Last = s.Windows.Span()
 	}
 
 	switch {
@@ -224,6 +337,50 @@ func (a *Args) Prepare(writers ...io.Writer) (*Statement, error) {
 		s.Direction = types.DirectionBoth
 	}
 
+	// the filters can only be applied to the hostnames / enriched columns present in the rows
+	if len(a.Filter) > 0 {
+		if s.rowFilters, err = results.ParseRowFilters(a.Filter); err != nil {
+			return s, fmt.Errorf("%w: %w", ErrInvalidArgs, err)
+		}
+		if a.Approx {
+			return s, fmt.Errorf("%w: filters are not supported for approximate aggregation", ErrInvalidArgs)
+		}
+		var hasSIP, hasDIP bool
+		for _, attribute := range s.attributes {
+			hasSIP = hasSIP || attribute.Name() == types.SIPName
+			hasDIP = hasDIP || attribute.Name() == types.DIPName
+		}
+		for _, filter := range s.rowFilters {
+			if filter.IsHostnameFilter() {
+				if (filter.Field == results.FilterSrcHostname && !hasSIP) ||
+					(filter.Field == results.FilterDstHostname && !hasDIP) ||
+					(!hasSIP && !hasDIP) {
+					return s, fmt.Errorf("%w: filter '%s' requires sip / dip to be queried", ErrInvalidArgs, filter)
+				}
+
+				// the hostnames only exist if they are resolved
+				s.DNSResolution.Enabled = true
+				continue
+			}
+			if filter.Field == results.FilterProcess {
+				if !s.LabelSelector.Process {
+					return s, fmt.Errorf("%w: filter '%s' requires process to be queried", ErrInvalidArgs, filter)
+				}
+				continue
+			}
+			if filter.IsLabelColumnFilter() {
+				if !slices.Contains(s.LabelSelector.Columns, filter.Field) {
+					return s, fmt.Errorf("%w: filter '%s' requires %s to be queried", ErrInvalidArgs, filter, filter.Field)
+				}
+				continue
+			}
+			if !slices.Contains(results.EnrichmentColumns(s.Enrich, s.attributes), filter.Field) {
+				return s, fmt.Errorf("%w: filter '%s' refers to a column which isn't enriched", ErrInvalidArgs, filter)
+			}
+		}
+		s.Filter = a.Filter
+	}
+
 	// check resolve timeout and DNS
 	if s.DNSResolution.Enabled {
 		err := dns.CheckDNS()
@@ -231,41 +388,119 @@ func (a *Args) Prepare(writers ...io.Writer) (*Statement, error) {
 			return s, fmt.Errorf("DNS warning: %w", err)
 		}
 		if !(0 < s.DNSResolution.Timeout) {
-			return s, fmt.Errorf("resolve-timeout must be greater than 0")
+			return s, fmt.Errorf("%w: resolve-timeout must be greater than 0", ErrInvalidArgs)
 		}
 		if !(0 < s.DNSResolution.MaxRows) {
-			return s, fmt.Errorf("resolve-rows must be greater than 0")
+			return s, fmt.Errorf("%w: resolve-rows must be greater than 0", ErrInvalidArgs)
+		}
+		for _, resolver := range s.DNSResolution.Resolvers {
+			if err := dns.ValidateServer(resolver); err != nil {
+				return s, fmt.Errorf("%w: %w", ErrInvalidArgs, err)
+			}
+		}
+		if s.DNSResolution.MaxConcurrency < 0 {
+			return s, fmt.Errorf("%w: resolve-concurrency must not be negative", ErrInvalidArgs)
+		}
+		if s.DNSResolution.NegativeCacheTTL < 0 {
+			return s, fmt.Errorf("%w: negative cache TTL must not be negative", ErrInvalidArgs)
 		}
 	}
 
 	// sanitize conditional if one was provided
-	a.Condition, err = conditions.SanitizeUserInput(a.Condition)
+	a.Condition, err = conditions.SanitizeUserInput(a.Condition, conditions.WithMacros(a.conditionMacros))
 	if err != nil {
-		return s, fmt.Errorf("failed to sanitize condition: %w", err)
+		return s, fmt.Errorf("%w: %w", ErrInvalidCondition, err)
 	}
 	s.Condition = a.Condition
 
 	// check memory flag
 	if !(0 < a.MaxMemPct && a.MaxMemPct <= 100) {
-		return s, fmt.Errorf("invalid memory percentage of '%d' provided", a.MaxMemPct)
+		return s, fmt.Errorf("%w: invalid memory percentage of '%d' provided", ErrInvalidArgs, a.MaxMemPct)
 	}
 	s.MaxMemPct = a.MaxMemPct
 
+	if a.MaxBlocksPerSec < 0 {
+		return s, fmt.Errorf("%w: invalid read rate limit of '%d' blocks per second provided", ErrInvalidArgs, a.MaxBlocksPerSec)
+	}
+	s.MaxBlocksPerSec = a.MaxBlocksPerSec
+
+	if a.Workers < 0 {
+		return s, fmt.Errorf("%w: invalid number of workers '%d' provided", ErrInvalidArgs, a.Workers)
+	}
+	s.Workers = a.Workers
+
+	// approximate aggregation only tracks the flows with the highest counters
+	if a.Approx && (s.SortBy == results.SortTime || s.SortAscending || a.SortAscending) {
+		return s, fmt.Errorf("%w: approximate aggregation requires a descending sort by bytes or packets", ErrInvalidArgs)
+	}
+	s.Approx = a.Approx
+
+	// the distinct attribute is queried in addition to the query attributes
+	if a.Distinct != "" {
+		distinct, err := types.NewAttribute(a.Distinct)
+		if err != nil {
+			return s, fmt.Errorf("%w: invalid distinct attribute: %w", ErrInvalidArgs, err)
+		}
+		for _, attribute := range s.attributes {
+			if attribute.Name() == distinct.Name() {
+				return s, fmt.Errorf("%w: distinct attribute '%s' is already part of the query", ErrInvalidArgs, distinct.Name())
+			}
+		}
+		if s.Approx {
+			return s, fmt.Errorf("%w: distinct counting is not supported for approximate aggregation", ErrInvalidArgs)
+		}
+		s.Distinct = distinct.Name()
+	}
+
+	// the time distribution is computed from the flows of each block in the queried range
+	if a.Distribution {
+		if selector.Timestamp {
+			return s, fmt.Errorf("%w: time distribution is not supported for queries including time", ErrInvalidArgs)
+		}
+		if s.Approx {
+			return s, fmt.Errorf("%w: time distribution is not supported for approximate aggregation", ErrInvalidArgs)
+		}
+		s.Distribution = true
+	}
+	s.Dedup = a.Dedup
+
 	// check limits flag
 	if !(0 < a.NumResults) {
-		return s, errors.New("the printed row limit must be greater than 0")
+		return s, fmt.Errorf("%w: the printed row limit must be greater than 0", ErrInvalidArgs)
 	}
 	s.NumResults = a.NumResults
 
+	// check IP version restriction
+	switch a.IPVersion {
+	case 0:
+	case 4:
+		s.IPVersion = types.IPVersionV4
+	case 6:
+		s.IPVersion = types.IPVersionV6
+	default:
+		return s, fmt.Errorf("%w: invalid IP version '%d' provided (must be 4 or 6)", ErrInvalidArgs, a.IPVersion)
+	}
+
+	// check capture quality restriction
+	if a.MaxDropPct < 0 || a.MaxDropPct > 100 {
+		return s, fmt.Errorf("%w: invalid maximum drop percentage '%g' provided (must be between 0 and 100)", ErrInvalidArgs, a.MaxDropPct)
+	}
+	s.MaxDropPct = a.MaxDropPct
+	s.Verify = a.Verify
+	s.Anomalies = a.Anomalies
+
 	// check for consistent use of the live flag
-	if s.Live && s.Last != types.MaxTime.Unix() {
-		return s, errors.New("live query not possible if query has last timestamp")
+	if s.Live && (s.Last != types.MaxTime.Unix() || len(s.Windows) > 0) {
+		return s, fmt.Errorf("%w: live query not possible if query has last timestamp or time windows", ErrInvalidArgs)
 	}
 
 	// fan-out query results in case multiple writers were supplied
 	writers = append(writers, a.outputs...)
 	if len(writers) > 0 {
 		s.Output = io.MultiWriter(writers...)
+	} else if s.OutputFile != "" {
+		// results written to a file aren't printed to the console as well
+		s.Output = io.Discard
 	}
 
 	return s, nil
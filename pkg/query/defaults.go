@@ -21,13 +21,6 @@ var (
 	DefaultSortBy         = "bytes"
 )
 
-// PermittedFormats stores all supported output formats
-var PermittedFormats = map[string]struct{}{
-	"txt":  {},
-	"json": {},
-	"csv":  {},
-}
-
 // PermittedSortBy sorts all permitted sorting orders
 var PermittedSortBy = map[string]results.SortOrder{
 	"bytes":   results.SortTraffic,
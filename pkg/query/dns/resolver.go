@@ -0,0 +1,329 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultDNSPort = "53"
+	defaultDoTPort = "853"
+
+	dohContentType = "application/dns-message"
+)
+
+// dohClient is the client used for DNS over HTTPS requests
+var dohClient = &http.Client{}
+
+// ErrInvalidServer is returned for DNS server addresses that can't be parsed
+var ErrInvalidServer = errors.New("invalid DNS server address")
+
+// Resolver performs reverse lookups against a configurable set of DNS servers. If no servers are
+// configured, the resolver of the host is used
+type Resolver struct {
+	resolvers []*net.Resolver
+	next      atomic.Uint32
+
+	maxConcurrency   int
+	negativeCacheTTL time.Duration
+}
+
+// ResolverOption configures a Resolver
+type ResolverOption func(*Resolver) error
+
+// WithServers sets the DNS servers the lookups are distributed across (round-robin). Supported
+// addresses are plain DNS ("1.1.1.1", "1.1.1.1:53"), DNS over TLS ("tls://1.1.1.1:853") and DNS over
+// HTTPS ("https://cloudflare-dns.com/dns-query")
+func WithServers(addrs ...string) ResolverOption {
+	return func(r *Resolver) error {
+		for _, addr := range addrs {
+			resolver, err := newServerResolver(addr)
+			if err != nil {
+				return err
+			}
+			r.resolvers = append(r.resolvers, resolver)
+		}
+		return nil
+	}
+}
+
+// WithMaxConcurrency limits the number of lookups performed in parallel (0 means no limit)
+func WithMaxConcurrency(n int) ResolverOption {
+	return func(r *Resolver) error {
+		r.maxConcurrency = n
+		return nil
+	}
+}
+
+// WithNegativeCacheTTL caches IPs without RDNS entry (or failing lookups) for the provided duration,
+// so that they aren't looked up again by subsequent lookups (0 disables the cache)
+func WithNegativeCacheTTL(ttl time.Duration) ResolverOption {
+	return func(r *Resolver) error {
+		r.negativeCacheTTL = ttl
+		return nil
+	}
+}
+
+// NewResolver creates a new Resolver
+func NewResolver(opts ...ResolverOption) (*Resolver, error) {
+	r := &Resolver{}
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			return nil, err
+		}
+	}
+	if len(r.resolvers) == 0 {
+		r.resolvers = []*net.Resolver{net.DefaultResolver}
+	}
+	return r, nil
+}
+
+// ValidateServer checks if the DNS server address is supported (see WithServers())
+func ValidateServer(addr string) error {
+	_, err := newServerResolver(addr)
+	return err
+}
+
+// TimedReverseLookup performs a reverse lookup on the given ips. The lookup takes at most timeout time,
+// afterwards it is aborted (see the package level TimedReverseLookup() for details on the result)
+func (r *Resolver) TimedReverseLookup(ips []string, timeout time.Duration) (ipToDomain map[string]string) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Compute set of ips so we look up each unique IP exactly once
+	// This assumes that the ips are provided in a normalized format.
+	ipset := make(map[string]struct{})
+	for _, ip := range ips {
+		if !r.isCachedNegative(ip) {
+			ipset[ip] = struct{}{}
+		}
+	}
+
+	var sem chan struct{}
+	if r.maxConcurrency > 0 {
+		sem = make(chan struct{}, r.maxConcurrency)
+	}
+
+	// the channel is buffered for all lookups so that none of them blocks once the
+	// timeout has passed
+	lookupChannel := make(chan LookupResult, len(ipset))
+	for ip := range ipset {
+		go func(ip string) {
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				case <-ctx.Done():
+					lookupChannel <- LookupResult{IP: ip}
+					return
+				}
+			}
+			lookupChannel <- r.lookup(ctx, ip)
+		}(ip)
+	}
+
+	ipToDomain = make(map[string]string)
+	for pending := len(ipset); pending > 0; pending-- {
+		// Aggregate results while waiting for timeout.
+		select {
+		case result := <-lookupChannel:
+			if result.Success {
+				ipToDomain[result.IP] = result.Domain
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+	return
+}
+
+func (r *Resolver) lookup(ctx context.Context, ip string) LookupResult {
+	resolver := r.resolvers[int(r.next.Add(1))%len(r.resolvers)]
+
+	result := LookupResult{IP: ip}
+	domains, err := resolver.LookupAddr(ctx, ip)
+	if err == nil && len(domains) > 0 {
+		result.Success = true
+		result.Domain = domains[0]
+		return result
+	}
+
+	// lookups interrupted by the timeout don't tell anything about the IP
+	if ctx.Err() == nil {
+		r.cacheNegative(ip)
+	}
+	return result
+}
+
+// the negative cache is shared by all resolvers, since the absence of an RDNS entry
+// is a property of the IP rather than of the server queried
+var negativeCache = struct {
+	sync.Mutex
+	expiry map[string]time.Time
+}{
+	expiry: make(map[string]time.Time),
+}
+
+func (r *Resolver) isCachedNegative(ip string) bool {
+	if r.negativeCacheTTL <= 0 {
+		return false
+	}
+
+	negativeCache.Lock()
+	defer negativeCache.Unlock()
+
+	expiry, exists := negativeCache.expiry[ip]
+	if exists && time.Now().After(expiry) {
+		delete(negativeCache.expiry, ip)
+		return false
+	}
+	return exists
+}
+
+func (r *Resolver) cacheNegative(ip string) {
+	if r.negativeCacheTTL <= 0 {
+		return
+	}
+
+	negativeCache.Lock()
+	negativeCache.expiry[ip] = time.Now().Add(r.negativeCacheTTL)
+	negativeCache.Unlock()
+}
+
+// newServerResolver creates a resolver sending all queries to the DNS server at addr
+func newServerResolver(addr string) (*net.Resolver, error) {
+	var dial func(ctx context.Context, network string) (net.Conn, error)
+
+	switch {
+	case strings.HasPrefix(addr, "https://"):
+		u, err := url.Parse(addr)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("%w: `%s`", ErrInvalidServer, addr)
+		}
+		dial = func(ctx context.Context, _ string) (net.Conn, error) {
+			return &dohConn{ctx: ctx, client: dohClient, url: u.String()}, nil
+		}
+	case strings.HasPrefix(addr, "tls://"):
+		hostPort, err := withDefaultPort(strings.TrimPrefix(addr, "tls://"), defaultDoTPort)
+		if err != nil {
+			return nil, fmt.Errorf("%w: `%s`: %w", ErrInvalidServer, addr, err)
+		}
+		host, _, _ := net.SplitHostPort(hostPort)
+		dialer := &tls.Dialer{Config: &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}}
+		dial = func(ctx context.Context, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "tcp", hostPort)
+		}
+	default:
+		hostPort, err := withDefaultPort(strings.TrimPrefix(addr, "udp://"), defaultDNSPort)
+		if err != nil {
+			return nil, fmt.Errorf("%w: `%s`: %w", ErrInvalidServer, addr, err)
+		}
+		var dialer net.Dialer
+		dial = func(ctx context.Context, network string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, hostPort)
+		}
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dial(ctx, network)
+		},
+	}, nil
+}
+
+func withDefaultPort(addr, port string) (string, error) {
+	if strings.Contains(addr, "://") {
+		return "", errors.New("unsupported scheme")
+	}
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr, nil
+	}
+	host := strings.Trim(addr, "[]")
+	if host == "" {
+		return "", errors.New("empty host")
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// dohConn relays the DNS messages of the Go resolver to a DNS over HTTPS server (RFC 8484). Since it
+// isn't a net.PacketConn, the resolver uses the stream framing (i.e. length prefixed messages)
+type dohConn struct {
+	ctx    context.Context
+	client *http.Client
+	url    string
+
+	request  bytes.Buffer
+	response *bytes.Reader
+}
+
+// Write buffers the length prefixed DNS message and sends it once it is complete
+func (c *dohConn) Write(b []byte) (int, error) {
+	c.request.Write(b)
+
+	buf := c.request.Bytes()
+	if len(buf) < 2 {
+		return len(b), nil
+	}
+	msgLen := int(buf[0])<<8 | int(buf[1])
+	if len(buf) < 2+msgLen {
+		return len(b), nil
+	}
+	msg := buf[2 : 2+msgLen]
+
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, c.url, bytes.NewReader(msg))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", dohContentType)
+	req.Header.Set("Accept", dohContentType)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("DNS over HTTPS request failed with status %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 0xffff))
+	if err != nil {
+		return 0, err
+	}
+	c.request.Reset()
+	c.response = bytes.NewReader(append([]byte{byte(len(body) >> 8), byte(len(body))}, body...))
+
+	return len(b), nil
+}
+
+// Read returns the length prefixed response to the last DNS message
+func (c *dohConn) Read(b []byte) (int, error) {
+	if c.response == nil {
+		return 0, io.EOF
+	}
+	return c.response.Read(b)
+}
+
+func (c *dohConn) Close() error                       { return nil }
+func (c *dohConn) LocalAddr() net.Addr                { return dohAddr(c.url) }
+func (c *dohConn) RemoteAddr() net.Addr               { return dohAddr(c.url) }
+func (c *dohConn) SetDeadline(_ time.Time) error      { return nil }
+func (c *dohConn) SetReadDeadline(_ time.Time) error  { return nil }
+func (c *dohConn) SetWriteDeadline(_ time.Time) error { return nil }
+
+type dohAddr string
+
+func (a dohAddr) Network() string { return "https" }
+func (a dohAddr) String() string  { return string(a) }
@@ -0,0 +1,137 @@
+package dns
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testDomain = "host.example.com."
+
+// ptrResponse answers the (PTR) query in msg with testDomain (or NXDOMAIN)
+func ptrResponse(msg []byte, nxdomain bool) []byte {
+	// skip the header and the question name
+	end := 12
+	for end < len(msg) && msg[end] != 0 {
+		end += int(msg[end]) + 1
+	}
+	end += 5 // zero label, qtype and qclass
+
+	resp := append([]byte{}, msg[:end]...)
+	resp[2] |= 0x80 // QR
+	resp[3] = 0x80  // RA, rcode 0
+	binary.BigEndian.PutUint16(resp[6:], 1)
+	binary.BigEndian.PutUint16(resp[8:], 0)
+	binary.BigEndian.PutUint16(resp[10:], 0)
+	if nxdomain {
+		resp[3] |= 0x03
+		binary.BigEndian.PutUint16(resp[6:], 0)
+		return resp
+	}
+
+	var rdata []byte
+	for _, label := range []string{"host", "example", "com"} {
+		rdata = append(rdata, byte(len(label)))
+		rdata = append(rdata, label...)
+	}
+	rdata = append(rdata, 0)
+
+	resp = append(resp, 0xc0, 0x0c, 0, 12, 0, 1, 0, 0, 0, 60)
+	resp = binary.BigEndian.AppendUint16(resp, uint16(len(rdata)))
+	return append(resp, rdata...)
+}
+
+func TestValidateServer(t *testing.T) {
+	for _, addr := range []string{
+		"1.1.1.1", "1.1.1.1:5353", "udp://1.1.1.1", "::1", "[::1]:53", "dns.example.com",
+		"tls://1.1.1.1", "tls://dns.example.com:853", "https://cloudflare-dns.com/dns-query",
+	} {
+		require.Nil(t, ValidateServer(addr), addr)
+	}
+	for _, addr := range []string{"", "[]", "ftp://1.1.1.1", "tls://", "https://", "https:///dns-query"} {
+		require.ErrorIs(t, ValidateServer(addr), ErrInvalidServer, addr)
+	}
+}
+
+func TestResolverDoH(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for max := maxInFlight.Load(); n > max && !maxInFlight.CompareAndSwap(max, n); max = maxInFlight.Load() {
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		msg, err := io.ReadAll(r.Body)
+		if err != nil || r.Header.Get("Content-Type") != dohContentType {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", dohContentType)
+		_, _ = w.Write(ptrResponse(msg, false))
+	}))
+	defer server.Close()
+
+	client := dohClient
+	dohClient = server.Client()
+	defer func() { dohClient = client }()
+
+	resolver, err := NewResolver(WithServers(server.URL), WithMaxConcurrency(2))
+	require.Nil(t, err)
+
+	ips := []string{"192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4", "192.0.2.1"}
+	ips2domains := resolver.TimedReverseLookup(ips, 5*time.Second)
+	require.Equal(t, map[string]string{
+		"192.0.2.1": testDomain,
+		"192.0.2.2": testDomain,
+		"192.0.2.3": testDomain,
+		"192.0.2.4": testDomain,
+	}, ips2domains)
+	require.LessOrEqual(t, maxInFlight.Load(), int32(2))
+}
+
+func TestResolverNegativeCache(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer conn.Close()
+
+	// answer the first query with NXDOMAIN and all subsequent ones with a PTR record
+	var queries atomic.Int32
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = conn.WriteTo(ptrResponse(buf[:n], queries.Add(1) == 1), addr)
+		}
+	}()
+
+	ip := "198.51.100.1"
+	negativeCache.Lock()
+	delete(negativeCache.expiry, ip)
+	negativeCache.Unlock()
+
+	resolver, err := NewResolver(WithServers(conn.LocalAddr().String()), WithNegativeCacheTTL(time.Minute))
+	require.Nil(t, err)
+
+	require.Empty(t, resolver.TimedReverseLookup([]string{ip}, 5*time.Second))
+	require.True(t, resolver.isCachedNegative(ip))
+
+	// the cached IP isn't looked up again
+	require.Empty(t, resolver.TimedReverseLookup([]string{ip}, 5*time.Second))
+	require.Equal(t, int32(1), queries.Load())
+
+	// resolvers without negative cache ignore the entry
+	uncached, err := NewResolver(WithServers(conn.LocalAddr().String()))
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{ip: testDomain}, uncached.TimedReverseLookup([]string{ip}, 5*time.Second))
+}
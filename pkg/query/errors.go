@@ -0,0 +1,39 @@
+package query
+
+import "errors"
+
+// Error classes returned by query preparation and execution. Errors are wrapped, hence callers
+// should use errors.Is() to branch on them instead of matching the error message
+var (
+
+	// ErrInvalidArgs denotes that the query arguments failed validation
+	ErrInvalidArgs = errors.New("invalid query arguments")
+
+	// ErrInvalidQueryType denotes that the query type (attributes / labels) could not be parsed
+	ErrInvalidQueryType = errors.New("invalid query type")
+
+	// ErrInvalidCondition denotes that the condition could not be parsed
+	ErrInvalidCondition = errors.New("invalid condition")
+
+	// ErrInvalidInterface denotes that an interface name is empty or malformed
+	ErrInvalidInterface = errors.New("invalid interface")
+
+	// ErrNoInterfaces denotes that no interfaces have been specified / are available for a query
+	ErrNoInterfaces = errors.New("no interfaces specified")
+
+	// ErrQueryTimeout denotes that the query did not complete before its deadline
+	ErrQueryTimeout = errors.New("query timed out")
+
+	// ErrMemoryBreach denotes that the query was aborted because it exceeded the memory limit
+	ErrMemoryBreach = errors.New("memory limit exceeded")
+)
+
+// IsClientError returns whether an error was caused by the query itself (as opposed to a failure
+// during its execution), i.e. running the same query again will not succeed
+func IsClientError(err error) bool {
+	return errors.Is(err, ErrInvalidArgs) ||
+		errors.Is(err, ErrInvalidQueryType) ||
+		errors.Is(err, ErrInvalidCondition) ||
+		errors.Is(err, ErrInvalidInterface) ||
+		errors.Is(err, ErrNoInterfaces)
+}
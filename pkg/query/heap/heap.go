@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"runtime"
 	"runtime/debug"
+	"sync/atomic"
 	"time"
 )
 
@@ -26,8 +27,42 @@ var (
 	ErrorMemoryBreach = errors.New("maximum memory breach")
 )
 
-// Watch makes sure to alert on too high memory consumption
-func Watch(ctx context.Context, maxAllowedMemPct int) (errors chan error) {
+// Usage tracks the peak memory used by the process (the quantity checked against the maximum
+// allowed memory usage)
+type Usage struct {
+	peak atomic.Uint64
+}
+
+// Peak returns the peak memory usage (in bytes) sampled so far
+func (u *Usage) Peak() uint64 {
+	return u.peak.Load()
+}
+
+// Sample reads the current memory usage (in bytes) and updates the peak accordingly
+func (u *Usage) Sample() uint64 {
+	var m runtime.MemStats
+	return u.sample(&m)
+}
+
+func (u *Usage) sample(m *runtime.MemStats) uint64 {
+	runtime.ReadMemStats(m)
+	usedMem := m.Sys - m.HeapReleased
+
+	for peak := u.peak.Load(); usedMem > peak; peak = u.peak.Load() {
+		if u.peak.CompareAndSwap(peak, usedMem) {
+			break
+		}
+	}
+	return usedMem
+}
+
+// Watch makes sure to alert on too high memory consumption. The memory usage is sampled
+// periodically and recorded in usage (if provided)
+func Watch(ctx context.Context, maxAllowedMemPct int, usage *Usage) (errors chan error) {
+	if usage == nil {
+		usage = new(Usage)
+	}
+
 	errors = make(chan error)
 	go func() {
 		// obtain physical memory of this host
@@ -47,19 +82,21 @@ func Watch(ctx context.Context, maxAllowedMemPct int) (errors chan error) {
 		m := runtime.MemStats{}
 		lastGC := time.Now()
 
+		// record the usage at the start of the query (which may finish before the first tick)
+		usage.sample(&m)
+
 		for {
 			select {
 			case <-memTicker.C:
-				runtime.ReadMemStats(&m)
-
-				usedMem := m.Sys - m.HeapReleased
+				usedMem := usage.sample(&m)
 				maxAllowedMem := uint64(float64(maxAllowedMemPct) * physMem / 100)
 
 				// Check if current memory consumption is higher than maximum allowed percentage of the available
 				// physical memory
 				if usedMem/1024 > maxAllowedMem {
 					memTicker.Stop()
-					errors <- fmt.Errorf("%w: %v%% of physical memory", ErrorMemoryBreach, maxAllowedMemPct)
+					errors <- fmt.Errorf("%w: %v%% of physical memory (%d MiB used, %d MiB allowed)",
+						ErrorMemoryBreach, maxAllowedMemPct, usedMem/(1024*1024), maxAllowedMem/1024)
 					return
 				}
 
@@ -1,6 +1,11 @@
 package query
 
-import "time"
+import (
+	"time"
+
+	"github.com/els0r/goProbe/pkg/goDB/conditions"
+	"github.com/els0r/goProbe/pkg/goDB/info"
+)
 
 // Option allows to modify an existing Args container
 type Option func(*Args)
@@ -8,6 +13,11 @@ type Option func(*Args)
 // WithCondition sets the condition argument
 func WithCondition(c string) Option { return func(a *Args) { a.Condition = c } }
 
+// WithConditionMacros sets the macros which can be referenced in the condition (via $name)
+func WithConditionMacros(m conditions.Macros) Option {
+	return func(a *Args) { a.conditionMacros = m }
+}
+
 // WithDirectionIn considers the incoming flows
 func WithDirectionIn() Option { return func(a *Args) { a.In = true } }
 
@@ -23,6 +33,9 @@ func WithFirst(f string) Option { return func(a *Args) { a.First = f } }
 // WithLast sets the last timestampt to consider
 func WithLast(l string) Option { return func(a *Args) { a.Last = l } }
 
+// WithWindows sets the time windows to query (see Args.Windows)
+func WithWindows(windows ...string) Option { return func(a *Args) { a.Windows = windows } }
+
 // WithFormat sets the output format
 func WithFormat(f string) Option { return func(a *Args) { a.Format = f } }
 
@@ -35,12 +48,27 @@ func WithNumResults(n uint64) Option { return func(a *Args) { a.NumResults = n }
 // WithSortAscending sorts rows ascending
 func WithSortAscending() Option { return func(a *Args) { a.SortAscending = true } }
 
+// WithRawUnits prints sizes and counts as exact integers
+func WithRawUnits() Option { return func(a *Args) { a.RawUnits = true } }
+
+// WithTotalRow appends a row summing up the printed rows to the output
+func WithTotalRow() Option { return func(a *Args) { a.TotalRow = true } }
+
+// WithSubtotals groups the printed rows by their first column and appends subtotal rows to the output
+func WithSubtotals() Option { return func(a *Args) { a.Subtotals = true } }
+
+// WithEnrich sets the enrichers run over the final rows before they are printed
+func WithEnrich(enrichers ...string) Option { return func(a *Args) { a.Enrich = enrichers } }
+
 // WithList sets the list parameter (only lists interfaces)
 func WithList() Option { return func(a *Args) { a.List = true } }
 
 // WithVersion sets the version parameter (print version and exit)
 func WithVersion() Option { return func(a *Args) { a.Version = true } }
 
+// WithExplain only plans the query (instead of running it)
+func WithExplain() Option { return func(a *Args) { a.Explain = true } }
+
 // WithResolve enables reverse lookups of IPs
 func WithResolve() Option { return func(a *Args) { a.DNSResolution.Enabled = true } }
 
@@ -50,8 +78,65 @@ func WithResolveTimeout(t time.Duration) Option { return func(a *Args) { a.DNSRe
 // WithResolveRows sets the amount of rows for which lookups should be attempted
 func WithResolveRows(r int) Option { return func(a *Args) { a.DNSResolution.MaxRows = r } }
 
+// WithResolvers sets the DNS servers used for reverse lookups (instead of the resolver of the host)
+func WithResolvers(servers ...string) Option {
+	return func(a *Args) { a.DNSResolution.Resolvers = servers }
+}
+
+// WithResolveConcurrency limits the number of reverse lookups performed in parallel
+func WithResolveConcurrency(n int) Option {
+	return func(a *Args) { a.DNSResolution.MaxConcurrency = n }
+}
+
+// WithResolveNegativeCacheTTL sets the duration for which IPs without RDNS entry aren't looked up again
+func WithResolveNegativeCacheTTL(ttl time.Duration) Option {
+	return func(a *Args) { a.DNSResolution.NegativeCacheTTL = ttl }
+}
+
 // WithMaxMemPct is an advanced parameter to restrict system memory usage to a fixed percentage of the available memory during query processing
 func WithMaxMemPct(m int) Option { return func(a *Args) { a.MaxMemPct = m } }
 
+// WithMaxBlocksPerSec throttles the number of blocks read from disk per second during query processing
+func WithMaxBlocksPerSec(n int) Option { return func(a *Args) { a.MaxBlocksPerSec = n } }
+
+// WithWorkers sets the number of workers reading the blocks of each interface concurrently during query processing
+func WithWorkers(n int) Option { return func(a *Args) { a.Workers = n } }
+
+// WithApprox approximates the top results (with bounded memory) instead of aggregating all flows exactly
+func WithApprox() Option { return func(a *Args) { a.Approx = true } }
+
+// WithDistinct counts the distinct values of an attribute (e.g. dip) per row
+func WithDistinct(attribute string) Option { return func(a *Args) { a.Distinct = attribute } }
+
+// WithFilter filters the rows on their resolved hostnames / enriched columns before they are limited
+func WithFilter(filters ...string) Option { return func(a *Args) { a.Filter = filters } }
+
+// WithDistribution computes the time distribution of the bytes of each row across the queried range
+func WithDistribution() Option { return func(a *Args) { a.Distribution = true } }
+
+// WithDedup counts flows seen identically on several interfaces (e.g. mirrored traffic) only once
+func WithDedup() Option { return func(a *Args) { a.Dedup = true } }
+
 // WithCaller sets the name of the program/tool calling the query
 func WithCaller(c string) Option { return func(a *Args) { a.Caller = c } }
+
+// WithIPVersion restricts the query to flows of one IP version (4 or 6)
+func WithIPVersion(v int) Option { return func(a *Args) { a.IPVersion = v } }
+
+// WithMaxDropPct excludes the blocks whose capture dropped a higher percentage of packets
+func WithMaxDropPct(pct float64) Option { return func(a *Args) { a.MaxDropPct = pct } }
+
+// WithVerify checks the consistency of the columns of each block, reporting inconsistent blocks as corruptions
+func WithVerify() Option { return func(a *Args) { a.Verify = true } }
+
+// WithAnomalies compares the traffic of each interface with its baseline, flagging unusual volumes
+func WithAnomalies() Option { return func(a *Args) { a.Anomalies = true } }
+
+// WithTenant restricts the query to the flows of a tenant
+func WithTenant(t string) Option { return func(a *Args) { a.Tenant = t } }
+
+// WithIfaceGroups sets the interface groups which can be selected in addition to the ones stored in the DB
+func WithIfaceGroups(groups info.Groups) Option { return func(a *Args) { a.IfaceGroups = groups } }
+
+// WithExport requests the server to export the result to a destination instead of returning it
+func WithExport(destination string) Option { return func(a *Args) { a.Export = destination } }
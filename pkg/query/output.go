@@ -0,0 +1,61 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// outputFilePermissions denotes the permissions of result files, which are usually consumed by
+// other tools
+const outputFilePermissions = 0644
+
+// WriteFileAtomic calls write with a writer to a temporary file in the directory of path. Only if
+// write succeeds, the temporary file replaces the file at path (via rename), so that readers of
+// the file never observe partially written results. Otherwise, the temporary file is removed and
+// an existing file at path remains untouched
+func WriteFileAtomic(path string, write func(w io.Writer) error) (err error) {
+	path = filepath.Clean(path)
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			err = errors.Join(err, os.Remove(f.Name()))
+		}
+	}()
+
+	if err = write(f); err != nil {
+		return err
+	}
+	if err = f.Chmod(outputFilePermissions); err != nil {
+		return fmt.Errorf("failed to set output file permissions: %w", err)
+	}
+	if err = f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync output file: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("failed to close output file: %w", err)
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace output file: %w", err)
+	}
+	return nil
+}
+
+// WriteOutput calls write with the output of the statement. If an output file is set, the output
+// is written to it atomically (see WriteFileAtomic()), in addition to any outputs added via
+// Args.AddOutputs()
+func (s *Statement) WriteOutput(write func(w io.Writer) error) error {
+	if s.OutputFile == "" {
+		return write(s.Output)
+	}
+	return WriteFileAtomic(s.OutputFile, func(w io.Writer) error {
+		return write(io.MultiWriter(s.Output, w))
+	})
+}
@@ -0,0 +1,68 @@
+package query
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.json")
+
+	require.Nil(t, WriteFileAtomic(path, func(w io.Writer) error {
+		_, err := w.Write([]byte("first"))
+		return err
+	}))
+
+	// a failed write leaves the previous results untouched
+	errWrite := errors.New("write failed")
+	require.ErrorIs(t, WriteFileAtomic(path, func(w io.Writer) error {
+		_, _ = w.Write([]byte("partial"))
+		return errWrite
+	}), errWrite)
+
+	content, err := os.ReadFile(path)
+	require.Nil(t, err)
+	require.Equal(t, "first", string(content))
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+
+	info, err := os.Stat(path)
+	require.Nil(t, err)
+	require.Equal(t, os.FileMode(outputFilePermissions), info.Mode().Perm())
+}
+
+func TestWriteOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.txt")
+
+	args := NewArgs("sip", "eth0")
+	args.OutputFile = path
+
+	// without additional outputs, results are only written to the file
+	stmt, err := args.Prepare()
+	require.Nil(t, err)
+	require.Equal(t, io.Discard, stmt.Output)
+
+	// outputs added via AddOutputs() receive the results as well
+	buf := new(bytes.Buffer)
+	stmt, err = args.AddOutputs(buf).Prepare()
+	require.Nil(t, err)
+	require.Nil(t, stmt.WriteOutput(func(w io.Writer) error {
+		_, err := w.Write([]byte("results"))
+		return err
+	}))
+
+	content, err := os.ReadFile(path)
+	require.Nil(t, err)
+	require.Equal(t, "results", string(content))
+	require.Equal(t, "results", buf.String())
+}
@@ -3,13 +3,19 @@ package query
 import (
 	"context"
 	"fmt"
+	"io"
+	"sort"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/els0r/goProbe/pkg/query/dns"
 	"github.com/els0r/goProbe/pkg/query/heap"
 	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/telemetry/tracing"
 	"github.com/els0r/goProbe/pkg/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // log writes a json marshaled query statement to disk
@@ -19,42 +25,33 @@ func (s *Statement) log() {
 
 // Print prints a statement to the result
 func (s *Statement) Print(ctx context.Context, result *results.Result) error {
-	var sip, dip types.Attribute
+	return s.WriteOutput(func(w io.Writer) error {
+		return s.print(ctx, w, result)
+	})
+}
 
-	var hasDNSattributes bool
-	for _, attribute := range s.attributes {
-		switch attribute.Name() {
-		case "sip":
-			sip = attribute
-			hasDNSattributes = true
-		case "dip":
-			dip = attribute
-			hasDNSattributes = true
-		}
+func (s *Statement) print(ctx context.Context, w io.Writer, result *results.Result) error {
+
+	// explained queries only show the query plan (which is part of the raw result otherwise)
+	if result.Query.Plan != nil && !results.IsRawFormat(s.Format) {
+		return printQueryPlan(w, result.Query)
 	}
 
 	// Find map from ips to domains for reverse DNS
-	var ips2domains map[string]string
-	if s.DNSResolution.Enabled && hasDNSattributes {
-		var ips []string
-		for i, l := 0, len(result.Rows); i < l && i < s.DNSResolution.MaxRows; i++ {
-			attr := result.Rows[i].Attributes
-			if sip != nil {
-				ips = append(ips, attr.SrcIP.String())
-			}
-			if dip != nil {
-				ips = append(ips, attr.DstIP.String())
-			}
-		}
+	ips2domains, err := s.resolveHostnames(ctx, result, s.DNSResolution.MaxRows)
+	if err != nil {
+		return err
+	}
 
-		resolveStart := time.Now()
-		ips2domains = dns.TimedReverseLookup(ips, s.DNSResolution.Timeout)
-		result.Summary.Timings.ResolutionDuration = time.Since(resolveStart)
+	// run the enrichers over the final rows
+	enrichments, err := s.EnrichRows(ctx, result.Rows)
+	if err != nil {
+		return err
 	}
 
 	// get the right printer
 	printer, err := results.NewTablePrinter(
-		s.Output,
+		w,
 		s.Format,
 		s.SortBy,
 		s.LabelSelector,
@@ -66,6 +63,12 @@ func (s *Statement) Print(ctx context.Context, result *results.Result) error {
 		s.DNSResolution.Timeout,
 		s.QueryType,
 		strings.Join(s.Ifaces, ","),
+		results.WithRawUnits(s.RawUnits),
+		results.WithTotalRow(s.TotalRow),
+		results.WithSubtotals(s.Subtotals),
+		results.WithDistinct(s.Distinct),
+		results.WithDistribution(s.Distribution),
+		results.WithEnrichments(enrichments...),
 	)
 	if err != nil {
 		return err
@@ -75,7 +78,7 @@ func (s *Statement) Print(ctx context.Context, result *results.Result) error {
 	heapWatchCtx, cancelHeapWatch := context.WithCancel(ctx)
 	defer cancelHeapWatch()
 
-	memErrors := heap.Watch(heapWatchCtx, s.MaxMemPct)
+	memErrors := heap.Watch(heapWatchCtx, s.MaxMemPct, nil)
 
 	printCtx, printCancel := context.WithCancel(ctx)
 	defer printCancel()
@@ -108,3 +111,137 @@ func (s *Statement) Print(ctx context.Context, result *results.Result) error {
 
 	return printer.Print(result)
 }
+
+// FilterRows applies the filters on the resolved hostnames / enriched columns of the statement (if any) to
+// the rows of the result. Since the filters can only be evaluated on the final rows, all of them are resolved /
+// enriched beforehand (regardless of DNSResolution.MaxRows). Runners have to apply the filters before limiting
+// the rows to the number of results requested
+func (s *Statement) FilterRows(ctx context.Context, result *results.Result) error {
+	if len(s.rowFilters) == 0 {
+		return nil
+	}
+
+	var filterHostnames, filterEnrichments bool
+	for _, filter := range s.rowFilters {
+		switch {
+		case filter.IsHostnameFilter():
+			filterHostnames = true
+		case filter.Field != results.FilterProcess && !filter.IsLabelColumnFilter():
+			filterEnrichments = true
+		}
+	}
+	if filterHostnames {
+		if _, err := s.resolveHostnames(ctx, result, len(result.Rows)); err != nil {
+			return err
+		}
+	}
+	if filterEnrichments {
+		if _, err := s.EnrichRows(ctx, result.Rows); err != nil {
+			return err
+		}
+	}
+
+	result.Rows = s.rowFilters.Filter(result.Rows)
+	result.Summary.Hits.Total = len(result.Rows)
+	return nil
+}
+
+// resolveHostnames performs the reverse DNS lookups of the IP attributes of the first maxRows rows (if
+// enabled) and attaches the hostnames to them, so that formats serializing the raw result carry them
+// alongside the IPs. Rows already carrying hostnames (e.g. since they were filtered on) aren't looked up
+// again. It returns the map from IPs to domains
+func (s *Statement) resolveHostnames(ctx context.Context, result *results.Result, maxRows int) (map[string]string, error) {
+	var hasSIP, hasDIP bool
+	for _, attribute := range s.attributes {
+		switch attribute.Name() {
+		case types.SIPName:
+			hasSIP = true
+		case types.DIPName:
+			hasDIP = true
+		}
+	}
+	if !s.DNSResolution.Enabled || (!hasSIP && !hasDIP) {
+		return nil, nil
+	}
+
+	var (
+		ips         []string
+		ips2domains = make(map[string]string)
+	)
+	for i, l := 0, len(result.Rows); i < l && i < maxRows; i++ {
+		row := &result.Rows[i]
+		if row.Hostnames != nil {
+			if row.Hostnames.SrcHost != "" {
+				ips2domains[row.Attributes.SrcIP.String()] = row.Hostnames.SrcHost
+			}
+			if row.Hostnames.DstHost != "" {
+				ips2domains[row.Attributes.DstIP.String()] = row.Hostnames.DstHost
+			}
+			continue
+		}
+		if hasSIP {
+			ips = append(ips, row.Attributes.SrcIP.String())
+		}
+		if hasDIP {
+			ips = append(ips, row.Attributes.DstIP.String())
+		}
+	}
+	if len(ips) == 0 {
+		return ips2domains, nil
+	}
+
+	_, span := tracing.Start(ctx, "dns.TimedReverseLookup", trace.WithAttributes(
+		attribute.Int("ips", len(ips)),
+	))
+	defer span.End()
+
+	resolver, err := dns.NewResolver(
+		dns.WithServers(s.DNSResolution.Resolvers...),
+		dns.WithMaxConcurrency(s.DNSResolution.MaxConcurrency),
+		dns.WithNegativeCacheTTL(s.DNSResolution.NegativeCacheTTL),
+	)
+	if err != nil {
+		return nil, err
+	}
+	resolveStart := time.Now()
+	resolved := resolver.TimedReverseLookup(ips, s.DNSResolution.Timeout)
+	result.Summary.Timings.ResolutionDuration += time.Since(resolveStart)
+	span.SetAttributes(attribute.Int("resolved", len(resolved)))
+
+	for ip, domain := range resolved {
+		ips2domains[ip] = domain
+	}
+	for i, l := 0, len(result.Rows); i < l && i < maxRows; i++ {
+		if result.Rows[i].Hostnames == nil {
+			result.Rows[i].SetHostnames(ips2domains)
+		}
+	}
+	return ips2domains, nil
+}
+
+// EnrichRows runs the enrichers of the statement over the rows and returns the columns they attached
+func (s *Statement) EnrichRows(ctx context.Context, rows results.Rows) ([]string, error) {
+	return results.Enrich(ctx, s.Enrich, s.attributes, rows)
+}
+
+// printQueryPlan prints how the query is executed in human-readable form
+func printQueryPlan(w io.Writer, q results.Query) error {
+	ifaces := make([]string, 0, len(q.Plan.Directories))
+	for iface := range q.Plan.Directories {
+		ifaces = append(ifaces, iface)
+	}
+	sort.Strings(ifaces)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Attributes:\t%s\n", strings.Join(q.Attributes, ", "))
+	if q.Condition != "" {
+		fmt.Fprintf(tw, "Condition:\t%s\n", q.Condition)
+	}
+	fmt.Fprintf(tw, "Columns read:\t%s\n", strings.Join(q.Plan.Columns, ", "))
+	fmt.Fprintf(tw, "Workers:\t%d per interface\n", q.Plan.Workers)
+	fmt.Fprintln(tw, "Directories read:")
+	for _, iface := range ifaces {
+		fmt.Fprintf(tw, "  %s\t%d\n", iface, q.Plan.Directories[iface])
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,233 @@
+// Package replay reads the queries recorded in a (structured) query audit log and replays
+// them against a query runner in order to measure query latencies, e.g. for regression
+// testing storage or hardware changes
+package replay
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/query"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// auditLogArgsKey denotes the field under which query arguments are logged, both by goQuery
+// (via --query.log) and by the API servers
+const auditLogArgsKey = "args"
+
+// maxLineSize limits the size of a single audit log entry
+const maxLineSize = 1024 * 1024
+
+var errorNoQueries = errors.New("no queries found in audit log")
+
+// ReadAuditLog extracts all query arguments from a JSON-encoded audit log. Each line carrying
+// an "args" field is considered to be a query. Lines which are not valid JSON (or don't hold
+// query arguments) are skipped
+func ReadAuditLog(r io.Reader) ([]*query.Args, error) {
+	var queries []*query.Args
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for lineNr := 1; scanner.Scan(); lineNr++ {
+		var entry map[string]jsoniter.RawMessage
+		if err := jsoniter.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		rawArgs, exists := entry[auditLogArgsKey]
+		if !exists {
+			continue
+		}
+		args, err := parseArgs(rawArgs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse query arguments in line %d: %w", lineNr, err)
+		}
+		queries = append(queries, args)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	if len(queries) == 0 {
+		return nil, errorNoQueries
+	}
+	return queries, nil
+}
+
+// parseArgs decodes the query arguments, which are either logged as a JSON object or as a string
+// holding the JSON-encoded arguments (see query.Args.LogValue)
+func parseArgs(raw jsoniter.RawMessage) (*query.Args, error) {
+	var argsStr string
+	if err := jsoniter.Unmarshal(raw, &argsStr); err == nil {
+		raw = jsoniter.RawMessage(argsStr)
+	}
+
+	args := query.DefaultArgs()
+	if err := jsoniter.Unmarshal(raw, args); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// Report summarizes a replay run
+type Report struct {
+	NumQueries int             `json:"num_queries"` // NumQueries: number of queries run. Example: 100
+	NumErrors  int             `json:"num_errors"`  // NumErrors: number of queries which failed. Example: 0
+	Duration   time.Duration   `json:"duration"`    // Duration: wall-clock duration of the replay. Example: 12000000000
+	Latencies  []time.Duration `json:"-"`           // Latencies: latencies of all successful queries (sorted)
+}
+
+// Percentile returns the p-th percentile (0 < p <= 100) of all latencies (using the nearest-rank method)
+func (r *Report) Percentile(p float64) time.Duration {
+	if len(r.Latencies) == 0 || p <= 0 {
+		return 0
+	}
+	if p >= 100 {
+		return r.Latencies[len(r.Latencies)-1]
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(r.Latencies))))
+	return r.Latencies[rank-1]
+}
+
+// Mean returns the average latency
+func (r *Report) Mean() time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	var sum time.Duration
+	for _, latency := range r.Latencies {
+		sum += latency
+	}
+	return sum / time.Duration(len(r.Latencies))
+}
+
+// QueriesPerSecond returns the query throughput achieved during the replay
+func (r *Report) QueriesPerSecond() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.NumQueries) / r.Duration.Seconds()
+}
+
+// ErrorFn is called for every query which failed during the replay
+type ErrorFn func(args *query.Args, err error)
+
+// Replayer runs recorded queries against a query runner
+type Replayer struct {
+	runner      query.Runner
+	concurrency int
+	repeat      int
+	onError     ErrorFn
+}
+
+// Option configures the replayer
+type Option func(*Replayer)
+
+// WithConcurrency sets the number of queries run in parallel
+func WithConcurrency(n int) Option {
+	return func(r *Replayer) {
+		if n > 0 {
+			r.concurrency = n
+		}
+	}
+}
+
+// WithRepeat sets how many times the full set of queries is replayed
+func WithRepeat(n int) Option {
+	return func(r *Replayer) {
+		if n > 0 {
+			r.repeat = n
+		}
+	}
+}
+
+// WithErrorFn sets a callback which is called for every failed query
+func WithErrorFn(fn ErrorFn) Option {
+	return func(r *Replayer) {
+		r.onError = fn
+	}
+}
+
+// New creates a new replayer running queries against runner. By default, queries are run
+// sequentially and exactly once
+func New(runner query.Runner, opts ...Option) *Replayer {
+	r := &Replayer{
+		runner:      runner,
+		concurrency: 1,
+		repeat:      1,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run replays all queries and reports their latencies. Results are discarded. If the context
+// is cancelled, the queries which haven't been started yet are skipped
+func (r *Replayer) Run(ctx context.Context, queries []*query.Args) (*Report, error) {
+	if len(queries) == 0 {
+		return nil, errorNoQueries
+	}
+
+	var (
+		jobs = make(chan *query.Args)
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+
+		report = &Report{Latencies: make([]time.Duration, 0, len(queries)*r.repeat)}
+	)
+
+	start := time.Now()
+	for i := 0; i < r.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for args := range jobs {
+				// copy the arguments, since they are modified during statement preparation
+				argsCopy := *args
+
+				queryStart := time.Now()
+				_, err := r.runner.Run(ctx, &argsCopy)
+				latency := time.Since(queryStart)
+
+				mu.Lock()
+				report.NumQueries++
+				if err != nil {
+					report.NumErrors++
+				} else {
+					report.Latencies = append(report.Latencies, latency)
+				}
+				mu.Unlock()
+
+				if err != nil && r.onError != nil {
+					r.onError(args, err)
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < r.repeat; i++ {
+		for _, args := range queries {
+			select {
+			case <-ctx.Done():
+				break feed
+			case jobs <- args:
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	report.Duration = time.Since(start)
+	sort.Slice(report.Latencies, func(i, j int) bool {
+		return report.Latencies[i] < report.Latencies[j]
+	})
+
+	return report, ctx.Err()
+}
@@ -0,0 +1,126 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/stretchr/testify/require"
+)
+
+const testAuditLog = `{"time":"2023-10-01T12:00:00Z","level":"INFO","msg":"preparing query","args":{"query":"sip,dip","ifaces":"eth0","first":"-24h","num_results":10}}
+{"time":"2023-10-01T12:00:00Z","level":"INFO","msg":"running query","stmt":{"query_type":"sip,dip"}}
+{"time":"2023-10-01T12:00:01Z","level":"INFO","msg":"query finished"}
+not a JSON line
+{"time":"2023-10-01T12:00:02Z","level":"INFO","msg":"running query","args":"{\"query\":\"dport\",\"ifaces\":\"eth1\",\"condition\":\"proto=tcp\"}"}
+`
+
+func TestReadAuditLog(t *testing.T) {
+	queries, err := ReadAuditLog(strings.NewReader(testAuditLog))
+	require.Nil(t, err)
+	require.Len(t, queries, 2)
+
+	require.Equal(t, "sip,dip", queries[0].Query)
+	require.Equal(t, "eth0", queries[0].Ifaces)
+	require.Equal(t, "-24h", queries[0].First)
+	require.Equal(t, uint64(10), queries[0].NumResults)
+
+	// defaults must be set for fields which weren't logged
+	require.Equal(t, "dport", queries[1].Query)
+	require.Equal(t, "proto=tcp", queries[1].Condition)
+	require.Equal(t, query.DefaultNumResults, queries[1].NumResults)
+
+	_, err = ReadAuditLog(strings.NewReader(`{"msg":"query finished"}`))
+	require.ErrorIs(t, err, errorNoQueries)
+
+	_, err = ReadAuditLog(strings.NewReader(`{"msg":"preparing query","args":{"num_results":"abc"}}`))
+	require.NotNil(t, err)
+}
+
+func TestPercentile(t *testing.T) {
+	report := &Report{}
+	require.Equal(t, time.Duration(0), report.Percentile(50))
+	require.Equal(t, time.Duration(0), report.Mean())
+
+	for i := 1; i <= 100; i++ {
+		report.Latencies = append(report.Latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	var tests = []struct {
+		p        float64
+		expected time.Duration
+	}{
+		{0, 0},
+		{1, time.Millisecond},
+		{50, 50 * time.Millisecond},
+		{99, 99 * time.Millisecond},
+		{99.9, 100 * time.Millisecond},
+		{100, 100 * time.Millisecond},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.expected, report.Percentile(test.p), "p%v", test.p)
+	}
+	require.Equal(t, 50500*time.Microsecond, report.Mean())
+}
+
+type mockRunner struct {
+	running, maxRunning atomic.Int64
+}
+
+func (m *mockRunner) Run(_ context.Context, args *query.Args) (*results.Result, error) {
+	running := m.running.Add(1)
+	defer m.running.Add(-1)
+	for {
+		maxRunning := m.maxRunning.Load()
+		if running <= maxRunning || m.maxRunning.CompareAndSwap(maxRunning, running) {
+			break
+		}
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if args.Ifaces == "fail" {
+		return nil, errors.New("query failed")
+	}
+	return results.New(), nil
+}
+
+func TestReplay(t *testing.T) {
+	queries := []*query.Args{
+		query.NewArgs("sip", "eth0"),
+		query.NewArgs("dip", "eth0"),
+		query.NewArgs("dport", "fail"),
+		query.NewArgs("proto", "eth1"),
+	}
+
+	runner := &mockRunner{}
+	var numFailed atomic.Int64
+	report, err := New(runner,
+		WithConcurrency(4),
+		WithRepeat(3),
+		WithErrorFn(func(_ *query.Args, _ error) {
+			numFailed.Add(1)
+		}),
+	).Run(context.Background(), queries)
+	require.Nil(t, err)
+
+	require.Equal(t, 12, report.NumQueries)
+	require.Equal(t, 3, report.NumErrors)
+	require.Equal(t, int64(3), numFailed.Load())
+	require.Len(t, report.Latencies, 9)
+	require.LessOrEqual(t, runner.maxRunning.Load(), int64(4))
+	require.Greater(t, runner.maxRunning.Load(), int64(1))
+	require.Greater(t, report.QueriesPerSecond(), 0.)
+
+	// latencies must be sorted
+	for i := 1; i < len(report.Latencies); i++ {
+		require.LessOrEqual(t, report.Latencies[i-1], report.Latencies[i])
+	}
+
+	_, err = New(runner).Run(context.Background(), nil)
+	require.ErrorIs(t, err, errorNoQueries)
+}
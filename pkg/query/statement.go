@@ -14,6 +14,10 @@ type Statement struct {
 	// Ifaces holds hte list of all interfaces that should be queried
 	Ifaces []string `json:"ifaces"`
 
+	// IfaceGroups maps the interfaces which were selected via an interface group to the group. Their
+	// flows are aggregated and reported under the group's name
+	IfaceGroups map[string]string `json:"iface_groups,omitempty"`
+
 	LabelSelector types.LabelSelector `json:"label_selector,omitempty"`
 
 	// needed for feedback to user
@@ -22,6 +26,18 @@ type Statement struct {
 	attributes []types.Attribute `json:"-"`
 	Condition  string            `json:"condition,omitempty"`
 
+	// IPVersion restricts the query to flows of one IP version (if limited)
+	IPVersion types.IPVersion `json:"ip_version,omitempty"`
+
+	// MaxDropPct excludes the blocks whose capture dropped a higher percentage of packets (if set)
+	MaxDropPct float64 `json:"max_drop_pct,omitempty"`
+
+	// Verify checks the consistency of the columns of each block (if set)
+	Verify bool `json:"verify,omitempty"`
+
+	// Anomalies compares the traffic of each interface with its baseline (if set)
+	Anomalies bool `json:"anomalies,omitempty"`
+
 	// which direction is added
 	Direction types.Direction `json:"direction"`
 
@@ -29,13 +45,31 @@ type Statement struct {
 	First int64 `json:"from"`
 	Last  int64 `json:"to"`
 
+	// Windows restricts the query to a set of disjoint time windows within [First, Last] (if set)
+	Windows types.TimeWindows `json:"windows,omitempty"`
+
 	// formatting
 	Format        string            `json:"format"`
 	NumResults    uint64            `json:"limit"`
 	SortBy        results.SortOrder `json:"sort_by"`
 	SortAscending bool              `json:"sort_ascending,omitempty"`
+	RawUnits      bool              `json:"raw_units,omitempty"`
+	TotalRow      bool              `json:"total_row,omitempty"`
+	Subtotals     bool              `json:"subtotals,omitempty"`
+	Enrich        []string          `json:"enrich,omitempty"`
 	Output        io.Writer         `json:"-"`
 
+	// Filter denotes the filters applied to the resolved hostnames / enriched columns of the rows
+	// by the query runner before they are limited
+	Filter     []string           `json:"filter,omitempty"`
+	rowFilters results.RowFilters `json:"-"`
+
+	// OutputFile denotes the file the results are written to atomically (see WriteOutput())
+	OutputFile string `json:"output_file,omitempty"`
+
+	// Explain only plans the query instead of running it
+	Explain bool `json:"explain,omitempty"`
+
 	// parameters for external calls
 	Caller string `json:"caller,omitempty"` // who called the query
 
@@ -46,8 +80,29 @@ type Statement struct {
 	MaxMemPct int  `json:"max_mem_pct,omitempty"`
 	LowMem    bool `json:"low_mem,omitempty"`
 
+	// MaxBlocksPerSec throttles the blocks read from disk (0: unlimited)
+	MaxBlocksPerSec int `json:"max_blocks_per_sec,omitempty"`
+
+	// Workers denotes the number of workers reading the blocks of each interface (0: runner default)
+	Workers int `json:"workers,omitempty"`
+
+	// Approx aggregates approximately, only tracking the top flows
+	Approx bool `json:"approx,omitempty"`
+
+	// Distinct denotes the attribute whose distinct values are counted per row
+	Distinct string `json:"distinct,omitempty"`
+
+	// Distribution computes the time distribution of the bytes of each row
+	Distribution bool `json:"distribution,omitempty"`
+
+	// Dedup counts flows seen identically on several interfaces only once
+	Dedup bool `json:"dedup,omitempty"`
+
 	// request live flow data (in addition to DB)
 	Live bool `json:"live,omitempty"`
+
+	// Tenant restricts the query to the flows of a tenant
+	Tenant string `json:"tenant,omitempty"`
 }
 
 // String prints the executable statement in human-readable form
@@ -56,15 +111,39 @@ func (s *Statement) String() string {
 		s.QueryType,
 		s.Ifaces,
 	)
+	if s.Tenant != "" {
+		str += fmt.Sprintf(", tenant: %s", s.Tenant)
+	}
 	if s.Condition != "" {
 		str += fmt.Sprintf(", condition: %s", s.Condition)
 	}
+	switch s.IPVersion {
+	case types.IPVersionV4:
+		str += ", ip-version: 4"
+	case types.IPVersionV6:
+		str += ", ip-version: 6"
+	}
+	if s.MaxDropPct > 0 {
+		str += fmt.Sprintf(", max-drop-pct: %g", s.MaxDropPct)
+	}
+	if s.Verify {
+		str += ", verify"
+	}
+	if s.Anomalies {
+		str += ", anomalies"
+	}
 	tFrom, tTo := time.Unix(s.First, 0), time.Unix(s.Last, 0)
 	str += fmt.Sprintf(", limit: %d, from: %s, to: %s",
 		s.NumResults,
 		tFrom.Format(time.ANSIC),
 		tTo.Format(time.ANSIC),
 	)
+	for _, window := range s.Windows {
+		str += fmt.Sprintf(", window: %s - %s",
+			time.Unix(window.First, 0).Format(time.ANSIC),
+			time.Unix(window.Last, 0).Format(time.ANSIC),
+		)
+	}
 	if s.DNSResolution.Enabled {
 		str += fmt.Sprintf(", dns-resolution: %t", s.DNSResolution.Enabled)
 	}
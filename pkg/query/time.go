@@ -195,6 +195,29 @@ func ParseTimeRange(firstStr, lastStr string) (first, last int64, err error) {
 	return first, last, nil
 }
 
+// TimeWindowSeparator separates the first and last timestamp of a time window
+const TimeWindowSeparator = ".."
+
+var errorInvalidTimeWindow = errors.New("invalid time window")
+
+// ParseTimeWindows parses time windows of the form "<first>..<last>" (where either bound may be
+// omitted, see ParseTimeRange) and merges overlapping ones
+func ParseTimeWindows(windowStrs []string) (types.TimeWindows, error) {
+	windows := make([]types.TimeWindow, 0, len(windowStrs))
+	for _, windowStr := range windowStrs {
+		firstStr, lastStr, found := strings.Cut(windowStr, TimeWindowSeparator)
+		if !found {
+			return nil, fmt.Errorf("%w %q: expected `<first>%s<last>`", errorInvalidTimeWindow, windowStr, TimeWindowSeparator)
+		}
+		first, last, err := ParseTimeRange(strings.TrimSpace(firstStr), strings.TrimSpace(lastStr))
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %w", errorInvalidTimeWindow, windowStr, err)
+		}
+		windows = append(windows, types.TimeWindow{First: first, Last: last})
+	}
+	return types.NewTimeWindows(windows...), nil
+}
+
 // ParseTimeArgument is the entry point for external calls and converts valid formats to a unix timtestamp
 func ParseTimeArgument(timeString string) (int64, error) {
 	var (
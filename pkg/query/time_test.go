@@ -34,3 +34,17 @@ func TestParseTimestamp(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTimeWindows(t *testing.T) {
+	windows, err := ParseTimeWindows([]string{"1700010000..1700020000", "1700000000 .. 1700015000", "1700050000..1700060000"})
+	assert.Nil(t, err)
+	assert.Equal(t, types.TimeWindows{
+		{First: 1700000000, Last: 1700020000},
+		{First: 1700050000, Last: 1700060000},
+	}, windows)
+
+	for _, invalid := range []string{"1700000000", "1700020000..1700010000", "foo..1700010000"} {
+		_, err := ParseTimeWindows([]string{invalid})
+		assert.ErrorIs(t, err, errorInvalidTimeWindow, invalid)
+	}
+}
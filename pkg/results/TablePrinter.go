@@ -37,11 +37,20 @@ const (
 	OutcolHostname
 	OutcolHostID
 	OutcolIface
+	OutcolProcess
+	OutcolDirection
 	// attributes
 	OutcolSIP
 	OutcolDIP
 	OutcolDport
 	OutcolProto
+	// resolved hostnames of the IP attributes
+	OutcolSIPHost
+	OutcolDIPHost
+	// distinct count of an attribute
+	OutcolDistinct
+	// time distribution of the bytes
+	OutcolDistribution
 	// counters
 	OutcolInPkts
 	OutcolInPktsPercent
@@ -64,16 +73,38 @@ const (
 	CountOutcol
 )
 
+// enrichmentCol returns the OutputColumn of the i-th enrichment column. Enrichment columns (see
+// WithEnrichments()) are numbered consecutively, starting at CountOutcol
+func enrichmentCol(i int) OutputColumn {
+	return CountOutcol + OutputColumn(i)
+}
+
+// labelColumnCol returns the OutputColumn of the i-th label column attribute (see
+// types.LabelSelector.Columns). They are numbered consecutively following the enrichment columns
+func labelColumnCol(enrichments []string, i int) OutputColumn {
+	return enrichmentCol(len(enrichments) + i)
+}
+
 const (
 	packetsStr = "packets"
 	bytesStr   = "bytes"
 )
 
+// Titles of the hostname columns printed alongside the IP attributes if reverse DNS lookups
+// are enabled
+const (
+	SIPHostName = "sip_host"
+	DIPHostName = "dip_host"
+)
+
 // columns returns the list of OutputColumns that (might) be printed.
 // timed indicates whether we're supposed to print timestamps. attributes lists
-// all attributes we have to print. d tells us which counters to print.
+// all attributes we have to print. hostnames indicates whether the resolved hostnames
+// are printed next to the IPs, enrichments lists the columns attached by enrichers.
+// distinct is the attribute whose distinct values are counted, distribution indicates
+// whether the time distribution of each row is printed. d tells us which counters to print.
 // in this function (and some others) ORDER matters
-func columns(selector types.LabelSelector, attributes []types.Attribute, d types.Direction) (cols []OutputColumn) {
+func columns(selector types.LabelSelector, attributes []types.Attribute, hostnames bool, enrichments []string, distinct string, distribution bool, d types.Direction) (cols []OutputColumn) {
 	if selector.Timestamp {
 		cols = append(cols, OutcolTime)
 	}
@@ -87,13 +118,28 @@ func columns(selector types.LabelSelector, attributes []types.Attribute, d types
 	if selector.Iface {
 		cols = append(cols, OutcolIface)
 	}
+	if selector.Process {
+		cols = append(cols, OutcolProcess)
+	}
+	if selector.Direction {
+		cols = append(cols, OutcolDirection)
+	}
+	for i := range selector.Columns {
+		cols = append(cols, labelColumnCol(enrichments, i))
+	}
 
 	for _, attrib := range attributes {
 		switch attrib.Name() {
 		case types.SIPName:
 			cols = append(cols, OutcolSIP)
+			if hostnames {
+				cols = append(cols, OutcolSIPHost)
+			}
 		case types.DIPName:
 			cols = append(cols, OutcolDIP)
+			if hostnames {
+				cols = append(cols, OutcolDIPHost)
+			}
 		case types.ProtoName:
 			cols = append(cols, OutcolProto)
 		case types.DportName:
@@ -101,6 +147,17 @@ func columns(selector types.LabelSelector, attributes []types.Attribute, d types
 		}
 	}
 
+	for i := range enrichments {
+		cols = append(cols, enrichmentCol(i))
+	}
+
+	if distinct != "" {
+		cols = append(cols, OutcolDistinct)
+	}
+	if distribution {
+		cols = append(cols, OutcolDistribution)
+	}
+
 	switch d {
 	case types.DirectionIn:
 		cols = append(cols,
@@ -133,10 +190,10 @@ func columns(selector types.LabelSelector, attributes []types.Attribute, d types
 	return
 }
 
-// Formatter provides methods for printing various types/units of values.
-// Each output format has an associated Formatter implementation, for instance
-// for csv, there is CSVFormatter.
-type Formatter interface {
+// ValueFormatter provides methods for printing various types/units of values.
+// Each tabular output format has an associated ValueFormatter implementation, for
+// instance for csv, there is CSVFormatter.
+type ValueFormatter interface {
 	// Size deals with data sizes (i.e. bytes)
 	Size(uint64) string
 	Duration(time.Duration) string
@@ -147,18 +204,12 @@ type Formatter interface {
 	String(string) string
 }
 
-func tryLookup(ips2domains map[string]string, ip string) string {
-	if dom, exists := ips2domains[ip]; exists {
-		return dom
-	}
-	return ip
-}
-
 // extract extracts the string that needs to be printed for the given OutputColumn.
 // The format argument is used to format the string appropriatly for the desired
-// output format. ips2domains is needed for reverse DNS lookups. totals is needed
-// for percentage calculations. e contains the actual data that is extracted.
-func extract(format Formatter, ips2domains map[string]string, totals types.Counters, row Row, col OutputColumn) string {
+// output format. ips2domains is needed for reverse DNS lookups, enrichments and labelColumns
+// for the names of the enrichment / label columns. totals is needed for percentage calculations. e
+// contains the actual data that is extracted.
+func extract(format ValueFormatter, ips2domains map[string]string, enrichments, labelColumns []string, totals types.Counters, row Row, col OutputColumn) string {
 	nz := func(u uint64) uint64 {
 		if u == 0 {
 			u = (1 << 64) - 1
@@ -175,16 +226,35 @@ func extract(format Formatter, ips2domains map[string]string, totals types.Count
 		return format.String(row.Labels.Hostname)
 	case OutcolHostID:
 		return format.String(row.Labels.HostID)
+	case OutcolProcess:
+		return format.String(row.Labels.Process)
+	case OutcolDirection:
+		return format.String(row.Labels.Direction)
 
 	case OutcolSIP:
-		return format.String(tryLookup(ips2domains, row.Attributes.SrcIP.String()))
+		return format.String(row.Attributes.SrcIP.String())
 	case OutcolDIP:
-		return format.String(tryLookup(ips2domains, row.Attributes.DstIP.String()))
+		return format.String(row.Attributes.DstIP.String())
+	case OutcolSIPHost:
+		return format.String(ips2domains[row.Attributes.SrcIP.String()])
+	case OutcolDIPHost:
+		return format.String(ips2domains[row.Attributes.DstIP.String()])
 	case OutcolDport:
 		return format.String(fmt.Sprintf("%d", row.Attributes.DstPort))
 	case OutcolProto:
 		return format.String(protocols.GetIPProto(int(row.Attributes.IPProto)))
 
+	case OutcolDistinct:
+		if row.Distinct == nil {
+			return format.String("")
+		}
+		return format.Count(row.Distinct.Count())
+	case OutcolDistribution:
+		if row.Distribution == nil {
+			return format.String("")
+		}
+		return format.String(row.Distribution.Sparkline())
+
 	case OutcolInBytes, OutcolBothBytesRcvd:
 		return format.Size(row.Counters.BytesRcvd)
 	case OutcolInBytesPercent:
@@ -210,13 +280,19 @@ func extract(format Formatter, ips2domains map[string]string, totals types.Count
 	case OutcolSumPktsPercent, OutcolBothPktsPercent:
 		return format.Float(float64(100*(row.Counters.SumPackets())) / float64(nz(totals.SumPackets())))
 	default:
+		if col >= CountOutcol && int(col-CountOutcol) < len(enrichments) {
+			return format.String(row.Enrichments[enrichments[col-CountOutcol]])
+		}
+		if i := int(col - labelColumnCol(enrichments, 0)); i >= 0 && i < len(labelColumns) {
+			return format.String(row.Labels.Columns.Get(labelColumns[i]))
+		}
 		panic("unknown OutputColumn value")
 	}
 }
 
 // extractTotal is similar to extract but extracts a total from totals rather
 // than an element of an Entry.
-func extractTotal(format Formatter, totals types.Counters, col OutputColumn) string {
+func extractTotal(format ValueFormatter, totals types.Counters, col OutputColumn) string {
 	switch col {
 	case OutcolInBytes, OutcolBothBytesRcvd:
 		return format.Size(totals.BytesRcvd)
@@ -289,8 +365,14 @@ type basePrinter struct {
 	// query attributes
 	attributes []types.Attribute
 
+	// attribute whose distinct values are counted (if any)
+	distinct string
+
 	ips2domains map[string]string
 
+	// columns attached by enrichers
+	enrichments []string
+
 	// needed for computing percentages
 	totals types.Counters
 
@@ -300,24 +382,13 @@ type basePrinter struct {
 }
 
 // newBasePrinter sets up the basic printing facilities
-func newBasePrinter(
-	output io.Writer,
-	sort SortOrder,
-	selector types.LabelSelector,
-	direction types.Direction,
-	attributes []types.Attribute,
-	ips2domains map[string]string,
-	totals types.Counters,
-	ifaces string,
-) basePrinter {
-	result := basePrinter{output, sort, selector, direction, attributes, ips2domains, totals, ifaces,
-		columns(selector, attributes, direction),
+func newBasePrinter(cfg PrinterConfig) basePrinter {
+	return basePrinter{cfg.Output, cfg.Sort, cfg.LabelSelector, cfg.Direction, cfg.Attributes, cfg.Distinct, cfg.IPs2Domains, cfg.Enrichments, cfg.Totals, cfg.Ifaces,
+		cfg.Columns(),
 	}
-
-	return result
 }
 
-// NewTablePrinter instantiates a new table printer
+// NewTablePrinter instantiates a new table printer for the (registered) output format
 func NewTablePrinter(output io.Writer, format string,
 	sort SortOrder,
 	labelSel types.LabelSelector,
@@ -328,19 +399,30 @@ func NewTablePrinter(output io.Writer, format string,
 	numFlows int,
 	resolveTimeout time.Duration,
 	_ string,
-	ifaces string) (TablePrinter, error) {
-	b := newBasePrinter(output, sort, labelSel, direction, attributes, ips2domains, totals, ifaces)
-
-	var printer TablePrinter
-	switch format {
-	case "txt":
-		printer = NewTextTablePrinter(b, numFlows, resolveTimeout)
-	case "csv":
-		printer = NewCSVTablePrinter(b)
-	default:
-		return nil, fmt.Errorf("unknown output format %s", format)
+	ifaces string,
+	opts ...PrinterOption) (TablePrinter, error) {
+	formatter, exists := LookupFormatter(format)
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
 	}
-	return printer, nil
+
+	cfg := PrinterConfig{
+		Output:         output,
+		Sort:           sort,
+		LabelSelector:  labelSel,
+		Direction:      direction,
+		Attributes:     attributes,
+		IPs2Domains:    ips2domains,
+		Totals:         totals,
+		NumFlows:       numFlows,
+		ResolveTimeout: resolveTimeout,
+		Ifaces:         ifaces,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return formatter.NewTablePrinter(cfg)
 }
 
 // CSVFormatter writes lines in CSV format
@@ -391,12 +473,17 @@ func NewCSVTablePrinter(b basePrinter) *CSVTablePrinter {
 		make([]string, 0, len(b.cols)),
 	}
 
-	headers := append(types.AllColumns(), []string{
+	headers := append(types.OutputColumns(), []string{
+		SIPHostName, DIPHostName,
+		"distinct " + c.distinct,
+		"trend",
 		packetsStr, "%", "data vol.", "%",
 		packetsStr, "%", "data vol.", "%",
 		packetsStr, "%", "data vol.", "%",
 		"packets received", "packets sent", "%", "data vol. received", "data vol. sent", "%",
 	}...)
+	headers = append(headers, c.enrichments...)
+	headers = append(headers, c.selector.Columns...)
 
 	for _, col := range c.cols {
 		c.fields = append(c.fields, headers[col])
@@ -413,7 +500,7 @@ func NewCSVTablePrinter(b basePrinter) *CSVTablePrinter {
 func (c *CSVTablePrinter) AddRow(row Row) error {
 	c.fields = c.fields[:0]
 	for _, col := range c.cols {
-		c.fields = append(c.fields, extract(CSVFormatter{}, c.ips2domains, c.totals, row, col))
+		c.fields = append(c.fields, extract(CSVFormatter{}, c.ips2domains, c.enrichments, c.selector.Columns, c.totals, row, col))
 	}
 	return c.writer.Write(c.fields)
 }
@@ -437,7 +524,7 @@ func (c *CSVTablePrinter) Footer(_ *Result) error {
 	summaryEntries[OutcolBothBytesRcvd] = "Received data volume (bytes)"
 	summaryEntries[OutcolBothBytesSent] = "Sent data volume (bytes)"
 	for _, col := range c.cols {
-		if summaryEntries[col] != "" {
+		if col < CountOutcol && summaryEntries[col] != "" {
 			if err := c.writer.Write([]string{summaryEntries[col], extractTotal(CSVFormatter{}, c.totals, col)}); err != nil {
 				return err
 			}
@@ -497,6 +584,22 @@ func (TextFormatter) String(s string) string {
 	return s
 }
 
+// RawTextFormatter table formats goProbe flows like TextFormatter, but prints sizes and counts
+// as exact integers (e.g. for further processing of the output in shell pipelines)
+type RawTextFormatter struct {
+	TextFormatter
+}
+
+// Size prints the integer size (in bytes)
+func (RawTextFormatter) Size(size uint64) string {
+	return fmt.Sprint(size)
+}
+
+// Count prints val as integer
+func (RawTextFormatter) Count(val uint64) string {
+	return fmt.Sprint(val)
+}
+
 // TextTablePrinter pretty prints all flows
 type TextTablePrinter struct {
 	basePrinter
@@ -505,20 +608,48 @@ type TextTablePrinter struct {
 	numFlows       int
 	resolveTimeout time.Duration
 	numPrinted     int
+	format         ValueFormatter
+
+	// aggregate rows (see WithAggregateRows())
+	totalRow   bool
+	rowTotals  types.Counters
+	groups     []rowGroup
+	groupIndex map[string]int
+}
+
+// rowGroup holds the rows sharing the value of the first column (for printing their subtotal)
+type rowGroup struct {
+	value    string
+	rows     Rows
+	counters types.Counters
 }
 
 // NewTextTablePrinter creates a new table printer
 func NewTextTablePrinter(b basePrinter, numFlows int, resolveTimeout time.Duration) *TextTablePrinter {
 	var t = &TextTablePrinter{
-		b,
-		tabwriter.NewWriter(b.output, 0, 1, 2, ' ', tabwriter.AlignRight),
-		tabwriter.NewWriter(b.output, 0, 4, 1, ' ', 0),
-		numFlows,
-		resolveTimeout,
-		0,
-	}
+		basePrinter:    b,
+		writer:         tabwriter.NewWriter(b.output, 0, 1, 2, ' ', tabwriter.AlignRight),
+		footwriter:     tabwriter.NewWriter(b.output, 0, 4, 1, ' ', 0),
+		numFlows:       numFlows,
+		resolveTimeout: resolveTimeout,
+		format:         TextFormatter{},
+	}
+
+	var header2 = append(types.OutputColumns(), []string{
+		SIPHostName, DIPHostName,
+		t.distinct,
+		"trend",
+		"in", "%", "in", "%",
+		"out", "%", "out", "%",
+		"in+out", "%", "in+out", "%",
+		"in", "out", "%", "in", "out", "%",
+	}...)
+	header2 = append(header2, t.enrichments...)
+	header2 = append(header2, t.selector.Columns...)
 
-	var header1 [CountOutcol]string
+	var header1 = make([]string, len(header2))
+	header1[OutcolDistinct] = "distinct"
+	header1[OutcolDistribution] = bytesStr
 	header1[OutcolInPkts] = packetsStr
 	header1[OutcolInBytes] = bytesStr
 	header1[OutcolOutPkts] = packetsStr
@@ -530,13 +661,6 @@ func NewTextTablePrinter(b basePrinter, numFlows int, resolveTimeout time.Durati
 	header1[OutcolBothBytesRcvd] = bytesStr
 	header1[OutcolBothBytesSent] = bytesStr
 
-	var header2 = append(types.AllColumns(), []string{
-		"in", "%", "in", "%",
-		"out", "%", "out", "%",
-		"in+out", "%", "in+out", "%",
-		"in", "out", "%", "in", "out", "%",
-	}...)
-
 	for _, col := range t.cols {
 		fmt.Fprint(t.writer, header1[col])
 		fmt.Fprint(t.writer, "\t")
@@ -553,6 +677,37 @@ func NewTextTablePrinter(b basePrinter, numFlows int, resolveTimeout time.Durati
 	return t
 }
 
+// WithAggregateRows enables printing a row summing up all printed rows and / or subtotal rows.
+// For the latter, the rows are grouped by their first column (in order of appearance) and a
+// subtotal row is appended to each group. Subtotals are only printed if the rows have more than
+// one label / attribute column
+func (t *TextTablePrinter) WithAggregateRows(totalRow, subtotals bool) *TextTablePrinter {
+	t.totalRow = totalRow
+
+	var numKeyCols int
+	for _, col := range t.cols {
+		if !isCounterCol(col) && col != OutcolDistinct && col != OutcolDistribution {
+			numKeyCols++
+		}
+	}
+	if subtotals && numKeyCols > 1 {
+		t.groupIndex = make(map[string]int)
+	}
+	return t
+}
+
+// WithRawUnits prints sizes and counts as exact integers instead of in human-readable form
+func (t *TextTablePrinter) WithRawUnits(raw bool) *TextTablePrinter {
+	if raw {
+		t.format = RawTextFormatter{}
+	}
+	return t
+}
+
+func isCounterCol(col OutputColumn) bool {
+	return col >= OutcolInPkts && col < CountOutcol
+}
+
 func addRows(ctx context.Context, p TablePrinter, rows Rows) error {
 	for i, row := range rows {
 		select {
@@ -570,12 +725,47 @@ func addRows(ctx context.Context, p TablePrinter, rows Rows) error {
 
 // AddRow adds a flow entry to the table printer
 func (t *TextTablePrinter) AddRow(row Row) error {
+	t.numPrinted++
+	t.rowTotals = t.rowTotals.Add(row.Counters)
+
+	// if subtotals are printed, the rows are printed per group upon Footer()
+	if t.groupIndex == nil {
+		t.printRow(row)
+		return nil
+	}
+
+	value := extract(t.format, t.ips2domains, t.enrichments, t.selector.Columns, t.totals, row, t.cols[0])
+	idx, exists := t.groupIndex[value]
+	if !exists {
+		idx = len(t.groups)
+		t.groupIndex[value] = idx
+		t.groups = append(t.groups, rowGroup{value: value})
+	}
+	t.groups[idx].rows = append(t.groups[idx].rows, row)
+	t.groups[idx].counters = t.groups[idx].counters.Add(row.Counters)
+	return nil
+}
+
+func (t *TextTablePrinter) printRow(row Row) {
 	for _, col := range t.cols {
-		fmt.Fprintf(t.writer, "%s\t", extract(TextFormatter{}, t.ips2domains, t.totals, row, col))
+		fmt.Fprintf(t.writer, "%s\t", extract(t.format, t.ips2domains, t.enrichments, t.selector.Columns, t.totals, row, col))
+	}
+	fmt.Fprintln(t.writer)
+}
+
+// printAggregateRow prints the counters of an aggregate (total / subtotal) row. The labels
+// are printed in the leading label / attribute columns
+func (t *TextTablePrinter) printAggregateRow(counters types.Counters, labels ...string) {
+	for i, col := range t.cols {
+		switch {
+		case isCounterCol(col):
+			fmt.Fprint(t.writer, extract(t.format, nil, nil, nil, t.totals, Row{Counters: counters}, col))
+		case i < len(labels):
+			fmt.Fprint(t.writer, labels[i])
+		}
+		fmt.Fprint(t.writer, "\t")
 	}
 	fmt.Fprintln(t.writer)
-	t.numPrinted++
-	return nil
 }
 
 // AddRows adds several flow entries to the table printer
@@ -585,7 +775,17 @@ func (t *TextTablePrinter) AddRows(ctx context.Context, rows Rows) error {
 
 // Footer appends the summary to the table printer
 func (t *TextTablePrinter) Footer(result *Result) error {
-	var isTotal [CountOutcol]bool
+	for _, group := range t.groups {
+		for _, row := range group.rows {
+			t.printRow(row)
+		}
+		t.printAggregateRow(group.counters, group.value, "subtotal")
+	}
+	if t.totalRow {
+		t.printAggregateRow(t.rowTotals, "total")
+	}
+
+	var isTotal = make([]bool, labelColumnCol(t.enrichments, len(t.selector.Columns)))
 	isTotal[OutcolInPkts] = true
 	isTotal[OutcolInBytes] = true
 	isTotal[OutcolOutPkts] = true
@@ -609,7 +809,7 @@ func (t *TextTablePrinter) Footer(result *Result) error {
 	// Totals
 	for _, col := range t.cols {
 		if isTotal[col] {
-			fmt.Fprint(t.writer, extractTotal(TextFormatter{}, t.totals, col))
+			fmt.Fprint(t.writer, extractTotal(t.format, t.totals, col))
 		}
 		fmt.Fprint(t.writer, "\t")
 	}
@@ -624,18 +824,16 @@ func (t *TextTablePrinter) Footer(result *Result) error {
 		fmt.Fprint(t.writer, "Totals:\t")
 		for _, col := range t.cols[1:] {
 			if col == OutcolBothPktsSent {
-				fmt.Fprint(t.writer, TextFormatter{}.Count(t.totals.SumPackets()))
+				fmt.Fprint(t.writer, t.format.Count(t.totals.SumPackets()))
 			}
 			if col == OutcolBothBytesSent {
-				fmt.Fprint(t.writer, TextFormatter{}.Size(t.totals.SumBytes()))
+				fmt.Fprint(t.writer, t.format.Size(t.totals.SumBytes()))
 			}
 			fmt.Fprint(t.writer, "\t")
 		}
 		fmt.Fprintln(t.writer)
 	}
 
-	textFormatter := TextFormatter{}
-
 	// Summary
 	fmt.Fprintf(t.footwriter, "Timespan / Interface\t: [%s, %s] (%s) / %s\n",
 		result.Summary.First.Format(types.DefaultTimeOutputFormat),
@@ -654,24 +852,98 @@ func (t *TextTablePrinter) Footer(result *Result) error {
 	if result.Summary.Hits.Displayed < 1000 {
 		hitsDisplayed = fmt.Sprintf("%d", result.Summary.Hits.Displayed)
 	} else {
-		hitsDisplayed = strings.TrimSpace(textFormatter.Count(uint64(result.Summary.Hits.Displayed)))
+		hitsDisplayed = strings.TrimSpace(t.format.Count(uint64(result.Summary.Hits.Displayed)))
 	}
 
 	var hitsTotal string
 	if result.Summary.Hits.Total < 1000 {
 		hitsTotal = fmt.Sprintf("%d", result.Summary.Hits.Total)
 	} else {
-		hitsTotal = strings.TrimSpace(textFormatter.Count(uint64(result.Summary.Hits.Total)))
+		hitsTotal = strings.TrimSpace(t.format.Count(uint64(result.Summary.Hits.Total)))
 	}
 
 	fmt.Fprintf(t.footwriter, "Query stats\t: displayed top %s hits out of %s in %s\n",
 		hitsDisplayed,
 		hitsTotal,
-		textFormatter.Duration(result.Summary.Timings.QueryDuration))
+		t.format.Duration(result.Summary.Timings.QueryDuration))
 	if result.Query.Condition != "" {
 		fmt.Fprintf(t.footwriter, "Conditions:\t: %s\n",
 			result.Query.Condition)
 	}
+	if approx := result.Summary.Approximation; approx != nil {
+		maxError := t.format.Count(approx.MaxError)
+		if t.sort == SortTraffic {
+			maxError = t.format.Size(approx.MaxError)
+		}
+		fmt.Fprintf(t.footwriter, "Approximation\t: top flows out of %d tracked, counters up to %s below actual\n",
+			approx.Capacity,
+			strings.TrimSpace(maxError))
+	}
+	if quality := result.Summary.CaptureQuality; quality != nil && (quality.Dropped > 0 || quality.Overruns > 0 || quality.Truncated > 0 || quality.BlocksExcluded > 0) {
+		fmt.Fprintf(t.footwriter, "Capture quality\t: %s packets dropped (%.2f%%), %d ring buffer overruns, %s truncated packets",
+			strings.TrimSpace(t.format.Count(quality.Dropped)),
+			100*quality.DropRate(),
+			quality.Overruns,
+			strings.TrimSpace(t.format.Count(quality.Truncated)))
+		if quality.BlocksExcluded > 0 {
+			fmt.Fprintf(t.footwriter, ", %d blocks excluded", quality.BlocksExcluded)
+		}
+		fmt.Fprintln(t.footwriter)
+	}
+	if dedup := result.Summary.Deduplication; dedup != nil && dedup.Flows > 0 {
+		fmt.Fprintf(t.footwriter, "Deduplicated\t: %d flows seen on multiple interfaces (%s / %s packets)\n",
+			dedup.Flows,
+			strings.TrimSpace(t.format.Size(dedup.Counters.SumBytes())),
+			strings.TrimSpace(t.format.Count(dedup.Counters.SumPackets())))
+	}
+	for _, gap := range result.Summary.Gaps {
+		label := gap.Iface
+		if gap.Hostname != "" {
+			label = gap.Hostname + "/" + gap.Iface
+		}
+		reason := "No data"
+		if gap.LinkDown {
+			reason = "Link down"
+		}
+		fmt.Fprintf(t.footwriter, "%s\t: [%s, %s] (%s) / %s\n",
+			reason,
+			gap.First.Format(types.DefaultTimeOutputFormat),
+			gap.Last.Format(types.DefaultTimeOutputFormat),
+			formatting.Durationable(gap.Last.Sub(gap.First).Round(time.Minute)),
+			label)
+	}
+	for _, corruption := range result.Summary.Corruptions {
+		label := corruption.Iface
+		if corruption.Hostname != "" {
+			label = corruption.Hostname + "/" + corruption.Iface
+		}
+		found := fmt.Sprintf("%d of %d entries", corruption.Found, corruption.Expected)
+		if corruption.Error != "" {
+			found = corruption.Error
+		}
+		fmt.Fprintf(t.footwriter, "Corrupt\t: %s (%s: %s) / %s\n",
+			corruption.Timestamp.Format(types.DefaultTimeOutputFormat),
+			corruption.Column,
+			found,
+			label)
+	}
+	for _, anomaly := range result.Summary.Anomalies {
+		label := anomaly.Iface
+		if anomaly.Hostname != "" {
+			label = anomaly.Hostname + "/" + anomaly.Iface
+		}
+		assessment := "Usual traffic"
+		if anomaly.Unusual {
+			assessment = "Unusual traffic"
+		}
+		fmt.Fprintf(t.footwriter, "%s\t: %s vs. %s on average over %d days (%.2fx) / %s\n",
+			assessment,
+			strings.TrimSpace(t.format.Size(anomaly.Totals.SumBytes())),
+			strings.TrimSpace(t.format.Size(anomaly.Baseline.SumBytes())),
+			anomaly.BaselineDays,
+			anomaly.Ratio,
+			label)
+	}
 
 	return nil
 }
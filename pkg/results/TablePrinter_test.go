@@ -0,0 +1,217 @@
+package results
+
+import (
+	"bytes"
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/goProbe/pkg/types/hll"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextAggregateRows(t *testing.T) {
+	row := func(sip string, dport uint16, packets uint64) Row {
+		return Row{
+			Attributes: Attributes{SrcIP: netip.MustParseAddr(sip), DstPort: dport},
+			Counters:   types.Counters{PacketsRcvd: packets},
+		}
+	}
+	rows := Rows{
+		row("10.0.0.1", 443, 40),
+		row("10.0.0.2", 443, 30),
+		row("10.0.0.1", 80, 20),
+		row("10.0.0.2", 53, 10),
+	}
+
+	printTable := func(queryType string, opts ...PrinterOption) []string {
+		attributes, selector, err := types.ParseQueryType(queryType)
+		require.Nil(t, err)
+
+		buf := &bytes.Buffer{}
+		printer, err := NewTablePrinter(buf, FormatTXT, SortPackets, selector, types.DirectionIn,
+			attributes, nil, types.Counters{PacketsRcvd: 200}, len(rows), 0, "", "eth0", opts...)
+		require.Nil(t, err)
+
+		require.Nil(t, printer.AddRows(context.Background(), rows))
+		require.Nil(t, printer.Footer(&Result{}))
+		require.Nil(t, printer.Print(&Result{}))
+
+		// only return the table rows (skipping the headers)
+		var lines []string
+		for _, line := range strings.Split(buf.String(), "\n")[3:] {
+			if strings.TrimSpace(line) == "" {
+				break
+			}
+			lines = append(lines, strings.Fields(line)...)
+			lines = append(lines, "|")
+		}
+		return lines
+	}
+
+	t.Run("none", func(t *testing.T) {
+		lines := printTable("sip,dport")
+		require.NotContains(t, lines, "total")
+		require.NotContains(t, lines, "subtotal")
+	})
+
+	t.Run("total", func(t *testing.T) {
+		lines := printTable("sip,dport", WithTotalRow(true))
+		require.Contains(t, strings.Join(lines, " "), "| total 100.00 50.00 0.00 B 0.00 |")
+	})
+
+	t.Run("subtotals", func(t *testing.T) {
+		lines := strings.Join(printTable("sip,dport", WithSubtotals(true), WithTotalRow(true)), " ")
+		require.True(t, strings.HasPrefix(lines,
+			"10.0.0.1 443 40.00 20.00 0.00 B 0.00 | 10.0.0.1 80 20.00 10.00 0.00 B 0.00 | 10.0.0.1 subtotal 60.00 30.00 0.00 B 0.00 | "+
+				"10.0.0.2 443 30.00 15.00 0.00 B 0.00 | 10.0.0.2 53 10.00 5.00 0.00 B 0.00 | 10.0.0.2 subtotal 40.00 20.00 0.00 B 0.00 | "+
+				"total 100.00 50.00 0.00 B 0.00 |"), lines)
+	})
+
+	t.Run("raw units", func(t *testing.T) {
+		lines := strings.Join(printTable("sip,dport", WithRawUnits(true), WithTotalRow(true)), " ")
+		require.True(t, strings.HasPrefix(lines, "10.0.0.1 443 40 20.00 0 0.00 | "), lines)
+		require.Contains(t, lines, "| total 100 50.00 0 0.00 |")
+	})
+
+	t.Run("subtotals single attribute", func(t *testing.T) {
+		require.NotContains(t, printTable("sip", WithSubtotals(true)), "subtotal")
+	})
+}
+
+func TestTextDistinct(t *testing.T) {
+	distinct := hll.New()
+	distinct.Add([]byte{10, 0, 0, 1})
+	distinct.Add([]byte{10, 0, 0, 2})
+
+	attributes, selector, err := types.ParseQueryType("dip")
+	require.Nil(t, err)
+
+	buf := &bytes.Buffer{}
+	printer, err := NewTablePrinter(buf, FormatTXT, SortPackets, selector, types.DirectionIn,
+		attributes, nil, types.Counters{PacketsRcvd: 10}, 1, 0, "", "eth0", WithDistinct(types.SIPName))
+	require.Nil(t, err)
+
+	require.Nil(t, printer.AddRow(Row{
+		Attributes: Attributes{DstIP: netip.MustParseAddr("10.0.0.3")},
+		Counters:   types.Counters{PacketsRcvd: 10},
+		Distinct:   distinct,
+	}))
+	require.Nil(t, printer.Footer(&Result{}))
+	require.Nil(t, printer.Print(&Result{}))
+
+	lines := strings.Split(buf.String(), "\n")[1:]
+	require.Equal(t, []string{"distinct", "packets", "bytes"}, strings.Fields(lines[0]))
+	require.Equal(t, []string{"dip", "sip", "in", "%", "in", "%"}, strings.Fields(lines[1]))
+	require.Equal(t, []string{"10.0.0.3", "2.00", "10.00", "100.00", "0.00", "B", "0.00"}, strings.Fields(lines[2]))
+}
+
+func TestHostnameColumns(t *testing.T) {
+	attributes, selector, err := types.ParseQueryType("sip,dip")
+	require.Nil(t, err)
+
+	ips2domains := map[string]string{
+		"10.0.0.1": "host-a.example.com.",
+		"10.0.0.3": "host-c.example.com.",
+	}
+	rows := Rows{
+		{Attributes: Attributes{SrcIP: netip.MustParseAddr("10.0.0.1"), DstIP: netip.MustParseAddr("10.0.0.2")}, Counters: types.Counters{PacketsRcvd: 6}},
+		{Attributes: Attributes{SrcIP: netip.MustParseAddr("10.0.0.4"), DstIP: netip.MustParseAddr("10.0.0.3")}, Counters: types.Counters{PacketsRcvd: 4}},
+	}
+
+	printCSV := func(ips2domains map[string]string) []string {
+		buf := &bytes.Buffer{}
+		printer, err := NewTablePrinter(buf, FormatCSV, SortPackets, selector, types.DirectionIn,
+			attributes, ips2domains, types.Counters{PacketsRcvd: 10}, len(rows), 0, "", "eth0")
+		require.Nil(t, err)
+
+		require.Nil(t, printer.AddRows(context.Background(), rows))
+		require.Nil(t, printer.Print(&Result{}))
+		return strings.Split(buf.String(), "\n")[:3]
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		require.Equal(t, []string{
+			"sip,dip,packets,%,data vol.,%",
+			"10.0.0.1,10.0.0.2,6,60.00,0,0.00",
+			"10.0.0.4,10.0.0.3,4,40.00,0,0.00",
+		}, printCSV(nil))
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		require.Equal(t, []string{
+			"sip,sip_host,dip,dip_host,packets,%,data vol.,%",
+			"10.0.0.1,host-a.example.com.,10.0.0.2,,6,60.00,0,0.00",
+			"10.0.0.4,,10.0.0.3,host-c.example.com.,4,40.00,0,0.00",
+		}, printCSV(ips2domains))
+	})
+
+	t.Run("raw rows", func(t *testing.T) {
+		rows[0].SetHostnames(ips2domains)
+		require.Equal(t, &Hostnames{SrcHost: "host-a.example.com."}, rows[0].Hostnames)
+
+		rows[1].SetHostnames(map[string]string{})
+		require.Nil(t, rows[1].Hostnames)
+	})
+}
+
+func TestLabelColumnColumns(t *testing.T) {
+	attributes, _, err := types.ParseQueryType("dport")
+	require.Nil(t, err)
+	selector := types.LabelSelector{Columns: []string{"vlan"}}
+
+	buf := &bytes.Buffer{}
+	printer, err := NewTablePrinter(buf, FormatCSV, SortPackets, selector, types.DirectionIn,
+		attributes, nil, types.Counters{PacketsRcvd: 10}, 1, 0, "", "eth0", WithEnrichments("dport_service"))
+	require.Nil(t, err)
+
+	require.Nil(t, printer.AddRow(Row{
+		Labels:      Labels{Columns: NewLabelColumns(map[string]string{"vlan": "42"})},
+		Attributes:  Attributes{DstPort: 443},
+		Counters:    types.Counters{PacketsRcvd: 10},
+		Enrichments: map[string]string{"dport_service": "https"},
+	}))
+	require.Nil(t, printer.Print(&Result{}))
+	require.Equal(t, []string{
+		"vlan,dport,dport_service,packets,%,data vol.,%",
+		"42,443,https,10,100.00,0,0.00",
+	}, strings.Split(buf.String(), "\n")[:2])
+}
+
+func TestTextDistribution(t *testing.T) {
+	attributes, selector, err := types.ParseQueryType("sip")
+	require.Nil(t, err)
+
+	buf := &bytes.Buffer{}
+	printer, err := NewTablePrinter(buf, FormatTXT, SortTraffic, selector, types.DirectionSum,
+		attributes, nil, types.Counters{BytesRcvd: 800}, 1, 0, "", "eth0", WithDistribution(true))
+	require.Nil(t, err)
+
+	require.Nil(t, printer.AddRow(Row{
+		Attributes:   Attributes{SrcIP: netip.MustParseAddr("10.0.0.1")},
+		Counters:     types.Counters{BytesRcvd: 800},
+		Distribution: &Distribution{100, 200, 300, 400, 500, 600, 700, 800},
+	}))
+	require.Nil(t, printer.Footer(&Result{}))
+	require.Nil(t, printer.Print(&Result{}))
+
+	lines := strings.Split(buf.String(), "\n")[1:]
+	require.Equal(t, []string{"bytes", "packets", "bytes"}, strings.Fields(lines[0]))
+	require.Equal(t, []string{"sip", "trend", "in+out", "%", "in+out", "%"}, strings.Fields(lines[1]))
+	require.Equal(t, "10.0.0.1", strings.Fields(lines[2])[0])
+	require.Equal(t, "▁▂▃▄▅▆▇█", strings.Fields(lines[2])[1])
+}
+
+func TestDistributionSparkline(t *testing.T) {
+	require.Equal(t, "        ", (&Distribution{}).Sparkline())
+	require.Equal(t, "   █    ", (&Distribution{3: 5000}).Sparkline())
+	require.Equal(t, "▁  ▄   █", (&Distribution{1, 0, 0, 50, 0, 0, 0, 100}).Sparkline())
+
+	require.Equal(t, 0, Bucket(0, 100, 899))
+	require.Equal(t, 0, Bucket(199, 100, 899))
+	require.Equal(t, 1, Bucket(200, 100, 899))
+	require.Equal(t, DistributionBuckets-1, Bucket(899, 100, 899))
+	require.Equal(t, DistributionBuckets-1, Bucket(1000, 100, 899))
+}
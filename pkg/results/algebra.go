@@ -0,0 +1,149 @@
+package results
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/els0r/goProbe/pkg/types"
+)
+
+// ErrIncompatibleResults denotes that results can't be combined because they stem from queries
+// with different attributes
+var ErrIncompatibleResults = errors.New("incompatible results")
+
+// Merge combines the rows of all results. The counters of rows with the same labels and attributes
+// are added up
+func Merge(res ...*Result) (*Result, error) {
+	if err := checkCompatible(res); err != nil {
+		return nil, err
+	}
+
+	rm := make(RowsMap)
+	for _, r := range res {
+		rm.MergeRows(r.Rows)
+	}
+
+	// the rows of a result may be limited, hence the totals are the ones of the results
+	combined := combine(res, rm)
+	combined.Summary.Totals = types.Counters{}
+	for _, r := range res {
+		combined.Summary.Totals = combined.Summary.Totals.Add(r.Summary.Totals)
+	}
+	return combined, nil
+}
+
+// Subtract returns the rows of res whose labels and attributes don't occur in any of the other
+// results, e.g. the hosts seen today but not yesterday. The counters of the remaining rows are
+// those of res
+func Subtract(res *Result, others ...*Result) (*Result, error) {
+	all := append([]*Result{res}, others...)
+	if err := checkCompatible(all); err != nil {
+		return nil, err
+	}
+
+	rm := make(RowsMap)
+	rm.MergeRows(res.Rows)
+	for _, other := range others {
+		for _, row := range other.Rows {
+			delete(rm, MergeableAttributes{row.Labels, row.Attributes})
+		}
+	}
+
+	return combine(all, rm), nil
+}
+
+// Intersect returns the rows whose labels and attributes occur in all results. The counters of the
+// remaining rows are added up across the results
+func Intersect(res ...*Result) (*Result, error) {
+	if err := checkCompatible(res); err != nil {
+		return nil, err
+	}
+
+	rm := make(RowsMap)
+	rm.MergeRows(res[0].Rows)
+	for _, r := range res[1:] {
+		om := make(RowsMap)
+		om.MergeRows(r.Rows)
+		for ma := range rm {
+			if _, exists := om[ma]; !exists {
+				delete(rm, ma)
+			}
+		}
+		for ma, values := range rm {
+			rm[ma] = values.merge(om[ma])
+		}
+	}
+
+	return combine(res, rm), nil
+}
+
+// checkCompatible makes sure that the rows of the results can be compared with each other, i.e.
+// that they were produced by queries for the same attributes
+func checkCompatible(res []*Result) error {
+	if len(res) == 0 {
+		return fmt.Errorf("%w: no results provided", ErrIncompatibleResults)
+	}
+	for _, r := range res[1:] {
+		if !slices.Equal(res[0].Query.Attributes, r.Query.Attributes) {
+			return fmt.Errorf("%w: attributes %v and %v differ", ErrIncompatibleResults, res[0].Query.Attributes, r.Query.Attributes)
+		}
+		if res[0].Query.Distinct != r.Query.Distinct {
+			return fmt.Errorf("%w: distinct attributes `%s` and `%s` differ", ErrIncompatibleResults, res[0].Query.Distinct, r.Query.Distinct)
+		}
+	}
+	return nil
+}
+
+// combine creates the result holding the rows of rm (sorted by bytes). The summary covers the
+// interfaces and time ranges of all results, its totals are the ones of the rows (which callers
+// may override)
+func combine(res []*Result, rm RowsMap) *Result {
+	combined := New()
+	combined.HostsStatuses = make(HostsStatuses)
+	combined.Query = Query{
+		Attributes: res[0].Query.Attributes,
+		Condition:  res[0].Query.Condition,
+		Distinct:   res[0].Query.Distinct,
+	}
+
+	var ifaces []string
+	for _, r := range res {
+		ifaces = append(ifaces, r.Summary.Interfaces...)
+		for host, status := range r.HostsStatuses {
+			combined.HostsStatuses[host] = status
+		}
+
+		// the condition is only retained if all results share it
+		if r.Query.Condition != combined.Query.Condition {
+			combined.Query.Condition = ""
+		}
+
+		if !r.Summary.First.IsZero() && (combined.Summary.First.IsZero() || r.Summary.First.Before(combined.Summary.First)) {
+			combined.Summary.First = r.Summary.First
+		}
+		if r.Summary.Last.After(combined.Summary.Last) {
+			combined.Summary.Last = r.Summary.Last
+		}
+	}
+	sort.Strings(ifaces)
+	combined.Summary.Interfaces = slices.Compact(ifaces)
+
+	combined.Rows = rm.ToRowsSorted(By(SortTraffic, types.DirectionBoth, false))
+	for _, row := range combined.Rows {
+		combined.Summary.Totals = combined.Summary.Totals.Add(row.Counters)
+	}
+	combined.Summary.Hits = Hits{
+		Displayed: len(combined.Rows),
+		Total:     len(combined.Rows),
+	}
+
+	if len(combined.Rows) == 0 {
+		combined.Status = Status{
+			Code:    types.StatusEmpty,
+			Message: ErrorNoResults.Error(),
+		}
+	}
+	return combined
+}
@@ -0,0 +1,68 @@
+package results
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultsAlgebra(t *testing.T) {
+	row := func(sip string, bytes uint64) Row {
+		return Row{
+			Attributes: Attributes{SrcIP: netip.MustParseAddr(sip)},
+			Counters:   types.Counters{BytesRcvd: bytes},
+		}
+	}
+	newResult := func(iface string, first time.Time, rows ...Row) *Result {
+		res := New()
+		res.Query = Query{Attributes: []string{types.SIPName}}
+		res.Summary.Interfaces = []string{iface}
+		res.Summary.First, res.Summary.Last = first, first.Add(24*time.Hour)
+		res.Rows = rows
+		for _, row := range rows {
+			res.Summary.Totals = res.Summary.Totals.Add(row.Counters)
+		}
+		return res
+	}
+
+	yesterday := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	today := yesterday.Add(24 * time.Hour)
+	resYesterday := newResult("eth0", yesterday, row("10.0.0.1", 100), row("10.0.0.2", 200))
+	resToday := newResult("eth1", today, row("10.0.0.2", 50), row("10.0.0.3", 10), row("10.0.0.4", 400))
+
+	merged, err := Merge(resYesterday, resToday)
+	require.Nil(t, err)
+	require.Equal(t, Rows{row("10.0.0.4", 400), row("10.0.0.2", 250), row("10.0.0.1", 100), row("10.0.0.3", 10)}, merged.Rows)
+	require.Equal(t, uint64(760), merged.Summary.Totals.BytesRcvd)
+	require.Equal(t, []string{"eth0", "eth1"}, merged.Summary.Interfaces)
+	require.Equal(t, yesterday, merged.Summary.First)
+	require.Equal(t, today.Add(24*time.Hour), merged.Summary.Last)
+	require.Equal(t, Hits{Displayed: 4, Total: 4}, merged.Summary.Hits)
+
+	// hosts seen today but not yesterday
+	subtracted, err := Subtract(resToday, resYesterday)
+	require.Nil(t, err)
+	require.Equal(t, Rows{row("10.0.0.4", 400), row("10.0.0.3", 10)}, subtracted.Rows)
+	require.Equal(t, uint64(410), subtracted.Summary.Totals.BytesRcvd)
+
+	intersected, err := Intersect(resToday, resYesterday)
+	require.Nil(t, err)
+	require.Equal(t, Rows{row("10.0.0.2", 250)}, intersected.Rows)
+	require.Equal(t, types.StatusOK, intersected.Status.Code)
+
+	empty, err := Subtract(resToday, resToday)
+	require.Nil(t, err)
+	require.Empty(t, empty.Rows)
+	require.Equal(t, types.StatusEmpty, empty.Status.Code)
+
+	// results of queries for different attributes can't be combined
+	other := newResult("eth0", today, row("10.0.0.1", 1))
+	other.Query.Attributes = []string{types.SIPName, types.DIPName}
+	_, err = Merge(resToday, other)
+	require.ErrorIs(t, err, ErrIncompatibleResults)
+	_, err = Intersect()
+	require.ErrorIs(t, err, ErrIncompatibleResults)
+}
@@ -0,0 +1,59 @@
+package results
+
+import "strings"
+
+// DistributionBuckets denotes the number of equally sized intervals the queried time range is
+// split into for the time distribution of a row
+const DistributionBuckets = 8
+
+// sparkTicks are the characters used to draw a sparkline, in increasing order of height
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// Distribution holds the bytes (sent and received) of a row per interval of the queried time
+// range, revealing whether the traffic was constant or occurred in bursts
+type Distribution [DistributionBuckets]uint64
+
+// Bucket returns the bucket the timestamp ts falls into if the range [first, last] is split into
+// DistributionBuckets intervals. Timestamps outside of the range are assigned to the first / last bucket
+func Bucket(ts, first, last int64) int {
+	if ts <= first || last <= first {
+		return 0
+	}
+	if ts >= last {
+		return DistributionBuckets - 1
+	}
+	return int((ts - first) * DistributionBuckets / (last - first + 1))
+}
+
+// Add adds the bytes to the i-th bucket
+func (d *Distribution) Add(i int, bytes uint64) {
+	d[i] += bytes
+}
+
+// Merge adds the buckets of od to d
+func (d *Distribution) Merge(od *Distribution) {
+	for i := range od {
+		d[i] += od[i]
+	}
+}
+
+// Sparkline draws the distribution as a sparkline, scaled to the largest bucket. Empty buckets
+// are drawn as blanks
+func (d *Distribution) Sparkline() string {
+	var peak uint64
+	for _, v := range d {
+		if v > peak {
+			peak = v
+		}
+	}
+
+	var sb strings.Builder
+	for _, v := range d {
+		if v == 0 {
+			sb.WriteRune(' ')
+			continue
+		}
+		sb.WriteRune(sparkTicks[(v*uint64(len(sparkTicks))-1)/peak])
+	}
+	return sb.String()
+}
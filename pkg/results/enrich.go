@@ -0,0 +1,136 @@
+package results
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/els0r/goProbe/pkg/types"
+)
+
+// Names of the built-in enrichers. Only the service and tunnel enrichers are registered by default,
+// the others require data to be provided (e.g. via NewPrefixEnricher())
+const (
+	EnricherService = "service"
+	EnricherLabel   = "label"
+	EnricherASN     = "asn"
+	EnricherTunnel  = "tunnel"
+)
+
+// ErrUnknownEnricher is returned if no enricher is registered under a name
+var ErrUnknownEnricher = errors.New("unknown enricher")
+
+var (
+	errorEmptyEnricherName = errors.New("empty enricher name")
+	errorEnricherExists    = errors.New("enricher already registered")
+	errorNilEnricher       = errors.New("nil enricher")
+)
+
+// Enricher attaches additional values to the final rows of a query before they are formatted,
+// e.g. the service of the destination port. Enrichers are made available to query statements by
+// registering them via RegisterEnricher(), which allows programs embedding the query package to
+// add their own
+type Enricher interface {
+	// Columns returns the names of the values attached to the rows of a query with the given
+	// attributes (in order). If none of the attributes is relevant, no columns are returned and
+	// the enricher isn't run
+	Columns(attributes []types.Attribute) []string
+
+	// Enrich attaches the values to the rows (see Row.Enrich())
+	Enrich(ctx context.Context, rows Rows) error
+}
+
+var enricherRegistry = struct {
+	sync.RWMutex
+	enrichers map[string]Enricher
+}{
+	enrichers: make(map[string]Enricher),
+}
+
+// RegisterEnricher registers an Enricher under a name
+func RegisterEnricher(name string, enricher Enricher) error {
+	if name == "" {
+		return errorEmptyEnricherName
+	}
+	if enricher == nil {
+		return fmt.Errorf("%w (enricher `%s`)", errorNilEnricher, name)
+	}
+
+	enricherRegistry.Lock()
+	defer enricherRegistry.Unlock()
+
+	if _, exists := enricherRegistry.enrichers[name]; exists {
+		return fmt.Errorf("%w: `%s`", errorEnricherExists, name)
+	}
+	enricherRegistry.enrichers[name] = enricher
+	return nil
+}
+
+// MustRegisterEnricher registers an Enricher and panics if the registration fails
+func MustRegisterEnricher(name string, enricher Enricher) {
+	if err := RegisterEnricher(name, enricher); err != nil {
+		panic(err)
+	}
+}
+
+// LookupEnricher returns the Enricher registered under a name
+func LookupEnricher(name string) (Enricher, bool) {
+	enricherRegistry.RLock()
+	defer enricherRegistry.RUnlock()
+
+	enricher, exists := enricherRegistry.enrichers[name]
+	return enricher, exists
+}
+
+// Enrichers returns the names of all registered enrichers (sorted)
+func Enrichers() []string {
+	enricherRegistry.RLock()
+	defer enricherRegistry.RUnlock()
+
+	names := make([]string, 0, len(enricherRegistry.enrichers))
+	for name := range enricherRegistry.enrichers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Enrich runs the enrichers registered under the given names over the rows (in order) and returns
+// the columns they attached, which can be printed via WithEnrichments()
+func Enrich(ctx context.Context, names []string, attributes []types.Attribute, rows Rows) (columns []string, err error) {
+	for _, name := range names {
+		enricher, exists := LookupEnricher(name)
+		if !exists {
+			return nil, fmt.Errorf("%w: `%s`", ErrUnknownEnricher, name)
+		}
+
+		cols := enricher.Columns(attributes)
+		if len(cols) == 0 {
+			continue
+		}
+		if err := enricher.Enrich(ctx, rows); err != nil {
+			return nil, fmt.Errorf("failed to run enricher `%s`: %w", name, err)
+		}
+		columns = append(columns, cols...)
+	}
+	return columns, nil
+}
+
+// EnrichmentColumns returns the columns the enrichers registered under the given names attach to the
+// rows of a query with the given attributes
+func EnrichmentColumns(names []string, attributes []types.Attribute) (columns []string) {
+	for _, name := range names {
+		if enricher, exists := LookupEnricher(name); exists {
+			columns = append(columns, enricher.Columns(attributes)...)
+		}
+	}
+	return columns
+}
+
+// the built-in enrichers not requiring any data
+func init() {
+	MustRegisterEnricher(EnricherService, NewServiceEnricher(DefaultServicesFile))
+	MustRegisterEnricher(EnricherTunnel, TunnelEnricher{})
+}
@@ -0,0 +1,218 @@
+package results
+
+import (
+	"bytes"
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+const testServices = `# comment
+ssh             22/tcp                          # SSH Remote Login Protocol
+domain          53/tcp                          # Domain Name Server
+domain          53/udp
+https           443/tcp
+https           443/udp         http3
+isakmp          500/udp
+ddp-only        4/ddp
+`
+
+const testPrefixes = `# comment
+10.0.0.0/8      internal
+10.1.0.0/16     internal servers
+2001:db8::/32   documentation
+192.168.1.1     gateway
+`
+
+func TestParseServices(t *testing.T) {
+	services, err := ParseServices(strings.NewReader(testServices))
+	require.Nil(t, err)
+
+	require.Equal(t, "https", services.Lookup(443, 17))
+	require.Equal(t, "domain", services.Lookup(53, 6))
+	require.Equal(t, "isakmp", services.Lookup(500, 0))
+	require.Equal(t, "", services.Lookup(500, 6))
+	require.Equal(t, "", services.Lookup(4, 0))
+
+	_, err = ParseServices(strings.NewReader("ssh\n"))
+	require.ErrorIs(t, err, errorInvalidService)
+	_, err = ParseServices(strings.NewReader("ssh 22\n"))
+	require.ErrorIs(t, err, errorInvalidService)
+}
+
+func TestPrefixEnricher(t *testing.T) {
+	prefixes, err := ParsePrefixes(strings.NewReader(testPrefixes))
+	require.Nil(t, err)
+	enricher := NewPrefixEnricher(EnricherLabel, prefixes)
+
+	for ip, expected := range map[string]string{
+		"10.0.0.1":           "internal",
+		"10.1.2.3":           "internal servers",
+		"::ffff:10.1.2.3":    "internal servers",
+		"2001:db8::1":        "documentation",
+		"192.168.1.1":        "gateway",
+		"192.168.1.2":        "",
+		"2001:db9::1":        "",
+		"11.0.0.1":           "",
+		"2001:db8:ffff::abc": "documentation",
+	} {
+		value, found := enricher.Lookup(netip.MustParseAddr(ip))
+		require.Equal(t, expected != "", found, ip)
+		require.Equal(t, expected, value, ip)
+	}
+
+	_, err = ParsePrefixes(strings.NewReader("10.0.0.0/8\n"))
+	require.ErrorIs(t, err, errorInvalidPrefix)
+	_, err = ParsePrefixes(strings.NewReader("10.0.0.0/33 internal\n"))
+	require.ErrorIs(t, err, errorInvalidPrefix)
+}
+
+func TestServiceEnricherMissingFile(t *testing.T) {
+	enricher := NewServiceEnricher(filepath.Join(t.TempDir(), "services"))
+
+	rows := Rows{{}}
+	rows[0].Attributes.DstPort = 22
+	rows[0].Attributes.IPProto = 6
+	require.Nil(t, enricher.Enrich(context.Background(), rows))
+	require.Equal(t, "", rows[0].Enrichments[types.DportName+"_"+EnricherService])
+}
+
+func TestRegisterEnricher(t *testing.T) {
+	require.Contains(t, Enrichers(), EnricherService)
+
+	prefixes, err := ParsePrefixes(strings.NewReader(testPrefixes))
+	require.Nil(t, err)
+	require.Nil(t, RegisterEnricher(EnricherLabel, NewPrefixEnricher(EnricherLabel, prefixes)))
+	defer func() {
+		enricherRegistry.Lock()
+		delete(enricherRegistry.enrichers, EnricherLabel)
+		enricherRegistry.Unlock()
+	}()
+
+	require.ErrorIs(t, RegisterEnricher(EnricherLabel, NewPrefixEnricher(EnricherLabel, nil)), errorEnricherExists)
+	require.ErrorIs(t, RegisterEnricher("", NewPrefixEnricher(EnricherLabel, nil)), errorEmptyEnricherName)
+	require.ErrorIs(t, RegisterEnricher("other", nil), errorNilEnricher)
+	require.Equal(t, []string{EnricherLabel, EnricherService, EnricherTunnel}, Enrichers())
+
+	_, err = Enrich(context.Background(), []string{"unknown"}, nil, nil)
+	require.ErrorIs(t, err, ErrUnknownEnricher)
+}
+
+func TestEnrichmentColumns(t *testing.T) {
+	servicesFile := filepath.Join(t.TempDir(), "services")
+	require.Nil(t, os.WriteFile(servicesFile, []byte(testServices), 0600))
+	prefixes, err := ParsePrefixes(strings.NewReader(testPrefixes))
+	require.Nil(t, err)
+
+	require.Nil(t, RegisterEnricher("test-service", NewServiceEnricher(servicesFile)))
+	require.Nil(t, RegisterEnricher("test-label", NewPrefixEnricher(EnricherLabel, prefixes)))
+	defer func() {
+		enricherRegistry.Lock()
+		delete(enricherRegistry.enrichers, "test-service")
+		delete(enricherRegistry.enrichers, "test-label")
+		enricherRegistry.Unlock()
+	}()
+
+	attributes, selector, err := types.ParseQueryType("sip,dport,proto")
+	require.Nil(t, err)
+
+	rows := Rows{
+		{Attributes: Attributes{SrcIP: netip.MustParseAddr("10.1.0.1"), DstPort: 443, IPProto: 6}, Counters: types.Counters{PacketsRcvd: 6}},
+		{Attributes: Attributes{SrcIP: netip.MustParseAddr("11.0.0.1"), DstPort: 8443, IPProto: 6}, Counters: types.Counters{PacketsRcvd: 4}},
+	}
+
+	columns, err := Enrich(context.Background(), []string{"test-label", "test-service"}, attributes, rows)
+	require.Nil(t, err)
+	require.Equal(t, []string{"sip_label", "dport_service"}, columns)
+	require.Equal(t, map[string]string{"sip_label": "internal servers", "dport_service": "https"}, rows[0].Enrichments)
+	require.Nil(t, rows[1].Enrichments)
+
+	// enrichers without relevant attributes don't attach any columns
+	columns, err = Enrich(context.Background(), []string{"test-service"}, attributes[:1], rows)
+	require.Nil(t, err)
+	require.Empty(t, columns)
+
+	buf := &bytes.Buffer{}
+	printer, err := NewTablePrinter(buf, FormatCSV, SortPackets, selector, types.DirectionIn,
+		attributes, nil, types.Counters{PacketsRcvd: 10}, len(rows), 0, "", "eth0", WithEnrichments("sip_label", "dport_service"))
+	require.Nil(t, err)
+
+	require.Nil(t, printer.AddRows(context.Background(), rows))
+	require.Nil(t, printer.Footer(&Result{}))
+	require.Nil(t, printer.Print(&Result{}))
+	require.Equal(t, []string{
+		"sip,dport,proto,sip_label,dport_service,packets,%,data vol.,%",
+		"10.1.0.1,443,TCP,internal servers,https,6,60.00,0,0.00",
+		"11.0.0.1,8443,TCP,,,4,40.00,0,0.00",
+	}, strings.Split(buf.String(), "\n")[:3])
+
+	buf.Reset()
+	printer, err = NewTablePrinter(buf, FormatTXT, SortPackets, selector, types.DirectionIn,
+		attributes, nil, types.Counters{PacketsRcvd: 10}, len(rows), 0, "", "eth0", WithEnrichments("sip_label", "dport_service"))
+	require.Nil(t, err)
+
+	require.Nil(t, printer.AddRows(context.Background(), rows))
+	require.Nil(t, printer.Footer(&Result{Summary: Summary{Interfaces: []string{"eth0"}}}))
+	require.Nil(t, printer.Print(&Result{}))
+	lines := strings.Split(buf.String(), "\n")
+	require.Equal(t, []string{"sip", "dport", "proto", "sip_label", "dport_service", "in", "%", "in", "%"}, strings.Fields(lines[2]))
+	require.Equal(t, []string{"10.1.0.1", "443", "TCP", "internal", "servers", "https", "6.00", "60.00", "0.00", "B", "0.00"}, strings.Fields(lines[3]))
+}
+
+func TestRowFilters(t *testing.T) {
+	rows := Rows{
+		{Hostnames: &Hostnames{SrcHost: "host.example.com", DstHost: "ec2-1-2-3-4.compute.amazonaws.com"}, Enrichments: map[string]string{"dip_asn": "AS16509"}},
+		{Labels: Labels{Process: "nginx.service"}, Hostnames: &Hostnames{SrcHost: "ec2-5-6-7-8.compute.amazonaws.com"}, Enrichments: map[string]string{"dip_asn": "AS3303"}},
+		{Enrichments: map[string]string{"dip_asn": "AS14618"}},
+	}
+
+	var tests = []struct {
+		filters  []string
+		expected []int
+	}{
+		{[]string{"hostname=*.amazonaws.com"}, []int{0, 1}},
+		{[]string{"sip_hostname=*.amazonaws.com"}, []int{1}},
+		{[]string{"dip_hostname=*.amazonaws.com"}, []int{0}},
+		{[]string{"dip_asn=/^AS(16509|14618)$/"}, []int{0, 2}},
+		{[]string{"hostname=*.amazonaws.com", "dip_asn=/^AS(16509|14618)$/"}, []int{0}},
+		{[]string{"dip_hostname="}, nil},
+		{[]string{"sip_label=*"}, []int{0, 1, 2}},
+		{[]string{"process=nginx*"}, []int{1}},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.filters, " & "), func(t *testing.T) {
+			filters, err := ParseRowFilters(test.filters)
+			if test.expected == nil {
+				require.ErrorIs(t, err, ErrInvalidFilter)
+				return
+			}
+			require.Nil(t, err)
+
+			var matched []int
+			for i := range rows {
+				if filters.Match(&rows[i]) {
+					matched = append(matched, i)
+				}
+			}
+			require.Equal(t, test.expected, matched)
+		})
+	}
+
+	for _, filter := range []string{"hostname", "=*", "hostname=[", "hostname=/(/"} {
+		_, err := ParseRowFilter(filter)
+		require.ErrorIs(t, err, ErrInvalidFilter, filter)
+	}
+
+	filters, err := ParseRowFilters([]string{"dip_asn=AS3303"})
+	require.Nil(t, err)
+	filtered := filters.Filter(append(Rows{}, rows...))
+	require.Len(t, filtered, 1)
+	require.Equal(t, "AS3303", filtered[0].Enrichments["dip_asn"])
+}
@@ -0,0 +1,315 @@
+package results
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/els0r/goProbe/pkg/goDB/protocols"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/goProbe/pkg/util"
+	"github.com/els0r/telemetry/logging"
+)
+
+// DefaultServicesFile is the services database the built-in service enricher reads from
+const DefaultServicesFile = "/etc/services"
+
+const enrichmentComment = "#"
+
+var (
+	errorInvalidService = errors.New("invalid service definition")
+	errorInvalidPrefix  = errors.New("invalid prefix definition")
+)
+
+type serviceKey struct {
+	port  uint16
+	proto uint8
+}
+
+// Services maps ports (per IP protocol) to service names
+type Services map[serviceKey]string
+
+// servicesFallbackProtos are consulted (in order) if the IP protocol of a row is unknown
+var servicesFallbackProtos = []uint8{6, 17, 132} // TCP, UDP, SCTP
+
+// ParseServices reads service definitions in the format of /etc/services, i.e.
+//
+//	https   443/tcp   # http protocol over TLS/SSL
+//
+// one per line. Aliases, comments and definitions for unknown IP protocols are ignored
+func ParseServices(r io.Reader) (Services, error) {
+	services := make(Services)
+
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line, _, _ := strings.Cut(scanner.Text(), enrichmentComment)
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%w in line %d: expected `name port/proto`", errorInvalidService, lineNum)
+		}
+
+		portStr, protoStr, found := strings.Cut(fields[1], "/")
+		port, err := strconv.ParseUint(portStr, 10, 16)
+		if !found || err != nil {
+			return nil, fmt.Errorf("%w in line %d: invalid port `%s`", errorInvalidService, lineNum, fields[1])
+		}
+		proto, exists := protocols.GetIPProtoID(strings.ToLower(protoStr))
+		if !exists {
+			continue
+		}
+
+		// the first definition of a port takes precedence
+		key := serviceKey{port: uint16(port), proto: uint8(proto)}
+		if _, exists := services[key]; !exists {
+			services[key] = fields[0]
+		}
+	}
+	return services, scanner.Err()
+}
+
+// Lookup returns the service name of a port. If the IP protocol is unknown (0), the service is
+// looked up for TCP, UDP and SCTP (in that order)
+func (s Services) Lookup(port uint16, proto uint8) string {
+	if proto != 0 {
+		return s[serviceKey{port: port, proto: proto}]
+	}
+	for _, proto := range servicesFallbackProtos {
+		if service, exists := s[serviceKey{port: port, proto: proto}]; exists {
+			return service
+		}
+	}
+	return ""
+}
+
+// ServiceEnricher attaches the service name of the destination port to the rows. The services are
+// read from file upon first use. If the file doesn't exist, the service names are left empty
+type ServiceEnricher struct {
+	path string
+
+	once     sync.Once
+	services Services
+	err      error
+}
+
+// NewServiceEnricher creates an enricher reading the services from a file in the format of
+// /etc/services (see ParseServices())
+func NewServiceEnricher(path string) *ServiceEnricher {
+	return &ServiceEnricher{path: path}
+}
+
+// Columns returns the service column if the destination port is queried
+func (e *ServiceEnricher) Columns(attributes []types.Attribute) []string {
+	for _, attribute := range attributes {
+		if attribute.Name() == types.DportName {
+			return []string{types.DportName + "_" + EnricherService}
+		}
+	}
+	return nil
+}
+
+// Enrich attaches the service names to the rows
+func (e *ServiceEnricher) Enrich(ctx context.Context, rows Rows) error {
+	e.once.Do(func() {
+		var f *os.File
+		if f, e.err = os.Open(filepath.Clean(e.path)); e.err != nil {
+			if errors.Is(e.err, fs.ErrNotExist) {
+				logging.FromContext(ctx).Warnf("services file %s not found, leaving service names empty", e.path)
+				e.err = nil
+			}
+			return
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		e.services, e.err = ParseServices(f)
+	})
+	if e.err != nil {
+		return fmt.Errorf("failed to read services from %s: %w", e.path, e.err)
+	}
+
+	column := types.DportName + "_" + EnricherService
+	for i := range rows {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rows[i].Enrich(column, e.services.Lookup(rows[i].Attributes.DstPort, rows[i].Attributes.IPProto))
+	}
+	return nil
+}
+
+// PrefixEnricher attaches a value to the source / destination IPs of the rows based on the longest
+// matching prefix, e.g. a label for internal networks or the ASN an IP belongs to. The columns are
+// named after the IP attributes, suffixed by the name of the enricher (e.g. "sip_label")
+type PrefixEnricher struct {
+	name string
+
+	// prefixes holds the values per prefix length, bits the prefix lengths present (longest first)
+	prefixes map[int]map[netip.Prefix]string
+	bits     []int
+}
+
+// NewPrefixEnricher creates an enricher attaching the values of the given prefixes
+func NewPrefixEnricher(name string, prefixes map[netip.Prefix]string) *PrefixEnricher {
+	e := &PrefixEnricher{
+		name:     name,
+		prefixes: make(map[int]map[netip.Prefix]string),
+	}
+	for prefix, value := range prefixes {
+		prefix = prefix.Masked()
+		if _, exists := e.prefixes[prefix.Bits()]; !exists {
+			e.prefixes[prefix.Bits()] = make(map[netip.Prefix]string)
+			e.bits = append(e.bits, prefix.Bits())
+		}
+		e.prefixes[prefix.Bits()][prefix] = value
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(e.bits)))
+	return e
+}
+
+// LoadPrefixEnricher creates an enricher attaching the values of the prefixes read from a file
+// (see ParsePrefixes())
+func LoadPrefixEnricher(name, path string) (*PrefixEnricher, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prefixes: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	prefixes, err := ParsePrefixes(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse prefixes from %s: %w", path, err)
+	}
+	return NewPrefixEnricher(name, prefixes), nil
+}
+
+// ParsePrefixes reads prefix definitions of the form
+//
+//	10.0.0.0/8   internal
+//
+// one per line, where the value is the remainder of the line. Single IPs are treated as host
+// prefixes. Empty lines and lines starting with "#" are ignored
+func ParsePrefixes(r io.Reader) (map[netip.Prefix]string, error) {
+	prefixes := make(map[netip.Prefix]string)
+
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, enrichmentComment) {
+			continue
+		}
+
+		sep := strings.IndexAny(line, " \t")
+		if sep < 0 {
+			return nil, fmt.Errorf("%w in line %d: expected `prefix value`", errorInvalidPrefix, lineNum)
+		}
+		prefixStr, value := line[:sep], strings.TrimSpace(line[sep:])
+
+		prefix, err := netip.ParsePrefix(prefixStr)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(prefixStr)
+			if aerr != nil {
+				return nil, fmt.Errorf("%w in line %d: %w", errorInvalidPrefix, lineNum, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes[prefix.Masked()] = value
+	}
+	return prefixes, scanner.Err()
+}
+
+// Lookup returns the value of the longest prefix containing the IP
+func (e *PrefixEnricher) Lookup(ip netip.Addr) (string, bool) {
+	ip = ip.Unmap()
+	for _, bits := range e.bits {
+		prefix, err := ip.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if value, exists := e.prefixes[bits][prefix]; exists {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// Columns returns the columns of the queried IP attributes
+func (e *PrefixEnricher) Columns(attributes []types.Attribute) (columns []string) {
+	for _, attribute := range attributes {
+		switch attribute.Name() {
+		case types.SIPName, types.DIPName:
+			columns = append(columns, attribute.Name()+"_"+e.name)
+		}
+	}
+	return columns
+}
+
+// Enrich attaches the values of the prefixes matching the IPs to the rows
+func (e *PrefixEnricher) Enrich(ctx context.Context, rows Rows) error {
+	sipColumn, dipColumn := types.SIPName+"_"+e.name, types.DIPName+"_"+e.name
+	for i := range rows {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if ip := rows[i].Attributes.SrcIP; ip.IsValid() {
+			value, _ := e.Lookup(ip)
+			rows[i].Enrich(sipColumn, value)
+		}
+		if ip := rows[i].Attributes.DstIP; ip.IsValid() {
+			value, _ := e.Lookup(ip)
+			rows[i].Enrich(dipColumn, value)
+		}
+	}
+	return nil
+}
+
+// Columns of the tunnel enricher
+const (
+	TunnelPeerColumn = "iface_peer"
+	TunnelSiteColumn = "iface_site"
+)
+
+// TunnelEnricher attaches the peer and site of tunnel interfaces to the rows (see util.TunnelInfos()).
+// Since the interface is a label rather than an attribute, its columns are attached to the rows
+// of all queries. Rows without an interface label or of non-tunnel interfaces remain empty
+type TunnelEnricher struct{}
+
+// Columns returns the tunnel columns
+func (TunnelEnricher) Columns(_ []types.Attribute) []string {
+	return []string{TunnelPeerColumn, TunnelSiteColumn}
+}
+
+// Enrich attaches the tunnel metadata of the interfaces to the rows
+func (TunnelEnricher) Enrich(ctx context.Context, rows Rows) error {
+	tunnels, err := util.TunnelInfos()
+	if err != nil {
+		return err
+	}
+	if len(tunnels) == 0 {
+		return nil
+	}
+	for i := range rows {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if tunnel, isTunnel := tunnels[rows[i].Labels.Iface]; isTunnel {
+			rows[i].Enrich(TunnelPeerColumn, tunnel.Peer)
+			rows[i].Enrich(TunnelSiteColumn, tunnel.Site)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package results
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/els0r/goProbe/pkg/types"
+)
+
+// Fields of the resolved hostnames which can be filtered on (in addition to the columns attached by
+// enrichers and the attributes stored in label columns). FilterHostname matches if the hostname of
+// either the source or destination IP matches. FilterProcess matches the process label of a row (if queried)
+const (
+	FilterHostname    = "hostname"
+	FilterSrcHostname = types.SIPName + "_" + FilterHostname
+	FilterDstHostname = types.DIPName + "_" + FilterHostname
+	FilterProcess     = types.ProcessName
+)
+
+// ErrInvalidFilter is returned if a row filter cannot be parsed
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// RowFilter matches the final rows of a query on values which can't be used in a condition, i.e. the
+// hostnames resolved via reverse DNS, the process labels, the attributes stored in label columns and
+// the columns attached by enrichers. Missing values are matched as empty strings
+type RowFilter struct {
+	Field   string
+	Pattern string
+
+	re *regexp.Regexp
+}
+
+// ParseRowFilter parses a filter of the form `<field>=<pattern>`. The pattern is a glob (e.g.
+// `*.amazonaws.com`) or a regular expression if enclosed in slashes (e.g. `/^AS(16509|14618)$/`)
+func ParseRowFilter(filter string) (RowFilter, error) {
+	field, pattern, found := strings.Cut(filter, "=")
+	field, pattern = strings.TrimSpace(field), strings.TrimSpace(pattern)
+	if !found || field == "" || pattern == "" {
+		return RowFilter{}, fmt.Errorf("%w: `%s` (must be of the form <field>=<pattern>)", ErrInvalidFilter, filter)
+	}
+
+	f := RowFilter{Field: field, Pattern: pattern}
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return RowFilter{}, fmt.Errorf("%w: `%s`: %w", ErrInvalidFilter, filter, err)
+		}
+		f.re = re
+		return f, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return RowFilter{}, fmt.Errorf("%w: `%s`: %w", ErrInvalidFilter, filter, err)
+	}
+	return f, nil
+}
+
+// IsHostnameFilter returns whether the filter matches on resolved hostnames
+func (f RowFilter) IsHostnameFilter() bool {
+	switch f.Field {
+	case FilterHostname, FilterSrcHostname, FilterDstHostname:
+		return true
+	}
+	return false
+}
+
+// IsLabelColumnFilter returns whether the filter matches on an attribute stored in a label column
+// (see types.AttributeSpec.LabelColumn)
+func (f RowFilter) IsLabelColumnFilter() bool {
+	spec, exists := types.LookupAttribute(f.Field)
+	return exists && spec.LabelColumn && spec.Name == f.Field
+}
+
+// Match returns whether the row matches the filter
+func (f RowFilter) Match(row *Row) bool {
+	var hostnames Hostnames
+	if row.Hostnames != nil {
+		hostnames = *row.Hostnames
+	}
+
+	switch f.Field {
+	case FilterHostname:
+		return f.matchValue(hostnames.SrcHost) || f.matchValue(hostnames.DstHost)
+	case FilterSrcHostname:
+		return f.matchValue(hostnames.SrcHost)
+	case FilterDstHostname:
+		return f.matchValue(hostnames.DstHost)
+	case FilterProcess:
+		return f.matchValue(row.Labels.Process)
+	}
+	if f.IsLabelColumnFilter() {
+		return f.matchValue(row.Labels.Columns.Get(f.Field))
+	}
+	return f.matchValue(row.Enrichments[f.Field])
+}
+
+func (f RowFilter) matchValue(value string) bool {
+	if f.re != nil {
+		return f.re.MatchString(value)
+	}
+	matches, _ := path.Match(f.Pattern, value)
+	return matches
+}
+
+// String returns the filter in its parseable form
+func (f RowFilter) String() string {
+	return f.Field + "=" + f.Pattern
+}
+
+// RowFilters denotes a set of filters, all of which have to match
+type RowFilters []RowFilter
+
+// ParseRowFilters parses a set of filters (see ParseRowFilter())
+func ParseRowFilters(filters []string) (RowFilters, error) {
+	fs := make(RowFilters, 0, len(filters))
+	for _, filter := range filters {
+		f, err := ParseRowFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		fs = append(fs, f)
+	}
+	return fs, nil
+}
+
+// Match returns whether the row matches all filters
+func (fs RowFilters) Match(row *Row) bool {
+	for _, f := range fs {
+		if !f.Match(row) {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns the rows matching all filters (reusing the memory of rows)
+func (fs RowFilters) Filter(rows Rows) Rows {
+	if len(fs) == 0 {
+		return rows
+	}
+	filtered := rows[:0]
+	for i := range rows {
+		if fs.Match(&rows[i]) {
+			filtered = append(filtered, rows[i])
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,252 @@
+package results
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/types"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Built-in output formats
+const (
+	FormatTXT  = "txt"
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// ErrUnknownFormat is returned if no formatter is registered for an output format
+var ErrUnknownFormat = errors.New("unknown output format")
+
+var (
+	errorEmptyFormatName = errors.New("empty output format name")
+	errorFormatExists    = errors.New("output format already registered")
+	errorNilFormatter    = errors.New("nil formatter")
+)
+
+// PrinterConfig holds everything a Formatter requires to set up a TablePrinter
+type PrinterConfig struct {
+	Output io.Writer // Output: the writer the results are printed to
+
+	Sort          SortOrder           // Sort: the order the rows are sorted in
+	LabelSelector types.LabelSelector // LabelSelector: the labels that are part of the query
+	Direction     types.Direction     // Direction: the counters that are printed
+	Attributes    []types.Attribute   // Attributes: the attributes that are part of the query
+	Distinct      string              // Distinct: the attribute whose distinct values are counted per row (if any)
+	Distribution  bool                // Distribution: print the time distribution of the bytes of each row as a sparkline
+
+	IPs2Domains map[string]string // IPs2Domains: reverse DNS lookups of the IPs in the result. If non-nil, the hostnames are printed in separate columns next to the IPs
+	Enrichments []string          // Enrichments: the columns attached to the rows by enrichers (see Enrich()), printed after the attributes
+	Totals      types.Counters    // Totals: the overall counters, e.g. for computing percentages
+
+	NumFlows       int           // NumFlows: total number of flows that matched the query
+	ResolveTimeout time.Duration // ResolveTimeout: the timeout used for reverse DNS lookups
+	Ifaces         string        // Ifaces: comma separated list of the queried interfaces
+
+	RawUnits  bool // RawUnits: print sizes and counts as exact integers instead of in human-readable form (txt format only)
+	TotalRow  bool // TotalRow: append a row summing up the printed rows (txt format only)
+	Subtotals bool // Subtotals: group the printed rows by their first column and append a subtotal row to each group (txt format only)
+}
+
+// PrinterOption sets optional parameters of the PrinterConfig
+type PrinterOption func(*PrinterConfig)
+
+// WithRawUnits prints sizes and counts as exact integers instead of in human-readable form
+func WithRawUnits(enable bool) PrinterOption {
+	return func(c *PrinterConfig) {
+		c.RawUnits = enable
+	}
+}
+
+// WithTotalRow appends a row summing up the printed rows
+func WithTotalRow(enable bool) PrinterOption {
+	return func(c *PrinterConfig) {
+		c.TotalRow = enable
+	}
+}
+
+// WithSubtotals groups the printed rows by their first column and appends a subtotal row to
+// each group. It only takes effect if more than one label / attribute is printed
+func WithSubtotals(enable bool) PrinterOption {
+	return func(c *PrinterConfig) {
+		c.Subtotals = enable
+	}
+}
+
+// WithDistinct prints the number of distinct values of the attribute counted per row
+func WithDistinct(attribute string) PrinterOption {
+	return func(c *PrinterConfig) {
+		c.Distinct = attribute
+	}
+}
+
+// WithDistribution prints the time distribution of the bytes of each row as a sparkline
+func WithDistribution(enable bool) PrinterOption {
+	return func(c *PrinterConfig) {
+		c.Distribution = enable
+	}
+}
+
+// WithEnrichments prints the columns attached to the rows by enrichers (see Enrich())
+func WithEnrichments(columns ...string) PrinterOption {
+	return func(c *PrinterConfig) {
+		c.Enrichments = columns
+	}
+}
+
+// Columns returns the OutputColumns to be printed for the configured labels, attributes
+// and direction (in order)
+func (c PrinterConfig) Columns() []OutputColumn {
+	return columns(c.LabelSelector, c.Attributes, c.IPs2Domains != nil, c.Enrichments, c.Distinct, c.Distribution, c.Direction)
+}
+
+// Value returns the value of a row for the given OutputColumn, formatted by format
+func (c PrinterConfig) Value(format ValueFormatter, row Row, col OutputColumn) string {
+	return extract(format, c.IPs2Domains, c.Enrichments, c.LabelSelector.Columns, c.Totals, row, col)
+}
+
+// Formatter is implemented by all output formats. Formats are made available to query
+// statements by registering them via RegisterFormatter(), which allows programs embedding
+// the query package to add their own
+type Formatter interface {
+	// NewTablePrinter creates a TablePrinter writing to the configured output
+	NewTablePrinter(cfg PrinterConfig) (TablePrinter, error)
+
+	// RawResult denotes whether the format serializes the full result. For such formats, the
+	// result is printed regardless of its status (and resolved hostnames are part of the rows)
+	RawResult() bool
+}
+
+// FormatterFunc adapts a function creating TablePrinters to the Formatter interface
+// (the resulting Formatter doesn't serialize the raw result)
+type FormatterFunc func(cfg PrinterConfig) (TablePrinter, error)
+
+// NewTablePrinter calls f(cfg)
+func (f FormatterFunc) NewTablePrinter(cfg PrinterConfig) (TablePrinter, error) {
+	return f(cfg)
+}
+
+// RawResult returns false
+func (FormatterFunc) RawResult() bool {
+	return false
+}
+
+var formatterRegistry = struct {
+	sync.RWMutex
+	formatters map[string]Formatter
+}{
+	formatters: make(map[string]Formatter),
+}
+
+// RegisterFormatter registers a Formatter for an output format
+func RegisterFormatter(format string, formatter Formatter) error {
+	if format == "" {
+		return errorEmptyFormatName
+	}
+	if formatter == nil {
+		return fmt.Errorf("%w (format `%s`)", errorNilFormatter, format)
+	}
+
+	formatterRegistry.Lock()
+	defer formatterRegistry.Unlock()
+
+	if _, exists := formatterRegistry.formatters[format]; exists {
+		return fmt.Errorf("%w: `%s`", errorFormatExists, format)
+	}
+	formatterRegistry.formatters[format] = formatter
+	return nil
+}
+
+// MustRegisterFormatter registers a Formatter and panics if the registration fails
+func MustRegisterFormatter(format string, formatter Formatter) {
+	if err := RegisterFormatter(format, formatter); err != nil {
+		panic(err)
+	}
+}
+
+// LookupFormatter returns the Formatter registered for an output format
+func LookupFormatter(format string) (Formatter, bool) {
+	formatterRegistry.RLock()
+	defer formatterRegistry.RUnlock()
+
+	formatter, exists := formatterRegistry.formatters[format]
+	return formatter, exists
+}
+
+// IsRawFormat returns whether the output format serializes the full result (see Formatter)
+func IsRawFormat(format string) bool {
+	formatter, exists := LookupFormatter(format)
+	return exists && formatter.RawResult()
+}
+
+// Formats returns the names of all registered output formats (sorted)
+func Formats() []string {
+	formatterRegistry.RLock()
+	defer formatterRegistry.RUnlock()
+
+	formats := make([]string, 0, len(formatterRegistry.formatters))
+	for format := range formatterRegistry.formatters {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// JSONTablePrinter serializes the full result as JSON. Rows and footer are part of the
+// result, hence they are only written upon Print()
+type JSONTablePrinter struct {
+	output io.Writer
+}
+
+// NewJSONTablePrinter creates a new JSONTablePrinter
+func NewJSONTablePrinter(output io.Writer) *JSONTablePrinter {
+	return &JSONTablePrinter{output: output}
+}
+
+// AddRow is a no-op, since the rows are serialized as part of the result
+func (j *JSONTablePrinter) AddRow(_ Row) error {
+	return nil
+}
+
+// AddRows is a no-op, since the rows are serialized as part of the result
+func (j *JSONTablePrinter) AddRows(_ context.Context, _ Rows) error {
+	return nil
+}
+
+// Footer is a no-op, since the summary is serialized as part of the result
+func (j *JSONTablePrinter) Footer(_ *Result) error {
+	return nil
+}
+
+// Print serializes the result
+func (j *JSONTablePrinter) Print(result *Result) error {
+	return jsoniter.NewEncoder(j.output).Encode(result)
+}
+
+type jsonFormatter struct{}
+
+func (jsonFormatter) NewTablePrinter(cfg PrinterConfig) (TablePrinter, error) {
+	return NewJSONTablePrinter(cfg.Output), nil
+}
+
+func (jsonFormatter) RawResult() bool {
+	return true
+}
+
+// the built-in output formats
+func init() {
+	MustRegisterFormatter(FormatTXT, FormatterFunc(func(cfg PrinterConfig) (TablePrinter, error) {
+		return NewTextTablePrinter(newBasePrinter(cfg), cfg.NumFlows, cfg.ResolveTimeout).
+			WithAggregateRows(cfg.TotalRow, cfg.Subtotals).
+			WithRawUnits(cfg.RawUnits), nil
+	}))
+	MustRegisterFormatter(FormatCSV, FormatterFunc(func(cfg PrinterConfig) (TablePrinter, error) {
+		return NewCSVTablePrinter(newBasePrinter(cfg)), nil
+	}))
+	MustRegisterFormatter(FormatJSON, jsonFormatter{})
+}
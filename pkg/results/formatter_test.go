@@ -0,0 +1,76 @@
+package results
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+// countPrinter prints the number of rows and the total number of packets
+type countPrinter struct {
+	cfg  PrinterConfig
+	rows int
+}
+
+func (c *countPrinter) AddRow(_ Row) error {
+	c.rows++
+	return nil
+}
+
+func (c *countPrinter) AddRows(ctx context.Context, rows Rows) error {
+	return addRows(ctx, c, rows)
+}
+
+func (c *countPrinter) Footer(_ *Result) error {
+	return nil
+}
+
+func (c *countPrinter) Print(_ *Result) error {
+	_, err := fmt.Fprintf(c.cfg.Output, "%d rows, %d packets", c.rows, c.cfg.Totals.SumPackets())
+	return err
+}
+
+func TestBuiltinFormatters(t *testing.T) {
+	require.Equal(t, []string{FormatCSV, FormatJSON, FormatTXT}, Formats())
+
+	require.True(t, IsRawFormat(FormatJSON))
+	require.False(t, IsRawFormat(FormatTXT))
+	require.False(t, IsRawFormat(FormatCSV))
+	require.False(t, IsRawFormat("unknown"))
+
+	_, err := NewTablePrinter(&bytes.Buffer{}, "unknown", SortPackets, types.LabelSelector{}, types.DirectionBoth,
+		nil, nil, types.Counters{}, 0, 0, "", "eth0")
+	require.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestRegisterFormatter(t *testing.T) {
+	format := "count"
+	require.Nil(t, RegisterFormatter(format, FormatterFunc(func(cfg PrinterConfig) (TablePrinter, error) {
+		return &countPrinter{cfg: cfg}, nil
+	})))
+	defer func() {
+		formatterRegistry.Lock()
+		delete(formatterRegistry.formatters, format)
+		formatterRegistry.Unlock()
+	}()
+
+	require.ErrorIs(t, RegisterFormatter(format, FormatterFunc(nil)), errorFormatExists)
+	require.ErrorIs(t, RegisterFormatter(FormatTXT, jsonFormatter{}), errorFormatExists)
+	require.ErrorIs(t, RegisterFormatter("", jsonFormatter{}), errorEmptyFormatName)
+	require.ErrorIs(t, RegisterFormatter("other", nil), errorNilFormatter)
+	require.Contains(t, Formats(), format)
+
+	buf := &bytes.Buffer{}
+	printer, err := NewTablePrinter(buf, format, SortPackets, types.LabelSelector{}, types.DirectionBoth,
+		nil, nil, types.Counters{PacketsRcvd: 3, PacketsSent: 4}, 2, 0, "", "eth0")
+	require.Nil(t, err)
+
+	require.Nil(t, printer.AddRows(context.Background(), Rows{{}, {}}))
+	require.Nil(t, printer.Footer(&Result{}))
+	require.Nil(t, printer.Print(&Result{}))
+	require.Equal(t, "2 rows, 7 packets", buf.String())
+}
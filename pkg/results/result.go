@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/goProbe/pkg/types/hll"
 	jsoniter "github.com/json-iterator/go"
 )
 
@@ -19,6 +20,11 @@ var (
 	ErrorNoResults = errors.New("query returned no results")
 )
 
+// SchemaVersion is the version of the JSON representation of a Result, which is part of every
+// serialized result (as schema_version). It is incremented upon changes breaking consumers of the
+// JSON output, e.g. if fields are renamed or removed
+const SchemaVersion = 1
+
 // Result bundles the data rows returned and the query meta information
 type Result struct {
 	Status        Status        `json:"status"`         // Status: the overall status of the result
@@ -29,10 +35,30 @@ type Result struct {
 	Rows    Rows    `json:"rows"`    // Rows: the data rows returned
 }
 
+// MarshalJSON implements the json.Marshaler interface. It adds the schema version to the
+// serialized result
+func (r Result) MarshalJSON() ([]byte, error) {
+	type result Result
+	return jsoniter.Marshal(struct {
+		SchemaVersion int `json:"schema_version"`
+		result
+	}{SchemaVersion, result(r)})
+}
+
 // Query stores the kind of query that was run
 type Query struct {
 	Attributes []string `json:"attributes"`          // Attributes: the attributes that were queried. Example: [sip dip dport proto]
 	Condition  string   `json:"condition,omitempty"` // Condition: the condition that was provided. Example: port=80 && proto=TCP
+	Distinct   string   `json:"distinct,omitempty"`  // Distinct: the attribute whose distinct values are counted per row. Example: dip
+
+	Plan *QueryPlan `json:"plan,omitempty"` // Plan: how the query is executed (only provided for explained queries)
+}
+
+// QueryPlan describes how a query is executed against the DB
+type QueryPlan struct {
+	Columns     []string       `json:"columns"`     // Columns: the columns read from the DB. Example: [sip bytes_rcvd bytes_sent pkts_rcvd pkts_sent]
+	Directories map[string]int `json:"directories"` // Directories: the number of daily directories read per interface. Example: {"eth0": 7}
+	Workers     int            `json:"workers"`     // Workers: the number of workers reading the blocks of each interface concurrently. Example: 4
 }
 
 // TimeRange describes the interval for which data is queried and presented
@@ -51,19 +77,137 @@ type Summary struct {
 	Totals  types.Counters `json:"totals"`  // Totals: the total traffic volume and packets observed over the queried range
 	Timings Timings        `json:"timings"` // Timings: query runtime fields
 	Hits    Hits           `json:"hits"`    // Hits: how many flow records were returned in total and how many are returned in Rows
+
+	Gaps []CoverageGap `json:"gaps,omitempty"` // Gaps: intervals of the queried range for which no data is available (as opposed to no traffic)
+
+	Corruptions []Corruption `json:"corruptions,omitempty"` // Corruptions: the blocks whose columns are inconsistent (if verified). They aren't part of the results
+
+	Anomalies []Anomaly `json:"anomalies,omitempty"` // Anomalies: the traffic of each interface compared to its baseline (if requested)
+
+	Approximation *Approximation `json:"approximation,omitempty"` // Approximation: error bounds of an approximate aggregation (if performed)
+
+	Deduplication *Deduplication `json:"deduplication,omitempty"` // Deduplication: the flows collapsed because they were seen on several interfaces (if requested)
+
+	CaptureQuality *CaptureQuality `json:"capture_quality,omitempty"` // CaptureQuality: the capture quality indicators of the data the results are based on (if known)
+
+	Resources *Resources `json:"resources,omitempty"` // Resources: the resources consumed by the query
+}
+
+// Resources summarizes the resources consumed by a query, allowing to understand its cost (e.g. when
+// hitting the maximum allowed memory usage) and to tune its conditions accordingly
+type Resources struct {
+	PeakMemory        uint64 `json:"peak_memory_bytes"`  // PeakMemory: the peak memory used while running the query (the quantity checked against the maximum allowed memory usage) in bytes. Example: 104857600
+	BlocksScanned     uint64 `json:"blocks_scanned"`     // BlocksScanned: the number of blocks read from disk and evaluated. Example: 288
+	BlocksSkipped     uint64 `json:"blocks_skipped"`     // BlocksSkipped: the number of blocks skipped because none of their flows can satisfy the condition. Example: 12
+	BytesDecompressed uint64 `json:"bytes_decompressed"` // BytesDecompressed: the size of all decompressed column blocks in bytes. Example: 52428800
+}
+
+// Merge combines the resources consumed by two queries (e.g. run on different hosts)
+func (r Resources) Merge(r2 Resources) Resources {
+	return Resources{
+		PeakMemory:        max(r.PeakMemory, r2.PeakMemory),
+		BlocksScanned:     r.BlocksScanned + r2.BlocksScanned,
+		BlocksSkipped:     r.BlocksSkipped + r2.BlocksSkipped,
+		BytesDecompressed: r.BytesDecompressed + r2.BytesDecompressed,
+	}
+}
+
+// Approximation denotes the error bounds of an approximate (top-K) aggregation. Only the flows with
+// the highest values of the sort metric are tracked, hence Hits.Total denotes the number of tracked
+// flows only. The counters of each row underestimate the actual ones by at most MaxError (in units of
+// the sort metric), which is also an upper bound for the sort metric of any flow not returned
+type Approximation struct {
+	Capacity int    `json:"capacity"`  // Capacity: number of flows tracked per host. Example: 10000
+	MaxError uint64 `json:"max_error"` // MaxError: maximum underestimation of the sort metric of any row (bytes or packets). Example: 4096
+}
+
+// CaptureQuality summarizes the capture quality indicators of the blocks the results are based on, allowing
+// to judge their completeness. Blocks excluded because of their drop rate aren't included
+type CaptureQuality struct {
+	Packets        uint64 `json:"packets"`                   // Packets: the number of packets received by the capture (including dropped ones). Example: 1000000
+	Dropped        uint64 `json:"dropped"`                   // Dropped: the number of packets dropped by the capture. Example: 1200
+	Overruns       uint64 `json:"overruns"`                  // Overruns: the number of ring buffer overruns (queue freezes). Example: 3
+	Truncated      uint64 `json:"truncated"`                 // Truncated: the number of packets too short to be parsed. Example: 12
+	BlocksExcluded uint64 `json:"blocks_excluded,omitempty"` // BlocksExcluded: the number of blocks excluded because their drop rate exceeded the maximum permitted one. Example: 2
+}
+
+// DropRate returns the fraction of packets dropped by the capture (zero if unknown)
+func (c CaptureQuality) DropRate() float64 {
+	if c.Packets == 0 {
+		return 0
+	}
+	return float64(c.Dropped) / float64(max(c.Packets, c.Dropped))
+}
+
+// Merge combines the capture quality indicators of two queries (e.g. run on different hosts)
+func (c CaptureQuality) Merge(c2 CaptureQuality) CaptureQuality {
+	return CaptureQuality{
+		Packets:        c.Packets + c2.Packets,
+		Dropped:        c.Dropped + c2.Dropped,
+		Overruns:       c.Overruns + c2.Overruns,
+		Truncated:      c.Truncated + c2.Truncated,
+		BlocksExcluded: c.BlocksExcluded + c2.BlocksExcluded,
+	}
+}
+
+// Deduplication denotes the flows which were seen identically (same key, matching counters) on several
+// interfaces, e.g. due to mirrored traffic, and hence were only counted once. The totals and rows exclude them
+type Deduplication struct {
+	Flows    int            `json:"flows"`    // Flows: the number of duplicate flows removed. Example: 1024
+	Counters types.Counters `json:"counters"` // Counters: the traffic of the duplicate flows removed
+}
+
+// CoverageGap denotes an interval of the queried range for which an interface didn't write any
+// data to the DB (e.g. due to capture downtime) or during which its link was down
+type CoverageGap struct {
+	Iface    string `json:"iface"`               // Iface: the interface lacking data
+	Hostname string `json:"host,omitempty"`      // Hostname: the host on which the interface lacks data
+	LinkDown bool   `json:"link_down,omitempty"` // LinkDown: whether the link of the interface was down (as opposed to the capture not running)
+	TimeRange
+}
+
+// Corruption denotes a column of a block whose number of entries deviates from the one of the block (e.g.
+// due to a partial write). Since the flows of the block can't be reconstructed, the block is skipped
+type Corruption struct {
+	Iface     string    `json:"iface"`           // Iface: the interface the block belongs to. Example: eth0
+	Hostname  string    `json:"host,omitempty"`  // Hostname: the host on which the block is stored. Example: probe-01
+	Timestamp time.Time `json:"timestamp"`       // Timestamp: the timestamp of the block. Example: 2024-03-01T12:05:00Z
+	Column    string    `json:"column"`          // Column: the column which is inconsistent. Example: dport
+	Expected  int       `json:"expected"`        // Expected: the number of entries of the block (as stored in its metadata). Example: 1024
+	Found     int       `json:"found"`           // Found: the number of entries found in the column. Example: 1000
+	Error     string    `json:"error,omitempty"` // Error: the error encountered when reading the column (if it couldn't be read at all)
+}
+
+// Anomaly compares the traffic of an interface over (the last day of) the queried range with its baseline,
+// i.e. the average traffic over the same period of the preceding days. It is a heuristic indicator of whether
+// the traffic is unusual, irrespective of the query's condition
+type Anomaly struct {
+	Iface    string `json:"iface"`          // Iface: the interface the traffic was observed on. Example: eth0
+	Hostname string `json:"host,omitempty"` // Hostname: the host the interface belongs to. Example: probe-01
+	TimeRange
+
+	Totals       types.Counters `json:"totals"`        // Totals: the traffic of the interface over the compared period
+	Baseline     types.Counters `json:"baseline"`      // Baseline: the average traffic over the same period of the preceding days
+	BaselineDays int            `json:"baseline_days"` // BaselineDays: the number of preceding days with data the baseline is computed from. Example: 7
+	Ratio        float64        `json:"ratio"`         // Ratio: the traffic volume (in bytes) relative to the baseline. Example: 2.5
+	Unusual      bool           `json:"unusual"`       // Unusual: whether the ratio exceeds the anomaly threshold (in either direction). Example: true
 }
 
 // Status denotes the overall status of the result
 type Status struct {
 	Code    types.Status `json:"code"`              // Code: the status code
 	Message string       `json:"message,omitempty"` // Message: an optional message
+
+	ClockSkew time.Duration `json:"clock_skew_ns,omitempty"` // ClockSkew: the estimated offset of the host's clock relative to the querying host in nanoseconds (only set if significant)
 }
 
 // Timings summarizes query runtimes
 type Timings struct {
-	QueryStart         time.Time     `json:"query_start"`          // QueryStart: the time when the query started
-	QueryDuration      time.Duration `json:"query_duration_ns"`    // QueryDuration: the time it took to run the query in nanoseconds
-	ResolutionDuration time.Duration `json:"resolution,omitempty"` // ResolutionDuration: the time it took to resolve all IPs in nanoseconds
+	QueryStart         time.Time     `json:"query_start"`           // QueryStart: the time when the query started
+	QueryDuration      time.Duration `json:"query_duration_ns"`     // QueryDuration: the time it took to run the query in nanoseconds
+	ResolutionDuration time.Duration `json:"resolution,omitempty"`  // ResolutionDuration: the time it took to resolve all IPs in nanoseconds
+	ScanDuration       time.Duration `json:"scan_ns,omitempty"`     // ScanDuration: the time it took to read and aggregate the flows in nanoseconds
+	FinalizeDuration   time.Duration `json:"finalize_ns,omitempty"` // FinalizeDuration: the time it took to build and sort the result rows in nanoseconds
 }
 
 // Hits stores how many flow records were returned in total and how many are
@@ -83,6 +227,26 @@ type Row struct {
 
 	// Counters for bytes/packets
 	Counters types.Counters `json:"counters"`
+
+	// Distinct estimates the number of distinct values of the distinct attribute of the
+	// query (if provided)
+	Distinct *hll.Sketch `json:"distinct,omitempty"`
+
+	// Distribution holds the bytes of the row per interval of the queried time range (if
+	// requested)
+	Distribution *Distribution `json:"distribution,omitempty"`
+
+	// Hostnames holds the reverse DNS lookups of the IP attributes (if resolved)
+	Hostnames *Hostnames `json:"hostnames,omitempty"`
+
+	// Enrichments holds the values attached by enrichers, keyed by column (see Enricher)
+	Enrichments map[string]string `json:"enrichments,omitempty"`
+}
+
+// Hostnames hold the domains resolved for the IP attributes of a row
+type Hostnames struct {
+	SrcHost string `json:"sip,omitempty"` // SrcHost: the hostname of the source IP address
+	DstHost string `json:"dip,omitempty"` // DstHost: the hostname of the destination IP address
 }
 
 // Labels hold labels by which the goDB database is partitioned
@@ -91,6 +255,77 @@ type Labels struct {
 	Iface     string    `json:"iface,omitempty"`     // Iface: the interface on which the flow was observed
 	Hostname  string    `json:"host,omitempty"`      // Hostname: the hostname of the host on which the flow was observed
 	HostID    string    `json:"host_id,omitempty"`   // HostID: the host id of the host on which the flow was observed
+	Process   string    `json:"process,omitempty"`   // Process: the local process / service the flow was attributed to (if any). Example: nginx.service
+	Direction string    `json:"dir,omitempty"`       // Direction: the direction of the flow, derived from its packet counters (in, out or bi). Example: bi
+
+	Columns LabelColumns `json:"columns,omitempty"` // Columns: the values of the attributes stored in label columns (if queried). Example: {"vlan": "42"}
+}
+
+// LabelColumns hold the values of the attributes stored in label columns (see types.AttributeSpec.LabelColumn).
+// The (non-empty) values are encoded in a string, ordered by the names of the columns, in order to keep
+// Labels comparable
+type LabelColumns string
+
+const (
+	labelColumnsSep      = "\x00"
+	labelColumnsValueSep = "="
+)
+
+// NewLabelColumns encodes the values of label columns by their names (omitting empty values)
+func NewLabelColumns(values map[string]string) LabelColumns {
+	names := make([]string, 0, len(values))
+	for name, value := range values {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + labelColumnsValueSep + values[name]
+	}
+	return LabelColumns(strings.Join(pairs, labelColumnsSep))
+}
+
+// Get returns the value of a label column (or an empty string if there is none)
+func (c LabelColumns) Get(name string) string {
+	return c.Map()[name]
+}
+
+// Map returns the values of the label columns by their names (nil if there are none)
+func (c LabelColumns) Map() map[string]string {
+	if c == "" {
+		return nil
+	}
+	pairs := strings.Split(string(c), labelColumnsSep)
+	values := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		// column names never contain the separator (see types.AttributeSpec.LabelColumn)
+		name, value, _ := strings.Cut(pair, labelColumnsValueSep)
+		values[name] = value
+	}
+	return values
+}
+
+// MarshalJSON implements the json.Marshaler interface, representing the label columns as an object
+func (c LabelColumns) MarshalJSON() ([]byte, error) {
+	return jsoniter.Marshal(c.Map())
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface (see MarshalJSON())
+func (c *LabelColumns) UnmarshalJSON(data []byte) error {
+	var values map[string]string
+	if err := jsoniter.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	*c = NewLabelColumns(values)
+	return nil
+}
+
+// String prints the label columns as comma-separated name=value pairs
+func (c LabelColumns) String() string {
+	return strings.ReplaceAll(string(c), labelColumnsSep, ",")
 }
 
 // Attributes are traffic attributes by which the goDB can be aggregated
@@ -190,7 +425,33 @@ func (r *Row) String() string {
 	return fmt.Sprintf("%s; %s; %s", r.Labels.String(), r.Attributes.String(), r.Counters.String())
 }
 
-// Less returns wether the row r sorts before r2
+// SetHostnames attaches the domains of the row's IP attributes found in ips2domains (if any)
+func (r *Row) SetHostnames(ips2domains map[string]string) {
+	var hostnames Hostnames
+	if r.Attributes.SrcIP.IsValid() {
+		hostnames.SrcHost = ips2domains[r.Attributes.SrcIP.String()]
+	}
+	if r.Attributes.DstIP.IsValid() {
+		hostnames.DstHost = ips2domains[r.Attributes.DstIP.String()]
+	}
+	if hostnames != (Hostnames{}) {
+		r.Hostnames = &hostnames
+	}
+}
+
+// Enrich attaches a value to the row. Empty values are skipped
+func (r *Row) Enrich(column, value string) {
+	if value == "" {
+		return
+	}
+	if r.Enrichments == nil {
+		r.Enrichments = make(map[string]string)
+	}
+	r.Enrichments[column] = value
+}
+
+// Less returns wether the row r sorts before r2. It constitutes a total order on the rows of a
+// result (whose attributes and labels are unique after aggregation)
 func (r *Row) Less(r2 *Row) bool {
 	if r.Attributes == r2.Attributes {
 		return r.Labels.Less(r2.Labels)
@@ -217,15 +478,21 @@ func (l Labels) MarshalJSON() ([]byte, error) {
 	var aux = struct {
 		// TODO: this is expensive. Check how to get rid of re-assigning
 		// values in order to properly treat empties
-		Timestamp *time.Time `json:"timestamp,omitempty"`
-		Iface     string     `json:"iface,omitempty"`
-		Hostname  string     `json:"host,omitempty"`
-		HostID    string     `json:"host_id,omitempty"`
+		Timestamp *time.Time        `json:"timestamp,omitempty"`
+		Iface     string            `json:"iface,omitempty"`
+		Hostname  string            `json:"host,omitempty"`
+		HostID    string            `json:"host_id,omitempty"`
+		Process   string            `json:"process,omitempty"`
+		Direction string            `json:"dir,omitempty"`
+		Columns   map[string]string `json:"columns,omitempty"`
 	}{
 		nil,
 		l.Iface,
 		l.Hostname,
 		l.HostID,
+		l.Process,
+		l.Direction,
+		l.Columns.Map(),
 	}
 	if !l.Timestamp.IsZero() {
 		aux.Timestamp = &l.Timestamp
@@ -235,11 +502,14 @@ func (l Labels) MarshalJSON() ([]byte, error) {
 
 // String prints all result labels
 func (l Labels) String() string {
-	return fmt.Sprintf("ts=%s iface=%s hostname=%s hostID=%s",
+	return fmt.Sprintf("ts=%s iface=%s hostname=%s hostID=%s process=%s dir=%s columns=%s",
 		l.Timestamp,
 		l.Iface,
 		l.Hostname,
 		l.HostID,
+		l.Process,
+		l.Direction,
+		l.Columns,
 	)
 }
 
@@ -249,13 +519,29 @@ func (l Labels) Less(l2 Labels) bool {
 		return l.Timestamp.Before(l2.Timestamp)
 	}
 
-	// Since sorting is about human-readable information this ignores the hostID, assuming
-	// that for sorting identical hostnames imply the same host
+	// Since sorting is about human-readable information the hostname takes precedence over the hostID
 	if l.Hostname != l2.Hostname {
 		return l.Hostname < l2.Hostname
 	}
 
-	return l.Iface < l2.Iface
+	if l.Iface != l2.Iface {
+		return l.Iface < l2.Iface
+	}
+
+	if l.Process != l2.Process {
+		return l.Process < l2.Process
+	}
+
+	if l.Direction != l2.Direction {
+		return l.Direction < l2.Direction
+	}
+
+	if l.Columns != l2.Columns {
+		return l.Columns < l2.Columns
+	}
+
+	// distinct hosts sharing a hostname are ordered by their ID in order to keep the order deterministic
+	return l.HostID < l2.HostID
 }
 
 // ExtendedAttributes includes the source port. It is meant to be used if (and only if)
@@ -265,6 +551,29 @@ type ExtendedAttributes struct {
 	Attributes
 }
 
+// MarshalJSON marshals an extended attribute set into a JSON byte slice. It is required
+// since the method of the embedded Attributes would omit the source port otherwise
+func (a ExtendedAttributes) MarshalJSON() ([]byte, error) {
+	var aux = struct {
+		SrcIP   *netip.Addr `json:"sip,omitempty"`
+		SrcPort uint16      `json:"sport,omitempty"`
+		DstIP   *netip.Addr `json:"dip,omitempty"`
+		IPProto uint8       `json:"proto,omitempty"`
+		DstPort uint16      `json:"dport,omitempty"`
+	}{
+		SrcPort: a.SrcPort,
+		IPProto: a.IPProto,
+		DstPort: a.DstPort,
+	}
+	if a.SrcIP.IsValid() {
+		aux.SrcIP = &a.SrcIP
+	}
+	if a.DstIP.IsValid() {
+		aux.DstIP = &a.DstIP
+	}
+	return jsoniter.Marshal(aux)
+}
+
 // MarshalJSON marshals an attribute set into a JSON byte slice
 func (a Attributes) MarshalJSON() ([]byte, error) {
 	var aux = struct {
@@ -320,34 +629,55 @@ type MergeableAttributes struct {
 	Attributes
 }
 
+// MergeableValues bundles all fields of a Result which are merged during aggregation
+type MergeableValues struct {
+	Counters     types.Counters
+	Distinct     *hll.Sketch
+	Distribution *Distribution
+}
+
+// merge adds the values of ov to v
+func (v MergeableValues) merge(ov MergeableValues) MergeableValues {
+	v.Counters = v.Counters.Add(ov.Counters)
+	if ov.Distinct != nil {
+		if v.Distinct == nil {
+			v.Distinct = hll.New()
+		}
+		v.Distinct.Merge(ov.Distinct)
+	}
+	if ov.Distribution != nil {
+		if v.Distribution == nil {
+			v.Distribution = new(Distribution)
+		}
+		v.Distribution.Merge(ov.Distribution)
+	}
+	return v
+}
+
 // RowsMap is an aggregated representation of a Rows list
-type RowsMap map[MergeableAttributes]types.Counters
+type RowsMap map[MergeableAttributes]MergeableValues
 
 // MergeRows aggregates Rows by use of the RowsMap rm, which is modified
 // in the process
 func (rm RowsMap) MergeRows(r Rows) (merged int) {
 	for _, res := range r {
-		counters, exists := rm[MergeableAttributes{res.Labels, res.Attributes}]
+		values, exists := rm[MergeableAttributes{res.Labels, res.Attributes}]
 		if exists {
-			rm[MergeableAttributes{res.Labels, res.Attributes}] = counters.Add(res.Counters)
 			merged++
-		} else {
-			rm[MergeableAttributes{res.Labels, res.Attributes}] = res.Counters
 		}
+		rm[MergeableAttributes{res.Labels, res.Attributes}] = values.merge(MergeableValues{res.Counters, res.Distinct, res.Distribution})
 	}
 	return
 }
 
 // MergeRowsMap aggregates all results of om and stores them in rm
 func (rm RowsMap) MergeRowsMap(om RowsMap) (merged int) {
-	for oma, oc := range om {
-		counters, exists := rm[oma]
+	for oma, ov := range om {
+		values, exists := rm[oma]
 		if exists {
-			rm[oma] = counters.Add(oc)
 			merged++
-		} else {
-			rm[oma] = oc
 		}
+		rm[oma] = values.merge(ov)
 	}
 	return
 }
@@ -369,11 +699,13 @@ func (rm RowsMap) ToRows() Rows {
 		return r
 	}
 	i := 0
-	for ma, c := range rm {
+	for ma, v := range rm {
 		r[i] = Row{
-			Labels:     ma.Labels,
-			Attributes: ma.Attributes,
-			Counters:   c,
+			Labels:       ma.Labels,
+			Attributes:   ma.Attributes,
+			Counters:     v.Counters,
+			Distinct:     v.Distinct,
+			Distribution: v.Distribution,
 		}
 		i++
 	}
@@ -2,7 +2,9 @@ package results
 
 import (
 	"fmt"
+	"net/netip"
 	"testing"
+	"time"
 
 	"github.com/els0r/goProbe/pkg/types"
 	jsoniter "github.com/json-iterator/go"
@@ -32,3 +34,113 @@ func TestMerge(t *testing.T) {
 		})
 	}
 }
+
+func TestResourcesMerge(t *testing.T) {
+	r := Resources{PeakMemory: 100, BlocksScanned: 10, BlocksSkipped: 1, BytesDecompressed: 1000}
+	merged := r.Merge(Resources{PeakMemory: 50, BlocksScanned: 5, BlocksSkipped: 2, BytesDecompressed: 500})
+
+	// the peak memory of different hosts doesn't add up
+	assert.Equal(t, Resources{PeakMemory: 100, BlocksScanned: 15, BlocksSkipped: 3, BytesDecompressed: 1500}, merged)
+}
+
+func TestExtendedAttributesMarshal(t *testing.T) {
+	attr := ExtendedAttributes{
+		SrcPort: 55555,
+		Attributes: Attributes{
+			SrcIP:   netip.MustParseAddr("1.2.3.4"),
+			DstIP:   netip.MustParseAddr("4.5.6.7"),
+			IPProto: 17,
+			DstPort: 53,
+		},
+	}
+
+	b, err := jsoniter.Marshal(attr)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"sip":"1.2.3.4","sport":55555,"dip":"4.5.6.7","proto":17,"dport":53}`, string(b))
+
+	var unmarshalled ExtendedAttributes
+	assert.Nil(t, jsoniter.Unmarshal(b, &unmarshalled))
+	assert.Equal(t, attr, unmarshalled)
+}
+
+func TestLabelColumns(t *testing.T) {
+	columns := NewLabelColumns(map[string]string{"zone": "dmz", "vlan": "42", "tenant": ""})
+	assert.Equal(t, "vlan=42,zone=dmz", columns.String())
+	assert.Equal(t, "42", columns.Get("vlan"))
+	assert.Empty(t, columns.Get("tenant"))
+	assert.Equal(t, columns, NewLabelColumns(map[string]string{"vlan": "42", "zone": "dmz"}))
+
+	labels := Labels{Iface: "eth0", Columns: columns}
+	b, err := jsoniter.Marshal(labels)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"iface":"eth0","columns":{"vlan":"42","zone":"dmz"}}`, string(b))
+
+	var unmarshalled Labels
+	assert.Nil(t, jsoniter.Unmarshal(b, &unmarshalled))
+	assert.Equal(t, labels, unmarshalled)
+
+	b, err = jsoniter.Marshal(Labels{Iface: "eth0"})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"iface":"eth0"}`, string(b))
+}
+
+func TestResultJSONSchema(t *testing.T) {
+	res := New()
+	res.Summary.Totals = types.Counters{BytesRcvd: 100, PacketsRcvd: 2}
+	res.Rows = Rows{{
+		Attributes: Attributes{SrcIP: netip.MustParseAddr("10.0.0.1")},
+		Counters:   types.Counters{BytesRcvd: 100, PacketsRcvd: 2},
+	}}
+
+	b, err := jsoniter.Marshal(res)
+	assert.Nil(t, err)
+	assert.Equal(t, SchemaVersion, jsoniter.Get(b, "schema_version").ToInt())
+
+	// the counters of both directions are included even if they are zero, along with their sums
+	for _, counters := range []jsoniter.Any{jsoniter.Get(b, "summary", "totals"), jsoniter.Get(b, "rows", 0, "counters")} {
+		assert.JSONEq(t, `{"br":100,"bs":0,"pr":2,"ps":0,"bytes":100,"packets":2}`, counters.ToString())
+	}
+
+	var unmarshalled Result
+	assert.Nil(t, jsoniter.Unmarshal(b, &unmarshalled))
+	assert.Equal(t, res.Summary.Totals, unmarshalled.Summary.Totals)
+	assert.Equal(t, res.Rows[0].Counters, unmarshalled.Rows[0].Counters)
+}
+
+func TestSortDeterministic(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	rows := Rows{
+		{Labels: Labels{Timestamp: ts, Hostname: "host", HostID: "b"}, Attributes: Attributes{SrcIP: netip.MustParseAddr("10.0.0.1"), DstPort: 80}, Counters: types.Counters{PacketsRcvd: 1}},
+		{Labels: Labels{Timestamp: ts, Hostname: "host", HostID: "a"}, Attributes: Attributes{SrcIP: netip.MustParseAddr("10.0.0.1"), DstPort: 80}, Counters: types.Counters{PacketsRcvd: 1}},
+		{Labels: Labels{Timestamp: ts, Hostname: "host", HostID: "a"}, Attributes: Attributes{SrcIP: netip.MustParseAddr("10.0.0.2"), DstPort: 443}, Counters: types.Counters{PacketsRcvd: 5}},
+		{Labels: Labels{Timestamp: ts, Hostname: "host", HostID: "a"}, Attributes: Attributes{SrcIP: netip.MustParseAddr("10.0.0.3"), DstPort: 53}, Counters: types.Counters{PacketsRcvd: 1}},
+		{Labels: Labels{Timestamp: ts, Hostname: "other", HostID: "c"}, Attributes: Attributes{SrcIP: netip.MustParseAddr("10.0.0.1"), DstPort: 80}, Counters: types.Counters{PacketsRcvd: 1}},
+	}
+
+	for _, ascending := range []bool{false, true} {
+		var expected Rows
+		for i := 0; i < 100; i++ {
+
+			// aggregating the rows in a map randomizes their order
+			rowsMap := make(RowsMap)
+			rowsMap.MergeRows(rows)
+			sorted := rowsMap.ToRowsSorted(By(SortPackets, types.DirectionIn, ascending))
+			if expected == nil {
+				expected = sorted
+				continue
+			}
+			assert.Equal(t, expected, sorted)
+		}
+
+		// rows with equal sort keys are ordered by their attributes / labels (ascending)
+		ties := expected[1:]
+		if ascending {
+			ties = expected[:len(expected)-1]
+		}
+		var order []string
+		for _, row := range ties {
+			order = append(order, row.Attributes.SrcIP.String()+"/"+row.Labels.HostID)
+		}
+		assert.Equal(t, []string{"10.0.0.1/a", "10.0.0.1/b", "10.0.0.1/c", "10.0.0.3/a"}, order)
+	}
+}
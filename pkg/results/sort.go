@@ -91,7 +91,10 @@ func (s *entrySorter) Less(i, j int) bool {
 	return s.less(&s.entries[i], &s.entries[j])
 }
 
-// By is part of the sort.Interface
+// By returns the ordering of rows for the given sort order and direction. Rows with equal sort keys
+// are ordered by their attributes and labels (ascending regardless of the sort direction, see
+// Row.Less()), hence the order is deterministic across runs and independent of the order in which
+// the rows were aggregated (e.g. in the distributed merge)
 func By(sort SortOrder, direction types.Direction, ascending bool) by {
 	switch sort {
 	case SortPackets:
@@ -107,7 +110,7 @@ func By(sort SortOrder, direction types.Direction, ascending bool) by {
 			}
 			return func(e1, e2 *Row) bool {
 				if e1.Counters.PacketsSent+e1.Counters.PacketsRcvd == e2.Counters.PacketsSent+e2.Counters.PacketsRcvd {
-					return e2.Less(e1)
+					return e1.Less(e2)
 				}
 				return e1.Counters.PacketsSent+e1.Counters.PacketsRcvd > e2.Counters.PacketsSent+e2.Counters.PacketsRcvd
 			}
@@ -122,7 +125,7 @@ func By(sort SortOrder, direction types.Direction, ascending bool) by {
 			}
 			return func(e1, e2 *Row) bool {
 				if e1.Counters.PacketsRcvd == e2.Counters.PacketsRcvd {
-					return e2.Less(e1)
+					return e1.Less(e2)
 				}
 				return e1.Counters.PacketsRcvd > e2.Counters.PacketsRcvd
 			}
@@ -137,7 +140,7 @@ func By(sort SortOrder, direction types.Direction, ascending bool) by {
 			}
 			return func(e1, e2 *Row) bool {
 				if e1.Counters.PacketsSent == e2.Counters.PacketsSent {
-					return e2.Less(e1)
+					return e1.Less(e2)
 				}
 				return e1.Counters.PacketsSent > e2.Counters.PacketsSent
 			}
@@ -155,7 +158,7 @@ func By(sort SortOrder, direction types.Direction, ascending bool) by {
 			}
 			return func(e1, e2 *Row) bool {
 				if e1.Counters.BytesSent+e1.Counters.BytesRcvd == e2.Counters.BytesSent+e2.Counters.BytesRcvd {
-					return e2.Less(e1)
+					return e1.Less(e2)
 				}
 				return e1.Counters.BytesSent+e1.Counters.BytesRcvd > e2.Counters.BytesSent+e2.Counters.BytesRcvd
 			}
@@ -170,7 +173,7 @@ func By(sort SortOrder, direction types.Direction, ascending bool) by {
 			}
 			return func(e1, e2 *Row) bool {
 				if e1.Counters.BytesRcvd == e2.Counters.BytesRcvd {
-					return e2.Less(e1)
+					return e1.Less(e2)
 				}
 				return e1.Counters.BytesRcvd > e2.Counters.BytesRcvd
 			}
@@ -185,7 +188,7 @@ func By(sort SortOrder, direction types.Direction, ascending bool) by {
 			}
 			return func(e1, e2 *Row) bool {
 				if e1.Counters.BytesSent == e2.Counters.BytesSent {
-					return e2.Less(e1)
+					return e1.Less(e2)
 				}
 				return e1.Counters.BytesSent > e2.Counters.BytesSent
 			}
@@ -201,7 +204,7 @@ func By(sort SortOrder, direction types.Direction, ascending bool) by {
 		}
 		return func(e1, e2 *Row) bool {
 			if e1.Labels.Timestamp.Equal(e2.Labels.Timestamp) {
-				return e2.Less(e1)
+				return e1.Less(e2)
 			}
 			return e1.Labels.Timestamp.After(e2.Labels.Timestamp)
 		}
@@ -0,0 +1,147 @@
+// Package systemd provides a minimal, dependency-free integration with systemd's service
+// notification protocol (sd_notify), watchdog and socket activation
+package systemd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// NotifyReady tells the service manager that service startup is finished
+	NotifyReady = "READY=1"
+
+	// NotifyStopping tells the service manager that the service is beginning its shutdown
+	NotifyStopping = "STOPPING=1"
+
+	// NotifyReloading tells the service manager that the service is reloading its configuration
+	NotifyReloading = "RELOADING=1"
+
+	// NotifyWatchdog updates the watchdog timestamp (keep-alive)
+	NotifyWatchdog = "WATCHDOG=1"
+
+	envNotifySocket = "NOTIFY_SOCKET"
+	envWatchdogUsec = "WATCHDOG_USEC"
+	envWatchdogPID  = "WATCHDOG_PID"
+	envListenFDs    = "LISTEN_FDS"
+	envListenPID    = "LISTEN_PID"
+	envListenNames  = "LISTEN_FDNAMES"
+
+	// listenFDsStart denotes the first file descriptor passed by systemd (SD_LISTEN_FDS_START)
+	listenFDsStart = 3
+)
+
+// Notify sends a state update (e.g. NotifyReady) to the service manager. If the process
+// was not started by systemd (or without notification support), false is returned without error
+func Notify(state string) (bool, error) {
+	socketAddr := os.Getenv(envNotifySocket)
+	if socketAddr == "" {
+		return false, nil
+	}
+
+	// support for abstract sockets
+	if socketAddr[0] == '@' {
+		socketAddr = "\x00" + socketAddr[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socketAddr, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to notification socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err = conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("failed to send notification: %w", err)
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns the interval at which the service manager expects keep-alive
+// notifications. If the watchdog is not enabled for this process, 0 is returned
+func WatchdogInterval() (time.Duration, error) {
+	usecStr := os.Getenv(envWatchdogUsec)
+	if usecStr == "" {
+		return 0, nil
+	}
+	usec, err := strconv.ParseInt(usecStr, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", envWatchdogUsec, err)
+	}
+	if usec <= 0 {
+		return 0, fmt.Errorf("invalid %s: %d", envWatchdogUsec, usec)
+	}
+
+	// if a PID is set, the watchdog is only meant for this specific process
+	if pidStr := os.Getenv(envWatchdogPID); pidStr != "" {
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse %s: %w", envWatchdogPID, err)
+		}
+		if pid != os.Getpid() {
+			return 0, nil
+		}
+	}
+
+	return time.Duration(usec) * time.Microsecond, nil
+}
+
+// ErrNoListeners denotes that no sockets were passed by the service manager
+var ErrNoListeners = errors.New("no sockets passed via socket activation")
+
+// Listeners returns the listeners passed to the process via socket activation, in the order
+// they are defined in the socket unit. The environment variables are unset afterwards so they
+// aren't inherited by child processes
+func Listeners() ([]net.Listener, error) {
+	defer func() {
+		_ = os.Unsetenv(envListenPID)
+		_ = os.Unsetenv(envListenFDs)
+		_ = os.Unsetenv(envListenNames)
+	}()
+
+	pidStr, fdsStr := os.Getenv(envListenPID), os.Getenv(envListenFDs)
+	if pidStr == "" || fdsStr == "" {
+		return nil, ErrNoListeners
+	}
+
+	pid, err := strconv.Atoi(pidStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", envListenPID, err)
+	}
+	if pid != os.Getpid() {
+		return nil, ErrNoListeners
+	}
+	nfds, err := strconv.Atoi(fdsStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", envListenFDs, err)
+	}
+	if nfds <= 0 {
+		return nil, ErrNoListeners
+	}
+
+	listeners := make([]net.Listener, 0, nfds)
+	for fd := listenFDsStart; fd < listenFDsStart+nfds; fd++ {
+		syscall.CloseOnExec(fd)
+
+		f := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		listener, err := net.FileListener(f)
+
+		// the listener holds a dup of the file descriptor, so the original can be closed in any case
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return nil, fmt.Errorf("failed to create listener from file descriptor %d: %w", fd, err)
+		}
+		listeners = append(listeners, listener)
+	}
+
+	return listeners, nil
+}
@@ -0,0 +1,143 @@
+package systemd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func listenNotifySocket(t *testing.T) *net.UnixConn {
+	socketPath := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	require.Nil(t, err)
+	t.Cleanup(func() {
+		require.Nil(t, conn.Close())
+	})
+	t.Setenv(envNotifySocket, socketPath)
+
+	return conn
+}
+
+func readNotification(t *testing.T, conn *net.UnixConn) string {
+	buf := make([]byte, 128)
+	require.Nil(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, err := conn.Read(buf)
+	require.Nil(t, err)
+	return string(buf[:n])
+}
+
+func TestNotify(t *testing.T) {
+	t.Run("no socket", func(t *testing.T) {
+		t.Setenv(envNotifySocket, "")
+		sent, err := Notify(NotifyReady)
+		require.Nil(t, err)
+		require.False(t, sent)
+	})
+
+	t.Run("ready", func(t *testing.T) {
+		conn := listenNotifySocket(t)
+
+		sent, err := Notify(NotifyReady)
+		require.Nil(t, err)
+		require.True(t, sent)
+		require.Equal(t, NotifyReady, readNotification(t, conn))
+	})
+
+	t.Run("socket gone", func(t *testing.T) {
+		t.Setenv(envNotifySocket, filepath.Join(t.TempDir(), "nonexistent.sock"))
+		sent, err := Notify(NotifyReady)
+		require.NotNil(t, err)
+		require.False(t, sent)
+	})
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	var tests = []struct {
+		name     string
+		usec     string
+		pid      string
+		expected time.Duration
+		hasErr   bool
+	}{
+		{"disabled", "", "", 0, false},
+		{"enabled", "30000000", "", 30 * time.Second, false},
+		{"enabled for this process", "30000000", strconv.Itoa(os.Getpid()), 30 * time.Second, false},
+		{"enabled for other process", "30000000", strconv.Itoa(os.Getpid() + 1), 0, false},
+		{"invalid usec", "abc", "", 0, true},
+		{"negative usec", "-1", "", 0, true},
+		{"invalid pid", "30000000", "abc", 0, true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(envWatchdogUsec, test.usec)
+			t.Setenv(envWatchdogPID, test.pid)
+
+			interval, err := WatchdogInterval()
+			if test.hasErr {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, test.expected, interval)
+		})
+	}
+}
+
+func TestListeners(t *testing.T) {
+	t.Run("not activated", func(t *testing.T) {
+		t.Setenv(envListenPID, "")
+		t.Setenv(envListenFDs, "")
+		_, err := Listeners()
+		require.ErrorIs(t, err, ErrNoListeners)
+	})
+
+	t.Run("other process", func(t *testing.T) {
+		t.Setenv(envListenPID, strconv.Itoa(os.Getpid()+1))
+		t.Setenv(envListenFDs, "1")
+		_, err := Listeners()
+		require.ErrorIs(t, err, ErrNoListeners)
+
+		// the environment must be cleaned up
+		require.Empty(t, os.Getenv(envListenFDs))
+	})
+
+	t.Run("invalid number of fds", func(t *testing.T) {
+		t.Setenv(envListenPID, strconv.Itoa(os.Getpid()))
+		t.Setenv(envListenFDs, "abc")
+		_, err := Listeners()
+		require.NotNil(t, err)
+	})
+}
+
+func TestRunWatchdog(t *testing.T) {
+	conn := listenNotifySocket(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var healthy = make(chan bool, 1)
+	healthy <- false
+
+	go RunWatchdog(ctx, 20*time.Millisecond, func(_ context.Context) error {
+		select {
+		case ok := <-healthy:
+			if !ok {
+				return errors.New("unhealthy")
+			}
+		default:
+		}
+		return nil
+	})
+
+	// the first check fails, all subsequent ones succeed
+	require.Equal(t, NotifyWatchdog, readNotification(t, conn))
+}
@@ -0,0 +1,60 @@
+package systemd
+
+import (
+	"context"
+	"time"
+
+	"github.com/els0r/telemetry/logging"
+)
+
+// HealthCheckFn denotes a function determining whether the service is healthy. It must return
+// before the context expires for the check to be considered successful
+type HealthCheckFn func(ctx context.Context) error
+
+// RunWatchdog sends keep-alive notifications to the service manager at half the provided
+// interval for as long as healthFn succeeds. If a health check fails (or doesn't complete in
+// time), the keep-alive is skipped so the service manager can detect the hung service and
+// restart it. The function blocks until ctx is cancelled
+func RunWatchdog(ctx context.Context, interval time.Duration, healthFn HealthCheckFn) {
+	logger := logging.FromContext(ctx)
+
+	period := interval / 2
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if healthFn != nil {
+				if err := runHealthCheck(ctx, period, healthFn); err != nil {
+					logger.Errorf("health check failed, skipping watchdog keep-alive: %v", err)
+					continue
+				}
+			}
+			if _, err := Notify(NotifyWatchdog); err != nil {
+				logger.Errorf("failed to send watchdog keep-alive: %v", err)
+			}
+		}
+	}
+}
+
+func runHealthCheck(ctx context.Context, timeout time.Duration, healthFn HealthCheckFn) error {
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// run the check in the background so a health check blocking on e.g. a lock doesn't block
+	// the watchdog loop itself
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- healthFn(checkCtx)
+	}()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-checkCtx.Done():
+		return checkCtx.Err()
+	}
+}
@@ -0,0 +1,146 @@
+// Package tracing provides OpenTelemetry tracing for the query pipeline. Spans are only exported
+// if a collector endpoint is configured (either explicitly or via the standard OTEL_EXPORTER_OTLP_*
+// environment variables). Otherwise, all spans are created by a no-op tracer and don't incur any
+// overhead worth mentioning
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/els0r/goProbe/pkg/version"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/els0r/goProbe"
+
+// environment variables from which the OTLP exporter picks up its endpoint
+var endpointEnvVars = []string{
+	"OTEL_EXPORTER_OTLP_ENDPOINT",
+	"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT",
+}
+
+var errorEmptyServiceName = errors.New("no service name provided")
+
+// ShutdownFunc flushes all pending spans and stops the exporter
+type ShutdownFunc func(context.Context) error
+
+type config struct {
+	endpoint    string
+	insecure    bool
+	sampleRatio float64
+}
+
+// Option configures tracing
+type Option func(*config)
+
+// WithEndpoint sets the OTLP/HTTP collector endpoint (host:port) spans are exported to
+func WithEndpoint(endpoint string) Option {
+	return func(c *config) {
+		c.endpoint = endpoint
+	}
+}
+
+// WithInsecure disables TLS when connecting to the collector
+func WithInsecure(insecure bool) Option {
+	return func(c *config) {
+		c.insecure = insecure
+	}
+}
+
+// WithSampleRatio sets the fraction of traces which are sampled (unless the parent
+// span decided otherwise). The default is to sample all traces
+func WithSampleRatio(ratio float64) Option {
+	return func(c *config) {
+		c.sampleRatio = ratio
+	}
+}
+
+// Init sets up the global tracer provider and trace context propagation. If no collector endpoint
+// is configured, the (no-op) default tracer provider is kept. The returned function must be called
+// on exit in order to flush any pending spans
+func Init(ctx context.Context, serviceName string, opts ...Option) (ShutdownFunc, error) {
+	if serviceName == "" {
+		return nil, errorEmptyServiceName
+	}
+
+	cfg := &config{
+		sampleRatio: 1.,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	// propagation is set up in any case so that trace contexts are handed through to
+	// downstream services (e.g. from global-query to goProbe)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if cfg.endpoint == "" && !endpointFromEnv() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var exporterOpts []otlptracehttp.Option
+	if cfg.endpoint != "" {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithEndpoint(cfg.endpoint))
+	}
+	if cfg.insecure {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(version.Short()),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.sampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown, nil
+}
+
+func endpointFromEnv() bool {
+	for _, env := range endpointEnvVars {
+		if os.Getenv(env) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Start creates a new span (and a context containing it) using the global tracer provider
+func Start(ctx context.Context, spanName string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, spanName, opts...)
+}
+
+// Error records err on the span and marks the span as failed. It is a no-op if err is nil
+func Error(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
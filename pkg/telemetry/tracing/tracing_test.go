@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInitUnconfigured(t *testing.T) {
+	for _, env := range endpointEnvVars {
+		t.Setenv(env, "")
+	}
+
+	_, err := Init(context.Background(), "")
+	require.ErrorIs(t, err, errorEmptyServiceName)
+
+	shutdown, err := Init(context.Background(), "test")
+	require.Nil(t, err)
+	require.Nil(t, shutdown(context.Background()))
+
+	// without an endpoint, spans are not recorded
+	_, span := Start(context.Background(), "span")
+	defer span.End()
+	require.False(t, span.IsRecording())
+}
+
+func TestSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	prevTP := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(prevTP)
+
+	ctx, parent := Start(context.Background(), "parent")
+	_, child := Start(ctx, "child")
+	Error(child, errors.New("failed"))
+	child.End()
+
+	Error(parent, nil)
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	require.Equal(t, "child", spans[0].Name())
+	require.Equal(t, codes.Error, spans[0].Status().Code)
+	require.Equal(t, "failed", spans[0].Status().Description)
+	require.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+
+	require.Equal(t, "parent", spans[1].Name())
+	require.Equal(t, codes.Unset, spans[1].Status().Code)
+}
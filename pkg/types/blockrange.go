@@ -0,0 +1,122 @@
+package types
+
+import "bytes"
+
+// IPRange denotes the smallest and largest IP address (of one IP version) in a block
+type IPRange struct {
+	Min, Max [IPv6Width]byte // IPv4 addresses occupy the first IPv4Width bytes only
+}
+
+// BlockRange summarizes the attribute values of a block of flows (minimum / maximum per attribute
+// and IP version). It allows to determine whether a condition can match any flow of a block without
+// having to decompress the block itself
+type BlockRange struct {
+	HasV4, HasV6 bool // HasV4 / HasV6: the block contains IPv4 / IPv6 flows
+
+	SIPv4, DIPv4 IPRange
+	SIPv6, DIPv6 IPRange
+
+	DportMin, DportMax [DPortWidth]byte
+	ProtoMin, ProtoMax byte
+}
+
+// NewBlockRange computes the range of all attribute values from the raw (column) data of a
+// block. The IP columns are expected to contain numV4Entries IPv4 addresses, followed by IPv6
+// addresses (as stored in the DB). If the lengths of the columns are inconsistent, no range is
+// computed and false is returned
+func NewBlockRange(numV4Entries int, sip, dip, dport, proto []byte) (BlockRange, bool) {
+	var r BlockRange
+
+	v4Len := numV4Entries * IPv4Width
+	if numV4Entries < 0 || len(sip) != len(dip) || len(sip) < v4Len || (len(sip)-v4Len)%IPv6Width != 0 {
+		return r, false
+	}
+	numEntries := numV4Entries + (len(sip)-v4Len)/IPv6Width
+	if len(dport) != numEntries*DPortWidth || len(proto) != numEntries*ProtoWidth {
+		return r, false
+	}
+
+	r.HasV4 = numV4Entries > 0
+	r.HasV6 = len(sip) > v4Len
+	if r.HasV4 {
+		r.SIPv4 = newIPRange(sip[:v4Len], IPv4Width)
+		r.DIPv4 = newIPRange(dip[:v4Len], IPv4Width)
+	}
+	if r.HasV6 {
+		r.SIPv6 = newIPRange(sip[v4Len:], IPv6Width)
+		r.DIPv6 = newIPRange(dip[v4Len:], IPv6Width)
+	}
+
+	if len(dport) >= DPortWidth {
+		copy(r.DportMin[:], dport[:DPortWidth])
+		copy(r.DportMax[:], dport[:DPortWidth])
+		for i := DPortWidth; i+DPortWidth <= len(dport); i += DPortWidth {
+			if bytes.Compare(dport[i:i+DPortWidth], r.DportMin[:]) < 0 {
+				copy(r.DportMin[:], dport[i:i+DPortWidth])
+			}
+			if bytes.Compare(dport[i:i+DPortWidth], r.DportMax[:]) > 0 {
+				copy(r.DportMax[:], dport[i:i+DPortWidth])
+			}
+		}
+	}
+
+	if len(proto) > 0 {
+		r.ProtoMin, r.ProtoMax = proto[0], proto[0]
+		for _, p := range proto[1:] {
+			r.ProtoMin, r.ProtoMax = min(r.ProtoMin, p), max(r.ProtoMax, p)
+		}
+	}
+
+	return r, true
+}
+
+// SIP returns the range of source IPs of the given IP version (and false if the block does
+// not contain any flows of that version)
+func (r *BlockRange) SIP(isIPv4 bool) (IPRange, bool) {
+	if isIPv4 {
+		return r.SIPv4, r.HasV4
+	}
+	return r.SIPv6, r.HasV6
+}
+
+// DIP returns the range of destination IPs of the given IP version (and false if the block does
+// not contain any flows of that version)
+func (r *BlockRange) DIP(isIPv4 bool) (IPRange, bool) {
+	if isIPv4 {
+		return r.DIPv4, r.HasV4
+	}
+	return r.DIPv6, r.HasV6
+}
+
+// Overlaps determines if the range intersects with the (inclusive) range [lower, upper]. Both
+// bounds must have the width of the IP version of the range
+func (r IPRange) Overlaps(lower, upper []byte) bool {
+	width := len(lower)
+	return bytes.Compare(r.Min[:width], upper) <= 0 && bytes.Compare(r.Max[:width], lower) >= 0
+}
+
+// Within determines if the range is fully contained in the (inclusive) range [lower, upper]. Both
+// bounds must have the width of the IP version of the range
+func (r IPRange) Within(lower, upper []byte) bool {
+	width := len(lower)
+	return bytes.Compare(r.Min[:width], lower) >= 0 && bytes.Compare(r.Max[:width], upper) <= 0
+}
+
+func newIPRange(ips []byte, width int) IPRange {
+	var r IPRange
+	if len(ips) < width {
+		return r
+	}
+
+	copy(r.Min[:width], ips[:width])
+	copy(r.Max[:width], ips[:width])
+	for i := width; i+width <= len(ips); i += width {
+		if bytes.Compare(ips[i:i+width], r.Min[:width]) < 0 {
+			copy(r.Min[:width], ips[i:i+width])
+		}
+		if bytes.Compare(ips[i:i+width], r.Max[:width]) > 0 {
+			copy(r.Max[:width], ips[i:i+width])
+		}
+	}
+	return r
+}
@@ -14,6 +14,7 @@ package types
 import (
 	"encoding/binary"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/els0r/goProbe/pkg/goDB/protocols"
@@ -55,6 +56,7 @@ const (
 	HostnameName = "hostname"
 	HostIDName   = "hostid"
 	IfaceName    = "iface"
+	ProcessName  = "process"
 
 	SIPName   = "sip"
 	DIPName   = "dip"
@@ -219,25 +221,37 @@ func (DportAttribute) attributeMarker() {}
 // exists, an error is returned.
 func NewAttribute(name string) (Attribute, error) {
 	// name/alias to attribute matching
-	switch name {
-	case SIPName, "src":
-		return SIPAttribute{}, nil
-	case DIPName, "dst":
-		return DIPAttribute{}, nil
-	case ProtoName, "protocol", "ipproto":
-		return ProtoAttribute{}, nil
-	case DportName, "port":
-		return DportAttribute{}, nil
-	default:
+	spec, exists := LookupAttribute(name)
+	if !exists {
 		return nil, fmt.Errorf("unknown attribute name: '%s'", name)
 	}
+	if spec.New == nil {
+		return nil, fmt.Errorf("attribute '%s' can only be used in conditions", name)
+	}
+	return spec.New(), nil
 }
 
-// AllColumns returns a set of all column names / titles
+// AllColumns returns a set of all column names / titles (as selected by the raw query type). The
+// process label is not part of it since it is only available for interfaces with process attribution,
+// neither are the other attributes stored in label columns or the flow direction (which is derived from
+// the counters)
 func AllColumns() []string {
-	return []string{
-		TimeName, HostnameName, HostIDName, IfaceName, SIPName, DIPName, DportName, ProtoName,
+	return append([]string{TimeName, HostnameName, HostIDName, IfaceName}, queryableAttributeNames()...)
+}
+
+// OutputColumns returns the names / titles of all columns which may be part of a result (in output order)
+func OutputColumns() []string {
+	return append([]string{TimeName, HostnameName, HostIDName, IfaceName, ProcessName, DirectionName}, queryableAttributeNames()...)
+}
+
+// queryableAttributeNames returns the names of all registered attributes that can be used in a query type
+func queryableAttributeNames() (names []string) {
+	for _, spec := range AttributeSpecs() {
+		if spec.New != nil {
+			names = append(names, spec.Name)
+		}
 	}
+	return
 }
 
 const attrSep = ","
@@ -309,6 +323,18 @@ func ParseQueryType(queryType string) (attributes []Attribute, selector LabelSel
 		case HostIDName:
 			selector.HostID = true
 			continue
+		case ProcessName:
+			selector.Process = true
+			continue
+		case DirectionName:
+			selector.Direction = true
+			continue
+		}
+		if spec, exists := LookupAttribute(attributeName); exists && spec.LabelColumn {
+			if !slices.Contains(selector.Columns, spec.Name) {
+				selector.Columns = append(selector.Columns, spec.Name)
+			}
+			continue
 		}
 
 		attribute, err := NewAttribute(attributeName)
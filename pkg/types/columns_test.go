@@ -101,6 +101,8 @@ var parseQueryTypeTests = []struct {
 	{"talk_src,dip", []Attribute{SIPAttribute{}, DIPAttribute{}}, false, false},
 	{"talk_src,src", []Attribute{SIPAttribute{}}, false, false},
 	{"raw", []Attribute{SIPAttribute{}, DIPAttribute{}, DportAttribute{}, ProtoAttribute{}}, true, true},
+	{"sip,process", []Attribute{SIPAttribute{}}, false, false},
+	{"dport,dir", []Attribute{DportAttribute{}}, false, false},
 }
 
 func TestParseQueryType(t *testing.T) {
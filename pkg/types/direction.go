@@ -59,3 +59,63 @@ func (d *Direction) UnmarshalJSON(b []byte) error {
 	*d = DirectionFromString(str)
 	return nil
 }
+
+// FlowDirection classifies a flow by the direction(s) in which traffic was observed
+type FlowDirection uint8
+
+// Enumeration of flow directions
+const (
+	FlowDirectionUnknown FlowDirection = iota // no traffic was observed at all
+	FlowDirectionIn                           // only inbound traffic (received packets)
+	FlowDirectionOut                          // only outbound traffic (sent packets)
+	FlowDirectionBi                           // traffic in both directions
+)
+
+// DirectionName denotes the name of the flow direction attribute. Since the direction is derived
+// from the counters of a flow (rather than stored in a column), it is a label when queried
+const DirectionName = "dir"
+
+// Values of the flow direction attribute. FlowDirectionUniName matches all unidirectional
+// (i.e. both inbound and outbound only) flows
+const (
+	FlowDirectionInName  = "in"
+	FlowDirectionOutName = "out"
+	FlowDirectionUniName = "uni"
+	FlowDirectionBiName  = "bi"
+)
+
+// FlowDirectionNames returns the values permitted for the flow direction attribute
+func FlowDirectionNames() []string {
+	return []string{FlowDirectionInName, FlowDirectionOutName, FlowDirectionUniName, FlowDirectionBiName}
+}
+
+// ClassifyDirection determines the direction of a flow from its packet counters
+func ClassifyDirection(pktsRcvd, pktsSent uint64) FlowDirection {
+	switch {
+	case pktsRcvd > 0 && pktsSent > 0:
+		return FlowDirectionBi
+	case pktsRcvd > 0:
+		return FlowDirectionIn
+	case pktsSent > 0:
+		return FlowDirectionOut
+	}
+	return FlowDirectionUnknown
+}
+
+// String returns the name of the flow direction
+func (d FlowDirection) String() string {
+	switch d {
+	case FlowDirectionIn:
+		return FlowDirectionInName
+	case FlowDirectionOut:
+		return FlowDirectionOutName
+	case FlowDirectionBi:
+		return FlowDirectionBiName
+	}
+	return "unknown"
+}
+
+// IsUnidirectional returns whether traffic was observed in one direction only
+func (d FlowDirection) IsUnidirectional() bool {
+	return d == FlowDirectionIn || d == FlowDirectionOut
+}
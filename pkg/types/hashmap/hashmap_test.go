@@ -116,6 +116,38 @@ func TestAggFlowMapFlatten(t *testing.T) {
 	}
 }
 
+func TestShardedAggFlowMapJoin(t *testing.T) {
+	for _, nShards := range []int{0, 1, 4} {
+		testMap := NewShardedAggFlowMap(nShards)
+		require.Equal(t, max(nShards, 1), testMap.NumShards())
+
+		for i := 0; i < 1000; i++ {
+			temp := make([]byte, 8)
+			binary.BigEndian.PutUint64(temp, uint64(i))
+
+			shard := testMap.Shard(i % testMap.NumShards())
+			shard.PrimaryMap.Set(temp, types.Counters{BytesRcvd: uint64(i)})
+			shard.SecondaryMap.Set(temp, types.Counters{PacketsSent: uint64(i)})
+		}
+		require.Equal(t, 2000, testMap.Len())
+
+		joined := testMap.Join()
+		require.Equal(t, 2000, joined.Len())
+		require.Equal(t, 1, testMap.NumShards())
+		for i := 0; i < 1000; i++ {
+			temp := make([]byte, 8)
+			binary.BigEndian.PutUint64(temp, uint64(i))
+
+			val, exists := joined.PrimaryMap.Get(temp)
+			require.True(t, exists)
+			require.Equal(t, types.Counters{BytesRcvd: uint64(i)}, val)
+			val, exists = joined.SecondaryMap.Get(temp)
+			require.True(t, exists)
+			require.Equal(t, types.Counters{PacketsSent: uint64(i)}, val)
+		}
+	}
+}
+
 func TestLinearHashMapOperations(t *testing.T) {
 
 	testMap := New()
@@ -0,0 +1,86 @@
+package hashmap
+
+// ShardedAggFlowMap denotes a set of AggFlowMaps (shards), typically with disjoint key spaces. Since each
+// shard is a fully independent map, they can be populated concurrently without any locking as
+// long as every shard is only ever accessed by a single goroutine at a time (e.g. by assigning
+// one goroutine per shard)
+type ShardedAggFlowMap struct {
+	shards []*AggFlowMap
+}
+
+// NewShardedAggFlowMap instantiates a new ShardedAggFlowMap with nShards shards (at least one),
+// each of them using a size hint of n
+func NewShardedAggFlowMap(nShards int, n ...int) *ShardedAggFlowMap {
+	if nShards < 1 {
+		nShards = 1
+	}
+	s := &ShardedAggFlowMap{
+		shards: make([]*AggFlowMap, nShards),
+	}
+	for i := range s.shards {
+		s.shards[i] = NewAggFlowMap(n...)
+	}
+	return s
+}
+
+// NumShards returns the number of shards
+func (s *ShardedAggFlowMap) NumShards() int {
+	return len(s.shards)
+}
+
+// Shard returns the i-th shard
+func (s *ShardedAggFlowMap) Shard(i int) *AggFlowMap {
+	return s.shards[i]
+}
+
+// Len returns the number of entries in all shards
+func (s *ShardedAggFlowMap) Len() (l int) {
+	for _, shard := range s.shards {
+		l += shard.Len()
+	}
+	return
+}
+
+// Join merges all shards into a single AggFlowMap. In order to avoid copying, the largest
+// shard is used as basis for the result, hence the ShardedAggFlowMap must not be used afterwards
+func (s *ShardedAggFlowMap) Join() *AggFlowMap {
+	if len(s.shards) == 1 {
+		return s.shards[0]
+	}
+
+	largest := 0
+	for i, shard := range s.shards {
+		if shard.Len() > s.shards[largest].Len() {
+			largest = i
+		}
+	}
+
+	res := s.shards[largest]
+	for i, shard := range s.shards {
+		if i == largest {
+			continue
+		}
+
+		// Entries present in more than one shard (if any) are added up
+		res.Merge(*shard, nil)
+		shard.ClearFast()
+	}
+	s.shards = []*AggFlowMap{res}
+
+	return res
+}
+
+// Clear frees as many resources as possible by making them eligible for GC
+func (s *ShardedAggFlowMap) Clear() {
+	for _, shard := range s.shards {
+		shard.Clear()
+	}
+}
+
+// ClearFast nils all main resources, making them eligible for GC (but
+// probably not as effectively as Clear())
+func (s *ShardedAggFlowMap) ClearFast() {
+	for _, shard := range s.shards {
+		shard.ClearFast()
+	}
+}
@@ -0,0 +1,131 @@
+// Package hll implements HyperLogLog sketches for estimating the number of distinct values
+// (e.g. the number of distinct destination IPs contacted by a source) with constant memory
+package hll
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/zeebo/xxh3"
+)
+
+const (
+	// Precision denotes the number of bits of a value's hash used to select its register
+	Precision = 10
+
+	// NumRegisters denotes the number of registers of a sketch. The standard error of the
+	// estimate is 1.04 / sqrt(NumRegisters), i.e. ~3.25%
+	NumRegisters = 1 << Precision
+)
+
+var errorInvalidRegisters = errors.New("invalid number of registers")
+
+// Sketch is a HyperLogLog sketch. Its zero value is an empty sketch ready to use
+type Sketch struct {
+	registers [NumRegisters]uint8
+}
+
+// New creates a new, empty sketch
+func New() *Sketch {
+	return &Sketch{}
+}
+
+// Add adds a value to the sketch
+func (s *Sketch) Add(value []byte) {
+	hash := xxh3.Hash(value)
+
+	// the leading bits select the register, the remaining bits determine its rank (the
+	// position of the first set bit)
+	idx := hash >> (64 - Precision)
+	rank := uint8(bits.LeadingZeros64(hash<<Precision|1<<(Precision-1))) + 1
+	if rank > s.registers[idx] {
+		s.registers[idx] = rank
+	}
+}
+
+// Merge adds all values of another sketch to the sketch
+func (s *Sketch) Merge(other *Sketch) {
+	if other == nil {
+		return
+	}
+	for i, rank := range other.registers {
+		if rank > s.registers[i] {
+			s.registers[i] = rank
+		}
+	}
+}
+
+// Count returns the estimated number of distinct values added to the sketch
+func (s *Sketch) Count() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	var sum float64
+	var zeros int
+	for _, rank := range s.registers {
+		sum += 1 / float64(uint64(1)<<rank)
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	const m = float64(NumRegisters)
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+
+	// linear counting is more accurate for small cardinalities
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(math.Round(estimate))
+}
+
+// sketchJSON is the serialized form of a sketch. Along with the estimate, the registers are
+// provided so that sketches can be merged downstream (e.g. across hosts)
+type sketchJSON struct {
+	Count     uint64 `json:"count"`     // Count: the estimated number of distinct values. Example: 42
+	Registers string `json:"registers"` // Registers: the base64 encoded registers of the sketch
+}
+
+// MarshalJSON implements the jsoniter.Marshaler interface
+func (s *Sketch) MarshalJSON() ([]byte, error) {
+	return jsoniter.Marshal(sketchJSON{
+		Count:     s.Count(),
+		Registers: base64.StdEncoding.EncodeToString(s.registers[:]),
+	})
+}
+
+// UnmarshalJSON implements the jsoniter.Unmarshaler interface
+func (s *Sketch) UnmarshalJSON(data []byte) error {
+	var aux sketchJSON
+	if err := jsoniter.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	registers, err := base64.StdEncoding.DecodeString(aux.Registers)
+	if err != nil {
+		return err
+	}
+	if len(registers) != NumRegisters {
+		return fmt.Errorf("%w: %d (expected %d)", errorInvalidRegisters, len(registers), NumRegisters)
+	}
+	copy(s.registers[:], registers)
+	return nil
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface, returning the registers of the sketch
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	return append([]byte(nil), s.registers[:]...), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface
+func (s *Sketch) UnmarshalBinary(data []byte) error {
+	if len(data) != NumRegisters {
+		return fmt.Errorf("%w: %d (expected %d)", errorInvalidRegisters, len(data), NumRegisters)
+	}
+	copy(s.registers[:], data)
+	return nil
+}
@@ -0,0 +1,77 @@
+package hll
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func addValues(s *Sketch, from, to int) {
+	var value [4]byte
+	for i := from; i < to; i++ {
+		binary.BigEndian.PutUint32(value[:], uint32(i))
+		s.Add(value[:])
+	}
+}
+
+func TestCount(t *testing.T) {
+	var tests = []int{0, 1, 10, 100, 1000, 10000, 100000, 1000000}
+
+	for _, n := range tests {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			s := New()
+			addValues(s, 0, n)
+
+			// adding the same values again must not change the estimate
+			count := s.Count()
+			addValues(s, 0, n)
+			require.Equal(t, count, s.Count())
+
+			require.InDelta(t, n, count, 0.1*float64(n)+1)
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, b, all := New(), New(), New()
+	addValues(a, 0, 6000)
+	addValues(b, 4000, 10000)
+	addValues(all, 0, 10000)
+
+	a.Merge(b)
+	require.Equal(t, all.Count(), a.Count())
+
+	a.Merge(nil)
+	require.Equal(t, all.Count(), a.Count())
+}
+
+func TestJSON(t *testing.T) {
+	s := New()
+	addValues(s, 0, 500)
+
+	b, err := jsoniter.Marshal(s)
+	require.Nil(t, err)
+
+	var decoded Sketch
+	require.Nil(t, jsoniter.Unmarshal(b, &decoded))
+	require.Equal(t, *s, decoded)
+
+	require.ErrorIs(t, decoded.UnmarshalJSON([]byte(`{"count":1,"registers":"AAAA"}`)), errorInvalidRegisters)
+}
+
+func TestBinary(t *testing.T) {
+	s := New()
+	addValues(s, 0, 500)
+
+	b, err := s.MarshalBinary()
+	require.Nil(t, err)
+
+	var decoded Sketch
+	require.Nil(t, decoded.UnmarshalBinary(b))
+	require.Equal(t, *s, decoded)
+
+	require.ErrorIs(t, decoded.UnmarshalBinary(b[1:]), errorInvalidRegisters)
+}
@@ -16,6 +16,7 @@ package types
 import (
 	"encoding/binary"
 	"fmt"
+	"strconv"
 
 	"github.com/els0r/goProbe/pkg/goDB/protocols"
 )
@@ -251,6 +252,19 @@ func (k Key) ExtendEmpty() (e ExtendedKey) {
 	return k.Extend(0)
 }
 
+// ExtendLabels appends an (empty) labels extension to the extended key, which is subsequently
+// set via PutLabels(). The extension holds the ID of the queried labels stored in label columns
+// (i.e. the process and / or label column attributes) of a flow
+func (e ExtendedKey) ExtendLabels() ExtendedKey {
+	return append(e.Clone(), make([]byte, LabelsIDWidth)...)
+}
+
+// ExtendDirection appends an (empty) flow direction extension to the extended key, which is
+// subsequently set via PutDirection(). It has to be the last extension of the key
+func (e ExtendedKey) ExtendDirection() ExtendedKey {
+	return append(e.Clone(), make([]byte, DirectionWidth)...)
+}
+
 // ExtendedKey is a Key with supplemental information
 type ExtendedKey []byte
 
@@ -272,15 +286,43 @@ func (e ExtendedKey) Key() Key {
 
 // IsIPv4 returns if the key represents an IPv4 packet / flow
 func (e ExtendedKey) IsIPv4() bool {
-	if len(e) == KeyWidthIPv4 || len(e) == KeyWidthIPv4+TimestampWidth {
+	if isExtensionWidth(len(e) - KeyWidthIPv4) {
 		return true
 	}
-	if len(e) == KeyWidthIPv6 || len(e) == KeyWidthIPv6+TimestampWidth {
+	if isExtensionWidth(len(e) - KeyWidthIPv6) {
 		return false
 	}
 	panic(fmt.Sprintf("extended key `%v` is neither ipv4 nor ipv6", []byte(e)))
 }
 
+// isExtensionWidth checks if width matches any combination of the (time / labels / direction) extensions
+func isExtensionWidth(width int) bool {
+	if width < 0 {
+		return false
+	}
+	if width >= TimestampWidth {
+		width -= TimestampWidth
+	}
+	if width >= LabelsIDWidth {
+		width -= LabelsIDWidth
+	}
+	return width == 0 || width == DirectionWidth
+}
+
+// extensions returns the position of the extensions of the key (following the basic key) and
+// which ones are present
+func (e ExtendedKey) extensions() (pos int, hasTime, hasLabels, hasDirection bool) {
+	pos = KeyWidthIPv6
+	if e.IsIPv4() {
+		pos = KeyWidthIPv4
+	}
+	width := len(e) - pos
+	if hasTime = width >= TimestampWidth; hasTime {
+		width -= TimestampWidth
+	}
+	return pos, hasTime, width >= LabelsIDWidth, width%LabelsIDWidth == DirectionWidth
+}
+
 // PutSIP stores a source IP in the key
 func (e ExtendedKey) PutSIP(sip []byte) {
 	copy(e[sipPos:], sip)
@@ -392,11 +434,49 @@ func (e ExtendedKey) GetDIP() []byte {
 
 // AttrTime retrieves the time extension (indicating its presence via the second result parameter)
 func (e ExtendedKey) AttrTime() (int64, bool) {
-	if len(e) == KeyWidthIPv4 || len(e) == KeyWidthIPv6 {
+	pos, hasTime, _, _ := e.extensions()
+	if !hasTime {
 		return 0, false
 	}
 
-	return int64(binary.BigEndian.Uint64(e[len(e)-8:])), true
+	return int64(binary.BigEndian.Uint64(e[pos : pos+TimestampWidth])), true
+}
+
+// PutLabels stores a labels ID in the labels extension of the key (see ExtendLabels())
+func (e ExtendedKey) PutLabels(id uint32) {
+	binary.BigEndian.PutUint32(e[e.labelsPos():], id)
+}
+
+// AttrLabels retrieves the labels extension (indicating its presence via the second result parameter)
+func (e ExtendedKey) AttrLabels() (uint32, bool) {
+	if _, _, hasLabels, _ := e.extensions(); !hasLabels {
+		return 0, false
+	}
+
+	return binary.BigEndian.Uint32(e[e.labelsPos():]), true
+}
+
+// labelsPos returns the position of the labels extension, which is followed by the direction
+// extension (if any)
+func (e ExtendedKey) labelsPos() int {
+	if _, _, _, hasDirection := e.extensions(); hasDirection {
+		return len(e) - DirectionWidth - LabelsIDWidth
+	}
+	return len(e) - LabelsIDWidth
+}
+
+// PutDirection stores a flow direction in the direction extension of the key (see ExtendDirection())
+func (e ExtendedKey) PutDirection(dir FlowDirection) {
+	e[len(e)-DirectionWidth] = byte(dir)
+}
+
+// AttrDirection retrieves the direction extension (indicating its presence via the second result parameter)
+func (e ExtendedKey) AttrDirection() (FlowDirection, bool) {
+	if _, _, _, hasDirection := e.extensions(); !hasDirection {
+		return FlowDirectionUnknown, false
+	}
+
+	return FlowDirection(e[len(e)-DirectionWidth]), true
 }
 
 // String prints the key as a comma separated attribute list
@@ -411,10 +491,30 @@ func (k Key) String() string {
 
 // Counters stores the goProbe flow counters (and, where required, some extensions)
 type Counters struct {
-	BytesRcvd   uint64 `json:"br,omitempty"` // BytesRcvd: bytes received
-	BytesSent   uint64 `json:"bs,omitempty"` // BytesSent: bytes sent
-	PacketsRcvd uint64 `json:"pr,omitempty"` // PacketRcvd: packets received
-	PacketsSent uint64 `json:"ps,omitempty"` // PacketSent: packets sent
+	BytesRcvd   uint64 `json:"br"` // BytesRcvd: bytes received
+	BytesSent   uint64 `json:"bs"` // BytesSent: bytes sent
+	PacketsRcvd uint64 `json:"pr"` // PacketRcvd: packets received
+	PacketsSent uint64 `json:"ps"` // PacketSent: packets sent
+}
+
+// MarshalJSON implements the json.Marshaler interface. The counters of both directions are always
+// included (even if zero), along with their sums (bytes / packets), so that consumers can rely on
+// the same fields regardless of the direction queried. The sums are ignored upon unmarshalling
+func (c Counters) MarshalJSON() ([]byte, error) {
+	b := make([]byte, 0, 128)
+	b = append(b, `{"br":`...)
+	b = strconv.AppendUint(b, c.BytesRcvd, 10)
+	b = append(b, `,"bs":`...)
+	b = strconv.AppendUint(b, c.BytesSent, 10)
+	b = append(b, `,"pr":`...)
+	b = strconv.AppendUint(b, c.PacketsRcvd, 10)
+	b = append(b, `,"ps":`...)
+	b = strconv.AppendUint(b, c.PacketsSent, 10)
+	b = append(b, `,"bytes":`...)
+	b = strconv.AppendUint(b, c.SumBytes(), 10)
+	b = append(b, `,"packets":`...)
+	b = strconv.AppendUint(b, c.SumPackets(), 10)
+	return append(b, '}'), nil
 }
 
 // String prints the flow counters
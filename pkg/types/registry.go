@@ -0,0 +1,285 @@
+package types
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/els0r/goProbe/pkg/goDB/protocols"
+)
+
+// Comparators supported by the condition grammar
+var (
+	// EqualityComparators can be used with any attribute
+	EqualityComparators = []string{"=", "!="}
+
+	// OrderedComparators can be used with attributes whose values are ordered (e.g. ports)
+	OrderedComparators = []string{"=", "!=", "<", ">", "<=", ">="}
+)
+
+// AttributeSpec describes an attribute, i.e. everything required to query it, use it in
+// conditions and display it. Attributes are made available by registering their spec
+// via RegisterAttribute()
+//
+// An attribute is either derived from one of the built-in attribute columns (see ColIdxAttributeCount)
+// or stored in a label column of its own (see LabelColumn). Derived attributes without an Attribute
+// implementation can only be used in conditions
+type AttributeSpec struct {
+	Name    string   // Name: canonical name of the attribute (e.g. "sip")
+	Aliases []string // Aliases: alternative names accepted in query types and conditions (e.g. "src")
+
+	// LabelColumn: the attribute is stored in a label column of its own (named after the attribute)
+	// instead of being derived from a built-in column. Its column index is allocated upon registration
+	// (past the built-in columns, see ColIdxCount), its values are written by a labeler of the same
+	// name (see goDB.DBWriter.WriteWithLabels()). Since they aren't part of the flow key, the attribute
+	// is queried like the process label and can't be used in conditions, hence none of the functions /
+	// comparators below apply
+	LabelColumn bool
+
+	ColIdx     ColumnIndex // ColIdx: the column the attribute is stored in (or derived from)
+	Width      Width       // Width: width of a value in bytes (IPSizeOf for variable width IPs)
+	Resolvable bool        // Resolvable: whether the values can be resolved via reverse DNS lookup
+
+	// Comparators denotes the comparison operators supported in conditions
+	Comparators []string
+
+	// Parse converts a (user provided) string into the raw value of the attribute
+	Parse func(value string) ([]byte, error)
+
+	// Format converts a raw value of the attribute into its string representation
+	Format func(data []byte) string
+
+	// Extract returns the raw value of the attribute from a flow key
+	Extract func(key Key) []byte
+
+	// New creates an instance of the attribute for use in query types (optional)
+	New func() Attribute
+}
+
+var (
+	errorEmptyAttributeName   = errors.New("empty attribute name")
+	errorAttributeExists      = errors.New("attribute name already registered")
+	errorInvalidAttributeCol  = errors.New("attribute column index out of range")
+	errorIncompleteAttribute  = errors.New("attribute spec requires Parse, Format and Extract functions")
+	errorNoAttributeCompartor = errors.New("attribute spec requires at least one comparator")
+	errorInvalidLabelColumn   = errors.New("invalid label column attribute")
+)
+
+// label column names are part of file names (see gpfile.GPDir.LabelColumns())
+var labelColumnNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+var attributeRegistry = struct {
+	sync.RWMutex
+
+	specs        []*AttributeSpec
+	byName       map[string]*AttributeSpec // includes aliases
+	labelColumns []*AttributeSpec          // indexed by ColIdx - ColIdxCount
+}{
+	byName: make(map[string]*AttributeSpec),
+}
+
+// RegisterAttribute registers an attribute, making it available to query type parsing,
+// conditions and command line completion. The column index of an attribute stored in a label
+// column is allocated by the registry
+func RegisterAttribute(spec AttributeSpec) error {
+	if spec.Name == "" {
+		return errorEmptyAttributeName
+	}
+	if spec.LabelColumn {
+		if err := validateLabelColumnAttribute(spec); err != nil {
+			return err
+		}
+	} else {
+		if spec.ColIdx < 0 || spec.ColIdx >= ColIdxAttributeCount {
+			return fmt.Errorf("%w: %d (attribute `%s`)", errorInvalidAttributeCol, spec.ColIdx, spec.Name)
+		}
+		if spec.Parse == nil || spec.Format == nil || spec.Extract == nil {
+			return fmt.Errorf("%w (attribute `%s`)", errorIncompleteAttribute, spec.Name)
+		}
+		if len(spec.Comparators) == 0 {
+			return fmt.Errorf("%w (attribute `%s`)", errorNoAttributeCompartor, spec.Name)
+		}
+	}
+
+	attributeRegistry.Lock()
+	defer attributeRegistry.Unlock()
+
+	names := append([]string{spec.Name}, spec.Aliases...)
+	for _, name := range names {
+		if _, exists := attributeRegistry.byName[name]; exists || isLabelName(name) {
+			return fmt.Errorf("%w: `%s`", errorAttributeExists, name)
+		}
+	}
+
+	s := spec
+	if s.LabelColumn {
+		s.ColIdx = ColIdxCount + ColumnIndex(len(attributeRegistry.labelColumns))
+		attributeRegistry.labelColumns = append(attributeRegistry.labelColumns, &s)
+	}
+	attributeRegistry.specs = append(attributeRegistry.specs, &s)
+	for _, name := range names {
+		attributeRegistry.byName[name] = &s
+	}
+	return nil
+}
+
+// validateLabelColumnAttribute checks that an attribute can be stored in a label column
+func validateLabelColumnAttribute(spec AttributeSpec) error {
+	if !labelColumnNameRegexp.MatchString(spec.Name) {
+		return fmt.Errorf("%w: `%s` can't be used as column name", errorInvalidLabelColumn, spec.Name)
+	}
+	for _, name := range ColumnFileNames {
+		if spec.Name == name {
+			return fmt.Errorf("%w: `%s` is a built-in column", errorInvalidLabelColumn, spec.Name)
+		}
+	}
+	if len(spec.Comparators) > 0 {
+		return fmt.Errorf("%w: `%s` can't be used in conditions", errorInvalidLabelColumn, spec.Name)
+	}
+	return nil
+}
+
+// MustRegisterAttribute registers an attribute and panics if the registration fails
+func MustRegisterAttribute(spec AttributeSpec) {
+	if err := RegisterAttribute(spec); err != nil {
+		panic(err)
+	}
+}
+
+// LookupAttribute returns the spec of an attribute by its name or one of its aliases
+func LookupAttribute(name string) (AttributeSpec, bool) {
+	attributeRegistry.RLock()
+	defer attributeRegistry.RUnlock()
+
+	spec, exists := attributeRegistry.byName[name]
+	if !exists {
+		return AttributeSpec{}, false
+	}
+	return *spec, true
+}
+
+// AttributeSpecs returns the specs of all registered attributes (in order of registration)
+func AttributeSpecs() []AttributeSpec {
+	attributeRegistry.RLock()
+	defer attributeRegistry.RUnlock()
+
+	specs := make([]AttributeSpec, len(attributeRegistry.specs))
+	for i, spec := range attributeRegistry.specs {
+		specs[i] = *spec
+	}
+	return specs
+}
+
+// LabelColumnAttribute returns the spec of the attribute stored in the label column with the given
+// column index (see AttributeSpec.LabelColumn)
+func LabelColumnAttribute(colIdx ColumnIndex) (AttributeSpec, bool) {
+	attributeRegistry.RLock()
+	defer attributeRegistry.RUnlock()
+
+	i := int(colIdx - ColIdxCount)
+	if i < 0 || i >= len(attributeRegistry.labelColumns) {
+		return AttributeSpec{}, false
+	}
+	return *attributeRegistry.labelColumns[i], true
+}
+
+// AttributeNames returns the canonical names of all registered attributes
+func AttributeNames() []string {
+	specs := AttributeSpecs()
+	names := make([]string, len(specs))
+	for i, spec := range specs {
+		names[i] = spec.Name
+	}
+	return names
+}
+
+func isLabelName(name string) bool {
+	switch name {
+	case TimeName, HostnameName, HostIDName, IfaceName, ProcessName, DirectionName:
+		return true
+	}
+	return false
+}
+
+func parseIP(value string) ([]byte, error) {
+	data, _, err := IPStringToBytes(value)
+	return data, err
+}
+
+func parseProto(value string) ([]byte, error) {
+	num, err := strconv.ParseUint(value, 10, 8)
+	if err != nil {
+		var isIn bool
+		if num, isIn = protocols.GetIPProtoID(value); !isIn {
+			return nil, fmt.Errorf("unknown IP protocol `%s`", value)
+		}
+	}
+	return []byte{uint8(num)}, nil
+}
+
+func parsePort(value string) ([]byte, error) {
+	num, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		return nil, err
+	}
+	return binary.BigEndian.AppendUint16(nil, uint16(num)), nil
+}
+
+// the built-in attributes, registered in the order they are displayed in
+func init() {
+	MustRegisterAttribute(AttributeSpec{
+		Name:        SIPName,
+		Aliases:     []string{"src"},
+		ColIdx:      SIPColIdx,
+		Width:       SIPSizeof,
+		Resolvable:  true,
+		Comparators: EqualityComparators,
+		Parse:       parseIP,
+		Format:      RawIPToString,
+		Extract:     Key.GetSIP,
+		New:         func() Attribute { return SIPAttribute{} },
+	})
+	MustRegisterAttribute(AttributeSpec{
+		Name:        DIPName,
+		Aliases:     []string{"dst"},
+		ColIdx:      DIPColIdx,
+		Width:       DIPSizeof,
+		Resolvable:  true,
+		Comparators: EqualityComparators,
+		Parse:       parseIP,
+		Format:      RawIPToString,
+		Extract:     Key.GetDIP,
+		New:         func() Attribute { return DIPAttribute{} },
+	})
+	MustRegisterAttribute(AttributeSpec{
+		Name:        DportName,
+		Aliases:     []string{"port"},
+		ColIdx:      DportColIdx,
+		Width:       DportSizeof,
+		Comparators: OrderedComparators,
+		Parse:       parsePort,
+		Format: func(data []byte) string {
+			return strconv.Itoa(int(PortToUint16(data)))
+		},
+		Extract: Key.GetDport,
+		New:     func() Attribute { return DportAttribute{} },
+	})
+	MustRegisterAttribute(AttributeSpec{
+		Name:        ProtoName,
+		Aliases:     []string{"protocol", "ipproto"},
+		ColIdx:      ProtoColIdx,
+		Width:       ProtoSizeof,
+		Comparators: OrderedComparators,
+		Parse:       parseProto,
+		Format: func(data []byte) string {
+			return protocols.GetIPProto(int(data[0]))
+		},
+		Extract: func(key Key) []byte {
+			return []byte{key.GetProto()}
+		},
+		New: func() Attribute { return ProtoAttribute{} },
+	})
+}
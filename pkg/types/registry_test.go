@@ -0,0 +1,147 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// unregisterAttribute removes an attribute registered during a test
+func unregisterAttribute(t *testing.T, name string) {
+	t.Helper()
+
+	attributeRegistry.Lock()
+	defer attributeRegistry.Unlock()
+
+	for i, spec := range attributeRegistry.specs {
+		if spec.Name != name {
+			continue
+		}
+		attributeRegistry.specs = append(attributeRegistry.specs[:i], attributeRegistry.specs[i+1:]...)
+		if spec.LabelColumn {
+			attributeRegistry.labelColumns = attributeRegistry.labelColumns[:spec.ColIdx-ColIdxCount]
+		}
+		delete(attributeRegistry.byName, spec.Name)
+		for _, alias := range spec.Aliases {
+			delete(attributeRegistry.byName, alias)
+		}
+		return
+	}
+	t.Fatalf("attribute %s not registered", name)
+}
+
+func TestBuiltinAttributes(t *testing.T) {
+	require.Equal(t, []string{SIPName, DIPName, DportName, ProtoName}, AttributeNames())
+
+	for _, test := range []struct {
+		name, value string
+		raw         []byte
+	}{
+		{"src", "10.0.0.1", []byte{10, 0, 0, 1}},
+		{DportName, "443", []byte{0x01, 0xBB}},
+		{"ipproto", "udp", []byte{17}},
+		{ProtoName, "6", []byte{6}},
+	} {
+		spec, exists := LookupAttribute(test.name)
+		require.True(t, exists)
+
+		raw, err := spec.Parse(test.value)
+		require.Nil(t, err)
+		require.Equal(t, test.raw, raw)
+	}
+
+	spec, _ := LookupAttribute(ProtoName)
+	require.Equal(t, "TCP", spec.Format([]byte{6}))
+	_, err := spec.Parse("notaprotocol")
+	require.NotNil(t, err)
+}
+
+func TestRegisterAttribute(t *testing.T) {
+	// a derived attribute: the privileged port range flag of the destination port
+	spec := AttributeSpec{
+		Name:        "dport_class",
+		Aliases:     []string{"pclass"},
+		ColIdx:      DportColIdx,
+		Width:       1,
+		Comparators: EqualityComparators,
+		Parse: func(value string) ([]byte, error) {
+			if value == "privileged" {
+				return []byte{1}, nil
+			}
+			return []byte{0}, nil
+		},
+		Format: func(data []byte) string {
+			if data[0] == 1 {
+				return "privileged"
+			}
+			return "unprivileged"
+		},
+		Extract: func(key Key) []byte {
+			if PortToUint16(key.GetDport()) < 1024 {
+				return []byte{1}
+			}
+			return []byte{0}
+		},
+	}
+	require.Nil(t, RegisterAttribute(spec))
+	defer unregisterAttribute(t, spec.Name)
+
+	registered, exists := LookupAttribute("pclass")
+	require.True(t, exists)
+	require.Equal(t, spec.Name, registered.Name)
+	require.Equal(t, []byte{1}, registered.Extract(NewV4KeyStatic([4]byte{}, [4]byte{}, []byte{0, 80}, 6)))
+
+	// derived attributes can't be queried
+	_, err := NewAttribute(spec.Name)
+	require.NotNil(t, err)
+	require.NotContains(t, AllColumns(), spec.Name)
+
+	// names (and aliases) must be unique
+	require.ErrorIs(t, RegisterAttribute(spec), errorAttributeExists)
+	spec.Name, spec.Aliases = "other", []string{"sip"}
+	require.ErrorIs(t, RegisterAttribute(spec), errorAttributeExists)
+	spec.Aliases = []string{TimeName}
+	require.ErrorIs(t, RegisterAttribute(spec), errorAttributeExists)
+
+	// incomplete specs are rejected
+	spec.Aliases = nil
+	spec.Extract = nil
+	require.ErrorIs(t, RegisterAttribute(spec), errorIncompleteAttribute)
+	require.ErrorIs(t, RegisterAttribute(AttributeSpec{Name: "x", ColIdx: ColIdxAttributeCount}), errorInvalidAttributeCol)
+	require.ErrorIs(t, RegisterAttribute(AttributeSpec{}), errorEmptyAttributeName)
+}
+
+func TestRegisterLabelColumnAttribute(t *testing.T) {
+	// the column indices of attributes stored in label columns are allocated past the built-in columns
+	for i, name := range []string{"vlan", "zone"} {
+		require.Nil(t, RegisterAttribute(AttributeSpec{Name: name, LabelColumn: true, ColIdx: SIPColIdx}))
+		defer unregisterAttribute(t, name)
+
+		spec, exists := LookupAttribute(name)
+		require.True(t, exists)
+		require.Equal(t, ColIdxCount+ColumnIndex(i), spec.ColIdx)
+
+		labelColumn, exists := LabelColumnAttribute(spec.ColIdx)
+		require.True(t, exists)
+		require.Equal(t, name, labelColumn.Name)
+	}
+	_, exists := LabelColumnAttribute(ColIdxCount + 2)
+	require.False(t, exists)
+
+	// they are queried as labels
+	attributes, selector, err := ParseQueryType("sip,zone,vlan,zone")
+	require.Nil(t, err)
+	require.Equal(t, []Attribute{SIPAttribute{}}, attributes)
+	require.Equal(t, []string{"zone", "vlan"}, selector.Columns)
+	require.NotContains(t, OutputColumns(), "vlan")
+
+	// the name has to be usable as column (file) name and they can't be used in conditions
+	for _, spec := range []AttributeSpec{
+		{Name: "VLAN", LabelColumn: true},
+		{Name: "vlan-id", LabelColumn: true},
+		{Name: ColumnFileNames[BytesRcvdColIdx], LabelColumn: true},
+		{Name: "tenant", LabelColumn: true, Comparators: EqualityComparators},
+	} {
+		require.ErrorIs(t, RegisterAttribute(spec), errorInvalidLabelColumn)
+	}
+}
@@ -0,0 +1,68 @@
+package types
+
+import (
+	"slices"
+	"sort"
+)
+
+// TimeWindow denotes an interval of block timestamps (UNIX timestamps, both inclusive)
+type TimeWindow struct {
+	First int64 `json:"from"` // First: start of the window. Example: 1672563600
+	Last  int64 `json:"to"`   // Last: end of the window. Example: 1672596000
+}
+
+// TimeWindows denotes a set of disjoint time windows, ordered by time
+type TimeWindows []TimeWindow
+
+// NewTimeWindows creates a set of disjoint time windows from the windows provided (in any order),
+// merging overlapping or adjacent windows
+func NewTimeWindows(windows ...TimeWindow) TimeWindows {
+	if len(windows) == 0 {
+		return nil
+	}
+
+	sorted := slices.Clone(windows)
+	slices.SortFunc(sorted, func(a, b TimeWindow) int {
+		switch {
+		case a.First < b.First:
+			return -1
+		case a.First > b.First:
+			return 1
+		}
+		return 0
+	})
+
+	merged := TimeWindows{sorted[0]}
+	for _, window := range sorted[1:] {
+		if cur := &merged[len(merged)-1]; window.First <= cur.Last+1 {
+			cur.Last = max(cur.Last, window.Last)
+			continue
+		}
+		merged = append(merged, window)
+	}
+	return merged
+}
+
+// Span returns the start of the first and the end of the last window
+func (w TimeWindows) Span() (first, last int64) {
+	if len(w) == 0 {
+		return 0, 0
+	}
+	return w[0].First, w[len(w)-1].Last
+}
+
+// Contains determines if the timestamp lies within any of the windows
+func (w TimeWindows) Contains(timestamp int64) bool {
+	i := sort.Search(len(w), func(i int) bool {
+		return w[i].Last >= timestamp
+	})
+	return i < len(w) && w[i].First <= timestamp
+}
+
+// Overlaps determines if any of the windows overlaps with the interval [first, last]
+func (w TimeWindows) Overlaps(first, last int64) bool {
+	i := sort.Search(len(w), func(i int) bool {
+		return w[i].Last >= first
+	})
+	return i < len(w) && w[i].First <= last
+}
@@ -66,6 +66,11 @@ type LabelSelector struct {
 	Iface     bool `json:"iface,omitempty"`
 	Hostname  bool `json:"hostname,omitempty"`
 	HostID    bool `json:"host_id,omitempty"`
+	Process   bool `json:"process,omitempty"`
+	Direction bool `json:"direction,omitempty"`
+
+	// Columns denotes the queried attributes stored in label columns (see AttributeSpec.LabelColumn)
+	Columns []string `json:"columns,omitempty"`
 }
 
 // Width denotes the on-screen column width based on column type
@@ -79,6 +84,8 @@ const (
 	ProtoWidth Width = 1
 
 	TimestampWidth Width = 8
+	LabelsIDWidth  Width = 4
+	DirectionWidth Width = 1
 )
 
 // Basic constants used to simplify column width calculations
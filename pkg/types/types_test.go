@@ -56,3 +56,83 @@ func TestIPAddressStringParsing(t *testing.T) {
 		require.Equal(t, test.expectedErr, err)
 	}
 }
+
+func TestTimeWindows(t *testing.T) {
+	windows := NewTimeWindows(
+		TimeWindow{First: 500, Last: 600},
+		TimeWindow{First: 100, Last: 200},
+		TimeWindow{First: 150, Last: 300},
+		TimeWindow{First: 301, Last: 400},
+	)
+	require.Equal(t, TimeWindows{{First: 100, Last: 400}, {First: 500, Last: 600}}, windows)
+
+	first, last := windows.Span()
+	require.EqualValues(t, 100, first)
+	require.EqualValues(t, 600, last)
+
+	for ts, expected := range map[int64]bool{
+		99: false, 100: true, 400: true, 401: false, 499: false, 500: true, 600: true, 601: false,
+	} {
+		require.Equal(t, expected, windows.Contains(ts), "timestamp %d", ts)
+	}
+
+	require.True(t, windows.Overlaps(0, 100))
+	require.True(t, windows.Overlaps(450, 550))
+	require.False(t, windows.Overlaps(401, 499))
+	require.False(t, windows.Overlaps(601, 700))
+
+	require.Nil(t, NewTimeWindows())
+	require.False(t, TimeWindows(nil).Contains(100))
+}
+
+func TestKeyExtensions(t *testing.T) {
+	for _, key := range []Key{NewEmptyV4Key(), NewEmptyV6Key()} {
+		key.PutDport([]byte{0, 80})
+
+		for _, ts := range []int64{0, 1000} {
+			extended := key.Extend(ts)
+			attrTime, hasTime := extended.AttrTime()
+			require.Equal(t, ts > 0, hasTime)
+			require.Equal(t, ts, attrTime)
+			_, hasLabels := extended.AttrLabels()
+			require.False(t, hasLabels)
+
+			withLabels := extended.ExtendLabels()
+			withLabels.PutLabels(42)
+			require.Equal(t, key.IsIPv4(), withLabels.IsIPv4())
+			require.Equal(t, key, withLabels.Key())
+
+			attrTime, hasTime = withLabels.AttrTime()
+			require.Equal(t, ts > 0, hasTime)
+			require.Equal(t, ts, attrTime)
+			labels, hasLabels := withLabels.AttrLabels()
+			require.True(t, hasLabels)
+			require.EqualValues(t, 42, labels)
+			_, hasDir := withLabels.AttrDirection()
+			require.False(t, hasDir)
+
+			for _, base := range []ExtendedKey{extended, withLabels} {
+				_, baseHasLabels := base.AttrLabels()
+				withDir := base.ExtendDirection()
+				withDir.PutDirection(FlowDirectionOut)
+				if baseHasLabels {
+					withDir.PutLabels(43)
+				}
+				require.Equal(t, key.IsIPv4(), withDir.IsIPv4())
+				require.Equal(t, key, withDir.Key())
+
+				attrTime, hasTime = withDir.AttrTime()
+				require.Equal(t, ts > 0, hasTime)
+				require.Equal(t, ts, attrTime)
+				dir, hasDir := withDir.AttrDirection()
+				require.True(t, hasDir)
+				require.Equal(t, FlowDirectionOut, dir)
+				labels, hasLabels = withDir.AttrLabels()
+				require.Equal(t, baseHasLabels, hasLabels)
+				if hasLabels {
+					require.EqualValues(t, 43, labels)
+				}
+			}
+		}
+	}
+}
@@ -11,8 +11,107 @@
 // Package util is used to store info about the physical interfaces of IPSEC tunnels. The mapping is specific to an environment that has multiple IPSec tunnels set up.
 package util
 
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
 // TunnelInfo stores information about the physical interfaces of IPSec tunnels
 type TunnelInfo struct {
-	PhysicalIface string
-	Peer          string
+	PhysicalIface string `json:"physical_iface,omitempty" yaml:"physical_iface,omitempty"` // PhysicalIface: the interface the tunnel is set up on. Example: eth0
+	Peer          string `json:"peer,omitempty" yaml:"peer,omitempty"`                     // Peer: the remote end of the tunnel. Example: 198.51.100.1
+	Site          string `json:"site,omitempty" yaml:"site,omitempty"`                     // Site: the logical site the tunnel connects to. Example: zurich
+}
+
+// TunnelProvider provides the metadata of tunnel interfaces, keyed by interface name
+type TunnelProvider interface {
+	TunnelInfos() (map[string]TunnelInfo, error)
+}
+
+var tunnelProvider = struct {
+	sync.RWMutex
+	provider TunnelProvider
+}{}
+
+// SetTunnelProvider sets the provider consulted by TunnelInfos(). A nil provider disables the
+// tunnel metadata
+func SetTunnelProvider(provider TunnelProvider) {
+	tunnelProvider.Lock()
+	tunnelProvider.provider = provider
+	tunnelProvider.Unlock()
+}
+
+// TunnelInfos returns the metadata of all tunnel interfaces known to the current provider. If
+// no provider is set, no tunnels are returned
+func TunnelInfos() (map[string]TunnelInfo, error) {
+	tunnelProvider.RLock()
+	provider := tunnelProvider.provider
+	tunnelProvider.RUnlock()
+
+	if provider == nil {
+		return nil, nil
+	}
+	infos, err := provider.TunnelInfos()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tunnel metadata: %w", err)
+	}
+	return infos, nil
+}
+
+// FileTunnelProvider reads the tunnel metadata from a YAML file mapping interface names to their
+// metadata, e.g.
+//
+//	t4_zrh:
+//	  physical_iface: eth0
+//	  peer: 198.51.100.1
+//	  site: zurich
+//
+// The file is re-read whenever it was modified. A missing file denotes that there are no tunnels
+type FileTunnelProvider struct {
+	path string
+
+	sync.Mutex
+	modTime time.Time
+	infos   map[string]TunnelInfo
+}
+
+// NewFileTunnelProvider creates a provider reading the tunnel metadata from the file at path
+func NewFileTunnelProvider(path string) *FileTunnelProvider {
+	return &FileTunnelProvider{path: path}
+}
+
+// TunnelInfos returns the tunnel metadata stored in the file
+func (p *FileTunnelProvider) TunnelInfos() (map[string]TunnelInfo, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	fileInfo, err := os.Stat(p.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			p.modTime, p.infos = time.Time{}, nil
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read tunnel metadata: %w", err)
+	}
+	if p.infos != nil && fileInfo.ModTime().Equal(p.modTime) {
+		return p.infos, nil
+	}
+
+	data, err := os.ReadFile(filepath.Clean(p.path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tunnel metadata: %w", err)
+	}
+	infos := make(map[string]TunnelInfo)
+	if err := yaml.Unmarshal(data, &infos); err != nil {
+		return nil, fmt.Errorf("failed to parse tunnel metadata from %s: %w", p.path, err)
+	}
+
+	p.modTime, p.infos = fileInfo.ModTime(), infos
+	return infos, nil
 }
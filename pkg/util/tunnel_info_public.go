@@ -8,11 +8,16 @@
 //
 /////////////////////////////////////////////////////////////////////////////////
 
+//go:build !OSAG
 // +build !OSAG
 
 package util
 
-// TunnelInfos is a no-op for the public release.
-func TunnelInfos() map[string]TunnelInfo {
-	return nil
+// DefaultTunnelsFile is the file the tunnel metadata is read from in the public release
+const DefaultTunnelsFile = "/etc/goprobe/tunnels.yaml"
+
+// The public release reads the tunnel metadata from file, other builds may set their own
+// provider via SetTunnelProvider()
+func init() {
+	SetTunnelProvider(NewFileTunnelProvider(DefaultTunnelsFile))
 }
@@ -0,0 +1,48 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileTunnelProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tunnels.yaml")
+	provider := NewFileTunnelProvider(path)
+
+	// a missing file denotes that there are no tunnels
+	infos, err := provider.TunnelInfos()
+	require.Nil(t, err)
+	require.Empty(t, infos)
+
+	require.Nil(t, os.WriteFile(path, []byte(`
+t4_zrh:
+  physical_iface: eth0
+  peer: 198.51.100.1
+  site: zurich
+`), 0600))
+	infos, err = provider.TunnelInfos()
+	require.Nil(t, err)
+	require.Equal(t, map[string]TunnelInfo{"t4_zrh": {PhysicalIface: "eth0", Peer: "198.51.100.1", Site: "zurich"}}, infos)
+
+	// modifications are picked up
+	require.Nil(t, os.WriteFile(path, []byte("t4_gva: {site: geneva}\n"), 0600))
+	require.Nil(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	infos, err = provider.TunnelInfos()
+	require.Nil(t, err)
+	require.Equal(t, map[string]TunnelInfo{"t4_gva": {Site: "geneva"}}, infos)
+
+	require.Nil(t, os.WriteFile(path, []byte("t4_gva: [\n"), 0600))
+	require.Nil(t, os.Chtimes(path, time.Now(), time.Now().Add(2*time.Minute)))
+	_, err = provider.TunnelInfos()
+	require.NotNil(t, err)
+
+	SetTunnelProvider(provider)
+	defer SetTunnelProvider(NewFileTunnelProvider(DefaultTunnelsFile))
+	infos, err = TunnelInfos()
+	require.NotNil(t, err)
+	require.Nil(t, infos)
+}
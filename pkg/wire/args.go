@@ -0,0 +1,215 @@
+package wire
+
+import (
+	"sort"
+
+	"github.com/els0r/goProbe/pkg/goDB/info"
+	"github.com/els0r/goProbe/pkg/query"
+)
+
+// MarshalArgs encodes query arguments (message Args)
+func MarshalArgs(args *query.Args) ([]byte, error) {
+	e := &encoder{}
+	e.string(1, args.Query)
+	e.string(2, args.Ifaces)
+	e.string(3, args.QueryHosts)
+	e.string(4, args.Tenant)
+
+	// sort the groups to obtain a deterministic encoding
+	groups := make([]string, 0, len(args.IfaceGroups))
+	for group := range args.IfaceGroups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		e.message(5, func(e *encoder) {
+			e.string(1, group)
+			e.message(2, func(e *encoder) {
+				e.strings(1, args.IfaceGroups[group])
+			})
+		})
+	}
+
+	e.string(6, args.Hostname)
+	e.uint(7, uint64(args.HostID))
+	e.string(8, args.Distinct)
+	e.bool(9, args.Distribution)
+	e.bool(10, args.Dedup)
+	e.string(11, args.Condition)
+	e.int(12, int64(args.IPVersion))
+	e.double(13, args.MaxDropPct)
+	e.bool(14, args.In)
+	e.bool(15, args.Out)
+	e.bool(16, args.Sum)
+	e.string(17, args.First)
+	e.string(18, args.Last)
+	e.strings(19, args.Windows)
+	e.string(20, args.Format)
+	e.string(21, args.SortBy)
+	e.uint(22, args.NumResults)
+	e.bool(23, args.SortAscending)
+	e.bool(24, args.RawUnits)
+	e.bool(25, args.TotalRow)
+	e.bool(26, args.Subtotals)
+	e.strings(27, args.Enrich)
+	e.strings(28, args.Filter)
+	e.bool(29, args.List)
+	e.bool(30, args.Version)
+	e.bool(31, args.Explain)
+	e.message(32, func(e *encoder) {
+		dns := args.DNSResolution
+		e.bool(1, dns.Enabled)
+		e.int(2, int64(dns.Timeout))
+		e.int(3, int64(dns.MaxRows))
+		e.strings(4, dns.Resolvers)
+		e.int(5, int64(dns.MaxConcurrency))
+		e.int(6, int64(dns.NegativeCacheTTL))
+	})
+	e.int(33, int64(args.MaxMemPct))
+	e.bool(34, args.LowMem)
+	e.int(35, int64(args.MaxBlocksPerSec))
+	e.int(36, int64(args.Workers))
+	e.bool(37, args.Approx)
+	e.string(38, args.Caller)
+	e.bool(39, args.Live)
+	e.string(40, args.Export)
+	e.bool(41, args.Verify)
+	e.bool(42, args.Anomalies)
+
+	return e.b, nil
+}
+
+// UnmarshalArgs decodes query arguments (message Args) into args. As is the case for JSON,
+// fields which aren't set in data retain their current value (e.g. the defaults)
+func UnmarshalArgs(data []byte, args *query.Args) error {
+	return decode(data, func(f field) error {
+		switch f.num {
+		case 1:
+			args.Query = f.string()
+		case 2:
+			args.Ifaces = f.string()
+		case 3:
+			args.QueryHosts = f.string()
+		case 4:
+			args.Tenant = f.string()
+		case 5:
+			var (
+				group  string
+				ifaces []string
+			)
+			err := decode(f.b, func(f field) error {
+				switch f.num {
+				case 1:
+					group = f.string()
+				case 2:
+					return decode(f.b, func(f field) error {
+						if f.num == 1 {
+							ifaces = append(ifaces, f.string())
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if args.IfaceGroups == nil {
+				args.IfaceGroups = make(info.Groups)
+			}
+			args.IfaceGroups[group] = ifaces
+		case 6:
+			args.Hostname = f.string()
+		case 7:
+			args.HostID = uint(f.uint())
+		case 8:
+			args.Distinct = f.string()
+		case 9:
+			args.Distribution = f.bool()
+		case 10:
+			args.Dedup = f.bool()
+		case 11:
+			args.Condition = f.string()
+		case 12:
+			args.IPVersion = int(f.int())
+		case 13:
+			args.MaxDropPct = f.double()
+		case 14:
+			args.In = f.bool()
+		case 15:
+			args.Out = f.bool()
+		case 16:
+			args.Sum = f.bool()
+		case 17:
+			args.First = f.string()
+		case 18:
+			args.Last = f.string()
+		case 19:
+			args.Windows = append(args.Windows, f.string())
+		case 20:
+			args.Format = f.string()
+		case 21:
+			args.SortBy = f.string()
+		case 22:
+			args.NumResults = f.uint()
+		case 23:
+			args.SortAscending = f.bool()
+		case 24:
+			args.RawUnits = f.bool()
+		case 25:
+			args.TotalRow = f.bool()
+		case 26:
+			args.Subtotals = f.bool()
+		case 27:
+			args.Enrich = append(args.Enrich, f.string())
+		case 28:
+			args.Filter = append(args.Filter, f.string())
+		case 29:
+			args.List = f.bool()
+		case 30:
+			args.Version = f.bool()
+		case 31:
+			args.Explain = f.bool()
+		case 32:
+			dns := &args.DNSResolution
+			return decode(f.b, func(f field) error {
+				switch f.num {
+				case 1:
+					dns.Enabled = f.bool()
+				case 2:
+					dns.Timeout = f.duration()
+				case 3:
+					dns.MaxRows = int(f.int())
+				case 4:
+					dns.Resolvers = append(dns.Resolvers, f.string())
+				case 5:
+					dns.MaxConcurrency = int(f.int())
+				case 6:
+					dns.NegativeCacheTTL = f.duration()
+				}
+				return nil
+			})
+		case 33:
+			args.MaxMemPct = int(f.int())
+		case 34:
+			args.LowMem = f.bool()
+		case 35:
+			args.MaxBlocksPerSec = int(f.int())
+		case 36:
+			args.Workers = int(f.int())
+		case 37:
+			args.Approx = f.bool()
+		case 38:
+			args.Caller = f.string()
+		case 39:
+			args.Live = f.bool()
+		case 40:
+			args.Export = f.string()
+		case 41:
+			args.Verify = f.bool()
+		case 42:
+			args.Anomalies = f.bool()
+		}
+		return nil
+	})
+}
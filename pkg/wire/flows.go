@@ -0,0 +1,169 @@
+package wire
+
+import (
+	"github.com/els0r/goProbe/pkg/capture/capturetypes"
+	"github.com/els0r/goProbe/pkg/types"
+)
+
+// FlowBatch denotes (a chunk of) the flows of an interface written out by a goProbe host at a
+// given time (message FlowBatch). It is streamed to an aggregator writing the flows of many hosts
+// to a central goDB
+type FlowBatch struct {
+	Hostname  string // Hostname: the hostname of the goProbe host. Example: sensor-01
+	HostID    string // HostID: the host ID of the goProbe host. Example: 2a0c4d1b
+	Timestamp int64  // Timestamp: unix timestamp of the writeout. Example: 1700000000
+	Iface     string // Iface: interface the flows were captured on. Example: eth0
+	Tenant    string // Tenant: tenant the interface is assigned to (if any). Example: acme
+
+	Stats capturetypes.CaptureStats // Stats: the capture statistics of the interface as of the writeout
+	Flows []Flow                    // Flows: the flows observed since the previous writeout
+}
+
+// Flow denotes a single aggregated flow of a FlowBatch
+type Flow struct {
+	Key      types.Key      // Key: the raw key of the flow (as stored in the flow maps)
+	Counters types.Counters // Counters: the counters of the flow
+	Process  string         // Process: the local process the flow was attributed to (if any). Example: nginx.service
+}
+
+// MarshalFlowBatch encodes a batch of flows (message FlowBatch)
+func MarshalFlowBatch(batch *FlowBatch) ([]byte, error) {
+	e := &encoder{
+		// the flows make up the bulk of the data (with a few dozen bytes each)
+		b: make([]byte, 0, 256+64*len(batch.Flows)),
+	}
+
+	e.string(1, batch.Hostname)
+	e.string(2, batch.HostID)
+	e.int(3, batch.Timestamp)
+	e.string(4, batch.Iface)
+	e.string(5, batch.Tenant)
+	e.message(6, func(e *encoder) { encodeCaptureStats(e, &batch.Stats) })
+	for i := range batch.Flows {
+		e.message(7, func(e *encoder) {
+			e.bytes(1, batch.Flows[i].Key)
+			e.message(2, func(e *encoder) { encodeCounters(e, batch.Flows[i].Counters) })
+			e.string(3, batch.Flows[i].Process)
+		})
+	}
+
+	return e.b, nil
+}
+
+// UnmarshalFlowBatch decodes a batch of flows (message FlowBatch) into batch
+func UnmarshalFlowBatch(data []byte, batch *FlowBatch) error {
+	return decode(data, func(f field) error {
+		switch f.num {
+		case 1:
+			batch.Hostname = f.string()
+		case 2:
+			batch.HostID = f.string()
+		case 3:
+			batch.Timestamp = f.int()
+		case 4:
+			batch.Iface = f.string()
+		case 5:
+			batch.Tenant = f.string()
+		case 6:
+			return decodeCaptureStats(f.b, &batch.Stats)
+		case 7:
+			var flow Flow
+			err := decode(f.b, func(f field) error {
+				switch f.num {
+				case 1:
+					flow.Key = append(types.Key{}, f.b...)
+				case 2:
+					return decodeCounters(f.b, &flow.Counters)
+				case 3:
+					flow.Process = f.string()
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			batch.Flows = append(batch.Flows, flow)
+		}
+		return nil
+	})
+}
+
+func encodeCaptureStats(e *encoder, s *capturetypes.CaptureStats) {
+	e.timestamp(1, s.StartedAt)
+	e.uint(2, s.Received)
+	e.uint(3, s.ReceivedTotal)
+	e.uint(4, s.Processed)
+	e.uint(5, s.ProcessedTotal)
+	e.uint(6, s.Dropped)
+	e.uint(7, s.DroppedTotal)
+	e.uint(8, s.Overruns)
+	e.string(9, string(s.LinkState))
+
+	parsingErrors := make([]uint64, len(s.ParsingErrors))
+	for i, n := range s.ParsingErrors {
+		parsingErrors[i] = uint64(n)
+	}
+	e.packedUints(10, parsingErrors)
+}
+
+func decodeCaptureStats(b []byte, s *capturetypes.CaptureStats) error {
+	return decode(b, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			s.StartedAt, err = f.timestamp()
+		case 2:
+			s.Received = f.uint()
+		case 3:
+			s.ReceivedTotal = f.uint()
+		case 4:
+			s.Processed = f.uint()
+		case 5:
+			s.ProcessedTotal = f.uint()
+		case 6:
+			s.Dropped = f.uint()
+		case 7:
+			s.DroppedTotal = f.uint()
+		case 8:
+			s.Overruns = f.uint()
+		case 9:
+			s.LinkState = capturetypes.LinkState(f.string())
+		case 10:
+			var parsingErrors []uint64
+			if parsingErrors, err = f.packedUints(); err != nil {
+				return err
+			}
+			// error types unknown to this version are skipped
+			for i := 0; i < len(parsingErrors) && i < len(s.ParsingErrors); i++ {
+				s.ParsingErrors[i] = int(parsingErrors[i])
+			}
+		}
+		return err
+	})
+}
+
+// WriteSummary acknowledges the flows stored by an aggregator (message WriteSummary)
+type WriteSummary struct {
+	Batches int // Batches: the number of batches received. Example: 4
+	Flows   int // Flows: the number of flows stored. Example: 65536
+}
+
+// MarshalWriteSummary encodes a write summary (message WriteSummary)
+func MarshalWriteSummary(summary *WriteSummary) ([]byte, error) {
+	e := &encoder{}
+	e.int(1, int64(summary.Batches))
+	e.int(2, int64(summary.Flows))
+	return e.b, nil
+}
+
+// UnmarshalWriteSummary decodes a write summary (message WriteSummary) into summary
+func UnmarshalWriteSummary(data []byte, summary *WriteSummary) error {
+	return decode(data, func(f field) error {
+		switch f.num {
+		case 1:
+			summary.Batches = int(f.int())
+		case 2:
+			summary.Flows = int(f.int())
+		}
+		return nil
+	})
+}
@@ -0,0 +1,610 @@
+package wire
+
+import (
+	"sort"
+
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/goProbe/pkg/types/hll"
+)
+
+// MarshalResult encodes a query result (message Result)
+func MarshalResult(res *results.Result) ([]byte, error) {
+	e := &encoder{
+		// the rows make up the bulk of the data (with a few dozen bytes each)
+		b: make([]byte, 0, 256+64*len(res.Rows)),
+	}
+
+	var err error
+	e.message(1, func(e *encoder) { encodeStatus(e, res.Status) })
+
+	// sort the hosts to obtain a deterministic encoding
+	hosts := make([]string, 0, len(res.HostsStatuses))
+	for host := range res.HostsStatuses {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		e.message(2, func(e *encoder) {
+			e.string(1, host)
+			e.message(2, func(e *encoder) { encodeStatus(e, res.HostsStatuses[host]) })
+		})
+	}
+
+	e.message(3, func(e *encoder) { encodeSummary(e, &res.Summary) })
+	e.message(4, func(e *encoder) { encodeQuery(e, &res.Query) })
+	for i := range res.Rows {
+		e.message(5, func(e *encoder) {
+			if rowErr := encodeRow(e, &res.Rows[i]); rowErr != nil && err == nil {
+				err = rowErr
+			}
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return e.b, nil
+}
+
+// UnmarshalResult decodes a query result (message Result) into res
+func UnmarshalResult(data []byte, res *results.Result) error {
+	if res.HostsStatuses == nil {
+		res.HostsStatuses = make(results.HostsStatuses)
+	}
+	return decode(data, func(f field) error {
+		switch f.num {
+		case 1:
+			return decodeStatus(f.b, &res.Status)
+		case 2:
+			var (
+				host   string
+				status results.Status
+			)
+			err := decode(f.b, func(f field) error {
+				switch f.num {
+				case 1:
+					host = f.string()
+				case 2:
+					return decodeStatus(f.b, &status)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			res.HostsStatuses[host] = status
+		case 3:
+			return decodeSummary(f.b, &res.Summary)
+		case 4:
+			return decodeQuery(f.b, &res.Query)
+		case 5:
+			var row results.Row
+			if err := decodeRow(f.b, &row); err != nil {
+				return err
+			}
+			res.Rows = append(res.Rows, row)
+		}
+		return nil
+	})
+}
+
+func encodeStatus(e *encoder, s results.Status) {
+	e.string(1, string(s.Code))
+	e.string(2, s.Message)
+	e.int(3, int64(s.ClockSkew))
+}
+
+func decodeStatus(b []byte, s *results.Status) error {
+	return decode(b, func(f field) error {
+		switch f.num {
+		case 1:
+			s.Code = types.Status(f.string())
+		case 2:
+			s.Message = f.string()
+		case 3:
+			s.ClockSkew = f.duration()
+		}
+		return nil
+	})
+}
+
+func encodeCounters(e *encoder, c types.Counters) {
+	e.uint(1, c.BytesRcvd)
+	e.uint(2, c.BytesSent)
+	e.uint(3, c.PacketsRcvd)
+	e.uint(4, c.PacketsSent)
+}
+
+func decodeCounters(b []byte, c *types.Counters) error {
+	return decode(b, func(f field) error {
+		switch f.num {
+		case 1:
+			c.BytesRcvd = f.uint()
+		case 2:
+			c.BytesSent = f.uint()
+		case 3:
+			c.PacketsRcvd = f.uint()
+		case 4:
+			c.PacketsSent = f.uint()
+		}
+		return nil
+	})
+}
+
+func encodeSummary(e *encoder, s *results.Summary) {
+	e.strings(1, s.Interfaces)
+	e.timestamp(2, s.First)
+	e.timestamp(3, s.Last)
+	e.message(4, func(e *encoder) { encodeCounters(e, s.Totals) })
+	e.message(5, func(e *encoder) {
+		e.timestamp(1, s.Timings.QueryStart)
+		e.int(2, int64(s.Timings.QueryDuration))
+		e.int(3, int64(s.Timings.ResolutionDuration))
+		e.int(4, int64(s.Timings.ScanDuration))
+		e.int(5, int64(s.Timings.FinalizeDuration))
+	})
+	e.message(6, func(e *encoder) {
+		e.int(1, int64(s.Hits.Displayed))
+		e.int(2, int64(s.Hits.Total))
+	})
+	for _, gap := range s.Gaps {
+		e.message(7, func(e *encoder) {
+			e.string(1, gap.Iface)
+			e.string(2, gap.Hostname)
+			e.timestamp(3, gap.First)
+			e.timestamp(4, gap.Last)
+			e.bool(5, gap.LinkDown)
+		})
+	}
+	if a := s.Approximation; a != nil {
+		e.message(8, func(e *encoder) {
+			e.int(1, int64(a.Capacity))
+			e.uint(2, a.MaxError)
+		})
+	}
+	if d := s.Deduplication; d != nil {
+		e.message(9, func(e *encoder) {
+			e.int(1, int64(d.Flows))
+			e.message(2, func(e *encoder) { encodeCounters(e, d.Counters) })
+		})
+	}
+	if q := s.CaptureQuality; q != nil {
+		e.message(10, func(e *encoder) {
+			e.uint(1, q.Packets)
+			e.uint(2, q.Dropped)
+			e.uint(3, q.Overruns)
+			e.uint(4, q.Truncated)
+			e.uint(5, q.BlocksExcluded)
+		})
+	}
+	if r := s.Resources; r != nil {
+		e.message(11, func(e *encoder) {
+			e.uint(1, r.PeakMemory)
+			e.uint(2, r.BlocksScanned)
+			e.uint(3, r.BlocksSkipped)
+			e.uint(4, r.BytesDecompressed)
+		})
+	}
+	for _, c := range s.Corruptions {
+		e.message(12, func(e *encoder) {
+			e.string(1, c.Iface)
+			e.string(2, c.Hostname)
+			e.timestamp(3, c.Timestamp)
+			e.string(4, c.Column)
+			e.int(5, int64(c.Expected))
+			e.int(6, int64(c.Found))
+			e.string(7, c.Error)
+		})
+	}
+	for _, a := range s.Anomalies {
+		e.message(13, func(e *encoder) {
+			e.string(1, a.Iface)
+			e.string(2, a.Hostname)
+			e.timestamp(3, a.First)
+			e.timestamp(4, a.Last)
+			e.message(5, func(e *encoder) { encodeCounters(e, a.Totals) })
+			e.message(6, func(e *encoder) { encodeCounters(e, a.Baseline) })
+			e.int(7, int64(a.BaselineDays))
+			e.double(8, a.Ratio)
+			e.bool(9, a.Unusual)
+		})
+	}
+}
+
+func decodeSummary(b []byte, s *results.Summary) error {
+	return decode(b, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			s.Interfaces = append(s.Interfaces, f.string())
+		case 2:
+			s.First, err = f.timestamp()
+		case 3:
+			s.Last, err = f.timestamp()
+		case 4:
+			err = decodeCounters(f.b, &s.Totals)
+		case 5:
+			err = decode(f.b, func(f field) (err error) {
+				switch f.num {
+				case 1:
+					s.Timings.QueryStart, err = f.timestamp()
+				case 2:
+					s.Timings.QueryDuration = f.duration()
+				case 3:
+					s.Timings.ResolutionDuration = f.duration()
+				case 4:
+					s.Timings.ScanDuration = f.duration()
+				case 5:
+					s.Timings.FinalizeDuration = f.duration()
+				}
+				return
+			})
+		case 6:
+			err = decode(f.b, func(f field) error {
+				switch f.num {
+				case 1:
+					s.Hits.Displayed = int(f.int())
+				case 2:
+					s.Hits.Total = int(f.int())
+				}
+				return nil
+			})
+		case 7:
+			var gap results.CoverageGap
+			err = decode(f.b, func(f field) (err error) {
+				switch f.num {
+				case 1:
+					gap.Iface = f.string()
+				case 2:
+					gap.Hostname = f.string()
+				case 3:
+					gap.First, err = f.timestamp()
+				case 4:
+					gap.Last, err = f.timestamp()
+				case 5:
+					gap.LinkDown = f.bool()
+				}
+				return
+			})
+			s.Gaps = append(s.Gaps, gap)
+		case 8:
+			a := new(results.Approximation)
+			err = decode(f.b, func(f field) error {
+				switch f.num {
+				case 1:
+					a.Capacity = int(f.int())
+				case 2:
+					a.MaxError = f.uint()
+				}
+				return nil
+			})
+			s.Approximation = a
+		case 9:
+			d := new(results.Deduplication)
+			err = decode(f.b, func(f field) error {
+				switch f.num {
+				case 1:
+					d.Flows = int(f.int())
+				case 2:
+					return decodeCounters(f.b, &d.Counters)
+				}
+				return nil
+			})
+			s.Deduplication = d
+		case 10:
+			q := new(results.CaptureQuality)
+			err = decode(f.b, func(f field) error {
+				switch f.num {
+				case 1:
+					q.Packets = f.uint()
+				case 2:
+					q.Dropped = f.uint()
+				case 3:
+					q.Overruns = f.uint()
+				case 4:
+					q.Truncated = f.uint()
+				case 5:
+					q.BlocksExcluded = f.uint()
+				}
+				return nil
+			})
+			s.CaptureQuality = q
+		case 11:
+			r := new(results.Resources)
+			err = decode(f.b, func(f field) error {
+				switch f.num {
+				case 1:
+					r.PeakMemory = f.uint()
+				case 2:
+					r.BlocksScanned = f.uint()
+				case 3:
+					r.BlocksSkipped = f.uint()
+				case 4:
+					r.BytesDecompressed = f.uint()
+				}
+				return nil
+			})
+			s.Resources = r
+		case 12:
+			var c results.Corruption
+			err = decode(f.b, func(f field) (err error) {
+				switch f.num {
+				case 1:
+					c.Iface = f.string()
+				case 2:
+					c.Hostname = f.string()
+				case 3:
+					c.Timestamp, err = f.timestamp()
+				case 4:
+					c.Column = f.string()
+				case 5:
+					c.Expected = int(f.int())
+				case 6:
+					c.Found = int(f.int())
+				case 7:
+					c.Error = f.string()
+				}
+				return
+			})
+			s.Corruptions = append(s.Corruptions, c)
+		case 13:
+			var a results.Anomaly
+			err = decode(f.b, func(f field) (err error) {
+				switch f.num {
+				case 1:
+					a.Iface = f.string()
+				case 2:
+					a.Hostname = f.string()
+				case 3:
+					a.First, err = f.timestamp()
+				case 4:
+					a.Last, err = f.timestamp()
+				case 5:
+					err = decodeCounters(f.b, &a.Totals)
+				case 6:
+					err = decodeCounters(f.b, &a.Baseline)
+				case 7:
+					a.BaselineDays = int(f.int())
+				case 8:
+					a.Ratio = f.double()
+				case 9:
+					a.Unusual = f.bool()
+				}
+				return
+			})
+			s.Anomalies = append(s.Anomalies, a)
+		}
+		return
+	})
+}
+
+func encodeQuery(e *encoder, q *results.Query) {
+	e.strings(1, q.Attributes)
+	e.string(2, q.Condition)
+	e.string(3, q.Distinct)
+	if p := q.Plan; p != nil {
+		e.message(4, func(e *encoder) {
+			e.strings(1, p.Columns)
+
+			ifaces := make([]string, 0, len(p.Directories))
+			for iface := range p.Directories {
+				ifaces = append(ifaces, iface)
+			}
+			sort.Strings(ifaces)
+			for _, iface := range ifaces {
+				e.message(2, func(e *encoder) {
+					e.string(1, iface)
+					e.int(2, int64(p.Directories[iface]))
+				})
+			}
+
+			e.int(3, int64(p.Workers))
+		})
+	}
+}
+
+func decodeQuery(b []byte, q *results.Query) error {
+	return decode(b, func(f field) error {
+		switch f.num {
+		case 1:
+			q.Attributes = append(q.Attributes, f.string())
+		case 2:
+			q.Condition = f.string()
+		case 3:
+			q.Distinct = f.string()
+		case 4:
+			p := new(results.QueryPlan)
+			q.Plan = p
+			return decode(f.b, func(f field) error {
+				switch f.num {
+				case 1:
+					p.Columns = append(p.Columns, f.string())
+				case 2:
+					var (
+						iface string
+						dirs  int
+					)
+					err := decode(f.b, func(f field) error {
+						switch f.num {
+						case 1:
+							iface = f.string()
+						case 2:
+							dirs = int(f.int())
+						}
+						return nil
+					})
+					if err != nil {
+						return err
+					}
+					if p.Directories == nil {
+						p.Directories = make(map[string]int)
+					}
+					p.Directories[iface] = dirs
+				case 3:
+					p.Workers = int(f.int())
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+}
+
+func encodeRow(e *encoder, row *results.Row) (err error) {
+	e.message(1, func(e *encoder) {
+		e.timestamp(1, row.Labels.Timestamp)
+		e.string(2, row.Labels.Iface)
+		e.string(3, row.Labels.Hostname)
+		e.string(4, row.Labels.HostID)
+		e.string(5, row.Labels.Process)
+		e.string(6, row.Labels.Direction)
+
+		// the label columns are encoded in order of their names (see results.NewLabelColumns())
+		columns := row.Labels.Columns.Map()
+		names := make([]string, 0, len(columns))
+		for name := range columns {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			e.message(7, func(e *encoder) {
+				e.string(1, name)
+				e.string(2, columns[name])
+			})
+		}
+	})
+	e.message(2, func(e *encoder) {
+		e.addr(1, row.Attributes.SrcIP)
+		e.addr(2, row.Attributes.DstIP)
+		e.uint(3, uint64(row.Attributes.IPProto))
+		e.uint(4, uint64(row.Attributes.DstPort))
+	})
+	e.message(3, func(e *encoder) { encodeCounters(e, row.Counters) })
+	if row.Distinct != nil {
+		var registers []byte
+		if registers, err = row.Distinct.MarshalBinary(); err != nil {
+			return err
+		}
+		e.bytes(4, registers)
+	}
+	if row.Distribution != nil {
+		e.packedUints(5, row.Distribution[:])
+	}
+	if h := row.Hostnames; h != nil {
+		e.message(6, func(e *encoder) {
+			e.string(1, h.SrcHost)
+			e.string(2, h.DstHost)
+		})
+	}
+
+	// sort the columns to obtain a deterministic encoding
+	if len(row.Enrichments) > 0 {
+		columns := make([]string, 0, len(row.Enrichments))
+		for column := range row.Enrichments {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+		for _, column := range columns {
+			e.message(7, func(e *encoder) {
+				e.string(1, column)
+				e.string(2, row.Enrichments[column])
+			})
+		}
+	}
+	return nil
+}
+
+func decodeRow(b []byte, row *results.Row) error {
+	return decode(b, func(f field) (err error) {
+		switch f.num {
+		case 1:
+			var columns map[string]string
+			err = decode(f.b, func(f field) (err error) {
+				switch f.num {
+				case 1:
+					row.Labels.Timestamp, err = f.timestamp()
+				case 2:
+					row.Labels.Iface = f.string()
+				case 3:
+					row.Labels.Hostname = f.string()
+				case 4:
+					row.Labels.HostID = f.string()
+				case 5:
+					row.Labels.Process = f.string()
+				case 6:
+					row.Labels.Direction = f.string()
+				case 7:
+					var name, value string
+					err = decode(f.b, func(f field) error {
+						switch f.num {
+						case 1:
+							name = f.string()
+						case 2:
+							value = f.string()
+						}
+						return nil
+					})
+					if columns == nil {
+						columns = make(map[string]string)
+					}
+					columns[name] = value
+				}
+				return
+			})
+			row.Labels.Columns = results.NewLabelColumns(columns)
+		case 2:
+			err = decode(f.b, func(f field) (err error) {
+				switch f.num {
+				case 1:
+					row.Attributes.SrcIP, err = f.addr()
+				case 2:
+					row.Attributes.DstIP, err = f.addr()
+				case 3:
+					row.Attributes.IPProto = uint8(f.uint())
+				case 4:
+					row.Attributes.DstPort = uint16(f.uint())
+				}
+				return
+			})
+		case 3:
+			err = decodeCounters(f.b, &row.Counters)
+		case 4:
+			row.Distinct = hll.New()
+			err = row.Distinct.UnmarshalBinary(f.b)
+		case 5:
+			var values []uint64
+			if values, err = f.packedUints(); err != nil {
+				return err
+			}
+			row.Distribution = new(results.Distribution)
+			copy(row.Distribution[:], values)
+		case 6:
+			row.Hostnames = new(results.Hostnames)
+			err = decode(f.b, func(f field) error {
+				switch f.num {
+				case 1:
+					row.Hostnames.SrcHost = f.string()
+				case 2:
+					row.Hostnames.DstHost = f.string()
+				}
+				return nil
+			})
+		case 7:
+			var column, value string
+			err = decode(f.b, func(f field) error {
+				switch f.num {
+				case 1:
+					column = f.string()
+				case 2:
+					value = f.string()
+				}
+				return nil
+			})
+			if row.Enrichments == nil {
+				row.Enrichments = make(map[string]string)
+			}
+			row.Enrichments[column] = value
+		}
+		return
+	})
+}
@@ -0,0 +1,201 @@
+// Package wire implements the binary (protobuf) wire format of the query arguments and results
+// exchanged between goProbe components (e.g. global-query and the goProbe hosts it queries), as well
+// as of the flows streamed to an aggregator. The schema is defined in wire.proto, which is the
+// reference for the field numbers and types.
+//
+// Compatibility between versions follows the protobuf rules: fields unknown to the decoder are
+// skipped and fields missing in the encoded data retain their (default) value. Hence, fields may
+// be added (using new field numbers), but the numbers / types of existing fields must never change
+package wire
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ContentType is the HTTP content type of data encoded in the wire format
+const ContentType = "application/x-protobuf"
+
+// ErrInvalidData is returned if data cannot be decoded
+var ErrInvalidData = errors.New("invalid wire format data")
+
+// encoder appends the fields of a message to its buffer. Fields holding their zero value are
+// omitted (as done by proto3)
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) uint(num protowire.Number, v uint64) {
+	if v == 0 {
+		return
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.VarintType)
+	e.b = protowire.AppendVarint(e.b, v)
+}
+
+func (e *encoder) int(num protowire.Number, v int64) {
+	e.uint(num, uint64(v))
+}
+
+func (e *encoder) bool(num protowire.Number, v bool) {
+	if v {
+		e.uint(num, 1)
+	}
+}
+
+func (e *encoder) double(num protowire.Number, v float64) {
+	if v == 0 {
+		return
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.Fixed64Type)
+	e.b = protowire.AppendFixed64(e.b, math.Float64bits(v))
+}
+
+func (e *encoder) bytes(num protowire.Number, v []byte) {
+	if len(v) == 0 {
+		return
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.BytesType)
+	e.b = protowire.AppendBytes(e.b, v)
+}
+
+func (e *encoder) string(num protowire.Number, v string) {
+	if v == "" {
+		return
+	}
+	e.b = protowire.AppendTag(e.b, num, protowire.BytesType)
+	e.b = protowire.AppendString(e.b, v)
+}
+
+func (e *encoder) strings(num protowire.Number, vs []string) {
+	for _, v := range vs {
+		e.b = protowire.AppendTag(e.b, num, protowire.BytesType)
+		e.b = protowire.AppendString(e.b, v)
+	}
+}
+
+func (e *encoder) packedUints(num protowire.Number, vs []uint64) {
+	e.message(num, func(e *encoder) {
+		for _, v := range vs {
+			e.b = protowire.AppendVarint(e.b, v)
+		}
+	})
+}
+
+func (e *encoder) addr(num protowire.Number, v netip.Addr) {
+	if v.IsValid() {
+		e.bytes(num, v.AsSlice())
+	}
+}
+
+// timestamp encodes a time as google.protobuf.Timestamp
+func (e *encoder) timestamp(num protowire.Number, v time.Time) {
+	if v.IsZero() {
+		return
+	}
+	e.message(num, func(e *encoder) {
+		e.int(1, v.Unix())
+		e.int(2, int64(v.Nanosecond()))
+	})
+}
+
+// message encodes a nested message. It is appended in place, moving it once its length (and hence
+// the size of the length prefix) is known
+func (e *encoder) message(num protowire.Number, fn func(e *encoder)) {
+	e.b = protowire.AppendTag(e.b, num, protowire.BytesType)
+	start := len(e.b)
+	fn(e)
+
+	n := len(e.b) - start
+	prefixLen := protowire.SizeVarint(uint64(n))
+	e.b = append(e.b, make([]byte, prefixLen)...)
+	copy(e.b[start+prefixLen:], e.b[start:start+n])
+	protowire.AppendVarint(e.b[start:start], uint64(n))
+}
+
+// field denotes a single decoded field of a message
+type field struct {
+	num protowire.Number
+
+	v uint64 // v: the value of varint / fixed size fields
+	b []byte // b: the value of length-delimited fields
+}
+
+func (f field) uint() uint64            { return f.v }
+func (f field) int() int64              { return int64(f.v) }
+func (f field) bool() bool              { return f.v != 0 }
+func (f field) double() float64         { return math.Float64frombits(f.v) }
+func (f field) string() string          { return string(f.b) }
+func (f field) duration() time.Duration { return time.Duration(f.v) }
+
+func (f field) addr() (netip.Addr, error) {
+	addr, ok := netip.AddrFromSlice(f.b)
+	if !ok {
+		return netip.Addr{}, fmt.Errorf("%w: IP address of length %d in field %d", ErrInvalidData, len(f.b), f.num)
+	}
+	return addr, nil
+}
+
+func (f field) timestamp() (t time.Time, err error) {
+	var sec, nsec int64
+	err = decode(f.b, func(f field) error {
+		switch f.num {
+		case 1:
+			sec = f.int()
+		case 2:
+			nsec = f.int()
+		}
+		return nil
+	})
+	return time.Unix(sec, nsec), err
+}
+
+func (f field) packedUints() (vs []uint64, err error) {
+	for b := f.b; len(b) > 0; {
+		v, n := protowire.ConsumeVarint(b)
+		if n < 0 {
+			return nil, fmt.Errorf("%w: field %d: %w", ErrInvalidData, f.num, protowire.ParseError(n))
+		}
+		vs = append(vs, v)
+		b = b[n:]
+	}
+	return vs, nil
+}
+
+// decode calls fn for each field of the message b. Fields unknown to fn are expected to be
+// ignored by it, allowing to decode data encoded by newer versions
+func decode(b []byte, fn func(f field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("%w: %w", ErrInvalidData, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		f := field{num: num}
+		switch typ {
+		case protowire.VarintType:
+			f.v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			f.v, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			f.b, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return fmt.Errorf("%w: field %d: %w", ErrInvalidData, num, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package wire
+
+import (
+	"fmt"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/els0r/goProbe/pkg/query"
+	"github.com/els0r/goProbe/pkg/results"
+	"github.com/els0r/goProbe/pkg/types"
+	"github.com/els0r/goProbe/pkg/types/hll"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// fill assigns distinct non-zero values to all exported fields (serialized to JSON) of v, so that
+// fields missing in the wire format are caught by the round trip tests
+func fill(v reflect.Value, n *int) {
+	*n++
+	switch v.Interface().(type) {
+	case time.Time:
+		v.Set(reflect.ValueOf(time.Unix(int64(1700000000+*n), int64(*n))))
+		return
+	case netip.Addr:
+		if *n%2 == 0 {
+			v.Set(reflect.ValueOf(netip.AddrFrom4([4]byte{10, 0, byte(*n >> 8), byte(*n)})))
+		} else {
+			v.Set(reflect.ValueOf(netip.AddrFrom16([16]byte{0: 0xfe, 1: 0x80, 14: byte(*n >> 8), 15: byte(*n)})))
+		}
+		return
+	case results.LabelColumns:
+		v.Set(reflect.ValueOf(results.NewLabelColumns(map[string]string{"vlan": fmt.Sprint(*n), "zone": fmt.Sprintf("zone%d", *n)})))
+		return
+	case hll.Sketch:
+		s := hll.New()
+		s.Add([]byte(fmt.Sprint(*n)))
+		v.Set(reflect.ValueOf(*s))
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(fmt.Sprintf("value%d", *n))
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(*n))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(*n))
+	case reflect.Float64:
+		v.SetFloat(float64(*n) + 0.5)
+	case reflect.Pointer:
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem(), n)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			fill(v.Index(i), n)
+		}
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 2, 2))
+		for i := 0; i < v.Len(); i++ {
+			fill(v.Index(i), n)
+		}
+	case reflect.Map:
+		v.Set(reflect.MakeMap(v.Type()))
+		for i := 0; i < 2; i++ {
+			key, val := reflect.New(v.Type().Key()).Elem(), reflect.New(v.Type().Elem()).Elem()
+			fill(key, n)
+			fill(val, n)
+			v.SetMapIndex(key, val)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() || v.Type().Field(i).Tag.Get("json") == "-" {
+				continue
+			}
+			fill(v.Field(i), n)
+		}
+	default:
+		panic(fmt.Sprintf("unsupported kind %s", v.Kind()))
+	}
+}
+
+func TestArgsRoundTrip(t *testing.T) {
+	var args query.Args
+	fill(reflect.ValueOf(&args).Elem(), new(int))
+
+	data, err := MarshalArgs(&args)
+	require.Nil(t, err)
+
+	var decoded query.Args
+	require.Nil(t, UnmarshalArgs(data, &decoded))
+	require.Equal(t, args, decoded)
+
+	// fields not set retain the defaults
+	decoded = *query.DefaultArgs()
+	require.Nil(t, UnmarshalArgs(nil, &decoded))
+	require.Equal(t, *query.DefaultArgs(), decoded)
+
+	data, err = MarshalArgs(&query.Args{Query: "sip", Ifaces: "eth0", DNSResolution: query.DNSResolution{Enabled: true}})
+	require.Nil(t, err)
+	require.Nil(t, UnmarshalArgs(data, &decoded))
+	require.Equal(t, "sip", decoded.Query)
+	require.True(t, decoded.DNSResolution.Enabled)
+	require.Equal(t, query.DefaultArgs().DNSResolution.Timeout, decoded.DNSResolution.Timeout)
+	require.Equal(t, query.DefaultArgs().SortBy, decoded.SortBy)
+}
+
+func TestResultRoundTrip(t *testing.T) {
+	var res results.Result
+	fill(reflect.ValueOf(&res).Elem(), new(int))
+
+	data, err := MarshalResult(&res)
+	require.Nil(t, err)
+
+	var decoded results.Result
+	require.Nil(t, UnmarshalResult(data, &decoded))
+	require.Equal(t, res, decoded)
+
+	// the encoding is deterministic
+	data2, err := MarshalResult(&decoded)
+	require.Nil(t, err)
+	require.Equal(t, data, data2)
+
+	// empty rows / optional fields
+	res = results.Result{
+		Status:        results.Status{Code: types.StatusEmpty},
+		HostsStatuses: results.HostsStatuses{},
+		Rows:          results.Rows{{}},
+	}
+	data, err = MarshalResult(&res)
+	require.Nil(t, err)
+
+	decoded = results.Result{}
+	require.Nil(t, UnmarshalResult(data, &decoded))
+	require.Equal(t, res, decoded)
+}
+
+func TestFlowBatchRoundTrip(t *testing.T) {
+	var batch FlowBatch
+	fill(reflect.ValueOf(&batch).Elem(), new(int))
+
+	data, err := MarshalFlowBatch(&batch)
+	require.Nil(t, err)
+
+	var decoded FlowBatch
+	require.Nil(t, UnmarshalFlowBatch(data, &decoded))
+	require.Equal(t, batch, decoded)
+
+	var summary WriteSummary
+	fill(reflect.ValueOf(&summary).Elem(), new(int))
+
+	data, err = MarshalWriteSummary(&summary)
+	require.Nil(t, err)
+
+	var decodedSummary WriteSummary
+	require.Nil(t, UnmarshalWriteSummary(data, &decodedSummary))
+	require.Equal(t, summary, decodedSummary)
+}
+
+func TestUnknownFields(t *testing.T) {
+	var res results.Result
+	fill(reflect.ValueOf(&res).Elem(), new(int))
+
+	data, err := MarshalResult(&res)
+	require.Nil(t, err)
+
+	// fields added by a newer version are skipped
+	data = protowire.AppendTag(data, 1000, protowire.VarintType)
+	data = protowire.AppendVarint(data, 42)
+	data = protowire.AppendTag(data, 1001, protowire.BytesType)
+	data = protowire.AppendString(data, "new field")
+	data = protowire.AppendTag(data, 1002, protowire.Fixed32Type)
+	data = protowire.AppendFixed32(data, 42)
+
+	var decoded results.Result
+	require.Nil(t, UnmarshalResult(data, &decoded))
+	require.Equal(t, res, decoded)
+}
+
+func TestInvalidData(t *testing.T) {
+	var res results.Result
+	fill(reflect.ValueOf(&res).Elem(), new(int))
+
+	data, err := MarshalResult(&res)
+	require.Nil(t, err)
+
+	require.ErrorIs(t, UnmarshalResult(data[:len(data)-1], &results.Result{}), ErrInvalidData)
+	require.ErrorIs(t, UnmarshalArgs([]byte{0xff}, &query.Args{}), ErrInvalidData)
+}
+
+func benchmarkResult(nRows int) *results.Result {
+	res := results.New()
+	res.Start()
+	res.Summary.Interfaces = []string{"eth0"}
+	for i := 0; i < nRows; i++ {
+		res.Rows = append(res.Rows, results.Row{
+			Labels: results.Labels{Iface: "eth0", Hostname: "host", HostID: "id", Process: "nginx.service"},
+			Attributes: results.Attributes{
+				SrcIP:   netip.AddrFrom4([4]byte{10, 0, byte(i >> 8), byte(i)}),
+				DstIP:   netip.AddrFrom4([4]byte{10, 1, byte(i >> 8), byte(i)}),
+				IPProto: 6,
+				DstPort: 443,
+			},
+			Counters: types.Counters{BytesRcvd: uint64(i * 1000), BytesSent: uint64(i * 100), PacketsRcvd: uint64(i * 10), PacketsSent: uint64(i)},
+		})
+	}
+	res.End()
+	return res
+}
+
+func BenchmarkResultEncoding(b *testing.B) {
+	res := benchmarkResult(10000)
+
+	for _, format := range []string{"wire", "json"} {
+		marshal, unmarshal := MarshalResult, UnmarshalResult
+		if format == "json" {
+			marshal = func(res *results.Result) ([]byte, error) { return jsoniter.Marshal(res) }
+			unmarshal = func(data []byte, res *results.Result) error { return jsoniter.Unmarshal(data, res) }
+		}
+		data, err := marshal(res)
+		require.Nil(b, err)
+
+		b.Run(format+"/marshal", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, _ = marshal(res)
+			}
+		})
+		b.Run(format+"/unmarshal", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				var decoded results.Result
+				_ = unmarshal(data, &decoded)
+			}
+		})
+	}
+}